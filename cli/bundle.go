@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportOutputFlag    string
+	exportImageFlag     string
+	importNameFlag      string
+	importWorkspaceFlag string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [name]",
+	Short: "Export an environment definition as a bundle",
+	Long: `Write a portable bundle containing the environment's YAML, devcontainer.json
+and Dockerfile, optionally with a prebuilt image reference.
+Example: tape export myenv -o myenv.tar.gz`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		output := exportOutputFlag
+		if output == "" {
+			output = envName + ".tar.gz"
+		}
+
+		file, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", output, err)
+		}
+		defer file.Close()
+
+		if err := core.ExportBundle(envName, file, exportImageFlag); err != nil {
+			file.Close()
+			os.Remove(output)
+			return fmt.Errorf("error exporting %s: %w", envName, err)
+		}
+
+		fmt.Printf("Exported %s to %s\n", envName, output)
+		return nil
+	},
+}
+
+var importCmd = &cobra.Command{
+	Use:   "import [bundle]",
+	Short: "Import an environment definition from a bundle",
+	Long: `Install an environment from a bundle written by tape export. Its hooks, host and
+docker-host settings aren't imported, since they run commands on or choose the host.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("error opening bundle: %w", err)
+		}
+		defer file.Close()
+
+		envName, err := core.ImportBundle(file, importNameFlag, importWorkspaceFlag)
+		if err != nil {
+			return fmt.Errorf("error importing bundle: %w", err)
+		}
+
+		fmt.Printf("Imported %s to %s\n", envName, core.BoxConfigPath(envName))
+		return nil
+	},
+}
+
+func init() {
+	exportCmd.Flags().StringVarP(&exportOutputFlag, "output", "o", "", "Bundle path (default <name>.tar.gz)")
+	exportCmd.Flags().StringVar(&exportImageFlag, "image", "", "Prebuilt image reference to record in the bundle")
+	importCmd.Flags().StringVar(&importNameFlag, "name", "", "Environment name (default the exported name)")
+	importCmd.Flags().StringVar(&importWorkspaceFlag, "workspace", "", "Workspace path for the imported environment")
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage toolchain caches shared between dev environments",
+	Long: `Boxes list the caches they use in their config, e.g. caches: [go, node].
+Each cache is a named volume shared by every box that uses it.`,
+}
+
+var cacheLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List cache volumes",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		caches, err := core.ListCaches()
+		if err != nil {
+			return fmt.Errorf("error listing caches: %w", err)
+		}
+
+		t := newTable("CACHE", "VOLUME", "SIZE", "ENVIRONMENTS")
+		for _, cache := range caches {
+			boxes := strings.Join(cache.Boxes, ",")
+			if boxes == "" {
+				boxes = "-"
+			}
+			t.addRow(cache.Cache, cache.Name, formatSize(cache.Size), boxes)
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+var cacheClearCmd = &cobra.Command{
+	Use:   "clear [cache...]",
+	Short: "Remove cache volumes",
+	Long:  `Remove the named caches, or all of them. Stop the environments using a cache first.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		removed, err := core.ClearCaches(args)
+		for _, name := range removed {
+			fmt.Printf("Cleared %s\n", name)
+		}
+		if err != nil {
+			return fmt.Errorf("error clearing caches: %w", err)
+		}
+		if len(removed) == 0 {
+			fmt.Println("Nothing to clear")
+		}
+		return nil
+	},
+}
+
+func init() {
+	addTableFlags(cacheLsCmd)
+	cacheCmd.AddCommand(cacheLsCmd)
+	cacheCmd.AddCommand(cacheClearCmd)
+}
@@ -1,16 +1,79 @@
 package cli
 
+import (
+	"errors"
+	"fmt"
+)
+
+// Execute runs the tape command line. Errors are returned rather than
+// printed, see HandleError.
 func Execute() error {
-	return rootCmd.Execute()
+	rootCmd.SilenceErrors = true
+	cmd, err := rootCmd.ExecuteC()
+	if err != nil && !commandStarted {
+		// cobra rejected the arguments or flags before running the command
+		fmt.Println(cmd.UsageString())
+		return usageErrorf("Error: %v", err)
+	}
+	var codeErr *exitCodeError
+	if errors.As(err, &codeErr) && codeErr.code == ExitUsage {
+		fmt.Println(cmd.UsageString())
+	}
+	return err
 }
 
 func init() {
 	// rootCmd.AddCommand(versionCmd)
 
+	rootCmd.AddCommand(newCmd)
 	rootCmd.AddCommand(upCmd)
+	rootCmd.AddCommand(upgradeCmd)
 	rootCmd.AddCommand(lsCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(topCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(logsCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(eventsCmd)
+	rootCmd.AddCommand(duCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(adoptCmd)
 	rootCmd.AddCommand(execCmd)
+	rootCmd.AddCommand(cpCmd)
+	rootCmd.AddCommand(fsCmd)
+	rootCmd.AddCommand(taskCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(syncCmd)
 	rootCmd.AddCommand(stopCmd)
 	rootCmd.AddCommand(rmCmd)
+	rootCmd.AddCommand(pruneCmd)
+	rootCmd.AddCommand(pauseCmd)
+	rootCmd.AddCommand(resumeCmd)
+	rootCmd.AddCommand(snapshotCmd)
+	rootCmd.AddCommand(restoreCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(importCmd)
+	rootCmd.AddCommand(configCmd)
+	rootCmd.AddCommand(openCmd)
+	rootCmd.AddCommand(uriCmd)
+	rootCmd.AddCommand(featureCmd)
 	rootCmd.AddCommand(sshCmd)
+	rootCmd.AddCommand(sshConfigCmd)
+	rootCmd.AddCommand(portsCmd)
+	rootCmd.AddCommand(shareCmd)
+	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(forwardCmd)
+	rootCmd.AddCommand(publishPortCmd)
+	rootCmd.AddCommand(unpublishPortCmd)
+	rootCmd.AddCommand(networkCmd)
+	rootCmd.AddCommand(hostsCmd)
+	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(reportCmd)
+	rootCmd.AddCommand(envCmd)
+	rootCmd.AddCommand(hookCmd)
+	rootCmd.AddCommand(hookEnvCmd)
+	rootCmd.AddCommand(lifecycleRunCmd)
+	rootCmd.AddCommand(direnvCmd)
+	rootCmd.AddCommand(shimCmd)
 }
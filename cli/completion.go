@@ -0,0 +1,84 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+// completeEnvNames completes an environment name argument
+func completeEnvNames(toComplete string) []string {
+	names, err := core.ListBoxConfigs()
+	if err != nil {
+		return nil
+	}
+	var matches []string
+	for _, name := range names {
+		if strings.HasPrefix(name, toComplete) {
+			matches = append(matches, name)
+		}
+	}
+	return matches
+}
+
+// completeBoxPath completes a path in the environment's container by listing
+// its directory there. Directories end with a slash, so completion doesn't
+// add a space after them and the next tab continues into them.
+func completeBoxPath(envName string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	paths, err := core.CompleteBoxPath(envName, toComplete)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveError
+	}
+	return paths, cobra.ShellCompDirectiveNoSpace | cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeBoxPathArg completes cp's arguments: environment names followed by a
+// colon and then paths in their container, or host files
+func completeBoxPathArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	envName, prefix, inBox := splitBoxPath(toComplete)
+	if !inBox {
+		var names []string
+		for _, name := range completeEnvNames(toComplete) {
+			names = append(names, name+":")
+		}
+		if len(names) == 0 {
+			return nil, cobra.ShellCompDirectiveDefault
+		}
+		return names, cobra.ShellCompDirectiveNoSpace
+	}
+
+	paths, directive := completeBoxPath(envName, prefix)
+	for i, p := range paths {
+		paths[i] = envName + ":" + p
+	}
+	return paths, directive
+}
+
+// completeExecArgs completes exec's environment name, then paths in its
+// container for the command's arguments and for commands given as a path
+func completeExecArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeEnvNames(toComplete), cobra.ShellCompDirectiveNoFileComp
+	}
+	if len(args) == 1 && !strings.Contains(toComplete, "/") {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeBoxPath(args[0], toComplete)
+}
+
+// completeBoxDir completes a directory in the container of the environment
+// named by the first argument, for flags like --workdir
+func completeBoxDir(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	paths, directive := completeBoxPath(args[0], toComplete)
+	var dirs []string
+	for _, p := range paths {
+		if strings.HasSuffix(p, "/") {
+			dirs = append(dirs, p)
+		}
+	}
+	return dirs, directive
+}
@@ -0,0 +1,375 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	configDevcontainerFlag bool
+	configGlobalEditFlag   bool
+	configGenerateForce    bool
+	configGeneratePrint    bool
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "View and edit environment configuration",
+	Long: `View and edit an environment's YAML config, or its devcontainer.json with --devcontainer.
+Keys are dotted paths, e.g. customizations.vscode.extensions.0`,
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get [name] [key]",
+	Short: "Print a config value",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := core.LoadConfigFile(args[0], configDevcontainerFlag)
+		if err != nil {
+			return err
+		}
+
+		value, err := file.Get(args[1])
+		if err != nil {
+			return err
+		}
+
+		formatted, err := file.Format(value)
+		if err != nil {
+			return fmt.Errorf("error formatting value: %w", err)
+		}
+		fmt.Println(formatted)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set [name] [key] [value]",
+	Short: "Set a config value",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := core.LoadConfigFile(args[0], configDevcontainerFlag)
+		if err != nil {
+			return err
+		}
+
+		if err := file.Set(args[1], args[2]); err != nil {
+			return err
+		}
+
+		if err := saveConfigFile(file); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var configEditCmd = &cobra.Command{
+	Use:   "edit [name]",
+	Short: "Open a config file in $EDITOR",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := core.LoadConfigFile(args[0], configDevcontainerFlag)
+		if err != nil {
+			return err
+		}
+
+		if err := editConfigFile(file); err != nil {
+			return err
+		}
+		return nil
+	},
+}
+
+var configDiffCmd = &cobra.Command{
+	Use:   "diff [name]",
+	Short: "Show how tape changes an environment's devcontainer.json",
+	Long: `Show the difference between the environment's devcontainer.json on disk and the effective
+config tape passes to the devcontainer CLI: the box's mounts, env, ports and resources, the
+locked features and images, and tape's labels. Nothing is started or built.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		diff, err := core.ConfigDiff(args[0])
+		if err != nil {
+			return err
+		}
+		if diff == "" {
+			fmt.Println("tape uses the config as it is")
+			return nil
+		}
+
+		for _, line := range strings.Split(strings.TrimSuffix(diff, "\n"), "\n") {
+			color := ""
+			switch {
+			case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+				color = colorBold
+			case strings.HasPrefix(line, "@@"):
+				color = colorCyan
+			case strings.HasPrefix(line, "+"):
+				color = colorGreen
+			case strings.HasPrefix(line, "-"):
+				color = colorRed
+			}
+			fmt.Println(colorize(color, line))
+		}
+		return nil
+	},
+}
+
+var configAddExtensionCmd = &cobra.Command{
+	Use:   "add-extension [name] [extension]",
+	Short: "Add a VS Code extension to the devcontainer config",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// extensions are only in the devcontainer config, whatever --devcontainer says
+		file, err := core.LoadConfigFile(args[0], true)
+		if err != nil {
+			return err
+		}
+
+		added, err := file.AddExtension(args[1])
+		if err != nil {
+			return err
+		}
+		if !added {
+			fmt.Printf("%s is already installed\n", args[1])
+			return nil
+		}
+
+		if err := saveConfigFile(file); err != nil {
+			return err
+		}
+		fmt.Printf("Added %s\n", args[1])
+		return nil
+	},
+}
+
+var configRemoveExtensionCmd = &cobra.Command{
+	Use:   "remove-extension [name] [extension]",
+	Short: "Remove a VS Code extension from the devcontainer config",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// extensions are only in the devcontainer config, whatever --devcontainer says
+		file, err := core.LoadConfigFile(args[0], true)
+		if err != nil {
+			return err
+		}
+
+		removed, err := file.RemoveExtension(args[1])
+		if err != nil {
+			return err
+		}
+		if !removed {
+			fmt.Printf("%s is not installed\n", args[1])
+			return nil
+		}
+
+		if err := saveConfigFile(file); err != nil {
+			return err
+		}
+		fmt.Printf("Removed %s\n", args[1])
+		return nil
+	},
+}
+
+var configGlobalCmd = &cobra.Command{
+	Use:   "global [key] [value]",
+	Short: "View and edit the global config",
+	Long: `Print the global config, get a single key, or set a key to a value.
+Use --edit to open the global config in $EDITOR.`,
+	Args: cobra.MaximumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file, err := core.LoadGlobalConfigFile()
+		if err != nil {
+			return err
+		}
+
+		if configGlobalEditFlag {
+			if err := editConfigFile(file); err != nil {
+				return err
+			}
+			return nil
+		}
+
+		switch len(args) {
+		case 0:
+			data, err := os.ReadFile(file.Path)
+			if err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("error reading %s: %w", file.Path, err)
+			}
+			fmt.Print(string(data))
+		case 1:
+			value, err := file.Get(args[0])
+			if err != nil {
+				return err
+			}
+			formatted, err := file.Format(value)
+			if err != nil {
+				return fmt.Errorf("error formatting value: %w", err)
+			}
+			fmt.Println(formatted)
+		case 2:
+			if err := file.Set(args[0], args[1]); err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Dir(file.Path), 0755); err != nil {
+				return fmt.Errorf("error creating %s: %w", filepath.Dir(file.Path), err)
+			}
+			if err := saveConfigFile(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+func saveConfigFile(file *core.ConfigFile) error {
+	if err := file.Save(); err != nil {
+		return fmt.Errorf("Not saving %s: %w", file.Path, err)
+	}
+	return nil
+}
+
+// editConfigFile opens a copy of the file in the user's editor and only
+// replaces the original once the edited copy validates
+func editConfigFile(file *core.ConfigFile) error {
+	path := file.Path
+	original, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	tmp, err := os.CreateTemp("", "tape-*"+filepath.Ext(path))
+	if err != nil {
+		return fmt.Errorf("error creating temp file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(original); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing temp file: %v", err)
+	}
+	tmp.Close()
+
+	for {
+		if err := runEditor(tmp.Name()); err != nil {
+			return err
+		}
+
+		edited, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return fmt.Errorf("error reading edited file: %v", err)
+		}
+
+		err = core.ValidateConfigData(edited, file.Kind)
+		if err == nil {
+			return os.WriteFile(path, edited, 0644)
+		}
+
+		fmt.Println(err)
+		fmt.Print("Edit again? [Y/n] ")
+		var answer string
+		fmt.Scanln(&answer)
+		if strings.HasPrefix(strings.ToLower(answer), "n") {
+			return fmt.Errorf("discarded changes to %s", path)
+		}
+	}
+}
+
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// the editor may include arguments, e.g. "code --wait"
+	parts := strings.Fields(editor)
+	editorCmd := exec.Command(parts[0], append(parts[1:], path)...)
+	editorCmd.Stdin = os.Stdin
+	editorCmd.Stdout = os.Stdout
+	editorCmd.Stderr = os.Stderr
+
+	if err := editorCmd.Run(); err != nil {
+		return fmt.Errorf("error running editor %s: %v", editor, err)
+	}
+	return nil
+}
+
+var configGenerateCmd = &cobra.Command{
+	Use:   "generate [workspace]",
+	Short: "Writes a devcontainer.json guessed from the workspace",
+	Long: `Write .devcontainer/devcontainer.json for a workspace without one, by default the
+current directory. A compose file's service is used if there is one, then a
+Dockerfile, then an image for the languages found, e.g. from go.mod or
+package.json. Ports are forwarded from the compose service or EXPOSE.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		workspace := "."
+		if len(args) == 1 {
+			workspace = args[0]
+		}
+		workspace, err := filepath.Abs(workspace)
+		if err != nil {
+			return fmt.Errorf("error resolving workspace: %w", err)
+		}
+
+		generated, err := core.GenerateDevContainerConfig(workspace)
+		if err != nil {
+			return fmt.Errorf("error generating config: %w", err)
+		}
+		data, err := json.MarshalIndent(generated.Config, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error serializing config: %w", err)
+		}
+		data = append(data, '\n')
+
+		if configGeneratePrint {
+			os.Stdout.Write(data)
+			return nil
+		}
+
+		path := filepath.Join(workspace, ".devcontainer", "devcontainer.json")
+		if _, err := os.Stat(path); err == nil && !configGenerateForce {
+			return stateConflictf("%s already exists, use --force to overwrite it", path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return fmt.Errorf("error creating %s: %w", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", path, err)
+		}
+		fmt.Printf("Wrote %s from %s\n", path, generated.Source)
+		if generated.Config.Service != "" {
+			fmt.Printf("Make sure service %s mounts the workspace at %s\n", generated.Config.Service, generated.Config.WorkspaceFolder)
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.PersistentFlags().BoolVar(&configDevcontainerFlag, "devcontainer", false, "Operate on the environment's devcontainer.json instead of its YAML config")
+
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	configCmd.AddCommand(configEditCmd)
+	configCmd.AddCommand(configDiffCmd)
+	configCmd.AddCommand(configAddExtensionCmd)
+	configCmd.AddCommand(configRemoveExtensionCmd)
+
+	configGenerateCmd.Flags().BoolVar(&configGenerateForce, "force", false, "Overwrite an existing devcontainer.json")
+	configGenerateCmd.Flags().BoolVar(&configGeneratePrint, "print", false, "Print the config instead of writing it")
+	configCmd.AddCommand(configGenerateCmd)
+
+	configGlobalCmd.Flags().BoolVar(&configGlobalEditFlag, "edit", false, "Open the global config in $EDITOR")
+	configCmd.AddCommand(configGlobalCmd)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var cpCmd = &cobra.Command{
+	Use:   "cp [src] [dest]",
+	Short: "Copy files between the host and a dev environment",
+	Long: `Copy a file or directory into a directory, where one of the two is a path in a
+dev environment's container written as name:path. Relative container paths are
+relative to the workspace folder. Files copied into the container are owned by
+the remoteUser.
+Example: tape cp ./fixtures myenv:/app/test`,
+	Args:              cobra.ExactArgs(2),
+	ValidArgsFunction: completeBoxPathArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		srcEnv, src, srcInBox := splitBoxPath(args[0])
+		destEnv, dest, destInBox := splitBoxPath(args[1])
+
+		switch {
+		case srcInBox && destInBox:
+			return usageErrorf("Error: can't copy between two environments")
+		case destInBox:
+			if err := core.CopyToBox(destEnv, src, dest); err != nil {
+				return fmt.Errorf("error copying to %s: %w", destEnv, err)
+			}
+		case srcInBox:
+			if err := core.CopyFromBox(srcEnv, src, dest); err != nil {
+				return fmt.Errorf("error copying from %s: %w", srcEnv, err)
+			}
+		default:
+			return usageErrorf("Error: one of the paths has to be in an environment, as name:path")
+		}
+		return nil
+	},
+}
+
+// splitBoxPath splits a name:path argument. Arguments without a colon are
+// host paths, as are ones starting like a path or a Windows drive letter.
+func splitBoxPath(arg string) (envName string, p string, inBox bool) {
+	envName, p, found := strings.Cut(arg, ":")
+	if !found || len(envName) < 2 || strings.ContainsAny(envName[:1], `./\~`) {
+		return "", arg, false
+	}
+	return envName, p, true
+}
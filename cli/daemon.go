@@ -0,0 +1,123 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/mikeocool/tape/daemon"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonMetricsAddressFlag string
+	daemonProxyAddressFlag   string
+	daemonAutoForwardFlag    bool
+)
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "Runs the tape daemon",
+	Long: `Run the SSH server (see tape ssh) and serve Prometheus metrics about the
+environments on this host, by default on http://127.0.0.1:9273/metrics. Set
+daemon.metrics-address in the global config to change the address.
+
+With daemon.proxy-address set, or --proxy-address, the daemon also runs a reverse
+proxy serving each environment's primary port, the first of its forwardPorts, on
+http://<name>.localhost, and ports labelled in portsAttributes on
+http://<label>.<name>.localhost. Add the proxy's port to the URL unless it's 80.
+
+With daemon.auto-forward set, or --auto-forward, the daemon watches for ports
+processes in running environments start listening on and forwards them, on the
+same port of 127.0.0.1 when it's free, following their onAutoForward. Ports
+only listening on the container's loopback address are relayed by socat, or
+nc, in the container, so one of them has to be installed there. Stop
+an automatic forward with tape forward rm to keep the port from being
+forwarded again.
+
+Boxes with an idle-timeout are stopped once they have been idle for longer:
+no commands running in them with exec, e.g. shells or editors, and no tape
+operations on them since.
+
+With retention.interval set in the global config, e.g. 24h, the daemon runs
+tape prune --auto that often.
+
+The daemon also serves an HTTP API on a unix socket in the config directory
+for editor plugins and other tools. While it runs, ls, status and stop go
+through it and port forwards can outlive the command that started them.
+Set TAPE_NO_DAEMON=1 to bypass it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		err := daemon.Run(daemon.Options{MetricsAddress: daemonMetricsAddressFlag, ProxyAddress: daemonProxyAddressFlag, AutoForward: daemonAutoForwardFlag})
+		if err != nil {
+			return fmt.Errorf("error running daemon: %w", err)
+		}
+		return nil
+	},
+}
+
+var daemonDialStdioCmd = &cobra.Command{
+	Use:    daemon.DialStdioCommand,
+	Short:  "Connects stdin and stdout to the running daemon's API",
+	Long:   `Used over SSH by tape --host to talk to the daemon on this machine.`,
+	Hidden: true,
+	Args:   cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return daemon.DialStdio(core.DaemonSocketPath(), os.Stdin, os.Stdout)
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonMetricsAddressFlag, "metrics-address", "", "host:port to serve metrics on")
+	daemonCmd.Flags().StringVar(&daemonProxyAddressFlag, "proxy-address", "", "host:port to serve the reverse proxy to the environments on")
+	daemonCmd.Flags().BoolVar(&daemonAutoForwardFlag, "auto-forward", false, "Forward the ports processes in running environments listen on")
+	daemonCmd.AddCommand(daemonDialStdioCmd)
+}
+
+var (
+	daemonOnce   sync.Once
+	daemonShared *daemon.Client
+)
+
+// daemonClient returns a client for the running tape daemon, which commands
+// prefer so the daemon sees every operation. With tape --host it is the
+// daemon on that machine. It returns nil when no daemon is running or
+// TAPE_NO_DAEMON is set, and commands run in process instead.
+func daemonClient() *daemon.Client {
+	daemonOnce.Do(func() {
+		if os.Getenv("TAPE_NO_DAEMON") != "" {
+			return
+		}
+		var (
+			client *daemon.Client
+			err    error
+		)
+		if core.HostOverride != "" {
+			client, err = daemon.ConnectRemote(core.HostOverride)
+		} else {
+			client, err = daemon.Connect(core.DaemonSocketPath())
+		}
+		if err == nil {
+			daemonShared = client
+		}
+	})
+	return daemonShared
+}
+
+// getBoxSummary gets the summary from the daemon when it runs
+func getBoxSummary(envName string) (*core.BoxSummary, error) {
+	if client := daemonClient(); client != nil {
+		return client.BoxSummary(envName)
+	}
+	return core.GetBoxSummary(envName)
+}
+
+// getCachedBoxSummary is getBoxSummary from the state cache, see
+// core.CachedBoxSummary
+func getCachedBoxSummary(envName string) (*core.BoxSummary, error) {
+	if client := daemonClient(); client != nil {
+		return client.BoxSummary(envName)
+	}
+	return core.CachedBoxSummary(envName)
+}
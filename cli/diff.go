@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffAllFlag bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [name] [path...]",
+	Short: "Shows the files changed in a dev environment's container",
+	Long: `List the files added (A), changed (C) or deleted (D) in the container since it was created, like
+docker diff. These changes are lost when the container is rebuilt, so they point to state that belongs
+in the Dockerfile, a feature or your dotfiles. The workspace and other mounts aren't included.
+
+Give paths to only show changes below them. Changes in temporary and cache directories like /tmp and
+/var/cache are hidden unless --all is given.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		ignore := core.DiffIgnore
+		if diffAllFlag {
+			ignore = nil
+		}
+
+		changes, err := core.BoxChanges(envName, args[1:], ignore)
+		if err != nil {
+			return fmt.Errorf("error listing changes of %s: %w", envName, err)
+		}
+		for _, change := range changes {
+			fmt.Printf("%s %s\n", colorize(changeColor(change.Kind), string(change.Kind)), change.Path)
+		}
+		return nil
+	},
+}
+
+// changeColor returns the color for a kind of change, like git diff
+func changeColor(kind container.ChangeKind) string {
+	switch kind {
+	case container.ChangeAdded:
+		return colorGreen
+	case container.ChangeDeleted:
+		return colorRed
+	}
+	return colorYellow
+}
+
+func init() {
+	diffCmd.Flags().BoolVar(&diffAllFlag, "all", false, "Include changes in temporary and cache directories")
+}
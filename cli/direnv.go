@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var direnvCmd = &cobra.Command{
+	Use:   "direnv [name]",
+	Short: "Print an .envrc block for a dev environment",
+	Long: `Print an .envrc block that puts tape's shims on PATH, points DOCKER_HOST at the
+environment's docker host and exports TAPE_PORT_<port> with the host port of each
+published port, so host-side tools can target the container.
+Without a name, the environment whose workspace contains the current directory is used.
+Example: tape direnv myenv >> .envrc`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var envName string
+		if len(args) == 1 {
+			envName = args[0]
+		} else {
+			dir, err := os.Getwd()
+			if err != nil {
+				return err
+			}
+			envName, err = core.FindBoxForDir(dir)
+			if err != nil {
+				return fmt.Errorf("error finding environment: %w", err)
+			}
+			if envName == "" {
+				return usageErrorf("No environment's workspace contains %s, pass a name", dir)
+			}
+		}
+
+		config, err := core.LoadBoxConfig(envName)
+		if err != nil {
+			return err
+		}
+
+		ports, err := core.GetBoxPorts(envName)
+		if err != nil {
+			return fmt.Errorf("error getting ports for %s: %w", envName, err)
+		}
+
+		fmt.Printf("# tape environment %s\n", envName)
+		fmt.Printf("export TAPE_ENV=%s\n", shellQuote(envName))
+		fmt.Printf("PATH_add %s\n", shellQuote(core.BinDir()))
+		if config.DockerHost != "" {
+			fmt.Printf("export DOCKER_HOST=%s\n", shellQuote(config.DockerHost))
+		}
+		for _, port := range ports {
+			fmt.Printf("export %s=%d\n", port.Variable(), port.HostPort)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Checks that tape can run dev environments",
+	Long: `Checks that docker is reachable and the devcontainer CLI is available. Inside
+WSL, also checks for workspaces on Windows drives, which are slow to mount.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		checks, err := core.Doctor()
+		if err != nil {
+			return fmt.Errorf("error running checks: %w", err)
+		}
+
+		failed := 0
+		for _, check := range checks {
+			lines := strings.Split(check.Message, "\n")
+			fmt.Printf("%-9s %s: %s\n", "["+check.Status+"]", check.Name, lines[0])
+			for _, line := range lines[1:] {
+				fmt.Printf("%-9s %s\n", "", line)
+			}
+			if check.Status == core.CheckFailed {
+				failed++
+			}
+		}
+
+		if failed > 0 {
+			return fmt.Errorf("%d checks failed", failed)
+		}
+		return nil
+	},
+}
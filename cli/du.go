@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var duCmd = &cobra.Command{
+	Use:   "du [name]",
+	Short: "Shows the disk space used by dev environments",
+	Long: `Show the image size, container writable layer and named volumes of each environment's containers.
+Without a name, every container tape created is shown. Images can be shared between environments.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			usages []core.BoxDiskUsage
+			err    error
+		)
+		if len(args) == 1 {
+			usages, err = core.GetBoxDiskUsage(args[0])
+		} else {
+			usages, err = core.ListBoxDiskUsage()
+		}
+		if err != nil {
+			return fmt.Errorf("error getting disk usage: %w", err)
+		}
+
+		if len(usages) == 0 {
+			fmt.Println("No containers")
+			return nil
+		}
+
+		t := newTable("NAME", "CONTAINER", "IMAGE", "WRITABLE", "VOLUMES", "TOTAL")
+		for _, usage := range usages {
+			volumes := make([]string, len(usage.Volumes))
+			for i, volume := range usage.Volumes {
+				volumes[i] = fmt.Sprintf("%s (%s)", volume.Name, formatSize(volume.Size))
+			}
+			if len(volumes) == 0 {
+				volumes = []string{"-"}
+			}
+			t.addRow(
+				usage.EnvName,
+				shortID(usage.ContainerID),
+				formatSize(usage.ImageSize),
+				formatSize(usage.WritableSize),
+				strings.Join(volumes, ", "),
+				formatSize(usage.Total()))
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+// formatSize formats a size docker reported, which is negative when unknown
+func formatSize(size int64) string {
+	if size < 0 {
+		return "unknown"
+	}
+	return formatBytes(uint64(size))
+}
+
+func init() {
+	addTableFlags(duCmd)
+}
@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	envFormatFlag string
+)
+
+var envCmd = &cobra.Command{
+	Use:   "env [name]",
+	Short: "Print the effective environment of a dev environment",
+	Long: `Print the merged environment of a dev environment: the probed user
+environment (when running), containerEnv, the box's env and remoteEnv.
+Example: eval "$(tape env myenv)"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		if envFormatFlag != "export" && envFormatFlag != "dotenv" {
+			return usageErrorf("Error: unknown format %s (expected export or dotenv)", envFormatFlag)
+		}
+
+		env, err := core.GetBoxEnv(envName)
+		if err != nil {
+			return fmt.Errorf("error getting environment for %s: %w", envName, err)
+		}
+
+		keys := make([]string, 0, len(env))
+		for key := range env {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			if envFormatFlag == "dotenv" {
+				fmt.Printf("%s=%s\n", key, dotenvQuote(env[key]))
+			} else {
+				fmt.Printf("export %s=%s\n", key, shellQuote(env[key]))
+			}
+		}
+		return nil
+	},
+}
+
+// shellQuote wraps a value in single quotes so it is safe to eval in a POSIX shell
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// dotenvQuote wraps a value in double quotes, escaping characters dotenv parsers interpret
+func dotenvQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`)
+	return `"` + replacer.Replace(value) + `"`
+}
+
+func init() {
+	envCmd.Flags().StringVar(&envFormatFlag, "format", "export", "Output format: export or dotenv")
+}
@@ -0,0 +1,102 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+)
+
+// Exit codes tape uses so scripts can branch on the kind of failure.
+// exec propagates the exit code of the command run in the container.
+const (
+	ExitOK                = 0
+	ExitError             = 1
+	ExitUsage             = 2
+	ExitEnvNotFound       = 3
+	ExitDockerUnavailable = 4
+	ExitStateConflict     = 5
+)
+
+// exitCodeError is an error with a message that is printed as is and a
+// specific exit code
+type exitCodeError struct {
+	code    int
+	message string
+}
+
+func (e *exitCodeError) Error() string {
+	return e.message
+}
+
+// usageErrorf returns an error for invalid arguments, which exits with ExitUsage
+func usageErrorf(format string, args ...interface{}) error {
+	return &exitCodeError{code: ExitUsage, message: fmt.Sprintf(format, args...)}
+}
+
+// stateConflictf returns an error for when the environment's state doesn't
+// allow the operation, which exits with ExitStateConflict
+func stateConflictf(format string, args ...interface{}) error {
+	return &exitCodeError{code: ExitStateConflict, message: fmt.Sprintf(format, args...)}
+}
+
+// HandleError prints err, with a hint for the errors tape knows how to
+// explain, and returns the exit code for it. Errors from a command run in a
+// container aren't printed, the command already reported them.
+func HandleError(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+
+	fmt.Println(errorMessage(err))
+	return exitCode(err)
+}
+
+func exitCode(err error) int {
+	var codeErr *exitCodeError
+	switch {
+	case errors.As(err, &codeErr):
+		return codeErr.code
+	case errors.Is(err, core.ErrConfigNotFound), errors.Is(err, core.ErrGroupNotFound):
+		return ExitEnvNotFound
+	case errors.Is(err, core.ErrDockerUnavailable):
+		return ExitDockerUnavailable
+	case errors.Is(err, core.ErrNotRunning), errors.Is(err, core.ErrAmbiguousContainer), container.IsContainerNotFound(err):
+		return ExitStateConflict
+	}
+	return ExitError
+}
+
+func errorMessage(err error) string {
+	switch {
+	case errors.Is(err, core.ErrConfigNotFound):
+		return fmt.Sprintf("%v\nRun tape ls to see the available environments.", err)
+	case errors.Is(err, core.ErrGroupNotFound):
+		return fmt.Sprintf("%v\nDefine it under groups: in the global config.", err)
+	case errors.Is(err, core.ErrDockerUnavailable):
+		return fmt.Sprintf("%v\nIs the docker daemon running? Check DOCKER_HOST or the docker-host and host settings.", err)
+	case errors.Is(err, core.ErrPolicyViolation):
+		return fmt.Sprintf("%v\nChange the config to follow the policy, or ask whoever maintains it for an exception.", err)
+	case errors.Is(err, core.ErrUntrustedWorkspace):
+		return fmt.Sprintf("%v\nRun tape up --trust if you trust it, or --untrusted to start it sandboxed.", err)
+	case errors.Is(err, core.ErrOffline):
+		return fmt.Sprintf("%v\nRun without --offline once the registry is reachable.", err)
+	case errors.Is(err, core.ErrRegistryUnavailable):
+		return fmt.Sprintf("%v\nCheck your network connection, or use --offline to work with local images.", err)
+	case errors.Is(err, core.ErrImageNotFound):
+		return fmt.Sprintf("%v\nCheck the image name, or pull or build it first.", err)
+	case errors.Is(err, core.ErrAmbiguousContainer):
+		return fmt.Sprintf("%v\nRun tape status to list the matching containers.", err)
+	case errors.Is(err, core.ErrNotRunning):
+		return fmt.Sprintf("%v\nStart it with tape up.", err)
+	case container.IsContainerNotFound(err):
+		return fmt.Sprintf("%v\nThe environment has no container yet, start it with tape up.", err)
+	}
+	return err.Error()
+}
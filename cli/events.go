@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	eventsEnvFlag    string
+	eventsFormatFlag string
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Stream dev environment events",
+	Long: `Print the events of environments as they happen, until interrupted: their containers'
+events on the default docker host, e.g. start, die or destroy, and the operations tape
+runs on them, e.g. up, stop or the end of the lifecycle commands run in the background.
+
+With --format json, each event is printed as a JSON object on a line of its own, for
+scripts and editor extensions to react to.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if eventsFormatFlag != "text" && eventsFormatFlag != "json" {
+			return usageErrorf("Error: unknown format %s (expected text or json)", eventsFormatFlag)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		err := core.WatchEvents(ctx, eventsEnvFlag, func(event core.BoxEvent) error {
+			if eventsFormatFlag == "json" {
+				line, err := json.Marshal(event)
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(line))
+				return nil
+			}
+			fmt.Println(formatEvent(event))
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("error watching events: %w", err)
+		}
+		return nil
+	},
+}
+
+// formatEvent formats an event as a line of text
+func formatEvent(event core.BoxEvent) string {
+	envName := event.EnvName
+	if envName == "" {
+		envName = "-"
+	}
+	fields := []string{event.Time.Local().Format(time.DateTime), envName, event.Source, event.Action}
+	switch {
+	case event.Source == core.EventSourceDocker && event.Action == "die":
+		fields = append(fields, fmt.Sprintf("exit %d", event.ExitCode))
+	case event.Source == core.EventSourceTape:
+		fields = append(fields, event.Outcome)
+	}
+	if event.Detail != "" {
+		fields = append(fields, event.Detail)
+	}
+	if event.Error != "" {
+		fields = append(fields, fmt.Sprintf("(%s)", event.Error))
+	}
+	return strings.Join(fields, " ")
+}
+
+func init() {
+	eventsCmd.Flags().StringVar(&eventsEnvFlag, "env", "", "Only stream the events of this environment")
+	eventsCmd.Flags().StringVar(&eventsFormatFlag, "format", "text", "Output format: text or json")
+}
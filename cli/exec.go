@@ -2,24 +2,28 @@ package cli
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
+	"strings"
 
 	"github.com/mikeocool/tape/core"
 	"github.com/spf13/cobra"
 )
 
+var (
+	execWorkdirFlag string
+	execUserFlag    string
+	execEnvFlag     []string
+)
+
 var execCmd = &cobra.Command{
 	Use:   "exec [envName] [cmd] [args...]",
 	Short: "Execute a command in a dev environment",
 	Long: `Execute a command inside a dev environment.
 Example: tape exec myenv ls -la
 Everything after -- will be passed directly to the container.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	ValidArgsFunction: completeExecArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
-			fmt.Println("Error: Missing environment name")
-			cmd.Usage()
-			os.Exit(1)
+			return usageErrorf("Error: Missing environment name")
 		}
 
 		// Get environment name
@@ -28,35 +32,34 @@ Everything after -- will be passed directly to the container.`,
 		// Everything after name is the command and its arguments
 		execArgs := args[1:]
 		if len(execArgs) < 1 {
-			fmt.Println("Error: No command specified to execute")
-			cmd.Usage()
-			os.Exit(1)
+			return usageErrorf("Error: No command specified to execute")
 		}
 
-		// TODO look at https://stackoverflow.com/questions/72708535/cobra-cli-pass-all-arguments-and-flags-to-an-executable
-		// to fix args passing through
-
-		// Load the configuration
-		config, err := core.LoadBoxConfig(envName)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+		for _, env := range execEnvFlag {
+			if !strings.Contains(env, "=") {
+				return usageErrorf("Error: invalid --env %s, expected KEY=VALUE", env)
+			}
 		}
 
-		// Create and execute the devcontainer command
-		devCmd := core.DevcontainerCommand{
-			BoxConfig:      *config,
-			Command:        "exec",
-			AdditionalArgs: execArgs,
-		}
+		// TODO look at https://stackoverflow.com/questions/72708535/cobra-cli-pass-all-arguments-and-flags-to-an-executable
+		// to fix args passing through
 
-		err = devCmd.Execute()
+		err := core.ExecInBox(envName, core.ExecOptions{
+			Command:    execArgs,
+			User:       execUserFlag,
+			WorkingDir: execWorkdirFlag,
+			Env:        execEnvFlag,
+		})
 		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				os.Exit(exitErr.ExitCode())
-			}
-			fmt.Printf("Error executing command: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error executing command: %w", err)
 		}
+		return nil
 	},
 }
+
+func init() {
+	execCmd.Flags().StringVarP(&execWorkdirFlag, "workdir", "w", "", "Working directory inside the container (defaults to the workspace folder)")
+	execCmd.Flags().StringVarP(&execUserFlag, "user", "u", "", "User to run the command as (defaults to the remoteUser)")
+	execCmd.Flags().StringArrayVarP(&execEnvFlag, "env", "e", nil, "Set an environment variable, KEY=VALUE (repeatable)")
+	execCmd.RegisterFlagCompletionFunc("workdir", completeBoxDir)
+}
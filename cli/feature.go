@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	featureTestScriptFlag  string
+	featureTestImageFlag   string
+	featureTestOptionsFlag string
+	featureTestKeepFlag    bool
+)
+
+var featureCmd = &cobra.Command{
+	Use:   "feature",
+	Short: "Develop devcontainer features",
+}
+
+var featureTestCmd = &cobra.Command{
+	Use:   "test [path]",
+	Short: "Tests a local feature in a scratch environment",
+	Long: `Build a scratch environment from a base image with the feature at path applied, then run the
+feature's test script inside it. The script defaults to test.sh in the feature directory, or
+test/<id>/test.sh when the feature is part of a collection laid out as src/<id>.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var options map[string]interface{}
+		if featureTestOptionsFlag != "" {
+			if err := json.Unmarshal([]byte(featureTestOptionsFlag), &options); err != nil {
+				return usageErrorf("Error: invalid --options: %v", err)
+			}
+		}
+
+		err := core.TestFeature(core.FeatureTestOptions{
+			FeaturePath: args[0],
+			TestScript:  featureTestScriptFlag,
+			BaseImage:   featureTestImageFlag,
+			Options:     options,
+			Keep:        featureTestKeepFlag,
+		})
+		if err != nil {
+			return fmt.Errorf("Feature test failed: %w", err)
+		}
+
+		fmt.Println("Feature test passed")
+		return nil
+	},
+}
+
+func init() {
+	featureTestCmd.Flags().StringVar(&featureTestScriptFlag, "test", "", "Test script to run in the environment")
+	featureTestCmd.Flags().StringVar(&featureTestImageFlag, "base-image", core.DefaultFeatureTestImage, "Image to apply the feature to")
+	featureTestCmd.Flags().StringVar(&featureTestOptionsFlag, "options", "", `Feature options as JSON, e.g. '{"version": "1.2"}'`)
+	featureTestCmd.Flags().BoolVar(&featureTestKeepFlag, "keep", false, "Keep the scratch container after the test")
+
+	featureCmd.AddCommand(featureTestCmd)
+}
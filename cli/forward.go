@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/mikeocool/tape/daemon"
+	"github.com/spf13/cobra"
+)
+
+var forwardAddressFlag string
+
+var forwardCmd = &cobra.Command{
+	Use:   "forward",
+	Short: "Forward host ports to dev environments",
+	Long: `Forward a host port to a port in a dev environment's container. When tape daemon
+runs, it keeps the forward open in the background, otherwise tape forward add
+forwards until interrupted. The container's address must be reachable from the
+host, which is the case where docker runs natively, e.g. on Linux.
+
+Forwarded ports follow the onAutoForward of their portsAttributes, or of
+otherPortsAttributes, in the devcontainer config: notify, the default, prints
+the port's URL, openBrowser and openBrowserOnce also open it in the browser,
+and silent and ignore print nothing.`,
+}
+
+var forwardAddCmd = &cobra.Command{
+	Use:   "add [name] [port]",
+	Short: "Forward a host port to a port in a dev environment",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		port, err := strconv.Atoi(args[1])
+		if err != nil || port <= 0 {
+			return usageErrorf("Invalid port %s", args[1])
+		}
+
+		if client := daemonClient(); client != nil {
+			forward, err := client.CreateForward(envName, daemon.ForwardRequest{
+				ContainerPort: port,
+				Address:       forwardAddressFlag,
+			})
+			if err != nil {
+				return fmt.Errorf("error forwarding port: %w", err)
+			}
+			fmt.Printf("Forwarding %s to %s:%d (stop it with tape forward rm %s)\n", forward.Address, envName, port, forward.ID)
+			if forward.Notification != "" {
+				fmt.Println(forward.Notification)
+			}
+			return nil
+		}
+
+		if err := core.RequireRunning(envName); err != nil {
+			return err
+		}
+		address := forwardAddressFlag
+		if address == "" {
+			address = "127.0.0.1:0"
+		}
+		listener, err := core.ListenNextFree(address)
+		if err != nil {
+			return fmt.Errorf("error listening on %s: %w", address, err)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Forwarding %s to %s:%d, press Ctrl-C to stop\n", listener.Addr(), envName, port)
+		notification, err := core.OnForward(envName, port, listener.Addr().String())
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		if notification != "" {
+			fmt.Println(notification)
+		}
+		if err := core.ForwardPort(ctx, envName, listener, port); err != nil {
+			return fmt.Errorf("error forwarding port: %w", err)
+		}
+		return nil
+	},
+}
+
+var forwardLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List the port forwards tape daemon runs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := daemonClient()
+		if client == nil {
+			return stateConflictf("tape daemon isn't running")
+		}
+		forwards, err := client.Forwards()
+		if err != nil {
+			return fmt.Errorf("error listing forwards: %w", err)
+		}
+
+		t := newTable("ID", "ADDRESS", "NAME", "PORT")
+		for _, forward := range forwards {
+			t.addRow(forward.ID, forward.Address, forward.EnvName, strconv.Itoa(forward.ContainerPort))
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+var forwardRmCmd = &cobra.Command{
+	Use:   "rm [id]",
+	Short: "Stop a port forward tape daemon runs",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client := daemonClient()
+		if client == nil {
+			return stateConflictf("tape daemon isn't running")
+		}
+		if err := client.DeleteForward(args[0]); err != nil {
+			return fmt.Errorf("error stopping forward: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	forwardAddCmd.Flags().StringVar(&forwardAddressFlag, "address", "", "host:port to listen on, or the next free port when it's taken, defaults to a free port on 127.0.0.1")
+	addTableFlags(forwardLsCmd)
+	forwardCmd.AddCommand(forwardAddCmd)
+	forwardCmd.AddCommand(forwardLsCmd)
+	forwardCmd.AddCommand(forwardRmCmd)
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var fsCmd = &cobra.Command{
+	Use:   "fs",
+	Short: "Inspect files in a dev environment without opening a shell",
+	Long: `Inspect files in a dev environment's container. Paths are written as name:path,
+relative paths are relative to the workspace folder and name: alone is the
+workspace folder.`,
+}
+
+// boxPathArg splits an fs command's name:path argument
+func boxPathArg(arg string) (string, string, error) {
+	envName, p, inBox := splitBoxPath(arg)
+	if !inBox {
+		return "", "", usageErrorf("Error: expected name:path, got %s", arg)
+	}
+	return envName, p, nil
+}
+
+var fsLsCmd = &cobra.Command{
+	Use:               "ls [name]:[path]",
+	Short:             "List a directory in a dev environment",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBoxPathArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName, p, err := boxPathArg(args[0])
+		if err != nil {
+			return err
+		}
+		files, err := core.ListBoxDir(envName, p)
+		if err != nil {
+			return fmt.Errorf("error listing %s: %w", args[0], err)
+		}
+
+		t := newTable("MODE", "OWNER", "SIZE", "MODIFIED", "NAME")
+		for _, file := range files {
+			name := file.Name()
+			if file.IsDir() {
+				name += "/"
+			}
+			t.addRow(file.Mode, file.Owner, strconv.FormatInt(file.Size, 10), file.Modified.Local().Format(time.DateTime), name)
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+var fsCatCmd = &cobra.Command{
+	Use:               "cat [name]:[path]",
+	Short:             "Print a file in a dev environment",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBoxPathArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName, p, err := boxPathArg(args[0])
+		if err != nil {
+			return err
+		}
+		content, err := core.ReadBoxFile(envName, p)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", args[0], err)
+		}
+		os.Stdout.Write(content)
+		return nil
+	},
+}
+
+var fsStatCmd = &cobra.Command{
+	Use:               "stat [name]:[path]",
+	Short:             "Describe a file in a dev environment",
+	Args:              cobra.ExactArgs(1),
+	ValidArgsFunction: completeBoxPathArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName, p, err := boxPathArg(args[0])
+		if err != nil {
+			return err
+		}
+		file, err := core.StatBoxPath(envName, p)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %w", args[0], err)
+		}
+
+		fmt.Printf("Path:     %s\n", file.Path)
+		fmt.Printf("Type:     %s\n", file.Type)
+		fmt.Printf("Mode:     %s\n", file.Mode)
+		fmt.Printf("Owner:    %s\n", file.Owner)
+		fmt.Printf("Size:     %d\n", file.Size)
+		fmt.Printf("Modified: %s\n", file.Modified.Local().Format(time.DateTime))
+		return nil
+	},
+}
+
+func init() {
+	fsCmd.AddCommand(fsLsCmd)
+	fsCmd.AddCommand(fsCatCmd)
+	fsCmd.AddCommand(fsStatCmd)
+	addTableFlags(fsLsCmd)
+}
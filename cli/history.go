@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var historyLimitFlag int
+
+var historyCmd = &cobra.Command{
+	Use:   "history [name]",
+	Short: "Shows the operations run on dev environments",
+	Long: `Show when environments were started, stopped, removed, snapshotted or had
+commands run in them, by whom and whether it worked. Without a name, every
+environment's history is shown.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := ""
+		if len(args) == 1 {
+			envName = args[0]
+		}
+
+		events, err := core.History(envName)
+		if err != nil {
+			return fmt.Errorf("error reading history: %w", err)
+		}
+		if historyLimitFlag > 0 && len(events) > historyLimitFlag {
+			events = events[len(events)-historyLimitFlag:]
+		}
+
+		t := newTable("TIME", "NAME", "OPERATION", "USER", "OUTCOME", "DETAIL", "COMMAND")
+		t.colorColumn(4, func(outcome string) string {
+			if outcome == core.OutcomeError {
+				return colorRed
+			}
+			return ""
+		})
+		for _, event := range events {
+			detail := event.Detail
+			if event.Error != "" {
+				detail = strings.TrimSpace(fmt.Sprintf("%s (%s)", detail, event.Error))
+			}
+			t.addRow(
+				event.Time.Local().Format(time.DateTime),
+				event.EnvName,
+				event.Operation,
+				event.User,
+				event.Outcome,
+				detail,
+				event.Command)
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+func init() {
+	addTableFlags(historyCmd)
+	historyCmd.Flags().IntVarP(&historyLimitFlag, "limit", "n", 0, "Show only the most recent events")
+}
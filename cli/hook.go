@@ -0,0 +1,202 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	hookAutoUpFlag bool
+)
+
+// hookShells are the shells tape hook can emit code for
+var hookShells = []string{"bash", "zsh", "fish"}
+
+// hookAliases are defined while inside a workspace, each running a tape
+// command against the active environment
+var hookAliases = []struct {
+	alias   string
+	command string
+}{
+	{"tup", "up"},
+	{"texec", "exec"},
+	{"tstatus", "status"},
+	{"tstop", "stop"},
+	{"topen", "open"},
+}
+
+const bashHook = `_tape_hook() {
+  local previous_exit_status=$?
+  if [[ "$PWD" != "${_TAPE_LAST_PWD-}" ]]; then
+    _TAPE_LAST_PWD="$PWD"
+    eval "$(%[1]s hook-env bash%[2]s)"
+  fi
+  return $previous_exit_status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_tape_hook;"* ]]; then
+  PROMPT_COMMAND="_tape_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`
+
+const zshHook = `_tape_hook() {
+  eval "$(%[1]s hook-env zsh%[2]s)"
+}
+typeset -ag chpwd_functions
+if (( ! ${chpwd_functions[(I)_tape_hook]} )); then
+  chpwd_functions=(_tape_hook $chpwd_functions)
+fi
+_tape_hook
+`
+
+const fishHook = `function _tape_hook --on-variable PWD
+    %[1]s hook-env fish%[2]s | source
+end
+_tape_hook
+`
+
+var hookCmd = &cobra.Command{
+	Use:   "hook [shell]",
+	Short: "Print shell code that activates environments on cd",
+	Long: `Print shell code that, when you cd into an environment's workspace, prints its
+status, exports TAPE_ENV and defines the aliases tup, texec, tstatus, tstop and topen.
+With --auto-up, the environment is also started if it isn't running.
+Add one of these to your shell's rc file:
+
+  eval "$(tape hook bash)"
+  eval "$(tape hook zsh)"
+  tape hook fish | source`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell := args[0]
+
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("error finding the tape executable: %w", err)
+		}
+
+		flags := ""
+		if hookAutoUpFlag {
+			flags = " --auto-up"
+		}
+
+		switch shell {
+		case "bash":
+			fmt.Printf(bashHook, shellQuote(executable), flags)
+		case "zsh":
+			fmt.Printf(zshHook, shellQuote(executable), flags)
+		case "fish":
+			fmt.Printf(fishHook, fishQuote(executable), flags)
+		default:
+			return usageErrorf("Unknown shell %s, expected one of %v", shell, hookShells)
+		}
+		return nil
+	},
+}
+
+var hookEnvCmd = &cobra.Command{
+	Use:    "hook-env [shell]",
+	Short:  "Print shell code for the environment of the current directory",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shell := args[0]
+		quote := shellQuote
+		switch shell {
+		case "bash", "zsh":
+		case "fish":
+			quote = fishQuote
+		default:
+			return usageErrorf("Unknown shell %s, expected one of %v", shell, hookShells)
+		}
+
+		dir, err := os.Getwd()
+		if err != nil {
+			return err
+		}
+
+		envName, err := core.FindBoxForDir(dir)
+		if err != nil {
+			// no config directory yet, so there is nothing to activate
+			envName = ""
+		}
+
+		active := os.Getenv("TAPE_ENV")
+		if envName == active {
+			return nil
+		}
+
+		var lines []string
+		if active != "" {
+			if shell == "fish" {
+				lines = append(lines, "set -e TAPE_ENV")
+			} else {
+				lines = append(lines, "unset TAPE_ENV")
+			}
+			for _, a := range hookAliases {
+				if shell == "fish" {
+					lines = append(lines, fmt.Sprintf("functions -e %s", a.alias))
+				} else {
+					lines = append(lines, fmt.Sprintf("unalias %s 2>/dev/null", a.alias))
+				}
+			}
+		}
+
+		if envName != "" {
+			state := core.BoxStateUnknown
+			if summary, err := core.GetBoxSummary(envName); err == nil {
+				state = summary.State
+			}
+			// stdout is evaluated by the shell, so messages go to stderr
+			fmt.Fprintf(os.Stderr, "tape: %s (%s)\n", envName, state)
+
+			if shell == "fish" {
+				lines = append(lines, fmt.Sprintf("set -gx TAPE_ENV %s", quote(envName)))
+			} else {
+				lines = append(lines, fmt.Sprintf("export TAPE_ENV=%s", quote(envName)))
+			}
+
+			executable, err := os.Executable()
+			if err != nil {
+				return fmt.Errorf("error finding the tape executable: %w", err)
+			}
+			for _, a := range hookAliases {
+				target := fmt.Sprintf("%s %s %s", quote(executable), a.command, quote(envName))
+				if shell == "fish" {
+					lines = append(lines, fmt.Sprintf("alias %s %s", a.alias, quote(target)))
+				} else {
+					lines = append(lines, fmt.Sprintf("alias %s=%s", a.alias, quote(target)))
+				}
+			}
+
+			// let the shell run these so their output reaches the terminal
+			if hookAutoUpFlag {
+				switch state {
+				case core.BoxStatePaused:
+					lines = append(lines, fmt.Sprintf("%s resume %s", quote(executable), quote(envName)))
+				case core.BoxStateRunning:
+				default:
+					lines = append(lines, fmt.Sprintf("%s up %s", quote(executable), quote(envName)))
+				}
+			}
+		}
+
+		fmt.Println(strings.Join(lines, "\n"))
+		return nil
+	},
+}
+
+// fishQuote wraps a value in single quotes for fish, which unlike POSIX
+// shells allows escaping quotes and backslashes inside them
+func fishQuote(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return "'" + replacer.Replace(value) + "'"
+}
+
+func init() {
+	hookCmd.Flags().BoolVar(&hookAutoUpFlag, "auto-up", false, "Start the environment when entering its workspace")
+	hookEnvCmd.Flags().BoolVar(&hookAutoUpFlag, "auto-up", false, "Start the environment when entering its workspace")
+}
@@ -0,0 +1,32 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var hostsCmd = &cobra.Command{
+	Use:   "hosts",
+	Short: "Print /etc/hosts entries for running environments",
+	Long: `Print /etc/hosts style entries for all running environments.
+Example: tape hosts | sudo tee -a /etc/hosts`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		hosts, err := core.ListBoxHosts()
+		if err != nil {
+			return fmt.Errorf("error listing hosts: %w", err)
+		}
+
+		for _, host := range hosts {
+			if host.Error != "" {
+				// keep the output usable as a hosts file
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %s\n", host.EnvName, host.Error)
+				continue
+			}
+			fmt.Printf("%s\t%s\n", host.IPAddress, host.EnvName)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"context"
+	"os"
+	"os/signal"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	logsLifecycleFlag bool
+	logsFollowFlag    bool
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs [name]",
+	Short: "Show the logs of a dev environment",
+	Long: `Show the output of a dev environment's container. With --lifecycle, show the
+output of the lifecycle commands tape up left running in the background after
+the waitFor phase instead.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		if logsLifecycleFlag {
+			return core.LifecycleLog(ctx, envName, logsFollowFlag, os.Stdout)
+		}
+		return core.BoxLogs(ctx, envName, logsFollowFlag, os.Stdout, os.Stderr)
+	},
+}
+
+var lifecycleRunCmd = &cobra.Command{
+	Use:    core.LifecycleRunCommand + " [name]",
+	Short:  "Run the lifecycle commands tape up left for the background",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return core.RunBackgroundLifecycle(args[0])
+	},
+}
+
+func init() {
+	logsCmd.Flags().BoolVar(&logsLifecycleFlag, "lifecycle", false, "Show the output of background lifecycle commands")
+	logsCmd.Flags().BoolVarP(&logsFollowFlag, "follow", "f", false, "Keep printing new output")
+}
@@ -1,43 +1,146 @@
 package cli
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"time"
 
+	"github.com/mikeocool/tape/container"
 	"github.com/mikeocool/tape/core"
+	"github.com/mikeocool/tape/daemon"
 	"github.com/spf13/cobra"
 )
 
+var (
+	lsContainersFlag bool
+	lsWatchFlag      bool
+)
+
 var lsCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "List environments",
-	Run: func(cmd *cobra.Command, args []string) {
-		envs, err := core.ListBoxConfigs()
+	Long: `List the configured environments and the states of their containers.
+
+With --watch, the table stays on screen and is redrawn as containers on the default docker
+host change state, e.g. to wait for an environment to finish starting, until interrupted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if lsContainersFlag {
+			return listContainers()
+		}
+		if lsWatchFlag {
+			return watchEnvironments()
+		}
+
+		envs, err := listEnvironments()
 		if err != nil {
-			fmt.Printf("Error listing environments: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error listing environments: %w", err)
 		}
+		printEnvironments(envs)
+		return nil
+	},
+}
 
-		// Find the longest environment name for proper alignment
-		maxNameLength := 0
-		for _, name := range envs {
-			if len(name) > maxNameLength {
-				maxNameLength = len(name)
-			}
+func printEnvironments(envs []daemon.Environment) {
+	t := newTable("NAME", "STATE")
+	t.colorColumn(1, stateColor)
+	for _, env := range envs {
+		if env.Error != "" {
+			// the error is an extra column without a header
+			t.addRow(env.Name, "error", env.Error)
+			continue
 		}
+		t.addRow(env.Name, string(env.State))
+	}
+	t.print(os.Stdout)
+}
 
-		// Format string with fixed width for the first column
-		formatStr := fmt.Sprintf("%%-%ds\t%%s\n", maxNameLength)
-		errorFormatStr := fmt.Sprintf("%%-%ds\terror\t%%s\n", maxNameLength)
+// lsWatchRefresh is how often ls --watch redraws without events, for boxes
+// on other docker hosts, whose events it doesn't see
+const lsWatchRefresh = 10 * time.Second
+
+// watchEnvironments redraws the environments table whenever a container's
+// events arrive, until interrupted
+func watchEnvironments() error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	changed := make(chan struct{}, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- core.WatchBoxEvents(ctx, func(container.Event) error {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+			return nil
+		})
+	}()
 
-		for _, name := range envs {
-			summary, err := core.GetBoxSummary(name)
+	ticker := time.NewTicker(lsWatchRefresh)
+	defer ticker.Stop()
+	for {
+		// the states are read here rather than from the daemon, which may
+		// not have seen the event yet
+		envs, err := daemon.ListEnvironments()
+		if err != nil {
+			return fmt.Errorf("error listing environments: %w", err)
+		}
+		// move the cursor home and clear the screen, like tape stats
+		fmt.Print("\033[H\033[2J")
+		printEnvironments(envs)
+
+		select {
+		case err := <-done:
 			if err != nil {
-				fmt.Printf(errorFormatStr, name, err)
-				continue
+				return fmt.Errorf("error watching environments: %w", err)
 			}
+			return nil
+		case <-changed:
+			// a container's events come in bursts, e.g. create then start
+			time.Sleep(100 * time.Millisecond)
+			select {
+			case <-changed:
+			default:
+			}
+		case <-ticker.C:
+		}
+	}
+}
 
-			fmt.Printf(formatStr, name, summary.State)
+// listEnvironments returns the configured environments and their states,
+// from the daemon when it runs
+func listEnvironments() ([]daemon.Environment, error) {
+	if client := daemonClient(); client != nil {
+		return client.Environments()
+	}
+	return daemon.ListEnvironments()
+}
+
+// listContainers prints every container tape created, including those whose
+// environment config has been deleted
+func listContainers() error {
+	containers, err := core.ListBoxContainers()
+	if err != nil {
+		return fmt.Errorf("error listing containers: %w", err)
+	}
+
+	t := newTable("CONTAINER", "NAME", "STATE", "VERSION")
+	t.colorColumn(2, stateColor)
+	for _, c := range containers {
+		name := c.EnvName
+		if !c.HasConfig() {
+			name += " (no config)"
 		}
-	},
+		t.addRow(shortID(c.ContainerID), name, string(c.State), c.Version)
+	}
+	t.print(os.Stdout)
+	return nil
+}
+
+func init() {
+	addTableFlags(lsCmd)
+	lsCmd.Flags().BoolVar(&lsContainersFlag, "containers", false, "List all containers tape created instead of configured environments")
+	lsCmd.Flags().BoolVarP(&lsWatchFlag, "watch", "w", false, "Keep the table on screen and update it as states change")
 }
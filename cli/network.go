@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Manage networks shared between dev environments",
+}
+
+var networkLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List networks created by tape",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		networks, err := core.ListNetworks()
+		if err != nil {
+			return fmt.Errorf("error listing networks: %w", err)
+		}
+
+		t := newTable("NETWORK", "ENVIRONMENTS")
+		for _, network := range networks {
+			members, err := core.NetworkMembers(network.Name)
+			if err != nil {
+				return fmt.Errorf("error listing environments: %w", err)
+			}
+			t.addRow(network.Name, strings.Join(members, ","))
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+var networkCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create a network that dev environments can join",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		if _, err := core.EnsureNetwork(name); err != nil {
+			return fmt.Errorf("error creating network %s: %w", name, err)
+		}
+
+		fmt.Printf("Created network %s\n", name)
+		return nil
+	},
+}
+
+var networkRmCmd = &cobra.Command{
+	Use:   "rm [name]",
+	Short: "Remove a network created by tape",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		if err := core.RemoveNetwork(name); err != nil {
+			return fmt.Errorf("error removing network %s: %w", name, err)
+		}
+
+		fmt.Printf("Removed network %s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	networkCmd.AddCommand(networkLsCmd)
+	networkCmd.AddCommand(networkCreateCmd)
+	networkCmd.AddCommand(networkRmCmd)
+	addTableFlags(networkLsCmd)
+}
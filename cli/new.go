@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	newTemplateFlag  string
+	newWorkspaceFlag string
+	newOptionFlag    []string
+	newYesFlag       bool
+	newForceFlag     bool
+)
+
+var newCmd = &cobra.Command{
+	Use:   "new [name]",
+	Short: "Creates a dev environment from a template",
+	Long: `Pull a devcontainer template, write its files into the workspace and create an environment config for it.
+Example: tape new myapp --template ghcr.io/devcontainers/templates/go --workspace ~/src/myapp
+Template options are prompted for unless given with --option or --yes is set.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		if newTemplateFlag == "" {
+			return usageErrorf("Error: --template is required")
+		}
+
+		options := map[string]string{}
+		for _, option := range newOptionFlag {
+			key, value, found := strings.Cut(option, "=")
+			if !found {
+				return usageErrorf("Error: invalid --option %s, expected name=value", option)
+			}
+			options[key] = value
+		}
+
+		workspace := newWorkspaceFlag
+		if workspace == "" {
+			workspace = "."
+		}
+		workspace, err := filepath.Abs(workspace)
+		if err != nil {
+			return fmt.Errorf("error resolving workspace: %w", err)
+		}
+
+		configPath := core.BoxConfigPath(envName)
+		if _, err := os.Stat(configPath); err == nil {
+			return stateConflictf("Environment %s already exists at %s", envName, configPath)
+		}
+
+		fmt.Printf("Fetching template %s...\n", newTemplateFlag)
+		template, err := core.FetchTemplate(newTemplateFlag)
+		if err != nil {
+			return fmt.Errorf("error fetching template: %w", err)
+		}
+
+		if !newYesFlag && term.IsTerminal(int(os.Stdin.Fd())) {
+			promptTemplateOptions(template, options)
+		}
+
+		written, err := template.Apply(workspace, options, newForceFlag)
+		if err != nil {
+			return fmt.Errorf("error applying template: %w", err)
+		}
+		for _, path := range written {
+			fmt.Printf("Wrote %s\n", filepath.Join(workspace, path))
+		}
+
+		if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+			return fmt.Errorf("error creating %s: %w", filepath.Dir(configPath), err)
+		}
+		if err := os.WriteFile(configPath, []byte(fmt.Sprintf("workspace: %s\n", workspace)), 0644); err != nil {
+			return fmt.Errorf("error writing %s: %w", configPath, err)
+		}
+		fmt.Printf("Created %s, start it with tape up %s\n", configPath, envName)
+		return nil
+	},
+}
+
+// promptTemplateOptions asks for each option not already set, keeping the
+// default when the answer is empty
+func promptTemplateOptions(template *core.Template, options map[string]string) {
+	reader := bufio.NewReader(os.Stdin)
+	for _, name := range template.Metadata.OptionNames() {
+		if _, ok := options[name]; ok {
+			continue
+		}
+		option := template.Metadata.Options[name]
+
+		for {
+			fmt.Printf("%s", name)
+			if option.Description != "" {
+				fmt.Printf(" (%s)", option.Description)
+			}
+			if choices := slices.Concat(option.Enum, option.Proposals); len(choices) > 0 {
+				fmt.Printf(" [%s]", strings.Join(choices, ", "))
+			}
+			fmt.Printf(" (default %s): ", option.DefaultString())
+
+			answer, _ := reader.ReadString('\n')
+			answer = strings.TrimSpace(answer)
+			if answer == "" {
+				break
+			}
+			if err := option.Validate(answer); err != nil {
+				fmt.Println(err)
+				continue
+			}
+			options[name] = answer
+			break
+		}
+	}
+}
+
+func init() {
+	newCmd.Flags().StringVar(&newTemplateFlag, "template", "", "Template to create the environment from, e.g. ghcr.io/devcontainers/templates/go")
+	newCmd.Flags().StringVar(&newWorkspaceFlag, "workspace", "", "Directory to write the template into (defaults to the current directory)")
+	newCmd.Flags().StringArrayVar(&newOptionFlag, "option", nil, "Set a template option, name=value (repeatable)")
+	newCmd.Flags().BoolVarP(&newYesFlag, "yes", "y", false, "Use the defaults for options that aren't set instead of prompting")
+	newCmd.Flags().BoolVar(&newForceFlag, "force", false, "Overwrite existing files in the workspace")
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	openEditorFlag string
+)
+
+var openCmd = &cobra.Command{
+	Use:   "open [name]",
+	Short: "Opens a dev environment in an editor",
+	Long: `Start the dev environment if needed, then open its workspace in an editor attached to the container.
+The editor defaults to the editor setting in the global config, or vscode.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		globalConfig, err := core.LoadGlobalConfig()
+		if err != nil {
+			return err
+		}
+
+		editor := openEditorFlag
+		if editor == "" {
+			editor = globalConfig.Editor
+		}
+		if editor == "" {
+			editor = core.EditorVSCode
+		}
+		if !slices.Contains(core.Editors, editor) {
+			return usageErrorf("Unknown editor %s, expected one of %v", editor, core.Editors)
+		}
+
+		if err := ensureBoxRunning(envName); err != nil {
+			return err
+		}
+
+		config, err := core.LoadBoxConfig(envName)
+		if err != nil {
+			return err
+		}
+
+		folder, err := config.ContainerWorkspaceFolder()
+		if err != nil {
+			return err
+		}
+
+		var editorCmd *exec.Cmd
+		switch editor {
+		case core.EditorVSCode:
+			editorCmd = exec.Command("code", "--folder-uri", core.AttachedContainerURI(config.ContainerName(), folder))
+		case core.EditorCursor:
+			editorCmd = exec.Command("cursor", "--folder-uri", core.AttachedContainerURI(config.ContainerName(), folder))
+		case core.EditorJetBrains:
+			// Gateway connects over SSH, see tape ssh
+			port := core.SSHPort()
+			fmt.Println(sshConfig(config, envName, port))
+			editorCmd = core.OpenURLCommand(core.GatewayURI("localhost", port, envName, folder))
+		}
+
+		editorCmd.Stdout = os.Stdout
+		editorCmd.Stderr = os.Stderr
+		if err := editorCmd.Run(); err != nil {
+			return fmt.Errorf("error launching %s: %w", editor, err)
+		}
+		return nil
+	},
+}
+
+// ensureBoxRunning starts or resumes the environment unless it is already running
+func ensureBoxRunning(envName string) error {
+	summary, err := core.GetBoxSummary(envName)
+	if err != nil {
+		return fmt.Errorf("error getting box summary for %s: %v", envName, err)
+	}
+
+	switch summary.State {
+	case core.BoxStateRunning:
+		return nil
+	case core.BoxStatePaused:
+		fmt.Println("Resuming box", envName)
+		return core.ResumeBox(envName)
+	default:
+		fmt.Println("Starting box", envName)
+		return core.UpBox(envName, core.UpOptions{})
+	}
+}
+
+func init() {
+	openCmd.Flags().StringVar(&openEditorFlag, "editor", "", "Editor to open: vscode, cursor or jetbrains")
+}
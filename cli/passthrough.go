@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// passthroughFlags returns the flags in args that cmd doesn't define, with
+// their values, for commands that pass them on to the devcontainer CLI.
+// Like pflag, which skips them, it takes the argument after an unknown flag
+// without = as its value unless it's a flag too, so boolean flags go after
+// the positional arguments or as --flag=true.
+func passthroughFlags(cmd *cobra.Command, args []string) []string {
+	var passthrough []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' {
+			continue
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		var flag *pflag.Flag
+		if strings.HasPrefix(arg, "--") {
+			flag = cmd.Flag(name)
+		} else if len(name) == 1 {
+			flag = cmd.Flags().ShorthandLookup(name)
+		}
+		takesValue := !hasValue && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-")
+		switch {
+		case flag != nil:
+			// skip the value of tape's own flags
+			if takesValue && flag.NoOptDefVal == "" {
+				i++
+			}
+		case takesValue:
+			passthrough = append(passthrough, arg, args[i+1])
+			i++
+		default:
+			passthrough = append(passthrough, arg)
+		}
+	}
+	return passthrough
+}
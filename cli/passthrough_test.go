@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func TestPassthroughFlags(t *testing.T) {
+	cmd := &cobra.Command{Use: "up"}
+	cmd.Flags().Bool("rebuild", false, "")
+	cmd.Flags().BoolP("verbose", "v", false, "")
+	cmd.Flags().StringP("config-name", "c", "", "")
+
+	tests := []struct {
+		name     string
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "no flags",
+			args:     []string{"up", "web"},
+			expected: nil,
+		},
+		{
+			name:     "flag with a value",
+			args:     []string{"up", "web", "--log-level", "debug"},
+			expected: []string{"--log-level", "debug"},
+		},
+		{
+			name:     "flag with an = value",
+			args:     []string{"up", "--log-level=debug", "web"},
+			expected: []string{"--log-level=debug"},
+		},
+		{
+			name:     "bool flag after the positional arguments",
+			args:     []string{"up", "web", "--skip-post-attach"},
+			expected: []string{"--skip-post-attach"},
+		},
+		{
+			name:     "bool flag followed by another flag",
+			args:     []string{"up", "web", "--skip-post-attach", "--log-level", "debug"},
+			expected: []string{"--skip-post-attach", "--log-level", "debug"},
+		},
+		{
+			name:     "bool flag before a positional argument takes it as its value",
+			args:     []string{"up", "--skip-post-attach", "web"},
+			expected: []string{"--skip-post-attach", "web"},
+		},
+		{
+			name:     "tape's bool flag leaves the next argument alone",
+			args:     []string{"up", "--rebuild", "web", "--log-level", "debug"},
+			expected: []string{"--log-level", "debug"},
+		},
+		{
+			name:     "tape's flag with a value",
+			args:     []string{"up", "web", "--config-name", "python", "--log-level", "debug"},
+			expected: []string{"--log-level", "debug"},
+		},
+		{
+			name:     "tape's flag with an = value",
+			args:     []string{"up", "web", "--config-name=python", "--skip-post-attach"},
+			expected: []string{"--skip-post-attach"},
+		},
+		{
+			name:     "tape's shorthands",
+			args:     []string{"up", "-v", "web", "-c", "python", "--log-level", "debug"},
+			expected: []string{"--log-level", "debug"},
+		},
+		{
+			name:     "unknown shorthand",
+			args:     []string{"up", "web", "-x", "value"},
+			expected: []string{"-x", "value"},
+		},
+		{
+			name:     "arguments after -- aren't flags",
+			args:     []string{"up", "web", "--skip-post-attach", "--", "--log-level", "debug"},
+			expected: []string{"--skip-post-attach"},
+		},
+		{
+			name:     "a lone - is an argument",
+			args:     []string{"up", "-", "web"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := passthroughFlags(cmd, tt.args)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("passthroughFlags(%q) = %q, want %q", tt.args, got, tt.expected)
+			}
+		})
+	}
+}
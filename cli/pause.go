@@ -0,0 +1,61 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var pauseCmd = &cobra.Command{
+	Use:   "pause [name]",
+	Short: "Pauses a running dev environment",
+	Long:  `Freeze all processes in a running dev environment, keeping their in-memory state.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		summary, err := core.GetBoxSummary(envName)
+		if err != nil {
+			return fmt.Errorf("error getting box summary for %s: %w", envName, err)
+		}
+
+		if summary.State != core.BoxStateRunning {
+			return stateConflictf("Cannot pause %s: container is not running (current state: %s)", envName, summary.State)
+		}
+
+		err = core.PauseBox(envName)
+		if err != nil {
+			return fmt.Errorf("error pausing container: %w", err)
+		}
+
+		fmt.Printf("Paused %s\n", envName)
+		return nil
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume [name]",
+	Short: "Resumes a paused dev environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		summary, err := core.GetBoxSummary(envName)
+		if err != nil {
+			return fmt.Errorf("error getting box summary for %s: %w", envName, err)
+		}
+
+		if summary.State != core.BoxStatePaused {
+			return stateConflictf("Cannot resume %s: container is not paused (current state: %s)", envName, summary.State)
+		}
+
+		err = core.ResumeBox(envName)
+		if err != nil {
+			return fmt.Errorf("error resuming container: %w", err)
+		}
+
+		fmt.Printf("Resumed %s\n", envName)
+		return nil
+	},
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+)
+
+// printUpPlan prints what tape up would do, see core.PlanUp
+func printUpPlan(plan *core.UpPlan) {
+	fmt.Println(colorize(colorBold, "Plan for "+plan.EnvName))
+	if len(plan.Images) > 0 {
+		fmt.Printf("Pull:       %s\n", strings.Join(plan.Images, ", "))
+	}
+	if plan.Dockerfile != "" {
+		fmt.Printf("Build:      %s, context %s\n", plan.Dockerfile, plan.Context)
+	}
+	if len(plan.Features) > 0 {
+		fmt.Printf("Features:   %s\n", strings.Join(plan.Features, ", "))
+	}
+	fmt.Printf("Run:        %s\n", plan.CommandLine())
+	if plan.CLIImage != "" {
+		fmt.Printf("            in a container of %s, with the docker socket and %s\n", plan.CLIImage, strings.Join(plan.HostPaths, ", "))
+	}
+	if len(plan.RunArgs) > 0 {
+		fmt.Printf("Run args:   %s\n", strings.Join(plan.RunArgs, " "))
+	}
+
+	mounts := plan.Mounts
+	if plan.WorkspaceMount != "" {
+		mounts = append([]string{plan.WorkspaceMount}, mounts...)
+	}
+	printPlanList("Mounts", mounts)
+	printPlanEnv("Container env", plan.ContainerEnv)
+	printPlanEnv("Remote env", plan.RemoteEnv)
+	printPlanList("Secrets", plan.Secrets)
+
+	if len(plan.Lifecycle) > 0 {
+		fmt.Println("Lifecycle:")
+		for _, command := range plan.Lifecycle {
+			phase := command.Phase
+			switch {
+			case command.OnHost:
+				phase += " (on the host)"
+			case command.Background:
+				phase += " (in the background)"
+			}
+			for _, line := range command.Commands {
+				fmt.Printf("  %s: %s\n", phase, line)
+			}
+		}
+	}
+}
+
+func printPlanList(title string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, value := range values {
+		fmt.Printf("  %s\n", value)
+	}
+}
+
+func printPlanEnv(title string, env map[string]string) {
+	if len(env) == 0 {
+		return
+	}
+	fmt.Printf("%s:\n", title)
+	for _, name := range slices.Sorted(maps.Keys(env)) {
+		fmt.Printf("  %s=%s\n", name, env[name])
+	}
+}
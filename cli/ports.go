@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var portsCmd = &cobra.Command{
+	Use:   "ports [name]",
+	Short: "List the host ports a dev environment is reachable on",
+	Long: `List the environment's published ports, the port forwards tape daemon runs for
+it, and the port of tape's SSH server. Forwards and the SSH server move to the
+next free port when the one they asked for is taken, this shows where they ended up.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		ports, err := core.GetBoxPorts(envName)
+		if err != nil {
+			return fmt.Errorf("error getting ports for %s: %w", envName, err)
+		}
+
+		t := newTable("PORT", "HOST ADDRESS", "VIA")
+		for _, port := range ports {
+			t.addRow(fmt.Sprintf("%d/%s", port.ContainerPort, port.Protocol), fmt.Sprintf("localhost:%d", port.HostPort), "published")
+		}
+		if client := daemonClient(); client != nil {
+			forwards, err := client.Forwards()
+			if err != nil {
+				return fmt.Errorf("error listing forwards: %w", err)
+			}
+			for _, forward := range forwards {
+				if forward.EnvName == envName {
+					t.addRow(strconv.Itoa(forward.ContainerPort)+"/tcp", forward.Address, "forward "+forward.ID)
+				}
+			}
+		}
+		t.addRow("ssh", fmt.Sprintf("localhost:%d", core.SSHPort()), "tape ssh")
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+func init() {
+	addTableFlags(portsCmd)
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pruneAutoFlag bool
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Removes containers left behind by deleted environments",
+	Long: `Remove stopped containers tape created for environments that no longer have a config file.
+With --auto, also enforce the retention policy from the global config, e.g.
+
+retention:
+  stopped-after: 720h  # remove containers stopped for more than 30 days
+  max-snapshots: 5     # keep the 5 newest snapshots of each environment`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if pruneAutoFlag {
+			return autoPrune()
+		}
+
+		removed, err := core.PruneBoxContainers()
+		for _, c := range removed {
+			fmt.Printf("Removed %s (%s)\n", c.EnvName, shortID(c.ContainerID))
+		}
+		if err != nil {
+			return fmt.Errorf("error pruning containers: %w", err)
+		}
+		if len(removed) == 0 {
+			fmt.Println("Nothing to prune")
+		}
+		return nil
+	},
+}
+
+func autoPrune() error {
+	report, err := core.AutoPrune()
+	if report != nil {
+		for _, c := range report.Containers {
+			fmt.Printf("Removed %s (%s)\n", c.EnvName, shortID(c.ContainerID))
+		}
+		for _, snapshot := range report.Snapshots {
+			fmt.Printf("Removed snapshot %s of %s\n", snapshot.Tag, snapshot.EnvName)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error pruning: %w", err)
+	}
+	if len(report.Containers) == 0 && len(report.Snapshots) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+	return nil
+}
+
+// shortID truncates a container ID the way docker ps does
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func init() {
+	pruneCmd.Flags().BoolVar(&pruneAutoFlag, "auto", false, "Also remove stopped containers and snapshots the global retention policy expires")
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var publishPortCmd = &cobra.Command{
+	Use:   "publish-port [name] [[hostPort:]containerPort]",
+	Short: "Publishes a port of a running dev environment on the host",
+	Long: `Publish a container port on the host's localhost without recreating the container, which adding
+it to ports in the box config requires. Docker can't add ports to a running container, so a small
+proxy container on the environment's network publishes it. Without a host port docker picks a free
+one. Without a port, the ports published this way are listed.
+
+Published ports last until tape unpublish-port, or until the environment is stopped.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		if len(args) == 1 {
+			ports, err := core.PublishedPorts(envName)
+			if err != nil {
+				return fmt.Errorf("error listing published ports of %s: %w", envName, err)
+			}
+			t := newTable("CONTAINER PORT", "HOST PORT", "PROXY")
+			for _, port := range ports {
+				t.addRow(strconv.Itoa(port.ContainerPort), strconv.Itoa(port.HostPort), shortID(port.ProxyID))
+			}
+			t.print(os.Stdout)
+			return nil
+		}
+
+		host, container, found := strings.Cut(args[1], ":")
+		if !found {
+			host, container = "0", host
+		}
+		hostPort, err := strconv.Atoi(host)
+		if err != nil || hostPort < 0 {
+			return usageErrorf("Invalid host port %s", host)
+		}
+		containerPort, err := strconv.Atoi(container)
+		if err != nil || containerPort <= 0 {
+			return usageErrorf("Invalid port %s", container)
+		}
+
+		published, err := core.PublishPort(envName, containerPort, hostPort)
+		if err != nil {
+			return fmt.Errorf("error publishing port %d of %s: %w", containerPort, envName, err)
+		}
+		fmt.Printf("Published port %d of %s on localhost:%d\n", containerPort, envName, published.HostPort)
+		return nil
+	},
+}
+
+var unpublishPortCmd = &cobra.Command{
+	Use:   "unpublish-port [name] [containerPort]",
+	Short: "Stops publishing a port published with tape publish-port",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		port, err := strconv.Atoi(args[1])
+		if err != nil || port <= 0 {
+			return usageErrorf("Invalid port %s", args[1])
+		}
+		if err := core.UnpublishPort(envName, port); err != nil {
+			return fmt.Errorf("error unpublishing port %d of %s: %w", port, envName, err)
+		}
+		fmt.Printf("Unpublished port %d of %s\n", port, envName)
+		return nil
+	},
+}
+
+func init() {
+	addTableFlags(publishPortCmd)
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reportOutputFlag string
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Writes a diagnostic bundle to attach to bug reports",
+	Long: `Write a tarball with tape's and docker's versions, the tape doctor checks, the state of each
+environment, the recent history log, and the global, box and devcontainer configs. Passwords, tokens,
+environment variables and other values that could be secrets are replaced with REDACTED.
+
+The report is only written to disk, nothing is sent anywhere. Look through it before sharing it.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := reportOutputFlag
+		if path == "" {
+			path = fmt.Sprintf("tape-report-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating %s: %w", path, err)
+		}
+		defer file.Close()
+
+		if err := core.WriteReport(file); err != nil {
+			os.Remove(path)
+			return fmt.Errorf("error writing report: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("error writing report: %w", err)
+		}
+		fmt.Printf("Wrote %s, review it before attaching it to a bug report\n", path)
+		return nil
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVarP(&reportOutputFlag, "output", "o", "", "Where to write the report (default tape-report-<time>.tar.gz)")
+}
@@ -1,11 +1,8 @@
 package cli
 
 import (
-	"context"
 	"fmt"
-	"os"
 
-	"github.com/mikeocool/tape/container"
 	"github.com/mikeocool/tape/core"
 	"github.com/spf13/cobra"
 )
@@ -15,32 +12,30 @@ var rmCmd = &cobra.Command{
 	Short: "Remove a stopped container",
 	Long:  `Remove a container for the specified environment name if it is in stopped state.`,
 	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		envName := args[0]
 
 		// Get box summary to check container state
 		summary, err := core.GetBoxSummary(envName)
 		if err != nil {
-			fmt.Printf("Error getting box summary for %s: %v\n", envName, err)
-			os.Exit(1)
+			return fmt.Errorf("error getting box summary for %s: %w", envName, err)
 		}
 
 		// Check if the container is in stopped state
-		if summary.State != core.BoxStateStopped {
-			fmt.Printf("Cannot remove %s: container is not stopped (current state: %s)\n", envName, summary.State)
-			os.Exit(1)
+		if !summary.State.CanRemove() {
+			return stateConflictf("Cannot remove %s: container is not stopped (current state: %s)", envName, summary.State)
 		}
 
 		fmt.Printf("Removing container %s...\n", envName)
 
 		// Remove the container
 
-		err = container.RemoveContainer(context.Background(), summary.ContainerID)
+		err = core.RemoveBox(envName)
 		if err != nil {
-			fmt.Printf("Error removing container: %v\n", err)
-			os.Exit(1)
+			return fmt.Errorf("error removing container: %w", err)
 		}
 
 		fmt.Printf("Successfully removed container for %s\n", envName)
+		return nil
 	},
 }
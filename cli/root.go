@@ -2,14 +2,38 @@ package cli
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/mikeocool/tape/core"
 	"github.com/spf13/cobra"
 )
 
+// commandStarted is set once cobra has validated the arguments and is about to run a command
+var commandStarted bool
+
 var rootCmd = &cobra.Command{
 	Use:   "tape",
 	Short: "Manage dev environments",
-	Run: func(cmd *cobra.Command, args []string) {
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		commandStarted = true
+		cmd.SilenceUsage = true
+		core.HostOverride = hostFlag
+		core.Offline = offlineFlag
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
 		fmt.Println("tape")
+		return nil
 	},
 }
+
+var (
+	hostFlag    string
+	offlineFlag bool
+)
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&hostFlag, "host", os.Getenv("TAPE_HOST"),
+		"Run environments on another machine, as [user@]host[:port], over SSH (default $TAPE_HOST)")
+	rootCmd.PersistentFlags().BoolVar(&offlineFlag, "offline", false,
+		"Don't contact registries, use only images that exist locally")
+}
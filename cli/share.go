@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [name]",
+	Short: "Let teammates connect to a dev environment over SSH",
+	Long: `Authorize teammates' public keys to connect to the environment through tape's
+SSH server, to pair on a shared dev server. --key takes github:<user> or
+gitlab:<user> for the keys the user published there, or a public key file.
+You're notified when a teammate connects, if notifications are configured.
+The SSH server only listens on 127.0.0.1 by default, set ssh.listen-address in
+the global config, e.g. to 0.0.0.0, for teammates to reach it.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		sources, _ := cmd.Flags().GetStringSlice("key")
+		if len(sources) == 0 {
+			return fmt.Errorf("Error: --key is required")
+		}
+
+		for _, source := range sources {
+			share, err := core.ShareBox(envName, source)
+			if err != nil {
+				return fmt.Errorf("error sharing %s: %w", envName, err)
+			}
+			fmt.Printf("Shared %s with %s (%d keys)\n", envName, source, len(share.Keys))
+		}
+		fmt.Printf("They connect with: ssh %s@<this host> -p %d\n", envName, core.SSHPort())
+		return nil
+	},
+}
+
+var shareLsCmd = &cobra.Command{
+	Use:   "ls [name]",
+	Short: "List who a dev environment is shared with",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		shares, err := core.ListShares(envName)
+		if err != nil {
+			return fmt.Errorf("error listing shares of %s: %w", envName, err)
+		}
+
+		t := newTable("SOURCE", "KEYS", "ADDED")
+		for _, share := range shares {
+			t.addRow(share.Source, strconv.Itoa(len(share.Keys)), share.Added.Local().Format(time.DateTime))
+		}
+		t.print(os.Stdout)
+		return nil
+	},
+}
+
+var shareRevokeCmd = &cobra.Command{
+	Use:   "revoke [name] [source]",
+	Short: "Stop sharing a dev environment with a teammate",
+	Long: `Remove the keys a share authorized. Connections the teammate already has open
+stay open until they disconnect.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName, source := args[0], args[1]
+		if err := core.RevokeShare(envName, source); err != nil {
+			return fmt.Errorf("error revoking share: %w", err)
+		}
+		fmt.Printf("Stopped sharing %s with %s\n", envName, source)
+		return nil
+	},
+}
+
+func init() {
+	shareCmd.Flags().StringSlice("key", nil, "Keys to authorize: github:<user>, gitlab:<user> or a public key file (repeatable)")
+	shareCmd.AddCommand(shareLsCmd)
+	shareCmd.AddCommand(shareRevokeCmd)
+	addTableFlags(shareLsCmd)
+}
@@ -0,0 +1,85 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var shimCmd = &cobra.Command{
+	Use:   "shim",
+	Short: "Manage host commands that run inside dev environments",
+	Long: `Shims are small scripts in ~/.tape/bin that run a command inside an environment
+with tape exec, so e.g. npm typed on the host runs in the container.
+Add ~/.tape/bin to your PATH, or use tape direnv.`,
+}
+
+var shimAddCmd = &cobra.Command{
+	Use:   "add [name] [cmd...]",
+	Short: "Add shims for commands",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("error finding the tape executable: %w", err)
+		}
+
+		for _, command := range args[1:] {
+			shim, err := core.AddShim(envName, command, executable)
+			if err != nil {
+				return fmt.Errorf("error adding shim for %s: %w", command, err)
+			}
+			fmt.Printf("Added %s\n", shim.Path)
+		}
+
+		path := filepath.SplitList(os.Getenv("PATH"))
+		if !slices.Contains(path, core.BinDir()) {
+			fmt.Printf("Add %s to your PATH to use the shims\n", core.BinDir())
+		}
+		return nil
+	},
+}
+
+var shimRmCmd = &cobra.Command{
+	Use:   "rm [cmd...]",
+	Short: "Remove shims",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, command := range args {
+			if err := core.RemoveShim(command); err != nil {
+				return fmt.Errorf("error removing shim for %s: %w", command, err)
+			}
+			fmt.Printf("Removed %s\n", command)
+		}
+		return nil
+	},
+}
+
+var shimLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "List shims",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		shims, err := core.ListShims()
+		if err != nil {
+			return fmt.Errorf("error listing shims: %w", err)
+		}
+
+		for _, shim := range shims {
+			fmt.Printf("%s\t%s\n", shim.Command, shim.EnvName)
+		}
+		return nil
+	},
+}
+
+func init() {
+	shimCmd.AddCommand(shimAddCmd)
+	shimCmd.AddCommand(shimRmCmd)
+	shimCmd.AddCommand(shimLsCmd)
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot [name] [tag]",
+	Short: "Snapshot a dev environment",
+	Long: `Commit a dev environment's container to an image and archive its named volumes.
+If no tag is given, a timestamp is used.
+Example: tape snapshot myenv before-upgrade`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		tag := time.Now().Format("20060102-150405")
+		if len(args) > 1 {
+			tag = args[1]
+		}
+
+		fmt.Printf("Creating snapshot %s of %s...\n", tag, envName)
+
+		snapshot, err := core.CreateSnapshot(envName, tag)
+		if err != nil {
+			return fmt.Errorf("error creating snapshot: %w", err)
+		}
+
+		fmt.Printf("Created snapshot %s (%s, %d volumes)\n", tag, snapshot.Image, len(snapshot.Volumes))
+		return nil
+	},
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [name] [tag]",
+	Short: "Restore a dev environment from a snapshot",
+	Long: `Recreate a dev environment's container from a snapshot and restore its named volumes.
+Example: tape restore myenv before-upgrade`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		tag := args[1]
+
+		fmt.Printf("Restoring %s from snapshot %s...\n", envName, tag)
+
+		err := core.RestoreSnapshot(envName, tag)
+		if err != nil {
+			return fmt.Errorf("error restoring snapshot: %w", err)
+		}
+
+		fmt.Printf("Restored %s from snapshot %s\n", envName, tag)
+		return nil
+	},
+}
@@ -1,14 +1,62 @@
 package cli
 
 import (
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
 	"github.com/mikeocool/tape/ssh"
 	"github.com/spf13/cobra"
 )
 
+var sshReadOnlyFlag []string
+
 var sshCmd = &cobra.Command{
 	Use:   "ssh",
 	Short: "SSH into dev environment",
-	Run: func(cmd *cobra.Command, args []string) {
-		ssh.Start()
+	Long: `Run an SSH server on port 2222 whose users are the dev environments, or on the
+next free port when 2222 is taken. tape ssh-config and tape ports print the
+port it listens on. It listens on 127.0.0.1 unless ssh.listen-address in the
+global config says otherwise. The password, dev, is only accepted from this
+host, and not at all while any environment is shared (see tape share), when
+only the shared keys log in.
+
+Each environment can have ssh.max-sessions sessions open at once, 10 unless
+set in the global config. With ssh.idle-timeout set, e.g. to 1h, connections
+nothing was sent on for that long are closed. A session's shell is ended when
+its SSH channel closes.
+
+Files are edited remotely over SFTP, served by the container's sftp-server.
+Environments passed to --read-only, or listed in ssh.read-only in the global
+config, only allow reading files over SFTP, while shells and commands still
+run, e.g. to let a teammate look around while debugging.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return ssh.Start(ssh.Options{ReadOnly: sshReadOnlyFlag})
 	},
 }
+
+var sshConfigCmd = &cobra.Command{
+	Use:   "ssh-config [name]",
+	Short: "Print an ssh_config block for connecting to a dev environment",
+	Long: `Print a Host block for ~/.ssh/config that connects to the environment through
+tape's SSH server (see tape ssh), on the port the server listens on.
+Example: tape ssh-config myenv >> ~/.ssh/config`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		config, err := core.LoadBoxConfig(envName)
+		if err != nil {
+			return err
+		}
+		fmt.Println(sshConfig(config, envName, core.SSHPort()))
+		return nil
+	},
+}
+
+// sshConfig returns the ssh_config Host block for the environment
+func sshConfig(config *core.BoxConfig, envName string, port int) string {
+	return fmt.Sprintf("Host tape-%s\n  HostName localhost\n  Port %d\n  User %s\n", config.ContainerName(), port, envName)
+}
+
+func init() {
+	sshCmd.Flags().StringSliceVar(&sshReadOnlyFlag, "read-only", nil, "Environments whose files can't be changed over SFTP")
+}
@@ -0,0 +1,150 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	statsNoStreamFlag bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [name...]",
+	Short: "Shows live resource usage of dev environments",
+	Long: `Show CPU, memory, network and block I/O for each environment, with totals.
+Without names, all running environments are shown. Use --no-stream to print a single sample.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envNames := args
+		if len(envNames) == 0 {
+			running, err := runningEnvironments()
+			if err != nil {
+				return err
+			}
+			if len(running) == 0 {
+				fmt.Println("No running environments")
+				return nil
+			}
+			envNames = running
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		var mu sync.Mutex
+		latest := map[string]core.BoxStats{}
+		record := func(stats core.BoxStats) {
+			mu.Lock()
+			latest[stats.EnvName] = stats
+			mu.Unlock()
+		}
+
+		if statsNoStreamFlag {
+			if err := core.WatchBoxStats(ctx, envNames, false, record); err != nil {
+				return fmt.Errorf("error getting stats: %w", err)
+			}
+			printStats(latest)
+			return nil
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- core.WatchBoxStats(ctx, envNames, true, record)
+		}()
+
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case err := <-done:
+				if err != nil {
+					return fmt.Errorf("error getting stats: %w", err)
+				}
+				return nil
+			case <-ticker.C:
+				mu.Lock()
+				// move the cursor home and clear the screen, like docker stats
+				fmt.Print("\033[H\033[2J")
+				printStats(latest)
+				mu.Unlock()
+			}
+		}
+	},
+}
+
+// runningEnvironments returns the configured environments whose container is running
+func runningEnvironments() ([]string, error) {
+	envs, err := core.ListBoxConfigs()
+	if err != nil {
+		return nil, fmt.Errorf("error listing environments: %w", err)
+	}
+
+	var running []string
+	for _, name := range envs {
+		summary, err := core.GetBoxSummary(name)
+		if err != nil {
+			return nil, fmt.Errorf("error getting box summary for %s: %w", name, err)
+		}
+		if summary.State == core.BoxStateRunning {
+			running = append(running, name)
+		}
+	}
+	return running, nil
+}
+
+func printStats(latest map[string]core.BoxStats) {
+	names := make([]string, 0, len(latest))
+	for name := range latest {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	t := newTable("NAME", "CPU %", "MEM USAGE / LIMIT", "NET I/O", "BLOCK I/O")
+
+	var total core.BoxStats
+	for _, name := range names {
+		stats := latest[name]
+		addStatsRow(t, name, stats)
+		total.Stats = total.Add(stats.Stats)
+	}
+	if len(names) > 1 {
+		addStatsRow(t, "TOTAL", total)
+	}
+	t.print(os.Stdout)
+}
+
+func addStatsRow(t *table, name string, stats core.BoxStats) {
+	t.addRow(
+		name,
+		fmt.Sprintf("%.2f%%", stats.CPUPercent),
+		formatBytes(stats.MemoryUsage)+" / "+formatBytes(stats.MemoryLimit),
+		formatBytes(stats.NetworkRx)+" / "+formatBytes(stats.NetworkTx),
+		formatBytes(stats.BlockRead)+" / "+formatBytes(stats.BlockWrite))
+}
+
+// formatBytes formats a size with binary units, e.g. 1.5GiB
+func formatBytes(size uint64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := uint64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	addTableFlags(statsCmd)
+	statsCmd.Flags().BoolVar(&statsNoStreamFlag, "no-stream", false, "Print a single sample and exit")
+}
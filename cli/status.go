@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status [name]",
+	Short: "Shows the status of a dev environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		summary, err := getCachedBoxSummary(envName)
+		if err != nil {
+			return fmt.Errorf("error getting box summary for %s: %w", envName, err)
+		}
+
+		fmt.Printf("Environment: %s\n", envName)
+		fmt.Printf("State:       %s\n", colorize(stateColor(string(summary.State)), string(summary.State)))
+		if summary.ContainerID != "" {
+			fmt.Printf("Container:   %s\n", shortID(summary.ContainerID))
+			restarts, err := core.GetBoxRestarts(envName)
+			if err != nil {
+				return fmt.Errorf("error inspecting the container of %s: %w", envName, err)
+			}
+			if restarts.Policy != "" && restarts.Policy != "no" {
+				fmt.Printf("Restart:     %s, %d restarts\n", restarts.Policy, restarts.Count)
+			}
+			if restarts.ExitCode != 0 {
+				fmt.Printf("Exit code:   %d\n", restarts.ExitCode)
+			}
+		}
+
+		if len(summary.Containers) > 1 {
+			fmt.Printf("\n%d containers match %s:\n", len(summary.Containers), envName)
+			t := newTable("", "CONTAINER", "STATE", "CREATED")
+			t.colorColumn(2, stateColor)
+			for i, c := range summary.Containers {
+				marker := ""
+				if i == 0 {
+					marker = "*"
+				}
+				created := time.Unix(c.Created, 0).Format(time.DateTime)
+				t.addRow(marker, shortID(c.ID), string(c.State), created)
+			}
+			t.print(os.Stdout)
+			fmt.Printf("\nUse tape adopt %s <container> to choose a different one.\n", envName)
+		}
+		return nil
+	},
+}
+
+var adoptCmd = &cobra.Command{
+	Use:   "adopt [name] [container]",
+	Short: "Chooses the container used by a dev environment",
+	Long:  `When several containers match an environment, use the given container ID (or unique prefix) from now on.`,
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := core.AdoptContainer(args[0], args[1])
+		if err != nil {
+			return fmt.Errorf("error adopting container: %w", err)
+		}
+		fmt.Printf("%s now uses container %s\n", args[0], shortID(id))
+		return nil
+	},
+}
@@ -1,44 +1,71 @@
 package cli
 
 import (
-	"context"
 	"fmt"
-	"os"
+	"slices"
 
-	"github.com/mikeocool/tape/container"
 	"github.com/mikeocool/tape/core"
 	"github.com/spf13/cobra"
 )
 
 var stopCmd = &cobra.Command{
-	Use:   "stop [name]",
+	Use:   "stop [name|@group]",
 	Short: "Stops a running dev environment",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
-		envName := args[0]
+	Long: `Stops a running dev environment. @group stops the running environments of a
+group from the global config, in the reverse of the order tape up starts them.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !core.IsGroup(args[0]) {
+			return stopBox(args[0])
+		}
 
-		// Get box summary to check the state
-		summary, err := core.GetBoxSummary(envName)
+		envNames, err := resolveEnvNames(args[0])
 		if err != nil {
-			fmt.Printf("Error getting box summary for %s: %v\n", envName, err)
-			os.Exit(1)
+			return err
 		}
-
-		// Check if the box is running
-		if summary.State != core.BoxStateRunning {
-			fmt.Printf("Cannot remove %s: container is not running (current state: %s)\n", envName, summary.State)
-			os.Exit(1)
+		slices.Reverse(envNames)
+		for _, envName := range envNames {
+			summary, err := getBoxSummary(envName)
+			if err != nil {
+				return fmt.Errorf("error getting box summary for %s: %w", envName, err)
+			}
+			if !summary.State.CanStop() {
+				fmt.Printf("%s is not running\n", envName)
+				continue
+			}
+			if err := stopBox(envName); err != nil {
+				return err
+			}
 		}
+		return nil
+	},
+}
 
-		fmt.Printf("Stopping container %s...\n", envName)
+// stopBox stops a running environment, through the daemon when it runs
+func stopBox(envName string) error {
+	// Get box summary to check the state
+	summary, err := getBoxSummary(envName)
+	if err != nil {
+		return fmt.Errorf("error getting box summary for %s: %w", envName, err)
+	}
 
-		// Stop the container
-		err = container.StopContainer(context.Background(), summary.ContainerID)
-		if err != nil {
-			fmt.Printf("Error stopping container: %v\n", err)
-			os.Exit(1)
-		}
+	// Check if the box is running
+	if !summary.State.CanStop() {
+		return stateConflictf("Cannot stop %s: container is not running (current state: %s)", envName, summary.State)
+	}
 
-		fmt.Printf("Successfully stopped and removed container for %s\n", envName)
-	},
+	fmt.Printf("Stopping container %s...\n", envName)
+
+	// Stop the container
+	if client := daemonClient(); client != nil {
+		err = client.Stop(envName)
+	} else {
+		err = core.StopBox(envName)
+	}
+	if err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
+	}
+
+	fmt.Printf("Successfully stopped and removed container for %s\n", envName)
+	return nil
 }
@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	syncPullFlag bool
+	syncOnceFlag bool
+)
+
+var syncCmd = &cobra.Command{
+	Use:   "sync [name]",
+	Short: "Sync the workspace of a sync mode dev environment",
+	Long: `For environments with sync: true, whose workspace lives in a volume instead of a
+bind mount. With mutagen installed, starts a two-way mutagen sync session.
+Otherwise copies the workspace into the container, then until interrupted keeps
+pushing changes made on the host and pulling files created or modified in the
+container. When both change a file, the last change wins. Files deleted in the
+container are kept on the host. Use --pull to copy the whole workspace back once.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		if syncPullFlag {
+			if err := core.PullWorkspace(envName); err != nil {
+				return fmt.Errorf("error pulling workspace: %w", err)
+			}
+			fmt.Printf("Pulled the workspace of %s\n", envName)
+			return nil
+		}
+
+		if syncOnceFlag {
+			if err := core.PushWorkspace(envName, nil); err != nil {
+				return fmt.Errorf("error pushing workspace: %w", err)
+			}
+			fmt.Printf("Pushed the workspace of %s\n", envName)
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		fmt.Printf("Syncing the workspace of %s, press Ctrl-C to stop\n", envName)
+		err := core.SyncBox(ctx, envName, core.WatchOptions{
+			Interval: 500 * time.Millisecond,
+			Debounce: 200 * time.Millisecond,
+		}, func(changed []string, err error) {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, errorMessage(err))
+				return
+			}
+			fmt.Printf("Pushed %d changed files\n", len(changed))
+		}, func(changed []string, err error) {
+			if err != nil {
+				fmt.Fprintln(os.Stderr, errorMessage(err))
+				return
+			}
+			fmt.Printf("Pulled %d changed files\n", len(changed))
+		})
+		if err != nil {
+			return fmt.Errorf("error syncing workspace: %w", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	syncCmd.Flags().BoolVar(&syncPullFlag, "pull", false, "Copy the workspace from the container back to the host")
+	syncCmd.Flags().BoolVar(&syncOnceFlag, "once", false, "Push the workspace once instead of watching for changes")
+}
@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// noHeaderFlag is shared by the commands that print tables
+var noHeaderFlag bool
+
+// addTableFlags adds the flags for commands that print a table
+func addTableFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&noHeaderFlag, "no-header", false, "Don't print the header row")
+}
+
+const (
+	colorReset  = "\033[0m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorCyan   = "\033[36m"
+	colorBold   = "\033[1m"
+)
+
+// colorEnabled reports whether output is colored: only on a terminal, and
+// never when NO_COLOR is set (https://no-color.org)
+func colorEnabled() bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd()))
+}
+
+// colorize wraps s in color when output is colored
+func colorize(color string, s string) string {
+	if color == "" || !colorEnabled() {
+		return s
+	}
+	return color + s + colorReset
+}
+
+// stateColor returns the color for a box or container state
+func stateColor(state string) string {
+	switch core.BoxState(state) {
+	case core.BoxStateRunning:
+		return colorGreen
+	case core.BoxStatePaused, core.BoxStateRestarting, core.BoxStateCreated:
+		return colorCyan
+	case core.BoxStateStopped, core.BoxStateRemoving, core.BoxStateDoesNotExist:
+		return colorYellow
+	case core.BoxStateDead, "error":
+		return colorRed
+	}
+	// docker's name for a stopped container
+	if state == "exited" {
+		return colorYellow
+	}
+	return ""
+}
+
+// table prints rows as aligned columns
+type table struct {
+	headers []string
+	rows    [][]string
+	// colors maps a column to the function picking each cell's color
+	colors map[int]func(string) string
+	color  bool
+}
+
+func newTable(headers ...string) *table {
+	return &table{headers: headers, colors: map[int]func(string) string{}, color: colorEnabled()}
+}
+
+// addRow adds a row, with a cell per header. Cells past the last header are
+// printed unaligned.
+func (t *table) addRow(cells ...string) {
+	t.rows = append(t.rows, cells)
+}
+
+// colorColumn colors the cells of a column with the color fn returns for them
+func (t *table) colorColumn(column int, fn func(string) string) {
+	t.colors[column] = fn
+}
+
+func (t *table) print(w io.Writer) {
+	widths := make([]int, len(t.headers))
+	measure := func(row []string) {
+		for i, cell := range row {
+			if i < len(widths) {
+				widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+			}
+		}
+	}
+	if !noHeaderFlag {
+		measure(t.headers)
+	}
+	for _, row := range t.rows {
+		measure(row)
+	}
+
+	if !noHeaderFlag {
+		t.printRow(w, t.headers, widths, func(int, string) string { return colorBold })
+	}
+	for _, row := range t.rows {
+		t.printRow(w, row, widths, func(column int, cell string) string {
+			if fn, ok := t.colors[column]; ok {
+				return fn(cell)
+			}
+			return ""
+		})
+	}
+}
+
+// printRow pads each cell before coloring it, so escape codes don't count
+// towards the column width
+func (t *table) printRow(w io.Writer, row []string, widths []int, color func(int, string) string) {
+	var line strings.Builder
+	for i, cell := range row {
+		if i > 0 {
+			line.WriteString("   ")
+		}
+		c := ""
+		if t.color {
+			c = color(i, cell)
+		}
+		if c != "" {
+			line.WriteString(c + cell + colorReset)
+		} else {
+			line.WriteString(cell)
+		}
+		// the last column isn't padded, to avoid trailing spaces
+		if i < len(row)-1 && i < len(widths) {
+			line.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		}
+	}
+	fmt.Fprintln(w, line.String())
+}
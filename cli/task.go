@@ -0,0 +1,37 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var taskCmd = &cobra.Command{
+	Use:   "task [name] [task] [args...]",
+	Short: "Run a named task in a dev environment",
+	Long: `Run a task defined in the box config's tasks or the devcontainer's
+customizations.tape.tasks. Without a task, list the environment's tasks.
+Arguments after the task are passed to its command.
+Example: tape task myenv test -- -run TestFoo`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+
+		if len(args) == 1 {
+			tasks, err := core.BoxTasks(envName)
+			if err != nil {
+				return fmt.Errorf("error listing tasks: %w", err)
+			}
+			for _, name := range core.TaskNames(tasks) {
+				fmt.Printf("%s\t%s\n", name, tasks[name])
+			}
+			return nil
+		}
+
+		if err := core.RunTask(envName, args[1], args[2:]); err != nil {
+			return fmt.Errorf("error running task %s: %w", args[1], err)
+		}
+		return nil
+	},
+}
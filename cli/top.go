@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	topWatchFlag    bool
+	topIntervalFlag time.Duration
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top [name] [ps options]",
+	Short: "Shows the processes running in a dev environment",
+	Long: `List the processes running inside the environment's container, like docker top.
+The ps options default to aux, which includes each process's CPU and memory usage.
+Use --watch to refresh the list until interrupted. Flags go before the name, everything after it
+is passed to ps, e.g. tape top --watch app -eo pid,pcpu,args.`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if topIntervalFlag <= 0 {
+			return usageErrorf("Error: --interval must be positive")
+		}
+		envName := args[0]
+		psArgs := args[1:]
+		if len(psArgs) == 0 {
+			psArgs = []string{"aux"}
+		}
+
+		if !topWatchFlag {
+			processes, err := core.BoxProcesses(envName, psArgs)
+			if err != nil {
+				return fmt.Errorf("error listing processes of %s: %w", envName, err)
+			}
+			printProcesses(processes)
+			return nil
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		ticker := time.NewTicker(topIntervalFlag)
+		defer ticker.Stop()
+		for {
+			processes, err := core.BoxProcesses(envName, psArgs)
+			if err != nil {
+				return fmt.Errorf("error listing processes of %s: %w", envName, err)
+			}
+			// move the cursor home and clear the screen, like tape stats
+			fmt.Print("\033[H\033[2J")
+			printProcesses(processes)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+		}
+	},
+}
+
+func printProcesses(processes *container.Processes) {
+	t := newTable(processes.Titles...)
+	for _, process := range processes.Processes {
+		t.addRow(process...)
+	}
+	t.print(os.Stdout)
+}
+
+func init() {
+	// ps options like -ef aren't tape's flags
+	topCmd.Flags().SetInterspersed(false)
+	addTableFlags(topCmd)
+	topCmd.Flags().BoolVarP(&topWatchFlag, "watch", "w", false, "Refresh the process list until interrupted")
+	topCmd.Flags().DurationVar(&topIntervalFlag, "interval", 2*time.Second, "How often --watch refreshes the list")
+}
@@ -1,72 +1,223 @@
 package cli
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
 
 	"github.com/mikeocool/tape/core"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var (
-	rebuildFlag bool
+	rebuildFlag    bool
+	recreateFlag   bool
+	noRecreateFlag bool
+	upDryRunFlag   bool
+	configNameFlag string
+	untrustedFlag  bool
+	trustFlag      bool
+
+	helpDevcontainerFlag bool
 )
 
 var upCmd = &cobra.Command{
-	Use:   "up [name]",
+	Use:   "up [name|@group]",
 	Short: "Starts a dev environment",
-	Args:  cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Starts a dev environment, creating its container if there is none. A stopped
+container whose config changed since it was created is recreated, use
+--recreate or --no-recreate to override that.
 
-		globalConfig, err := core.LoadGlobalConfig()
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+Unless the box sets config, its devcontainer config is the first of
+.devcontainer/devcontainer.json, .devcontainer.json and
+.devcontainer/<name>/devcontainer.json in the workspace. When there are several
+of the last kind, up asks which one to use, or takes --config-name <name>, and
+later commands use the one the container was created from. config-name: in the
+box's config chooses one for good.
 
-		envName := args[0]
-		fmt.Println("Starting box", envName)
+--untrusted opens a third-party repo in a sandbox, as untrusted: true in the
+box's config does. Its initializeCommand, which runs on the host, is skipped,
+as are the lifecycle commands in untrusted.unsafe-commands of the global
+config. Neither the docker socket nor host paths outside the workspace are
+mounted, flags like --privileged and --cap-add are refused, and the container
+runs on an internal network where an egress proxy only lets it reach
+untrusted.allowed-hosts, by default the common package registries and GitHub.
+Compose configs can't be sandboxed and are refused.
 
-		// Load the configuration
-		config, err := core.LoadBoxConfig(envName)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
-		}
+The first time a box would run commands on the host, its hooks or its
+devcontainer config's initializeCommand, mount the docker socket, or start
+compose services that are privileged or mount host paths outside the
+workspace, up asks whether the workspace is trusted, and remembers the answer. --trust starts it without asking, e.g. in
+automation.
+
+Flags tape doesn't know are passed to devcontainer up as they are, so new
+options of the devcontainer CLI work before tape supports them. Give boolean
+ones after the environment name or as --flag=true. --help-devcontainer lists
+them.
+
+@group starts every environment of a group from the global config, each after
+the environments in its depends-on, e.g.
+
+groups:
+  backend: [db, api]
 
-		// Create additional arguments if rebuild flag is set
-		additionalArgs := []string{}
-		if rebuildFlag {
-			additionalArgs = append(additionalArgs,
-				"--build-no-cache",
-				"--remove-existing-container")
+--dry-run prints the plan instead: the images to pull or build, the devcontainer CLI command,
+the mounts, env and lifecycle commands. Docker isn't contacted, so the plan is for creating the
+container, and it fails when the environment violates a policy.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if helpDevcontainerFlag {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if helpDevcontainerFlag {
+			return core.DevcontainerHelp("up")
 		}
 
-		if globalConfig.DotfilesRepository != "" {
-			additionalArgs = append(additionalArgs,
-				"--dotfiles-repository", globalConfig.DotfilesRepository,
-			)
+		envNames, err := resolveEnvNames(args[0])
+		if err != nil {
+			return err
 		}
 
-		// Create and execute the devcontainer command
-		devCmd := core.DevcontainerCommand{
-			BoxConfig:      *config,
-			Command:        "up",
-			AdditionalArgs: additionalArgs,
+		opts := core.UpOptions{
+			Rebuild:    rebuildFlag,
+			Recreate:   recreateFlag,
+			NoRecreate: noRecreateFlag,
+			ConfigName: configNameFlag,
+			Untrusted:  untrustedFlag,
+			Trust:      trustFlag,
+			// cobra parsed os.Args, skipping the flags it doesn't know
+			DevcontainerArgs: passthroughFlags(cmd, os.Args[1:]),
+		}
+		if upDryRunFlag {
+			for _, envName := range envNames {
+				opts.ConfigName, err = upConfigName(envName)
+				if err != nil {
+					return err
+				}
+				plan, err := core.PlanUp(envName, opts)
+				if plan != nil {
+					printUpPlan(plan)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
 		}
 
-		err = devCmd.Execute()
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				os.Exit(exitErr.ExitCode())
+		for _, envName := range envNames {
+			opts.ConfigName, err = upConfigName(envName)
+			if err != nil {
+				return err
+			}
+
+			if !trustFlag && !untrustedFlag {
+				if err := confirmWorkspaceTrust(envName, opts.ConfigName); err != nil {
+					return err
+				}
+			}
+
+			fmt.Println("Starting box", envName)
+
+			err = core.UpBox(envName, opts)
+			if err != nil {
+				return fmt.Errorf("error executing command: %w", err)
 			}
-			fmt.Printf("Error executing command: %v\n", err)
-			os.Exit(1)
 		}
+		return nil
 	},
 }
 
+// upConfigName returns the devcontainer config to start the environment
+// with: --config-name, or the one chosen at a prompt when its workspace has
+// several and none was chosen before. Without a terminal UpBox fails listing them.
+func upConfigName(envName string) (string, error) {
+	if configNameFlag != "" || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return configNameFlag, nil
+	}
+	choices, err := core.DevcontainerConfigChoices(envName)
+	if err != nil || len(choices) == 0 {
+		// UpBox reports the error
+		return "", nil
+	}
+
+	fmt.Printf("The workspace of %s has several devcontainer configs:\n", envName)
+	for i, name := range choices {
+		fmt.Printf("  %d) %s\n", i+1, name)
+	}
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		fmt.Printf("Config to use [1-%d]: ", len(choices))
+		answer, err := reader.ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if i, convErr := strconv.Atoi(answer); convErr == nil && i >= 1 && i <= len(choices) {
+			return choices[i-1], nil
+		}
+		if slices.Contains(choices, answer) {
+			return answer, nil
+		}
+		if err != nil {
+			return "", usageErrorf("Error: no config chosen, pass --config-name")
+		}
+	}
+}
+
+// confirmWorkspaceTrust asks whether the environment's workspace is trusted
+// when it would have to be to start, and records the answer. Without a
+// terminal, or once the workspace was declined, UpBox fails with the reasons.
+func confirmWorkspaceTrust(envName string, configName string) error {
+	var trustErr *core.TrustError
+	err := core.CheckWorkspaceTrust(envName, configName)
+	if !errors.As(err, &trustErr) || trustErr.Declined || !term.IsTerminal(int(os.Stdin.Fd())) {
+		// UpBox reports the error
+		return nil
+	}
+
+	fmt.Printf("The workspace %s of %s hasn't been used before. Starting it would:\n", trustErr.Workspace, envName)
+	for _, reason := range trustErr.Reasons {
+		fmt.Printf("  %s\n", reason)
+	}
+	fmt.Print("Trust the workspace? [y/N] ")
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	trusted := answer == "y" || answer == "yes"
+	if err := core.SetWorkspaceTrust(trustErr.Workspace, trusted); err != nil {
+		return fmt.Errorf("error recording workspace trust: %w", err)
+	}
+	return nil
+}
+
+// resolveEnvNames returns the environments a name refers to: the boxes of a
+// group in start order, or the environment itself
+func resolveEnvNames(name string) ([]string, error) {
+	if !core.IsGroup(name) {
+		return []string{name}, nil
+	}
+	envNames, err := core.ResolveGroup(name)
+	if err != nil {
+		return nil, fmt.Errorf("error resolving group %s: %w", name, err)
+	}
+	return envNames, nil
+}
+
 func init() {
 	upCmd.Flags().BoolVar(&rebuildFlag, "rebuild", false, "Rebuild the container with no cache and remove existing container")
+	upCmd.Flags().BoolVar(&recreateFlag, "recreate", false, "Remove the existing container and create a new one")
+	upCmd.Flags().BoolVar(&noRecreateFlag, "no-recreate", false, "Start the existing container even if its config changed")
+	upCmd.Flags().BoolVar(&upDryRunFlag, "dry-run", false, "Print what would be done without doing it")
+	upCmd.Flags().StringVar(&configNameFlag, "config-name", "", "Use the workspace's .devcontainer/<name>/devcontainer.json when it has several configs")
+	upCmd.Flags().BoolVar(&untrustedFlag, "untrusted", false, "Sandbox the environment: no initializeCommand, docker socket or unsafe lifecycle commands, and only allowed hosts on the network")
+	upCmd.Flags().BoolVar(&trustFlag, "trust", false, "Start the environment without asking whether its workspace is trusted")
+	upCmd.Flags().BoolVar(&helpDevcontainerFlag, "help-devcontainer", false, "Print the devcontainer CLI's options for up, which tape passes through")
+	upCmd.MarkFlagsMutuallyExclusive("recreate", "no-recreate")
+	upCmd.MarkFlagsMutuallyExclusive("rebuild", "no-recreate")
+	upCmd.MarkFlagsMutuallyExclusive("trust", "untrusted")
 }
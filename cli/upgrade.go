@@ -0,0 +1,144 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var (
+	upgradeAllFlag    bool
+	upgradeYesFlag    bool
+	upgradeDryRunFlag bool
+	upgradeLockFlag   bool
+)
+
+var upgradeCmd = &cobra.Command{
+	Use:   "upgrade [name...]",
+	Short: "Rebuilds environments with newer base images or features",
+	Long: `Check the registries for newer digests of the environments' base images and features,
+show what would be updated, then rebuild the environments that are outdated. The new image is
+built while the old container keeps running, and the container is only replaced once it's ready.
+
+Environments with a lock file (tape.lock, written next to the devcontainer config when a container
+is first built) are compared with their locked digests and only upgraded with --update-lock, which
+also updates the lock. Otherwise base images are compared with the local images, and features with
+the versions recorded when the container was created. Containers created before tape recorded them
+are treated as outdated.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envNames := args
+		switch {
+		case upgradeAllFlag && len(args) > 0:
+			return usageErrorf("Error: give environment names or --all, not both")
+		case upgradeAllFlag:
+			var err error
+			envNames, err = core.ListBoxConfigs()
+			if err != nil {
+				return fmt.Errorf("error listing environments: %w", err)
+			}
+		case len(args) == 0:
+			return usageErrorf("Error: give the environments to upgrade or --all")
+		}
+
+		var plans []*core.UpgradePlan
+		var failed []string
+		for _, envName := range envNames {
+			plan, err := core.PlanUpgrade(envName)
+			if container.IsContainerNotFound(err) {
+				fmt.Printf("%s: no container, tape up builds it with the latest versions\n", envName)
+				continue
+			}
+			if err != nil {
+				fmt.Printf("%s: error checking for updates: %v\n", envName, err)
+				failed = append(failed, envName)
+				continue
+			}
+			printUpgradePlan(plan)
+			if !plan.NeedsUpgrade() {
+				continue
+			}
+			if plan.Locked && !upgradeLockFlag {
+				fmt.Printf("  locked in %s, run with --update-lock to upgrade it\n", core.LockFile)
+				continue
+			}
+			plans = append(plans, plan)
+		}
+
+		if len(plans) == 0 {
+			if len(failed) > 0 {
+				return fmt.Errorf("error checking %s for updates", strings.Join(failed, ", "))
+			}
+			fmt.Println("Nothing to upgrade")
+			return nil
+		}
+		if upgradeDryRunFlag {
+			return nil
+		}
+
+		if !upgradeYesFlag {
+			if !term.IsTerminal(int(os.Stdin.Fd())) {
+				return usageErrorf("Error: pass --yes to upgrade without a prompt")
+			}
+			fmt.Printf("Upgrade %d environments? [y/N] ", len(plans))
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+				return nil
+			}
+		}
+
+		for _, plan := range plans {
+			fmt.Printf("Upgrading %s...\n", plan.EnvName)
+			if err := core.UpgradeBox(plan); err != nil {
+				fmt.Printf("Error upgrading %s: %v\n", plan.EnvName, err)
+				failed = append(failed, plan.EnvName)
+				continue
+			}
+			fmt.Printf("Upgraded %s\n", plan.EnvName)
+		}
+		if len(failed) > 0 {
+			return fmt.Errorf("error upgrading %s", strings.Join(failed, ", "))
+		}
+		return nil
+	},
+}
+
+// printUpgradePlan prints what upgrading an environment would update
+func printUpgradePlan(plan *core.UpgradePlan) {
+	if !plan.NeedsUpgrade() {
+		fmt.Printf("%s: up to date\n", plan.EnvName)
+		return
+	}
+	fmt.Printf("%s:\n", plan.EnvName)
+	for _, update := range plan.Images {
+		fmt.Printf("  image %s: %s -> %s\n", update.Image, shortDigest(update.Current), shortDigest(update.Latest))
+	}
+	for _, update := range plan.Features {
+		latest := shortDigest(update.Latest)
+		if update.Version != "" {
+			latest = fmt.Sprintf("%s (%s)", latest, update.Version)
+		}
+		fmt.Printf("  feature %s: %s -> %s\n", update.Feature, shortDigest(update.Current), latest)
+	}
+}
+
+// shortDigest shortens a digest like docker does image IDs
+func shortDigest(digest string) string {
+	if digest == "" {
+		return "unknown"
+	}
+	_, hex, _ := strings.Cut(digest, ":")
+	return shortID(hex)
+}
+
+func init() {
+	upgradeCmd.Flags().BoolVar(&upgradeAllFlag, "all", false, "Upgrade every environment")
+	upgradeCmd.Flags().BoolVarP(&upgradeYesFlag, "yes", "y", false, "Upgrade without asking for confirmation")
+	upgradeCmd.Flags().BoolVar(&upgradeDryRunFlag, "dry-run", false, "Only show what would be upgraded")
+	upgradeCmd.Flags().BoolVar(&upgradeLockFlag, "update-lock", false, "Upgrade environments with a lock file, updating the locked digests")
+}
@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var uriCmd = &cobra.Command{
+	Use:   "uri [name]",
+	Short: "Prints the editor URI for a dev environment",
+	Long: `Print the vscode-remote:// URI that opens the environment's workspace in its container,
+e.g. for code --folder-uri $(tape uri myenv). The environment is not started.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := core.LoadBoxConfig(args[0])
+		if err != nil {
+			return err
+		}
+
+		folder, err := config.ContainerWorkspaceFolder()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(core.AttachedContainerURI(config.ContainerName(), folder))
+		return nil
+	},
+}
@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchPollFlag     bool
+	watchIntervalFlag time.Duration
+	watchDebounceFlag time.Duration
+	watchIgnoreFlag   []string
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch [name] -- [cmd] [args...]",
+	Short: "Re-run a command in a dev environment when workspace files change",
+	Long: `Watch the workspace on the host and re-run the command inside the container
+whenever files change, for edit-test loops where file watching inside the
+container is slow, e.g. on macOS bind mounts. The command runs once at start.
+Use --poll where the workspace's file system doesn't report changes, e.g.
+network mounts.
+Example: tape watch myenv -- go test ./...`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := args[0]
+		command := args[1:]
+
+		config, err := core.LoadBoxConfig(envName)
+		if err != nil {
+			return err
+		}
+		if err := core.RequireRunning(envName); err != nil {
+			return err
+		}
+
+		run := func() {
+			if err := core.ExecInBox(envName, core.ExecOptions{Command: command}); err != nil {
+				fmt.Fprintln(os.Stderr, errorMessage(err))
+			}
+			fmt.Printf("Watching %s for changes...\n", config.Workspace)
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+
+		run()
+		return core.WatchWorkspace(ctx, config.Workspace, core.WatchOptions{
+			Poll:     watchPollFlag,
+			Interval: watchIntervalFlag,
+			Debounce: watchDebounceFlag,
+			Ignore:   watchIgnoreFlag,
+		}, func(changed []string) {
+			if len(changed) == 1 {
+				fmt.Printf("%s changed\n", changed[0])
+			} else {
+				fmt.Printf("%d files changed\n", len(changed))
+			}
+			run()
+		})
+	},
+}
+
+func init() {
+	watchCmd.Flags().BoolVar(&watchPollFlag, "poll", false, "Scan the workspace for changes instead of relying on file system events")
+	watchCmd.Flags().DurationVar(&watchIntervalFlag, "interval", 500*time.Millisecond, "How often to scan the workspace for changes with --poll")
+	watchCmd.Flags().DurationVar(&watchDebounceFlag, "debounce", 300*time.Millisecond, "How long files must be unchanged before re-running")
+	watchCmd.Flags().StringArrayVar(&watchIgnoreFlag, "ignore", core.DefaultWatchIgnore, "File or directory name pattern to ignore (repeatable)")
+}
@@ -0,0 +1,107 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"golang.org/x/term"
+)
+
+// CopyToContainer extracts a tar archive into the given directory in the container
+func (c *Client) CopyToContainer(ctx context.Context, containerID string, dir string, content io.Reader) error {
+	err := c.client.CopyToContainer(ctx, containerID, dir, content, container.CopyToContainerOptions{
+		AllowOverwriteDirWithFile: true,
+	})
+	if err != nil {
+		return fmt.Errorf("error copying to %s in container: %v", dir, err)
+	}
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of the given path in the container
+func (c *Client) CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, error) {
+	reader, _, err := c.client.CopyFromContainer(ctx, containerID, path)
+	if err != nil {
+		return nil, fmt.Errorf("error copying %s from container: %v", path, err)
+	}
+	return reader, nil
+}
+
+// AttachAndRun starts the created container attached to the terminal and
+// waits for it to exit, returning an ExitError if it fails
+func (c *Client) AttachAndRun(ctx context.Context, containerID string) error {
+	// Set up terminal raw mode to properly handle control sequences, when
+	// there is a terminal, e.g. not when running in the background
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return fmt.Errorf("unable to set terminal to raw mode: %v", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), oldState)
+	}
+
+	out, err := c.client.ContainerAttach(ctx, containerID, container.AttachOptions{
+		Stream: true,
+		Stdout: true,
+		Stderr: true,
+		Stdin:  true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to attach to container: %w", err)
+	}
+	defer out.Close()
+
+	go func() {
+		// Copy container output directly to terminal
+		// TODO test that we also get stderr -- tty mode seems to break stdcopy
+		//_, err := stdcopy.StdCopy(os.Stdout, os.Stderr, out.Reader)
+		_, err := io.Copy(os.Stdout, out.Reader)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error streaming output: %s\n", err)
+		}
+	}()
+
+	// Set up goroutine to handle terminal input (if needed)
+	go func() {
+		if _, err := io.Copy(out.Conn, os.Stdin); err != nil {
+			fmt.Fprintf(os.Stderr, "Error copying stdin: %s\n", err)
+		}
+		out.CloseWrite()
+	}()
+
+	// Start the container
+	if err := c.client.ContainerStart(ctx, containerID, container.StartOptions{}); err != nil {
+		return fmt.Errorf("error starting container: %v", err)
+	}
+
+	// TODO this is probably not strcitly necessary, or can at least fail silently
+	// defer func() {
+	// 	if err := cli.ContainerStop(ctx, resp.ID, container.StopOptions{}); err != nil {
+	// 		log.Printf("Warning: failed to stop container: %v", err)
+	// 	}
+	// }()
+
+	var exitCode int64
+	waitC, errC := c.client.ContainerWait(ctx, containerID, container.WaitConditionNotRunning)
+	select {
+	case err := <-errC:
+		if err != nil {
+			return fmt.Errorf("error waiting for container: %v", err)
+		}
+	case status := <-waitC:
+		// Container is not running anymore
+		exitCode = status.StatusCode
+	}
+
+	// Give a small amount of time for final I/O operations to complete
+	time.Sleep(100 * time.Millisecond)
+
+	if exitCode != 0 {
+		return &ExitError{Code: int(exitCode)}
+	}
+	return nil
+}
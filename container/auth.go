@@ -0,0 +1,186 @@
+package container
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/api/types/registry"
+)
+
+// DockerHubRegistry is the registry of image references without a registry
+const DockerHubRegistry = "docker.io"
+
+// dockerHubConfigKey is the key docker login stores Docker Hub credentials under
+const dockerHubConfigKey = "https://index.docker.io/v1/"
+
+// RegistryAuth are the credentials for a registry. IdentityToken is set
+// instead of Password by registries that use OAuth.
+type RegistryAuth struct {
+	Username      string
+	Password      string
+	IdentityToken string
+}
+
+// encode returns the auth as the docker API expects it in X-Registry-Auth
+func (a *RegistryAuth) encode(registryName string) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	return registry.EncodeAuthConfig(registry.AuthConfig{
+		Username:      a.Username,
+		Password:      a.Password,
+		IdentityToken: a.IdentityToken,
+		ServerAddress: registryName,
+	})
+}
+
+// ImageRegistry returns the registry an image reference is pulled from, e.g.
+// ghcr.io for ghcr.io/acme/app:1 and docker.io for ubuntu
+func ImageRegistry(ref string) string {
+	first, _, found := strings.Cut(ref, "/")
+	if found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return first
+	}
+	return DockerHubRegistry
+}
+
+// DockerConfigDir returns the docker CLI's config directory, DOCKER_CONFIG or ~/.docker
+func DockerConfigDir() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".docker"
+	}
+	return filepath.Join(home, ".docker")
+}
+
+// DockerConfig is the part of the docker CLI's config.json with credentials
+type DockerConfig struct {
+	Auths       map[string]DockerConfigAuth `json:"auths,omitempty"`
+	CredsStore  string                      `json:"credsStore,omitempty"`
+	CredHelpers map[string]string           `json:"credHelpers,omitempty"`
+}
+
+// DockerConfigAuth is a registry's entry in config.json, auth is the
+// base64 encoded username:password
+type DockerConfigAuth struct {
+	Auth          string `json:"auth,omitempty"`
+	IdentityToken string `json:"identitytoken,omitempty"`
+}
+
+// LoadDockerConfig reads config.json from DockerConfigDir. A missing file is
+// treated as an empty config.
+func LoadDockerConfig() (*DockerConfig, error) {
+	path := filepath.Join(DockerConfigDir(), "config.json")
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &DockerConfig{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", path, err)
+	}
+
+	var config DockerConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// Lookup returns the credentials for a registry the way the docker CLI finds
+// them: the registry's credential helper, then its entry in auths, then the
+// default credential store. It returns nil when there are none.
+func (c *DockerConfig) Lookup(registryName string) (*RegistryAuth, error) {
+	key := registryName
+	if registryName == DockerHubRegistry {
+		key = dockerHubConfigKey
+	}
+
+	if helper, ok := c.CredHelpers[key]; ok {
+		return credentialHelper(helper, key)
+	}
+
+	if entry, ok := c.Auths[key]; ok {
+		return entry.decode(key)
+	}
+
+	if c.CredsStore != "" {
+		return credentialHelper(c.CredsStore, key)
+	}
+	return nil, nil
+}
+
+func (e DockerConfigAuth) decode(key string) (*RegistryAuth, error) {
+	auth := &RegistryAuth{IdentityToken: e.IdentityToken}
+	if e.Auth == "" {
+		return auth, nil
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(e.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding credentials for %s: %v", key, err)
+	}
+	username, password, found := strings.Cut(string(decoded), ":")
+	if !found {
+		return nil, fmt.Errorf("invalid credentials for %s", key)
+	}
+	auth.Username, auth.Password = username, password
+	return auth, nil
+}
+
+// EncodeDockerConfigAuth returns auth as a config.json auths entry
+func EncodeDockerConfigAuth(auth RegistryAuth) DockerConfigAuth {
+	entry := DockerConfigAuth{IdentityToken: auth.IdentityToken}
+	if auth.Username != "" || auth.Password != "" {
+		entry.Auth = base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	}
+	return entry
+}
+
+// DockerConfigKey returns the key config.json stores a registry's credentials under
+func DockerConfigKey(registryName string) string {
+	if registryName == DockerHubRegistry {
+		return dockerHubConfigKey
+	}
+	return registryName
+}
+
+// credentialHelper gets a registry's credentials from docker-credential-<helper>.
+// Registries the helper has nothing for have no credentials.
+func credentialHelper(helper string, key string) (*RegistryAuth, error) {
+	binary := "docker-credential-" + helper
+	cmd := exec.Command(binary, "get")
+	cmd.Stdin = strings.NewReader(key)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// helpers report missing credentials on stdout, some on stderr
+		output := stdout.String() + stderr.String()
+		if strings.Contains(output, "credentials not found") {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error running %s: %v: %s", binary, err, strings.TrimSpace(output))
+	}
+
+	var creds struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &creds); err != nil {
+		return nil, fmt.Errorf("error parsing %s output: %v", binary, err)
+	}
+	// helpers store OAuth identity tokens with this username
+	if creds.Username == "<token>" {
+		return &RegistryAuth{IdentityToken: creds.Secret}, nil
+	}
+	return &RegistryAuth{Username: creds.Username, Password: creds.Secret}, nil
+}
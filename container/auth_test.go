@@ -0,0 +1,86 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestImageRegistry(t *testing.T) {
+	tests := map[string]string{
+		"ubuntu":                       "docker.io",
+		"acme/app:1":                   "docker.io",
+		"ghcr.io/acme/app:1":           "ghcr.io",
+		"localhost/app":                "localhost",
+		"registry.local:5000/app@sha1": "registry.local:5000",
+	}
+	for ref, expected := range tests {
+		if got := ImageRegistry(ref); got != expected {
+			t.Errorf("ImageRegistry(%q) = %q, want %q", ref, got, expected)
+		}
+	}
+}
+
+func TestDockerConfigLookup(t *testing.T) {
+	// a credential helper that knows one registry
+	bin := t.TempDir()
+	helper := `#!/bin/sh
+read registry
+if [ "$registry" = "private.example.com" ]; then
+  echo '{"ServerURL":"private.example.com","Username":"helper","Secret":"s3cret"}'
+else
+  echo "credentials not found in native keychain"
+  exit 1
+fi
+`
+	if err := os.WriteFile(filepath.Join(bin, "docker-credential-test"), []byte(helper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	config := &DockerConfig{
+		Auths: map[string]DockerConfigAuth{
+			"https://index.docker.io/v1/": EncodeDockerConfigAuth(RegistryAuth{Username: "hub", Password: "pw"}),
+			"ghcr.io":                     {IdentityToken: "token"},
+		},
+		CredHelpers: map[string]string{"private.example.com": "test"},
+	}
+
+	tests := []struct {
+		registry   string
+		credsStore string
+		expected   *RegistryAuth
+	}{
+		{"docker.io", "", &RegistryAuth{Username: "hub", Password: "pw"}},
+		{"ghcr.io", "", &RegistryAuth{IdentityToken: "token"}},
+		{"private.example.com", "", &RegistryAuth{Username: "helper", Password: "s3cret"}},
+		{"quay.io", "", nil},
+		{"quay.io", "test", nil},
+	}
+	for _, tt := range tests {
+		config.CredsStore = tt.credsStore
+		got, err := config.Lookup(tt.registry)
+		if err != nil {
+			t.Errorf("Lookup(%q) error = %v", tt.registry, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("Lookup(%q) = %+v, want %+v", tt.registry, got, tt.expected)
+		}
+	}
+}
+
+func TestParseImageRef(t *testing.T) {
+	tests := map[string]ImageRef{
+		"ubuntu":                      {Registry: "docker.io", Repository: "library/ubuntu", Tag: "latest"},
+		"docker.io/acme/app:1":        {Registry: "docker.io", Repository: "acme/app", Tag: "1"},
+		"ghcr.io/acme/app@sha256:abc": {Registry: "ghcr.io", Repository: "acme/app", Digest: "sha256:abc"},
+		"localhost:5000/app":          {Registry: "localhost:5000", Repository: "app", Tag: "latest"},
+	}
+	for ref, expected := range tests {
+		if got := ParseImageRef(ref); !reflect.DeepEqual(got, expected) {
+			t.Errorf("ParseImageRef(%q) = %+v, want %+v", ref, got, expected)
+		}
+	}
+}
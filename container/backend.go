@@ -0,0 +1,151 @@
+package container
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+)
+
+// Backend is the container runtime tape manages boxes with. Client
+// implements it with docker; other runtimes implement it so core works with
+// them unchanged. Containers are identified by the IDs the backend returns
+// and found by their labels, each "key" or "key=value".
+type Backend interface {
+	// Host returns where the backend's runtime runs, for messages
+	Host() string
+	// Ping checks that the runtime is reachable, returning its API version
+	Ping(ctx context.Context) (string, error)
+	// Info describes the runtime and the machine it runs on
+	Info(ctx context.Context) (*DaemonInfo, error)
+	Close() error
+
+	CreateContainer(ctx context.Context, config ContainerConfig) (*Container, error)
+	StartContainer(ctx context.Context, containerID string) error
+	StopContainer(ctx context.Context, containerID string) error
+	PauseContainer(ctx context.Context, containerID string) error
+	UnpauseContainer(ctx context.Context, containerID string) error
+	RemoveContainer(ctx context.Context, containerID string) error
+	// CommitContainer saves the container's filesystem as an image
+	CommitContainer(ctx context.Context, containerID string, reference string, labels map[string]string) (string, error)
+	InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error)
+	// FindContainers is ListContainers sorted by preference, failing with a
+	// ContainerNotFoundError when nothing matches
+	FindContainers(ctx context.Context, labels []string) ([]Container, error)
+	ListContainers(ctx context.Context, labels []string) ([]Container, error)
+
+	// ExecContainer runs a command in the container and captures its output
+	ExecContainer(ctx context.Context, containerID string, config ExecConfig) (*ExecResult, error)
+	// ExecContainerInteractive runs a command attached to the terminal and
+	// returns its exit code
+	ExecContainerInteractive(ctx context.Context, containerID string, config ExecConfig) (int, error)
+	// ExecConn runs a command with its stdin and stdout connected to the
+	// returned connection
+	ExecConn(ctx context.Context, containerID string, config ExecConfig) (net.Conn, error)
+	// AttachAndRun starts a created container attached to the terminal and
+	// waits for it to exit
+	AttachAndRun(ctx context.Context, containerID string) error
+	// CopyToContainer extracts a tar archive into dir in the container
+	CopyToContainer(ctx context.Context, containerID string, dir string, content io.Reader) error
+	// CopyFromContainer returns a tar archive of path in the container
+	CopyFromContainer(ctx context.Context, containerID string, path string) (io.ReadCloser, error)
+	ContainerLogs(ctx context.Context, containerID string, follow bool, stdout, stderr io.Writer) error
+	ContainerStats(ctx context.Context, containerID string, stream bool, fn func(Stats) error) error
+	// ContainerDiff lists the files changed in the container's writable layer
+	ContainerDiff(ctx context.Context, containerID string) ([]Change, error)
+	// ContainerTop lists the processes running in the container
+	ContainerTop(ctx context.Context, containerID string, psArgs []string) (*Processes, error)
+	// ContainerEvents calls fn with the events of the containers matching
+	// labels until ctx is cancelled or fn returns an error
+	ContainerEvents(ctx context.Context, labels []string, fn func(Event) error) error
+	// DialContainer connects to a TCP port of a running container
+	DialContainer(ctx context.Context, containerID string, port int) (net.Conn, error)
+
+	// PullImage and PushImage authenticate with auth when it isn't nil and
+	// write their progress to out
+	PullImage(ctx context.Context, reference string, auth *RegistryAuth, out io.Writer) error
+	PushImage(ctx context.Context, reference string, auth *RegistryAuth, out io.Writer) error
+	// HasImage reports whether an image exists locally
+	HasImage(ctx context.Context, reference string) (bool, error)
+	// ImageDigest returns the registry digest a local image was pulled with,
+	// "" when it wasn't pulled
+	ImageDigest(ctx context.Context, reference string) (string, error)
+	RemoveImage(ctx context.Context, reference string) error
+	CreateNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error)
+	// CreateInternalNetwork creates a network without a route off the host,
+	// its containers only reach each other
+	CreateInternalNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error)
+	// ConnectNetwork attaches a container to another network, where the
+	// other containers reach it by its aliases
+	ConnectNetwork(ctx context.Context, networkID string, containerID string, aliases []string) error
+	FindNetwork(ctx context.Context, name string) (*Network, error)
+	ListNetworks(ctx context.Context, labels []string) ([]Network, error)
+	RemoveNetwork(ctx context.Context, networkID string) error
+	ListVolumes(ctx context.Context, labels []string) ([]Volume, error)
+	RemoveVolume(ctx context.Context, name string) error
+	DiskUsage(ctx context.Context) ([]DiskUsage, error)
+}
+
+var _ Backend = (*Client)(nil)
+
+// NewBackend returns the backend for a docker host, see NewClientForHost
+func NewBackend(host string) (Backend, error) {
+	return NewClientForHost(host)
+}
+
+// ContainerDetails is what a backend knows about a single container
+type ContainerDetails struct {
+	ID         string
+	State      State
+	Created    time.Time
+	StartedAt  time.Time
+	FinishedAt time.Time
+	// ExitCode is the exit code of the container's last run
+	ExitCode int
+	// RestartPolicy is docker's restart policy for the container, e.g. on-failure
+	RestartPolicy string
+	// RestartCount is how often docker restarted the container since it was started
+	RestartCount int
+	// Execs is the number of commands running in the container with exec
+	Execs  int
+	Mounts []Mount
+	// Networks maps the networks the container is attached to to its address on them
+	Networks map[string]string
+	Ports    []PortBinding
+}
+
+// MountType is the kind of a container mount
+type MountType string
+
+const (
+	MountTypeBind   MountType = "bind"
+	MountTypeVolume MountType = "volume"
+)
+
+// Mount is a volume or host directory mounted into a container
+type Mount struct {
+	Type MountType
+	// Name is the volume's name, empty for bind mounts
+	Name        string
+	Source      string
+	Destination string
+}
+
+// PortBinding is a container port published on a host port. A port can be
+// published more than once, e.g. on IPv4 and IPv6.
+type PortBinding struct {
+	ContainerPort int
+	Protocol      string
+	HostIP        string
+	HostPort      int
+}
+
+// Event is something that happened to a container, e.g. it started or died
+type Event struct {
+	ContainerID string
+	Action      string
+	Labels      map[string]string
+	// ExitCode is the container's exit code, for die events
+	ExitCode int
+	Time     time.Time
+}
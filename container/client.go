@@ -1,13 +1,27 @@
 package container
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"github.com/docker/go-connections/nat"
 )
 
 type ContainerNotFoundError struct {
@@ -19,23 +33,73 @@ func (e *ContainerNotFoundError) Error() string {
 	return "no matching containers found"
 }
 
-// IsContainerNotFound checks if an error is a ContainerNotFoundError
+// IsContainerNotFound checks if an error is or wraps a ContainerNotFoundError
 func IsContainerNotFound(err error) bool {
-	_, ok := err.(*ContainerNotFoundError)
-	return ok
+	var notFound *ContainerNotFoundError
+	return errors.As(err, &notFound)
 }
 
 type Client struct {
 	client *client.Client
+	host   string
 }
 
 func NewClient() (*Client, error) {
-	client, err := client.NewClientWithOpts(client.FromEnv, client.WithAPIVersionNegotiation())
+	return NewClientForHost("")
+}
+
+// NewClientForHost creates a client for the given docker host, falling back to
+// the environment (DOCKER_HOST) when host is empty. ssh:// hosts are reached
+// by running docker system dial-stdio on the remote machine, like the docker CLI.
+func NewClientForHost(host string) (*Client, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+
+	opts := []client.Opt{client.FromEnv, client.WithAPIVersionNegotiation()}
+	if IsSSHHost(host) {
+		args, err := SSHCommand(host, "docker", "system", "dial-stdio")
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts,
+			// the host only has to parse, every connection goes through ssh
+			client.WithHost("http://docker.example.com"),
+			// set after the host, which would configure the default transport
+			// including proxies from the environment
+			client.WithHTTPClient(&http.Client{Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return DialCommand(context.WithoutCancel(ctx), args, os.Stderr)
+				},
+			}}),
+		)
+	} else if host != "" {
+		opts = append(opts, client.WithHost(host))
+	}
+
+	client, err := client.NewClientWithOpts(opts...)
 	if err != nil {
 		return nil, fmt.Errorf("error creating Docker client: %v", err)
 	}
 
-	return &Client{client: client}, nil
+	return &Client{client: client, host: host}, nil
+}
+
+// Host returns the docker host the client connects to
+func (c *Client) Host() string {
+	if c.host != "" {
+		return c.host
+	}
+	return c.client.DaemonHost()
+}
+
+// Ping checks that the docker daemon is reachable, returning its API version
+func (c *Client) Ping(ctx context.Context) (string, error) {
+	ping, err := c.client.Ping(ctx)
+	if err != nil {
+		return "", fmt.Errorf("error connecting to docker at %s: %w", c.Host(), wrapDockerError(err))
+	}
+	return ping.APIVersion, nil
 }
 
 func (c *Client) Close() error {
@@ -46,6 +110,8 @@ func (c *Client) CreateContainer(ctx context.Context, config ContainerConfig) (*
 	containerConfig := &container.Config{
 		Image:        config.Image,
 		Cmd:          config.Command,
+		Env:          config.Env,
+		Labels:       config.Labels,
 		Tty:          config.Interactive,
 		AttachStdout: config.Interactive,
 		AttachStderr: config.Interactive,
@@ -58,48 +124,98 @@ func (c *Client) CreateContainer(ctx context.Context, config ContainerConfig) (*
 		AutoRemove: true,
 	}
 
+	if len(config.Ports) > 0 {
+		containerConfig.ExposedPorts = nat.PortSet{}
+		hostConfig.PortBindings = nat.PortMap{}
+		for _, binding := range config.Ports {
+			port := nat.Port(fmt.Sprintf("%d/%s", binding.ContainerPort, cmp.Or(binding.Protocol, "tcp")))
+			containerConfig.ExposedPorts[port] = struct{}{}
+			hostPort := ""
+			if binding.HostPort != 0 {
+				hostPort = strconv.Itoa(binding.HostPort)
+			}
+			hostConfig.PortBindings[port] = append(hostConfig.PortBindings[port], nat.PortBinding{HostIP: binding.HostIP, HostPort: hostPort})
+		}
+	}
+
+	var networkingConfig *network.NetworkingConfig
+	if config.Network != "" {
+		networkingConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{config.Network: {}},
+		}
+	}
+
 	resp, err := c.client.ContainerCreate(
 		ctx,
 		containerConfig,
 		hostConfig,
+		networkingConfig,
 		nil,
-		nil,
-		"",
+		config.Name,
 	)
 	if err != nil {
-		return nil, fmt.Errorf("error creating container: %v", err)
+		return nil, fmt.Errorf("error creating container: %w", wrapImageError(err))
 	}
 
-	return &Container{ID: resp.ID, State: "created", client: c.client}, nil
+	return &Container{ID: resp.ID, State: StateCreated, backend: c}, nil
 }
 
+// FindContainer returns the preferred container matching the labels, see FindContainers
 func (c *Client) FindContainer(ctx context.Context, labels []string) (*Container, error) {
-	containers, err := c.listContainers(ctx, labels)
+	containers, err := c.FindContainers(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+	return &containers[0], nil
+}
+
+// FindContainers returns all containers matching the labels, except those
+// being removed, ordered by preference: running containers first, then the
+// most recently created
+func (c *Client) FindContainers(ctx context.Context, labels []string) ([]Container, error) {
+	summaries, err := c.listContainers(ctx, labels)
 	if err != nil {
-		return nil, fmt.Errorf("error listing containers: %v", err)
+		return nil, err
 	}
 
-	// Filter out containers with status "Removing"
-	var filteredContainers []container.Summary
-	for _, c := range containers {
+	var containers []Container
+	for _, summary := range summaries {
 		// Skip containers that are in the process of being removed
-		if c.Status != "Removing" {
-			filteredContainers = append(filteredContainers, c)
+		if State(summary.State) != StateRemoving {
+			containers = append(containers, c.summaryToContainer(summary))
 		}
 	}
 
-	if len(filteredContainers) == 0 {
+	if len(containers) == 0 {
 		return nil, &ContainerNotFoundError{Labels: labels}
 	}
 
-	container := c.summaryToContainer(filteredContainers[0])
-	return &container, nil
+	SortByPreference(containers)
+	return containers, nil
+}
+
+// SortByPreference orders containers with running ones first, then by most
+// recently created, breaking ties by ID so the order is deterministic
+func SortByPreference(containers []Container) {
+	slices.SortStableFunc(containers, func(a, b Container) int {
+		aRunning, bRunning := a.State == StateRunning, b.State == StateRunning
+		if aRunning != bRunning {
+			if aRunning {
+				return -1
+			}
+			return 1
+		}
+		if a.Created != b.Created {
+			return cmp.Compare(b.Created, a.Created)
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
 }
 
 func (c *Client) ListContainers(ctx context.Context, labels []string) ([]Container, error) {
 	containerSummaries, err := c.listContainers(ctx, labels)
 	if err != nil {
-		return nil, fmt.Errorf("error listing containers: %v", err)
+		return nil, err
 	}
 
 	containers := make([]Container, len(containerSummaries))
@@ -123,31 +239,194 @@ func (c *Client) listContainers(ctx context.Context, labels []string) ([]contain
 		Filters: labelFilters,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error listing containers: %v", err)
+		return nil, fmt.Errorf("error listing containers: %w", wrapDockerError(err))
 	}
 
 	return containerSummaries, nil
 }
 
+func (c *Client) StartContainer(ctx context.Context, containerID string) error {
+	return wrapDockerError(c.client.ContainerStart(ctx, containerID, container.StartOptions{}))
+}
+
 func (c *Client) StopContainer(ctx context.Context, containerID string) error {
 	timeout := int(30 * time.Second)
-	return c.client.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout})
+	return wrapDockerError(c.client.ContainerStop(ctx, containerID, container.StopOptions{Timeout: &timeout}))
+}
+
+func (c *Client) PauseContainer(ctx context.Context, containerID string) error {
+	return wrapDockerError(c.client.ContainerPause(ctx, containerID))
+}
+
+func (c *Client) UnpauseContainer(ctx context.Context, containerID string) error {
+	return wrapDockerError(c.client.ContainerUnpause(ctx, containerID))
 }
 
 func (c *Client) RemoveContainer(ctx context.Context, containerID string) error {
-	return c.client.ContainerRemove(ctx, containerID, container.RemoveOptions{RemoveVolumes: true, RemoveLinks: false, Force: true})
+	return wrapDockerError(c.client.ContainerRemove(ctx, containerID, container.RemoveOptions{RemoveVolumes: true, RemoveLinks: false, Force: true}))
+}
+
+// CommitContainer creates an image from the container's current filesystem
+func (c *Client) CommitContainer(ctx context.Context, containerID string, reference string, labels map[string]string) (string, error) {
+	changes := []string{}
+	for key, value := range labels {
+		changes = append(changes, fmt.Sprintf("LABEL %s=%q", key, value))
+	}
+
+	resp, err := c.client.ContainerCommit(ctx, containerID, container.CommitOptions{
+		Reference: reference,
+		Changes:   changes,
+		Pause:     true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error committing container: %w", wrapDockerError(err))
+	}
+
+	return resp.ID, nil
+}
+
+// PullImage pulls an image, authenticating with auth when it isn't nil, and
+// writes the progress to out
+func (c *Client) PullImage(ctx context.Context, ref string, auth *RegistryAuth, out io.Writer) error {
+	encoded, err := auth.encode(ImageRegistry(ref))
+	if err != nil {
+		return fmt.Errorf("error encoding registry credentials: %v", err)
+	}
+	reader, err := c.client.ImagePull(ctx, ref, image.PullOptions{RegistryAuth: encoded})
+	if err != nil {
+		return fmt.Errorf("error pulling %s: %w", ref, wrapImageError(err))
+	}
+	defer reader.Close()
+
+	if err := displayPullProgress(reader, ref, out); err != nil {
+		return fmt.Errorf("error pulling %s: %w", ref, err)
+	}
+	return nil
+}
+
+// HasImage reports whether an image exists locally
+func (c *Client) HasImage(ctx context.Context, ref string) (bool, error) {
+	_, err := c.client.ImageInspect(ctx, ref)
+	if errdefs.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error inspecting image %s: %w", ref, wrapDockerError(err))
+	}
+	return true, nil
+}
+
+// PushImage pushes an image, authenticating with auth when it isn't nil, and
+// writes the progress to out
+func (c *Client) PushImage(ctx context.Context, ref string, auth *RegistryAuth, out io.Writer) error {
+	encoded, err := auth.encode(ImageRegistry(ref))
+	if err != nil {
+		return fmt.Errorf("error encoding registry credentials: %v", err)
+	}
+	reader, err := c.client.ImagePush(ctx, ref, image.PushOptions{RegistryAuth: encoded})
+	if err != nil {
+		return fmt.Errorf("error pushing %s: %w", ref, wrapImageError(err))
+	}
+	defer reader.Close()
+
+	if err := jsonmessage.DisplayJSONMessagesStream(reader, out, 0, false, nil); err != nil {
+		return fmt.Errorf("error pushing %s: %v", ref, err)
+	}
+	return nil
+}
+
+// ImageDigest returns the registry digest of a local image, the one its
+// repository's manifest had when it was pulled. It returns "" for images
+// that don't exist locally or weren't pulled, e.g. because they were built.
+func (c *Client) ImageDigest(ctx context.Context, ref string) (string, error) {
+	resp, err := c.client.ImageInspect(ctx, ref)
+	if errdefs.IsNotFound(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("error inspecting image %s: %w", ref, wrapDockerError(err))
+	}
+
+	// repo digests are name@digest, with the name as familiar as ref's
+	name := ParseImageRef(ref).Name()
+	for _, repoDigest := range resp.RepoDigests {
+		repository, digest, found := strings.Cut(repoDigest, "@")
+		if found && ParseImageRef(repository).Name() == name {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
+// RemoveImage removes an image by reference or ID
+func (c *Client) RemoveImage(ctx context.Context, reference string) error {
+	_, err := c.client.ImageRemove(ctx, reference, image.RemoveOptions{PruneChildren: true})
+	return wrapImageError(err)
+}
+
+func (c *Client) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	resp, err := c.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return nil, wrapDockerError(err)
+	}
+	return inspectToDetails(resp), nil
 }
 
-func (c *Client) InspectContainer(ctx context.Context, containerID string) (container.InspectResponse, error) {
-	// TODO re-export InspectResponse type?
-	return c.client.ContainerInspect(ctx, containerID)
+// inspectToDetails converts docker's inspect response. Docker reports unset
+// times as the zero time or not at all, both are left zero.
+func inspectToDetails(resp container.InspectResponse) *ContainerDetails {
+	details := &ContainerDetails{ID: resp.ID, RestartCount: resp.RestartCount, Execs: len(resp.ExecIDs), Networks: map[string]string{}}
+	details.Created, _ = time.Parse(time.RFC3339Nano, resp.Created)
+	if resp.HostConfig != nil {
+		details.RestartPolicy = string(resp.HostConfig.RestartPolicy.Name)
+	}
+	if resp.State != nil {
+		details.State = State(resp.State.Status)
+		details.ExitCode = resp.State.ExitCode
+		details.StartedAt, _ = time.Parse(time.RFC3339Nano, resp.State.StartedAt)
+		details.FinishedAt, _ = time.Parse(time.RFC3339Nano, resp.State.FinishedAt)
+	}
+
+	for _, m := range resp.Mounts {
+		details.Mounts = append(details.Mounts, Mount{
+			Type:        MountType(m.Type),
+			Name:        m.Name,
+			Source:      m.Source,
+			Destination: m.Destination,
+		})
+	}
+
+	if resp.NetworkSettings != nil {
+		for name, endpoint := range resp.NetworkSettings.Networks {
+			if endpoint != nil && endpoint.IPAddress != "" {
+				details.Networks[name] = endpoint.IPAddress
+			}
+		}
+		for port, bindings := range resp.NetworkSettings.Ports {
+			for _, binding := range bindings {
+				hostPort, err := strconv.Atoi(binding.HostPort)
+				if err != nil {
+					continue
+				}
+				details.Ports = append(details.Ports, PortBinding{
+					ContainerPort: port.Int(),
+					Protocol:      port.Proto(),
+					HostIP:        binding.HostIP,
+					HostPort:      hostPort,
+				})
+			}
+		}
+	}
+	return details
 }
 
 func (c *Client) summaryToContainer(summary container.Summary) Container {
 	return Container{
-		ID:     summary.ID,
-		State:  summary.State,
-		client: c.client,
+		ID:      summary.ID,
+		State:   State(summary.State),
+		Labels:  summary.Labels,
+		Created: summary.Created,
+		backend: c,
 	}
 }
 
@@ -170,3 +449,23 @@ func RemoveContainer(ctx context.Context, containerID string) error {
 
 	return cli.RemoveContainer(ctx, containerID)
 }
+
+func PauseContainer(ctx context.Context, containerID string) error {
+	cli, err := NewClient()
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	return cli.PauseContainer(ctx, containerID)
+}
+
+func UnpauseContainer(ctx context.Context, containerID string) error {
+	cli, err := NewClient()
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	return cli.UnpauseContainer(ctx, containerID)
+}
@@ -0,0 +1,120 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+)
+
+func TestSortByPreference(t *testing.T) {
+	containers := []Container{
+		{ID: "old-exited", State: StateExited, Created: 100},
+		{ID: "new-exited", State: StateExited, Created: 300},
+		{ID: "old-running", State: StateRunning, Created: 100},
+		{ID: "b-tie", State: StateExited, Created: 200},
+		{ID: "a-tie", State: StateExited, Created: 200},
+		{ID: "new-running", State: StateRunning, Created: 200},
+	}
+
+	SortByPreference(containers)
+
+	var got []string
+	for _, c := range containers {
+		got = append(got, c.ID)
+	}
+	expected := []string{"new-running", "old-running", "new-exited", "a-tie", "b-tie", "old-exited"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("SortByPreference() = %v, want %v", got, expected)
+	}
+}
+
+func TestMatchLabels(t *testing.T) {
+	labels := map[string]string{"tape.cache": "go", "other": ""}
+
+	tests := []struct {
+		filters  []string
+		expected bool
+	}{
+		{nil, true},
+		{[]string{"tape.cache"}, true},
+		{[]string{"tape.cache=go"}, true},
+		{[]string{"tape.cache=node"}, false},
+		{[]string{"tape.cache", "other"}, true},
+		{[]string{"missing"}, false},
+	}
+
+	for _, tt := range tests {
+		if got := matchLabels(labels, tt.filters); got != tt.expected {
+			t.Errorf("matchLabels(%v) = %v, want %v", tt.filters, got, tt.expected)
+		}
+	}
+}
+
+func TestInspectToDetails(t *testing.T) {
+	resp := container.InspectResponse{
+		ContainerJSONBase: &container.ContainerJSONBase{
+			ID:           "abc",
+			Created:      "2024-05-01T10:00:00.5Z",
+			RestartCount: 2,
+			HostConfig:   &container.HostConfig{RestartPolicy: container.RestartPolicy{Name: container.RestartPolicyOnFailure}},
+			State: &container.State{
+				Status:     "exited",
+				ExitCode:   1,
+				StartedAt:  "2024-05-01T10:00:01Z",
+				FinishedAt: "0001-01-01T00:00:00Z",
+			},
+		},
+		Mounts: []container.MountPoint{
+			{Type: mount.TypeVolume, Name: "cache", Source: "/var/lib/docker/volumes/cache/_data", Destination: "/cache"},
+		},
+		NetworkSettings: &container.NetworkSettings{
+			NetworkSettingsBase: container.NetworkSettingsBase{
+				Ports: nat.PortMap{"8080/tcp": {{HostIP: "0.0.0.0", HostPort: "32768"}}, "9000/tcp": nil},
+			},
+			Networks: map[string]*network.EndpointSettings{
+				"bridge": {IPAddress: "172.17.0.2"},
+				"none":   {},
+			},
+		},
+	}
+
+	got := inspectToDetails(resp)
+	expected := &ContainerDetails{
+		ID:            "abc",
+		State:         StateExited,
+		Created:       time.Date(2024, 5, 1, 10, 0, 0, 500000000, time.UTC),
+		StartedAt:     time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC),
+		ExitCode:      1,
+		RestartPolicy: "on-failure",
+		RestartCount:  2,
+		Mounts: []Mount{
+			{Type: MountTypeVolume, Name: "cache", Source: "/var/lib/docker/volumes/cache/_data", Destination: "/cache"},
+		},
+		Networks: map[string]string{"bridge": "172.17.0.2"},
+		Ports:    []PortBinding{{ContainerPort: 8080, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 32768}},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("inspectToDetails() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestIsRootless(t *testing.T) {
+	tests := []struct {
+		options  []string
+		expected bool
+	}{
+		{nil, false},
+		{[]string{"name=seccomp,profile=builtin", "name=cgroupns"}, false},
+		{[]string{"name=seccomp,profile=builtin", "name=rootless", "name=cgroupns"}, true},
+	}
+	for _, tt := range tests {
+		if got := isRootless(tt.options); got != tt.expected {
+			t.Errorf("isRootless(%v) = %v, want %v", tt.options, got, tt.expected)
+		}
+	}
+}
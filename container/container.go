@@ -6,13 +6,7 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
-	"time"
-
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/client"
-	"golang.org/x/term"
+	"path"
 )
 
 type ContainerConfig struct {
@@ -20,22 +14,71 @@ type ContainerConfig struct {
 	Command     []string
 	Interactive bool
 	Binds       []string
+	// Env are KEY=value environment variables
+	Env []string
+	// Name is the container's name, docker generates one when it's empty
+	Name   string
+	Labels map[string]string
+	// Network is the network the container is attached to instead of the default
+	Network string
+	// Ports are published on the host, a HostPort of 0 picks a free port
+	Ports []PortBinding
+}
+
+// ExitError is returned when a container's command exits with a non-zero status
+type ExitError struct {
+	Code int
+}
+
+// Error implements the error interface for ExitError
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// ExitCode returns the command's exit status
+func (e *ExitError) ExitCode() int {
+	return e.Code
 }
 
+// State is the lifecycle state docker reports for a container
+type State string
+
+const (
+	StateCreated    State = "created"
+	StateRunning    State = "running"
+	StatePaused     State = "paused"
+	StateRestarting State = "restarting"
+	StateRemoving   State = "removing"
+	StateExited     State = "exited"
+	StateDead       State = "dead"
+)
+
 type Container struct {
 	ID     string
-	State  string
-	client *client.Client
+	State  State
+	Labels map[string]string
+	// Created is the container's creation time as a unix timestamp
+	Created int64
+	backend Backend
 }
 
-func (c *Container) CreateFile(ctx context.Context, path string, content []byte) error {
+func (c *Container) CreateFile(ctx context.Context, dest string, content []byte) error {
+	return c.createFile(ctx, dest, content, 0644)
+}
+
+// CreatePrivateFile is CreateFile for files only their owner can read, e.g. secrets
+func (c *Container) CreatePrivateFile(ctx context.Context, dest string, content []byte) error {
+	return c.createFile(ctx, dest, content, 0600)
+}
+
+func (c *Container) createFile(ctx context.Context, dest string, content []byte, mode int64) error {
 	var copyContent bytes.Buffer
 	tarWriter := tar.NewWriter(&copyContent)
 	defer tarWriter.Close()
 
 	header := &tar.Header{
-		Name: filepath.Base(path),
-		Mode: 0644,
+		Name: path.Base(dest),
+		Mode: mode,
 		Size: int64(len(content)),
 	}
 
@@ -52,77 +95,32 @@ func (c *Container) CreateFile(ctx context.Context, path string, content []byte)
 
 	contentReader := bytes.NewReader(copyContent.Bytes())
 
-	// Create a tar archive for copying into the container
-	err := c.client.CopyToContainer(ctx, c.ID, filepath.Dir(path), contentReader, container.CopyToContainerOptions{
-		AllowOverwriteDirWithFile: true,
-	})
-	if err != nil {
-		return fmt.Errorf("error copying config file to container: %v", err)
-	}
-	return nil
+	return c.backend.CopyToContainer(ctx, c.ID, path.Dir(dest), contentReader)
 }
 
-func (c *Container) AttachAndRun(ctx context.Context, command []string) error {
-	// Set up terminal raw mode to properly handle control sequences
-	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
-		return fmt.Errorf("unable to set terminal to raw mode: %v", err)
-	}
-	defer term.Restore(int(os.Stdin.Fd()), oldState)
-
-	out, err := c.client.ContainerAttach(ctx, c.ID, container.AttachOptions{
-		Stream: true,
-		Stdout: true,
-		Stderr: true,
-		Stdin:  true,
-	})
-	if err != nil {
-		return fmt.Errorf("failed to attach to container: %w", err)
-	}
-	defer out.Close()
-
-	go func() {
-		// Copy container output directly to terminal
-		// TODO test that we also get stderr -- tty mode seems to break stdcopy
-		//_, err := stdcopy.StdCopy(os.Stdout, os.Stderr, out.Reader)
-		_, err := io.Copy(os.Stdout, out.Reader)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error streaming output: %s\n", err)
-		}
-	}()
-
-	// Set up goroutine to handle terminal input (if needed)
-	go func() {
-		if _, err := io.Copy(out.Conn, os.Stdin); err != nil {
-			fmt.Fprintf(os.Stderr, "Error copying stdin: %s\n", err)
-		}
-		out.CloseWrite()
-	}()
-
-	// Start the container
-	if err := c.client.ContainerStart(ctx, c.ID, container.StartOptions{}); err != nil {
-		return fmt.Errorf("error starting container: %v", err)
-	}
+// CopyFrom returns a tar archive of the given path in the container
+func (c *Container) CopyFrom(ctx context.Context, path string) (io.ReadCloser, error) {
+	return c.backend.CopyFromContainer(ctx, c.ID, path)
+}
 
-	// TODO this is probably not strcitly necessary, or can at least fail silently
-	// defer func() {
-	// 	if err := cli.ContainerStop(ctx, resp.ID, container.StopOptions{}); err != nil {
-	// 		log.Printf("Warning: failed to stop container: %v", err)
-	// 	}
-	// }()
-
-	waitC, errC := c.client.ContainerWait(ctx, c.ID, container.WaitConditionNotRunning)
-	select {
-	case err := <-errC:
-		if err != nil {
-			return fmt.Errorf("error waiting for container: %v", err)
-		}
-	case <-waitC:
-		// Container is not running anymore
-	}
+// CopyTo extracts a tar archive into the given directory in the container
+func (c *Container) CopyTo(ctx context.Context, dir string, content io.Reader) error {
+	return c.backend.CopyToContainer(ctx, c.ID, dir, content)
+}
 
-	// Give a small amount of time for final I/O operations to complete
-	time.Sleep(100 * time.Millisecond)
+// AttachAndRun starts the created container attached to the terminal and
+// waits for it to exit
+func (c *Container) AttachAndRun(ctx context.Context) error {
+	return c.backend.AttachAndRun(ctx, c.ID)
+}
+
+// Exec runs a command in the container without a TTY and captures its output
+func (c *Container) Exec(ctx context.Context, config ExecConfig) (*ExecResult, error) {
+	return c.backend.ExecContainer(ctx, c.ID, config)
+}
 
-	return nil
+// ExecInteractive runs a command in the container attached to the terminal,
+// allocating a TTY when stdin is one, and returns the command's exit code
+func (c *Container) ExecInteractive(ctx context.Context, config ExecConfig) (int, error) {
+	return c.backend.ExecContainerInteractive(ctx, c.ID, config)
 }
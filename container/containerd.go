@@ -0,0 +1,746 @@
+package container
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"google.golang.org/grpc"
+)
+
+// DefaultContainerdNamespace is the containerd namespace nerdctl uses by default
+const DefaultContainerdNamespace = "default"
+
+// ContainerdClient is the backend for containerd without dockerd. It talks
+// to containerd's API for exec, pausing and events, see containerdapi.go.
+// What docker has and containerd doesn't, like networks, published ports,
+// named volumes and container logs, is left to nerdctl, which also reports
+// containers, images and networks in docker's format.
+type ContainerdClient struct {
+	binary string
+	// address is containerd's socket, nerdctl's default when empty
+	address   string
+	namespace string
+	conn      *grpc.ClientConn
+}
+
+var _ Backend = (*ContainerdClient)(nil)
+
+// NewContainerdClient returns a backend for the containerd at address, the
+// default socket when it's empty, managing containers in namespace
+func NewContainerdClient(address string, namespace string) (*ContainerdClient, error) {
+	binary, err := exec.LookPath("nerdctl")
+	if err != nil {
+		return nil, fmt.Errorf("%w: nerdctl not found on PATH, it's needed to use containerd", ErrDockerUnavailable)
+	}
+	if namespace == "" {
+		namespace = DefaultContainerdNamespace
+	}
+	conn, err := dialContainerd(address)
+	if err != nil {
+		return nil, err
+	}
+	return &ContainerdClient{binary: binary, address: address, namespace: namespace, conn: conn}, nil
+}
+
+// Host returns containerd's address and namespace
+func (c *ContainerdClient) Host() string {
+	address := c.address
+	if address == "" {
+		address = "containerd"
+	}
+	return fmt.Sprintf("%s (namespace %s)", address, c.namespace)
+}
+
+func (c *ContainerdClient) Close() error {
+	return c.conn.Close()
+}
+
+// command returns a nerdctl invocation for the client's containerd
+func (c *ContainerdClient) command(ctx context.Context, args ...string) *exec.Cmd {
+	globalArgs := []string{"--namespace", c.namespace}
+	if c.address != "" {
+		globalArgs = append(globalArgs, "--address", c.address)
+	}
+	return exec.CommandContext(ctx, c.binary, append(globalArgs, args...)...)
+}
+
+// run runs nerdctl and returns its output, failing with its error message
+func (c *ContainerdClient) run(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := c.command(ctx, args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, nerdctlError(err, stderr.String())
+	}
+	return stdout.Bytes(), nil
+}
+
+// nerdctlError turns a failed nerdctl run into an error with its message,
+// marking the ones for an unreachable containerd with ErrDockerUnavailable
+func nerdctlError(err error, stderr string) error {
+	message := strings.TrimSpace(stderr)
+	if message == "" {
+		return err
+	}
+	// nerdctl logs errors like level=fatal msg="..."
+	if _, msg, found := strings.Cut(message, "msg="); found {
+		if unquoted, err := strconv.Unquote(msg); err == nil {
+			msg = unquoted
+		}
+		message = msg
+	}
+	if strings.Contains(message, "containerd.sock") && (strings.Contains(message, "no such file") || strings.Contains(message, "connection refused") || strings.Contains(message, "permission denied")) {
+		return fmt.Errorf("%w: %s", ErrDockerUnavailable, message)
+	}
+	return errors.New(message)
+}
+
+// isNotFound reports whether nerdctl failed because an object doesn't exist
+func isNotFound(err error) bool {
+	message := strings.ToLower(err.Error())
+	return strings.Contains(message, "no such") || strings.Contains(message, "not found")
+}
+
+// Info returns what nerdctl info reports about containerd
+func (c *ContainerdClient) Info(ctx context.Context) (*DaemonInfo, error) {
+	output, err := c.run(ctx, "info", "--format", "{{json .}}")
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to containerd at %s: %w", c.Host(), err)
+	}
+	var info struct {
+		ServerVersion   string
+		OperatingSystem string
+		OSType          string
+		Architecture    string
+		KernelVersion   string
+		Driver          string
+		NCPU            int
+		MemTotal        int64
+		Containers      int
+		Images          int
+		SecurityOptions []string
+	}
+	if err := json.Unmarshal(output, &info); err != nil {
+		return nil, fmt.Errorf("error parsing nerdctl info: %v", err)
+	}
+	return &DaemonInfo{
+		ServerVersion:   info.ServerVersion,
+		OperatingSystem: info.OperatingSystem,
+		OSType:          info.OSType,
+		Architecture:    info.Architecture,
+		KernelVersion:   info.KernelVersion,
+		StorageDriver:   info.Driver,
+		CPUs:            info.NCPU,
+		Memory:          info.MemTotal,
+		Containers:      info.Containers,
+		Images:          info.Images,
+		Rootless:        isRootless(info.SecurityOptions),
+	}, nil
+}
+
+func (c *ContainerdClient) CreateContainer(ctx context.Context, config ContainerConfig) (*Container, error) {
+	output, err := c.run(ctx, createArgs(config)...)
+	if err != nil {
+		if isNotFound(err) {
+			err = fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+		return nil, fmt.Errorf("error creating container: %w", err)
+	}
+	return &Container{ID: strings.TrimSpace(string(output)), State: StateCreated, backend: c}, nil
+}
+
+// createArgs returns the nerdctl create invocation for config. Like the
+// docker client, containers are removed once they exit.
+func createArgs(config ContainerConfig) []string {
+	args := []string{"create", "--rm"}
+	if config.Name != "" {
+		args = append(args, "--name", config.Name)
+	}
+	if config.Interactive {
+		args = append(args, "--interactive", "--tty")
+	}
+	for _, key := range slices.Sorted(maps.Keys(config.Labels)) {
+		args = append(args, "--label", key+"="+config.Labels[key])
+	}
+	for _, env := range config.Env {
+		args = append(args, "--env", env)
+	}
+	for _, bind := range config.Binds {
+		args = append(args, "--volume", bind)
+	}
+	if config.Network != "" {
+		args = append(args, "--network", config.Network)
+	}
+	for _, binding := range config.Ports {
+		hostPort := ""
+		if binding.HostPort != 0 {
+			hostPort = strconv.Itoa(binding.HostPort)
+		}
+		publish := fmt.Sprintf("%s:%d/%s", hostPort, binding.ContainerPort, cmp.Or(binding.Protocol, "tcp"))
+		if binding.HostIP != "" {
+			publish = binding.HostIP + ":" + publish
+		}
+		args = append(args, "--publish", publish)
+	}
+	args = append(args, config.Image)
+	return append(args, config.Command...)
+}
+
+func (c *ContainerdClient) StartContainer(ctx context.Context, containerID string) error {
+	_, err := c.run(ctx, "start", containerID)
+	return err
+}
+
+func (c *ContainerdClient) StopContainer(ctx context.Context, containerID string) error {
+	_, err := c.run(ctx, "stop", "--time", "30", containerID)
+	return err
+}
+
+func (c *ContainerdClient) RemoveContainer(ctx context.Context, containerID string) error {
+	_, err := c.run(ctx, "rm", "--force", "--volumes", containerID)
+	return err
+}
+
+// CommitContainer creates an image from the container's current filesystem
+func (c *ContainerdClient) CommitContainer(ctx context.Context, containerID string, reference string, labels map[string]string) (string, error) {
+	args := []string{"commit", "--pause"}
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		args = append(args, "--change", fmt.Sprintf("LABEL %s=%q", key, labels[key]))
+	}
+	output, err := c.run(ctx, append(args, containerID, reference)...)
+	if err != nil {
+		return "", fmt.Errorf("error committing container: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+func (c *ContainerdClient) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	inspects, err := c.inspectContainers(ctx, []string{containerID})
+	if err != nil {
+		return nil, err
+	}
+	if len(inspects) == 0 {
+		return nil, fmt.Errorf("no such container: %s", containerID)
+	}
+	return inspectToDetails(inspects[0]), nil
+}
+
+// inspectContainers returns nerdctl's inspect of the containers, which has
+// docker's format
+func (c *ContainerdClient) inspectContainers(ctx context.Context, containerIDs []string) ([]container.InspectResponse, error) {
+	if len(containerIDs) == 0 {
+		return nil, nil
+	}
+	output, err := c.run(ctx, append([]string{"container", "inspect"}, containerIDs...)...)
+	if err != nil {
+		return nil, err
+	}
+	var inspects []container.InspectResponse
+	if err := json.Unmarshal(output, &inspects); err != nil {
+		return nil, fmt.Errorf("error parsing container inspect: %v", err)
+	}
+	return inspects, nil
+}
+
+// FindContainers returns all containers matching the labels ordered by
+// preference, see SortByPreference
+func (c *ContainerdClient) FindContainers(ctx context.Context, labels []string) ([]Container, error) {
+	containers, err := c.ListContainers(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	containers = slices.DeleteFunc(containers, func(c Container) bool {
+		return c.State == StateRemoving
+	})
+	if len(containers) == 0 {
+		return nil, &ContainerNotFoundError{Labels: labels}
+	}
+
+	SortByPreference(containers)
+	return containers, nil
+}
+
+// ListContainers returns the containers matching the labels. They're
+// inspected because nerdctl ps joins labels with commas, which label values
+// can contain.
+func (c *ContainerdClient) ListContainers(ctx context.Context, labels []string) ([]Container, error) {
+	args := []string{"ps", "--all", "--quiet", "--no-trunc"}
+	for _, label := range labels {
+		args = append(args, "--filter", "label="+label)
+	}
+	output, err := c.run(ctx, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %w", err)
+	}
+
+	inspects, err := c.inspectContainers(ctx, strings.Fields(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %w", err)
+	}
+	containers := make([]Container, 0, len(inspects))
+	for _, inspect := range inspects {
+		containers = append(containers, c.inspectToContainer(inspect))
+	}
+	return containers, nil
+}
+
+func (c *ContainerdClient) inspectToContainer(inspect container.InspectResponse) Container {
+	details := inspectToDetails(inspect)
+	result := Container{ID: details.ID, State: details.State, Created: details.Created.Unix(), backend: c}
+	if inspect.Config != nil {
+		result.Labels = inspect.Config.Labels
+	}
+	return result
+}
+
+// exitCode returns the exit code of a command that ran, and the error of
+// one that couldn't be run
+func exitCode(err error) (int, error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), nil
+	}
+	return 0, err
+}
+
+// AttachAndRun starts the created container attached to the terminal and
+// waits for it to exit, returning an ExitError if it fails
+func (c *ContainerdClient) AttachAndRun(ctx context.Context, containerID string) error {
+	cmd := c.command(ctx, "start", "--attach", containerID)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	code, err := exitCode(cmd.Run())
+	if err != nil {
+		return fmt.Errorf("error starting container: %v", err)
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// ContainerLogs writes the container's output to stdout and stderr. With
+// follow, new output is written until the container stops or ctx is cancelled.
+func (c *ContainerdClient) ContainerLogs(ctx context.Context, containerID string, follow bool, stdout, stderr io.Writer) error {
+	args := []string{"logs"}
+	if follow {
+		args = append(args, "--follow")
+	}
+	cmd := c.command(ctx, append(args, containerID)...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error reading container logs: %v", err)
+	}
+	return nil
+}
+
+// statsInterval is how often a stats stream samples, nerdctl stats can only
+// stream to a terminal
+const statsInterval = 2 * time.Second
+
+// ContainerStats calls fn with each resource usage sample for the container.
+// Without stream it reports a single sample.
+func (c *ContainerdClient) ContainerStats(ctx context.Context, containerID string, stream bool, fn func(Stats) error) error {
+	for {
+		output, err := c.run(ctx, "stats", "--no-stream", "--format", "{{json .}}", containerID)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error getting container stats: %w", err)
+		}
+		stats, err := parseNerdctlStats(output)
+		if err != nil {
+			return fmt.Errorf("error reading container stats: %v", err)
+		}
+		if err := fn(stats); err != nil {
+			return err
+		}
+		if !stream {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(statsInterval):
+		}
+	}
+}
+
+// parseNerdctlStats parses a line of nerdctl stats, which formats its numbers
+// for humans like docker stats, e.g. "12.5MiB / 7.7GiB"
+func parseNerdctlStats(output []byte) (Stats, error) {
+	var line struct {
+		CPUPerc  string
+		MemUsage string
+		NetIO    string
+		BlockIO  string
+	}
+	if err := json.Unmarshal(bytes.TrimSpace(output), &line); err != nil {
+		return Stats{}, err
+	}
+
+	var stats Stats
+	var err error
+	if stats.CPUPercent, err = strconv.ParseFloat(strings.TrimSuffix(line.CPUPerc, "%"), 64); err != nil {
+		return Stats{}, fmt.Errorf("invalid CPU usage %q", line.CPUPerc)
+	}
+	for _, pair := range []struct {
+		value       string
+		first, last *uint64
+	}{
+		{line.MemUsage, &stats.MemoryUsage, &stats.MemoryLimit},
+		{line.NetIO, &stats.NetworkRx, &stats.NetworkTx},
+		{line.BlockIO, &stats.BlockRead, &stats.BlockWrite},
+	} {
+		first, last, _ := strings.Cut(pair.value, "/")
+		if *pair.first, err = parseHumanSize(first); err != nil {
+			return Stats{}, err
+		}
+		if *pair.last, err = parseHumanSize(last); err != nil {
+			return Stats{}, err
+		}
+	}
+	return stats, nil
+}
+
+// humanSizeUnits are the units of sizes formatted for humans, decimal like
+// kB and binary like KiB
+var humanSizeUnits = map[string]float64{
+	"b":  1,
+	"kb": 1e3, "mb": 1e6, "gb": 1e9, "tb": 1e12,
+	"kib": 1 << 10, "mib": 1 << 20, "gib": 1 << 30, "tib": 1 << 40,
+}
+
+// parseHumanSize parses a size such as 1.5MiB or 12kB into bytes
+func parseHumanSize(size string) (uint64, error) {
+	size = strings.TrimSpace(size)
+	i := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i <= 0 {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	value, err := strconv.ParseFloat(size[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	unit, ok := humanSizeUnits[strings.ToLower(strings.TrimSpace(size[i:]))]
+	if !ok {
+		return 0, fmt.Errorf("invalid size %q", size)
+	}
+	return uint64(value * unit), nil
+}
+
+// ContainerDiff isn't supported, nerdctl can't list a container's changes
+func (c *ContainerdClient) ContainerDiff(ctx context.Context, containerID string) ([]Change, error) {
+	return nil, fmt.Errorf("%w: listing changes needs docker", ErrUnsupported)
+}
+
+// ContainerTop lists the processes running in the container with ps on the
+// container's host, with psArgs or -ef
+func (c *ContainerdClient) ContainerTop(ctx context.Context, containerID string, psArgs []string) (*Processes, error) {
+	output, err := c.run(ctx, append([]string{"top", containerID}, psArgs...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %w", err)
+	}
+	return parseTop(output), nil
+}
+
+// parseTop parses ps output into processes. The last column, the command,
+// can contain spaces.
+func parseTop(output []byte) *Processes {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	processes := &Processes{Titles: strings.Fields(lines[0])}
+	if len(processes.Titles) == 0 {
+		return processes
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) > len(processes.Titles) {
+			last := len(processes.Titles) - 1
+			fields = append(fields[:last], strings.Join(fields[last:], " "))
+		}
+		processes.Processes = append(processes.Processes, fields)
+	}
+	return processes
+}
+
+// DialContainer connects to a TCP port of a running container at its network
+// address, which the host can route to as containerd runs natively
+func (c *ContainerdClient) DialContainer(ctx context.Context, containerID string, port int) (net.Conn, error) {
+	details, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %w", err)
+	}
+	networks := slices.Sorted(maps.Keys(details.Networks))
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("container %s has no IP address", containerID)
+	}
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", net.JoinHostPort(details.Networks[networks[0]], strconv.Itoa(port)))
+}
+
+// withAuth runs an image command with auth in a docker config of its own,
+// where nerdctl reads credentials from, when auth isn't nil
+func (c *ContainerdClient) withAuth(ref string, auth *RegistryAuth, cmd *exec.Cmd) (func(), error) {
+	if auth == nil {
+		return func() {}, nil
+	}
+	dir, err := os.MkdirTemp("", "tape-nerdctl-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	config := DockerConfig{Auths: map[string]DockerConfigAuth{
+		DockerConfigKey(ImageRegistry(ref)): EncodeDockerConfigAuth(*auth),
+	}}
+	data, err := json.Marshal(config)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(dir, "config.json"), data, 0600)
+	}
+	if err != nil {
+		cleanup()
+		return nil, fmt.Errorf("error writing registry credentials: %v", err)
+	}
+	cmd.Env = append(os.Environ(), "DOCKER_CONFIG="+dir)
+	return cleanup, nil
+}
+
+// PullImage pulls an image, authenticating with auth when it isn't nil, and
+// writes nerdctl's progress to out
+func (c *ContainerdClient) PullImage(ctx context.Context, ref string, auth *RegistryAuth, out io.Writer) error {
+	return c.transferImage(ctx, "pull", ref, auth, out)
+}
+
+// PushImage pushes an image, authenticating with auth when it isn't nil, and
+// writes nerdctl's progress to out
+func (c *ContainerdClient) PushImage(ctx context.Context, ref string, auth *RegistryAuth, out io.Writer) error {
+	return c.transferImage(ctx, "push", ref, auth, out)
+}
+
+func (c *ContainerdClient) transferImage(ctx context.Context, command string, ref string, auth *RegistryAuth, out io.Writer) error {
+	cmd := c.command(ctx, command, ref)
+	cleanup, err := c.withAuth(ref, auth, cmd)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	var stderr bytes.Buffer
+	cmd.Stdout = out
+	cmd.Stderr = io.MultiWriter(out, &stderr)
+	if err := cmd.Run(); err != nil {
+		err = nerdctlError(err, stderr.String())
+		if isNotFound(err) {
+			err = fmt.Errorf("%w: %v", ErrImageNotFound, err)
+		}
+		return fmt.Errorf("error %sing %s: %w", command, ref, err)
+	}
+	return nil
+}
+
+// imageRepoDigests returns the repo digests of a local image, false when it
+// doesn't exist
+func (c *ContainerdClient) imageRepoDigests(ctx context.Context, ref string) ([]string, bool, error) {
+	output, err := c.run(ctx, "image", "inspect", ref)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("error inspecting image %s: %w", ref, err)
+	}
+	var images []struct {
+		RepoDigests []string
+	}
+	if err := json.Unmarshal(output, &images); err != nil {
+		return nil, false, fmt.Errorf("error parsing image inspect: %v", err)
+	}
+	if len(images) == 0 {
+		return nil, false, nil
+	}
+	return images[0].RepoDigests, true, nil
+}
+
+// HasImage reports whether an image exists locally
+func (c *ContainerdClient) HasImage(ctx context.Context, ref string) (bool, error) {
+	_, found, err := c.imageRepoDigests(ctx, ref)
+	return found, err
+}
+
+// ImageDigest returns the registry digest of a local image, "" for images
+// that don't exist locally or weren't pulled, see Client.ImageDigest
+func (c *ContainerdClient) ImageDigest(ctx context.Context, ref string) (string, error) {
+	repoDigests, _, err := c.imageRepoDigests(ctx, ref)
+	if err != nil {
+		return "", err
+	}
+	name := ParseImageRef(ref).Name()
+	for _, repoDigest := range repoDigests {
+		repository, digest, found := strings.Cut(repoDigest, "@")
+		if found && ParseImageRef(repository).Name() == name {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
+// RemoveImage removes an image by reference or ID
+func (c *ContainerdClient) RemoveImage(ctx context.Context, reference string) error {
+	_, err := c.run(ctx, "rmi", reference)
+	if err != nil && isNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrImageNotFound, err)
+	}
+	return err
+}
+
+func (c *ContainerdClient) CreateNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error) {
+	args := []string{"network", "create", "--driver", "bridge"}
+	for _, key := range slices.Sorted(maps.Keys(labels)) {
+		args = append(args, "--label", key+"="+labels[key])
+	}
+	output, err := c.run(ctx, append(args, name)...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating network: %w", err)
+	}
+	return &Network{ID: strings.TrimSpace(string(output)), Name: name, Driver: "bridge", Labels: labels}, nil
+}
+
+func (c *ContainerdClient) FindNetwork(ctx context.Context, name string) (*Network, error) {
+	networks, err := c.inspectNetworks(ctx, []string{name})
+	if err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("error inspecting network: %w", err)
+	}
+	if len(networks) == 0 {
+		return nil, &NetworkNotFoundError{Name: name}
+	}
+	return &networks[0], nil
+}
+
+func (c *ContainerdClient) ListNetworks(ctx context.Context, labels []string) ([]Network, error) {
+	output, err := c.run(ctx, "network", "ls", "--format", "{{.Name}}")
+	if err != nil {
+		return nil, fmt.Errorf("error listing networks: %w", err)
+	}
+	all, err := c.inspectNetworks(ctx, strings.Fields(string(output)))
+	if err != nil {
+		return nil, fmt.Errorf("error listing networks: %w", err)
+	}
+
+	var networks []Network
+	for _, network := range all {
+		if matchLabels(network.Labels, labels) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+func (c *ContainerdClient) inspectNetworks(ctx context.Context, names []string) ([]Network, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	output, err := c.run(ctx, append([]string{"network", "inspect"}, names...)...)
+	if err != nil {
+		return nil, err
+	}
+	var inspects []struct {
+		ID     string `json:"Id"`
+		Name   string
+		Labels map[string]string
+	}
+	if err := json.Unmarshal(output, &inspects); err != nil {
+		return nil, fmt.Errorf("error parsing network inspect: %v", err)
+	}
+	networks := make([]Network, len(inspects))
+	for i, inspect := range inspects {
+		// nerdctl only creates bridge networks
+		networks[i] = Network{ID: inspect.ID, Name: inspect.Name, Driver: "bridge", Labels: inspect.Labels}
+	}
+	return networks, nil
+}
+
+func (c *ContainerdClient) CreateInternalNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error) {
+	return nil, fmt.Errorf("%w: internal networks need docker", ErrUnsupported)
+}
+
+func (c *ContainerdClient) ConnectNetwork(ctx context.Context, networkID string, containerID string, aliases []string) error {
+	return fmt.Errorf("%w: nerdctl can't connect running containers to networks", ErrUnsupported)
+}
+
+func (c *ContainerdClient) RemoveNetwork(ctx context.Context, networkID string) error {
+	_, err := c.run(ctx, "network", "rm", networkID)
+	return err
+}
+
+// ListVolumes returns the volumes matching all labels, each "key" or "key=value"
+func (c *ContainerdClient) ListVolumes(ctx context.Context, labels []string) ([]Volume, error) {
+	output, err := c.run(ctx, "volume", "ls", "--quiet")
+	if err != nil {
+		return nil, fmt.Errorf("error listing volumes: %w", err)
+	}
+	names := strings.Fields(string(output))
+	if len(names) == 0 {
+		return nil, nil
+	}
+	output, err = c.run(ctx, append([]string{"volume", "inspect", "--size"}, names...)...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing volumes: %w", err)
+	}
+	var inspects []struct {
+		Name   string
+		Labels map[string]string
+		Size   *int64
+	}
+	if err := json.Unmarshal(output, &inspects); err != nil {
+		return nil, fmt.Errorf("error parsing volume inspect: %v", err)
+	}
+
+	var volumes []Volume
+	for _, inspect := range inspects {
+		if !matchLabels(inspect.Labels, labels) {
+			continue
+		}
+		size := int64(-1)
+		if inspect.Size != nil {
+			size = *inspect.Size
+		}
+		volumes = append(volumes, Volume{Name: inspect.Name, Labels: inspect.Labels, Size: size})
+	}
+	return volumes, nil
+}
+
+// RemoveVolume removes a volume, which fails while a container uses it
+func (c *ContainerdClient) RemoveVolume(ctx context.Context, name string) error {
+	if _, err := c.run(ctx, "volume", "rm", name); err != nil {
+		return fmt.Errorf("error removing volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// DiskUsage isn't supported, nerdctl doesn't report containers' disk usage
+func (c *ContainerdClient) DiskUsage(ctx context.Context) ([]DiskUsage, error) {
+	return nil, fmt.Errorf("%w: disk usage needs docker", ErrUnsupported)
+}
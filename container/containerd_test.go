@@ -0,0 +1,173 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	"github.com/containerd/containerd/api/types"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestCreateArgs(t *testing.T) {
+	args := createArgs(ContainerConfig{
+		Image:   "alpine",
+		Command: []string{"sleep", "1"},
+		Name:    "proxy",
+		Labels:  map[string]string{"b": "2", "a": "1"},
+		Env:     []string{"X=1"},
+		Binds:   []string{"/src:/dst"},
+		Network: "tape",
+		Ports:   []PortBinding{{ContainerPort: 80, HostIP: "127.0.0.1"}, {ContainerPort: 53, HostPort: 5353, Protocol: "udp"}},
+	})
+	expected := []string{
+		"create", "--rm", "--name", "proxy",
+		"--label", "a=1", "--label", "b=2",
+		"--env", "X=1", "--volume", "/src:/dst", "--network", "tape",
+		"--publish", "127.0.0.1::80/tcp", "--publish", "5353:53/udp",
+		"alpine", "sleep", "1",
+	}
+	if !reflect.DeepEqual(args, expected) {
+		t.Errorf("createArgs() = %q, want %q", args, expected)
+	}
+}
+
+func TestParseNerdctlStats(t *testing.T) {
+	line := `{"BlockIO":"1kB / 2MB","CPUPerc":"12.50%","MemUsage":"1.5MiB / 1GiB","NetIO":"10B / 0B"}`
+	expected := Stats{
+		CPUPercent:  12.5,
+		MemoryUsage: 1572864,
+		MemoryLimit: 1 << 30,
+		NetworkRx:   10,
+		BlockRead:   1000,
+		BlockWrite:  2000000,
+	}
+	got, err := parseNerdctlStats([]byte(line))
+	if err != nil {
+		t.Fatalf("parseNerdctlStats() error = %v", err)
+	}
+	if got != expected {
+		t.Errorf("parseNerdctlStats() = %+v, want %+v", got, expected)
+	}
+
+	if _, err := parseNerdctlStats([]byte(`{"CPUPerc":"1%","MemUsage":"1 parsecs / 2B"}`)); err == nil {
+		t.Error("parseNerdctlStats() with an unknown unit should fail")
+	}
+}
+
+func TestParseTop(t *testing.T) {
+	output := "UID  PID  CMD\nroot 1    sleep infinity\nroot 20   sh\n"
+	expected := &Processes{
+		Titles:    []string{"UID", "PID", "CMD"},
+		Processes: [][]string{{"root", "1", "sleep infinity"}, {"root", "20", "sh"}},
+	}
+	if got := parseTop([]byte(output)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseTop() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestContainerdEvent(t *testing.T) {
+	envelope := func(topic string, event proto.Message) *types.Envelope {
+		payload, err := anypb.New(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &types.Envelope{Topic: topic, Event: payload}
+	}
+	start := envelope("/tasks/start", &apievents.TaskStart{ContainerID: "abc", Pid: 42})
+	start.Timestamp = timestamppb.New(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name     string
+		envelope *types.Envelope
+		expected Event
+		ok       bool
+	}{
+		{
+			name:     "start",
+			envelope: start,
+			expected: Event{ContainerID: "abc", Action: "start", Time: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)},
+			ok:       true,
+		},
+		{
+			name:     "exit",
+			envelope: envelope("/tasks/exit", &apievents.TaskExit{ContainerID: "abc", ID: "abc", ExitStatus: 137}),
+			expected: Event{ContainerID: "abc", Action: "die", ExitCode: 137},
+			ok:       true,
+		},
+		{
+			name:     "exec exit",
+			envelope: envelope("/tasks/exit", &apievents.TaskExit{ContainerID: "abc", ID: "tape-exec-1", ExitStatus: 1}),
+		},
+		{
+			name:     "delete",
+			envelope: envelope("/containers/delete", &apievents.ContainerDelete{ID: "abc"}),
+			expected: Event{ContainerID: "abc", Action: "destroy"},
+			ok:       true,
+		},
+		{
+			name:     "image event",
+			envelope: envelope("/images/create", &apievents.ImageCreate{Name: "alpine"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := containerdEvent(tt.envelope)
+			if ok != tt.ok || !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("containerdEvent() = %+v, %v, want %+v, %v", got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestLookupUser(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"passwd": "root:x:0:0:root:/root:/bin/bash\nnode:x:1000:1000::/home/node:/bin/bash\n",
+		"group":  "root:x:0:\nnode:x:1000:\ndocker:x:999:node\nstaff:x:50:\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, "etc", name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		user     string
+		expected specs.User
+		home     string
+	}{
+		{user: "node", expected: specs.User{UID: 1000, GID: 1000, AdditionalGids: []uint32{999}}, home: "/home/node"},
+		{user: "0", expected: specs.User{UID: 0, GID: 0}, home: "/root"},
+		{user: "node:staff", expected: specs.User{UID: 1000, GID: 50, AdditionalGids: []uint32{999}}, home: "/home/node"},
+		{user: "2000:2000", expected: specs.User{UID: 2000, GID: 2000}},
+	}
+	for _, tt := range tests {
+		user, home, err := lookupUser(root, tt.user)
+		if err != nil || !reflect.DeepEqual(user, tt.expected) || home != tt.home {
+			t.Errorf("lookupUser(%q) = %+v, %q, %v, want %+v, %q", tt.user, user, home, err, tt.expected, tt.home)
+		}
+	}
+
+	if _, _, err := lookupUser(root, "missing"); err == nil {
+		t.Errorf("lookupUser(missing) succeeded, want an error")
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	got := mergeEnv([]string{"PATH=/usr/bin", "HOME=/root"}, []string{"HOME=/home/node", "TERM=xterm"})
+	expected := []string{"PATH=/usr/bin", "HOME=/home/node", "TERM=xterm"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("mergeEnv() = %v, want %v", got, expected)
+	}
+}
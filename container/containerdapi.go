@@ -0,0 +1,665 @@
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	apievents "github.com/containerd/containerd/api/events"
+	containersapi "github.com/containerd/containerd/api/services/containers/v1"
+	eventsapi "github.com/containerd/containerd/api/services/events/v1"
+	tasksapi "github.com/containerd/containerd/api/services/tasks/v1"
+	versionapi "github.com/containerd/containerd/api/services/version/v1"
+	"github.com/containerd/containerd/api/types"
+	"github.com/containerd/fifo"
+	specs "github.com/opencontainers/runtime-spec/specs-go"
+	"golang.org/x/term"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// What containerd's API has is used directly: processes are exec'd in a
+// container's task, tasks are paused and resumed, and events are subscribed
+// to. nerdctl is left what only it adds, see ContainerdClient.
+
+// DefaultContainerdAddress is containerd's socket when none is configured
+const DefaultContainerdAddress = "/run/containerd/containerd.sock"
+
+// containerdNamespaceHeader is the gRPC metadata choosing the namespace of a
+// containerd API call
+const containerdNamespaceHeader = "containerd-namespace"
+
+// processSpecType is the type URL of an OCI process spec, which containerd
+// expects exec'd processes in
+const processSpecType = "types.containerd.io/opencontainers/runtime-spec/1/Process"
+
+// dialContainerd returns a connection to containerd's API at address. It
+// connects lazily, an unreachable containerd fails the first call.
+func dialContainerd(address string) (*grpc.ClientConn, error) {
+	if address == "" {
+		address = DefaultContainerdAddress
+	}
+	conn, err := grpc.NewClient("unix://"+address,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithAuthority("localhost"))
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to containerd at %s: %v", address, err)
+	}
+	return conn, nil
+}
+
+// apiContext makes containerd API calls with ctx in the client's namespace
+func (c *ContainerdClient) apiContext(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, containerdNamespaceHeader, c.namespace)
+}
+
+// apiError turns a failed containerd API call into an error with its
+// message, marking the ones for an unreachable containerd with
+// ErrDockerUnavailable
+func apiError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if s.Code() == codes.Unavailable {
+		return fmt.Errorf("%w: %s", ErrDockerUnavailable, s.Message())
+	}
+	return errors.New(s.Message())
+}
+
+// Ping checks that containerd's API is reachable, returning containerd's version
+func (c *ContainerdClient) Ping(ctx context.Context) (string, error) {
+	version, err := versionapi.NewVersionClient(c.conn).Version(c.apiContext(ctx), &emptypb.Empty{})
+	if err != nil {
+		return "", fmt.Errorf("error connecting to containerd at %s: %w", c.Host(), apiError(err))
+	}
+	return version.Version, nil
+}
+
+func (c *ContainerdClient) PauseContainer(ctx context.Context, containerID string) error {
+	_, err := tasksapi.NewTasksClient(c.conn).Pause(c.apiContext(ctx), &tasksapi.PauseTaskRequest{ContainerID: containerID})
+	if err != nil {
+		return fmt.Errorf("error pausing container: %w", apiError(err))
+	}
+	return nil
+}
+
+func (c *ContainerdClient) UnpauseContainer(ctx context.Context, containerID string) error {
+	_, err := tasksapi.NewTasksClient(c.conn).Resume(c.apiContext(ctx), &tasksapi.ResumeTaskRequest{ContainerID: containerID})
+	if err != nil {
+		return fmt.Errorf("error unpausing container: %w", apiError(err))
+	}
+	return nil
+}
+
+// containerLabels returns the labels of a container, which nerdctl keeps
+// on containerd's container
+func (c *ContainerdClient) containerLabels(ctx context.Context, containerID string) (map[string]string, error) {
+	resp, err := containersapi.NewContainersClient(c.conn).Get(c.apiContext(ctx), &containersapi.GetContainerRequest{ID: containerID})
+	if err != nil {
+		return nil, apiError(err)
+	}
+	return resp.Container.Labels, nil
+}
+
+// containerdTopicActions maps containerd's event topics to docker's actions
+var containerdTopicActions = map[string]string{
+	"/containers/create": "create",
+	"/containers/delete": "destroy",
+	"/tasks/start":       "start",
+	"/tasks/exit":        "die",
+	"/tasks/paused":      "pause",
+	"/tasks/resumed":     "unpause",
+}
+
+// ContainerEvents calls fn with the events of the containers matching all
+// labels until ctx is cancelled or fn returns an error. containerd's events
+// don't carry labels, they're looked up when a container is first seen.
+func (c *ContainerdClient) ContainerEvents(ctx context.Context, labels []string, fn func(Event) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := eventsapi.NewEventsClient(c.conn).Subscribe(c.apiContext(ctx), &eventsapi.SubscribeRequest{
+		Filters: []string{fmt.Sprintf("namespace==%s", c.namespace)},
+	})
+	if err != nil {
+		return fmt.Errorf("error reading container events: %w", apiError(err))
+	}
+
+	containerLabels := map[string]map[string]string{}
+	lookupLabels := func(containerID string) map[string]string {
+		if known, ok := containerLabels[containerID]; ok {
+			return known
+		}
+		labels, err := c.containerLabels(ctx, containerID)
+		if err != nil {
+			return nil
+		}
+		containerLabels[containerID] = labels
+		return labels
+	}
+
+	for {
+		envelope, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error reading container events: %w", apiError(err))
+		}
+		event, ok := containerdEvent(envelope)
+		if !ok {
+			continue
+		}
+		event.Labels = lookupLabels(event.ContainerID)
+		if event.Action == "destroy" {
+			delete(containerLabels, event.ContainerID)
+		}
+		if event.Labels == nil || !matchLabels(event.Labels, labels) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+}
+
+// containerdEvent converts a containerd event into a container's event.
+// Exits of exec'd processes and other objects' events are skipped.
+func containerdEvent(envelope *types.Envelope) (Event, bool) {
+	action, ok := containerdTopicActions[envelope.Topic]
+	if !ok || envelope.Event == nil {
+		return Event{}, false
+	}
+	payload, err := envelope.Event.UnmarshalNew()
+	if err != nil {
+		return Event{}, false
+	}
+
+	event := Event{Action: action}
+	if envelope.Timestamp != nil {
+		event.Time = envelope.Timestamp.AsTime()
+	}
+	switch p := payload.(type) {
+	case *apievents.ContainerCreate:
+		event.ContainerID = p.ID
+	case *apievents.ContainerDelete:
+		event.ContainerID = p.ID
+	case *apievents.TaskStart:
+		event.ContainerID = p.ContainerID
+	case *apievents.TaskPaused:
+		event.ContainerID = p.ContainerID
+	case *apievents.TaskResumed:
+		event.ContainerID = p.ContainerID
+	case *apievents.TaskExit:
+		// a task's exec'd processes exit too, only its own exit is the
+		// container's death
+		if p.ID != p.ContainerID {
+			return Event{}, false
+		}
+		event.ContainerID = p.ContainerID
+		event.ExitCode = int(p.ExitStatus)
+	default:
+		return Event{}, false
+	}
+	return event, event.ContainerID != ""
+}
+
+// containerdExec is a process exec'd in a container's task, with the FIFOs
+// containerd connects its stdio to
+type containerdExec struct {
+	client      *ContainerdClient
+	tasks       tasksapi.TasksClient
+	containerID string
+	id          string
+	dir         string
+	stdin       io.WriteCloser
+	stdout      io.ReadCloser
+	stderr      io.ReadCloser
+	closeOnce   sync.Once
+}
+
+// startExec execs config's command in the container's task. Without a
+// terminal the process gets separate stdout and stderr, and it only gets
+// stdin when withStdin is set. close must be called once it's done.
+func (c *ContainerdClient) startExec(ctx context.Context, containerID string, config ExecConfig, withStdin bool, terminal bool) (*containerdExec, error) {
+	tasks := tasksapi.NewTasksClient(c.conn)
+	process, err := c.execProcess(ctx, tasks, containerID, config, terminal)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := json.Marshal(process)
+	if err != nil {
+		return nil, err
+	}
+
+	id := make([]byte, 8)
+	rand.Read(id)
+	dir, err := os.MkdirTemp("", "tape-exec-")
+	if err != nil {
+		return nil, fmt.Errorf("error creating exec FIFOs: %v", err)
+	}
+	e := &containerdExec{client: c, tasks: tasks, containerID: containerID, id: "tape-exec-" + hex.EncodeToString(id), dir: dir}
+
+	request := &tasksapi.ExecProcessRequest{
+		ContainerID: containerID,
+		ExecID:      e.id,
+		Terminal:    terminal,
+		Spec:        &anypb.Any{TypeUrl: processSpecType, Value: spec},
+		Stdout:      filepath.Join(dir, "stdout"),
+	}
+	// the FIFOs are opened without blocking, containerd opens the other ends
+	if e.stdout, err = fifo.OpenFifo(ctx, request.Stdout, syscall.O_RDONLY|syscall.O_CREAT|syscall.O_NONBLOCK, 0700); err != nil {
+		e.close()
+		return nil, fmt.Errorf("error creating exec FIFOs: %v", err)
+	}
+	if !terminal {
+		request.Stderr = filepath.Join(dir, "stderr")
+		if e.stderr, err = fifo.OpenFifo(ctx, request.Stderr, syscall.O_RDONLY|syscall.O_CREAT|syscall.O_NONBLOCK, 0700); err != nil {
+			e.close()
+			return nil, fmt.Errorf("error creating exec FIFOs: %v", err)
+		}
+	}
+	if withStdin {
+		request.Stdin = filepath.Join(dir, "stdin")
+		if e.stdin, err = fifo.OpenFifo(ctx, request.Stdin, syscall.O_WRONLY|syscall.O_CREAT|syscall.O_NONBLOCK, 0700); err != nil {
+			e.close()
+			return nil, fmt.Errorf("error creating exec FIFOs: %v", err)
+		}
+	}
+
+	apiCtx := c.apiContext(ctx)
+	if _, err := tasks.Exec(apiCtx, request); err != nil {
+		e.close()
+		return nil, fmt.Errorf("error creating exec: %w", apiError(err))
+	}
+	if _, err := tasks.Start(apiCtx, &tasksapi.StartRequest{ContainerID: containerID, ExecID: e.id}); err != nil {
+		e.close()
+		return nil, fmt.Errorf("error starting exec: %w", apiError(err))
+	}
+	return e, nil
+}
+
+// execProcess returns the spec of a process running config in the
+// container: the container's own process with config's command, user,
+// working directory and variables
+func (c *ContainerdClient) execProcess(ctx context.Context, tasks tasksapi.TasksClient, containerID string, config ExecConfig, terminal bool) (*specs.Process, error) {
+	apiCtx := c.apiContext(ctx)
+	resp, err := containersapi.NewContainersClient(c.conn).Get(apiCtx, &containersapi.GetContainerRequest{ID: containerID})
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %w", apiError(err))
+	}
+	var spec specs.Spec
+	if err := json.Unmarshal(resp.Container.Spec.GetValue(), &spec); err != nil || spec.Process == nil {
+		return nil, fmt.Errorf("container %s has no process spec", containerID)
+	}
+
+	process := *spec.Process
+	process.Args = config.Command
+	process.Terminal = terminal
+	process.ConsoleSize = nil
+	if config.WorkingDir != "" {
+		process.Cwd = config.WorkingDir
+	}
+	env := process.Env
+	if config.User != "" {
+		task, err := tasks.Get(apiCtx, &tasksapi.GetRequest{ContainerID: containerID})
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting container: %w", apiError(err))
+		}
+		user, home, err := lookupUser(fmt.Sprintf("/proc/%d/root", task.Process.Pid), config.User)
+		if err != nil {
+			return nil, err
+		}
+		process.User = user
+		if home != "" {
+			env = mergeEnv(env, []string{"HOME=" + home})
+		}
+	}
+	process.Env = mergeEnv(env, config.Env)
+	return &process, nil
+}
+
+// mergeEnv returns env with the variables of overrides replacing or added to it
+func mergeEnv(env []string, overrides []string) []string {
+	merged := slices.Clone(env)
+	for _, override := range overrides {
+		name, _, _ := strings.Cut(override, "=")
+		i := slices.IndexFunc(merged, func(variable string) bool {
+			return strings.HasPrefix(variable, name+"=")
+		})
+		if i >= 0 {
+			merged[i] = override
+		} else {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
+
+// lookupUser resolves a user like docker exec --user, a name or uid with an
+// optional group name or gid, against the passwd and group files under
+// root, the container's root. Returns the user's home directory when known.
+func lookupUser(root string, user string) (specs.User, string, error) {
+	name, group, hasGroup := strings.Cut(user, ":")
+	var result specs.User
+	var home string
+
+	uid, uidErr := strconv.ParseUint(name, 10, 32)
+	found := false
+	for _, fields := range readContainerFile(root, "passwd") {
+		if len(fields) < 6 || (fields[0] != name && fields[2] != name) {
+			continue
+		}
+		entryUID, err1 := strconv.ParseUint(fields[2], 10, 32)
+		gid, err2 := strconv.ParseUint(fields[3], 10, 32)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		result.UID, result.GID, home, name = uint32(entryUID), uint32(gid), fields[5], fields[0]
+		found = true
+		break
+	}
+	if !found {
+		if uidErr != nil {
+			return specs.User{}, "", fmt.Errorf("unable to find user %s: no matching entries in passwd file", name)
+		}
+		result.UID, result.GID = uint32(uid), uint32(uid)
+	}
+
+	groups := readContainerFile(root, "group")
+	if hasGroup {
+		gid, err := strconv.ParseUint(group, 10, 32)
+		found := err == nil
+		for _, fields := range groups {
+			if len(fields) >= 3 && fields[0] == group {
+				gid, err = strconv.ParseUint(fields[2], 10, 32)
+				found = err == nil
+				break
+			}
+		}
+		if !found {
+			return specs.User{}, "", fmt.Errorf("unable to find group %s: no matching entries in group file", group)
+		}
+		result.GID = uint32(gid)
+	}
+	for _, fields := range groups {
+		if len(fields) < 4 || !slices.Contains(strings.Split(fields[3], ","), name) {
+			continue
+		}
+		if gid, err := strconv.ParseUint(fields[2], 10, 32); err == nil && uint32(gid) != result.GID {
+			result.AdditionalGids = append(result.AdditionalGids, uint32(gid))
+		}
+	}
+	return result, home, nil
+}
+
+// readContainerFile returns the colon separated fields of the lines of a
+// file in the container's /etc, nothing if it's missing. The container
+// controls its files, so /etc and the file can't be links, which would be
+// resolved on the host.
+func readContainerFile(root string, name string) [][]string {
+	for _, path := range []string{filepath.Join(root, "etc"), filepath.Join(root, "etc", name)} {
+		if info, err := os.Lstat(path); err != nil || info.Mode()&os.ModeSymlink != 0 {
+			return nil
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(root, "etc", name))
+	if err != nil {
+		return nil
+	}
+	var lines [][]string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, strings.Split(line, ":"))
+		}
+	}
+	return lines
+}
+
+// closeStdin closes the process's stdin, so it sees the end of its input
+func (e *containerdExec) closeStdin() error {
+	if e.stdin == nil {
+		return nil
+	}
+	e.stdin.Close()
+	_, err := e.tasks.CloseIO(e.client.apiContext(context.Background()), &tasksapi.CloseIORequest{ContainerID: e.containerID, ExecID: e.id, Stdin: true})
+	return apiError(err)
+}
+
+// wait waits for the process to exit and returns its exit code
+func (e *containerdExec) wait(ctx context.Context) (int, error) {
+	resp, err := e.tasks.Wait(e.client.apiContext(ctx), &tasksapi.WaitRequest{ContainerID: e.containerID, ExecID: e.id})
+	if err != nil {
+		return 0, fmt.Errorf("error waiting for exec: %w", apiError(err))
+	}
+	return int(resp.ExitStatus), nil
+}
+
+// resize sets the size of the process's terminal
+func (e *containerdExec) resize(ctx context.Context, width int, height int) {
+	e.tasks.ResizePty(e.client.apiContext(ctx), &tasksapi.ResizePtyRequest{ContainerID: e.containerID, ExecID: e.id, Width: uint32(width), Height: uint32(height)})
+}
+
+// close kills the process if it's still running, deletes it and its FIFOs
+func (e *containerdExec) close() {
+	e.closeOnce.Do(func() {
+		ctx := e.client.apiContext(context.Background())
+		e.tasks.Kill(ctx, &tasksapi.KillRequest{ContainerID: e.containerID, ExecID: e.id, Signal: uint32(syscall.SIGKILL)})
+		e.tasks.DeleteProcess(ctx, &tasksapi.DeleteProcessRequest{ContainerID: e.containerID, ExecID: e.id})
+		for _, f := range []io.Closer{e.stdin, e.stdout, e.stderr} {
+			if f != nil {
+				f.Close()
+			}
+		}
+		os.RemoveAll(e.dir)
+	})
+}
+
+// ExecContainer runs a command in the container without a TTY and captures its output
+func (c *ContainerdClient) ExecContainer(ctx context.Context, containerID string, config ExecConfig) (*ExecResult, error) {
+	e, err := c.startExec(ctx, containerID, config, false, false)
+	if err != nil {
+		return nil, err
+	}
+	defer e.close()
+
+	var stdout, stderr bytes.Buffer
+	var copying sync.WaitGroup
+	copying.Add(2)
+	go func() {
+		defer copying.Done()
+		io.Copy(&stdout, e.stdout)
+	}()
+	go func() {
+		defer copying.Done()
+		io.Copy(&stderr, e.stderr)
+	}()
+
+	exitCode, err := e.wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+	copying.Wait()
+	return &ExecResult{ExitCode: exitCode, Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}, nil
+}
+
+// ExecContainerInteractive runs a command in the container attached to the
+// terminal, allocating a TTY when stdin is one, and returns the command's exit code
+func (c *ContainerdClient) ExecContainerInteractive(ctx context.Context, containerID string, config ExecConfig) (int, error) {
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+	e, err := c.startExec(ctx, containerID, config, true, tty)
+	if err != nil {
+		return 0, err
+	}
+	defer e.close()
+
+	if tty {
+		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return 0, fmt.Errorf("unable to set terminal to raw mode: %v", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), oldState)
+
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			e.resize(ctx, width, height)
+		}
+	}
+
+	go func() {
+		io.Copy(e.stdin, os.Stdin)
+		e.closeStdin()
+	}()
+
+	stdout := config.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	var copying sync.WaitGroup
+	copying.Add(1)
+	go func() {
+		defer copying.Done()
+		io.Copy(stdout, e.stdout)
+	}()
+	if e.stderr != nil {
+		copying.Add(1)
+		go func() {
+			defer copying.Done()
+			io.Copy(os.Stderr, e.stderr)
+		}()
+	}
+
+	exitCode, err := e.wait(ctx)
+	if err != nil {
+		return 0, err
+	}
+	copying.Wait()
+	return exitCode, nil
+}
+
+// ExecConn runs a command in the container and returns a connection to its
+// stdin and stdout. Its stderr is dropped. Closing the connection ends the exec.
+func (c *ContainerdClient) ExecConn(ctx context.Context, containerID string, config ExecConfig) (net.Conn, error) {
+	e, err := c.startExec(ctx, containerID, config, true, false)
+	if err != nil {
+		return nil, err
+	}
+	go io.Copy(io.Discard, e.stderr)
+	return &containerdExecConn{exec: e}, nil
+}
+
+// containerdExecConn is an exec'd process's stdin and stdout
+type containerdExecConn struct {
+	exec *containerdExec
+}
+
+func (c *containerdExecConn) Read(p []byte) (int, error) {
+	return c.exec.stdout.Read(p)
+}
+
+func (c *containerdExecConn) Write(p []byte) (int, error) {
+	return c.exec.stdin.Write(p)
+}
+
+// CloseWrite closes the exec's stdin, so the command sees the end of its input
+func (c *containerdExecConn) CloseWrite() error {
+	return c.exec.closeStdin()
+}
+
+func (c *containerdExecConn) Close() error {
+	c.exec.close()
+	return nil
+}
+
+func (c *containerdExecConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *containerdExecConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+// deadlines aren't supported on FIFOs, like on commandConn's pipes
+func (c *containerdExecConn) SetDeadline(t time.Time) error      { return nil }
+func (c *containerdExecConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *containerdExecConn) SetWriteDeadline(t time.Time) error { return nil }
+
+// CopyToContainer extracts a tar archive into the given directory in the
+// container with the container's tar, as containerd can't copy archives
+func (c *ContainerdClient) CopyToContainer(ctx context.Context, containerID string, dir string, content io.Reader) error {
+	e, err := c.startExec(ctx, containerID, ExecConfig{Command: []string{"tar", "-x", "-C", dir}}, true, false)
+	if err != nil {
+		return fmt.Errorf("error copying to %s in container: %w", dir, err)
+	}
+	defer e.close()
+
+	go io.Copy(io.Discard, e.stdout)
+	var stderr bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(&stderr, e.stderr)
+		close(copied)
+	}()
+	if _, err := io.Copy(e.stdin, content); err != nil {
+		return fmt.Errorf("error copying to %s in container: %v", dir, err)
+	}
+	e.closeStdin()
+
+	exitCode, err := e.wait(ctx)
+	if err != nil {
+		return fmt.Errorf("error copying to %s in container: %w", dir, err)
+	}
+	<-copied
+	if exitCode != 0 {
+		return fmt.Errorf("error copying to %s in container: %s", dir, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of the given path in the container,
+// created with the container's tar
+func (c *ContainerdClient) CopyFromContainer(ctx context.Context, containerID string, p string) (io.ReadCloser, error) {
+	e, err := c.startExec(ctx, containerID, ExecConfig{Command: []string{"tar", "-c", "-C", path.Dir(p), path.Base(p)}}, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("error copying %s from container: %w", p, err)
+	}
+	go io.Copy(io.Discard, e.stderr)
+	return &execOutput{exec: e}, nil
+}
+
+// execOutput is an exec'd process's stdout, closing it ends the exec
+type execOutput struct {
+	exec *containerdExec
+}
+
+func (o *execOutput) Read(p []byte) (int, error) {
+	return o.exec.stdout.Read(p)
+}
+
+func (o *execOutput) Close() error {
+	o.exec.close()
+	return nil
+}
@@ -0,0 +1,40 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+)
+
+// DialContainer connects to a TCP port of a running container at its network
+// address, which the host can only route to where docker runs natively, e.g.
+// on Linux. Containers on a docker host reached over SSH are dialed through
+// an SSH tunnel.
+func (c *Client) DialContainer(ctx context.Context, containerID string, port int) (net.Conn, error) {
+	details, err := c.InspectContainer(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %w", err)
+	}
+
+	// any network reaches the container, pick one predictably
+	networks := slices.Sorted(maps.Keys(details.Networks))
+	if len(networks) == 0 {
+		return nil, fmt.Errorf("container %s has no IP address", containerID)
+	}
+	target := net.JoinHostPort(details.Networks[networks[0]], strconv.Itoa(port))
+
+	if IsSSHHost(c.host) {
+		args, err := SSHTunnelCommand(c.host, target)
+		if err != nil {
+			return nil, err
+		}
+		return DialCommand(ctx, args, os.Stderr)
+	}
+
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "tcp", target)
+}
@@ -0,0 +1,49 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// ChangeKind is how a file changed in a container's writable layer
+type ChangeKind string
+
+// The kinds of changes, abbreviated like docker diff
+const (
+	ChangeModified ChangeKind = "C"
+	ChangeAdded    ChangeKind = "A"
+	ChangeDeleted  ChangeKind = "D"
+)
+
+// Change is a file or directory that changed in a container since it was created
+type Change struct {
+	Kind ChangeKind
+	Path string
+}
+
+// ContainerDiff lists the files changed in the container's writable layer.
+// Changes in mounted volumes and bind mounts aren't included.
+func (c *Client) ContainerDiff(ctx context.Context, containerID string) ([]Change, error) {
+	resp, err := c.client.ContainerDiff(ctx, containerID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing changes: %w", wrapDockerError(err))
+	}
+	return changesFromResponse(resp), nil
+}
+
+func changesFromResponse(resp []container.FilesystemChange) []Change {
+	changes := make([]Change, 0, len(resp))
+	for _, change := range resp {
+		kind := ChangeModified
+		switch change.Kind {
+		case container.ChangeAdd:
+			kind = ChangeAdded
+		case container.ChangeDelete:
+			kind = ChangeDeleted
+		}
+		changes = append(changes, Change{Kind: kind, Path: change.Path})
+	}
+	return changes
+}
@@ -0,0 +1,24 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestChangesFromResponse(t *testing.T) {
+	resp := []container.FilesystemChange{
+		{Kind: container.ChangeModify, Path: "/etc"},
+		{Kind: container.ChangeAdd, Path: "/etc/apt/sources.list.d/extra.list"},
+		{Kind: container.ChangeDelete, Path: "/tmp/build.lock"},
+	}
+	expected := []Change{
+		{Kind: ChangeModified, Path: "/etc"},
+		{Kind: ChangeAdded, Path: "/etc/apt/sources.list.d/extra.list"},
+		{Kind: ChangeDeleted, Path: "/tmp/build.lock"},
+	}
+	if got := changesFromResponse(resp); !reflect.DeepEqual(got, expected) {
+		t.Errorf("changesFromResponse() = %v, want %v", got, expected)
+	}
+}
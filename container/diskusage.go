@@ -0,0 +1,85 @@
+package container
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/mount"
+)
+
+// DiskUsage is the space used by a container: its image, its writable layer
+// and the named volumes mounted into it
+type DiskUsage struct {
+	ContainerID  string
+	Labels       map[string]string
+	ImageID      string
+	ImageSize    int64
+	WritableSize int64
+	Volumes      []VolumeUsage
+}
+
+// VolumeUsage is the space used by a named volume, -1 if docker didn't report it
+type VolumeUsage struct {
+	Name string
+	Size int64
+}
+
+// Total returns the bytes used by the image, writable layer and volumes.
+// The image may be shared with other containers.
+func (d DiskUsage) Total() int64 {
+	total := d.ImageSize + d.WritableSize
+	for _, volume := range d.Volumes {
+		if volume.Size > 0 {
+			total += volume.Size
+		}
+	}
+	return total
+}
+
+// DiskUsage returns the disk usage of every container, like docker system df -v
+func (c *Client) DiskUsage(ctx context.Context) ([]DiskUsage, error) {
+	df, err := c.client.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.ContainerObject, types.ImageObject, types.VolumeObject},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting disk usage: %w", wrapDockerError(err))
+	}
+
+	imageSizes := map[string]int64{}
+	for _, image := range df.Images {
+		imageSizes[image.ID] = image.Size
+	}
+
+	volumeSizes := map[string]int64{}
+	for _, volume := range df.Volumes {
+		size := int64(-1)
+		if volume.UsageData != nil {
+			size = volume.UsageData.Size
+		}
+		volumeSizes[volume.Name] = size
+	}
+
+	usages := make([]DiskUsage, 0, len(df.Containers))
+	for _, summary := range df.Containers {
+		usage := DiskUsage{
+			ContainerID:  summary.ID,
+			Labels:       summary.Labels,
+			ImageID:      summary.ImageID,
+			ImageSize:    imageSizes[summary.ImageID],
+			WritableSize: summary.SizeRw,
+		}
+		for _, m := range summary.Mounts {
+			if m.Type != mount.TypeVolume {
+				continue
+			}
+			size, ok := volumeSizes[m.Name]
+			if !ok {
+				size = -1
+			}
+			usage.Volumes = append(usage.Volumes, VolumeUsage{Name: m.Name, Size: size})
+		}
+		usages = append(usages, usage)
+	}
+	return usages, nil
+}
@@ -0,0 +1,40 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/client"
+	"github.com/docker/docker/errdefs"
+)
+
+var (
+	// ErrDockerUnavailable is returned when the docker daemon can't be reached
+	ErrDockerUnavailable = errors.New("docker is unavailable")
+	// ErrImageNotFound is returned when a container's image doesn't exist locally
+	ErrImageNotFound = errors.New("image not found")
+	// ErrRegistryUnavailable is returned when a pull keeps failing with transient registry errors
+	ErrRegistryUnavailable = errors.New("registry is unavailable")
+	// ErrOffline is returned when an offline operation needs the registry
+	ErrOffline = errors.New("offline")
+	// ErrUnsupported is returned for operations the container runtime doesn't have
+	ErrUnsupported = errors.New("not supported by the container runtime")
+)
+
+// wrapDockerError marks errors from the docker API that callers may want to
+// handle with ErrDockerUnavailable, keeping the original message
+func wrapDockerError(err error) error {
+	if err != nil && client.IsErrConnectionFailed(err) {
+		return fmt.Errorf("%w: %v", ErrDockerUnavailable, err)
+	}
+	return err
+}
+
+// wrapImageError is wrapDockerError for calls that need an image, which
+// report a missing image as not found
+func wrapImageError(err error) error {
+	if err != nil && errdefs.IsNotFound(err) {
+		return fmt.Errorf("%w: %v", ErrImageNotFound, err)
+	}
+	return wrapDockerError(err)
+}
@@ -0,0 +1,44 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// ContainerEvents calls fn with the events of the containers matching all
+// labels, each "key" or "key=value", until ctx is cancelled or fn returns an error
+func (c *Client) ContainerEvents(ctx context.Context, labels []string, fn func(Event) error) error {
+	filterArgs := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))
+	for _, label := range labels {
+		filterArgs.Add("label", label)
+	}
+
+	messages, errs := c.client.Events(ctx, events.ListOptions{Filters: filterArgs})
+	for {
+		select {
+		case message := <-messages:
+			// the attributes hold the container's labels, and its exit code for die events
+			exitCode, _ := strconv.Atoi(message.Actor.Attributes["exitCode"])
+			err := fn(Event{
+				ContainerID: message.Actor.ID,
+				Action:      string(message.Action),
+				Labels:      message.Actor.Attributes,
+				ExitCode:    exitCode,
+				Time:        time.Unix(0, message.TimeNano),
+			})
+			if err != nil {
+				return err
+			}
+		case err := <-errs:
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error reading container events: %w", wrapDockerError(err))
+		}
+	}
+}
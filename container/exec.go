@@ -0,0 +1,128 @@
+package container
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"golang.org/x/term"
+)
+
+type ExecConfig struct {
+	Command    []string
+	User       string
+	WorkingDir string
+	Env        []string
+	// Stdout replaces the terminal's output for ExecContainerInteractive
+	Stdout io.Writer
+}
+
+type ExecResult struct {
+	ExitCode int
+	Stdout   []byte
+	Stderr   []byte
+}
+
+// ExecContainer runs a command in the container without a TTY and captures its output
+func (c *Client) ExecContainer(ctx context.Context, containerID string, config ExecConfig) (*ExecResult, error) {
+	execResp, err := c.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         config.User,
+		WorkingDir:   config.WorkingDir,
+		Env:          config.Env,
+		Cmd:          config.Command,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating exec: %v", err)
+	}
+
+	hijacked, err := c.client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error attaching to exec: %v", err)
+	}
+	defer hijacked.Close()
+
+	var stdout, stderr bytes.Buffer
+	if _, err := stdcopy.StdCopy(&stdout, &stderr, hijacked.Reader); err != nil {
+		return nil, fmt.Errorf("error reading exec output: %v", err)
+	}
+
+	inspect, err := c.client.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting exec: %v", err)
+	}
+
+	return &ExecResult{
+		ExitCode: inspect.ExitCode,
+		Stdout:   stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+	}, nil
+}
+
+// ExecContainerInteractive runs a command in the container attached to the
+// terminal, allocating a TTY when stdin is one, and returns the command's exit code
+func (c *Client) ExecContainerInteractive(ctx context.Context, containerID string, config ExecConfig) (int, error) {
+	tty := term.IsTerminal(int(os.Stdin.Fd()))
+
+	execResp, err := c.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         config.User,
+		WorkingDir:   config.WorkingDir,
+		Env:          config.Env,
+		Cmd:          config.Command,
+		Tty:          tty,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error creating exec: %v", err)
+	}
+
+	hijacked, err := c.client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{Tty: tty})
+	if err != nil {
+		return 0, fmt.Errorf("error attaching to exec: %v", err)
+	}
+	defer hijacked.Close()
+
+	if tty {
+		oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+		if err != nil {
+			return 0, fmt.Errorf("unable to set terminal to raw mode: %v", err)
+		}
+		defer term.Restore(int(os.Stdin.Fd()), oldState)
+
+		if width, height, err := term.GetSize(int(os.Stdout.Fd())); err == nil {
+			c.client.ContainerExecResize(ctx, execResp.ID, container.ResizeOptions{Width: uint(width), Height: uint(height)})
+		}
+	}
+
+	go func() {
+		io.Copy(hijacked.Conn, os.Stdin)
+		hijacked.CloseWrite()
+	}()
+
+	stdout := config.Stdout
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	// without a TTY stdout and stderr are multiplexed on one stream
+	if tty {
+		_, err = io.Copy(stdout, hijacked.Reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, os.Stderr, hijacked.Reader)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error streaming exec output: %v", err)
+	}
+
+	inspect, err := c.client.ContainerExecInspect(ctx, execResp.ID)
+	if err != nil {
+		return 0, fmt.Errorf("error inspecting exec: %v", err)
+	}
+	return inspect.ExitCode, nil
+}
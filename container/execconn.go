@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ExecConn runs a command in the container without a TTY and returns a
+// connection to its stdin and stdout, e.g. for relaying a TCP connection
+// with socat. Its stderr is dropped. Closing the connection ends the exec.
+func (c *Client) ExecConn(ctx context.Context, containerID string, config ExecConfig) (net.Conn, error) {
+	execResp, err := c.client.ContainerExecCreate(ctx, containerID, container.ExecOptions{
+		User:         config.User,
+		WorkingDir:   config.WorkingDir,
+		Env:          config.Env,
+		Cmd:          config.Command,
+		AttachStdin:  true,
+		AttachStdout: true,
+		AttachStderr: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating exec: %v", err)
+	}
+
+	hijacked, err := c.client.ContainerExecAttach(ctx, execResp.ID, container.ExecAttachOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error attaching to exec: %v", err)
+	}
+
+	// without a TTY stdout and stderr are multiplexed on one stream
+	reader, writer := io.Pipe()
+	go func() {
+		_, err := stdcopy.StdCopy(writer, io.Discard, hijacked.Reader)
+		writer.CloseWithError(err)
+	}()
+	return &execConn{Conn: hijacked.Conn, hijacked: hijacked, stdout: reader}, nil
+}
+
+// execConn is an exec's stdin and demultiplexed stdout
+type execConn struct {
+	net.Conn
+	hijacked types.HijackedResponse
+	stdout   *io.PipeReader
+}
+
+func (c *execConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+// CloseWrite closes the exec's stdin, so the command sees the end of its input
+func (c *execConn) CloseWrite() error {
+	return c.hijacked.CloseWrite()
+}
+
+func (c *execConn) Close() error {
+	c.stdout.Close()
+	c.hijacked.Close()
+	return nil
+}
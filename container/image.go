@@ -0,0 +1,47 @@
+package container
+
+import "strings"
+
+// ImageRef is a parsed image reference such as ghcr.io/acme/app:1
+type ImageRef struct {
+	Registry string
+	// Repository is the image's path in its registry, with library/ added to
+	// official Docker Hub images
+	Repository string
+	// Tag is latest unless the reference has a tag or digest
+	Tag    string
+	Digest string
+}
+
+// ParseImageRef parses an image reference the way docker does, without
+// validating it
+func ParseImageRef(ref string) ImageRef {
+	parsed := ImageRef{Registry: ImageRegistry(ref)}
+
+	name := ref
+	if before, digest, found := strings.Cut(name, "@"); found {
+		name, parsed.Digest = before, digest
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, parsed.Tag = name[:i], name[i+1:]
+	}
+	if parsed.Tag == "" && parsed.Digest == "" {
+		parsed.Tag = "latest"
+	}
+
+	if parsed.Registry == DockerHubRegistry {
+		name = strings.TrimPrefix(name, DockerHubRegistry+"/")
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+	} else {
+		name = strings.TrimPrefix(name, parsed.Registry+"/")
+	}
+	parsed.Repository = name
+	return parsed
+}
+
+// Name returns the registry and repository, e.g. docker.io/library/ubuntu
+func (r ImageRef) Name() string {
+	return r.Registry + "/" + r.Repository
+}
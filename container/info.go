@@ -0,0 +1,54 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DaemonInfo describes the docker daemon and the machine it runs on
+type DaemonInfo struct {
+	ServerVersion   string `json:"serverVersion"`
+	OperatingSystem string `json:"operatingSystem"`
+	OSType          string `json:"osType"`
+	Architecture    string `json:"architecture"`
+	KernelVersion   string `json:"kernelVersion"`
+	StorageDriver   string `json:"storageDriver"`
+	CPUs            int    `json:"cpus"`
+	Memory          int64  `json:"memory"`
+	Containers      int    `json:"containers"`
+	Images          int    `json:"images"`
+	// Rootless is set when the daemon runs as an unprivileged user, where
+	// root in containers is that user on the host
+	Rootless bool `json:"rootless"`
+}
+
+// isRootless reports whether the security options of docker or nerdctl info
+// include rootless mode
+func isRootless(securityOptions []string) bool {
+	return slices.ContainsFunc(securityOptions, func(option string) bool {
+		return slices.Contains(strings.Split(option, ","), "name=rootless")
+	})
+}
+
+// Info returns what docker info reports about the daemon
+func (c *Client) Info(ctx context.Context) (*DaemonInfo, error) {
+	info, err := c.client.Info(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error getting docker info: %w", wrapDockerError(err))
+	}
+	return &DaemonInfo{
+		ServerVersion:   info.ServerVersion,
+		OperatingSystem: info.OperatingSystem,
+		OSType:          info.OSType,
+		Architecture:    info.Architecture,
+		KernelVersion:   info.KernelVersion,
+		StorageDriver:   info.Driver,
+		CPUs:            info.NCPU,
+		Memory:          info.MemTotal,
+		Containers:      info.Containers,
+		Images:          info.Images,
+		Rootless:        isRootless(info.SecurityOptions),
+	}, nil
+}
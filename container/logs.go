@@ -0,0 +1,40 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// ContainerLogs writes the container's output to stdout and stderr. With
+// follow, new output is written until the container stops or ctx is cancelled.
+func (c *Client) ContainerLogs(ctx context.Context, containerID string, follow bool, stdout, stderr io.Writer) error {
+	inspect, err := c.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("error inspecting container: %w", wrapDockerError(err))
+	}
+
+	reader, err := c.client.ContainerLogs(ctx, containerID, container.LogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     follow,
+	})
+	if err != nil {
+		return fmt.Errorf("error reading container logs: %w", wrapDockerError(err))
+	}
+	defer reader.Close()
+
+	// containers with a tty have a single stream that isn't multiplexed
+	if inspect.Config != nil && inspect.Config.Tty {
+		_, err = io.Copy(stdout, reader)
+	} else {
+		_, err = stdcopy.StdCopy(stdout, stderr, reader)
+	}
+	if err != nil && ctx.Err() == nil {
+		return fmt.Errorf("error reading container logs: %v", err)
+	}
+	return nil
+}
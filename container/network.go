@@ -0,0 +1,113 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/network"
+)
+
+type NetworkNotFoundError struct {
+	Name string
+}
+
+// Error implements the error interface for NetworkNotFoundError
+func (e *NetworkNotFoundError) Error() string {
+	return fmt.Sprintf("network %s not found", e.Name)
+}
+
+// IsNetworkNotFound checks if an error is or wraps a NetworkNotFoundError
+func IsNetworkNotFound(err error) bool {
+	var notFound *NetworkNotFoundError
+	return errors.As(err, &notFound)
+}
+
+type Network struct {
+	ID     string
+	Name   string
+	Driver string
+	Labels map[string]string
+}
+
+func (c *Client) CreateNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error) {
+	return c.createNetwork(ctx, name, labels, false)
+}
+
+func (c *Client) CreateInternalNetwork(ctx context.Context, name string, labels map[string]string) (*Network, error) {
+	return c.createNetwork(ctx, name, labels, true)
+}
+
+func (c *Client) createNetwork(ctx context.Context, name string, labels map[string]string, internal bool) (*Network, error) {
+	resp, err := c.client.NetworkCreate(ctx, name, network.CreateOptions{
+		Driver:   "bridge",
+		Labels:   labels,
+		Internal: internal,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating network: %w", wrapDockerError(err))
+	}
+
+	return &Network{ID: resp.ID, Name: name, Driver: "bridge", Labels: labels}, nil
+}
+
+func (c *Client) ConnectNetwork(ctx context.Context, networkID string, containerID string, aliases []string) error {
+	err := c.client.NetworkConnect(ctx, networkID, containerID, &network.EndpointSettings{Aliases: aliases})
+	if err != nil {
+		return fmt.Errorf("error connecting to network: %w", wrapDockerError(err))
+	}
+	return nil
+}
+
+func (c *Client) FindNetwork(ctx context.Context, name string) (*Network, error) {
+	nameFilters := filters.NewArgs()
+	nameFilters.Add("name", name)
+
+	summaries, err := c.client.NetworkList(ctx, network.ListOptions{Filters: nameFilters})
+	if err != nil {
+		return nil, fmt.Errorf("error listing networks: %w", wrapDockerError(err))
+	}
+
+	// the name filter matches on substrings, so look for the exact name
+	for _, summary := range summaries {
+		if summary.Name == name {
+			network := summaryToNetwork(summary)
+			return &network, nil
+		}
+	}
+
+	return nil, &NetworkNotFoundError{Name: name}
+}
+
+func (c *Client) ListNetworks(ctx context.Context, labels []string) ([]Network, error) {
+	labelFilters := filters.NewArgs()
+	for _, label := range labels {
+		labelFilters.Add("label", label)
+	}
+
+	summaries, err := c.client.NetworkList(ctx, network.ListOptions{Filters: labelFilters})
+	if err != nil {
+		return nil, fmt.Errorf("error listing networks: %w", wrapDockerError(err))
+	}
+
+	networks := make([]Network, len(summaries))
+	for i, summary := range summaries {
+		networks[i] = summaryToNetwork(summary)
+	}
+
+	return networks, nil
+}
+
+func (c *Client) RemoveNetwork(ctx context.Context, networkID string) error {
+	return wrapDockerError(c.client.NetworkRemove(ctx, networkID))
+}
+
+func summaryToNetwork(summary network.Summary) Network {
+	return Network{
+		ID:     summary.ID,
+		Name:   summary.Name,
+		Driver: summary.Driver,
+		Labels: summary.Labels,
+	}
+}
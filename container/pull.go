@@ -0,0 +1,275 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/errdefs"
+	"github.com/docker/docker/pkg/jsonmessage"
+	"golang.org/x/term"
+)
+
+// RetryPolicy is how pulls that fail with a transient registry error are retried
+type RetryPolicy struct {
+	// Attempts is how often the pull is tried in total, 1 disables retries
+	Attempts int
+	// Backoff is the wait before the first retry, doubling with each one
+	Backoff time.Duration
+	// MaxBackoff caps the wait between retries, 0 leaves it uncapped
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used for pulls unless configured otherwise
+var DefaultRetryPolicy = RetryPolicy{Attempts: 3, Backoff: 2 * time.Second, MaxBackoff: 30 * time.Second}
+
+// Delay returns the wait before retrying after the given failed attempt, counting from 1
+func (p RetryPolicy) Delay(attempt int) time.Duration {
+	delay := p.Backoff
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			break
+		}
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return delay
+}
+
+// PullOptions configure Pull
+type PullOptions struct {
+	// Auth is the registry's credentials, nil pulls anonymously
+	Auth  *RegistryAuth
+	Retry RetryPolicy
+	// Offline never contacts the registry, the image has to exist locally
+	Offline bool
+	// Out receives the pull's progress, nothing is written when it's nil
+	Out io.Writer
+}
+
+// Pull pulls an image, retrying transient registry errors as options.Retry
+// says. When the registry stays unreachable the error wraps
+// ErrRegistryUnavailable. Offline, Pull only checks that the image exists
+// locally and fails with ErrOffline when it doesn't.
+func Pull(ctx context.Context, backend Backend, ref string, options PullOptions) error {
+	if options.Offline {
+		exists, err := backend.HasImage(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s isn't available locally", ErrOffline, ref)
+		}
+		return nil
+	}
+
+	out := options.Out
+	if out == nil {
+		out = io.Discard
+	}
+	attempts := max(options.Retry.Attempts, 1)
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = backend.PullImage(ctx, ref, options.Auth, out)
+		if err == nil || !IsTransientRegistryError(err) {
+			return err
+		}
+		if attempt >= attempts {
+			break
+		}
+
+		delay := options.Retry.Delay(attempt)
+		fmt.Fprintf(out, "%v, retrying in %s (attempt %d of %d)\n", err, delay, attempt+1, attempts)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return fmt.Errorf("%w: %v", ErrRegistryUnavailable, err)
+}
+
+// transientRegistryErrors are the messages of registry errors that a retry can
+// fix, the daemon passes them on as text
+var transientRegistryErrors = []string{
+	"timeout",
+	"timed out",
+	"connection reset",
+	"connection refused",
+	"no such host",
+	"temporary failure in name resolution",
+	"network is unreachable",
+	"unexpected eof",
+	"toomanyrequests",
+	"too many requests",
+	"500 internal server error",
+	"502 bad gateway",
+	"503 service unavailable",
+	"504 gateway timeout",
+}
+
+// IsTransientRegistryError reports whether a pull failed for a reason that may
+// go away on its own, like a network error or the registry being overloaded,
+// rather than e.g. a missing image or bad credentials
+func IsTransientRegistryError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	// the docker daemon itself being unreachable isn't the registry's fault
+	if errors.Is(err, ErrDockerUnavailable) || errors.Is(err, ErrImageNotFound) {
+		return false
+	}
+	if errdefs.IsUnauthorized(err) || errdefs.IsForbidden(err) || errdefs.IsInvalidParameter(err) {
+		return false
+	}
+	if errdefs.IsUnavailable(err) || errdefs.IsDeadline(err) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, pattern := range transientRegistryErrors {
+		if strings.Contains(message, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// layerProgress is the state of one layer of a pull
+type layerProgress struct {
+	downloaded int64
+	size       int64
+	done       bool
+}
+
+// pullProgress summarizes the messages docker sends during a pull by layer
+type pullProgress struct {
+	ref    string
+	layers map[string]*layerProgress
+	// order keeps the layers in the order docker announced them
+	order []string
+}
+
+func newPullProgress(ref string) *pullProgress {
+	return &pullProgress{ref: ref, layers: map[string]*layerProgress{}}
+}
+
+// update applies a message to the layer it's about, reporting whether the
+// layer finished with it. Messages about the image rather than a layer, like
+// "Pulling from library/ubuntu", are ignored.
+func (p *pullProgress) update(msg jsonmessage.JSONMessage) bool {
+	if msg.ID == "" || strings.HasPrefix(msg.Status, "Pulling from ") {
+		return false
+	}
+
+	layer, ok := p.layers[msg.ID]
+	if !ok {
+		layer = &layerProgress{}
+		p.layers[msg.ID] = layer
+		p.order = append(p.order, msg.ID)
+	}
+	if layer.done {
+		return false
+	}
+
+	switch msg.Status {
+	case "Downloading":
+		if msg.Progress != nil {
+			layer.downloaded = msg.Progress.Current
+			if msg.Progress.Total > 0 {
+				layer.size = msg.Progress.Total
+			}
+		}
+	case "Verifying Checksum", "Download complete":
+		layer.downloaded = layer.size
+	case "Pull complete", "Already exists":
+		layer.downloaded = layer.size
+		layer.done = true
+		return true
+	}
+	return false
+}
+
+// String summarizes the pull, e.g. "ubuntu:24.04: 2/5 layers, 20.1MiB/48.3MiB"
+func (p *pullProgress) String() string {
+	var done int
+	var downloaded, size int64
+	for _, layer := range p.layers {
+		if layer.done {
+			done++
+		}
+		downloaded += layer.downloaded
+		size += layer.size
+	}
+
+	summary := fmt.Sprintf("%s: %d/%d layers", p.ref, done, len(p.layers))
+	if size > 0 {
+		summary += fmt.Sprintf(", %s/%s", formatSize(downloaded), formatSize(size))
+	}
+	return summary
+}
+
+// displayPullProgress reads the messages of a pull and shows its progress on
+// out: a line that's kept up to date on a terminal, otherwise a line per
+// finished layer. It returns the error the pull failed with, if any.
+func displayPullProgress(in io.Reader, ref string, out io.Writer) error {
+	file, ok := out.(*os.File)
+	tty := ok && term.IsTerminal(int(file.Fd()))
+
+	progress := newPullProgress(ref)
+	decoder := json.NewDecoder(in)
+	for {
+		var msg jsonmessage.JSONMessage
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != nil {
+			if tty {
+				fmt.Fprintln(out)
+			}
+			return msg.Error
+		}
+
+		layerDone := progress.update(msg)
+		switch {
+		case tty:
+			fmt.Fprintf(out, "\r\033[K%s", progress)
+		case layerDone:
+			fmt.Fprintln(out, progress)
+		}
+	}
+
+	if tty {
+		fmt.Fprintln(out)
+	}
+	return nil
+}
+
+// formatSize formats a size with binary units, e.g. 1.5GiB
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,134 @@
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{Attempts: 5, Backoff: time.Second, MaxBackoff: 5 * time.Second}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := policy.Delay(i + 1); got != want {
+			t.Errorf("Delay(%d) = %s, want %s", i+1, got, want)
+		}
+	}
+}
+
+func TestIsTransientRegistryError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{errors.New("Get \"https://registry-1.docker.io/v2/\": net/http: TLS handshake timeout"), true},
+		{errors.New("dial tcp: lookup ghcr.io: no such host"), true},
+		{errors.New("toomanyrequests: You have reached your pull rate limit"), true},
+		{errors.New("received unexpected HTTP status: 503 Service Unavailable"), true},
+		{fmt.Errorf("%w: connection refused", ErrDockerUnavailable), false},
+		{fmt.Errorf("%w: manifest unknown", ErrImageNotFound), false},
+		{errors.New("unauthorized: authentication required"), false},
+		{context.Canceled, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := IsTransientRegistryError(tt.err); got != tt.expected {
+			t.Errorf("IsTransientRegistryError(%v) = %v, want %v", tt.err, got, tt.expected)
+		}
+	}
+}
+
+// fakePuller is a Backend whose pulls fail with errs in turn
+type fakePuller struct {
+	Backend
+	errs   []error
+	pulls  int
+	images []string
+}
+
+func (f *fakePuller) PullImage(ctx context.Context, ref string, auth *RegistryAuth, out io.Writer) error {
+	f.pulls++
+	if f.pulls <= len(f.errs) {
+		return f.errs[f.pulls-1]
+	}
+	return nil
+}
+
+func (f *fakePuller) HasImage(ctx context.Context, ref string) (bool, error) {
+	for _, image := range f.images {
+		if image == ref {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func TestPull(t *testing.T) {
+	timeout := errors.New("i/o timeout")
+	retry := RetryPolicy{Attempts: 3}
+
+	tests := []struct {
+		name      string
+		errs      []error
+		options   PullOptions
+		pulls     int
+		wantError error
+	}{
+		{"succeeds", nil, PullOptions{Retry: retry}, 1, nil},
+		{"retries transient errors", []error{timeout, timeout}, PullOptions{Retry: retry}, 3, nil},
+		{"gives up after the attempts", []error{timeout, timeout, timeout}, PullOptions{Retry: retry}, 3, ErrRegistryUnavailable},
+		{"doesn't retry other errors", []error{ErrImageNotFound}, PullOptions{Retry: retry}, 1, ErrImageNotFound},
+		{"no retries by default", []error{timeout}, PullOptions{}, 1, ErrRegistryUnavailable},
+		{"offline with a local image", nil, PullOptions{Offline: true}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := &fakePuller{errs: tt.errs, images: []string{"ubuntu"}}
+			err := Pull(context.Background(), backend, "ubuntu", tt.options)
+			if !errors.Is(err, tt.wantError) || (tt.wantError == nil && err != nil) {
+				t.Errorf("Pull() error = %v, want %v", err, tt.wantError)
+			}
+			if backend.pulls != tt.pulls {
+				t.Errorf("Pull() pulled %d times, want %d", backend.pulls, tt.pulls)
+			}
+		})
+	}
+
+	err := Pull(context.Background(), &fakePuller{}, "ubuntu", PullOptions{Offline: true})
+	if !errors.Is(err, ErrOffline) {
+		t.Errorf("Pull() offline without a local image error = %v, want %v", err, ErrOffline)
+	}
+}
+
+func TestDisplayPullProgress(t *testing.T) {
+	stream := `{"status":"Pulling from library/ubuntu","id":"24.04"}
+{"status":"Pulling fs layer","id":"a"}
+{"status":"Already exists","id":"b"}
+{"status":"Downloading","progressDetail":{"current":512,"total":2048},"id":"a"}
+{"status":"Download complete","id":"a"}
+{"status":"Extracting","progressDetail":{"current":2048,"total":2048},"id":"a"}
+{"status":"Pull complete","id":"a"}
+{"status":"Digest: sha256:abc"}
+{"status":"Status: Downloaded newer image for ubuntu:24.04"}
+`
+	var out strings.Builder
+	if err := displayPullProgress(strings.NewReader(stream), "ubuntu:24.04", &out); err != nil {
+		t.Fatalf("displayPullProgress() error = %v", err)
+	}
+	expected := "ubuntu:24.04: 1/2 layers\nubuntu:24.04: 2/2 layers, 2.0KiB/2.0KiB\n"
+	if out.String() != expected {
+		t.Errorf("displayPullProgress() output = %q, want %q", out.String(), expected)
+	}
+
+	stream = `{"status":"Pulling fs layer","id":"a"}
+{"errorDetail":{"message":"unexpected EOF"},"error":"unexpected EOF"}
+`
+	err := displayPullProgress(strings.NewReader(stream), "ubuntu", io.Discard)
+	if err == nil || !IsTransientRegistryError(err) {
+		t.Errorf("displayPullProgress() error = %v, want a transient error", err)
+	}
+}
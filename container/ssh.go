@@ -0,0 +1,137 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SSHHost returns the docker host URL for a machine given as [user@]host[:port]
+// or as an ssh:// URL
+func SSHHost(host string) string {
+	if strings.HasPrefix(host, "ssh://") {
+		return host
+	}
+	return "ssh://" + host
+}
+
+// IsSSHHost reports whether a docker host is reached over SSH
+func IsSSHHost(host string) bool {
+	return strings.HasPrefix(host, "ssh://")
+}
+
+// SSHCommand returns the ssh invocation that runs remoteCommand on the
+// machine of an ssh:// docker host
+func SSHCommand(host string, remoteCommand ...string) ([]string, error) {
+	args, err := sshArgs(host)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, "--")
+	return append(args, remoteCommand...), nil
+}
+
+// SSHTunnelCommand returns the ssh invocation that connects its stdin and
+// stdout to address as seen from the machine of an ssh:// docker host
+func SSHTunnelCommand(host string, address string) ([]string, error) {
+	args, err := sshArgs(host)
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{args[0], "-W", address}, args[1:]...), nil
+}
+
+// sshArgs returns ssh followed by its options and the destination for host
+func sshArgs(host string) ([]string, error) {
+	u, err := url.Parse(SSHHost(host))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing host %s: %v", host, err)
+	}
+	if u.Hostname() == "" || (u.Path != "" && u.Path != "/") {
+		return nil, fmt.Errorf("invalid host %s, expected [user@]host[:port]", host)
+	}
+
+	args := []string{"ssh"}
+	if u.Port() != "" {
+		args = append(args, "-p", u.Port())
+	}
+	destination := u.Hostname()
+	if u.User != nil {
+		destination = u.User.Username() + "@" + destination
+	}
+	return append(args, destination), nil
+}
+
+// DialCommand starts a command and returns a connection to its stdin and
+// stdout, e.g. ssh running docker system dial-stdio on a remote machine.
+// The command's errors, like failed SSH logins, are written to stderr.
+func DialCommand(ctx context.Context, args []string, stderr io.Writer) (net.Conn, error) {
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stderr = stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error running %s: %v", args[0], err)
+	}
+	return &commandConn{cmd: cmd, stdin: stdin, stdout: stdout}, nil
+}
+
+// commandConn is a net.Conn backed by a command's stdin and stdout
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	once   sync.Once
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	return c.stdout.Read(p)
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	return c.stdin.Write(p)
+}
+
+func (c *commandConn) Close() error {
+	c.once.Do(func() {
+		c.stdin.Close()
+		c.stdout.Close()
+		if c.cmd.Process != nil {
+			c.cmd.Process.Kill()
+		}
+		c.cmd.Wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return commandAddr{}
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return commandAddr{}
+}
+
+// deadlines aren't supported on pipes, the HTTP clients using the
+// connection rely on contexts instead
+func (c *commandConn) SetDeadline(t time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(t time.Time) error { return nil }
+
+type commandAddr struct{}
+
+func (commandAddr) Network() string { return "command" }
+func (commandAddr) String() string  { return "command" }
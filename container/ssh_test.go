@@ -0,0 +1,42 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSSHCommand(t *testing.T) {
+	tests := []struct {
+		host     string
+		expected []string
+		wantErr  bool
+	}{
+		{"devbox", []string{"ssh", "devbox", "--", "docker", "system", "dial-stdio"}, false},
+		{"dev@devbox", []string{"ssh", "dev@devbox", "--", "docker", "system", "dial-stdio"}, false},
+		{"ssh://dev@devbox:2222", []string{"ssh", "-p", "2222", "dev@devbox", "--", "docker", "system", "dial-stdio"}, false},
+		{"ssh://devbox/path", nil, true},
+		{"", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := SSHCommand(tt.host, "docker", "system", "dial-stdio")
+		if (err != nil) != tt.wantErr {
+			t.Errorf("SSHCommand(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("SSHCommand(%q) = %v, want %v", tt.host, got, tt.expected)
+		}
+	}
+}
+
+func TestSSHTunnelCommand(t *testing.T) {
+	got, err := SSHTunnelCommand("dev@devbox:2222", "172.17.0.2:8080")
+	if err != nil {
+		t.Fatalf("SSHTunnelCommand() error = %v", err)
+	}
+	expected := []string{"ssh", "-W", "172.17.0.2:8080", "-p", "2222", "dev@devbox"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("SSHTunnelCommand() = %v, want %v", got, expected)
+	}
+}
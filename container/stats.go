@@ -0,0 +1,104 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+// Stats is a sample of a container's resource usage
+type Stats struct {
+	CPUPercent  float64
+	MemoryUsage uint64
+	MemoryLimit uint64
+	NetworkRx   uint64
+	NetworkTx   uint64
+	BlockRead   uint64
+	BlockWrite  uint64
+}
+
+// Add returns the sum of two samples, for totals across containers
+func (s Stats) Add(other Stats) Stats {
+	return Stats{
+		CPUPercent:  s.CPUPercent + other.CPUPercent,
+		MemoryUsage: s.MemoryUsage + other.MemoryUsage,
+		MemoryLimit: s.MemoryLimit + other.MemoryLimit,
+		NetworkRx:   s.NetworkRx + other.NetworkRx,
+		NetworkTx:   s.NetworkTx + other.NetworkTx,
+		BlockRead:   s.BlockRead + other.BlockRead,
+		BlockWrite:  s.BlockWrite + other.BlockWrite,
+	}
+}
+
+// ContainerStats calls fn with each resource usage sample for the container.
+// Without stream it reports a single sample. It returns when the stream ends,
+// ctx is cancelled or fn returns an error.
+func (c *Client) ContainerStats(ctx context.Context, containerID string, stream bool, fn func(Stats) error) error {
+	resp, err := c.client.ContainerStats(ctx, containerID, stream)
+	if err != nil {
+		return fmt.Errorf("error getting container stats: %w", wrapDockerError(err))
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var sample container.StatsResponse
+		if err := decoder.Decode(&sample); err != nil {
+			if errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("error reading container stats: %v", err)
+		}
+		if err := fn(statsFromResponse(sample)); err != nil {
+			return err
+		}
+	}
+}
+
+// statsFromResponse computes usage the same way docker stats does
+func statsFromResponse(resp container.StatsResponse) Stats {
+	stats := Stats{
+		MemoryUsage: resp.MemoryStats.Usage,
+		MemoryLimit: resp.MemoryStats.Limit,
+	}
+
+	// page cache can be reclaimed, so it doesn't count as used memory.
+	// cgroup v2 reports it as inactive_file, v1 as total_inactive_file.
+	cache := resp.MemoryStats.Stats["inactive_file"]
+	if v1Cache, ok := resp.MemoryStats.Stats["total_inactive_file"]; ok {
+		cache = v1Cache
+	}
+	if cache < stats.MemoryUsage {
+		stats.MemoryUsage -= cache
+	}
+
+	cpuDelta := float64(resp.CPUStats.CPUUsage.TotalUsage) - float64(resp.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(resp.CPUStats.SystemUsage) - float64(resp.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(resp.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(resp.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	for _, network := range resp.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+
+	for _, entry := range resp.BlkioStats.IoServiceBytesRecursive {
+		switch entry.Op {
+		case "read", "Read":
+			stats.BlockRead += entry.Value
+		case "write", "Write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+
+	return stats
+}
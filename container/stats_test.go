@@ -0,0 +1,51 @@
+package container
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStatsFromResponse(t *testing.T) {
+	resp := container.StatsResponse{
+		CPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 300},
+			SystemUsage: 2000,
+			OnlineCPUs:  4,
+		},
+		PreCPUStats: container.CPUStats{
+			CPUUsage:    container.CPUUsage{TotalUsage: 100},
+			SystemUsage: 1000,
+		},
+		MemoryStats: container.MemoryStats{
+			Usage: 500,
+			Limit: 1000,
+			Stats: map[string]uint64{"inactive_file": 100},
+		},
+		Networks: map[string]container.NetworkStats{
+			"eth0": {RxBytes: 10, TxBytes: 20},
+			"eth1": {RxBytes: 1, TxBytes: 2},
+		},
+		BlkioStats: container.BlkioStats{
+			IoServiceBytesRecursive: []container.BlkioStatEntry{
+				{Op: "read", Value: 7},
+				{Op: "write", Value: 3},
+				{Op: "Read", Value: 1},
+			},
+		},
+	}
+
+	expected := Stats{
+		CPUPercent:  80,
+		MemoryUsage: 400,
+		MemoryLimit: 1000,
+		NetworkRx:   11,
+		NetworkTx:   22,
+		BlockRead:   8,
+		BlockWrite:  3,
+	}
+	if got := statsFromResponse(resp); !reflect.DeepEqual(got, expected) {
+		t.Errorf("statsFromResponse() = %+v, want %+v", got, expected)
+	}
+}
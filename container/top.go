@@ -0,0 +1,24 @@
+package container
+
+import (
+	"context"
+	"fmt"
+)
+
+// Processes are the processes running in a container, as reported by ps
+type Processes struct {
+	// Titles are ps's column headers, e.g. PID and CMD
+	Titles []string
+	// Processes are the rows, one cell per title
+	Processes [][]string
+}
+
+// ContainerTop lists the processes running in the container. psArgs are
+// passed to ps on the container's host, docker defaults them to -ef.
+func (c *Client) ContainerTop(ctx context.Context, containerID string, psArgs []string) (*Processes, error) {
+	resp, err := c.client.ContainerTop(ctx, containerID, psArgs)
+	if err != nil {
+		return nil, fmt.Errorf("error listing processes: %w", wrapDockerError(err))
+	}
+	return &Processes{Titles: resp.Titles, Processes: resp.Processes}, nil
+}
@@ -0,0 +1,61 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+)
+
+// Volume is a docker named volume
+type Volume struct {
+	Name   string
+	Labels map[string]string
+	// Size in bytes, -1 if docker didn't report it
+	Size int64
+}
+
+// ListVolumes returns the volumes matching all labels, each "key" or "key=value"
+func (c *Client) ListVolumes(ctx context.Context, labels []string) ([]Volume, error) {
+	// disk usage is the only volume listing that includes sizes
+	df, err := c.client.DiskUsage(ctx, types.DiskUsageOptions{
+		Types: []types.DiskUsageObject{types.VolumeObject},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error listing volumes: %w", wrapDockerError(err))
+	}
+
+	var volumes []Volume
+	for _, v := range df.Volumes {
+		if !matchLabels(v.Labels, labels) {
+			continue
+		}
+		size := int64(-1)
+		if v.UsageData != nil {
+			size = v.UsageData.Size
+		}
+		volumes = append(volumes, Volume{Name: v.Name, Labels: v.Labels, Size: size})
+	}
+	return volumes, nil
+}
+
+// RemoveVolume removes a volume, which fails while a container uses it
+func (c *Client) RemoveVolume(ctx context.Context, name string) error {
+	if err := c.client.VolumeRemove(ctx, name, false); err != nil {
+		return fmt.Errorf("error removing volume %s: %w", name, wrapDockerError(err))
+	}
+	return nil
+}
+
+// matchLabels reports whether labels has every filter, each "key" or "key=value"
+func matchLabels(labels map[string]string, filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		actual, ok := labels[key]
+		if !ok || hasValue && actual != value {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,102 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"gopkg.in/yaml.v2"
+)
+
+// adoptionsPath is the file recording which container was adopted for
+// environments with several matching containers
+func adoptionsPath() string {
+	return filepath.Join(ConfigDir, ".adopted.yml")
+}
+
+func loadAdoptions() (map[string]string, error) {
+	adoptions := map[string]string{}
+
+	data, err := os.ReadFile(adoptionsPath())
+	if os.IsNotExist(err) {
+		return adoptions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", adoptionsPath(), err)
+	}
+
+	if err := yaml.Unmarshal(data, &adoptions); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", adoptionsPath(), err)
+	}
+	return adoptions, nil
+}
+
+// adoptedContainer returns the ID of the container adopted for the environment, if any
+func adoptedContainer(envName string) (string, error) {
+	adoptions, err := loadAdoptions()
+	if err != nil {
+		return "", err
+	}
+	return adoptions[envName], nil
+}
+
+// preferAdopted moves the adopted container to the front of containers
+func preferAdopted(containers []container.Container, adoptedID string) {
+	if adoptedID == "" {
+		return
+	}
+	i := slices.IndexFunc(containers, func(c container.Container) bool { return c.ID == adoptedID })
+	if i > 0 {
+		adopted := containers[i]
+		copy(containers[1:i+1], containers[:i])
+		containers[0] = adopted
+	}
+}
+
+// AdoptContainer makes the container with the given ID, or unique ID prefix,
+// the one tape uses for the environment. It returns the full container ID.
+func AdoptContainer(envName string, id string) (string, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return "", err
+	}
+
+	containers, err := FindDevContainers(*boxConfig)
+	if err != nil {
+		return "", err
+	}
+
+	var matches []string
+	for _, c := range containers {
+		if strings.HasPrefix(c.ID, id) {
+			matches = append(matches, c.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no container matching %s belongs to %s", id, envName)
+	case 1:
+	default:
+		return "", fmt.Errorf("%w: %s matches %d containers, use a longer ID", ErrAmbiguousContainer, id, len(matches))
+	}
+
+	adoptions, err := loadAdoptions()
+	if err != nil {
+		return "", err
+	}
+	adoptions[envName] = matches[0]
+
+	data, err := yaml.Marshal(adoptions)
+	if err != nil {
+		return "", fmt.Errorf("error serializing adoptions: %v", err)
+	}
+	if err := os.WriteFile(adoptionsPath(), data, 0644); err != nil {
+		return "", fmt.Errorf("error writing %s: %v", adoptionsPath(), err)
+	}
+	forgetBoxState(envName)
+
+	return matches[0], nil
+}
@@ -0,0 +1,165 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// The onAutoForward behaviors of portsAttributes, what tape does when it
+// forwards a port
+const (
+	// AutoForwardNotify reports the forwarded port's URL, the default
+	AutoForwardNotify = "notify"
+	// AutoForwardOpenBrowser reports the URL and opens it in the browser
+	AutoForwardOpenBrowser = "openBrowser"
+	// AutoForwardOpenBrowserOnce opens the browser the first time the port is forwarded
+	AutoForwardOpenBrowserOnce = "openBrowserOnce"
+	// AutoForwardSilent forwards the port without reporting it
+	AutoForwardSilent = "silent"
+	// AutoForwardIgnore keeps the port from being forwarded automatically.
+	// Ports forwarded explicitly, e.g. with tape forward add, are silent.
+	AutoForwardIgnore = "ignore"
+)
+
+// PortAttributes returns the portsAttributes of a container port: those of
+// the port itself, or of a range like 3000-3010 or a regular expression it
+// matches, else otherPortsAttributes
+func PortAttributes(config *devcontainer.DevContainerConfig, port int) devcontainer.PortAttributes {
+	if config == nil {
+		return devcontainer.PortAttributes{}
+	}
+	key := strconv.Itoa(port)
+	if attributes, ok := config.PortsAttributes[key]; ok {
+		return attributes
+	}
+	for pattern, attributes := range config.PortsAttributes {
+		if portMatches(pattern, port) {
+			return attributes
+		}
+	}
+	if config.OtherPortsAttributes != nil {
+		return *config.OtherPortsAttributes
+	}
+	return devcontainer.PortAttributes{}
+}
+
+// portMatches reports whether a portsAttributes key other than a single port,
+// a range or a regular expression, matches port
+func portMatches(pattern string, port int) bool {
+	if low, high, found := strings.Cut(pattern, "-"); found {
+		lowPort, lowErr := strconv.Atoi(strings.TrimSpace(low))
+		highPort, highErr := strconv.Atoi(strings.TrimSpace(high))
+		if lowErr == nil && highErr == nil {
+			return lowPort <= port && port <= highPort
+		}
+	}
+	if _, err := strconv.Atoi(pattern); err == nil {
+		return false
+	}
+	re, err := regexp.Compile("^(?:" + pattern + ")$")
+	return err == nil && re.MatchString(strconv.Itoa(port))
+}
+
+// autoForwardBehavior returns the port's onAutoForward, AutoForwardNotify
+// when it isn't set or tape doesn't support it, e.g. openPreview
+func autoForwardBehavior(attributes devcontainer.PortAttributes) string {
+	switch attributes.OnAutoForward {
+	case AutoForwardOpenBrowser, AutoForwardOpenBrowserOnce, AutoForwardSilent, AutoForwardIgnore:
+		return attributes.OnAutoForward
+	}
+	return AutoForwardNotify
+}
+
+// AutoForwardBehavior returns the onAutoForward behavior of a port of the box
+func AutoForwardBehavior(envName string, containerPort int) (string, error) {
+	config, err := loadBoxDevcontainerConfig(envName)
+	if err != nil {
+		return "", err
+	}
+	return autoForwardBehavior(PortAttributes(config, containerPort)), nil
+}
+
+// loadBoxDevcontainerConfig loads the box's devcontainer config, nil for boxes
+// without one
+func loadBoxDevcontainerConfig(envName string) (*devcontainer.DevContainerConfig, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	if boxConfig.Config == "" {
+		return nil, nil
+	}
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+	return config, nil
+}
+
+var (
+	openedBrowserMu sync.Mutex
+	// openedBrowser records the ports openBrowserOnce opened, by env:port
+	openedBrowser = map[string]bool{}
+)
+
+// OnForward carries out the onAutoForward behavior of a port forwarded to
+// the box on address. It opens the browser for openBrowser, and returns the
+// notification to show the user, or "" when the port is silent.
+func OnForward(envName string, containerPort int, address string) (string, error) {
+	config, err := loadBoxDevcontainerConfig(envName)
+	if err != nil {
+		return "", err
+	}
+	attributes := PortAttributes(config, containerPort)
+	behavior := autoForwardBehavior(attributes)
+	if behavior == AutoForwardSilent || behavior == AutoForwardIgnore {
+		return "", nil
+	}
+
+	url := forwardURL(attributes, address)
+	name := strconv.Itoa(containerPort)
+	if attributes.Label != "" {
+		name = fmt.Sprintf("%d (%s)", containerPort, attributes.Label)
+	}
+	message := fmt.Sprintf("Port %s of %s is available on %s", name, envName, url)
+
+	open := behavior == AutoForwardOpenBrowser
+	if behavior == AutoForwardOpenBrowserOnce {
+		key := fmt.Sprintf("%s:%d", envName, containerPort)
+		openedBrowserMu.Lock()
+		open = !openedBrowser[key]
+		openedBrowser[key] = true
+		openedBrowserMu.Unlock()
+	}
+	if open {
+		cmd := OpenURLCommand(url)
+		if err := cmd.Start(); err != nil {
+			return message, fmt.Errorf("error opening browser: %v", err)
+		}
+		go cmd.Wait()
+	}
+	return message, nil
+}
+
+// forwardURL returns the URL of a forward listening on address, on localhost
+// when it listens on every interface
+func forwardURL(attributes devcontainer.PortAttributes, address string) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	protocol := "http"
+	if attributes.Protocol == "https" {
+		protocol = "https"
+	}
+	return protocol + "://" + net.JoinHostPort(host, port)
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestPortAttributes(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{
+		PortsAttributes: map[string]devcontainer.PortAttributes{
+			"3000":       {Label: "web", OnAutoForward: AutoForwardOpenBrowser},
+			"5000-5010":  {Label: "range", OnAutoForward: AutoForwardSilent},
+			"90[0-9]{2}": {Label: "regexp", OnAutoForward: AutoForwardIgnore},
+		},
+		OtherPortsAttributes: &devcontainer.PortAttributes{OnAutoForward: AutoForwardSilent},
+	}
+
+	tests := []struct {
+		name             string
+		config           *devcontainer.DevContainerConfig
+		port             int
+		expectedLabel    string
+		expectedBehavior string
+	}{
+		{"exact port", config, 3000, "web", AutoForwardOpenBrowser},
+		{"range", config, 5005, "range", AutoForwardSilent},
+		{"regular expression", config, 9042, "regexp", AutoForwardIgnore},
+		{"other ports", config, 8080, "", AutoForwardSilent},
+		{"no attributes", &devcontainer.DevContainerConfig{}, 8080, "", AutoForwardNotify},
+		{"no config", nil, 8080, "", AutoForwardNotify},
+		{
+			"unsupported behavior",
+			&devcontainer.DevContainerConfig{PortsAttributes: map[string]devcontainer.PortAttributes{"3000": {OnAutoForward: "openPreview"}}},
+			3000, "", AutoForwardNotify,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attributes := PortAttributes(tt.config, tt.port)
+			if attributes.Label != tt.expectedLabel {
+				t.Errorf("PortAttributes() label = %q, want %q", attributes.Label, tt.expectedLabel)
+			}
+			if behavior := autoForwardBehavior(attributes); behavior != tt.expectedBehavior {
+				t.Errorf("autoForwardBehavior() = %q, want %q", behavior, tt.expectedBehavior)
+			}
+		})
+	}
+}
+
+func TestForwardURL(t *testing.T) {
+	tests := []struct {
+		attributes devcontainer.PortAttributes
+		address    string
+		expected   string
+	}{
+		{devcontainer.PortAttributes{}, "127.0.0.1:34567", "http://127.0.0.1:34567"},
+		{devcontainer.PortAttributes{Protocol: "https"}, "127.0.0.1:8443", "https://127.0.0.1:8443"},
+		{devcontainer.PortAttributes{}, "0.0.0.0:3000", "http://localhost:3000"},
+		{devcontainer.PortAttributes{}, "[::]:3000", "http://localhost:3000"},
+	}
+
+	for _, tt := range tests {
+		if url := forwardURL(tt.attributes, tt.address); url != tt.expected {
+			t.Errorf("forwardURL(%q) = %q, want %q", tt.address, url, tt.expected)
+		}
+	}
+}
@@ -4,14 +4,25 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/mikeocool/tape/container"
-	"gopkg.in/yaml.v2"
 )
 
 var ConfigDir string
 
+// HostOverride runs every box on this machine instead of the one in its
+// config, see BoxConfig.Host. It is set by tape --host.
+var HostOverride string
+
+// Offline keeps tape from contacting registries, so images have to exist
+// locally. It is set by tape --offline.
+var Offline bool
+
 func init() {
 	ConfigDir = os.Getenv("TAPE_CONFIG_DIR")
 	if ConfigDir == "" {
@@ -26,28 +37,176 @@ func init() {
 }
 
 type BoxConfig struct {
-	Name      string
+	Name      string `yaml:"-"`
 	Workspace string `yaml:"workspace" validate:"required"`
 	Config    string `yaml:"config,omitempty"`
+	// ConfigName chooses the workspace's .devcontainer/<name>/devcontainer.json
+	// when it has several, instead of a config path. Without either, the
+	// workspace's config is discovered, see FindDevcontainerConfigs.
+	ConfigName string            `yaml:"config-name,omitempty" validate:"excluded_with=Config,excludesall=/\\"`
+	Network    string            `yaml:"network,omitempty"`
+	Env        map[string]string `yaml:"env,omitempty" validate:"dive,keys,required,endkeys"`
+	// Secrets are variables looked up from secret stores when the box is
+	// started and for each exec, by reference, e.g. ssm:///team/db-password,
+	// see RegisterSecretProvider. Unlike Env they aren't stored in the container's config.
+	Secrets map[string]string `yaml:"secrets,omitempty" validate:"dive,keys,required,endkeys,required,contains=://"`
+	// Mounts are additional docker --mount style mounts
+	Mounts []string `yaml:"mounts,omitempty" validate:"dive,required"`
+	// Ports are published to the host, as "port", "hostPort:containerPort" or
+	// "ip:hostPort:containerPort", e.g. 127.0.0.1:3000:3000
+	Ports []string `yaml:"ports,omitempty" validate:"dive,port_mapping"`
+	// DockerHost is the docker daemon the box runs on, defaulting to DOCKER_HOST
+	DockerHost string `yaml:"docker-host,omitempty" validate:"omitempty,uri"`
+	// Host is a machine the box runs on, as [user@]host[:port]. Its docker
+	// daemon is reached over SSH, so the workspace and config paths have to
+	// exist there too, unless the workspace is synced, see Sync.
+	Host      string       `yaml:"host,omitempty" validate:"excluded_with=DockerHost"`
+	Resources BoxResources `yaml:"resources,omitempty"`
+	// Build configures how the devcontainer's Dockerfile is built
+	Build BoxBuild `yaml:"build,omitempty"`
+	// IdleTimeout is how long the box can sit idle before tape daemon stops
+	// it, e.g. 30m, see LastActivity
+	IdleTimeout string `yaml:"idle-timeout,omitempty" validate:"omitempty,duration"`
+	// Restart is the docker restart policy of the box's container, for boxes
+	// running long-lived services. Containers stopped by tape stay stopped.
+	Restart string `yaml:"restart,omitempty" validate:"omitempty,oneof=no on-failure always unless-stopped"`
+	// PrebuiltImage is used instead of building the devcontainer's image
+	PrebuiltImage string `yaml:"prebuilt-image,omitempty"`
+	// Tasks are named shell commands run with tape task. They override tasks
+	// of the same name in the devcontainer's customizations.tape.tasks.
+	Tasks map[string]string `yaml:"tasks,omitempty" validate:"dive,keys,required,endkeys,required"`
+	// Sync keeps the workspace in a named volume synced with the host, see
+	// tape sync, instead of bind mounting it, which is slow on macOS and Windows
+	Sync bool `yaml:"sync,omitempty"`
+	// SyncIgnore are file and directory name patterns that aren't synced
+	SyncIgnore []string `yaml:"sync-ignore,omitempty"`
+	// Hardened runs the container with a read-only root filesystem, without
+	// capabilities and with no-new-privileges, for evaluating untrusted
+	// repositories. The workspace, caches, home directory and /tmp stay
+	// writable. Lifecycle commands that install packages fail.
+	Hardened bool `yaml:"hardened,omitempty"`
+	// Untrusted runs the box sandboxed, like tape up --untrusted does: without
+	// its initializeCommand and unsafe lifecycle commands, without the
+	// docker socket or binds of host paths outside the workspace, and on an
+	// internal network whose only way out is an egress proxy to the allowed
+	// hosts, see UntrustedConfig. Compose configs can't be sandboxed.
+	Untrusted bool `yaml:"untrusted,omitempty"`
+	// GUI has GUI programs in the box, e.g. browsers for end-to-end tests,
+	// display on the host, see guiOverrides
+	GUI string `yaml:"gui,omitempty" validate:"omitempty,oneof=x11"`
+	// Clipboard copies what programs in the box copy with OSC 52, e.g. tmux
+	// and vim, to the host's clipboard in tape exec and SSH sessions
+	Clipboard bool `yaml:"clipboard,omitempty"`
+	// NoHostLocale keeps the container in UTC and the image's locale instead
+	// of giving it the host's timezone and locale, see applyHostLocale
+	NoHostLocale bool `yaml:"no-host-locale,omitempty"`
+	// WorkspaceMountConsistency trades consistency between the host and the
+	// container for speed of the workspace bind mount on macOS, where bind
+	// mounts are slow. It applies to the devcontainer config's workspaceMount too.
+	WorkspaceMountConsistency string `yaml:"workspace-mount-consistency,omitempty" validate:"omitempty,oneof=consistent cached delegated"`
+	// Caches are toolchain caches shared with other boxes, see CachePresets
+	Caches []string `yaml:"caches,omitempty" validate:"dive,oneof=go node pip cargo maven"`
+	// Registries are credentials for private registries by registry host,
+	// e.g. ghcr.io, used instead of the docker CLI's
+	Registries map[string]RegistryCredentials `yaml:"registries,omitempty" validate:"dive,keys,required,endkeys"`
+	// DependsOn are boxes started before this one when they are started as a
+	// group, see GlobalConfig.Groups
+	DependsOn []string `yaml:"depends-on,omitempty" validate:"dive,required"`
+	Hooks     BoxHooks `yaml:"hooks,omitempty"`
+}
+
+type BoxResources struct {
+	CPUs   string `yaml:"cpus,omitempty" validate:"omitempty,numeric"`
+	Memory string `yaml:"memory,omitempty" validate:"omitempty,memory_size"`
 }
 
+var portMappingPattern = regexp.MustCompile(`^(((\d{1,3}(\.\d{1,3}){3}|\[[0-9a-fA-F:.]+\]):)?(\d{1,5})?:)?\d{1,5}(/(tcp|udp))?$`)
+var memorySizePattern = regexp.MustCompile(`^\d+[bkmgBKMG]?$`)
+
 // ValidateConfig validates the BoxConfig using validator
 func (b *BoxConfig) ValidateConfig() error {
 	validate := validator.New()
+	validate.RegisterValidation("port_mapping", func(fl validator.FieldLevel) bool {
+		return portMappingPattern.MatchString(fl.Field().String())
+	})
+	validate.RegisterValidation("memory_size", func(fl validator.FieldLevel) bool {
+		return memorySizePattern.MatchString(fl.Field().String())
+	})
+	validate.RegisterValidation("duration", func(fl validator.FieldLevel) bool {
+		_, err := time.ParseDuration(fl.Field().String())
+		return err == nil
+	})
 	return validate.Struct(b)
 }
 
+// IdleTimeoutDuration returns the parsed idle timeout, or 0 if the box has none
+func (b *BoxConfig) IdleTimeoutDuration() time.Duration {
+	timeout, err := time.ParseDuration(b.IdleTimeout)
+	if err != nil {
+		return 0
+	}
+	return timeout
+}
+
+// boxConfigExtensions are the extensions box config files can have, in order of precedence
+var boxConfigExtensions = []string{".yml", ".yaml"}
+
+// BoxConfigPath returns the path of the YAML file for an environment name.
+// Names can include directories, e.g. project/api. If no file exists yet the
+// .yml path is returned.
+func BoxConfigPath(envName string) string {
+	for _, ext := range boxConfigExtensions {
+		path := filepath.Join(ConfigDir, filepath.FromSlash(envName)+ext)
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return filepath.Join(ConfigDir, filepath.FromSlash(envName)+boxConfigExtensions[0])
+}
+
+// ContainerName returns the name used for the box's container, which can't
+// contain slashes. Names that only differ in slashes and dashes, e.g.
+// team/app and team-app, get the same container name, see
+// checkContainerName.
+func (b *BoxConfig) ContainerName() string {
+	return strings.ReplaceAll(b.Name, "/", "-")
+}
+
+// checkContainerName fails when another box has the same container name as
+// the box, since they would share the container and the volumes named after it
+func checkContainerName(boxConfig BoxConfig) error {
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return err
+	}
+	name := boxConfig.ContainerName()
+	for _, envName := range envNames {
+		other := BoxConfig{Name: envName}
+		if envName != boxConfig.Name && other.ContainerName() == name {
+			return fmt.Errorf("%s and %s would both use the container name %s, rename one of them", boxConfig.Name, envName, name)
+		}
+	}
+	return nil
+}
+
 // LoadBoxConfig loads a box configuration from a YAML file by environment name
 func LoadBoxConfig(envName string) (*BoxConfig, error) {
-	configFile := filepath.Join(ConfigDir, envName+".yml")
+	if !filepath.IsLocal(filepath.FromSlash(envName)) {
+		return nil, fmt.Errorf("invalid environment name %s", envName)
+	}
+
+	configFile := BoxConfigPath(envName)
 	yamlData, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: no config for %s at %s", ErrConfigNotFound, envName, configFile)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file %s: %v", configFile, err)
 	}
 
 	var config BoxConfig
-	if err := yaml.Unmarshal(yamlData, &config); err != nil {
-		return nil, fmt.Errorf("error parsing YAML: %v", err)
+	if err := unmarshalStrict(yamlData, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", configFile, err)
 	}
 	config.Name = envName
 
@@ -57,6 +216,30 @@ func LoadBoxConfig(envName string) (*BoxConfig, error) {
 	}
 
 	// fill in defaults
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case HostOverride != "":
+		config.Host = HostOverride
+		config.DockerHost = container.SSHHost(HostOverride)
+	case config.Host != "":
+		config.DockerHost = container.SSHHost(config.Host)
+	case config.DockerHost == "":
+		config.Host = globalConfig.Host
+		config.DockerHost = globalConfig.DockerHost
+	}
+	if config.IdleTimeout == "" {
+		config.IdleTimeout = globalConfig.IdleTimeout
+	}
+
+	// paths copied from Windows tools point at the same files through WSL's mounts
+	if InWSL() {
+		config.Workspace = wslPath(config.Workspace)
+		config.Config = wslPath(config.Config)
+	}
+
 	// Make workspace path absolute
 	if !filepath.IsAbs(config.Workspace) {
 		absPath, err := filepath.Abs(filepath.Join(ConfigDir, config.Workspace))
@@ -70,7 +253,10 @@ func LoadBoxConfig(envName string) (*BoxConfig, error) {
 	config.Workspace = filepath.Clean(config.Workspace)
 
 	if config.Config == "" {
-		config.Config = fmt.Sprintf("%s/.devcontainer/devcontainer.json", config.Workspace)
+		if config.ConfigName == "" {
+			config.ConfigName = selectedConfigName(config)
+		}
+		config.Config = devcontainerConfigPath(config.Workspace, config.ConfigName)
 	} else {
 		if !filepath.IsAbs(config.Config) {
 			absConfigPath, err := filepath.Abs(filepath.Join(ConfigDir, config.Config))
@@ -84,8 +270,10 @@ func LoadBoxConfig(envName string) (*BoxConfig, error) {
 	return &config, nil
 }
 
-// ListBoxConfigs returns a list of available box configurations by listing
-// all YAML files in the sample-config directory and removing the .yml extension
+// ListBoxConfigs returns a list of available box configurations by finding
+// all YAML files in the config directory and its subdirectories. The
+// environment name is the path relative to the config directory without the
+// extension. Hidden files and directories are skipped.
 func ListBoxConfigs() ([]string, error) {
 
 	// Check if the directory exists
@@ -93,72 +281,176 @@ func ListBoxConfigs() ([]string, error) {
 		return nil, fmt.Errorf("config directory %s does not exist", ConfigDir)
 	}
 
-	// Read all files in the directory
-	files, err := os.ReadDir(ConfigDir)
-	if err != nil {
-		return nil, fmt.Errorf("error reading config directory: %v", err)
-	}
-
 	var configs []string
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	seen := map[string]bool{}
+	err := filepath.WalkDir(ConfigDir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
 		}
 
-		filename := file.Name()
-		if filepath.Ext(filename) == ".yml" {
-			// Remove the .yml extension to get the environment name
-			envName := filename[:len(filename)-4]
+		if path != ConfigDir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if d.IsDir() || !slices.Contains(boxConfigExtensions, ext) {
+			return nil
+		}
+
+		rel, err := filepath.Rel(ConfigDir, path)
+		if err != nil {
+			return err
+		}
+
+		// Remove the extension to get the environment name
+		envName := filepath.ToSlash(strings.TrimSuffix(rel, ext))
+		if !seen[envName] {
+			seen[envName] = true
 			configs = append(configs, envName)
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading config directory: %v", err)
 	}
 
 	return configs, nil
 }
 
+// FindBoxForDir returns the environment whose workspace contains dir, or ""
+// if there is none. When workspaces are nested the innermost one wins.
+// Configs that fail to load are skipped.
+func FindBoxForDir(dir string) (string, error) {
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return "", err
+	}
+
+	dir = filepath.Clean(dir)
+	match, matchLength := "", 0
+	for _, envName := range envNames {
+		config, err := LoadBoxConfig(envName)
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(config.Workspace, dir)
+		if err != nil || !filepath.IsLocal(rel) && rel != "." {
+			continue
+		}
+		if len(config.Workspace) > matchLength {
+			match, matchLength = envName, len(config.Workspace)
+		}
+	}
+	return match, nil
+}
+
 type BoxState string
 
 const (
+	BoxStateCreated      BoxState = "created"
 	BoxStateRunning      BoxState = "running"
+	BoxStatePaused       BoxState = "paused"
+	BoxStateRestarting   BoxState = "restarting"
+	BoxStateRemoving     BoxState = "removing"
 	BoxStateStopped      BoxState = "stopped"
+	BoxStateDead         BoxState = "dead"
 	BoxStateDoesNotExist BoxState = "does-not-exist"
 	BoxStateUnknown      BoxState = "unknown"
 )
 
+// CanStop reports whether a box in this state has a container that can be stopped
+func (s BoxState) CanStop() bool {
+	return s == BoxStateRunning || s == BoxStatePaused || s == BoxStateRestarting
+}
+
+// CanRemove reports whether a box in this state has a container that can be removed
+func (s BoxState) CanRemove() bool {
+	return s == BoxStateStopped || s == BoxStateCreated || s == BoxStateDead
+}
+
+func boxStateFromContainerState(state container.State) BoxState {
+	switch state {
+	case container.StateCreated:
+		return BoxStateCreated
+	case container.StateRunning:
+		return BoxStateRunning
+	case container.StatePaused:
+		return BoxStatePaused
+	case container.StateRestarting:
+		return BoxStateRestarting
+	case container.StateRemoving:
+		return BoxStateRemoving
+	case container.StateExited:
+		return BoxStateStopped
+	case container.StateDead:
+		return BoxStateDead
+	default:
+		return BoxStateUnknown
+	}
+}
+
 type BoxSummary struct {
 	EnvName     string
 	State       BoxState
 	ContainerID string
+	// Containers are all the containers matching the box, the selected one first.
+	// More than one means leftovers, e.g. from rebuilds, see AdoptContainer.
+	Containers []container.Container
 }
 
+// GetBoxSummary returns the state of the box's container
 func GetBoxSummary(envName string) (*BoxSummary, error) {
 	boxConfig, err := LoadBoxConfig(envName)
 	if err != nil {
 		return nil, err
 	}
+	summary, err := getBoxSummary(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	cacheBoxSummary(*boxConfig, summary)
+	return summary, nil
+}
 
-	state := BoxStateUnknown
-	dc, err := FindDevContainer(*boxConfig)
+// CachedBoxSummary is GetBoxSummary, from the state cache when it's valid,
+// see stateCache. The state may be stale, it's for displaying boxes, e.g.
+// in ls, not for deciding what to do with them.
+func CachedBoxSummary(envName string) (*BoxSummary, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	if summary, ok := cachedBoxSummary(*boxConfig); ok {
+		return summary, nil
+	}
+
+	summary, err := getBoxSummary(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	cacheBoxSummary(*boxConfig, summary)
+	return summary, nil
+}
+
+func getBoxSummary(boxConfig BoxConfig) (*BoxSummary, error) {
+	containers, err := FindDevContainers(boxConfig)
 	if err != nil {
 		if container.IsContainerNotFound(err) {
 			return &BoxSummary{
-				EnvName: envName,
+				EnvName: boxConfig.Name,
 				State:   BoxStateDoesNotExist,
 			}, nil
 		}
 		return nil, err
 	}
 
-	if dc.State == "running" {
-		state = BoxStateRunning
-	} else if dc.State == "exited" {
-		state = BoxStateStopped
-	}
-
 	return &BoxSummary{
-		EnvName:     envName,
-		State:       state,
-		ContainerID: dc.ID,
+		EnvName:     boxConfig.Name,
+		State:       boxStateFromContainerState(containers[0].State),
+		ContainerID: containers[0].ID,
+		Containers:  containers,
 	}, nil
-
 }
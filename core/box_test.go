@@ -0,0 +1,796 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func setupConfigDir(t *testing.T, files map[string]string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create config dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write config file: %v", err)
+		}
+	}
+
+	original := ConfigDir
+	ConfigDir = dir
+	t.Cleanup(func() { ConfigDir = original })
+}
+
+func TestLoadBoxConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		yaml     string
+		expected func(dir string) *BoxConfig
+		wantErr  bool
+	}{
+		{
+			name: "minimal config",
+			yaml: "workspace: /src/app\n",
+			expected: func(dir string) *BoxConfig {
+				return &BoxConfig{
+					Name:      "box",
+					Workspace: "/src/app",
+					Config:    "/src/app/.devcontainer/devcontainer.json",
+				}
+			},
+		},
+		{
+			name: "relative paths",
+			yaml: "workspace: app/\nconfig: configs/devcontainer.json\n",
+			expected: func(dir string) *BoxConfig {
+				return &BoxConfig{
+					Name:      "box",
+					Workspace: filepath.Join(dir, "app"),
+					Config:    filepath.Join(dir, "configs/devcontainer.json"),
+				}
+			},
+		},
+		{
+			name: "full config",
+			yaml: `workspace: /src/app
+network: shared
+env:
+  FOO: bar
+mounts:
+  - source=cache,target=/cache,type=volume
+ports:
+  - "8080"
+  - "5433:5432"
+  - 53:53/udp
+  - 127.0.0.1:3000:3000
+docker-host: tcp://devbox:2375
+resources:
+  cpus: "2.5"
+  memory: 4g
+idle-timeout: 30m
+prebuilt-image: ghcr.io/acme/app:latest
+`,
+			expected: func(dir string) *BoxConfig {
+				return &BoxConfig{
+					Name:          "box",
+					Workspace:     "/src/app",
+					Config:        "/src/app/.devcontainer/devcontainer.json",
+					Network:       "shared",
+					Env:           map[string]string{"FOO": "bar"},
+					Mounts:        []string{"source=cache,target=/cache,type=volume"},
+					Ports:         []string{"8080", "5433:5432", "53:53/udp", "127.0.0.1:3000:3000"},
+					DockerHost:    "tcp://devbox:2375",
+					Resources:     BoxResources{CPUs: "2.5", Memory: "4g"},
+					IdleTimeout:   "30m",
+					PrebuiltImage: "ghcr.io/acme/app:latest",
+				}
+			},
+		},
+		{
+			name: "config name",
+			yaml: "workspace: /src/app\nconfig-name: python\n",
+			expected: func(dir string) *BoxConfig {
+				return &BoxConfig{
+					Name:       "box",
+					Workspace:  "/src/app",
+					Config:     "/src/app/.devcontainer/python/devcontainer.json",
+					ConfigName: "python",
+				}
+			},
+		},
+		{
+			name:    "config name with config",
+			yaml:    "workspace: /src/app\nconfig: devcontainer.json\nconfig-name: python\n",
+			wantErr: true,
+		},
+		{
+			name:    "missing workspace",
+			yaml:    "network: shared\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid port",
+			yaml:    "workspace: /src/app\nports: [\"http\"]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid port address",
+			yaml:    "workspace: /src/app\nports: [\"localhost:3000:3000\"]\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid cpus",
+			yaml:    "workspace: /src/app\nresources:\n  cpus: two\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid memory",
+			yaml:    "workspace: /src/app\nresources:\n  memory: 4 gigs\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid idle timeout",
+			yaml:    "workspace: /src/app\nidle-timeout: forever\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid workspace mount consistency",
+			yaml:    "workspace: /src/app\nworkspace-mount-consistency: fast\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown cache",
+			yaml:    "workspace: /src/app\ncaches: [go, ruby]\n",
+			wantErr: true,
+		},
+		{
+			name:    "empty env key",
+			yaml:    "workspace: /src/app\nenv:\n  \"\": bar\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfigDir(t, map[string]string{"box.yml": tt.yaml})
+
+			got, err := LoadBoxConfig("box")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadBoxConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			expected := tt.expected(ConfigDir)
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("LoadBoxConfig() = %+v, want %+v", got, expected)
+			}
+		})
+	}
+}
+
+func TestIdleTimeoutDuration(t *testing.T) {
+	config := BoxConfig{IdleTimeout: "1h30m"}
+	if got := config.IdleTimeoutDuration(); got != 90*time.Minute {
+		t.Errorf("IdleTimeoutDuration() = %v, want 1h30m", got)
+	}
+
+	config = BoxConfig{}
+	if got := config.IdleTimeoutDuration(); got != 0 {
+		t.Errorf("IdleTimeoutDuration() = %v, want 0", got)
+	}
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	boxConfig := BoxConfig{
+		Name:        "box",
+		Network:     "shared",
+		Env:         map[string]string{"FOO": "bar"},
+		Mounts:      []string{"source=cache,target=/cache,type=volume"},
+		Ports:       []string{"8080"},
+		Resources:   BoxResources{CPUs: "2", Memory: "4g"},
+		IdleTimeout: "30m",
+		Restart:     "on-failure",
+	}
+	config := &devcontainer.DevContainerConfig{
+		Image:        "ubuntu",
+		RunArgs:      []string{"--init"},
+		ContainerEnv: map[string]string{"FOO": "baz", "HELLO": "world"},
+	}
+
+	got := EffectiveConfig(boxConfig, config)
+
+	expectedRunArgs := []string{
+		"--init",
+		"-p", "8080",
+		"--cpus", "2",
+		"--memory", "4g",
+		"--label", "tape.env=box",
+		"--label", "tape.version=dev",
+		"--label", "tape.idle-timeout=30m",
+		"--restart", "on-failure",
+		"--name", "box",
+		"--network", "shared",
+		"--network-alias", "box",
+	}
+	if !reflect.DeepEqual(got.RunArgs, expectedRunArgs) {
+		t.Errorf("EffectiveConfig().RunArgs = %v, want %v", got.RunArgs, expectedRunArgs)
+	}
+
+	expectedEnv := map[string]string{"FOO": "bar", "HELLO": "world"}
+	if !reflect.DeepEqual(got.ContainerEnv, expectedEnv) {
+		t.Errorf("EffectiveConfig().ContainerEnv = %v, want %v", got.ContainerEnv, expectedEnv)
+	}
+
+	if len(got.Mounts) != 1 || got.Mounts[0].AsString() != "source=cache,target=/cache,type=volume" {
+		t.Errorf("EffectiveConfig().Mounts = %v, want the box mount", got.Mounts)
+	}
+
+	if got.Image != "ubuntu" {
+		t.Errorf("EffectiveConfig().Image = %v, want ubuntu", got.Image)
+	}
+}
+
+func TestEffectiveConfigKeepsConfiguredName(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{
+		RunArgs: []string{"--name", "custom"},
+	}
+
+	got := EffectiveConfig(BoxConfig{Name: "box"}, config)
+
+	expected := []string{"--name", "custom", "--label", "tape.env=box", "--label", "tape.version=dev"}
+	if !reflect.DeepEqual(got.RunArgs, expected) {
+		t.Errorf("EffectiveConfig().RunArgs = %v, want %v", got.RunArgs, expected)
+	}
+}
+
+func TestEffectiveConfigSync(t *testing.T) {
+	boxConfig := BoxConfig{Name: "box", Workspace: "/src/app", Sync: true}
+
+	got := EffectiveConfig(boxConfig, &devcontainer.DevContainerConfig{})
+	if got.WorkspaceFolder != "/workspaces/app" {
+		t.Errorf("EffectiveConfig().WorkspaceFolder = %v, want /workspaces/app", got.WorkspaceFolder)
+	}
+	if expected := "source=tape-sync-box,target=/workspaces/app,type=volume"; got.WorkspaceMount != expected {
+		t.Errorf("EffectiveConfig().WorkspaceMount = %v, want %v", got.WorkspaceMount, expected)
+	}
+
+	got = EffectiveConfig(boxConfig, &devcontainer.DevContainerConfig{WorkspaceFolder: "/code"})
+	if expected := "source=tape-sync-box,target=/code,type=volume"; got.WorkspaceMount != expected {
+		t.Errorf("EffectiveConfig().WorkspaceMount = %v, want %v", got.WorkspaceMount, expected)
+	}
+}
+
+func TestMountWithConsistency(t *testing.T) {
+	tests := []struct {
+		mount    string
+		expected string
+	}{
+		{"source=/src/app,target=/workspaces/app,type=bind", "source=/src/app,target=/workspaces/app,type=bind,consistency=delegated"},
+		{"type=bind,source=/src/app,target=/app,consistency=cached", "type=bind,source=/src/app,target=/app,consistency=cached"},
+		{"source=app-data,target=/workspaces/app,type=volume", "source=app-data,target=/workspaces/app,type=volume"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := mountWithConsistency(tt.mount, "delegated"); got != tt.expected {
+			t.Errorf("mountWithConsistency(%q) = %q, want %q", tt.mount, got, tt.expected)
+		}
+	}
+}
+
+func TestEffectiveConfigCaches(t *testing.T) {
+	boxConfig := BoxConfig{
+		Name:   "box",
+		Env:    map[string]string{"GOCACHE": "/custom"},
+		Caches: []string{"go", "node"},
+	}
+	config := &devcontainer.DevContainerConfig{
+		ContainerEnv: map[string]string{"npm_config_cache": "/from-config"},
+	}
+
+	got := EffectiveConfig(boxConfig, config)
+
+	var mounts []string
+	for _, mount := range got.Mounts {
+		mounts = append(mounts, mount.AsString())
+	}
+	expectedMounts := []string{
+		"source=tape-cache-go,target=/tape-cache/go,type=volume,volume-label=tape.cache=go",
+		"source=tape-cache-node,target=/tape-cache/npm,type=volume,volume-label=tape.cache=node",
+	}
+	if !reflect.DeepEqual(mounts, expectedMounts) {
+		t.Errorf("EffectiveConfig().Mounts = %v, want %v", mounts, expectedMounts)
+	}
+
+	expectedEnv := map[string]string{
+		"GOMODCACHE":       "/tape-cache/go/mod",
+		"GOCACHE":          "/custom",
+		"npm_config_cache": "/from-config",
+	}
+	if !reflect.DeepEqual(got.ContainerEnv, expectedEnv) {
+		t.Errorf("EffectiveConfig().ContainerEnv = %v, want %v", got.ContainerEnv, expectedEnv)
+	}
+	if len(boxConfig.Env) != 1 {
+		t.Errorf("EffectiveConfig() modified the box's env: %v", boxConfig.Env)
+	}
+}
+
+func TestLoadBoxConfigUnknownField(t *testing.T) {
+	setupConfigDir(t, map[string]string{"box.yml": "workspace: /src/app\nnetwrok: shared\nresources:\n  memroy: 4g\n"})
+
+	_, err := LoadBoxConfig("box")
+	if err == nil {
+		t.Fatalf("LoadBoxConfig() did not return an error for unknown fields")
+	}
+
+	for _, want := range []string{
+		`unknown field "netwrok" at line 2, did you mean "network"?`,
+		`unknown field "memroy" at line 4, did you mean "memory"?`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("LoadBoxConfig() error = %v, want it to contain %s", err, want)
+		}
+	}
+}
+
+func TestClosestField(t *testing.T) {
+	known := []string{"workspace", "config", "network", "env"}
+
+	tests := []struct {
+		field    string
+		expected string
+	}{
+		{field: "workspce", expected: "workspace"},
+		{field: "Config", expected: "config"},
+		{field: "envs", expected: "env"},
+		{field: "completely-different", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if got := closestField(tt.field, known); got != tt.expected {
+			t.Errorf("closestField(%q) = %q, want %q", tt.field, got, tt.expected)
+		}
+	}
+}
+
+func TestListBoxConfigs(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"web.yml":                   "workspace: /src/web\n",
+		"api.yaml":                  "workspace: /src/api\n",
+		"both.yml":                  "workspace: /src/both\n",
+		"both.yaml":                 "workspace: /src/both-yaml\n",
+		"project/backend.yaml":      "workspace: /src/backend\n",
+		"project/nested/worker.yml": "workspace: /src/worker\n",
+		"notes.txt":                 "not a config\n",
+		".tape.yml":                 "dotfiles-repository: example\n",
+		".bundles/imported/box.yml": "workspace: /src/imported\n",
+	})
+
+	got, err := ListBoxConfigs()
+	if err != nil {
+		t.Fatalf("ListBoxConfigs() error = %v", err)
+	}
+
+	expected := []string{"api", "both", "project/backend", "project/nested/worker", "web"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ListBoxConfigs() = %v, want %v", got, expected)
+	}
+
+	for _, envName := range expected {
+		if _, err := LoadBoxConfig(envName); err != nil {
+			t.Errorf("LoadBoxConfig(%q) error = %v", envName, err)
+		}
+	}
+
+	config, err := LoadBoxConfig("both")
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	if config.Workspace != "/src/both" {
+		t.Errorf("LoadBoxConfig() preferred %s, want the .yml config", config.Workspace)
+	}
+
+	config, err = LoadBoxConfig("project/nested/worker")
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	if got := config.ContainerName(); got != "project-nested-worker" {
+		t.Errorf("ContainerName() = %v, want project-nested-worker", got)
+	}
+
+	if _, err := LoadBoxConfig("../outside"); err == nil {
+		t.Errorf("LoadBoxConfig() did not reject a name outside the config directory")
+	}
+}
+
+func TestFindBoxForDir(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"web.yml":     "workspace: /src/web\n",
+		"nested.yml":  "workspace: /src/web/packages/nested\n",
+		"webapp.yml":  "workspace: /src/webapp\n",
+		"invalid.yml": "workspace: /src\nunknown: true\n",
+	})
+
+	tests := []struct {
+		dir      string
+		expected string
+	}{
+		{"/src/web", "web"},
+		{"/src/web/cmd/", "web"},
+		{"/src/web/packages/nested/lib", "nested"},
+		{"/src/webapp", "webapp"},
+		{"/src", ""},
+		{"/elsewhere", ""},
+	}
+
+	for _, tt := range tests {
+		got, err := FindBoxForDir(tt.dir)
+		if err != nil {
+			t.Fatalf("FindBoxForDir(%q) error = %v", tt.dir, err)
+		}
+		if got != tt.expected {
+			t.Errorf("FindBoxForDir(%q) = %q, want %q", tt.dir, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadGlobalConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		expected *GlobalConfig
+		wantErr  bool
+	}{
+		{
+			name:     "missing file",
+			files:    map[string]string{},
+			expected: &GlobalConfig{},
+		},
+		{
+			name: "full config",
+			files: map[string]string{".tape.yml": `dotfiles-repository: https://github.com/acme/dotfiles
+docker-host: tcp://devbox:2375
+idle-timeout: 1h
+devcontainer-image: ghcr.io/acme/devcontainer-cli:latest
+execution-strategy: local-binary
+disable-telemetry: true
+log-level: debug
+editor: cursor
+`},
+			expected: &GlobalConfig{
+				DotfilesRepository: "https://github.com/acme/dotfiles",
+				DockerHost:         "tcp://devbox:2375",
+				IdleTimeout:        "1h",
+				DevcontainerImage:  "ghcr.io/acme/devcontainer-cli:latest",
+				ExecutionStrategy:  "local-binary",
+				DisableTelemetry:   true,
+				LogLevel:           "debug",
+				Editor:             "cursor",
+			},
+		},
+		{
+			name:    "invalid log level",
+			files:   map[string]string{".tape.yml": "log-level: verbose\n"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid execution strategy",
+			files:   map[string]string{".tape.yml": "execution-strategy: remote\n"},
+			wantErr: true,
+		},
+		{
+			name:     "retention interval",
+			files:    map[string]string{".tape.yml": "retention:\n  interval: 24h\n"},
+			expected: &GlobalConfig{Retention: RetentionPolicy{Interval: "24h"}},
+		},
+		{
+			name:    "invalid retention interval",
+			files:   map[string]string{".tape.yml": "retention:\n  interval: daily\n"},
+			wantErr: true,
+		},
+		{
+			name:     "native execution strategy",
+			files:    map[string]string{".tape.yml": "execution-strategy: native\n"},
+			expected: &GlobalConfig{ExecutionStrategy: ExecutionStrategyNative},
+		},
+		{
+			name:    "invalid editor",
+			files:   map[string]string{".tape.yml": "editor: emacs\n"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid idle timeout",
+			files:   map[string]string{".tape.yml": "idle-timeout: forever\n"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown field",
+			files:   map[string]string{".tape.yml": "log-levl: debug\n"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupConfigDir(t, tt.files)
+
+			got, err := LoadGlobalConfig()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadGlobalConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("LoadGlobalConfig() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadBoxConfigGlobalDefaults(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		".tape.yml":  "docker-host: tcp://devbox:2375\nidle-timeout: 1h\n",
+		"box.yml":    "workspace: /src/app\n",
+		"custom.yml": "workspace: /src/app\ndocker-host: tcp://other:2375\nidle-timeout: 5m\n",
+	})
+
+	config, err := LoadBoxConfig("box")
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	if config.DockerHost != "tcp://devbox:2375" || config.IdleTimeout != "1h" {
+		t.Errorf("LoadBoxConfig() = %+v, want the global defaults", config)
+	}
+
+	config, err = LoadBoxConfig("custom")
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	if config.DockerHost != "tcp://other:2375" || config.IdleTimeout != "5m" {
+		t.Errorf("LoadBoxConfig() = %+v, want the box's own settings", config)
+	}
+}
+
+func TestLoadBoxConfigHost(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		".tape.yml":  "host: dev@devbox\n",
+		"box.yml":    "workspace: /src/app\n",
+		"remote.yml": "workspace: /src/app\nhost: ssh://dev@gpubox:2222\n",
+		"both.yml":   "workspace: /src/app\nhost: devbox\ndocker-host: tcp://devbox:2375\n",
+	})
+
+	tests := []struct {
+		name       string
+		override   string
+		dockerHost string
+		wantErr    bool
+	}{
+		{name: "box", dockerHost: "ssh://dev@devbox"},
+		{name: "remote", dockerHost: "ssh://dev@gpubox:2222"},
+		{name: "remote", override: "other", dockerHost: "ssh://other"},
+		{name: "both", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+tt.override, func(t *testing.T) {
+			HostOverride = tt.override
+			t.Cleanup(func() { HostOverride = "" })
+
+			config, err := LoadBoxConfig(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadBoxConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && config.DockerHost != tt.dockerHost {
+				t.Errorf("LoadBoxConfig() DockerHost = %q, want %q", config.DockerHost, tt.dockerHost)
+			}
+		})
+	}
+}
+
+func TestContainerWorkspaceFolder(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"default/devcontainer.json": `{"image": "ubuntu"}`,
+		"custom/devcontainer.json":  `{"image": "ubuntu", "workspaceFolder": "/src"}`,
+	})
+
+	config := BoxConfig{Workspace: "/home/dev/app", Config: filepath.Join(ConfigDir, "default/devcontainer.json")}
+	if got, err := config.ContainerWorkspaceFolder(); err != nil || got != "/workspaces/app" {
+		t.Errorf("ContainerWorkspaceFolder() = %v, %v, want /workspaces/app", got, err)
+	}
+
+	config.Config = filepath.Join(ConfigDir, "custom/devcontainer.json")
+	if got, err := config.ContainerWorkspaceFolder(); err != nil || got != "/src" {
+		t.Errorf("ContainerWorkspaceFolder() = %v, %v, want /src", got, err)
+	}
+}
+
+func TestAttachedContainerURI(t *testing.T) {
+	got := AttachedContainerURI("box", "/workspaces/app")
+	expected := "vscode-remote://attached-container+626f78/workspaces/app"
+	if got != expected {
+		t.Errorf("AttachedContainerURI() = %v, want %v", got, expected)
+	}
+}
+
+func TestConfiguredPorts(t *testing.T) {
+	got := configuredPorts([]string{"8080", "5433:5432", "53:53/udp", "127.0.0.1:3000:3000", "[::1]:9230:9229", "127.0.0.1::8081"})
+	expected := []BoxPort{
+		{ContainerPort: 53, Protocol: "udp", HostPort: 53},
+		{ContainerPort: 3000, Protocol: "tcp", HostPort: 3000},
+		{ContainerPort: 5432, Protocol: "tcp", HostPort: 5433},
+		{ContainerPort: 9229, Protocol: "tcp", HostPort: 9230},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("configuredPorts() = %v, want %v", got, expected)
+	}
+}
+
+func TestPublishedPorts(t *testing.T) {
+	got := publishedPorts([]container.PortBinding{
+		{ContainerPort: 8080, Protocol: "tcp", HostIP: "0.0.0.0", HostPort: 32768},
+		{ContainerPort: 8080, Protocol: "tcp", HostIP: "::", HostPort: 32768},
+		{ContainerPort: 53, Protocol: "udp", HostIP: "0.0.0.0", HostPort: 5353},
+	})
+	expected := []BoxPort{
+		{ContainerPort: 53, Protocol: "udp", HostPort: 5353},
+		{ContainerPort: 8080, Protocol: "tcp", HostPort: 32768},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("publishedPorts() = %v, want %v", got, expected)
+	}
+}
+
+func TestResolveBuildPaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     devcontainer.DevContainerConfig
+		dockerfile string
+		context    string
+		paths      []string
+	}{
+		{
+			name:   "image",
+			config: devcontainer.DevContainerConfig{Image: "ubuntu"},
+		},
+		{
+			name:       "build with default context",
+			config:     devcontainer.DevContainerConfig{Build: &devcontainer.BuildOptions{Dockerfile: "Dockerfile"}},
+			dockerfile: "/src/app/.devcontainer/Dockerfile",
+			context:    "/src/app/.devcontainer",
+			paths:      []string{"/src/app/.devcontainer", "/src/app/.devcontainer"},
+		},
+		{
+			name:       "build with context outside the config dir",
+			config:     devcontainer.DevContainerConfig{Build: &devcontainer.BuildOptions{Dockerfile: "../docker/Dockerfile", Context: "../.."}},
+			dockerfile: "/src/app/docker/Dockerfile",
+			context:    "/src",
+			paths:      []string{"/src/app/docker", "/src"},
+		},
+		{
+			name:       "legacy dockerFile",
+			config:     devcontainer.DevContainerConfig{DockerFile: "/opt/Dockerfile", Context: ".."},
+			dockerfile: "/opt/Dockerfile",
+			context:    "/src/app",
+			paths:      []string{"/opt", "/src/app"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			paths := resolveBuildPaths(&config, "/src/app/.devcontainer")
+
+			if !reflect.DeepEqual(paths, tt.paths) {
+				t.Errorf("resolveBuildPaths() = %v, want %v", paths, tt.paths)
+			}
+
+			dockerfile, context := config.DockerFile, config.Context
+			if config.Build != nil {
+				dockerfile, context = config.Build.Dockerfile, config.Build.Context
+			}
+			if dockerfile != tt.dockerfile || context != tt.context {
+				t.Errorf("resolveBuildPaths() set dockerfile %q and context %q, want %q and %q", dockerfile, context, tt.dockerfile, tt.context)
+			}
+		})
+	}
+}
+
+func TestMinimalMounts(t *testing.T) {
+	got := minimalMounts([]string{"/src/app", "/src/app/.devcontainer", "/src/app-other/", "/opt", "/src/app"})
+	expected := []string{"/opt", "/src/app", "/src/app-other"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("minimalMounts() = %v, want %v", got, expected)
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu"}
+	first, err := configHash(config)
+	if err != nil {
+		t.Fatalf("configHash() error = %v", err)
+	}
+	if len(first) != 12 {
+		t.Errorf("configHash() = %v, want 12 characters", first)
+	}
+
+	config.Image = "debian"
+	second, err := configHash(config)
+	if err != nil {
+		t.Fatalf("configHash() error = %v", err)
+	}
+	if first == second {
+		t.Errorf("configHash() = %v for different configs", first)
+	}
+}
+
+func TestHashChanged(t *testing.T) {
+	effective := &devcontainer.DevContainerConfig{
+		RunArgs: []string{"--cpus", "2", "--label", EnvLabel + "=app", "--label", ConfigHashLabel + "=abc123"},
+	}
+
+	tests := []struct {
+		name     string
+		labels   map[string]string
+		expected bool
+	}{
+		{"same hash", map[string]string{ConfigHashLabel: "abc123"}, false},
+		{"different hash", map[string]string{ConfigHashLabel: "def456"}, true},
+		{"no hash label", map[string]string{EnvLabel: "app"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hashChanged(tt.labels, effective); got != tt.expected {
+				t.Errorf("hashChanged() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPreferAdopted(t *testing.T) {
+	containers := []container.Container{{ID: "a"}, {ID: "b"}, {ID: "c"}}
+
+	preferAdopted(containers, "c")
+	if got := []string{containers[0].ID, containers[1].ID, containers[2].ID}; !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Errorf("preferAdopted() = %v, want [c a b]", got)
+	}
+
+	preferAdopted(containers, "missing")
+	if containers[0].ID != "c" {
+		t.Errorf("preferAdopted() reordered containers for an unknown ID")
+	}
+}
+
+func TestLoadBoxConfigNotFound(t *testing.T) {
+	setupConfigDir(t, map[string]string{})
+
+	_, err := LoadBoxConfig("missing")
+	if !errors.Is(err, ErrConfigNotFound) {
+		t.Errorf("LoadBoxConfig() error = %v, want ErrConfigNotFound", err)
+	}
+}
+
+func TestCheckContainerName(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"team/app.yml": "workspace: /src/team/app\n",
+		"team-app.yml": "workspace: /src/team-app\n",
+		"team/web.yml": "workspace: /src/team/web\n",
+	})
+
+	if err := checkContainerName(BoxConfig{Name: "team/app"}); err == nil {
+		t.Errorf("checkContainerName(team/app) didn't fail, team-app has the same container name")
+	}
+	if err := checkContainerName(BoxConfig{Name: "team/web"}); err != nil {
+		t.Errorf("checkContainerName(team/web) error = %v", err)
+	}
+}
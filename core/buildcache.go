@@ -0,0 +1,198 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// buildCache returns the --cache-from sources and the --cache-to destination
+// of a config that builds a Dockerfile: the box's, and the team's cache in
+// GlobalConfig.BuildCache, tagged with the config's name so boxes of the same
+// project share it whatever they're called. The team's cache is only
+// exported to with GlobalConfig.BuildCacheExport. Nothing is returned for
+// configs that don't build one. The config's own build.cacheFrom is passed by
+// the devcontainer CLI.
+func buildCache(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, globalConfig *GlobalConfig) ([]string, string) {
+	if !buildsDockerfile(config) {
+		return nil, ""
+	}
+	from := slices.Clone(boxConfig.Build.CacheFrom)
+	to := boxConfig.Build.CacheTo
+	if globalConfig.BuildCache != "" {
+		ref := "type=registry,ref=" + globalConfig.BuildCache + ":" + buildCacheTag(boxConfig, config)
+		from = append(from, ref)
+		if to == "" && globalConfig.BuildCacheExport {
+			to = ref + ",mode=max"
+		}
+	}
+	return from, to
+}
+
+// exportableCache returns the cache destination when the builder the
+// devcontainer CLI uses can export to it. The docker driver, the default
+// builder of Docker Engine, only embeds caches in the image with type=inline.
+// The CLI's container only has the default builder.
+func exportableCache(boxConfig BoxConfig, strategy executionStrategy, to string) string {
+	if to == "" || slices.Contains(strings.Split(to, ","), "type=inline") {
+		return to
+	}
+	driver := "docker"
+	if strategy.runsOnHost() {
+		driver = buildxDriver(boxConfig)
+	}
+	if driver == "docker" || driver == "" {
+		fmt.Fprintf(Stdout, "Not exporting the build cache to %s, the buildx builder's %q driver can't export caches\n", to, driver)
+		return ""
+	}
+	return to
+}
+
+// buildxDriver returns the driver of the current buildx builder on the
+// box's docker host, "" when it can't be determined, e.g. without buildx
+func buildxDriver(boxConfig BoxConfig) string {
+	cmd := exec.Command("docker", "buildx", "inspect")
+	cmd.Env = os.Environ()
+	if boxConfig.DockerHost != "" {
+		cmd.Env = append(cmd.Env, "DOCKER_HOST="+boxConfig.DockerHost)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return parseBuildxDriver(string(out))
+}
+
+// parseBuildxDriver reads the Driver line of docker buildx inspect
+func parseBuildxDriver(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if driver, found := strings.CutPrefix(strings.TrimSpace(line), "Driver:"); found {
+			return strings.TrimSpace(driver)
+		}
+	}
+	return ""
+}
+
+// buildCacheTag names a project's cache in the team's cache repository
+func buildCacheTag(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) string {
+	name := config.Name
+	if name == "" {
+		name = filepath.Base(boxConfig.Workspace)
+	}
+	if tag := hostnameLabel(name); tag != "" {
+		return tag
+	}
+	return "latest"
+}
+
+func buildsDockerfile(config *devcontainer.DevContainerConfig) bool {
+	return config != nil && (config.DockerFile != "" || config.Build != nil && config.Build.Dockerfile != "")
+}
+
+// buildCacheArgs returns the devcontainer CLI arguments for the build cache
+func buildCacheArgs(from []string, to string) []string {
+	var args []string
+	for _, source := range from {
+		args = append(args, "--cache-from", source)
+	}
+	if to != "" {
+		args = append(args, "--cache-to", to)
+	}
+	return args
+}
+
+// configCacheImages returns the images of the config's build.cacheFrom, a
+// string or a list
+func configCacheImages(config *devcontainer.DevContainerConfig) []string {
+	if config == nil || config.Build == nil {
+		return nil
+	}
+	switch v := config.Build.CacheFrom.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var images []string
+		for _, image := range v {
+			if s, ok := image.(string); ok {
+				images = append(images, s)
+			}
+		}
+		return images
+	}
+	return nil
+}
+
+// cacheRef returns the image of a cache source or destination: the image
+// itself, or the ref of a registry cache. Other kinds of caches, e.g.
+// type=local, have none.
+func cacheRef(cache string) string {
+	if !strings.Contains(cache, "=") {
+		return cache
+	}
+	options := strings.Split(cache, ",")
+	if !slices.Contains(options, "type=registry") {
+		return ""
+	}
+	for _, option := range options {
+		if ref, found := strings.CutPrefix(option, "ref="); found {
+			return ref
+		}
+	}
+	return ""
+}
+
+// buildCacheRegistries returns the registries the build's caches are read
+// from and written to, which need credentials
+func buildCacheRegistries(from []string, to string) []string {
+	var registries []string
+	for _, cache := range append(slices.Clone(from), to) {
+		if ref := cacheRef(cache); ref != "" {
+			registries = append(registries, container.ImageRegistry(ref))
+		}
+	}
+	return registries
+}
+
+// pullCacheImages pulls the images given as plain cache sources, which
+// builds without BuildKit only use when they exist locally. Registry caches
+// are read by BuildKit itself. A missing cache only makes the build slower,
+// e.g. before the first build has pushed it.
+func pullCacheImages(boxConfig BoxConfig, images []string) error {
+	var pulls []string
+	for _, image := range images {
+		if image != "" && !strings.Contains(image, "=") {
+			pulls = append(pulls, image)
+		}
+	}
+	if len(pulls) == 0 {
+		return nil
+	}
+
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	for _, image := range pulls {
+		exists, err := cli.HasImage(ctx, image)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := pullImage(ctx, cli, boxConfig, image); err != nil {
+			fmt.Fprintf(Stdout, "Not using the build cache %s: %v\n", image, err)
+		}
+	}
+	return nil
+}
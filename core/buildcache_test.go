@@ -0,0 +1,100 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestBuildCache(t *testing.T) {
+	dockerfile := &devcontainer.DevContainerConfig{Name: "Acme API", Build: &devcontainer.BuildOptions{Dockerfile: "Dockerfile"}}
+
+	tests := []struct {
+		name         string
+		boxConfig    BoxConfig
+		config       *devcontainer.DevContainerConfig
+		globalConfig GlobalConfig
+		expectedFrom []string
+		expectedTo   string
+	}{
+		{
+			name:      "image config",
+			boxConfig: BoxConfig{Build: BoxBuild{CacheFrom: []string{"ghcr.io/acme/api:cache"}}},
+			config:    &devcontainer.DevContainerConfig{Image: "ubuntu"},
+		},
+		{
+			name:         "team cache",
+			boxConfig:    BoxConfig{Workspace: "/src/api"},
+			config:       dockerfile,
+			globalConfig: GlobalConfig{BuildCache: "ghcr.io/acme/cache"},
+			expectedFrom: []string{"type=registry,ref=ghcr.io/acme/cache:acme-api"},
+		},
+		{
+			name:         "team cache export",
+			boxConfig:    BoxConfig{Workspace: "/src/api"},
+			config:       dockerfile,
+			globalConfig: GlobalConfig{BuildCache: "ghcr.io/acme/cache", BuildCacheExport: true},
+			expectedFrom: []string{"type=registry,ref=ghcr.io/acme/cache:acme-api"},
+			expectedTo:   "type=registry,ref=ghcr.io/acme/cache:acme-api,mode=max",
+		},
+		{
+			name:         "box cache over the team's",
+			boxConfig:    BoxConfig{Workspace: "/src/api", Build: BoxBuild{CacheFrom: []string{"ghcr.io/acme/api:cache"}, CacheTo: "type=inline"}},
+			config:       &devcontainer.DevContainerConfig{DockerFile: "Dockerfile"},
+			globalConfig: GlobalConfig{BuildCache: "ghcr.io/acme/cache", BuildCacheExport: true},
+			expectedFrom: []string{"ghcr.io/acme/api:cache", "type=registry,ref=ghcr.io/acme/cache:api"},
+			expectedTo:   "type=inline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := buildCache(tt.boxConfig, tt.config, &tt.globalConfig)
+			if !reflect.DeepEqual(from, tt.expectedFrom) || to != tt.expectedTo {
+				t.Errorf("buildCache() = %v, %q, want %v, %q", from, to, tt.expectedFrom, tt.expectedTo)
+			}
+		})
+	}
+}
+
+func TestExportableCache(t *testing.T) {
+	// the devcontainer CLI's container only has docker's default builder
+	strategy := containerStrategy{}
+	if to := exportableCache(BoxConfig{}, strategy, "type=registry,ref=ghcr.io/acme/cache:api,mode=max"); to != "" {
+		t.Errorf("exportableCache() = %q, want no export with the docker driver", to)
+	}
+	if to := exportableCache(BoxConfig{}, strategy, "type=inline"); to != "type=inline" {
+		t.Errorf("exportableCache() = %q, want type=inline", to)
+	}
+}
+
+func TestParseBuildxDriver(t *testing.T) {
+	output := "Name:          ci\nDriver:        docker-container\nLast Activity: 2024-05-01\n\nNodes:\nName:      ci0\n"
+	if driver := parseBuildxDriver(output); driver != "docker-container" {
+		t.Errorf("parseBuildxDriver() = %q, want docker-container", driver)
+	}
+	if driver := parseBuildxDriver(""); driver != "" {
+		t.Errorf("parseBuildxDriver() = %q, want none", driver)
+	}
+}
+
+func TestBuildCacheRegistries(t *testing.T) {
+	from := []string{"ghcr.io/acme/api:cache", "type=local,src=/tmp/cache", "type=registry,ref=quay.io/acme/cache:api"}
+	registries := buildCacheRegistries(from, "type=inline")
+	expected := []string{"ghcr.io", "quay.io"}
+	if !reflect.DeepEqual(registries, expected) {
+		t.Errorf("buildCacheRegistries() = %v, want %v", registries, expected)
+	}
+}
+
+func TestConfigCacheImages(t *testing.T) {
+	config, err := devcontainer.ParseDevContainer([]byte(`{"build": {"dockerfile": "Dockerfile", "cacheFrom": ["ghcr.io/acme/api:a", "ghcr.io/acme/api:b"]}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ghcr.io/acme/api:a", "ghcr.io/acme/api:b"}
+	if images := configCacheImages(config); !reflect.DeepEqual(images, expected) {
+		t.Errorf("configCacheImages() = %v, want %v", images, expected)
+	}
+}
@@ -0,0 +1,146 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// BoxBuild configures the BuildKit build of a box's Dockerfile. Secrets and
+// SSH keys are only available to the build steps that mount them, e.g.
+// RUN --mount=type=secret,id=npmrc, and aren't stored in the image. The
+// cache options are applied along with GlobalConfig.BuildCache, see buildCache.
+type BoxBuild struct {
+	// Secrets are BuildKit secrets by id, looked up by reference like the
+	// box's secrets, e.g. npmrc: file://~/.npmrc, see ResolveSecret
+	Secrets map[string]string `yaml:"secrets,omitempty" validate:"dive,keys,required,endkeys,required,contains=://"`
+	// SSH are BuildKit SSH sockets or keys, as given to docker build --ssh,
+	// e.g. default for the SSH agent, or github=~/.ssh/id_ed25519
+	SSH []string `yaml:"ssh,omitempty" validate:"dive,required"`
+	// CacheFrom are images or docker --cache-from sources the build reuses
+	// layers from, in addition to the devcontainer config's build.cacheFrom
+	CacheFrom []string `yaml:"cache-from,omitempty" validate:"dive,required"`
+	// CacheTo is where the build's cache is exported, as docker --cache-to,
+	// e.g. type=inline or type=registry,ref=ghcr.io/acme/cache:app
+	CacheTo string `yaml:"cache-to,omitempty"`
+}
+
+// sshAgentSocket is where the SSH agent is mounted into the devcontainer
+// CLI's container, see containerStrategy
+const sshAgentSocket = "/tmp/ssh-agent.sock"
+
+// buildSecretOptions adds the box's build secrets and SSH sockets to the
+// docker build options of a config that builds a Dockerfile. The secrets are
+// written to files in a directory only the user can read, so their values
+// stay out of the config and the CLI's environment. It returns that
+// directory, which the caller removes after the build, and the directories
+// of the SSH keys. translate maps host paths to where the CLI reads them.
+func buildSecretOptions(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, translate func(string) string) (string, []string, error) {
+	build := boxConfig.Build
+	if len(build.Secrets) == 0 && len(build.SSH) == 0 {
+		return "", nil, nil
+	}
+	if !buildsDockerfile(config) {
+		// compose and image configs have nothing to build with them
+		return "", nil, nil
+	}
+	if config.Build == nil {
+		config.Build = &devcontainer.BuildOptions{}
+	}
+
+	secretsDir, err := writeBuildSecrets(build.Secrets)
+	if err != nil {
+		return "", nil, err
+	}
+	for i, id := range slices.Sorted(maps.Keys(build.Secrets)) {
+		src := translate(filepath.Join(secretsDir, strconv.Itoa(i)))
+		config.Build.Options = append(config.Build.Options, "--secret", fmt.Sprintf("id=%s,src=%s", id, src))
+	}
+
+	var keyPaths []string
+	for _, ssh := range build.SSH {
+		id, paths, found := strings.Cut(ssh, "=")
+		if !found {
+			config.Build.Options = append(config.Build.Options, "--ssh", ssh)
+			continue
+		}
+		var resolved []string
+		for _, path := range strings.Split(paths, ",") {
+			path, err := expandHome(path)
+			if err != nil {
+				os.RemoveAll(secretsDir)
+				return "", nil, err
+			}
+			resolved = append(resolved, translate(path))
+			keyPaths = append(keyPaths, filepath.Dir(path))
+		}
+		config.Build.Options = append(config.Build.Options, "--ssh", id+"="+strings.Join(resolved, ","))
+	}
+	return secretsDir, keyPaths, nil
+}
+
+// writeBuildSecrets resolves the build's secrets into a new directory, each
+// secret in a file named by its position in the sorted ids. The directory is
+// created with mode 0700 and the files with 0600. It returns "" when there
+// are no secrets.
+func writeBuildSecrets(secrets map[string]string) (string, error) {
+	if len(secrets) == 0 {
+		return "", nil
+	}
+	dir, err := os.MkdirTemp("", "tape-build-secrets-*")
+	if err != nil {
+		return "", fmt.Errorf("error creating build secrets directory: %v", err)
+	}
+	for i, id := range slices.Sorted(maps.Keys(secrets)) {
+		secret, err := ResolveSecret(context.Background(), secrets[id])
+		if err == nil {
+			err = os.WriteFile(filepath.Join(dir, strconv.Itoa(i)), []byte(secret), 0600)
+		}
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// usesSSHAgent reports whether the build forwards the SSH agent, with --ssh
+// default or another id without keys
+func (b BoxBuild) usesSSHAgent() bool {
+	return slices.ContainsFunc(b.SSH, func(ssh string) bool { return !strings.Contains(ssh, "=") })
+}
+
+// sshAgentBind returns the bind mount of the host's SSH agent into a
+// container. Docker Desktop can't mount host sockets, it forwards the agent
+// on its own socket instead.
+func sshAgentBind() (string, error) {
+	if runtime.GOOS == "darwin" {
+		return "/run/host-services/ssh-auth.sock:" + sshAgentSocket, nil
+	}
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return "", fmt.Errorf("the build uses the SSH agent, but SSH_AUTH_SOCK is not set")
+	}
+	return socket + ":" + sshAgentSocket, nil
+}
+
+// expandHome expands a leading ~/ to the home directory
+func expandHome(path string) (string, error) {
+	rest, found := strings.CutPrefix(path, "~/")
+	if !found {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, rest), nil
+}
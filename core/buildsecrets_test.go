@@ -0,0 +1,69 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestBuildSecretOptions(t *testing.T) {
+	t.Setenv("TAPE_TEST_NPM_TOKEN", "s3cret")
+	t.Setenv("HOME", "/home/dev")
+
+	boxConfig := BoxConfig{Build: BoxBuild{
+		Secrets: map[string]string{"npm-token": "env://TAPE_TEST_NPM_TOKEN"},
+		SSH:     []string{"default", "github=~/.ssh/id_ed25519"},
+	}}
+	config := &devcontainer.DevContainerConfig{Build: &devcontainer.BuildOptions{Dockerfile: "Dockerfile"}}
+
+	secretsDir, keyPaths, err := buildSecretOptions(boxConfig, config, func(path string) string { return "/host" + path })
+	if err != nil {
+		t.Fatalf("buildSecretOptions() error = %v", err)
+	}
+	defer os.RemoveAll(secretsDir)
+
+	expectedOptions := []string{
+		"--secret", "id=npm-token,src=/host" + filepath.Join(secretsDir, "0"),
+		"--ssh", "default",
+		"--ssh", "github=/host/home/dev/.ssh/id_ed25519",
+	}
+	if !reflect.DeepEqual(config.Build.Options, expectedOptions) {
+		t.Errorf("build options = %v, want %v", config.Build.Options, expectedOptions)
+	}
+	if strings.Contains(strings.Join(config.Build.Options, " "), "s3cret") {
+		t.Errorf("build options = %v contain the secret", config.Build.Options)
+	}
+	if info, err := os.Stat(secretsDir); err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("secrets directory = %v, %v, want mode 0700", info, err)
+	}
+	secretFile := filepath.Join(secretsDir, "0")
+	if info, err := os.Stat(secretFile); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("secret file = %v, %v, want mode 0600", info, err)
+	}
+	if data, _ := os.ReadFile(secretFile); string(data) != "s3cret" {
+		t.Errorf("secret file contains %q, want s3cret", data)
+	}
+	if !reflect.DeepEqual(keyPaths, []string{"/home/dev/.ssh"}) {
+		t.Errorf("buildSecretOptions() key paths = %v, want [/home/dev/.ssh]", keyPaths)
+	}
+	if !boxConfig.Build.usesSSHAgent() {
+		t.Errorf("usesSSHAgent() = false, want true")
+	}
+}
+
+func TestBuildSecretOptionsWithoutDockerfile(t *testing.T) {
+	boxConfig := BoxConfig{Build: BoxBuild{SSH: []string{"default"}}}
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu"}
+
+	secretsDir, _, err := buildSecretOptions(boxConfig, config, DockerPath)
+	if err != nil {
+		t.Fatalf("buildSecretOptions() error = %v", err)
+	}
+	if secretsDir != "" || config.Build != nil {
+		t.Errorf("buildSecretOptions() changed an image config: %q, %+v", secretsDir, config.Build)
+	}
+}
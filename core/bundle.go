@@ -0,0 +1,279 @@
+package core
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// a bundle is a gzipped tar with the box YAML, a manifest, and the
+// devcontainer definition under bundleDevcontainerDir
+const (
+	bundleManifestFile    = "manifest.json"
+	bundleBoxConfigFile   = "box.yml"
+	bundleDevcontainerDir = "devcontainer"
+)
+
+type BundleManifest struct {
+	Name string `json:"name"`
+	// Config is the devcontainer config's path within the devcontainer directory
+	Config        string `json:"config"`
+	PrebuiltImage string `json:"prebuiltImage,omitempty"`
+}
+
+// ExportBundle writes a portable bundle of the box's definition. When image is
+// empty the box's prebuilt image, if any, is recorded instead.
+func ExportBundle(envName string, w io.Writer, image string) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	if image == "" {
+		image = boxConfig.PrebuiltImage
+	}
+
+	files, root, err := devcontainerFiles(*boxConfig)
+	if err != nil {
+		return err
+	}
+
+	relConfig, err := filepath.Rel(root, boxConfig.Config)
+	if err != nil {
+		return fmt.Errorf("error resolving config path: %v", err)
+	}
+
+	manifest, err := json.MarshalIndent(BundleManifest{
+		Name:          envName,
+		Config:        filepath.ToSlash(relConfig),
+		PrebuiltImage: image,
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing manifest: %v", err)
+	}
+
+	boxYaml, err := os.ReadFile(BoxConfigPath(envName))
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	if err := writeTarFile(tarWriter, bundleManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := writeTarFile(tarWriter, bundleBoxConfigFile, boxYaml); err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", file, err)
+		}
+
+		rel, err := filepath.Rel(root, file)
+		if err != nil {
+			return fmt.Errorf("error resolving %s: %v", file, err)
+		}
+
+		if err := writeTarFile(tarWriter, bundleDevcontainerDir+"/"+filepath.ToSlash(rel), content); err != nil {
+			return err
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("error writing bundle: %v", err)
+	}
+	return gzipWriter.Close()
+}
+
+// devcontainerFiles returns the files that make up the box's devcontainer
+// definition and the directory they are relative to. A .devcontainer directory
+// is included wholesale, otherwise just the config and its Dockerfile.
+func devcontainerFiles(boxConfig BoxConfig) ([]string, string, error) {
+	configDir := filepath.Dir(boxConfig.Config)
+
+	if filepath.Base(configDir) == ".devcontainer" {
+		var files []string
+		err := filepath.WalkDir(configDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("error reading %s: %v", configDir, err)
+		}
+		return files, configDir, nil
+	}
+
+	files := []string{boxConfig.Config}
+
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, "", fmt.Errorf("error loading config: %v", err)
+	}
+
+	dockerfile := config.DockerFile
+	if config.Build != nil && config.Build.Dockerfile != "" {
+		dockerfile = config.Build.Dockerfile
+	}
+	if dockerfile != "" {
+		path := filepath.Join(configDir, dockerfile)
+		if !strings.HasPrefix(path, configDir+string(filepath.Separator)) {
+			return nil, "", fmt.Errorf("dockerfile %s is outside of %s", dockerfile, configDir)
+		}
+		files = append(files, path)
+	}
+
+	return files, configDir, nil
+}
+
+func writeTarFile(tarWriter *tar.Writer, name string, content []byte) error {
+	header := &tar.Header{
+		Name: name,
+		Mode: 0644,
+		Size: int64(len(content)),
+	}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return fmt.Errorf("error writing tar header: %v", err)
+	}
+	if _, err := tarWriter.Write(content); err != nil {
+		return fmt.Errorf("error writing %s to bundle: %v", name, err)
+	}
+	return nil
+}
+
+// bundleHostSettings are the box settings that run commands on, or choose, the
+// host a box runs on. A bundle can come from anyone, so they aren't imported.
+var bundleHostSettings = []string{"hooks", "host", "docker-host"}
+
+// ImportBundle installs a bundle as a new box. The devcontainer definition is
+// extracted under ConfigDir/.bundles. name and workspace override the values
+// stored in the bundle when set, and bundleHostSettings are dropped. Returns
+// the installed environment name.
+func ImportBundle(r io.Reader, name string, workspace string) (string, error) {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return "", fmt.Errorf("error reading bundle: %v", err)
+	}
+	defer gzipReader.Close()
+
+	var manifest *BundleManifest
+	var boxYaml []byte
+	definitionFiles := map[string][]byte{}
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("error reading bundle: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return "", fmt.Errorf("error reading %s from bundle: %v", header.Name, err)
+		}
+
+		switch {
+		case header.Name == bundleManifestFile:
+			manifest = &BundleManifest{}
+			if err := json.Unmarshal(content, manifest); err != nil {
+				return "", fmt.Errorf("error parsing manifest: %v", err)
+			}
+		case header.Name == bundleBoxConfigFile:
+			boxYaml = content
+		case strings.HasPrefix(header.Name, bundleDevcontainerDir+"/"):
+			rel := filepath.FromSlash(strings.TrimPrefix(header.Name, bundleDevcontainerDir+"/"))
+			if !filepath.IsLocal(rel) {
+				return "", fmt.Errorf("bundle contains invalid path %s", header.Name)
+			}
+			definitionFiles[rel] = content
+		}
+	}
+
+	if manifest == nil || boxYaml == nil {
+		return "", fmt.Errorf("not a tape bundle: missing %s or %s", bundleManifestFile, bundleBoxConfigFile)
+	}
+
+	if name == "" {
+		name = manifest.Name
+	}
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return "", fmt.Errorf("invalid environment name %s", name)
+	}
+
+	if _, err := os.Stat(BoxConfigPath(name)); err == nil {
+		return "", fmt.Errorf("environment %s already exists", name)
+	}
+
+	bundleDir := filepath.Join(".bundles", name)
+	for rel, content := range definitionFiles {
+		path := filepath.Join(ConfigDir, bundleDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", fmt.Errorf("error creating %s: %v", filepath.Dir(path), err)
+		}
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			return "", fmt.Errorf("error writing %s: %v", path, err)
+		}
+	}
+
+	// rewrite the box config to point at the extracted devcontainer definition
+	var boxConfig yaml.MapSlice
+	if err := yaml.Unmarshal(boxYaml, &boxConfig); err != nil {
+		return "", fmt.Errorf("error parsing YAML: %v", err)
+	}
+	boxConfig = slices.DeleteFunc(boxConfig, func(item yaml.MapItem) bool {
+		key, _ := item.Key.(string)
+		return slices.Contains(bundleHostSettings, key)
+	})
+	boxConfig = setMapSliceValue(boxConfig, "config", filepath.Join(bundleDir, filepath.FromSlash(manifest.Config)))
+	if workspace != "" {
+		boxConfig = setMapSliceValue(boxConfig, "workspace", workspace)
+	}
+	if manifest.PrebuiltImage != "" {
+		boxConfig = setMapSliceValue(boxConfig, "prebuilt-image", manifest.PrebuiltImage)
+	}
+
+	out, err := yaml.Marshal(boxConfig)
+	if err != nil {
+		return "", fmt.Errorf("error serializing YAML: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(BoxConfigPath(name)), 0755); err != nil {
+		return "", fmt.Errorf("error creating config directory: %v", err)
+	}
+	if err := os.WriteFile(BoxConfigPath(name), out, 0644); err != nil {
+		return "", fmt.Errorf("error writing config file: %v", err)
+	}
+
+	return name, nil
+}
+
+func setMapSliceValue(slice yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range slice {
+		if item.Key == key {
+			slice[i].Value = value
+			return slice
+		}
+	}
+	return append(slice, yaml.MapItem{Key: key, Value: value})
+}
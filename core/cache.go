@@ -0,0 +1,196 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+const CacheLabel = "tape.cache" // used to label cache volumes with their preset
+
+// CachePreset is a toolchain cache shared between environments through a named volume
+type CachePreset struct {
+	Name string
+	// Target is where the volume is mounted in the container
+	Target string
+	// Env points the toolchain at Target
+	Env map[string]string
+}
+
+// CachePresets are the caches boxes can enable with caches: [...]
+var CachePresets = []CachePreset{
+	{Name: "go", Target: "/tape-cache/go", Env: map[string]string{
+		"GOMODCACHE": "/tape-cache/go/mod",
+		"GOCACHE":    "/tape-cache/go/build",
+	}},
+	{Name: "node", Target: "/tape-cache/npm", Env: map[string]string{
+		"npm_config_cache": "/tape-cache/npm",
+	}},
+	{Name: "pip", Target: "/tape-cache/pip", Env: map[string]string{
+		"PIP_CACHE_DIR": "/tape-cache/pip",
+	}},
+	// CARGO_HOME also holds installed binaries, so only the registry is shared
+	{Name: "cargo", Target: "/usr/local/cargo/registry"},
+	{Name: "maven", Target: "/tape-cache/maven", Env: map[string]string{
+		"MAVEN_OPTS": "-Dmaven.repo.local=/tape-cache/maven",
+	}},
+}
+
+// CacheNames returns the names of the cache presets
+func CacheNames() []string {
+	names := make([]string, len(CachePresets))
+	for i, preset := range CachePresets {
+		names[i] = preset.Name
+	}
+	return names
+}
+
+func cachePreset(name string) (CachePreset, bool) {
+	for _, preset := range CachePresets {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return CachePreset{}, false
+}
+
+// CacheVolumeName returns the volume shared by all boxes using a cache preset
+func CacheVolumeName(name string) string {
+	return "tape-cache-" + name
+}
+
+// cacheOverrides returns the mounts and env for the box's caches. Env the
+// devcontainer config sets itself is left alone.
+func cacheOverrides(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) ([]devcontainer.MountValue, map[string]string) {
+	var mounts []devcontainer.MountValue
+	env := map[string]string{}
+	for _, name := range boxConfig.Caches {
+		preset, ok := cachePreset(name)
+		if !ok {
+			continue
+		}
+		mounts = append(mounts, devcontainer.NewMountString(fmt.Sprintf(
+			"source=%s,target=%s,type=volume,volume-label=%s=%s",
+			CacheVolumeName(name), preset.Target, CacheLabel, name)))
+		for key, value := range preset.Env {
+			if _, ok := config.ContainerEnv[key]; !ok {
+				env[key] = value
+			}
+		}
+	}
+	return mounts, env
+}
+
+// prepareCacheVolumes makes the box's cache volumes writable by every user,
+// since docker creates them owned by root and boxes run as different users
+func prepareCacheVolumes(boxConfig BoxConfig) error {
+	var targets []string
+	for _, name := range boxConfig.Caches {
+		if preset, ok := cachePreset(name); ok {
+			targets = append(targets, preset.Target)
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+
+	dc, err := FindDevContainer(boxConfig)
+	if err != nil {
+		return err
+	}
+
+	result, err := dc.Exec(context.Background(), container.ExecConfig{
+		Command: append([]string{"chmod", "1777", "--"}, targets...),
+		User:    "root",
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("error preparing cache volumes: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+	return nil
+}
+
+// CacheVolume is a cache volume and the boxes configured to use it
+type CacheVolume struct {
+	container.Volume
+	Cache string
+	Boxes []string
+}
+
+// ListCaches returns the cache volumes on the default docker host
+func ListCaches() ([]CacheVolume, error) {
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	volumes, err := cli.ListVolumes(context.Background(), []string{CacheLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+	boxesByCache := map[string][]string{}
+	for _, envName := range envNames {
+		boxConfig, err := LoadBoxConfig(envName)
+		if err != nil {
+			continue
+		}
+		for _, name := range boxConfig.Caches {
+			boxesByCache[name] = append(boxesByCache[name], envName)
+		}
+	}
+
+	caches := make([]CacheVolume, len(volumes))
+	for i, volume := range volumes {
+		name := volume.Labels[CacheLabel]
+		caches[i] = CacheVolume{Volume: volume, Cache: name, Boxes: boxesByCache[name]}
+	}
+	slices.SortFunc(caches, func(a, b CacheVolume) int {
+		return strings.Compare(a.Cache, b.Cache)
+	})
+	return caches, nil
+}
+
+// ClearCaches removes the cache volumes for the named presets, or all cache
+// volumes if names is empty. Volumes in use by a container can't be removed.
+func ClearCaches(names []string) ([]string, error) {
+	for _, name := range names {
+		if _, ok := cachePreset(name); !ok {
+			return nil, fmt.Errorf("unknown cache %s, expected one of %v", name, CacheNames())
+		}
+	}
+
+	caches, err := ListCaches()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	var removed []string
+	for _, cache := range caches {
+		if len(names) > 0 && !slices.Contains(names, cache.Cache) {
+			continue
+		}
+		if err := cli.RemoveVolume(context.Background(), cache.Name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, cache.Cache)
+	}
+	return removed, nil
+}
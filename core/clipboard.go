@@ -0,0 +1,165 @@
+package core
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// OSC 52 sets the terminal's clipboard, ESC ] 52 ; <selection> ; <base64> BEL.
+// tmux wraps it in its passthrough, with the inner escapes doubled, when
+// set-clipboard is off or it runs inside another terminal multiplexer.
+const (
+	osc52Prefix     = "\x1b]52;"
+	tmuxOSC52Prefix = "\x1bPtmux;\x1b\x1b]52;"
+)
+
+// maxClipboardSequence bounds how much output is held back waiting for the
+// end of a clipboard sequence, longer ones are passed on as they are
+const maxClipboardSequence = 1 << 20
+
+// clipboardBridge passes output on to w, replacing the OSC 52 sequences in
+// it with what its copy function returns for their text
+type clipboardBridge struct {
+	w    io.Writer
+	copy func(text []byte) []byte
+	// pending is the start of a sequence split across writes
+	pending []byte
+}
+
+// NewClipboardBridge returns a writer passing output on to w, with the OSC 52
+// clipboard sequences programs like tmux and vim write replaced by what copy
+// returns for the copied text. Queries of the clipboard are passed on.
+func NewClipboardBridge(w io.Writer, copy func(text []byte) []byte) io.Writer {
+	return &clipboardBridge{w: w, copy: copy}
+}
+
+func (b *clipboardBridge) Write(p []byte) (int, error) {
+	data := append(b.pending, p...)
+	b.pending = nil
+
+	var out bytes.Buffer
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, 0x1b)
+		if i < 0 {
+			out.Write(data)
+			break
+		}
+		out.Write(data[:i])
+		data = data[i:]
+
+		n, text, complete := parseOSC52(data)
+		switch {
+		case n == 0:
+			b.pending = bytes.Clone(data)
+			data = nil
+		case complete && text != nil:
+			out.Write(b.copy(text))
+			data = data[n:]
+		case complete:
+			out.Write(data[:n])
+			data = data[n:]
+		default:
+			// not a clipboard sequence
+			out.WriteByte(0x1b)
+			data = data[1:]
+		}
+	}
+	if _, err := b.w.Write(out.Bytes()); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// parseOSC52 parses the clipboard sequence data starts with, returning its
+// length and copied text. n is 0 for a sequence that isn't complete yet, and
+// -1 for data that isn't a clipboard sequence. Complete sequences that don't
+// copy text, e.g. queries, return a nil text.
+func parseOSC52(data []byte) (n int, text []byte, complete bool) {
+	prefix, terminators := osc52Prefix, []string{"\a", "\x1b\\"}
+	if bytes.HasPrefix(data, []byte(tmuxOSC52Prefix)) || bytes.HasPrefix([]byte(tmuxOSC52Prefix), data) {
+		prefix, terminators = tmuxOSC52Prefix, []string{"\a\x1b\\", "\x1b\x1b\\\x1b\\"}
+	}
+	if len(data) < len(prefix) {
+		if bytes.HasPrefix([]byte(prefix), data) {
+			return 0, nil, false
+		}
+		return -1, nil, false
+	}
+	if !bytes.HasPrefix(data, []byte(prefix)) {
+		return -1, nil, false
+	}
+
+	payload := data[len(prefix):]
+	end, terminator := -1, ""
+	for _, t := range terminators {
+		if i := bytes.Index(payload, []byte(t)); i >= 0 && (end < 0 || i < end) {
+			end, terminator = i, t
+		}
+	}
+	if end < 0 {
+		if len(data) >= maxClipboardSequence {
+			return -1, nil, false
+		}
+		return 0, nil, false
+	}
+	n = len(prefix) + end + len(terminator)
+
+	_, encoded, _ := bytes.Cut(payload[:end], []byte(";"))
+	text, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil || string(encoded) == "?" {
+		return n, nil, true
+	}
+	return n, text, true
+}
+
+// OSC52 returns the sequence that sets the terminal's clipboard to text
+func OSC52(text []byte) []byte {
+	return []byte(osc52Prefix + "c;" + base64.StdEncoding.EncodeToString(text) + "\a")
+}
+
+// HostClipboard copies text to the host's clipboard, handing it to the
+// terminal with OSC 52 when the host has no clipboard command, e.g. over SSH
+func HostClipboard(text []byte) []byte {
+	if err := copyToHostClipboard(text); err != nil {
+		return OSC52(text)
+	}
+	return nil
+}
+
+// copyToHostClipboard runs the host's clipboard command with text on its input
+func copyToHostClipboard(text []byte) error {
+	command := hostClipboardCommand()
+	if command == nil {
+		return fmt.Errorf("no clipboard command found")
+	}
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(text)
+	return cmd.Run()
+}
+
+// hostClipboardCommand returns the command that sets the host's clipboard
+// from its input, nil when there is none
+func hostClipboardCommand() []string {
+	var candidates [][]string
+	switch {
+	case runtime.GOOS == "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case runtime.GOOS == "windows" || InWSL():
+		candidates = [][]string{{"clip.exe"}}
+	case os.Getenv("WAYLAND_DISPLAY") != "":
+		candidates = [][]string{{"wl-copy"}}
+	case os.Getenv("DISPLAY") != "":
+		candidates = [][]string{{"xclip", "-selection", "clipboard"}, {"xsel", "--clipboard", "--input"}}
+	}
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err == nil {
+			return candidate
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestClipboardBridge(t *testing.T) {
+	tests := []struct {
+		name     string
+		writes   []string
+		expected string
+		copied   []string
+	}{
+		{
+			name:     "plain output",
+			writes:   []string{"hello \x1b[1mworld\x1b[0m\n"},
+			expected: "hello \x1b[1mworld\x1b[0m\n",
+		},
+		{
+			name:     "osc 52",
+			writes:   []string{"a\x1b]52;c;aGVsbG8=\ab"},
+			expected: "a[hello]b",
+			copied:   []string{"hello"},
+		},
+		{
+			name:     "split across writes",
+			writes:   []string{"a\x1b", "]52;c;aGVs", "bG8=\x1b\\b"},
+			expected: "a[hello]b",
+			copied:   []string{"hello"},
+		},
+		{
+			name:     "tmux passthrough",
+			writes:   []string{"\x1bPtmux;\x1b\x1b]52;c;aGVsbG8=\a\x1b\\"},
+			expected: "[hello]",
+			copied:   []string{"hello"},
+		},
+		{
+			name:     "query",
+			writes:   []string{"\x1b]52;c;?\a"},
+			expected: "\x1b]52;c;?\a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var copied []string
+			bridge := NewClipboardBridge(&out, func(text []byte) []byte {
+				copied = append(copied, string(text))
+				return []byte("[" + string(text) + "]")
+			})
+			for _, w := range tt.writes {
+				if n, err := bridge.Write([]byte(w)); err != nil || n != len(w) {
+					t.Fatalf("Write() = %d, %v", n, err)
+				}
+			}
+			if out.String() != tt.expected {
+				t.Errorf("output = %q, want %q", out.String(), tt.expected)
+			}
+			if len(copied) != len(tt.copied) || (len(copied) > 0 && copied[0] != tt.copied[0]) {
+				t.Errorf("copied = %q, want %q", copied, tt.copied)
+			}
+		})
+	}
+}
+
+func TestOSC52(t *testing.T) {
+	if got := string(OSC52([]byte("hello"))); got != "\x1b]52;c;aGVsbG8=\a" {
+		t.Errorf("OSC52() = %q", got)
+	}
+}
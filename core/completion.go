@@ -0,0 +1,60 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+	"time"
+)
+
+// completionTimeout bounds listing a directory in the container, so a slow
+// container doesn't hang the shell
+const completionTimeout = 3 * time.Second
+
+// CompleteBoxPath returns the paths in the box's container that start with
+// prefix, for shell completion. It lists the prefix's directory with ls as
+// the remote user. Directories end with a slash, and a relative prefix is
+// relative to the workspace folder.
+func CompleteBoxPath(envName string, prefix string) ([]string, error) {
+	boxConfig, dc, err := runningBoxContainer(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	dir := prefix[:strings.LastIndex(prefix, "/")+1]
+	listed := dir
+	if listed == "" {
+		listed = "."
+	}
+	config, err := execConfig(*boxConfig, ExecOptions{Command: []string{"ls", "-1Ap", "--", listed}})
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), completionTimeout)
+	defer cancel()
+	result, err := dc.Exec(ctx, config)
+	if err != nil {
+		return nil, err
+	}
+	// a directory that doesn't exist has nothing to complete
+	if result.ExitCode != 0 {
+		return nil, nil
+	}
+	return pathCandidates(dir, prefix, result.Stdout), nil
+}
+
+// pathCandidates returns the entries ls listed in dir, prefixed with dir,
+// that start with prefix
+func pathCandidates(dir string, prefix string, listing []byte) []string {
+	var candidates []string
+	scanner := bufio.NewScanner(bytes.NewReader(listing))
+	for scanner.Scan() {
+		candidate := dir + scanner.Text()
+		if scanner.Text() != "" && strings.HasPrefix(candidate, prefix) {
+			candidates = append(candidates, candidate)
+		}
+	}
+	return candidates
+}
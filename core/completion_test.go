@@ -0,0 +1,29 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPathCandidates(t *testing.T) {
+	listing := []byte("src/\nmain.go\n.env\nMakefile\n")
+	tests := []struct {
+		name     string
+		dir      string
+		prefix   string
+		expected []string
+	}{
+		{name: "directory", dir: "/app/", prefix: "/app/", expected: []string{"/app/src/", "/app/main.go", "/app/.env", "/app/Makefile"}},
+		{name: "partial name", dir: "/app/", prefix: "/app/m", expected: []string{"/app/main.go"}},
+		{name: "relative", dir: "", prefix: "s", expected: []string{"src/"}},
+		{name: "no match", dir: "/app/", prefix: "/app/x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pathCandidates(tt.dir, tt.prefix, listing); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("pathCandidates() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
@@ -1,30 +1,364 @@
 package core
 
 import (
+	"cmp"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
-	"gopkg.in/yaml.v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/mikeocool/tape/container"
 )
 
 type GlobalConfig struct {
-	DotfilesRepository string `yaml:"dotfiles-repository"`
+	DotfilesRepository string `yaml:"dotfiles-repository,omitempty"`
+	// DockerHost is the default docker daemon for boxes that don't set one
+	DockerHost string `yaml:"docker-host,omitempty" validate:"omitempty,uri"`
+	// Host is the default machine boxes run on, see BoxConfig.Host
+	Host string `yaml:"host,omitempty" validate:"excluded_with=DockerHost"`
+	// IdleTimeout is the default idle timeout for boxes that don't set one
+	IdleTimeout string `yaml:"idle-timeout,omitempty" validate:"omitempty,duration"`
+	// Runtime is the container runtime boxes run on, see RuntimeDocker
+	Runtime string `yaml:"runtime,omitempty" validate:"omitempty,oneof=docker containerd"`
+	// Containerd configures the containerd runtime
+	Containerd ContainerdConfig `yaml:"containerd,omitempty"`
+	// DevcontainerImage overrides the image used to run the devcontainer CLI
+	DevcontainerImage string `yaml:"devcontainer-image,omitempty"`
+	// DockerSocketProxy gives the devcontainer CLI's container the docker API
+	// through a proxy that only allows the endpoints the CLI uses, instead of
+	// mounting the docker socket. It narrows the API, e.g. no swarm, plugins
+	// or secrets, but isn't a sandbox: the CLI has to create containers and
+	// the proxy doesn't look at what they mount or whether they're
+	// privileged, so the CLI's container still amounts to root on the host.
+	DockerSocketProxy bool `yaml:"docker-socket-proxy,omitempty"`
+	// ExecutionStrategy is how the devcontainer CLI is run, see ExecutionStrategyContainer
+	ExecutionStrategy string `yaml:"execution-strategy,omitempty" validate:"omitempty,oneof=container local-binary native"`
+	// DisableTelemetry sets DO_NOT_TRACK for the devcontainer CLI and the
+	// docker CLI it runs, see devcontainerCliEnv
+	DisableTelemetry bool   `yaml:"disable-telemetry,omitempty"`
+	LogLevel         string `yaml:"log-level,omitempty" validate:"omitempty,oneof=debug info warn error"`
+	Editor           string `yaml:"editor,omitempty" validate:"omitempty,oneof=vscode cursor jetbrains"`
+	// Retention is enforced by tape prune --auto
+	Retention RetentionPolicy `yaml:"retention,omitempty"`
+	Daemon    DaemonConfig    `yaml:"daemon,omitempty"`
+	SSH       SSHServerConfig `yaml:"ssh,omitempty"`
+	Pull      PullConfig      `yaml:"pull,omitempty"`
+	// Notifications are sent when long operations finish or a container crashes
+	Notifications NotificationsConfig `yaml:"notifications,omitempty"`
+	// BuildCache is a registry repository the team shares Dockerfile build
+	// caches in, e.g. ghcr.io/acme/devcontainer-cache, see buildCache
+	BuildCache string `yaml:"build-cache,omitempty"`
+	// BuildCacheExport pushes builds' caches to BuildCache too, which needs
+	// push access and a buildx builder that can export caches, e.g. on CI.
+	// Otherwise the cache is only read.
+	BuildCacheExport bool `yaml:"build-cache-export,omitempty"`
+	// PolicyURL is where the organisation's policy is fetched from, see Policy
+	PolicyURL string `yaml:"policy-url,omitempty" validate:"omitempty,url"`
+	// Groups are named sets of boxes started together with tape up @name
+	Groups map[string][]string `yaml:"groups,omitempty" validate:"dive,keys,required,endkeys,min=1,dive,required"`
+	// Untrusted configures the sandbox of untrusted boxes, see BoxConfig.Untrusted
+	Untrusted UntrustedConfig `yaml:"untrusted,omitempty"`
+	// StateCacheTTL caches the CLI's box states on disk for this long, e.g.
+	// 5s, which speeds up ls and status with many containers when the
+	// daemon, which caches them in memory, isn't running. Containers changed
+	// outside tape may show a stale state until it expires.
+	StateCacheTTL string `yaml:"state-cache-ttl,omitempty" validate:"omitempty,duration"`
 }
 
+// StateCacheTTLDuration returns the parsed StateCacheTTL, or 0 if there is none
+func (g GlobalConfig) StateCacheTTLDuration() time.Duration {
+	duration, err := time.ParseDuration(g.StateCacheTTL)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// Container runtimes, set in the global config. With containerd, tape execs,
+// pauses and watches boxes through containerd's API and manages the rest with
+// nerdctl, and the devcontainer CLI has to be installed on the
+// host as its helper container would need the docker socket.
+const (
+	RuntimeDocker     = "docker"
+	RuntimeContainerd = "containerd"
+)
+
+// ContainerdConfig configures how tape reaches containerd
+type ContainerdConfig struct {
+	// Address is containerd's socket, container.DefaultContainerdAddress when
+	// unset
+	Address string `yaml:"address,omitempty"`
+	// Namespace is the containerd namespace boxes are created in, see
+	// container.DefaultContainerdNamespace
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+// Env returns the environment variables pointing nerdctl at containerd, for
+// the devcontainer CLI which runs it itself
+func (c ContainerdConfig) Env() []string {
+	env := []string{"CONTAINERD_NAMESPACE=" + cmp.Or(c.Namespace, container.DefaultContainerdNamespace)}
+	if c.Address != "" {
+		env = append(env, "CONTAINERD_ADDRESS="+c.Address)
+	}
+	return env
+}
+
+// UntrustedConfig configures the sandbox untrusted boxes run in
+type UntrustedConfig struct {
+	// AllowedHosts are the hosts untrusted boxes reach through the egress
+	// proxy, e.g. registry.npmjs.org, or *.example.com for its subdomains,
+	// see DefaultUntrustedAllowedHosts
+	AllowedHosts []string `yaml:"allowed-hosts,omitempty" validate:"dive,required,hostname_rfc1123|startswith=*."`
+	// UnsafeCommands are the lifecycle commands untrusted boxes don't run,
+	// besides initializeCommand, which runs on the host and never does
+	UnsafeCommands []string `yaml:"unsafe-commands,omitempty" validate:"dive,oneof=onCreateCommand updateContentCommand postCreateCommand postStartCommand postAttachCommand"`
+}
+
+// DefaultUntrustedAllowedHosts are the package registries and source hosts
+// untrusted boxes reach unless configured otherwise
+var DefaultUntrustedAllowedHosts = []string{
+	"github.com",
+	"*.github.com",
+	"*.githubusercontent.com",
+	"registry.npmjs.org",
+	"registry.yarnpkg.com",
+	"pypi.org",
+	"files.pythonhosted.org",
+	"proxy.golang.org",
+	"sum.golang.org",
+	"index.crates.io",
+	"static.crates.io",
+	"rubygems.org",
+	"repo.maven.apache.org",
+	"deb.debian.org",
+	"archive.ubuntu.com",
+	"security.ubuntu.com",
+	"dl-cdn.alpinelinux.org",
+}
+
+// AllowedHostsOrDefault returns the hosts untrusted boxes reach
+func (c UntrustedConfig) AllowedHostsOrDefault() []string {
+	if len(c.AllowedHosts) == 0 {
+		return DefaultUntrustedAllowedHosts
+	}
+	return c.AllowedHosts
+}
+
+// DefaultMetricsAddress is where tape daemon serves metrics unless configured otherwise
+const DefaultMetricsAddress = "127.0.0.1:9273"
+
+// DaemonConfig configures tape daemon
+type DaemonConfig struct {
+	// MetricsAddress is the host:port Prometheus metrics are served on, see DefaultMetricsAddress
+	MetricsAddress string `yaml:"metrics-address,omitempty" validate:"omitempty,hostname_port"`
+	// ProxyAddress is the host:port of the reverse proxy serving each box on
+	// http://<name>.localhost, e.g. 127.0.0.1:80. It's off when unset.
+	ProxyAddress string `yaml:"proxy-address,omitempty" validate:"omitempty,hostname_port"`
+	// AutoForward forwards the ports processes in running boxes listen on,
+	// following their onAutoForward
+	AutoForward bool `yaml:"auto-forward,omitempty"`
+}
+
+// DefaultSSHMaxSessions is how many SSH sessions an environment can have open
+// at once unless configured otherwise
+const DefaultSSHMaxSessions = 10
+
+// DefaultSSHListenAddress keeps the SSH server to connections from this host
+// unless configured otherwise
+const DefaultSSHListenAddress = "127.0.0.1"
+
+// SSHServerConfig configures tape's SSH server, see tape ssh
+type SSHServerConfig struct {
+	// MaxSessions is how many sessions an environment can have open at once,
+	// see DefaultSSHMaxSessions
+	MaxSessions int `yaml:"max-sessions,omitempty" validate:"omitempty,min=1"`
+	// IdleTimeout closes connections nothing was sent on for this long, e.g.
+	// 1h. Connections stay open when it's unset.
+	IdleTimeout string `yaml:"idle-timeout,omitempty" validate:"omitempty,duration"`
+	// ReadOnly are environments whose files can be read but not changed
+	// over SFTP, e.g. while sharing them with a teammate. Shells and
+	// commands still run.
+	ReadOnly []string `yaml:"read-only,omitempty"`
+	// ListenAddress is the address the server listens on, see
+	// DefaultSSHListenAddress. Teammates an environment is shared with need
+	// one they can reach, e.g. 0.0.0.0.
+	ListenAddress string `yaml:"listen-address,omitempty" validate:"omitempty,ip"`
+}
+
+// MaxSessionsOrDefault returns MaxSessions, defaulting to DefaultSSHMaxSessions
+func (c SSHServerConfig) MaxSessionsOrDefault() int {
+	if c.MaxSessions > 0 {
+		return c.MaxSessions
+	}
+	return DefaultSSHMaxSessions
+}
+
+// ListenAddressOrDefault returns ListenAddress, defaulting to
+// DefaultSSHListenAddress
+func (c SSHServerConfig) ListenAddressOrDefault() string {
+	if c.ListenAddress != "" {
+		return c.ListenAddress
+	}
+	return DefaultSSHListenAddress
+}
+
+// IdleTimeoutDuration returns the parsed IdleTimeout, 0 when connections
+// don't time out
+func (c SSHServerConfig) IdleTimeoutDuration() time.Duration {
+	duration, err := time.ParseDuration(c.IdleTimeout)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// RetentionPolicy configures what tape prune --auto removes
+type RetentionPolicy struct {
+	// StoppedAfter removes containers that have been stopped for longer than this, e.g. 720h
+	StoppedAfter string `yaml:"stopped-after,omitempty" validate:"omitempty,duration"`
+	// MaxSnapshots keeps at most this many snapshots per environment, removing the oldest
+	MaxSnapshots int `yaml:"max-snapshots,omitempty" validate:"omitempty,min=1"`
+	// Interval has tape daemon enforce the policy this often, e.g. 24h, as
+	// tape prune --auto does. The daemon doesn't prune when it's unset.
+	Interval string `yaml:"interval,omitempty" validate:"omitempty,duration"`
+}
+
+// NotificationsConfig configures the notifications sent when tape up or tape
+// upgrade finish, and when tape daemon sees a container crash
+type NotificationsConfig struct {
+	// Desktop shows notifications with osascript on macOS or notify-send on Linux
+	Desktop bool `yaml:"desktop,omitempty"`
+	// Webhook is a URL notifications are POSTed to as JSON
+	Webhook string `yaml:"webhook,omitempty" validate:"omitempty,url"`
+	// After is how long an operation must take to be notified, see DefaultNotifyAfter
+	After string `yaml:"after,omitempty" validate:"omitempty,duration"`
+}
+
+// Enabled reports whether any notifications are configured
+func (n NotificationsConfig) Enabled() bool {
+	return n.Desktop || n.Webhook != ""
+}
+
+// AfterDuration returns the parsed After, defaulting to DefaultNotifyAfter
+func (n NotificationsConfig) AfterDuration() time.Duration {
+	duration, err := time.ParseDuration(n.After)
+	if err != nil {
+		return DefaultNotifyAfter
+	}
+	return duration
+}
+
+// PullConfig configures how tape retries image pulls that fail with transient
+// registry errors, see container.DefaultRetryPolicy for the defaults
+type PullConfig struct {
+	// Attempts is how often a pull is tried in total, 1 disables retries
+	Attempts int `yaml:"attempts,omitempty" validate:"omitempty,min=1"`
+	// Backoff is the wait before the first retry, e.g. 2s, doubling with each one
+	Backoff string `yaml:"backoff,omitempty" validate:"omitempty,duration"`
+	// MaxBackoff caps the wait between retries
+	MaxBackoff string `yaml:"max-backoff,omitempty" validate:"omitempty,duration"`
+}
+
+// RetryPolicy returns the configured retry policy, defaulting unset fields
+func (p PullConfig) RetryPolicy() container.RetryPolicy {
+	policy := container.DefaultRetryPolicy
+	if p.Attempts > 0 {
+		policy.Attempts = p.Attempts
+	}
+	if duration, err := time.ParseDuration(p.Backoff); err == nil {
+		policy.Backoff = duration
+	}
+	if duration, err := time.ParseDuration(p.MaxBackoff); err == nil {
+		policy.MaxBackoff = duration
+	}
+	return policy
+}
+
+// StoppedAfterDuration returns the parsed StoppedAfter, or 0 if there is none
+func (r RetentionPolicy) StoppedAfterDuration() time.Duration {
+	duration, err := time.ParseDuration(r.StoppedAfter)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// IntervalDuration returns the parsed Interval, or 0 if there is none
+func (r RetentionPolicy) IntervalDuration() time.Duration {
+	duration, err := time.ParseDuration(r.Interval)
+	if err != nil {
+		return 0
+	}
+	return duration
+}
+
+// ValidateConfig validates the GlobalConfig using validator
+func (g *GlobalConfig) ValidateConfig() error {
+	validate := validator.New()
+	validate.RegisterValidation("duration", func(fl validator.FieldLevel) bool {
+		_, err := time.ParseDuration(fl.Field().String())
+		return err == nil
+	})
+	return validate.Struct(g)
+}
+
+// Debug reports whether debug output is enabled
+func (g *GlobalConfig) Debug() bool {
+	return g.LogLevel == "debug"
+}
+
+// GlobalConfigPath returns the path of the global config file
+func GlobalConfigPath() string {
+	return filepath.Join(ConfigDir, ".tape.yml")
+}
+
+// BinDir returns the directory for executables tape installs on the host,
+// which users add to their PATH
+func BinDir() string {
+	return filepath.Join(ConfigDir, "bin")
+}
+
+// DaemonSocketPath returns the unix socket tape daemon serves its API on. It's
+// in a directory only the user can access, so the socket is never reachable
+// by others, whatever the umask it's created with.
+func DaemonSocketPath() string {
+	return filepath.Join(ConfigDir, ".daemon", "daemon.sock")
+}
+
+// LoadGlobalConfig loads the global config. A missing file is treated as an empty config.
 func LoadGlobalConfig() (*GlobalConfig, error) {
-	configFile := filepath.Join(ConfigDir, ".tape.yml")
+	configFile := GlobalConfigPath()
 	yamlData, err := os.ReadFile(configFile)
+	if os.IsNotExist(err) {
+		config := &GlobalConfig{}
+		config.applyHost()
+		return config, nil
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error reading config file %s: %v", configFile, err)
 	}
 
 	var config GlobalConfig
-	if err := yaml.Unmarshal(yamlData, &config); err != nil {
-		return nil, fmt.Errorf("error parsing YAML: %v", err)
+	if err := unmarshalStrict(yamlData, &config); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", configFile, err)
 	}
 
-	// TODO validate config
+	if err := config.ValidateConfig(); err != nil {
+		return nil, fmt.Errorf("configuration validation failed: %v", err)
+	}
 
+	config.applyHost()
 	return &config, nil
 }
+
+// applyHost points DockerHost at the docker daemon of the configured host,
+// or the one given with tape --host
+func (g *GlobalConfig) applyHost() {
+	if HostOverride != "" {
+		g.Host = HostOverride
+	}
+	if g.Host != "" {
+		g.DockerHost = container.SSHHost(g.Host)
+	}
+}
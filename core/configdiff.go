@@ -0,0 +1,127 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// configDiffContext is how many unchanged lines surround each change
+const configDiffContext = 3
+
+// ConfigDiff returns a unified diff from the box's devcontainer.json on disk
+// to the effective config tape passes to the devcontainer CLI, with the box's
+// overrides, its lock and tape's labels applied. It's empty when they're the
+// same, and both are formatted alike so only their content differs.
+func ConfigDiff(envName string) (string, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return "", err
+	}
+	if boxConfig.Config == "" {
+		return "", fmt.Errorf("%s has no devcontainer config", envName)
+	}
+
+	onDisk, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return "", fmt.Errorf("error loading config: %v", err)
+	}
+	dc := DevcontainerCommand{BoxConfig: *boxConfig, Command: "up"}
+	effective, err := dc.effectiveConfig()
+	if err != nil {
+		return "", err
+	}
+	resolveBuildPaths(effective, filepath.Dir(boxConfig.Config))
+
+	from, err := json.MarshalIndent(onDisk, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	to, err := json.MarshalIndent(effective, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	return unifiedDiff(boxConfig.Config, "effective", strings.Split(string(from), "\n"), strings.Split(string(to), "\n"), configDiffContext), nil
+}
+
+// unifiedDiff returns the diff from one set of lines to another in the
+// unified format, with context unchanged lines around each hunk
+func unifiedDiff(fromName string, toName string, from []string, to []string, context int) string {
+	// lcs[i][j] is the length of the longest common subsequence of from[i:] and to[j:]
+	lcs := make([][]int, len(from)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if from[i] == to[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type edit struct {
+		op       byte
+		text     string
+		fromLine int
+		toLine   int
+	}
+	var edits []edit
+	i, j := 0, 0
+	for i < len(from) || j < len(to) {
+		switch {
+		case i < len(from) && j < len(to) && from[i] == to[j]:
+			edits = append(edits, edit{' ', from[i], i, j})
+			i++
+			j++
+		// removals come before additions, like diff -u
+		case i < len(from) && (j == len(to) || lcs[i+1][j] >= lcs[i][j+1]):
+			edits = append(edits, edit{'-', from[i], i, j})
+			i++
+		default:
+			edits = append(edits, edit{'+', to[j], i, j})
+			j++
+		}
+	}
+
+	var b strings.Builder
+	for start := 0; start < len(edits); {
+		if edits[start].op == ' ' {
+			start++
+			continue
+		}
+		// a hunk runs until more than twice the context separates two changes
+		end := start
+		for k := start; k < len(edits); k++ {
+			if edits[k].op != ' ' {
+				end = k + 1
+			} else if k-end >= 2*context {
+				break
+			}
+		}
+		first := max(start-context, 0)
+		last := min(end+context, len(edits))
+
+		if b.Len() == 0 {
+			fmt.Fprintf(&b, "--- %s\n+++ %s\n", fromName, toName)
+		}
+		var fromCount, toCount int
+		for _, e := range edits[first:last] {
+			if e.op != '+' {
+				fromCount++
+			}
+			if e.op != '-' {
+				toCount++
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", edits[first].fromLine+1, fromCount, edits[first].toLine+1, toCount)
+		for _, e := range edits[first:last] {
+			fmt.Fprintf(&b, "%c%s\n", e.op, e.text)
+		}
+		start = last
+	}
+	return b.String()
+}
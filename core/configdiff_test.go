@@ -0,0 +1,57 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	from := []string{"{", "  \"a\": 1,", "  \"b\": 2,", "  \"c\": 3,", "  \"d\": 4,", "  \"e\": 5,", "  \"f\": 6,", "  \"g\": 7,", "  \"h\": 8,", "  \"i\": 9", "}"}
+	to := []string{"{", "  \"a\": 10,", "  \"b\": 2,", "  \"c\": 3,", "  \"d\": 4,", "  \"e\": 5,", "  \"f\": 6,", "  \"g\": 7,", "  \"h\": 8,", "  \"i\": 9,", "  \"j\": 10", "}"}
+
+	expected := `--- a.json
++++ b.json
+@@ -1,5 +1,5 @@
+ {
+-  "a": 1,
++  "a": 10,
+   "b": 2,
+   "c": 3,
+   "d": 4,
+@@ -7,5 +7,6 @@
+   "f": 6,
+   "g": 7,
+   "h": 8,
+-  "i": 9
++  "i": 9,
++  "j": 10
+ }
+`
+	if diff := unifiedDiff("a.json", "b.json", from, to, 3); diff != expected {
+		t.Errorf("unifiedDiff() =\n%s\nwant\n%s", diff, expected)
+	}
+
+	if diff := unifiedDiff("a.json", "b.json", from, from, 3); diff != "" {
+		t.Errorf("unifiedDiff() of the same lines = %q, want empty", diff)
+	}
+}
+
+func TestConfigDiff(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"app.yml":                             "workspace: app\nenv:\n  FOO: bar\n",
+		"app/.devcontainer/devcontainer.json": `{"image": "ubuntu"}`,
+	})
+
+	diff, err := ConfigDiff("app")
+	if err != nil {
+		t.Fatalf("ConfigDiff() error = %v", err)
+	}
+	for _, want := range []string{`+    "FOO": "bar"`, `+    "--label",`} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("ConfigDiff() = %s, want it to contain %s", diff, want)
+		}
+	}
+	if strings.Contains(diff, `-  "image"`) {
+		t.Errorf("ConfigDiff() = %s, want the unchanged image left out", diff)
+	}
+}
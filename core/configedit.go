@@ -0,0 +1,347 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+	"gopkg.in/yaml.v2"
+)
+
+type ConfigKind int
+
+const (
+	ConfigKindBox ConfigKind = iota
+	ConfigKindDevcontainer
+	ConfigKindGlobal
+)
+
+// ConfigFile is a box's YAML config, its devcontainer.json, or the global
+// config, loaded as a generic document so edits preserve fields tape does not model
+type ConfigFile struct {
+	Path string
+	Kind ConfigKind
+	doc  interface{}
+	// raw is a devcontainer.json's text, edited in place alongside doc so
+	// saving keeps its comments and formatting
+	raw []byte
+}
+
+// LoadConfigFile loads the box's YAML config, or its devcontainer.json when devcontainer is set
+func LoadConfigFile(envName string, devcontainer bool) (*ConfigFile, error) {
+	if !devcontainer {
+		return loadConfigFile(BoxConfigPath(envName), ConfigKindBox)
+	}
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	return loadConfigFile(boxConfig.Config, ConfigKindDevcontainer)
+}
+
+// LoadGlobalConfigFile loads the global config, which may not exist yet
+func LoadGlobalConfigFile() (*ConfigFile, error) {
+	path := GlobalConfigPath()
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return &ConfigFile{Path: path, Kind: ConfigKindGlobal, doc: yaml.MapSlice{}}, nil
+	}
+	return loadConfigFile(path, ConfigKindGlobal)
+}
+
+func loadConfigFile(path string, kind ConfigKind) (*ConfigFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file %s: %v", path, err)
+	}
+
+	file := &ConfigFile{Path: path, Kind: kind}
+	if kind == ConfigKindDevcontainer {
+		var doc map[string]interface{}
+		if err := json.Unmarshal(devcontainer.StandardizeJSONC(data), &doc); err != nil {
+			return nil, fmt.Errorf("error parsing JSON: %v", err)
+		}
+		file.doc = doc
+		file.raw = data
+	} else {
+		var doc yaml.MapSlice
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("error parsing YAML: %v", err)
+		}
+		file.doc = doc
+	}
+
+	return file, nil
+}
+
+// Get returns the value at a dotted path such as customizations.vscode.extensions.0
+func (f *ConfigFile) Get(key string) (interface{}, error) {
+	value := f.doc
+	for _, part := range strings.Split(key, ".") {
+		next, ok := getChild(value, part)
+		if !ok {
+			return nil, fmt.Errorf("key %s not found", key)
+		}
+		value = next
+	}
+	return value, nil
+}
+
+// Set sets the value at a dotted path, creating intermediate objects as needed.
+// The value is parsed as YAML or JSON so numbers, booleans and lists keep their type.
+func (f *ConfigFile) Set(key string, rawValue string) error {
+	value := f.parseValue(rawValue)
+	path := strings.Split(key, ".")
+
+	doc, err := setPath(f.doc, path, value, f.Kind == ConfigKindDevcontainer)
+	if err != nil {
+		return fmt.Errorf("error setting %s: %v", key, err)
+	}
+	if f.Kind == ConfigKindDevcontainer {
+		raw, err := devcontainer.SetJSONC(f.raw, path, value)
+		if err != nil {
+			return fmt.Errorf("error setting %s: %v", key, err)
+		}
+		f.raw = raw
+	}
+	f.doc = doc
+	return nil
+}
+
+// Format renders a value the way it would appear in the config file
+func (f *ConfigFile) Format(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int, int64, float64, bool:
+		return fmt.Sprint(v), nil
+	}
+
+	if f.Kind == ConfigKindDevcontainer {
+		data, err := json.MarshalIndent(value, "", "  ")
+		return string(data), err
+	}
+	data, err := yaml.Marshal(value)
+	return strings.TrimSuffix(string(data), "\n"), err
+}
+
+// Save validates the edited document and writes it back to disk. A
+// devcontainer.json keeps its formatting and comments outside the edited values.
+func (f *ConfigFile) Save() error {
+	var data []byte
+	var err error
+	if f.Kind == ConfigKindDevcontainer {
+		data = f.raw
+	} else {
+		data, err = yaml.Marshal(f.doc)
+	}
+	if err != nil {
+		return fmt.Errorf("error serializing config: %v", err)
+	}
+
+	if err := ValidateConfigData(data, f.Kind); err != nil {
+		return err
+	}
+
+	return os.WriteFile(f.Path, data, 0644)
+}
+
+// ValidateConfigData checks that data is a valid config of the given kind
+func ValidateConfigData(data []byte, kind ConfigKind) error {
+	switch kind {
+	case ConfigKindDevcontainer:
+		if _, err := devcontainer.ParseDevContainer(devcontainer.StandardizeJSONC(data)); err != nil {
+			return fmt.Errorf("invalid devcontainer config: %v", err)
+		}
+		return nil
+	case ConfigKindGlobal:
+		var config GlobalConfig
+		if err := unmarshalStrict(data, &config); err != nil {
+			return fmt.Errorf("error parsing YAML: %v", err)
+		}
+		if err := config.ValidateConfig(); err != nil {
+			return fmt.Errorf("configuration validation failed: %v", err)
+		}
+		return nil
+	}
+
+	var config BoxConfig
+	if err := unmarshalStrict(data, &config); err != nil {
+		return fmt.Errorf("error parsing YAML: %v", err)
+	}
+	if err := config.ValidateConfig(); err != nil {
+		return fmt.Errorf("configuration validation failed: %v", err)
+	}
+	return nil
+}
+
+func (f *ConfigFile) parseValue(rawValue string) interface{} {
+	var value interface{}
+	if f.Kind == ConfigKindDevcontainer {
+		if err := json.Unmarshal([]byte(rawValue), &value); err != nil {
+			return rawValue
+		}
+		return value
+	}
+
+	if err := yaml.Unmarshal([]byte(rawValue), &value); err != nil || value == nil {
+		return rawValue
+	}
+	return value
+}
+
+func getChild(value interface{}, key string) (interface{}, bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, ok := v[key]
+		return child, ok
+	case yaml.MapSlice:
+		for _, item := range v {
+			if fmt.Sprint(item.Key) == key {
+				return item.Value, true
+			}
+		}
+	case map[interface{}]interface{}:
+		child, ok := v[key]
+		return child, ok
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err == nil && i >= 0 && i < len(v) {
+			return v[i], true
+		}
+	}
+	return nil, false
+}
+
+func setPath(value interface{}, path []string, newValue interface{}, devcontainer bool) (interface{}, error) {
+	if len(path) == 0 {
+		return newValue, nil
+	}
+	key := path[0]
+
+	if value == nil {
+		if devcontainer {
+			value = map[string]interface{}{}
+		} else {
+			value = yaml.MapSlice{}
+		}
+	}
+
+	child, _ := getChild(value, key)
+	updated, err := setPath(child, path[1:], newValue, devcontainer)
+	if err != nil {
+		return nil, err
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		v[key] = updated
+		return v, nil
+	case yaml.MapSlice:
+		for i, item := range v {
+			if fmt.Sprint(item.Key) == key {
+				v[i].Value = updated
+				return v, nil
+			}
+		}
+		return append(v, yaml.MapItem{Key: key, Value: updated}), nil
+	case map[interface{}]interface{}:
+		v[key] = updated
+		return v, nil
+	case []interface{}:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i > len(v) {
+			return nil, fmt.Errorf("invalid index %s", key)
+		}
+		if i == len(v) {
+			return append(v, updated), nil
+		}
+		v[i] = updated
+		return v, nil
+	default:
+		return nil, fmt.Errorf("cannot set %s on a %T", key, value)
+	}
+}
+
+// AddExtension adds a VS Code extension to a devcontainer config file
+func (f *ConfigFile) AddExtension(id string) (bool, error) {
+	return f.editCustomizations(func(config *devcontainer.DevContainerConfig) bool {
+		return config.AddExtension(id)
+	})
+}
+
+// RemoveExtension removes a VS Code extension from a devcontainer config file
+func (f *ConfigFile) RemoveExtension(id string) (bool, error) {
+	return f.editCustomizations(func(config *devcontainer.DevContainerConfig) bool {
+		return config.RemoveExtension(id)
+	})
+}
+
+func (f *ConfigFile) editCustomizations(edit func(*devcontainer.DevContainerConfig) bool) (bool, error) {
+	doc, ok := f.doc.(map[string]interface{})
+	if !ok {
+		return false, fmt.Errorf("extensions can only be changed in a devcontainer config")
+	}
+
+	// the customizations accessors edit the raw maps in place, so unknown keys survive
+	customizations, _ := doc["customizations"].(map[string]interface{})
+	previous, hadExtensions := extensionsList(customizations)
+	config := devcontainer.DevContainerConfig{Customizations: customizations}
+	changed := edit(&config)
+	doc["customizations"] = config.Customizations
+	if !changed {
+		return false, nil
+	}
+
+	extensions, _ := extensionsList(config.Customizations)
+	raw, err := editExtensionsJSONC(f.raw, previous, extensions, hadExtensions)
+	if err != nil {
+		return false, fmt.Errorf("error editing extensions: %v", err)
+	}
+	f.raw = raw
+	return true, nil
+}
+
+var extensionsPath = []string{"customizations", "vscode", "extensions"}
+
+// extensionsList returns customizations.vscode.extensions and whether it's set
+func extensionsList(customizations map[string]interface{}) ([]interface{}, bool) {
+	vscode, _ := customizations["vscode"].(map[string]interface{})
+	extensions, ok := vscode["extensions"].([]interface{})
+	return extensions, ok
+}
+
+// editExtensionsJSONC changes the extensions in a devcontainer.json's text
+// from previous to extensions, appending added ones and deleting removed
+// ones so the other entries' lines are left alone
+func editExtensionsJSONC(raw []byte, previous, extensions []interface{}, hadExtensions bool) ([]byte, error) {
+	if !hadExtensions {
+		return devcontainer.SetJSONC(raw, extensionsPath, extensions)
+	}
+	if len(extensions) > len(previous) {
+		var err error
+		for i := len(previous); i < len(extensions) && err == nil; i++ {
+			raw, err = devcontainer.SetJSONC(raw, append(extensionsPath, strconv.Itoa(i)), extensions[i])
+		}
+		return raw, err
+	}
+
+	// extensions keeps previous's order, so the entries it skips were removed
+	var removed []int
+	for i, j := 0, 0; i < len(previous); i++ {
+		if j < len(extensions) && reflect.DeepEqual(previous[i], extensions[j]) {
+			j++
+			continue
+		}
+		removed = append(removed, i)
+	}
+	var err error
+	for k := len(removed) - 1; k >= 0 && err == nil; k-- {
+		raw, err = devcontainer.DeleteJSONC(raw, append(extensionsPath, strconv.Itoa(removed[k])))
+	}
+	return raw, err
+}
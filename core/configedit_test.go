@@ -0,0 +1,332 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfigFileGet(t *testing.T) {
+	yamlDoc := yaml.MapSlice{
+		{Key: "workspace", Value: "/src/app"},
+		{Key: "resources", Value: yaml.MapSlice{{Key: "cpus", Value: "2"}}},
+		{Key: "ports", Value: []interface{}{"8080", "5433:5432"}},
+	}
+	jsonDoc := map[string]interface{}{
+		"customizations": map[string]interface{}{
+			"vscode": map[string]interface{}{"extensions": []interface{}{"golang.go"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		file     *ConfigFile
+		key      string
+		expected interface{}
+		wantErr  bool
+	}{
+		{name: "top level", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "workspace", expected: "/src/app"},
+		{name: "nested", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "resources.cpus", expected: "2"},
+		{name: "list index", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "ports.1", expected: "5433:5432"},
+		{name: "json nested", file: &ConfigFile{Kind: ConfigKindDevcontainer, doc: jsonDoc}, key: "customizations.vscode.extensions.0", expected: "golang.go"},
+		{name: "missing", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "resources.memory", wantErr: true},
+		{name: "missing intermediate", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "build.ssh", wantErr: true},
+		{name: "index out of range", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "ports.2", wantErr: true},
+		{name: "key of a scalar", file: &ConfigFile{Kind: ConfigKindBox, doc: yamlDoc}, key: "workspace.path", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.file.Get(tt.key)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Get(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Get(%q) = %#v, want %#v", tt.key, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSetPath(t *testing.T) {
+	tests := []struct {
+		name         string
+		doc          interface{}
+		path         string
+		value        interface{}
+		devcontainer bool
+		expected     interface{}
+		wantErr      bool
+	}{
+		{
+			name:     "replace",
+			doc:      yaml.MapSlice{{Key: "workspace", Value: "/src/app"}},
+			path:     "workspace",
+			value:    "/src/other",
+			expected: yaml.MapSlice{{Key: "workspace", Value: "/src/other"}},
+		},
+		{
+			name:     "append key",
+			doc:      yaml.MapSlice{{Key: "workspace", Value: "/src/app"}},
+			path:     "network",
+			value:    "shared",
+			expected: yaml.MapSlice{{Key: "workspace", Value: "/src/app"}, {Key: "network", Value: "shared"}},
+		},
+		{
+			name:  "missing intermediate maps",
+			doc:   yaml.MapSlice{},
+			path:  "resources.limits.cpus",
+			value: "2",
+			expected: yaml.MapSlice{{Key: "resources", Value: yaml.MapSlice{
+				{Key: "limits", Value: yaml.MapSlice{{Key: "cpus", Value: "2"}}},
+			}}},
+		},
+		{
+			name:         "missing intermediate json objects",
+			doc:          map[string]interface{}{},
+			path:         "customizations.vscode.settings",
+			value:        map[string]interface{}{},
+			devcontainer: true,
+			expected: map[string]interface{}{"customizations": map[string]interface{}{
+				"vscode": map[string]interface{}{"settings": map[string]interface{}{}},
+			}},
+		},
+		{
+			name:     "nested in existing map",
+			doc:      map[interface{}]interface{}{"resources": map[interface{}]interface{}{"cpus": "1"}},
+			path:     "resources.memory",
+			value:    "4g",
+			expected: map[interface{}]interface{}{"resources": map[interface{}]interface{}{"cpus": "1", "memory": "4g"}},
+		},
+		{
+			name:     "list index",
+			doc:      yaml.MapSlice{{Key: "ports", Value: []interface{}{"8080"}}},
+			path:     "ports.0",
+			value:    "9090",
+			expected: yaml.MapSlice{{Key: "ports", Value: []interface{}{"9090"}}},
+		},
+		{
+			name:     "list append",
+			doc:      yaml.MapSlice{{Key: "ports", Value: []interface{}{"8080"}}},
+			path:     "ports.1",
+			value:    "9090",
+			expected: yaml.MapSlice{{Key: "ports", Value: []interface{}{"8080", "9090"}}},
+		},
+		{
+			name:    "list index out of range",
+			doc:     yaml.MapSlice{{Key: "ports", Value: []interface{}{"8080"}}},
+			path:    "ports.3",
+			value:   "9090",
+			wantErr: true,
+		},
+		{
+			name:    "list key that isn't an index",
+			doc:     yaml.MapSlice{{Key: "ports", Value: []interface{}{"8080"}}},
+			path:    "ports.http",
+			value:   "9090",
+			wantErr: true,
+		},
+		{
+			name:    "key of a scalar",
+			doc:     yaml.MapSlice{{Key: "workspace", Value: "/src/app"}},
+			path:    "workspace.path",
+			value:   "/src/other",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := setPath(tt.doc, strings.Split(tt.path, "."), tt.value, tt.devcontainer)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("setPath(%q) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("setPath(%q) = %#v, want %#v", tt.path, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigFileSet(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     ConfigKind
+		key      string
+		value    string
+		expected interface{}
+		wantErr  bool
+	}{
+		{name: "yaml number", kind: ConfigKindBox, key: "resources.cpus", value: "2", expected: 2},
+		{name: "yaml list", kind: ConfigKindBox, key: "ports", value: "[8080, 9090]", expected: []interface{}{8080, 9090}},
+		{name: "yaml string", kind: ConfigKindBox, key: "network", value: "shared", expected: "shared"},
+		{name: "json bool", kind: ConfigKindDevcontainer, key: "overrideCommand", value: "false", expected: false},
+		{name: "json string that isn't json", kind: ConfigKindDevcontainer, key: "name", value: "my app", expected: "my app"},
+		{name: "key of a scalar", kind: ConfigKindBox, key: "workspace.path", value: "x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := &ConfigFile{Kind: tt.kind, doc: yaml.MapSlice{{Key: "workspace", Value: "/src/app"}}}
+			if tt.kind == ConfigKindDevcontainer {
+				file.doc = map[string]interface{}{"image": "ubuntu"}
+				file.raw = []byte(`{"image": "ubuntu"}`)
+			}
+			err := file.Set(tt.key, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Set(%q) error = %v, wantErr %v", tt.key, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got, err := file.Get(tt.key)
+			if err != nil {
+				t.Fatalf("Get(%q) after Set() error = %v", tt.key, err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Get(%q) after Set() = %#v, want %#v", tt.key, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigFileSave(t *testing.T) {
+	tests := []struct {
+		name     string
+		kind     ConfigKind
+		original string
+		key      string
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "box config keeps the key order",
+			kind:     ConfigKindBox,
+			original: "workspace: /src/app\nnetwork: shared\n",
+			key:      "resources.memory",
+			value:    "4g",
+			expected: "workspace: /src/app\nnetwork: shared\nresources:\n  memory: 4g\n",
+		},
+		{
+			name:     "invalid box config",
+			kind:     ConfigKindBox,
+			original: "workspace: /src/app\n",
+			key:      "resources.memory",
+			value:    "4 gigs",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown box key",
+			kind:     ConfigKindBox,
+			original: "workspace: /src/app\n",
+			key:      "wrokspace",
+			value:    "/src/other",
+			wantErr:  true,
+		},
+		{
+			name:     "global config",
+			kind:     ConfigKindGlobal,
+			original: "idle-timeout: 1h\n",
+			key:      "log-level",
+			value:    "loud",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "box.yml")
+			if err := os.WriteFile(path, []byte(tt.original), 0644); err != nil {
+				t.Fatal(err)
+			}
+			file, err := loadConfigFile(path, tt.kind)
+			if err != nil {
+				t.Fatalf("loadConfigFile() error = %v", err)
+			}
+			if err := file.Set(tt.key, tt.value); err != nil {
+				t.Fatalf("Set() error = %v", err)
+			}
+
+			err = file.Save()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Save() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			expected := tt.expected
+			if tt.wantErr {
+				// an invalid edit leaves the file alone
+				expected = tt.original
+			}
+			if string(data) != expected {
+				t.Errorf("Save() wrote %q, want %q", data, expected)
+			}
+		})
+	}
+}
+
+func TestConfigFileSaveDevcontainer(t *testing.T) {
+	original := `{
+    // built from the repo's Dockerfile
+    "build": {"dockerfile": "Dockerfile"},
+    "remoteUser": "node",
+    "customizations": {
+        "vscode": {
+            "extensions": [
+                "dbaeumer.vscode-eslint", // linting
+                "esbenp.prettier-vscode",
+            ]
+        }
+    }
+}
+`
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(path, []byte(original), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := loadConfigFile(path, ConfigKindDevcontainer)
+	if err != nil {
+		t.Fatalf("loadConfigFile() error = %v", err)
+	}
+
+	if err := file.Set("remoteUser", "vscode"); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if added, err := file.AddExtension("golang.go"); err != nil || !added {
+		t.Fatalf("AddExtension() = %v, %v", added, err)
+	}
+	if removed, err := file.RemoveExtension("dbaeumer.vscode-eslint"); err != nil || !removed {
+		t.Fatalf("RemoveExtension() = %v, %v", removed, err)
+	}
+	if err := file.Save(); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+    // built from the repo's Dockerfile
+    "build": {"dockerfile": "Dockerfile"},
+    "remoteUser": "vscode",
+    "customizations": {
+        "vscode": {
+            "extensions": [
+                "esbenp.prettier-vscode",
+                "golang.go"
+            ]
+        }
+    }
+}
+`
+	if string(data) != expected {
+		t.Errorf("Save() wrote\n%s\nwant\n%s", data, expected)
+	}
+	if value, err := file.Get("customizations.vscode.extensions.1"); err != nil || value != "golang.go" {
+		t.Errorf("Get() after AddExtension() = %v, %v", value, err)
+	}
+}
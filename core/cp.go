@@ -0,0 +1,142 @@
+package core
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// runningBoxContainer returns the box's config and its container, which has
+// to be running
+func runningBoxContainer(envName string) (*BoxConfig, *container.Container, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, nil, err
+	}
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := requireRunning(envName, dc); err != nil {
+		return nil, nil, err
+	}
+	return boxConfig, dc, nil
+}
+
+// containerPath makes a path in the container absolute, relative paths are
+// relative to the workspace folder
+func containerPath(boxConfig BoxConfig, p string) (string, error) {
+	if path.IsAbs(p) {
+		return path.Clean(p), nil
+	}
+	folder, err := boxConfig.ContainerWorkspaceFolder()
+	if err != nil {
+		return "", err
+	}
+	return path.Join(folder, p), nil
+}
+
+// CopyToBox copies a host file or directory into the directory dest in the
+// box's container, owned by the remote user. A relative dest is relative to
+// the workspace folder.
+func CopyToBox(envName string, src string, dest string) (err error) {
+	defer func() {
+		recordEvent(envName, "cp", fmt.Sprintf("%s %s:%s", src, envName, dest), err)
+	}()
+
+	boxConfig, dc, err := runningBoxContainer(envName)
+	if err != nil {
+		return err
+	}
+	dest, err = containerPath(*boxConfig, dest)
+	if err != nil {
+		return err
+	}
+	src = filepath.Clean(src)
+	if _, err := os.Lstat(src); err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(archivePath(writer, src))
+	}()
+	ctx := context.Background()
+	if err := dc.CopyTo(ctx, dest, reader); err != nil {
+		reader.CloseWithError(err)
+		return err
+	}
+
+	// the archive is extracted as root, hand the files to the remote user
+	config, err := execConfig(*boxConfig, ExecOptions{})
+	if err != nil {
+		return err
+	}
+	if config.User != "" && config.User != "root" {
+		copied := path.Join(dest, filepath.Base(src))
+		result, err := dc.Exec(ctx, container.ExecConfig{Command: []string{"chown", "-R", config.User + ":", "--", copied}, User: "root"})
+		if err != nil {
+			return err
+		}
+		if result.ExitCode != 0 {
+			return fmt.Errorf("error running chown in container: %s", strings.TrimSpace(string(result.Stderr)))
+		}
+	}
+	return nil
+}
+
+// archivePath writes an archive of a file or directory, named after its
+// base name
+func archivePath(w io.Writer, src string) error {
+	tw := tar.NewWriter(w)
+	parent := filepath.Dir(src)
+	err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+		return addToTar(tw, parent, rel, info)
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// CopyFromBox copies a file or directory in the box's container into the
+// host directory dest. A relative src is relative to the workspace folder.
+func CopyFromBox(envName string, src string, dest string) (err error) {
+	defer func() {
+		recordEvent(envName, "cp", fmt.Sprintf("%s:%s %s", envName, src, dest), err)
+	}()
+
+	boxConfig, dc, err := runningBoxContainer(envName)
+	if err != nil {
+		return err
+	}
+	src, err = containerPath(*boxConfig, src)
+	if err != nil {
+		return err
+	}
+
+	reader, err := dc.CopyFrom(context.Background(), src)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := os.MkdirAll(dest, 0755); err != nil {
+		return err
+	}
+	return extractArchive(reader, dest, false)
+}
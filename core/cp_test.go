@@ -0,0 +1,48 @@
+package core
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchivePath(t *testing.T) {
+	src := t.TempDir()
+	files := map[string]string{
+		filepath.Join("project", "main.go"):               "package main",
+		filepath.Join("project", "pkg", "util.go"):        "package pkg",
+		filepath.Join("project", "pkg", "nested", "a.go"): "package nested",
+		"notes.txt": "notes",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(src, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dest := t.TempDir()
+	for _, name := range []string{"project", "notes.txt"} {
+		var buf bytes.Buffer
+		if err := archivePath(&buf, filepath.Join(src, name)); err != nil {
+			t.Fatalf("archivePath(%s) error = %v", name, err)
+		}
+		if err := extractArchive(&buf, dest, false); err != nil {
+			t.Fatalf("extractArchive(%s) error = %v", name, err)
+		}
+	}
+
+	for name, expected := range files {
+		data, err := os.ReadFile(filepath.Join(dest, name))
+		if err != nil {
+			t.Errorf("%s was not copied: %v", name, err)
+			continue
+		}
+		if string(data) != expected {
+			t.Errorf("%s = %q, want %q", name, data, expected)
+		}
+	}
+}
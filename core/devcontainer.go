@@ -2,107 +2,326 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
+	"strings"
 
 	"github.com/mikeocool/tape/container"
-	"github.com/mikeocool/tape/devcontinaer"
+	"github.com/mikeocool/tape/devcontainer"
 )
 
 const DevContainerCliImage = "devcontainer:latest"
 
 const HostFolderLabel = "devcontainer.local_folder" // used to label containers created from a workspace/folder
 const ConfigFileLabel = "devcontainer.config_file"
+const IdleTimeoutLabel = "tape.idle-timeout" // the box's idle timeout when the container was created, see StopIdleBox
+
+// Labels tape sets on the containers it creates, so they can be told apart
+// from containers created by other devcontainer tools
+const EnvLabel = "tape.env"
+const ConfigHashLabel = "tape.config-hash" // hash of the effective devcontainer config
+const VersionLabel = "tape.version"
+const ConfigNameLabel = "tape.config-name" // the workspace config the container was created from, see BoxConfig.ConfigName
+const UntrustedLabel = "tape.untrusted"    // whether the container was created sandboxed, see BoxConfig.Untrusted
 
 // DevcontainerCommand represents a command to be executed against the devcontainer CLI
 type DevcontainerCommand struct {
 	BoxConfig      BoxConfig
 	Command        string
 	AdditionalArgs []string
+	// Image replaces the image or build in the devcontainer config when set
+	Image string
+	// ImageFromConfig marks Image as built from the devcontainer config, so
+	// the container is labeled with the config's hash as if up had built it
+	ImageFromConfig bool
+	// Labels are added to the container when it's created
+	Labels map[string]string
+	// SkipCreateCommands drops create-time lifecycle commands, for images that already ran them
+	SkipCreateCommands bool
+	// Secrets are variables for the lifecycle commands the CLI runs, passed
+	// in a file so they aren't on its command line, see writeSecretsFile
+	Secrets map[string]string
+
+	// lock is the lock the command builds with, resolved before the build so
+	// the lock file records what it used, see resolveLock. The box's lock
+	// file is used when it's nil.
+	lock *BoxLock
+	// buildSecretsDir holds the build's secrets, mounted read-only into the
+	// CLI's container, see buildSecretOptions
+	buildSecretsDir string
+	// sshAgent forwards the SSH agent to the CLI, for builds using it
+	sshAgent bool
+	// rootless is set when docker runs rootless, see rootlessOverrides
+	rootless bool
 }
 
-// Execute builds and runs the devcontainer command
+// Execute builds and runs the devcontainer command with the execution
+// strategy from the global config
 func (dc *DevcontainerCommand) Execute() error {
-	devConArgs := []string{"devcontainer", dc.Command, "--workspace-folder", dc.BoxConfig.Workspace}
-
-	// Add config path argument if needed
-	if dc.BoxConfig.Config != "" {
-		//devConArgs = append(devConArgs, "--config", dc.BoxConfig.Config)
-		devConArgs = append(devConArgs, "--config", "/tmp/devcontainer.json")
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
 	}
 
-	// Add any additional arguments
-	devConArgs = append(devConArgs, dc.AdditionalArgs...)
-
-	// Configure container binds for volumes
-	binds := []string{
-		"/var/run/docker.sock:/var/run/docker.sock",
-		fmt.Sprintf("%s:%s", dc.BoxConfig.Workspace, dc.BoxConfig.Workspace),
+	strategy, err := newExecutionStrategy(globalConfig)
+	if err != nil {
+		return err
 	}
 
-	// Optional config path binding
+	var (
+		configJSON []byte
+		auths      *container.DockerConfig
+	)
+	hostPaths := []string{dc.BoxConfig.Workspace}
 	if dc.BoxConfig.Config != "" {
+		config, err := dc.effectiveConfig()
+		if err != nil {
+			return err
+		}
+
+		// a CLI that isn't on the host would run initializeCommand in its own container
+		if dc.Command == "up" && !strategy.runsOnHost() {
+			if err := runInitializeCommand(config, dc.BoxConfig.Workspace); err != nil {
+				return err
+			}
+			config.InitializeCommand = nil
+		}
+
+		// the CLI reads a copy of the config, so paths can't stay relative to the original
 		configDir := filepath.Dir(dc.BoxConfig.Config)
-		binds = append(binds, fmt.Sprintf("%s:%s", configDir, configDir))
-		// TODO manage binding the Dockerfile
-		// the build path is relative to the config file
-		// if Dockerfile is in workspace -- maybe just mount the workspace?
-		// though need to handle cases where we need to modify the devcontainer config?
+		hostPaths = append(hostPaths, configDir)
+		hostPaths = append(hostPaths, resolveBuildPaths(config, configDir)...)
+		var cacheFrom []string
+		var cacheTo string
+		if dc.Command == "up" || dc.Command == "build" {
+			cacheFrom, cacheTo = buildCache(dc.BoxConfig, config, globalConfig)
+			cacheTo = exportableCache(dc.BoxConfig, strategy, cacheTo)
+		}
+		// read the Dockerfile's base images before its path is translated
+		auths, err = registryAuths(dc.BoxConfig, config, buildCacheRegistries(cacheFrom, cacheTo)...)
+		if err != nil {
+			return err
+		}
+		if dc.Command == "up" || dc.Command == "build" {
+			if err := enforcePolicies(dc.BoxConfig, config); err != nil {
+				return err
+			}
+			lock, err := dc.boxLock()
+			if err != nil {
+				return err
+			}
+			pinnedDir, err := pinLockedImages(config, lock)
+			if err != nil {
+				return err
+			}
+			if pinnedDir != "" {
+				defer os.RemoveAll(pinnedDir)
+				hostPaths = append(hostPaths, pinnedDir)
+			}
+			if err := pullMissingImages(dc.BoxConfig, config); err != nil {
+				return err
+			}
+			if err := pullCacheImages(dc.BoxConfig, append(configCacheImages(config), cacheFrom...)); err != nil {
+				return err
+			}
+			dc.AdditionalArgs = append(slices.Clip(dc.AdditionalArgs), buildCacheArgs(cacheFrom, cacheTo)...)
+
+			translate := func(path string) string { return path }
+			if !strategy.runsOnHost() {
+				translate = DockerPath
+			}
+			secretsDir, keyPaths, err := buildSecretOptions(dc.BoxConfig, config, translate)
+			if err != nil {
+				return err
+			}
+			if secretsDir != "" {
+				defer os.RemoveAll(secretsDir)
+			}
+			dc.buildSecretsDir = secretsDir
+			dc.sshAgent = config.Build != nil && dc.BoxConfig.Build.usesSSHAgent()
+			hostPaths = append(hostPaths, keyPaths...)
+		}
+		if !strategy.runsOnHost() {
+			dockerBuildPaths(config)
+		}
+
+		configJSON, err = json.MarshalIndent(config, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error serializing config to JSON: %v", err)
+		}
+
+		if globalConfig.Debug() {
+			fmt.Fprintf(Stdout, "Using devcontainer config:\n%s\n", string(configJSON))
+		}
 	}
 
-	cli, err := container.NewClient()
-	if err != nil {
-		return fmt.Errorf("error creating container client: %v", err)
+	if dc.BoxConfig.Config == "" && (dc.Command == "up" || dc.Command == "build") {
+		if err := enforcePolicies(dc.BoxConfig, nil); err != nil {
+			return err
+		}
 	}
-	defer cli.Close()
 
-	config := container.ContainerConfig{
-		Image:       DevContainerCliImage,
-		Command:     devConArgs,
-		Interactive: true,
-		Binds:       binds,
+	if auths == nil {
+		auths, err = registryAuths(dc.BoxConfig, nil)
+		if err != nil {
+			return err
+		}
 	}
-	ctx := context.Background()
-	devContainer, err := cli.CreateContainer(ctx, config)
+
+	return strategy.run(dc, configJSON, minimalMounts(hostPaths), auths)
+}
+
+// effectiveConfig loads the box's devcontainer config and applies tape's overrides
+func (dc *DevcontainerCommand) effectiveConfig() (*devcontainer.DevContainerConfig, error) {
+	config, err := LoadConfig(dc.BoxConfig.Config)
 	if err != nil {
-		return fmt.Errorf("error creating container: %v", err)
+		return nil, fmt.Errorf("error loading config: %v", err)
 	}
-
-	if dc.BoxConfig.Config != "" {
-		// Load the config file, modify it, and serialize it to JSON
-		config, err := LoadConfig(dc.BoxConfig.Config)
+	if dc.BoxConfig.Untrusted {
+		// before tape's overrides, which bind what the box's config asks for
+		stripHostBinds(config, dc.BoxConfig.Workspace)
+	}
+	config = EffectiveConfig(dc.BoxConfig, config)
+	if dc.BoxConfig.Untrusted {
+		globalConfig, err := LoadGlobalConfig()
 		if err != nil {
-			return fmt.Errorf("error loading config: %v", err)
+			return nil, err
 		}
-		overrideConfigValues(dc.BoxConfig, config)
+		sandboxConfig(config, untrustedNetworkName(dc.BoxConfig), globalConfig.Untrusted.UnsafeCommands)
+	}
+	hash, err := configHash(config)
+	if err != nil {
+		return nil, err
+	}
+	if dc.Image != "" {
+		useImage(config, dc.Image)
+	}
+	if dc.SkipCreateCommands {
+		config.OnCreateCommand = nil
+		config.UpdateContentCommand = nil
+		config.PostCreateCommand = nil
+	}
 
-		// Serialize the config to JSON
-		configJSON, err := json.MarshalIndent(config, "", "  ")
+	if !dc.ImageFromConfig {
+		hash, err = configHash(config)
 		if err != nil {
-			return fmt.Errorf("error serializing config to JSON: %v", err)
+			return nil, err
 		}
+	}
+
+	// the lock is left out of the hash, tape upgrade recreates containers when it changes
+	lock, err := dc.boxLock()
+	if err != nil {
+		return nil, err
+	}
+	if err := applyLock(config, lock); err != nil {
+		return nil, err
+	}
+	// like the lock, the daemon's mode and the host's locale are left out of the hash
+	if dc.rootless {
+		rootlessOverrides(config)
+	}
+	if !dc.BoxConfig.NoHostLocale {
+		applyHostLocale(dc.BoxConfig, config, hostLocaleEnv())
+	}
+	config.RunArgs = append(config.RunArgs, "--label", fmt.Sprintf("%s=%s", ConfigHashLabel, hash))
+	config.RunArgs = append(config.RunArgs, "--label", fmt.Sprintf("%s=%t", UntrustedLabel, dc.BoxConfig.Untrusted))
+	for _, key := range slices.Sorted(maps.Keys(dc.Labels)) {
+		config.RunArgs = append(config.RunArgs, "--label", fmt.Sprintf("%s=%s", key, dc.Labels[key]))
+	}
 
-		// TOOD only show this when debugging
-		fmt.Printf("Using devcontainer config:\n%s\n", string(configJSON))
+	return config, nil
+}
 
-		err = devContainer.CreateFile(ctx, "/tmp/devcontainer.json", configJSON)
-		if err != nil {
-			return fmt.Errorf("error creating config file: %v", err)
+// boxLock returns the lock the command builds with
+func (dc *DevcontainerCommand) boxLock() (*BoxLock, error) {
+	if dc.lock != nil {
+		return dc.lock, nil
+	}
+	return LoadLock(dc.BoxConfig)
+}
+
+// resolveBuildPaths rewrites the Dockerfile and build context in config to
+// absolute paths, resolving them relative to configDir the way the
+// devcontainer CLI does. It returns the host directories the build reads from.
+func resolveBuildPaths(config *devcontainer.DevContainerConfig, configDir string) []string {
+	resolve := func(path string) string {
+		if filepath.IsAbs(path) {
+			return filepath.Clean(path)
 		}
+		return filepath.Join(configDir, path)
 	}
 
-	err = devContainer.AttachAndRun(ctx, devConArgs)
-	if err != nil {
-		return fmt.Errorf("error attaching and running container: %v", err)
+	var paths []string
+	dockerfile, buildContext := &config.DockerFile, &config.Context
+	if config.Build != nil {
+		dockerfile, buildContext = &config.Build.Dockerfile, &config.Build.Context
 	}
 
-	return nil
+	if *dockerfile == "" {
+		return nil
+	}
+	*dockerfile = resolve(*dockerfile)
+	paths = append(paths, filepath.Dir(*dockerfile))
+
+	// the context defaults to the config's directory
+	if *buildContext == "" {
+		*buildContext = "."
+	}
+	*buildContext = resolve(*buildContext)
+	paths = append(paths, *buildContext)
+
+	return paths
+}
+
+// dockerBuildPaths converts the resolved Dockerfile and build context in
+// config to docker paths, for a CLI that sees host paths the way docker does
+func dockerBuildPaths(config *devcontainer.DevContainerConfig) {
+	paths := []*string{&config.DockerFile, &config.Context}
+	if config.Build != nil {
+		paths = append(paths, &config.Build.Dockerfile, &config.Build.Context)
+	}
+	for _, p := range paths {
+		if *p != "" {
+			*p = DockerPath(*p)
+		}
+	}
 }
 
-func LoadConfig(path string) (*devcontinaer.DevContainerConfig, error) {
+// minimalMounts returns the smallest set of directories that covers paths,
+// dropping any path inside another
+func minimalMounts(paths []string) []string {
+	cleaned := make([]string, len(paths))
+	for i, path := range paths {
+		cleaned[i] = filepath.Clean(path)
+	}
+	slices.Sort(cleaned)
+	cleaned = slices.Compact(cleaned)
+
+	var mounts []string
+	for _, path := range cleaned {
+		covered := false
+		for _, mount := range mounts {
+			if rel, err := filepath.Rel(mount, path); err == nil && filepath.IsLocal(rel) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			mounts = append(mounts, path)
+		}
+	}
+	return mounts
+}
+
+func LoadConfig(path string) (*devcontainer.DevContainerConfig, error) {
 	// Read the original devcontainer.json file
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -110,17 +329,184 @@ func LoadConfig(path string) (*devcontinaer.DevContainerConfig, error) {
 	}
 
 	// Parse the devcontainer.json into our config structure
-	return devcontinaer.ParseDevContainer(data)
+	return devcontainer.ParseDevContainer(data)
 }
 
-func overrideConfigValues(boxConfig BoxConfig, config *devcontinaer.DevContainerConfig) {
+// boxOverrides returns the values tape layers on top of the devcontainer
+// config for a box. Values the config already sets are left alone.
+func boxOverrides(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) *devcontainer.DevContainerConfig {
+	overrides := &devcontainer.DevContainerConfig{
+		ContainerEnv: boxConfig.Env,
+	}
+
+	for _, mount := range boxConfig.Mounts {
+		overrides.Mounts = append(overrides.Mounts, devcontainer.NewMountString(mount))
+	}
+
+	cacheMounts, cacheEnv := cacheOverrides(boxConfig, config)
+	overrides.Mounts = append(overrides.Mounts, cacheMounts...)
+	if len(cacheEnv) > 0 {
+		overrides.ContainerEnv = maps.Clone(cacheEnv)
+		maps.Copy(overrides.ContainerEnv, boxConfig.Env)
+	}
+
+	for _, port := range boxConfig.Ports {
+		overrides.RunArgs = append(overrides.RunArgs, "-p", port)
+	}
+
+	if boxConfig.Resources.CPUs != "" {
+		overrides.RunArgs = append(overrides.RunArgs, "--cpus", boxConfig.Resources.CPUs)
+	}
+
+	if boxConfig.Resources.Memory != "" {
+		overrides.RunArgs = append(overrides.RunArgs, "--memory", boxConfig.Resources.Memory)
+	}
+
+	overrides.RunArgs = append(overrides.RunArgs,
+		"--label", fmt.Sprintf("%s=%s", EnvLabel, boxConfig.Name),
+		"--label", fmt.Sprintf("%s=%s", VersionLabel, Version),
+	)
+
+	if boxConfig.IdleTimeout != "" {
+		overrides.RunArgs = append(overrides.RunArgs, "--label", fmt.Sprintf("%s=%s", IdleTimeoutLabel, boxConfig.IdleTimeout))
+	}
+
+	if boxConfig.ConfigName != "" {
+		overrides.RunArgs = append(overrides.RunArgs, "--label", fmt.Sprintf("%s=%s", ConfigNameLabel, boxConfig.ConfigName))
+	}
+
+	if boxConfig.Restart != "" && !slices.Contains(config.RunArgs, "--restart") {
+		overrides.RunArgs = append(overrides.RunArgs, "--restart", boxConfig.Restart)
+	}
+
 	if !slices.Contains(config.RunArgs, "--name") {
-		config.RunArgs = append(config.RunArgs, "--name", boxConfig.Name)
+		overrides.RunArgs = append(overrides.RunArgs, "--name", boxConfig.ContainerName())
+	}
+
+	if boxConfig.Network != "" && !slices.Contains(config.RunArgs, "--network") {
+		overrides.RunArgs = append(overrides.RunArgs, "--network", boxConfig.Network)
+	}
+
+	// make the box reachable from the other boxes on its network by name
+	if boxConfig.Network != "" && !slices.Contains(config.RunArgs, "--network-alias") {
+		overrides.RunArgs = append(overrides.RunArgs, "--network-alias", boxConfig.ContainerName())
+	}
+
+	// the devcontainer CLI requires workspaceFolder whenever workspaceMount is set
+	if boxConfig.Sync {
+		folder := config.WorkspaceFolder
+		if folder == "" {
+			folder = defaultWorkspaceFolder(boxConfig)
+		}
+		overrides.WorkspaceFolder = folder
+		overrides.WorkspaceMount = syncWorkspaceMount(boxConfig, folder)
+	}
+
+	if boxConfig.Hardened {
+		hardenedOverrides(boxConfig, config, overrides)
+	}
+
+	if boxConfig.GUI == GUIX11 {
+		guiOverrides(boxConfig, config, overrides)
+	}
+
+	// the devcontainer CLI only applies the consistency to the workspace mount it creates itself
+	if !boxConfig.Sync && boxConfig.WorkspaceMountConsistency != "" && runtime.GOOS == "darwin" {
+		overrides.WorkspaceMount = mountWithConsistency(config.WorkspaceMount, boxConfig.WorkspaceMountConsistency)
+	}
+
+	return overrides
+}
+
+// mountWithConsistency adds the consistency option to a bind mount in docker
+// --mount syntax that doesn't set one. Other mounts are returned as they are.
+func mountWithConsistency(mount string, consistency string) string {
+	bind := false
+	for _, option := range strings.Split(mount, ",") {
+		key, value, _ := strings.Cut(option, "=")
+		switch key {
+		case "consistency":
+			return mount
+		case "type":
+			bind = value == "bind"
+		}
+	}
+	if !bind {
+		return mount
+	}
+	return mount + ",consistency=" + consistency
+}
+
+// EffectiveConfig returns the devcontainer config with the box's overrides applied
+func EffectiveConfig(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) *devcontainer.DevContainerConfig {
+	return devcontainer.Merge(config, boxOverrides(boxConfig, config))
+}
+
+// useImage points the config at a prebuilt image. Features are dropped since
+// the image already has them applied.
+func useImage(config *devcontainer.DevContainerConfig, image string) {
+	config.Image = image
+	config.Build = nil
+	config.DockerFile = ""
+	config.Context = ""
+	config.Features = nil
+}
+
+// configHash returns a short hash identifying a devcontainer config
+func configHash(config *devcontainer.DevContainerConfig) (string, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return "", fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:12], nil
+}
+
+// runArgLabel returns the value of a label set with --label in runArgs
+func runArgLabel(runArgs []string, key string) string {
+	for i := 0; i+1 < len(runArgs); i++ {
+		if runArgs[i] != "--label" {
+			continue
+		}
+		if k, value, _ := strings.Cut(runArgs[i+1], "="); k == key {
+			return value
+		}
+	}
+	return ""
+}
+
+// newBoxClient returns the backend for the docker host the box runs on
+func newBoxClient(boxConfig BoxConfig) (container.Backend, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
 	}
+	return newBackend(globalConfig, boxConfig.DockerHost)
 }
 
+// newBackend returns the backend for the runtime in the global config: the
+// docker daemon at host, or containerd, which doesn't use host
+func newBackend(globalConfig *GlobalConfig, host string) (container.Backend, error) {
+	if globalConfig.Runtime == RuntimeContainerd {
+		return container.NewContainerdClient(globalConfig.Containerd.Address, globalConfig.Containerd.Namespace)
+	}
+	return container.NewBackend(host)
+}
+
+// FindDevContainer returns the box's container. When several containers
+// match, the adopted one is preferred, then see container.SortByPreference.
 func FindDevContainer(config BoxConfig) (*container.Container, error) {
-	cli, err := container.NewClient()
+	containers, err := FindDevContainers(config)
+	if err != nil {
+		return nil, err
+	}
+	return &containers[0], nil
+}
+
+// FindDevContainers returns every container that matches the box, with the
+// one FindDevContainer would pick first
+func FindDevContainers(config BoxConfig) ([]container.Container, error) {
+	cli, err := newBoxClient(config)
 	if err != nil {
 		return nil, fmt.Errorf("error creating container client: %v", err)
 	}
@@ -128,22 +514,29 @@ func FindDevContainer(config BoxConfig) (*container.Container, error) {
 
 	ctx := context.Background()
 
-	hostFolderLabel := fmt.Sprintf("%s=%s", HostFolderLabel, config.Workspace)
-	labels := []string{
-		hostFolderLabel,
-		fmt.Sprintf("%s=%s", ConfigFileLabel, config.Config),
-	}
-
-	dc, err := cli.FindContainer(ctx, labels)
+	containers, err := cli.FindContainers(ctx, []string{fmt.Sprintf("%s=%s", EnvLabel, config.Name)})
 	if err != nil && container.IsContainerNotFound(err) {
-		// seems like sometimes the config file label is wrong?
-		// so matching the devcontainer-cli impl of just using the host folder label
-		dc, err = cli.FindContainer(ctx, []string{hostFolderLabel})
+		// containers created before tape labeled them only have the devcontainer CLI's labels
+		hostFolderLabel := fmt.Sprintf("%s=%s", HostFolderLabel, config.Workspace)
+		containers, err = cli.FindContainers(ctx, []string{
+			hostFolderLabel,
+			fmt.Sprintf("%s=%s", ConfigFileLabel, config.Config),
+		})
+		if err != nil && container.IsContainerNotFound(err) {
+			// seems like sometimes the config file label is wrong?
+			// so matching the devcontainer-cli impl of just using the host folder label
+			containers, err = cli.FindContainers(ctx, []string{hostFolderLabel})
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	adopted, err := adoptedContainer(config.Name)
 	if err != nil {
 		return nil, err
 	}
+	preferAdopted(containers, adopted)
 
-	return dc, nil
+	return containers, nil
 }
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DevcontainerConfigFile is a devcontainer.json found in a workspace
+type DevcontainerConfigFile struct {
+	// Name is the folder of a .devcontainer/<name>/devcontainer.json, empty
+	// for .devcontainer/devcontainer.json and .devcontainer.json
+	Name string
+	Path string
+}
+
+// FindDevcontainerConfigs returns the workspace's devcontainer configs in the
+// spec's discovery order: .devcontainer/devcontainer.json, .devcontainer.json,
+// then .devcontainer/<name>/devcontainer.json by name
+func FindDevcontainerConfigs(workspace string) ([]DevcontainerConfigFile, error) {
+	var configs []DevcontainerConfigFile
+	for _, p := range []string{
+		filepath.Join(workspace, ".devcontainer", "devcontainer.json"),
+		filepath.Join(workspace, ".devcontainer.json"),
+	} {
+		if isFile(p) {
+			configs = append(configs, DevcontainerConfigFile{Path: p})
+		}
+	}
+
+	entries, err := os.ReadDir(filepath.Join(workspace, ".devcontainer"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	// ReadDir sorts the entries by name
+	for _, entry := range entries {
+		p := filepath.Join(workspace, ".devcontainer", entry.Name(), "devcontainer.json")
+		if entry.IsDir() && isFile(p) {
+			configs = append(configs, DevcontainerConfigFile{Name: entry.Name(), Path: p})
+		}
+	}
+	return configs, nil
+}
+
+func isFile(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
+
+// defaultDevcontainerConfig returns the config of a box without one set: the
+// first config in discovery order, unless the workspace only has several
+// named ones, and .devcontainer/devcontainer.json when there's none to pick
+func defaultDevcontainerConfig(workspace string) string {
+	configs, err := FindDevcontainerConfigs(workspace)
+	if err == nil && len(configs) > 0 && (configs[0].Name == "" || len(configs) == 1) {
+		return configs[0].Path
+	}
+	return filepath.Join(workspace, ".devcontainer", "devcontainer.json")
+}
+
+// devcontainerConfigPath returns the path of the workspace's config named
+// name, or its default config without a name
+func devcontainerConfigPath(workspace string, name string) string {
+	if name == "" {
+		return defaultDevcontainerConfig(workspace)
+	}
+	return filepath.Join(workspace, ".devcontainer", name, "devcontainer.json")
+}
+
+// configChoices returns the names of the workspace's configs to choose from
+// when it has several named ones and no default one, nil otherwise
+func configChoices(workspace string) []string {
+	configs, err := FindDevcontainerConfigs(workspace)
+	if err != nil || len(configs) < 2 || configs[0].Name == "" {
+		return nil
+	}
+	var names []string
+	for _, config := range configs {
+		names = append(names, config.Name)
+	}
+	return names
+}
+
+// selectedConfigName returns the config the box's container was created from
+// when the workspace has several to choose from, so commands after tape up
+// use the same one
+func selectedConfigName(boxConfig BoxConfig) string {
+	if configChoices(boxConfig.Workspace) == nil {
+		return ""
+	}
+	dc, err := FindDevContainer(boxConfig)
+	if err != nil {
+		return ""
+	}
+	return dc.Labels[ConfigNameLabel]
+}
+
+// DevcontainerConfigChoices returns the names of the configs to choose from
+// when the box's workspace has several and none was chosen, with config-name
+// or by creating the container. It returns nil when there's no choice to make.
+func DevcontainerConfigChoices(envName string) ([]string, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	if isFile(boxConfig.Config) {
+		return nil, nil
+	}
+	return configChoices(boxConfig.Workspace), nil
+}
+
+// selectDevcontainerConfig points the box at the workspace's config named
+// name, the folder of a .devcontainer/<name>/devcontainer.json, which the
+// container is labeled with. Without a name, it checks the box's config
+// exists, and fails listing the names to choose from when the workspace has
+// several configs instead.
+func selectDevcontainerConfig(boxConfig *BoxConfig, name string) error {
+	if name == "" && isFile(boxConfig.Config) {
+		return nil
+	}
+
+	configs, err := FindDevcontainerConfigs(boxConfig.Workspace)
+	if err != nil {
+		return fmt.Errorf("error finding devcontainer configs: %v", err)
+	}
+	var names []string
+	for _, config := range configs {
+		if config.Name == "" {
+			continue
+		}
+		if config.Name == name {
+			boxConfig.Config = config.Path
+			boxConfig.ConfigName = name
+			return nil
+		}
+		names = append(names, config.Name)
+	}
+
+	switch {
+	case name != "" && len(names) == 0:
+		return fmt.Errorf("%s has no configs in .devcontainer/<name>/devcontainer.json", boxConfig.Workspace)
+	case name != "":
+		return fmt.Errorf("%s has no config %s, choose one of %s", boxConfig.Workspace, name, strings.Join(names, ", "))
+	case len(names) > 1:
+		return fmt.Errorf("%w: %s has configs %s, choose one with --config-name", ErrAmbiguousConfig, boxConfig.Workspace, strings.Join(names, ", "))
+	}
+	// a missing config is reported by the devcontainer CLI
+	return nil
+}
@@ -0,0 +1,100 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeDevcontainerConfigs(t *testing.T, paths ...string) string {
+	t.Helper()
+	workspace := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(workspace, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte("{}"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return workspace
+}
+
+func TestFindDevcontainerConfigs(t *testing.T) {
+	workspace := writeDevcontainerConfigs(t,
+		".devcontainer/python/devcontainer.json",
+		".devcontainer/devcontainer.json",
+		".devcontainer.json",
+		".devcontainer/go/devcontainer.json",
+		".devcontainer/scripts/setup.sh",
+	)
+
+	got, err := FindDevcontainerConfigs(workspace)
+	if err != nil {
+		t.Fatalf("FindDevcontainerConfigs() error = %v", err)
+	}
+	expected := []DevcontainerConfigFile{
+		{Path: filepath.Join(workspace, ".devcontainer", "devcontainer.json")},
+		{Path: filepath.Join(workspace, ".devcontainer.json")},
+		{Name: "go", Path: filepath.Join(workspace, ".devcontainer", "go", "devcontainer.json")},
+		{Name: "python", Path: filepath.Join(workspace, ".devcontainer", "python", "devcontainer.json")},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("FindDevcontainerConfigs() = %+v, want %+v", got, expected)
+	}
+}
+
+func TestDefaultDevcontainerConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		configs  []string
+		expected string
+	}{
+		{name: "none", expected: ".devcontainer/devcontainer.json"},
+		{name: "root file", configs: []string{".devcontainer.json", ".devcontainer/go/devcontainer.json"}, expected: ".devcontainer.json"},
+		{name: "single named", configs: []string{".devcontainer/go/devcontainer.json"}, expected: ".devcontainer/go/devcontainer.json"},
+		{name: "several named", configs: []string{".devcontainer/go/devcontainer.json", ".devcontainer/python/devcontainer.json"}, expected: ".devcontainer/devcontainer.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := writeDevcontainerConfigs(t, tt.configs...)
+			expected := filepath.Join(workspace, filepath.FromSlash(tt.expected))
+			if got := defaultDevcontainerConfig(workspace); got != expected {
+				t.Errorf("defaultDevcontainerConfig() = %s, want %s", got, expected)
+			}
+		})
+	}
+}
+
+func TestSelectDevcontainerConfig(t *testing.T) {
+	workspace := writeDevcontainerConfigs(t, ".devcontainer/go/devcontainer.json", ".devcontainer/python/devcontainer.json")
+	defaultConfig := defaultDevcontainerConfig(workspace)
+
+	boxConfig := &BoxConfig{Workspace: workspace, Config: defaultConfig}
+	if err := selectDevcontainerConfig(boxConfig, ""); !errors.Is(err, ErrAmbiguousConfig) {
+		t.Errorf("selectDevcontainerConfig() without a name error = %v, want ErrAmbiguousConfig", err)
+	}
+
+	if choices := configChoices(workspace); !reflect.DeepEqual(choices, []string{"go", "python"}) {
+		t.Errorf("configChoices() = %q, want go and python", choices)
+	}
+
+	if err := selectDevcontainerConfig(boxConfig, "python"); err != nil {
+		t.Fatalf("selectDevcontainerConfig() error = %v", err)
+	}
+	if expected := filepath.Join(workspace, ".devcontainer", "python", "devcontainer.json"); boxConfig.Config != expected || boxConfig.ConfigName != "python" {
+		t.Errorf("selectDevcontainerConfig() config = %s (%s), want %s (python)", boxConfig.Config, boxConfig.ConfigName, expected)
+	}
+	// the chosen config is kept by later commands
+	if err := selectDevcontainerConfig(boxConfig, ""); err != nil {
+		t.Errorf("selectDevcontainerConfig() of a chosen config error = %v", err)
+	}
+
+	if err := selectDevcontainerConfig(boxConfig, "rust"); err == nil {
+		t.Error("selectDevcontainerConfig() of a missing name should fail")
+	}
+}
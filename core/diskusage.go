@@ -0,0 +1,76 @@
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// BoxDiskUsage is the disk space used by one of a box's containers
+type BoxDiskUsage struct {
+	EnvName string
+	container.DiskUsage
+}
+
+// GetBoxDiskUsage returns the disk usage of every container matching the box
+func GetBoxDiskUsage(envName string) ([]BoxDiskUsage, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := FindDevContainers(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, c := range containers {
+		ids[c.ID] = true
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	usages, err := cli.DiskUsage(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var boxUsages []BoxDiskUsage
+	for _, usage := range usages {
+		if ids[usage.ContainerID] {
+			boxUsages = append(boxUsages, BoxDiskUsage{EnvName: envName, DiskUsage: usage})
+		}
+	}
+	return boxUsages, nil
+}
+
+// ListBoxDiskUsage returns the disk usage of every container tape created on
+// the default docker host, sorted by environment name
+func ListBoxDiskUsage() ([]BoxDiskUsage, error) {
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	usages, err := cli.DiskUsage(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	var boxUsages []BoxDiskUsage
+	for _, usage := range usages {
+		if envName, ok := usage.Labels[EnvLabel]; ok {
+			boxUsages = append(boxUsages, BoxDiskUsage{EnvName: envName, DiskUsage: usage})
+		}
+	}
+	sort.SliceStable(boxUsages, func(i, j int) bool {
+		return boxUsages[i].EnvName < boxUsages[j].EnvName
+	})
+	return boxUsages, nil
+}
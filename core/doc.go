@@ -0,0 +1,10 @@
+// Package core manages tape environments ("boxes"): their YAML configs and
+// the devcontainers created from them.
+//
+// Other Go programs can embed tape through this package. The stable API is
+// LoadBoxConfig, ListBoxConfigs, GetBoxSummary, UpBox, StopBox, RemoveBox,
+// PauseBox, ResumeBox and ExecInBox. Functions return errors rather than
+// exiting; check them with errors.Is against ErrConfigNotFound,
+// ErrDockerUnavailable, ErrNotRunning, ErrAmbiguousContainer and
+// ErrImageNotFound. Progress messages and warnings go to Stdout and Stderr.
+package core
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// CheckStatus is the outcome of a doctor check
+type CheckStatus string
+
+const (
+	CheckOK      CheckStatus = "ok"
+	CheckWarning CheckStatus = "warning"
+	CheckFailed  CheckStatus = "failed"
+)
+
+// Check is the result of one of the checks tape doctor runs
+type Check struct {
+	Name    string
+	Status  CheckStatus
+	Message string
+}
+
+// Doctor checks that tape's environment is set up to run boxes
+func Doctor() ([]Check, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	checks := []Check{dockerCheck(globalConfig), devcontainerCliCheck(globalConfig)}
+	if InWSL() {
+		checks = append(checks, wslChecks()...)
+	}
+	return checks, nil
+}
+
+func dockerCheck(globalConfig *GlobalConfig) Check {
+	check := Check{Name: cmp.Or(globalConfig.Runtime, RuntimeDocker)}
+
+	cli, err := newBackend(globalConfig, globalConfig.DockerHost)
+	if err != nil {
+		check.Status, check.Message = CheckFailed, err.Error()
+		return check
+	}
+	defer cli.Close()
+
+	version, err := cli.Ping(context.Background())
+	if err != nil {
+		check.Status, check.Message = CheckFailed, err.Error()
+		if InWSL() {
+			check.Message += "\nturn on WSL integration for this distro in Docker Desktop's Resources settings"
+		}
+		return check
+	}
+	check.Status = CheckOK
+	check.Message = fmt.Sprintf("reachable at %s, API version %s", cli.Host(), version)
+	if info, err := cli.Info(context.Background()); err == nil {
+		if info.Rootless {
+			check.Message += ", rootless mode: root in containers is your user, updateRemoteUserUID is turned off"
+		} else {
+			check.Message += ", rootful mode"
+		}
+	}
+	return check
+}
+
+func devcontainerCliCheck(globalConfig *GlobalConfig) Check {
+	check := Check{Name: "devcontainer CLI", Status: CheckOK}
+	switch {
+	case globalConfig.ExecutionStrategy == ExecutionStrategyNative:
+		binary := "docker"
+		if globalConfig.Runtime == RuntimeContainerd {
+			binary = "nerdctl"
+		}
+		path, err := exec.LookPath(binary)
+		if err != nil {
+			check.Status = CheckFailed
+			check.Message = fmt.Sprintf("not used, the %s execution strategy needs %s on PATH", ExecutionStrategyNative, binary)
+			return check
+		}
+		check.Message = fmt.Sprintf("not used, tape runs its commands with %s, for configs without features or docker compose", path)
+	case globalConfig.Runtime == RuntimeContainerd:
+		binary, err := exec.LookPath("devcontainer")
+		if err != nil {
+			check.Status = CheckFailed
+			check.Message = "not found on PATH, the containerd runtime needs it installed"
+			return check
+		}
+		check.Message = "using " + binary + " with nerdctl"
+	case globalConfig.ExecutionStrategy == ExecutionStrategyLocalBinary:
+		binary, err := exec.LookPath("devcontainer")
+		if err != nil {
+			check.Status = CheckWarning
+			check.Message = "not found on PATH, running it in a container instead"
+			return check
+		}
+		check.Message = "using " + binary
+	default:
+		check.Message = "running it in a container from " + devcontainerCliImage(globalConfig)
+	}
+	return check
+}
+
+// wslChecks warns about box configs that work poorly from WSL
+func wslChecks() []Check {
+	message := "running in WSL"
+	if distro := os.Getenv("WSL_DISTRO_NAME"); distro != "" {
+		message += " distro " + distro
+	}
+	checks := []Check{{Name: "wsl", Status: CheckOK, Message: message}}
+
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return append(checks, Check{Name: "wsl", Status: CheckFailed, Message: err.Error()})
+	}
+	for _, envName := range envNames {
+		boxConfig, err := LoadBoxConfig(envName)
+		if err != nil {
+			continue
+		}
+		if onWindowsDrive(boxConfig.Workspace) {
+			checks = append(checks, Check{
+				Name:    envName,
+				Status:  CheckWarning,
+				Message: fmt.Sprintf("workspace %s is on a Windows drive, move it into the WSL filesystem for faster mounts", boxConfig.Workspace),
+			})
+		}
+	}
+	return checks
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path"
+	"runtime"
+)
+
+const (
+	EditorVSCode    = "vscode"
+	EditorCursor    = "cursor"
+	EditorJetBrains = "jetbrains"
+)
+
+// Editors are the editors tape knows how to launch
+var Editors = []string{EditorVSCode, EditorCursor, EditorJetBrains}
+
+// ContainerWorkspaceFolder returns the path the workspace is mounted at inside
+// the container, using the devcontainer default when the config doesn't set one
+func (b *BoxConfig) ContainerWorkspaceFolder() (string, error) {
+	config, err := LoadConfig(b.Config)
+	if err != nil {
+		return "", err
+	}
+	if config.WorkspaceFolder != "" {
+		return config.WorkspaceFolder, nil
+	}
+	return defaultWorkspaceFolder(*b), nil
+}
+
+// defaultWorkspaceFolder is where the devcontainer CLI mounts the workspace
+// when the config doesn't set workspaceFolder
+func defaultWorkspaceFolder(boxConfig BoxConfig) string {
+	return path.Join("/workspaces", path.Base(DockerPath(boxConfig.Workspace)))
+}
+
+// AttachedContainerURI returns the vscode-remote URI that opens folder inside
+// the named container with the Dev Containers extension
+func AttachedContainerURI(containerName string, folder string) string {
+	return fmt.Sprintf("vscode-remote://attached-container+%s%s", hex.EncodeToString([]byte(containerName)), folder)
+}
+
+// GatewayURI returns the JetBrains Gateway URI that connects to the tape SSH
+// server and opens folder
+func GatewayURI(host string, port int, user string, folder string) string {
+	params := url.Values{}
+	params.Set("type", "ssh")
+	params.Set("deploy", "false")
+	params.Set("host", host)
+	params.Set("port", fmt.Sprint(port))
+	params.Set("user", user)
+	params.Set("projectPath", folder)
+	return "jetbrains-gateway://connect#" + params.Encode()
+}
+
+// OpenURLCommand returns a command that opens url with the system handler
+func OpenURLCommand(url string) *exec.Cmd {
+	switch runtime.GOOS {
+	case "darwin":
+		return exec.Command("open", url)
+	case "windows":
+		return exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		return exec.Command("xdg-open", url)
+	}
+}
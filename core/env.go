@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// shell flags used to probe the user's environment for each userEnvProbe value
+var userEnvProbeFlags = map[string]string{
+	"loginShell":            "-lc",
+	"interactiveShell":      "-ic",
+	"loginInteractiveShell": "-lic",
+}
+
+// GetBoxEnv returns the effective environment of a box. Later sources win:
+// the probed user environment, containerEnv (including the box's env), and
+// finally remoteEnv. The user environment is only probed when the box is running.
+func GetBoxEnv(envName string) (map[string]string, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+	config = EffectiveConfig(*boxConfig, config)
+
+	env := map[string]string{}
+
+	probe := config.UserEnvProbe
+	if probe == "" {
+		probe = "loginInteractiveShell"
+	}
+
+	if flags, ok := userEnvProbeFlags[probe]; ok {
+		dc, err := FindDevContainer(*boxConfig)
+		if err != nil && !container.IsContainerNotFound(err) {
+			return nil, err
+		}
+
+		if dc != nil && dc.State == container.StateRunning {
+			user := config.RemoteUser
+			if user == "" {
+				user = config.ContainerUser
+			}
+
+			probed, err := probeUserEnv(dc, user, flags)
+			if err != nil {
+				return nil, err
+			}
+			for key, value := range probed {
+				env[key] = value
+			}
+		}
+	}
+
+	for key, value := range config.ContainerEnv {
+		env[key] = value
+	}
+
+	for key, value := range config.RemoteEnv {
+		// a null remoteEnv value unsets the variable
+		if value == nil {
+			delete(env, key)
+			continue
+		}
+		env[key] = *value
+	}
+
+	return env, nil
+}
+
+func probeUserEnv(dc *container.Container, user string, flags string) (map[string]string, error) {
+	result, err := dc.Exec(context.Background(), container.ExecConfig{
+		// NUL separated, since values can span lines
+		Command: []string{"/bin/sh", "-c", fmt.Sprintf(`exec "${SHELL:-/bin/sh}" %s 'env -0'`, flags)},
+		User:    user,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error probing user environment: %v", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("error probing user environment: exit code %d: %s", result.ExitCode, strings.TrimSpace(string(result.Stderr)))
+	}
+
+	return parseEnvOutput(string(result.Stdout)), nil
+}
+
+// parseEnvOutput parses the output of env -0. Anything the shell printed
+// before it, e.g. a login banner, ends up in front of the first variable's
+// name and is dropped, as is anything after the last NUL.
+func parseEnvOutput(output string) map[string]string {
+	env := map[string]string{}
+	entries := strings.Split(output, "\x00")
+	for _, entry := range entries[:len(entries)-1] {
+		key, value, found := strings.Cut(entry, "=")
+		if i := strings.LastIndex(key, "\n"); i >= 0 {
+			key = key[i+1:]
+		}
+		if !found || key == "" {
+			continue
+		}
+		env[key] = value
+	}
+	return env
+}
@@ -0,0 +1,18 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvOutput(t *testing.T) {
+	output := "Welcome to the box\nPATH=/usr/bin\x00KEY=line one\nline two\x00EMPTY=\x00=ignored\x00logout\n"
+	expected := map[string]string{
+		"PATH":  "/usr/bin",
+		"KEY":   "line one\nline two",
+		"EMPTY": "",
+	}
+	if got := parseEnvOutput(output); !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseEnvOutput() = %q, want %q", got, expected)
+	}
+}
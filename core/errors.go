@@ -0,0 +1,34 @@
+package core
+
+import (
+	"errors"
+
+	"github.com/mikeocool/tape/container"
+)
+
+var (
+	// ErrConfigNotFound is returned when an environment has no config file
+	ErrConfigNotFound = errors.New("environment not found")
+	// ErrGroupNotFound is returned when a group isn't defined in the global config
+	ErrGroupNotFound = errors.New("group not found")
+	// ErrAmbiguousContainer is returned when a container reference matches more than one container
+	ErrAmbiguousContainer = errors.New("ambiguous container")
+	// ErrAmbiguousConfig is returned when a workspace has several devcontainer configs and none was chosen
+	ErrAmbiguousConfig = errors.New("ambiguous devcontainer config")
+	// ErrNotRunning is returned when an operation needs a running container
+	ErrNotRunning = errors.New("environment is not running")
+	// ErrPolicyViolation is returned when a box breaks a configured policy, see PolicyError
+	ErrPolicyViolation = errors.New("policy violation")
+	// ErrNoProxyRoute is returned for hostnames the reverse proxy has no box for
+	ErrNoProxyRoute = errors.New("no environment for this hostname")
+	// ErrUnknownSecretScheme is returned for secret references without a registered provider
+	ErrUnknownSecretScheme = errors.New("unknown secret scheme")
+	// ErrUntrustedWorkspace is returned when a box needs its workspace trusted to start, see TrustError
+	ErrUntrustedWorkspace = errors.New("untrusted workspace")
+
+	// re-exported so callers can check errors without importing container
+	ErrDockerUnavailable   = container.ErrDockerUnavailable
+	ErrImageNotFound       = container.ErrImageNotFound
+	ErrRegistryUnavailable = container.ErrRegistryUnavailable
+	ErrOffline             = container.ErrOffline
+)
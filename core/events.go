@@ -0,0 +1,184 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// Sources of box events
+const (
+	// EventSourceDocker is a container event from the default docker host
+	EventSourceDocker = "docker"
+	// EventSourceTape is an operation tape recorded in its history
+	EventSourceTape = "tape"
+)
+
+// BoxEvent is a change of an environment, as tape events reports it
+type BoxEvent struct {
+	Time    time.Time `json:"time"`
+	EnvName string    `json:"env"`
+	Source  string    `json:"source"`
+	// Action is a container event's action, e.g. start or die, or the
+	// operation tape ran, e.g. up or lifecycle
+	Action      string `json:"action"`
+	ContainerID string `json:"container,omitempty"`
+	// ExitCode is the container's exit code, for die events
+	ExitCode int `json:"exitCode,omitempty"`
+	// Outcome, Detail and Error are those of tape's operations, see Event
+	Outcome string `json:"outcome,omitempty"`
+	Detail  string `json:"detail,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// historyPollInterval is how often WatchEvents checks the history log for
+// operations other tape processes recorded
+const historyPollInterval = 500 * time.Millisecond
+
+// WatchEvents calls fn with the events of the environment, or of every
+// environment when envName is empty, as they happen, until ctx is cancelled
+// or fn returns an error: the container events of the default docker host,
+// and the operations tape processes record in the history, including the
+// end of lifecycle commands run in the background.
+func WatchEvents(ctx context.Context, envName string, fn func(BoxEvent) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	events := make(chan BoxEvent)
+	errs := make(chan error, 2)
+	send := func(event BoxEvent) error {
+		if envName != "" && event.EnvName != envName {
+			return nil
+		}
+		select {
+		case events <- event:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+
+	go func() {
+		errs <- WatchBoxEvents(ctx, func(event container.Event) error {
+			return send(containerBoxEvent(event))
+		})
+	}()
+	go func() {
+		errs <- tailHistory(ctx, historyPollInterval, func(event Event) error {
+			return send(historyBoxEvent(event))
+		})
+	}()
+
+	for {
+		select {
+		case event := <-events:
+			if err := fn(event); err != nil {
+				return err
+			}
+		case err := <-errs:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// containerBoxEvent converts a container event. Containers tape didn't label
+// have no environment name.
+func containerBoxEvent(event container.Event) BoxEvent {
+	return BoxEvent{
+		Time:        event.Time,
+		EnvName:     event.Labels[EnvLabel],
+		Source:      EventSourceDocker,
+		Action:      event.Action,
+		ContainerID: event.ContainerID,
+		ExitCode:    event.ExitCode,
+	}
+}
+
+func historyBoxEvent(event Event) BoxEvent {
+	return BoxEvent{
+		Time:    event.Time,
+		EnvName: event.EnvName,
+		Source:  EventSourceTape,
+		Action:  event.Operation,
+		Outcome: event.Outcome,
+		Detail:  event.Detail,
+		Error:   event.Error,
+	}
+}
+
+// tailHistory calls fn with the events appended to the history log from now
+// on, checking for them every interval, until ctx is cancelled
+func tailHistory(ctx context.Context, interval time.Duration, fn func(Event) error) error {
+	var offset int64
+	if info, err := os.Stat(HistoryPath()); err == nil {
+		offset = info.Size()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+
+		events, next, err := readHistoryFrom(offset)
+		if err != nil {
+			return err
+		}
+		offset = next
+		for _, event := range events {
+			if err := fn(event); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readHistoryFrom returns the whole lines of the history log after offset,
+// and the offset after them. A line still being written is left for later.
+func readHistoryFrom(offset int64) ([]Event, int64, error) {
+	file, err := os.Open(HistoryPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, offset, fmt.Errorf("error reading history: %v", err)
+	}
+	defer file.Close()
+
+	if info, err := file.Stat(); err == nil && info.Size() < offset {
+		// the log was replaced, read the new one from its start
+		offset = 0
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, offset, fmt.Errorf("error reading history: %v", err)
+	}
+
+	var events []Event
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			// an incomplete last line isn't consumed
+			return events, offset, nil
+		}
+		offset += int64(len(line))
+		var event Event
+		// skip lines that were cut short, like History
+		if err := json.Unmarshal(bytes.TrimSpace(line), &event); err == nil {
+			events = append(events, event)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestReadHistoryFrom(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	events, offset, err := readHistoryFrom(0)
+	if err != nil || len(events) != 0 || offset != 0 {
+		t.Fatalf("readHistoryFrom() without a log = %v, %d, %v, want nothing", events, offset, err)
+	}
+
+	recordEvent("app", "up", "", nil)
+	info, err := os.Stat(HistoryPath())
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+	start := info.Size()
+
+	recordEvent("app", "lifecycle", "run-user-commands", errors.New("exit status 1"))
+	file, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	file.WriteString(`{"time": "2025-`)
+	file.Close()
+
+	events, offset, err = readHistoryFrom(start)
+	if err != nil {
+		t.Fatalf("readHistoryFrom() error = %v", err)
+	}
+	if len(events) != 1 || events[0].Operation != "lifecycle" || events[0].Outcome != OutcomeError {
+		t.Fatalf("readHistoryFrom() = %+v, want the failed lifecycle", events)
+	}
+
+	// the incomplete line is read once it's finished
+	file, err = os.OpenFile(HistoryPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	file.WriteString(`01-02T03:04:05Z", "env": "api", "operation": "stop", "outcome": "ok"}` + "\n")
+	file.Close()
+
+	events, _, err = readHistoryFrom(offset)
+	if err != nil {
+		t.Fatalf("readHistoryFrom() error = %v", err)
+	}
+	if len(events) != 1 || events[0].EnvName != "api" || events[0].Operation != "stop" {
+		t.Errorf("readHistoryFrom() = %+v, want the finished stop", events)
+	}
+}
+
+func TestBoxEventConversion(t *testing.T) {
+	now := time.Now()
+
+	event := containerBoxEvent(container.Event{
+		ContainerID: "abc",
+		Action:      "die",
+		Labels:      map[string]string{EnvLabel: "app"},
+		ExitCode:    137,
+		Time:        now,
+	})
+	want := BoxEvent{Time: now, EnvName: "app", Source: EventSourceDocker, Action: "die", ContainerID: "abc", ExitCode: 137}
+	if event != want {
+		t.Errorf("containerBoxEvent() = %+v, want %+v", event, want)
+	}
+
+	event = historyBoxEvent(Event{Time: now, EnvName: "app", Operation: "up", Detail: "rebuild", Outcome: OutcomeOK})
+	want = BoxEvent{Time: now, EnvName: "app", Source: EventSourceTape, Action: "up", Outcome: OutcomeOK, Detail: "rebuild"}
+	if event != want {
+		t.Errorf("historyBoxEvent() = %+v, want %+v", event, want)
+	}
+}
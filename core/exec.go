@@ -0,0 +1,189 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// ExecOptions configures a command run in a box
+type ExecOptions struct {
+	Command []string
+	// User defaults to the devcontainer config's remoteUser
+	User string
+	// WorkingDir defaults to the workspace folder in the container
+	WorkingDir string
+	// Env are extra KEY=VALUE variables for the command
+	Env []string
+}
+
+// ExecInBox runs a command in the box's running container, attached to the
+// terminal. The devcontainer CLI runs it unless a user or working directory is
+// set, which it doesn't support, in which case it is run with docker exec.
+func ExecInBox(envName string, opts ExecOptions) (err error) {
+	defer func() {
+		recordEvent(envName, "exec", strings.Join(opts.Command, " "), err)
+	}()
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	if err := RequireRunning(envName); err != nil {
+		return err
+	}
+
+	// variables given for the command override the box's secrets
+	secrets, err := resolveSecrets(*boxConfig)
+	if err != nil {
+		return err
+	}
+	opts.Env = append(secretsEnv(secrets), opts.Env...)
+
+	// devcontainer exec only takes variables on its command line, where
+	// secrets would show up in ps, so commands with secrets run with docker
+	// exec, as do commands whose output goes through the clipboard bridge
+	if opts.User == "" && opts.WorkingDir == "" && len(secrets) == 0 && !boxConfig.Clipboard {
+		var args []string
+		for _, env := range opts.Env {
+			args = append(args, "--remote-env", env)
+		}
+
+		devCmd := DevcontainerCommand{
+			BoxConfig:      *boxConfig,
+			Command:        "exec",
+			AdditionalArgs: append(args, opts.Command...),
+		}
+		return devCmd.Execute()
+	}
+
+	return nativeExec(*boxConfig, opts)
+}
+
+// nativeExec runs the command with docker exec, filling in the defaults the
+// devcontainer CLI would use
+func nativeExec(boxConfig BoxConfig, opts ExecOptions) error {
+	dc, err := FindDevContainer(boxConfig)
+	if err != nil {
+		return err
+	}
+
+	opts.Env, err = withRemoteEnv(boxConfig, dc, opts.Env)
+	if err != nil {
+		return err
+	}
+	config, err := execConfig(boxConfig, opts)
+	if err != nil {
+		return err
+	}
+	if boxConfig.Clipboard {
+		config.Stdout = NewClipboardBridge(os.Stdout, HostClipboard)
+	}
+
+	exitCode, err := dc.ExecInteractive(context.Background(), config)
+	if err != nil {
+		return fmt.Errorf("error running command: %w", err)
+	}
+	if exitCode != 0 {
+		return &container.ExitError{Code: exitCode}
+	}
+	return nil
+}
+
+// execConfig fills in the user and working directory the devcontainer CLI
+// would use when opts doesn't set them
+func execConfig(boxConfig BoxConfig, opts ExecOptions) (container.ExecConfig, error) {
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return container.ExecConfig{}, err
+	}
+
+	if opts.User == "" {
+		opts.User = config.RemoteUser
+	}
+	if opts.User == "" {
+		opts.User = config.ContainerUser
+	}
+	if opts.WorkingDir == "" {
+		opts.WorkingDir, err = boxConfig.ContainerWorkspaceFolder()
+		if err != nil {
+			return container.ExecConfig{}, err
+		}
+	}
+
+	return container.ExecConfig{
+		Command:    opts.Command,
+		User:       opts.User,
+		WorkingDir: opts.WorkingDir,
+		Env:        opts.Env,
+	}, nil
+}
+
+// withRemoteEnv returns env after the devcontainer config's remoteEnv, which
+// the devcontainer CLI sets for the commands it runs, so the variables in env
+// take precedence
+func withRemoteEnv(boxConfig BoxConfig, dc *container.Container, env []string) ([]string, error) {
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+	config, err = substituteVariables(config, boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	remoteEnv, err := resolveRemoteEnv(config.RemoteEnv, func() (map[string]string, error) {
+		result, err := dc.Exec(context.Background(), container.ExecConfig{Command: []string{"env"}})
+		if err != nil {
+			return nil, fmt.Errorf("error reading the container's environment: %w", err)
+		}
+		return parseEnv(strings.Split(string(result.Stdout), "\n")), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(remoteEnv, env...), nil
+}
+
+// RunInBox runs a command in the box's running container without a terminal
+// and returns its output, for callers that aren't attached to one
+func RunInBox(envName string, opts ExecOptions) (result *container.ExecResult, err error) {
+	defer func() {
+		if err == nil && result.ExitCode != 0 {
+			recordEvent(envName, "exec", strings.Join(opts.Command, " "), &container.ExitError{Code: result.ExitCode})
+			return
+		}
+		recordEvent(envName, "exec", strings.Join(opts.Command, " "), err)
+	}()
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRunning(envName, dc); err != nil {
+		return nil, err
+	}
+
+	opts.Env, err = withRemoteEnv(*boxConfig, dc, opts.Env)
+	if err != nil {
+		return nil, err
+	}
+	config, err := execConfig(*boxConfig, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = dc.Exec(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("error running command: %w", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// Execution strategies for running the devcontainer CLI, set in the global config
+const (
+	ExecutionStrategyContainer   = "container"
+	ExecutionStrategyLocalBinary = "local-binary"
+	// ExecutionStrategyNative has tape do what the CLI does itself, see nativeStrategy
+	ExecutionStrategyNative = "native"
+)
+
+// executionStrategy runs a devcontainer CLI command. configJSON is the
+// effective devcontainer config, or nil if the box has none, and hostPaths
+// are the host directories the CLI needs to read.
+type executionStrategy interface {
+	// auths are the credentials for the registries the box pulls from
+	run(dc *DevcontainerCommand, configJSON []byte, hostPaths []string, auths *container.DockerConfig) error
+	// runsOnHost reports whether the CLI runs on the host, where it runs
+	// host-side lifecycle commands like initializeCommand itself
+	runsOnHost() bool
+	// help prints the CLI's help for a command, listing its options
+	help(command string) error
+}
+
+// DevcontainerHelp prints the help of the devcontainer CLI tape runs for a
+// command, e.g. up, with the options tape passes through to it
+func DevcontainerHelp(command string) error {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	strategy, err := newExecutionStrategy(globalConfig)
+	if err != nil {
+		return err
+	}
+	return strategy.help(command)
+}
+
+// newExecutionStrategy returns the strategy selected in the global config
+func newExecutionStrategy(globalConfig *GlobalConfig) (executionStrategy, error) {
+	if globalConfig.Runtime == RuntimeContainerd {
+		if globalConfig.ExecutionStrategy == ExecutionStrategyNative {
+			binary, err := exec.LookPath("nerdctl")
+			if err != nil {
+				return nil, fmt.Errorf("the %s execution strategy needs nerdctl installed with the containerd runtime: %v", ExecutionStrategyNative, err)
+			}
+			return nativeStrategy{docker: binary, containerd: &globalConfig.Containerd, env: devcontainerCliEnv(globalConfig)}, nil
+		}
+		// the CLI's container would need the docker socket, on the host it runs nerdctl
+		binary, err := exec.LookPath("devcontainer")
+		if err != nil {
+			return nil, fmt.Errorf("the containerd runtime needs the devcontainer CLI installed on the host: %v", err)
+		}
+		return localBinaryStrategy{binary: binary, containerd: &globalConfig.Containerd, env: devcontainerCliEnv(globalConfig)}, nil
+	}
+
+	switch globalConfig.ExecutionStrategy {
+	case ExecutionStrategyLocalBinary:
+		binary, err := exec.LookPath("devcontainer")
+		if err == nil {
+			return localBinaryStrategy{binary: binary, env: devcontainerCliEnv(globalConfig)}, nil
+		}
+		fmt.Fprintln(Stdout, "devcontainer CLI not found on PATH, running it in a container instead")
+	case ExecutionStrategyNative:
+		binary, err := exec.LookPath("docker")
+		if err != nil {
+			return nil, fmt.Errorf("the %s execution strategy needs the docker CLI installed: %v", ExecutionStrategyNative, err)
+		}
+		return nativeStrategy{docker: binary, env: devcontainerCliEnv(globalConfig)}, nil
+	}
+
+	return containerStrategy{image: devcontainerCliImage(globalConfig), socketProxy: globalConfig.DockerSocketProxy, env: devcontainerCliEnv(globalConfig)}, nil
+}
+
+// devcontainerCliEnv returns the environment variables the global config sets
+// for the devcontainer CLI
+func devcontainerCliEnv(globalConfig *GlobalConfig) []string {
+	var env []string
+	if globalConfig.DisableTelemetry {
+		// the opt-out the CLI's npm dependencies and the docker CLI honor
+		env = append(env, "DO_NOT_TRACK=1")
+	}
+	return env
+}
+
+// devcontainerCliImage returns the image used to run the devcontainer CLI,
+// which can be overridden in the global config
+func devcontainerCliImage(globalConfig *GlobalConfig) string {
+	if globalConfig.DevcontainerImage != "" {
+		return globalConfig.DevcontainerImage
+	}
+	return DevContainerCliImage
+}
+
+// buildDevcontainerArgs returns the devcontainer CLI invocation, reading the
+// config from configPath when set
+func buildDevcontainerArgs(command string, workspace string, configPath string, additionalArgs []string) []string {
+	devConArgs := []string{"devcontainer", command, "--workspace-folder", workspace}
+
+	// Add config path argument if needed
+	if configPath != "" {
+		devConArgs = append(devConArgs, "--config", configPath)
+	}
+
+	// Add any additional arguments
+	return append(devConArgs, additionalArgs...)
+}
+
+// secretsFileArgs adds the devcontainer CLI's --secrets-file to args when
+// there is a secrets file, ahead of the command exec takes at the end
+func secretsFileArgs(secretsPath string, args []string) []string {
+	if secretsPath == "" {
+		return args
+	}
+	return append([]string{"--secrets-file", secretsPath}, args...)
+}
+
+// secretsContainerPath is where the box's secrets are written in the
+// devcontainer CLI's container, which is removed when the CLI exits
+const secretsContainerPath = "/tmp/secrets.json"
+
+// containerStrategy runs the devcontainer CLI in a helper container with
+// access to the docker socket
+type containerStrategy struct {
+	image string
+	// socketProxy has the CLI reach docker through the docker socket proxy
+	// instead of the mounted socket, see ensureDockerSocketProxy
+	socketProxy bool
+	// env is set for the CLI, see devcontainerCliEnv
+	env []string
+}
+
+func (s containerStrategy) runsOnHost() bool {
+	return false
+}
+
+func (s containerStrategy) help(command string) error {
+	cli, err := newBoxClient(BoxConfig{})
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	config := container.ContainerConfig{
+		Image:       s.image,
+		Command:     []string{"devcontainer", command, "--help"},
+		Interactive: true,
+		Env:         s.env,
+	}
+	helpContainer, err := cli.CreateContainer(ctx, config)
+	if errors.Is(err, container.ErrImageNotFound) {
+		if err := pullImage(ctx, cli, BoxConfig{}, s.image); err != nil {
+			return err
+		}
+		helpContainer, err = cli.CreateContainer(ctx, config)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating container: %v", err)
+	}
+	return helpContainer.AttachAndRun(ctx)
+}
+
+func (s containerStrategy) run(dc *DevcontainerCommand, configJSON []byte, hostPaths []string, auths *container.DockerConfig) error {
+	configPath := ""
+	if configJSON != nil {
+		configPath = "/tmp/devcontainer.json"
+	}
+	secretsPath := ""
+	if len(dc.Secrets) > 0 {
+		secretsPath = secretsContainerPath
+	}
+	devConArgs := buildDevcontainerArgs(dc.Command, DockerPath(dc.BoxConfig.Workspace), configPath, secretsFileArgs(secretsPath, dc.AdditionalArgs))
+
+	cli, err := newBoxClient(dc.BoxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	// the docker CLI in the container reads the credentials from /tmp/config.json
+	env := append([]string{"DOCKER_CONFIG=/tmp"}, s.env...)
+	var binds []string
+	network := ""
+	if s.socketProxy {
+		network, err = ensureDockerSocketProxy(ctx, cli, dc.BoxConfig)
+		if err != nil {
+			return err
+		}
+		env = append(env, "DOCKER_HOST="+dockerSocketProxyHost)
+	} else {
+		binds = append(binds, "/var/run/docker.sock:/var/run/docker.sock")
+	}
+
+	// Mount the host paths the CLI reads at the same location in the container,
+	// so the paths it passes back to docker refer to the same host directories
+	for _, path := range hostPaths {
+		binds = append(binds, fmt.Sprintf("%s:%s", path, DockerPath(path)))
+	}
+
+	if dc.buildSecretsDir != "" {
+		binds = append(binds, fmt.Sprintf("%s:%s:ro", dc.buildSecretsDir, DockerPath(dc.buildSecretsDir)))
+	}
+
+	if dc.sshAgent {
+		bind, err := sshAgentBind()
+		if err != nil {
+			return err
+		}
+		binds = append(binds, bind)
+		env = append(env, "SSH_AUTH_SOCK="+sshAgentSocket)
+	}
+	config := container.ContainerConfig{
+		Image:       s.image,
+		Command:     devConArgs,
+		Interactive: true,
+		Binds:       binds,
+		Env:         env,
+		Network:     network,
+	}
+	devContainer, err := cli.CreateContainer(ctx, config)
+	if errors.Is(err, container.ErrImageNotFound) {
+		// the CLI's image can be in a private registry, see GlobalConfig.DevcontainerImage
+		if err := pullImage(ctx, cli, dc.BoxConfig, s.image); err != nil {
+			return err
+		}
+		devContainer, err = cli.CreateContainer(ctx, config)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating container: %v", err)
+	}
+
+	if configJSON != nil {
+		err = devContainer.CreateFile(ctx, configPath, configJSON)
+		if err != nil {
+			return fmt.Errorf("error creating config file: %v", err)
+		}
+	}
+
+	if secretsPath != "" {
+		secretsJSON, err := json.Marshal(dc.Secrets)
+		if err != nil {
+			return fmt.Errorf("error serializing secrets: %v", err)
+		}
+		if err := devContainer.CreatePrivateFile(ctx, secretsPath, secretsJSON); err != nil {
+			return fmt.Errorf("error creating secrets file: %v", err)
+		}
+	}
+
+	authsJSON, err := json.Marshal(auths)
+	if err != nil {
+		return fmt.Errorf("error serializing registry credentials: %v", err)
+	}
+	if err := devContainer.CreateFile(ctx, "/tmp/config.json", authsJSON); err != nil {
+		return fmt.Errorf("error creating docker config file: %v", err)
+	}
+
+	err = devContainer.AttachAndRun(ctx)
+	if err != nil {
+		return fmt.Errorf("error attaching and running container: %w", err)
+	}
+
+	return nil
+}
+
+// localBinaryStrategy runs a devcontainer CLI installed on the host
+type localBinaryStrategy struct {
+	binary string
+	// containerd has the CLI run nerdctl instead of docker when set
+	containerd *ContainerdConfig
+	// env is added to the CLI's environment, see devcontainerCliEnv
+	env []string
+}
+
+func (s localBinaryStrategy) runsOnHost() bool {
+	return true
+}
+
+func (s localBinaryStrategy) help(command string) error {
+	cmd := exec.Command(s.binary, command, "--help")
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	cmd.Env = append(os.Environ(), s.env...)
+	return cmd.Run()
+}
+
+func (s localBinaryStrategy) run(dc *DevcontainerCommand, configJSON []byte, hostPaths []string, auths *container.DockerConfig) error {
+	configPath := ""
+	if configJSON != nil {
+		// write next to the original so paths relative to the config still resolve
+		configFile, err := os.CreateTemp(filepath.Dir(dc.BoxConfig.Config), ".tape-devcontainer-*.json")
+		if err != nil {
+			return fmt.Errorf("error creating config file: %v", err)
+		}
+		defer os.Remove(configFile.Name())
+
+		_, err = configFile.Write(configJSON)
+		configFile.Close()
+		if err != nil {
+			return fmt.Errorf("error creating config file: %v", err)
+		}
+		configPath = configFile.Name()
+	}
+
+	secretsPath := ""
+	if len(dc.Secrets) > 0 {
+		path, cleanup, err := writeSecretsFile(dc.Secrets)
+		if err != nil {
+			return err
+		}
+		defer cleanup()
+		secretsPath = path
+	}
+
+	args := secretsFileArgs(secretsPath, dc.AdditionalArgs)
+	if s.containerd != nil {
+		args = append([]string{"--docker-path", "nerdctl"}, args...)
+	}
+	devConArgs := buildDevcontainerArgs(dc.Command, dc.BoxConfig.Workspace, configPath, args)
+	cmd := exec.Command(s.binary, devConArgs[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	env, cleanup, err := hostDockerEnv(dc.BoxConfig, s.containerd, auths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	cmd.Env = append(env, s.env...)
+
+	return cmd.Run()
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildDevcontainerArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		command        string
+		configPath     string
+		additionalArgs []string
+		expected       []string
+	}{
+		{
+			name:     "no config",
+			command:  "up",
+			expected: []string{"devcontainer", "up", "--workspace-folder", "/src/app"},
+		},
+		{
+			name:       "with config",
+			command:    "up",
+			configPath: "/tmp/devcontainer.json",
+			expected:   []string{"devcontainer", "up", "--workspace-folder", "/src/app", "--config", "/tmp/devcontainer.json"},
+		},
+		{
+			name:           "additional args",
+			command:        "up",
+			configPath:     "/tmp/devcontainer.json",
+			additionalArgs: []string{"--remove-existing-container"},
+			expected:       []string{"devcontainer", "up", "--workspace-folder", "/src/app", "--config", "/tmp/devcontainer.json", "--remove-existing-container"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := buildDevcontainerArgs(tt.command, "/src/app", tt.configPath, tt.additionalArgs)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("buildDevcontainerArgs() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNewExecutionStrategy(t *testing.T) {
+	strategy, err := newExecutionStrategy(&GlobalConfig{})
+	if err != nil {
+		t.Fatalf("newExecutionStrategy() error = %v", err)
+	}
+	if got, ok := strategy.(containerStrategy); !ok || got.image != DevContainerCliImage {
+		t.Errorf("newExecutionStrategy() = %#v, want the container strategy with the default image", strategy)
+	}
+
+	strategy, err = newExecutionStrategy(&GlobalConfig{DevcontainerImage: "custom:latest"})
+	if err != nil {
+		t.Fatalf("newExecutionStrategy() error = %v", err)
+	}
+	if got, ok := strategy.(containerStrategy); !ok || got.image != "custom:latest" {
+		t.Errorf("newExecutionStrategy() = %#v, want the container strategy with custom:latest", strategy)
+	}
+
+	strategy, err = newExecutionStrategy(&GlobalConfig{DockerSocketProxy: true})
+	if err != nil {
+		t.Fatalf("newExecutionStrategy() error = %v", err)
+	}
+	if got, ok := strategy.(containerStrategy); !ok || !got.socketProxy {
+		t.Errorf("newExecutionStrategy() = %#v, want the container strategy through the socket proxy", strategy)
+	}
+
+	strategy, err = newExecutionStrategy(&GlobalConfig{DisableTelemetry: true})
+	if err != nil {
+		t.Fatalf("newExecutionStrategy() error = %v", err)
+	}
+	if got, ok := strategy.(containerStrategy); !ok || !reflect.DeepEqual(got.env, []string{"DO_NOT_TRACK=1"}) {
+		t.Errorf("newExecutionStrategy() = %#v, want the container strategy with DO_NOT_TRACK set", strategy)
+	}
+}
@@ -0,0 +1,148 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// FeatureMetadata is the part of a feature's devcontainer-feature.json that
+// changes how the container is run. The devcontainer CLI applies it on top
+// of the config's runArgs.
+type FeatureMetadata struct {
+	ID          string   `json:"id"`
+	Privileged  bool     `json:"privileged,omitempty"`
+	CapAdd      []string `json:"capAdd,omitempty"`
+	SecurityOpt []string `json:"securityOpt,omitempty"`
+	// Mounts are mounted into the container, e.g. the docker socket
+	Mounts []devcontainer.MountValue `json:"mounts,omitempty"`
+}
+
+// RunArgs returns the docker run flags the feature's metadata amounts to, in
+// the forms of runArgForms
+func (m FeatureMetadata) RunArgs() []string {
+	var args []string
+	if m.Privileged {
+		args = append(args, "--privileged")
+	}
+	for _, capability := range m.CapAdd {
+		args = append(args, "--cap-add", "--cap-add="+capability)
+	}
+	for _, opt := range m.SecurityOpt {
+		args = append(args, "--security-opt", "--security-opt="+opt)
+	}
+	for _, mount := range m.Mounts {
+		if spec, err := mount.DockerMountSpec(); err == nil {
+			args = append(args, "--mount", "--mount="+spec)
+		}
+	}
+	return args
+}
+
+const featureMetadataFile = "devcontainer-feature.json"
+
+// featureMetadata reads a feature's devcontainer-feature.json: from its
+// directory for local features, its manifest's annotation or its layer for
+// OCI features, and its archive for tarballs. configDir is the directory of
+// the devcontainer config local features are relative to.
+func featureMetadata(registry *registryClient, ref devcontainer.FeatureRef, configDir string) (*FeatureMetadata, error) {
+	var data []byte
+	var err error
+	switch ref.Kind {
+	case devcontainer.FeatureRefLocal:
+		data, err = os.ReadFile(filepath.Join(configDir, filepath.FromSlash(ref.Path), featureMetadataFile))
+	case devcontainer.FeatureRefTarball:
+		data, err = fetchTarballMetadata(ref.Path)
+	default:
+		data, err = registry.featureMetadata(ref)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading metadata of feature %s: %w", ref.Raw, err)
+	}
+
+	var metadata FeatureMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("error parsing metadata of feature %s: %v", ref.Raw, err)
+	}
+	return &metadata, nil
+}
+
+// featureMetadata returns the metadata publishers store in the manifest's
+// annotation, falling back to the file in the feature's layer
+func (c *registryClient) featureMetadata(ref devcontainer.FeatureRef) ([]byte, error) {
+	if Offline {
+		return nil, fmt.Errorf("%w: reading %s needs the registry", ErrOffline, ref)
+	}
+	reference := ref.Version
+	if ref.Digest != "" {
+		reference = ref.Digest
+	}
+	data, err := c.get(ref, "manifests/"+reference, ociManifestMediaType)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest: %v", err)
+	}
+	if metadata := manifest.Annotations[featureMetadataAnnotation]; metadata != "" {
+		return []byte(metadata), nil
+	}
+
+	layer, err := c.fetchLayer(ref)
+	if err != nil {
+		return nil, err
+	}
+	return readTarFile(bytes.NewReader(layer), featureMetadataFile)
+}
+
+// fetchTarballMetadata downloads a feature's .tgz and reads its metadata
+func fetchTarballMetadata(url string) ([]byte, error) {
+	if Offline {
+		return nil, fmt.Errorf("%w: downloading %s", ErrOffline, url)
+	}
+	resp, err := policyClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	gzipReader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	defer gzipReader.Close()
+	return readTarFile(gzipReader, featureMetadataFile)
+}
+
+// readTarFile returns the content of the file at the archive's root with the
+// given name, which may be written as ./name
+func readTarFile(r io.Reader, name string) ([]byte, error) {
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s not found", name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && path.Clean(header.Name) == name {
+			return io.ReadAll(tarReader)
+		}
+	}
+}
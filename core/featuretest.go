@@ -0,0 +1,225 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+)
+
+// DefaultFeatureTestImage is the base image features are tested on when none is given
+const DefaultFeatureTestImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+// FeatureTestOptions configures TestFeature
+type FeatureTestOptions struct {
+	// FeaturePath is the feature's directory, containing devcontainer-feature.json
+	FeaturePath string
+	// TestScript defaults to test.sh in the feature directory, or
+	// test/<id>/test.sh in the feature collection it belongs to
+	TestScript string
+	BaseImage  string
+	// Options are the feature's options, as in the devcontainer.json features map
+	Options map[string]interface{}
+	// Keep leaves the scratch container running after the test
+	Keep bool
+}
+
+// TestFeature builds a scratch environment with a local feature applied and
+// runs the feature's test script inside it
+func TestFeature(opts FeatureTestOptions) error {
+	featurePath, err := filepath.Abs(opts.FeaturePath)
+	if err != nil {
+		return fmt.Errorf("error resolving %s: %v", opts.FeaturePath, err)
+	}
+
+	id, err := featureID(featurePath)
+	if err != nil {
+		return err
+	}
+
+	testScript := opts.TestScript
+	if testScript == "" {
+		testScript, err = findFeatureTestScript(featurePath, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	baseImage := opts.BaseImage
+	if baseImage == "" {
+		baseImage = DefaultFeatureTestImage
+	}
+
+	boxConfig, err := prepareFeatureTestWorkspace(featurePath, id, testScript, baseImage, opts.Options)
+	if err != nil {
+		return err
+	}
+
+	up := DevcontainerCommand{
+		BoxConfig:      *boxConfig,
+		Command:        "up",
+		AdditionalArgs: []string{"--remove-existing-container"},
+	}
+	if err := up.Execute(); err != nil {
+		return fmt.Errorf("error building feature test environment: %w", err)
+	}
+
+	if !opts.Keep {
+		defer removeFeatureTestContainer(*boxConfig)
+	}
+
+	test := DevcontainerCommand{
+		BoxConfig:      *boxConfig,
+		Command:        "exec",
+		AdditionalArgs: []string{"bash", "test.sh"},
+	}
+	return test.Execute()
+}
+
+// featureID reads the feature's id from devcontainer-feature.json
+func featureID(featurePath string) (string, error) {
+	data, err := os.ReadFile(filepath.Join(featurePath, "devcontainer-feature.json"))
+	if err != nil {
+		return "", fmt.Errorf("%s is not a feature directory: %v", featurePath, err)
+	}
+
+	var metadata struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return "", fmt.Errorf("error parsing devcontainer-feature.json: %v", err)
+	}
+	id := metadata.ID
+	if id == "" {
+		id = filepath.Base(featurePath)
+	}
+	if err := checkFeatureID(id); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// featureIDPattern is the form of the feature ids the spec allows
+var featureIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// checkFeatureID rejects ids that aren't a single path component, since the
+// id names the feature's scratch workspace, which is removed before each test
+func checkFeatureID(id string) error {
+	if !filepath.IsLocal(id) || !featureIDPattern.MatchString(id) {
+		return fmt.Errorf("invalid feature id %q, ids may only contain letters, digits, - and _", id)
+	}
+	return nil
+}
+
+// findFeatureTestScript looks for the test script next to the feature, then
+// where the devcontainer CLI expects it in a feature collection (src/<id>, test/<id>)
+func findFeatureTestScript(featurePath string, id string) (string, error) {
+	candidates := []string{
+		filepath.Join(featurePath, "test.sh"),
+		filepath.Join(featurePath, "..", "..", "test", id, "test.sh"),
+	}
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return filepath.Clean(candidate), nil
+		}
+	}
+	return "", fmt.Errorf("no test script found for %s, looked in %v", id, candidates)
+}
+
+// prepareFeatureTestWorkspace writes a scratch workspace under the config
+// directory with the feature copied into its .devcontainer folder, since the
+// devcontainer CLI only allows local features inside it
+func prepareFeatureTestWorkspace(featurePath, id, testScript, baseImage string, options map[string]interface{}) (*BoxConfig, error) {
+	if err := checkFeatureID(id); err != nil {
+		return nil, err
+	}
+	workspace := filepath.Join(ConfigDir, ".feature-tests", id)
+	if err := os.RemoveAll(workspace); err != nil {
+		return nil, fmt.Errorf("error cleaning %s: %v", workspace, err)
+	}
+
+	devcontainerDir := filepath.Join(workspace, ".devcontainer")
+	if err := copyDir(featurePath, filepath.Join(devcontainerDir, id)); err != nil {
+		return nil, fmt.Errorf("error copying feature: %v", err)
+	}
+
+	script, err := os.ReadFile(testScript)
+	if err != nil {
+		return nil, fmt.Errorf("error reading test script: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, "test.sh"), script, 0755); err != nil {
+		return nil, fmt.Errorf("error writing test script: %v", err)
+	}
+
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+	config := map[string]interface{}{
+		"image":    baseImage,
+		"features": map[string]interface{}{"./" + id: options},
+	}
+	configJSON, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	configPath := filepath.Join(devcontainerDir, "devcontainer.json")
+	if err := os.WriteFile(configPath, configJSON, 0644); err != nil {
+		return nil, fmt.Errorf("error writing %s: %v", configPath, err)
+	}
+
+	return &BoxConfig{
+		Name:      "feature-test-" + id,
+		Workspace: workspace,
+		Config:    configPath,
+	}, nil
+}
+
+func removeFeatureTestContainer(boxConfig BoxConfig) {
+	dc, err := FindDevContainer(boxConfig)
+	if err != nil {
+		return
+	}
+
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return
+	}
+	defer cli.Close()
+
+	cli.RemoveContainer(context.Background(), dc.ID)
+}
+
+// copyDir copies the files under src to dst, keeping their permissions
+func copyDir(src string, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm()|0700)
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPrepareFeatureTestWorkspace(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"features/src/hello/devcontainer-feature.json": `{"id": "hello", "version": "1.0.0"}`,
+		"features/src/hello/install.sh":                "echo installing\n",
+		"features/test/hello/test.sh":                  "hello --version\n",
+	})
+	featurePath := filepath.Join(ConfigDir, "features/src/hello")
+
+	id, err := featureID(featurePath)
+	if err != nil || id != "hello" {
+		t.Fatalf("featureID() = %v, %v, want hello", id, err)
+	}
+
+	testScript, err := findFeatureTestScript(featurePath, id)
+	if err != nil {
+		t.Fatalf("findFeatureTestScript() error = %v", err)
+	}
+	if expected := filepath.Join(ConfigDir, "features/test/hello/test.sh"); testScript != expected {
+		t.Errorf("findFeatureTestScript() = %v, want %v", testScript, expected)
+	}
+
+	boxConfig, err := prepareFeatureTestWorkspace(featurePath, id, testScript, "ubuntu", map[string]interface{}{"version": "2"})
+	if err != nil {
+		t.Fatalf("prepareFeatureTestWorkspace() error = %v", err)
+	}
+
+	for _, path := range []string{".devcontainer/hello/install.sh", ".devcontainer/hello/devcontainer-feature.json", "test.sh"} {
+		if _, err := os.Stat(filepath.Join(boxConfig.Workspace, path)); err != nil {
+			t.Errorf("prepareFeatureTestWorkspace() did not write %s: %v", path, err)
+		}
+	}
+
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Image != "ubuntu" || !reflect.DeepEqual(config.Features["./hello"], map[string]interface{}{"version": "2"}) {
+		t.Errorf("prepareFeatureTestWorkspace() wrote config %+v", config)
+	}
+}
+
+func TestFeatureIDRejectsPaths(t *testing.T) {
+	for _, id := range []string{"../..", "..", ".", "a/b", "/etc"} {
+		setupConfigDir(t, map[string]string{
+			"feature/devcontainer-feature.json": `{"id": "` + id + `"}`,
+		})
+		if got, err := featureID(filepath.Join(ConfigDir, "feature")); err == nil {
+			t.Errorf("featureID() with id %q = %q, want an error", id, got)
+		}
+		if _, err := prepareFeatureTestWorkspace(t.TempDir(), id, "", "ubuntu", nil); err == nil {
+			t.Errorf("prepareFeatureTestWorkspace() with id %q didn't fail", id)
+		}
+	}
+}
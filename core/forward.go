@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// DialBox connects to a port of the box's running container, see
+// dialBoxPort for how the container is reached
+func DialBox(ctx context.Context, envName string, containerPort int) (net.Conn, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := GetBoxSummary(envName)
+	if err != nil {
+		return nil, err
+	}
+	if summary.State != BoxStateRunning {
+		return nil, fmt.Errorf("%w: %s is %s", ErrNotRunning, envName, summary.State)
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+	return dialBoxPort(ctx, cli, summary.ContainerID, containerPort)
+}
+
+// ForwardPort accepts connections on listener and proxies each one to
+// containerPort on the box's container until ctx is cancelled, see
+// dialBoxPort for how the container is reached.
+func ForwardPort(ctx context.Context, envName string, listener net.Listener, containerPort int) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+	summary, err := GetBoxSummary(envName)
+	if err != nil {
+		return err
+	}
+	if summary.State != BoxStateRunning {
+		return fmt.Errorf("%w: %s is %s", ErrNotRunning, envName, summary.State)
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	target := fmt.Sprintf("%s:%d", envName, containerPort)
+	dial := func(ctx context.Context) (net.Conn, error) {
+		return dialBoxPort(ctx, cli, summary.ContainerID, containerPort)
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			proxyConn(ctx, conn, target, dial)
+		}()
+	}
+}
+
+// dialBoxPort connects to a port of a running container, see
+// container.Backend's DialContainer. Ports only listening on the container's
+// loopback address can't be reached that way, they are relayed by socat, or
+// nc when it isn't installed, run in the container.
+func dialBoxPort(ctx context.Context, cli container.Backend, containerID string, port int) (net.Conn, error) {
+	// without the listeners, e.g. in a container without cat, dial directly
+	listeners, err := readListeners(ctx, cli, containerID)
+	if address := loopbackAddress(listeners[port]); err == nil && address != "" {
+		return cli.ExecConn(ctx, containerID, container.ExecConfig{
+			Command: relayCommand(address, port),
+			User:    "root",
+		})
+	}
+	return cli.DialContainer(ctx, containerID, port)
+}
+
+// relayCommand returns the command connecting its stdin and stdout to a port
+// on the container's loopback address
+func relayCommand(address string, port int) []string {
+	script := `if command -v socat >/dev/null 2>&1; then exec socat - "TCP:$1"; fi; exec nc "$2" "$3"`
+	return []string{"sh", "-c", script, "relay", net.JoinHostPort(address, strconv.Itoa(port)), address, strconv.Itoa(port)}
+}
+
+func proxyConn(ctx context.Context, conn net.Conn, target string, dial func(context.Context) (net.Conn, error)) {
+	defer conn.Close()
+
+	upstream, err := dial(ctx)
+	if err != nil {
+		log.Printf("Error connecting to %s: %v", target, err)
+		return
+	}
+	defer upstream.Close()
+
+	// closing both ends when ctx is cancelled stops the copies
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+		upstream.Close()
+	})
+	defer stop()
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(upstream, conn)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(conn, upstream)
+		done <- struct{}{}
+	}()
+	<-done
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BoxFileInfo describes a file in a box's container
+type BoxFileInfo struct {
+	Path string
+	// Type is how stat describes the file, e.g. "regular file" or "directory"
+	Type     string
+	Mode     string
+	Size     int64
+	Owner    string
+	Modified time.Time
+}
+
+// Name returns the file's base name
+func (f BoxFileInfo) Name() string {
+	return path.Base(f.Path)
+}
+
+// IsDir reports whether the file is a directory
+func (f BoxFileInfo) IsDir() bool {
+	return f.Type == "directory"
+}
+
+// statFormat makes stat print the fields of a BoxFileInfo separated by tabs,
+// the path last so tabs in it survive. GNU and busybox stat both support it.
+const statFormat = "%F\t%A\t%s\t%U\t%Y\t%n"
+
+// StatBoxPath describes a file in the box's container. A relative path is
+// relative to the workspace folder.
+func StatBoxPath(envName string, p string) (*BoxFileInfo, error) {
+	output, err := runBoxFsCommand(envName, p, func(p string) []string {
+		return []string{"stat", "-c", statFormat, "--", p}
+	})
+	if err != nil {
+		return nil, err
+	}
+	infos, err := parseStatOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	if len(infos) != 1 {
+		return nil, fmt.Errorf("unexpected stat output: %q", output)
+	}
+	return &infos[0], nil
+}
+
+// ListBoxDir describes the files in a directory in the box's container,
+// including hidden ones, sorted by name. A relative path is relative to the
+// workspace folder.
+func ListBoxDir(envName string, p string) ([]BoxFileInfo, error) {
+	output, err := runBoxFsCommand(envName, p, func(p string) []string {
+		return []string{"find", p, "-mindepth", "1", "-maxdepth", "1", "-exec", "stat", "-c", statFormat, "--", "{}", "+"}
+	})
+	if err != nil {
+		return nil, err
+	}
+	infos, err := parseStatOutput(output)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(infos, func(a, b BoxFileInfo) int { return strings.Compare(a.Path, b.Path) })
+	return infos, nil
+}
+
+// ReadBoxFile returns the content of a file in the box's container. A
+// relative path is relative to the workspace folder.
+func ReadBoxFile(envName string, p string) ([]byte, error) {
+	return runBoxFsCommand(envName, p, func(p string) []string {
+		return []string{"cat", "--", p}
+	})
+}
+
+// runBoxFsCommand runs the command for a path as the remote user and returns
+// its output. The path is passed as an argument rather than through a shell,
+// so it needs no quoting.
+func runBoxFsCommand(envName string, p string, command func(p string) []string) ([]byte, error) {
+	boxConfig, dc, err := runningBoxContainer(envName)
+	if err != nil {
+		return nil, err
+	}
+	p, err = containerPath(*boxConfig, p)
+	if err != nil {
+		return nil, err
+	}
+	config, err := execConfig(*boxConfig, ExecOptions{Command: command(p)})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := dc.Exec(context.Background(), config)
+	if err != nil {
+		return nil, fmt.Errorf("error running command: %w", err)
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("%s", strings.TrimSpace(string(result.Stderr)))
+	}
+	return result.Stdout, nil
+}
+
+// parseStatOutput parses the lines stat prints with statFormat
+func parseStatOutput(output []byte) ([]BoxFileInfo, error) {
+	var infos []BoxFileInfo
+	for _, line := range strings.Split(strings.TrimSuffix(string(output), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		fields := strings.SplitN(line, "\t", 6)
+		if len(fields) != 6 {
+			return nil, fmt.Errorf("unexpected stat output: %q", line)
+		}
+		size, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected size in stat output: %q", line)
+		}
+		modified, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected time in stat output: %q", line)
+		}
+		infos = append(infos, BoxFileInfo{
+			Type:     fields[0],
+			Mode:     fields[1],
+			Size:     size,
+			Owner:    fields[3],
+			Modified: time.Unix(modified, 0),
+			Path:     fields[5],
+		})
+	}
+	return infos, nil
+}
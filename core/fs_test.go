@@ -0,0 +1,31 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseStatOutput(t *testing.T) {
+	output := []byte("directory\tdrwxr-xr-x\t4096\tnode\t1714557600\t/app/src\n" +
+		"regular file\t-rw-r--r--\t12\troot\t1714557660\t/app/with\ttab.txt\n")
+	expected := []BoxFileInfo{
+		{Path: "/app/src", Type: "directory", Mode: "drwxr-xr-x", Size: 4096, Owner: "node", Modified: time.Unix(1714557600, 0)},
+		{Path: "/app/with\ttab.txt", Type: "regular file", Mode: "-rw-r--r--", Size: 12, Owner: "root", Modified: time.Unix(1714557660, 0)},
+	}
+
+	got, err := parseStatOutput(output)
+	if err != nil {
+		t.Fatalf("parseStatOutput() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("parseStatOutput() = %+v, want %+v", got, expected)
+	}
+	if !got[0].IsDir() || got[1].IsDir() || got[1].Name() != "with\ttab.txt" {
+		t.Errorf("IsDir() and Name() don't match the stat output: %+v", got)
+	}
+
+	if _, err := parseStatOutput([]byte("stat: can't stat '/nope'\n")); err == nil {
+		t.Error("parseStatOutput() of unexpected output should fail")
+	}
+}
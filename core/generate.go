@@ -0,0 +1,236 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+	"gopkg.in/yaml.v2"
+)
+
+// composeFiles are the compose file names docker compose looks for, in its order
+var composeFiles = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// preferredServices are the compose service names that usually hold the code
+var preferredServices = []string{"app", "dev", "devcontainer", "workspace", "web", "api"}
+
+// languageMarker maps a file found in a workspace to the devcontainer image
+// for its language, and the feature that adds the language to another image
+type languageMarker struct {
+	files   []string
+	image   string
+	feature string
+}
+
+var languageMarkers = []languageMarker{
+	{[]string{"go.mod"}, "mcr.microsoft.com/devcontainers/go:1", "ghcr.io/devcontainers/features/go:1"},
+	{[]string{"Cargo.toml"}, "mcr.microsoft.com/devcontainers/rust:1", "ghcr.io/devcontainers/features/rust:1"},
+	{[]string{"tsconfig.json"}, "mcr.microsoft.com/devcontainers/typescript-node:1", "ghcr.io/devcontainers/features/node:1"},
+	{[]string{"package.json"}, "mcr.microsoft.com/devcontainers/javascript-node:1", "ghcr.io/devcontainers/features/node:1"},
+	{[]string{"pyproject.toml", "requirements.txt", "Pipfile", "setup.py"}, "mcr.microsoft.com/devcontainers/python:3", "ghcr.io/devcontainers/features/python:1"},
+	{[]string{"Gemfile"}, "mcr.microsoft.com/devcontainers/ruby:3", "ghcr.io/devcontainers/features/ruby:1"},
+	{[]string{"pom.xml", "build.gradle", "build.gradle.kts"}, "mcr.microsoft.com/devcontainers/java:1", "ghcr.io/devcontainers/features/java:1"},
+	{[]string{"composer.json"}, "mcr.microsoft.com/devcontainers/php:1", "ghcr.io/devcontainers/features/php:1"},
+}
+
+// fallbackImage is used when nothing in the workspace suggests a language
+const fallbackImage = "mcr.microsoft.com/devcontainers/base:ubuntu"
+
+// GeneratedConfig is a devcontainer config guessed from a workspace
+type GeneratedConfig struct {
+	Config *devcontainer.DevContainerConfig
+	// Source describes what the config was generated from
+	Source string
+}
+
+// GenerateDevContainerConfig guesses a devcontainer config for a workspace
+// without one. A compose file is preferred, then a Dockerfile, then an image
+// for the languages found. Paths in the config are relative to the
+// workspace's .devcontainer directory, where it is meant to be written.
+func GenerateDevContainerConfig(workspace string) (*GeneratedConfig, error) {
+	config := &devcontainer.DevContainerConfig{Name: filepath.Base(workspace)}
+
+	for _, name := range composeFiles {
+		data, err := os.ReadFile(filepath.Join(workspace, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", name, err)
+		}
+
+		service, ports, err := composeService(data)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", name, err)
+		}
+		config.DockerComposeFile = devcontainer.NewComposeFile(filepath.Join("..", name))
+		config.Service = service
+		config.WorkspaceFolder = "/workspaces/${localWorkspaceFolderBasename}"
+		config.ForwardPorts = forwardPorts(ports)
+		return &GeneratedConfig{Config: config, Source: fmt.Sprintf("service %s in %s", service, name)}, nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(workspace, "Dockerfile"))
+	if err == nil {
+		config.Build = &devcontainer.BuildOptions{Dockerfile: "../Dockerfile", Context: ".."}
+		config.ForwardPorts = forwardPorts(dockerfileExposes(data))
+		return &GeneratedConfig{Config: config, Source: "Dockerfile"}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading Dockerfile: %v", err)
+	}
+
+	var found []languageMarker
+	for _, marker := range languageMarkers {
+		for _, file := range marker.files {
+			if _, err := os.Stat(filepath.Join(workspace, file)); err == nil {
+				found = append(found, marker)
+				break
+			}
+		}
+	}
+	if len(found) == 0 {
+		config.Image = fallbackImage
+		return &GeneratedConfig{Config: config, Source: "no language detected"}, nil
+	}
+
+	// the first language gets its image, the others are added as features
+	config.Image = found[0].image
+	var sources []string
+	for _, marker := range found {
+		sources = append(sources, marker.files[0])
+		if marker.feature == found[0].feature {
+			continue
+		}
+		if config.Features == nil {
+			config.Features = map[string]interface{}{}
+		}
+		config.Features[marker.feature] = map[string]interface{}{}
+	}
+	return &GeneratedConfig{Config: config, Source: strings.Join(sources, ", ")}, nil
+}
+
+// composeProject is the part of a compose file needed to pick a service
+type composeProject struct {
+	Services map[string]struct {
+		Build  interface{}   `yaml:"build"`
+		Ports  []interface{} `yaml:"ports"`
+		Expose []interface{} `yaml:"expose"`
+	} `yaml:"services"`
+}
+
+// composeService picks the service to develop in and returns the container
+// ports it publishes or exposes. Services with a preferred name win, then
+// services that are built from the workspace rather than pulled.
+func composeService(data []byte) (string, []int, error) {
+	var project composeProject
+	if err := yaml.Unmarshal(data, &project); err != nil {
+		return "", nil, err
+	}
+	if len(project.Services) == 0 {
+		return "", nil, fmt.Errorf("no services")
+	}
+
+	names := make([]string, 0, len(project.Services))
+	for name := range project.Services {
+		names = append(names, name)
+	}
+	// ties are broken by name
+	sort.Slice(names, func(i, j int) bool {
+		ri := serviceRank(names[i], project.Services[names[i]].Build != nil)
+		rj := serviceRank(names[j], project.Services[names[j]].Build != nil)
+		return ri < rj || (ri == rj && names[i] < names[j])
+	})
+
+	service := project.Services[names[0]]
+	var ports []int
+	for _, entry := range append(service.Ports, service.Expose...) {
+		if port, ok := composePort(entry); ok {
+			ports = append(ports, port)
+		}
+	}
+	return names[0], ports, nil
+}
+
+func serviceRank(name string, built bool) int {
+	if i := slices.Index(preferredServices, name); i >= 0 {
+		return i
+	}
+	if built {
+		return len(preferredServices)
+	}
+	return len(preferredServices) + 1
+}
+
+// composePort returns the container port of a ports or expose entry, e.g.
+// 3000, "8080:80", "127.0.0.1:5432:5432/tcp" or {target: 80}. Port ranges
+// and UDP ports, which can't be forwarded, are skipped.
+func composePort(entry interface{}) (int, bool) {
+	var value string
+	switch v := entry.(type) {
+	case int:
+		return v, true
+	case string:
+		value = v
+	case map[interface{}]interface{}:
+		target, ok := v["target"].(int)
+		if protocol, _ := v["protocol"].(string); protocol != "" && protocol != "tcp" {
+			return 0, false
+		}
+		return target, ok
+	default:
+		return 0, false
+	}
+
+	value, protocol, _ := strings.Cut(value, "/")
+	if protocol != "" && protocol != "tcp" {
+		return 0, false
+	}
+	if i := strings.LastIndex(value, ":"); i >= 0 {
+		value = value[i+1:]
+	}
+	port, err := strconv.Atoi(value)
+	return port, err == nil
+}
+
+// dockerfileExposes returns the TCP ports in a Dockerfile's EXPOSE
+// instructions, skipping ones given as build arguments
+func dockerfileExposes(data []byte) []int {
+	var ports []int
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || !strings.EqualFold(fields[0], "EXPOSE") {
+			continue
+		}
+		for _, field := range fields[1:] {
+			field, protocol, _ := strings.Cut(field, "/")
+			if protocol != "" && protocol != "tcp" {
+				continue
+			}
+			if port, err := strconv.Atoi(field); err == nil {
+				ports = append(ports, port)
+			}
+		}
+	}
+	return ports
+}
+
+// forwardPorts returns the ports sorted and without duplicates as
+// devcontainer.json forwardPorts
+func forwardPorts(ports []int) []interface{} {
+	slices.Sort(ports)
+	ports = slices.Compact(ports)
+	var forward []interface{}
+	for _, port := range ports {
+		forward = append(forward, port)
+	}
+	return forward
+}
@@ -0,0 +1,112 @@
+package core
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestComposePort(t *testing.T) {
+	tests := []struct {
+		entry    interface{}
+		expected int
+		ok       bool
+	}{
+		{3000, 3000, true},
+		{"3000", 3000, true},
+		{"8080:80", 80, true},
+		{"127.0.0.1:5432:5432/tcp", 5432, true},
+		{"53:53/udp", 0, false},
+		{"8000-8001:8000-8001", 0, false},
+		{map[interface{}]interface{}{"target": 443, "published": 8443}, 443, true},
+		{map[interface{}]interface{}{"target": 53, "protocol": "udp"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		port, ok := composePort(tt.entry)
+		if port != tt.expected || ok != tt.ok {
+			t.Errorf("composePort(%v) = %d, %v, want %d, %v", tt.entry, port, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestDockerfileExposes(t *testing.T) {
+	dockerfile := "FROM golang AS build\nexpose 9000\nFROM debian\nEXPOSE 8080 8443/tcp 53/udp $PORT\n"
+	if got := dockerfileExposes([]byte(dockerfile)); !reflect.DeepEqual(got, []int{9000, 8080, 8443}) {
+		t.Errorf("dockerfileExposes() = %v, want [9000 8080 8443]", got)
+	}
+}
+
+func TestGenerateDevContainerConfig(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		source   string
+		expected string
+	}{
+		{
+			name: "compose prefers built services",
+			files: map[string]string{
+				"docker-compose.yml": "services:\n  db:\n    image: postgres\n    ports: [\"5432:5432\"]\n  server:\n    build: .\n    ports: [\"8080:80\"]\n",
+				"Dockerfile":         "FROM debian\n",
+			},
+			source:   "service server in docker-compose.yml",
+			expected: `{"name":"ws","forwardPorts":[80],"workspaceFolder":"/workspaces/${localWorkspaceFolderBasename}","dockerComposeFile":"../docker-compose.yml","service":"server"}`,
+		},
+		{
+			name:     "compose prefers app",
+			files:    map[string]string{"compose.yaml": "services:\n  worker:\n    build: .\n  app:\n    image: node\n"},
+			source:   "service app in compose.yaml",
+			expected: `{"name":"ws","workspaceFolder":"/workspaces/${localWorkspaceFolderBasename}","dockerComposeFile":"../compose.yaml","service":"app"}`,
+		},
+		{
+			name:     "dockerfile",
+			files:    map[string]string{"Dockerfile": "FROM debian\nEXPOSE 3000\n", "go.mod": ""},
+			source:   "Dockerfile",
+			expected: `{"name":"ws","forwardPorts":[3000],"build":{"dockerfile":"../Dockerfile","context":".."}}`,
+		},
+		{
+			name:     "languages",
+			files:    map[string]string{"pyproject.toml": "", "package.json": "", "tsconfig.json": ""},
+			source:   "tsconfig.json, package.json, pyproject.toml",
+			expected: `{"name":"ws","features":{"ghcr.io/devcontainers/features/python:1":{}},"image":"mcr.microsoft.com/devcontainers/typescript-node:1"}`,
+		},
+		{
+			name:     "nothing",
+			files:    map[string]string{"README.md": ""},
+			source:   "no language detected",
+			expected: `{"name":"ws","image":"mcr.microsoft.com/devcontainers/base:ubuntu"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workspace := filepath.Join(t.TempDir(), "ws")
+			if err := os.Mkdir(workspace, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for name, content := range tt.files {
+				if err := os.WriteFile(filepath.Join(workspace, name), []byte(content), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			generated, err := GenerateDevContainerConfig(workspace)
+			if err != nil {
+				t.Fatalf("GenerateDevContainerConfig() error = %v", err)
+			}
+			if generated.Source != tt.source {
+				t.Errorf("GenerateDevContainerConfig() source = %q, want %q", generated.Source, tt.source)
+			}
+			data, err := json.Marshal(generated.Config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("GenerateDevContainerConfig() = %s, want %s", data, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// GroupPrefix marks a group in place of an environment name, e.g. tape up @backend
+const GroupPrefix = "@"
+
+// IsGroup reports whether name refers to a group rather than an environment
+func IsGroup(name string) bool {
+	return strings.HasPrefix(name, GroupPrefix)
+}
+
+// ResolveGroup returns the boxes of a group, given with or without
+// GroupPrefix, in the order they are started: every box after the boxes it
+// depends on. Dependencies that aren't in the group are included, like
+// docker compose starts the services a service depends on.
+func ResolveGroup(name string) ([]string, error) {
+	name = strings.TrimPrefix(name, GroupPrefix)
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	boxes, ok := globalConfig.Groups[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s is not defined in %s", ErrGroupNotFound, name, GlobalConfigPath())
+	}
+
+	return startOrder(boxes, func(envName string) ([]string, error) {
+		boxConfig, err := LoadBoxConfig(envName)
+		if err != nil {
+			return nil, err
+		}
+		return boxConfig.DependsOn, nil
+	})
+}
+
+// startOrder orders boxes and their dependencies so every box comes after
+// the ones it depends on. Boxes keep their order otherwise.
+func startOrder(boxes []string, dependsOn func(string) ([]string, error)) ([]string, error) {
+	var order []string
+	// visiting holds the path of dependencies being resolved, to report cycles
+	var visiting []string
+
+	var visit func(envName string) error
+	visit = func(envName string) error {
+		if slices.Contains(order, envName) {
+			return nil
+		}
+		if i := slices.Index(visiting, envName); i >= 0 {
+			cycle := append(slices.Clone(visiting[i:]), envName)
+			return fmt.Errorf("dependency cycle: %s", strings.Join(cycle, " -> "))
+		}
+
+		dependencies, err := dependsOn(envName)
+		if err != nil {
+			return err
+		}
+		visiting = append(visiting, envName)
+		for _, dependency := range dependencies {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		visiting = visiting[:len(visiting)-1]
+		order = append(order, envName)
+		return nil
+	}
+
+	for _, envName := range boxes {
+		if err := visit(envName); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStartOrder(t *testing.T) {
+	tests := []struct {
+		name      string
+		boxes     []string
+		deps      map[string][]string
+		expected  []string
+		wantError string
+	}{
+		{
+			name:     "no dependencies",
+			boxes:    []string{"api", "web"},
+			expected: []string{"api", "web"},
+		},
+		{
+			name:     "dependencies first",
+			boxes:    []string{"web", "api", "db"},
+			deps:     map[string][]string{"web": {"api"}, "api": {"db", "cache"}},
+			expected: []string{"db", "cache", "api", "web"},
+		},
+		{
+			name:      "cycle",
+			boxes:     []string{"a"},
+			deps:      map[string][]string{"a": {"b"}, "b": {"a"}},
+			wantError: "dependency cycle: a -> b -> a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := startOrder(tt.boxes, func(envName string) ([]string, error) {
+				return tt.deps[envName], nil
+			})
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Errorf("startOrder() error = %v, want %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("startOrder() error = %v", err)
+			}
+			if !reflect.DeepEqual(order, tt.expected) {
+				t.Errorf("startOrder() = %v, want %v", order, tt.expected)
+			}
+		})
+	}
+}
+
+func TestResolveGroup(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		".tape.yml": "groups:\n  backend: [api, db]\n",
+		"api.yml":   "workspace: /src/api\ndepends-on: [db]\n",
+		"db.yml":    "workspace: /src/db\n",
+	})
+
+	order, err := ResolveGroup("@backend")
+	if err != nil {
+		t.Fatalf("ResolveGroup() error = %v", err)
+	}
+	if expected := []string{"db", "api"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("ResolveGroup() = %v, want %v", order, expected)
+	}
+
+	if _, err := ResolveGroup("@frontend"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("ResolveGroup() error = %v, want %v", err, ErrGroupNotFound)
+	}
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// GUIX11 has GUI programs in the box display on the host's X server
+const GUIX11 = "x11"
+
+// x11SocketDir holds the sockets of the local X servers, on the host and in the container
+const x11SocketDir = "/tmp/.X11-unix"
+
+// xauthContainerPath is where the box's X authority file is mounted
+const xauthContainerPath = "/tmp/.tape-xauthority"
+
+// xauthPath returns the X authority file with the cookie the box's programs
+// authenticate with, see writeXauth
+func xauthPath(boxConfig BoxConfig) string {
+	return filepath.Join(ConfigDir, "xauth", boxConfig.Name)
+}
+
+// guiOverrides connects the box to the host's X server. On Linux the X
+// socket and an X authority file are mounted, and DISPLAY is the host's,
+// which XWayland sets on Wayland desktops too. Docker Desktop can't mount
+// the socket, so the box connects over TCP to an X server on the host
+// listening on display 0, e.g. XQuartz or VcXsrv. Browsers get a bigger
+// /dev/shm than docker's 64MB, which they crash with.
+func guiOverrides(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, overrides *devcontainer.DevContainerConfig) {
+	env := map[string]string{}
+	if runtime.GOOS == "linux" {
+		env["DISPLAY"] = os.Getenv("DISPLAY")
+		env["XAUTHORITY"] = xauthContainerPath
+		overrides.Mounts = append(overrides.Mounts,
+			devcontainer.NewMountString(fmt.Sprintf("source=%s,target=%s,type=bind", x11SocketDir, x11SocketDir)),
+			devcontainer.NewMountString(fmt.Sprintf("source=%s,target=%s,type=bind,readonly", xauthPath(boxConfig), xauthContainerPath)),
+		)
+	} else {
+		env["DISPLAY"] = "host.docker.internal:0"
+	}
+	for key, value := range overrides.ContainerEnv {
+		env[key] = value
+	}
+	overrides.ContainerEnv = env
+
+	if !slices.ContainsFunc(config.RunArgs, func(arg string) bool { return strings.HasPrefix(arg, "--shm-size") }) {
+		overrides.RunArgs = append(overrides.RunArgs, "--shm-size", "1g")
+	}
+}
+
+// checkGUI fails for GUI boxes that can't reach the host's X server
+func checkGUI(boxConfig BoxConfig) error {
+	if boxConfig.GUI == "" {
+		return nil
+	}
+	if boxConfig.Host != "" {
+		return fmt.Errorf("gui: %s needs %s to run on this machine, not %s", boxConfig.GUI, boxConfig.Name, boxConfig.Host)
+	}
+	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" {
+		return fmt.Errorf("gui: %s needs an X server, DISPLAY is not set", boxConfig.GUI)
+	}
+	return nil
+}
+
+// writeXauth writes the box's X authority file with the cookie of the
+// host's display. The cookie's address is made a wildcard, since the
+// container's hostname differs from the host's. Without xauth the file is
+// empty, which X servers allowing local connections, e.g. after xhost
+// +local:, don't mind.
+func writeXauth(boxConfig BoxConfig) error {
+	if boxConfig.GUI != GUIX11 || runtime.GOOS != "linux" {
+		return nil
+	}
+	p := xauthPath(boxConfig)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	if err := os.WriteFile(p, nil, 0600); err != nil {
+		return err
+	}
+
+	cookies, err := exec.Command("xauth", "nlist", os.Getenv("DISPLAY")).Output()
+	if err != nil {
+		fmt.Fprintf(Stdout, "Warning: not authenticating %s with the X server: %v\n", boxConfig.Name, err)
+		return nil
+	}
+	cmd := exec.Command("xauth", "-f", p, "nmerge", "-")
+	cmd.Stdin = bytes.NewReader(wildcardXauth(cookies))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error writing X authority file: %v: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// wildcardXauth sets the address family of each cookie xauth nlist printed
+// to FamilyWild, ffff, so they match any host
+func wildcardXauth(cookies []byte) []byte {
+	var out bytes.Buffer
+	for _, line := range strings.Split(strings.TrimSpace(string(cookies)), "\n") {
+		if len(line) > 4 {
+			out.WriteString("ffff" + line[4:] + "\n")
+		}
+	}
+	return out.Bytes()
+}
@@ -0,0 +1,38 @@
+package core
+
+import (
+	"runtime"
+	"slices"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestEffectiveConfigGUI(t *testing.T) {
+	t.Setenv("DISPLAY", ":1")
+	boxConfig := BoxConfig{Name: "box", GUI: GUIX11, Env: map[string]string{"DISPLAY": ":99"}}
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu", RunArgs: []string{"--shm-size=2g"}}
+
+	got := EffectiveConfig(boxConfig, config)
+
+	// the box's own variables win
+	if got.ContainerEnv["DISPLAY"] != ":99" {
+		t.Errorf("DISPLAY = %q, want the box's :99", got.ContainerEnv["DISPLAY"])
+	}
+	if slices.Contains(got.RunArgs, "1g") {
+		t.Errorf("RunArgs = %v, want the config's --shm-size only", got.RunArgs)
+	}
+	if runtime.GOOS == "linux" {
+		if got.ContainerEnv["XAUTHORITY"] != xauthContainerPath || len(got.Mounts) != 2 {
+			t.Errorf("EffectiveConfig() = %v, %v, want the X socket and authority mounted", got.ContainerEnv, got.Mounts)
+		}
+	}
+}
+
+func TestWildcardXauth(t *testing.T) {
+	cookies := "0100 0004 6465736b 0001 31 0012 4d49542d4d414749432d434f4f4b49452d31 0010 00112233445566778899aabbccddeeff\n"
+	expected := "ffff 0004 6465736b 0001 31 0012 4d49542d4d414749432d434f4f4b49452d31 0010 00112233445566778899aabbccddeeff\n"
+	if got := string(wildcardXauth([]byte(cookies))); got != expected {
+		t.Errorf("wildcardXauth() = %q, want %q", got, expected)
+	}
+}
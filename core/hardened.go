@@ -0,0 +1,80 @@
+package core
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// hardenedRunArgs run a hardened box's container with a read-only root
+// filesystem, without capabilities and without gaining privileges through
+// setuid binaries. The scratch directories are tmpfs mounts so tools that
+// need them keep working.
+var hardenedRunArgs = []string{
+	"--read-only",
+	"--security-opt", "no-new-privileges",
+	"--cap-drop", "ALL",
+	"--tmpfs", "/tmp:rw,exec,nosuid,mode=1777",
+	"--tmpfs", "/var/tmp:rw,exec,nosuid,mode=1777",
+	"--tmpfs", "/run:rw,nosuid",
+}
+
+// hardenedPolicy is enforced for hardened boxes, so the devcontainer config
+// or its features can't undo the hardening
+var hardenedPolicy = Policy{
+	DeniedRunArgs: []string{
+		"--privileged",
+		"--cap-add",
+		"--read-only=false",
+		"--security-opt=no-new-privileges=false",
+		"--security-opt=no-new-privileges:false",
+		"--security-opt=seccomp=unconfined",
+		"--security-opt=apparmor=unconfined",
+		"--security-opt=label=disable",
+		"--pid=host",
+		"--ipc=host",
+		"--userns=host",
+		"--network=host",
+	},
+}
+
+// hardenedPolicySource names the hardened policy in policy errors
+const hardenedPolicySource = "hardened mode"
+
+// HomeVolumeName returns the named volume holding a hardened box's home
+// directory, which is writable unlike the rest of its root filesystem
+func HomeVolumeName(boxConfig BoxConfig) string {
+	return "tape-home-" + boxConfig.ContainerName()
+}
+
+// hardenedOverrides adds the run flags of a hardened box, and mounts its home
+// directory from a volume. The workspace and caches are mounted writable
+// anyway.
+func hardenedOverrides(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, overrides *devcontainer.DevContainerConfig) {
+	overrides.RunArgs = append(overrides.RunArgs, hardenedRunArgs...)
+	home := fmt.Sprintf("source=%s,target=%s,type=volume", HomeVolumeName(boxConfig), remoteHome(config))
+	overrides.Mounts = append(overrides.Mounts, devcontainer.NewMountString(home))
+}
+
+// remoteHome guesses the home directory of the user commands run as in the
+// container, /home/<user> for users other than root
+func remoteHome(config *devcontainer.DevContainerConfig) string {
+	user := remoteUser(config)
+	if user == "root" {
+		return "/root"
+	}
+	return path.Join("/home", user)
+}
+
+// remoteUser returns the user commands run as in the container, root unless
+// the config sets one
+func remoteUser(config *devcontainer.DevContainerConfig) string {
+	if config.RemoteUser != "" {
+		return config.RemoteUser
+	}
+	if config.ContainerUser != "" {
+		return config.ContainerUser
+	}
+	return "root"
+}
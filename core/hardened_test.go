@@ -0,0 +1,71 @@
+package core
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestEffectiveConfigHardened(t *testing.T) {
+	boxConfig := BoxConfig{Name: "box", Hardened: true}
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu", RemoteUser: "vscode"}
+
+	got := EffectiveConfig(boxConfig, config)
+
+	for _, arg := range hardenedRunArgs {
+		if !slices.Contains(got.RunArgs, arg) {
+			t.Errorf("EffectiveConfig().RunArgs = %v, missing %s", got.RunArgs, arg)
+		}
+	}
+	var mounts []string
+	for _, mount := range got.Mounts {
+		mounts = append(mounts, mount.AsString())
+	}
+	if expected := []string{"source=tape-home-box,target=/home/vscode,type=volume"}; !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("EffectiveConfig().Mounts = %v, want %v", mounts, expected)
+	}
+
+	// tape's own flags don't violate the hardened policy
+	if violations := hardenedPolicy.violations(boxConfig, got, nil); len(violations) > 0 {
+		t.Errorf("hardened config violates the hardened policy: %v", violations)
+	}
+}
+
+func TestHardenedPolicy(t *testing.T) {
+	boxConfig := BoxConfig{Name: "box", Hardened: true}
+	config := EffectiveConfig(boxConfig, &devcontainer.DevContainerConfig{
+		Image:   "ubuntu",
+		RunArgs: []string{"--privileged", "--security-opt", "seccomp=unconfined"},
+	})
+	featureArgs := map[string][]string{
+		"ghcr.io/devcontainers/features/docker-in-docker:2": FeatureMetadata{CapAdd: []string{"SYS_ADMIN"}}.RunArgs(),
+	}
+
+	expected := []string{
+		"runArgs --privileged is not allowed",
+		"runArgs --security-opt=seccomp=unconfined is not allowed",
+		"feature ghcr.io/devcontainers/features/docker-in-docker:2 uses --cap-add, which is not allowed",
+	}
+	if got := hardenedPolicy.violations(boxConfig, config, featureArgs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("violations() = %q, want %q", got, expected)
+	}
+}
+
+func TestRemoteHome(t *testing.T) {
+	tests := []struct {
+		config   devcontainer.DevContainerConfig
+		expected string
+	}{
+		{devcontainer.DevContainerConfig{}, "/root"},
+		{devcontainer.DevContainerConfig{RemoteUser: "root"}, "/root"},
+		{devcontainer.DevContainerConfig{ContainerUser: "node"}, "/home/node"},
+		{devcontainer.DevContainerConfig{RemoteUser: "vscode", ContainerUser: "root"}, "/home/vscode"},
+	}
+	for _, tt := range tests {
+		if got := remoteHome(&tt.config); got != tt.expected {
+			t.Errorf("remoteHome(%+v) = %s, want %s", tt.config, got, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Event outcomes
+const (
+	OutcomeOK    = "ok"
+	OutcomeError = "error"
+)
+
+// Event is an operation on an environment, recorded in the history log
+type Event struct {
+	Time      time.Time `json:"time"`
+	EnvName   string    `json:"env"`
+	Operation string    `json:"operation"`
+	// Detail qualifies the operation, e.g. the command for exec or the tag for snapshot
+	Detail string `json:"detail,omitempty"`
+	User   string `json:"user"`
+	// Command is the tape invocation that ran the operation
+	Command string `json:"command"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HistoryPath returns the append-only log of operations on environments
+func HistoryPath() string {
+	return filepath.Join(ConfigDir, "history.jsonl")
+}
+
+// recordEvent appends an operation and its outcome to the history log. The
+// log is best effort, failing to write it doesn't fail the operation.
+func recordEvent(envName string, operation string, detail string, err error) {
+	event := Event{
+		Time:      time.Now(),
+		EnvName:   envName,
+		Operation: operation,
+		Detail:    detail,
+		User:      currentUser(),
+		Command:   strings.Join(os.Args, " "),
+		Outcome:   OutcomeOK,
+	}
+	if err != nil {
+		event.Outcome = OutcomeError
+		event.Error = err.Error()
+	}
+
+	if err := appendEvent(event); err != nil {
+		fmt.Fprintf(Stderr, "Warning: error recording history: %v\n", err)
+	}
+}
+
+func appendEvent(event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(HistoryPath()), 0755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	// a single write keeps lines from concurrent tape processes whole
+	_, err = file.Write(append(line, '\n'))
+	return err
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// History returns the recorded operations on an environment, or on all
+// environments if envName is empty, oldest first
+func History(envName string) ([]Event, error) {
+	file, err := os.Open(HistoryPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var event Event
+		// skip lines that were cut short, e.g. by a full disk
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		if envName == "" || event.EnvName == envName {
+			events = append(events, event)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("error reading history: %v", err)
+	}
+	return events, nil
+}
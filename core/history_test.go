@@ -0,0 +1,54 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestHistory(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	recordEvent("app", "up", "rebuild", nil)
+	recordEvent("api", "stop", "", nil)
+	recordEvent("app", "exec", "go test ./...", errors.New("exit status 1"))
+
+	// a truncated line is skipped
+	file, err := os.OpenFile(HistoryPath(), os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("OpenFile() error = %v", err)
+	}
+	file.WriteString(`{"time": "2025-`)
+	file.Close()
+
+	all, err := History("")
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("History() returned %d events, want 3", len(all))
+	}
+
+	events, err := History("app")
+	if err != nil {
+		t.Fatalf("History() error = %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("History(app) returned %d events, want 2", len(events))
+	}
+	if events[0].Operation != "up" || events[0].Detail != "rebuild" || events[0].Outcome != OutcomeOK {
+		t.Errorf("History(app)[0] = %+v, want a successful rebuild", events[0])
+	}
+	if events[1].Outcome != OutcomeError || events[1].Error != "exit status 1" {
+		t.Errorf("History(app)[1] = %+v, want a failed exec", events[1])
+	}
+}
+
+func TestHistoryMissing(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	events, err := History("")
+	if err != nil || events != nil {
+		t.Errorf("History() = %v, %v, want no events", events, err)
+	}
+}
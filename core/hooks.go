@@ -0,0 +1,105 @@
+package core
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// The points in a box's operations where its hooks run
+const (
+	HookPreUp    = "pre-up"
+	HookPostUp   = "post-up"
+	HookPreStop  = "pre-stop"
+	HookPostStop = "post-stop"
+)
+
+// BoxHooks are shell commands run on the host before and after a box is
+// started or stopped, e.g. to adjust a VPN or hosts file. They run in the
+// workspace with variables describing the box, see hookEnv. A failing pre
+// hook cancels the operation.
+type BoxHooks struct {
+	PreUp    []string `yaml:"pre-up,omitempty" validate:"dive,required"`
+	PostUp   []string `yaml:"post-up,omitempty" validate:"dive,required"`
+	PreStop  []string `yaml:"pre-stop,omitempty" validate:"dive,required"`
+	PostStop []string `yaml:"post-stop,omitempty" validate:"dive,required"`
+}
+
+func (h BoxHooks) commands(hook string) []string {
+	switch hook {
+	case HookPreUp:
+		return h.PreUp
+	case HookPostUp:
+		return h.PostUp
+	case HookPreStop:
+		return h.PreStop
+	case HookPostStop:
+		return h.PostStop
+	}
+	return nil
+}
+
+// runHook runs the box's commands for a hook, one after the other, stopping
+// at the first that fails
+func runHook(boxConfig BoxConfig, hook string) error {
+	commands := boxConfig.Hooks.commands(hook)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	summary, err := GetBoxSummary(boxConfig.Name)
+	if err != nil {
+		return err
+	}
+	ports, err := GetBoxPorts(boxConfig.Name)
+	if err != nil {
+		return err
+	}
+
+	// remote boxes can have a workspace that only exists on their host
+	dir := ""
+	if _, err := os.Stat(boxConfig.Workspace); err == nil {
+		dir = boxConfig.Workspace
+	}
+	return runHookCommands(hook, commands, hookEnv(boxConfig, hook, summary, ports), dir)
+}
+
+func runHookCommands(hook string, commands []string, env []string, dir string) error {
+	for _, command := range commands {
+		cmd := exec.Command("/bin/sh", "-c", command)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = Stdout
+		cmd.Stderr = Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("%s hook %q failed: %w", hook, command, err)
+		}
+	}
+	return nil
+}
+
+// hookEnv returns the variables hooks run with: TAPE_ENV, TAPE_HOOK,
+// TAPE_WORKSPACE, TAPE_CONTAINER_ID and TAPE_CONTAINER_STATE when the box has
+// a container, TAPE_PORT_<port> for each published port like tape direnv, and
+// DOCKER_HOST for boxes on another docker host
+func hookEnv(boxConfig BoxConfig, hook string, summary *BoxSummary, ports []BoxPort) []string {
+	env := []string{
+		"TAPE_ENV=" + boxConfig.Name,
+		"TAPE_HOOK=" + hook,
+		"TAPE_WORKSPACE=" + boxConfig.Workspace,
+	}
+	if summary.ContainerID != "" {
+		env = append(env,
+			"TAPE_CONTAINER_ID="+summary.ContainerID,
+			"TAPE_CONTAINER_STATE="+string(summary.State),
+		)
+	}
+	for _, port := range ports {
+		env = append(env, port.Variable()+"="+strconv.Itoa(port.HostPort))
+	}
+	if boxConfig.DockerHost != "" {
+		env = append(env, "DOCKER_HOST="+boxConfig.DockerHost)
+	}
+	return env
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHookEnv(t *testing.T) {
+	boxConfig := BoxConfig{Name: "app", Workspace: "/src/app", DockerHost: "ssh://dev"}
+	summary := &BoxSummary{EnvName: "app", State: BoxStateRunning, ContainerID: "abc123"}
+	ports := []BoxPort{{ContainerPort: 8080, Protocol: "tcp", HostPort: 49153}, {ContainerPort: 53, Protocol: "udp", HostPort: 5353}}
+
+	expected := []string{
+		"TAPE_ENV=app",
+		"TAPE_HOOK=post-up",
+		"TAPE_WORKSPACE=/src/app",
+		"TAPE_CONTAINER_ID=abc123",
+		"TAPE_CONTAINER_STATE=" + string(BoxStateRunning),
+		"TAPE_PORT_8080=49153",
+		"TAPE_PORT_53_UDP=5353",
+		"DOCKER_HOST=ssh://dev",
+	}
+	if env := hookEnv(boxConfig, HookPostUp, summary, ports); !reflect.DeepEqual(env, expected) {
+		t.Errorf("hookEnv() = %v, want %v", env, expected)
+	}
+
+	// before the first up there is no container
+	env := hookEnv(BoxConfig{Name: "app", Workspace: "/src/app"}, HookPreUp, &BoxSummary{State: BoxStateDoesNotExist}, nil)
+	if expected := []string{"TAPE_ENV=app", "TAPE_HOOK=pre-up", "TAPE_WORKSPACE=/src/app"}; !reflect.DeepEqual(env, expected) {
+		t.Errorf("hookEnv() = %v, want %v", env, expected)
+	}
+}
+
+func TestRunHookCommands(t *testing.T) {
+	dir := t.TempDir()
+	commands := []string{
+		`echo "$TAPE_ENV" > first`,
+		"false",
+		"touch never",
+	}
+	err := runHookCommands(HookPreUp, commands, []string{"TAPE_ENV=app"}, dir)
+	if err == nil || !strings.Contains(err.Error(), `pre-up hook "false" failed`) {
+		t.Errorf("runHookCommands() error = %v, want the failing command", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "first"))
+	if err != nil || string(data) != "app\n" {
+		t.Errorf("first hook wrote %q, %v, want the environment name", data, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "never")); !os.IsNotExist(err) {
+		t.Errorf("hooks after the failing one ran")
+	}
+}
+
+func TestLoadBoxConfigHooks(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"app.yml": "workspace: /src/app\nhooks:\n  pre-up: [vpn up]\n  post-stop:\n    - vpn down\n    - notify stopped\n",
+	})
+
+	config, err := LoadBoxConfig("app")
+	if err != nil {
+		t.Fatalf("LoadBoxConfig() error = %v", err)
+	}
+	expected := BoxHooks{PreUp: []string{"vpn up"}, PostStop: []string{"vpn down", "notify stopped"}}
+	if !reflect.DeepEqual(config.Hooks, expected) {
+		t.Errorf("LoadBoxConfig().Hooks = %+v, want %+v", config.Hooks, expected)
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+)
+
+type BoxHost struct {
+	EnvName   string
+	IPAddress string
+	Network   string
+	// Error is set when the box's address couldn't be read, see ListBoxHosts
+	Error string
+}
+
+// GetBoxHost returns the address a running box can be reached at. Boxes that
+// join a tape network are resolved on that network, otherwise the first
+// of the container's networks, by name, is used.
+func GetBoxHost(envName string) (*BoxHost, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := GetBoxSummary(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.State != BoxStateRunning {
+		return nil, fmt.Errorf("%s is not running (current state: %s)", envName, summary.State)
+	}
+	return boxHost(*boxConfig, summary)
+}
+
+// boxHost is GetBoxHost for the summary of a running box
+func boxHost(boxConfig BoxConfig, summary *BoxSummary) (*BoxHost, error) {
+	envName := summary.EnvName
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	inspect, err := cli.InspectContainer(context.Background(), summary.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %v", err)
+	}
+
+	if boxConfig.Network != "" {
+		if address, ok := inspect.Networks[boxConfig.Network]; ok {
+			return &BoxHost{EnvName: envName, IPAddress: address, Network: boxConfig.Network}, nil
+		}
+	}
+
+	if names := slices.Sorted(maps.Keys(inspect.Networks)); len(names) > 0 {
+		return &BoxHost{EnvName: envName, IPAddress: inspect.Networks[names[0]], Network: names[0]}, nil
+	}
+
+	return nil, fmt.Errorf("%s has no IP address", envName)
+}
+
+// ListBoxHosts returns the addresses of all running boxes. A box whose
+// address or state can't be read is returned with Error set rather than
+// failing the whole listing.
+func ListBoxHosts() ([]BoxHost, error) {
+	envs, err := ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []BoxHost
+	for _, envName := range envs {
+		host, err := listedBoxHost(envName)
+		if err != nil {
+			hosts = append(hosts, BoxHost{EnvName: envName, Error: err.Error()})
+			continue
+		}
+		if host != nil {
+			hosts = append(hosts, *host)
+		}
+	}
+
+	return hosts, nil
+}
+
+// listedBoxHost returns the address of a box for ListBoxHosts, nil when it
+// isn't running
+func listedBoxHost(envName string) (*BoxHost, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := GetBoxSummary(envName)
+	if err != nil {
+		return nil, err
+	}
+	if summary.State != BoxStateRunning {
+		return nil, nil
+	}
+	return boxHost(*boxConfig, summary)
+}
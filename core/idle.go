@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// IdleStopOperation is the history operation of boxes stopped by StopIdleBox
+const IdleStopOperation = "idle-stop"
+
+// LastActivity returns when the box was last in use: now while commands run
+// in its container with exec, e.g. shells, editors and tape exec, otherwise
+// the latest of when its container started and the last tape operation on
+// it. running is false when the box has no running container.
+func LastActivity(envName string) (last time.Time, running bool, err error) {
+	var details *container.ContainerDetails
+	err = withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if dc.State != container.StateRunning {
+			return nil
+		}
+		var err error
+		details, err = cli.InspectContainer(ctx, dc.ID)
+		return err
+	})
+	if container.IsContainerNotFound(err) {
+		return time.Time{}, false, nil
+	}
+	if err != nil || details == nil {
+		return time.Time{}, false, err
+	}
+
+	events, err := History(envName)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return lastActivity(details, events, time.Now()), true, nil
+}
+
+// lastActivity is LastActivity for a running container's details and the
+// box's history
+func lastActivity(details *container.ContainerDetails, events []Event, now time.Time) time.Time {
+	if details.Execs > 0 {
+		return now
+	}
+	last := details.StartedAt
+	for _, event := range events {
+		if event.Time.After(last) {
+			last = event.Time
+		}
+	}
+	return last
+}
+
+// StopIdleBox stops the box when it has been idle for longer than its idle
+// timeout, reporting whether it did. Boxes without an idle timeout are left
+// running.
+func StopIdleBox(envName string) (bool, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return false, err
+	}
+	timeout := boxConfig.IdleTimeoutDuration()
+	if timeout == 0 {
+		return false, nil
+	}
+
+	last, running, err := LastActivity(envName)
+	if err != nil || !running || time.Since(last) < timeout {
+		return false, err
+	}
+	return true, stopBox(envName, IdleStopOperation, "idle for "+boxConfig.IdleTimeout)
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestLastActivity(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	started := now.Add(-2 * time.Hour)
+	events := []Event{
+		{Time: now.Add(-3 * time.Hour), Operation: "up"},
+		{Time: now.Add(-90 * time.Minute), Operation: "exec"},
+	}
+
+	tests := []struct {
+		name     string
+		details  container.ContainerDetails
+		events   []Event
+		expected time.Time
+	}{
+		{
+			name:     "no operations since the start",
+			details:  container.ContainerDetails{StartedAt: started},
+			events:   events[:1],
+			expected: started,
+		},
+		{
+			name:     "operation since the start",
+			details:  container.ContainerDetails{StartedAt: started},
+			events:   events,
+			expected: now.Add(-90 * time.Minute),
+		},
+		{
+			name:     "running exec",
+			details:  container.ContainerDetails{StartedAt: started, Execs: 1},
+			events:   events,
+			expected: now,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastActivity(&tt.details, tt.events, now); !got.Equal(tt.expected) {
+				t.Errorf("lastActivity() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
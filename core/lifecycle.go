@@ -0,0 +1,254 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// runInitializeCommand runs the config's initializeCommand on the host with
+// the workspace as the working directory, as the spec requires before the
+// container is created. Output streams to the terminal and a failing command
+// returns an error.
+func runInitializeCommand(config *devcontainer.DevContainerConfig, workspace string) error {
+	if config.InitializeCommand == nil {
+		return nil
+	}
+
+	commands, err := hostCommands(config.InitializeCommand)
+	if err != nil {
+		return fmt.Errorf("invalid initializeCommand: %v", err)
+	}
+
+	// the object form runs its commands in parallel
+	errs := make([]error, len(commands))
+	var wg sync.WaitGroup
+	for i, args := range commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Dir = workspace
+			cmd.Stdout = Stdout
+			cmd.Stderr = Stderr
+			if err := cmd.Run(); err != nil {
+				errs[i] = fmt.Errorf("initializeCommand failed: %w", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// hostCommands returns the argument lists for a lifecycle command. Strings
+// run in a shell, arrays run directly and objects hold several of either.
+func hostCommands(command *devcontainer.CommandValue) ([][]string, error) {
+	parsed, err := command.Normalize()
+	if err != nil {
+		return nil, err
+	}
+	commands := make([][]string, len(parsed))
+	for i, c := range parsed {
+		commands[i] = c.Argv()
+	}
+	return commands, nil
+}
+
+// LifecycleRunCommand is the hidden tape command that runs the lifecycle
+// commands up leaves for the background
+const LifecycleRunCommand = "lifecycle-run"
+
+// lifecyclePhases are the container lifecycle commands in the order they run
+var lifecyclePhases = []string{
+	"onCreateCommand",
+	"updateContentCommand",
+	"postCreateCommand",
+	"postStartCommand",
+	"postAttachCommand",
+}
+
+func lifecycleCommand(config *devcontainer.DevContainerConfig, phase string) *devcontainer.CommandValue {
+	switch phase {
+	case "onCreateCommand":
+		return config.OnCreateCommand
+	case "updateContentCommand":
+		return config.UpdateContentCommand
+	case "postCreateCommand":
+		return config.PostCreateCommand
+	case "postStartCommand":
+		return config.PostStartCommand
+	case "postAttachCommand":
+		return config.PostAttachCommand
+	}
+	return nil
+}
+
+// waitForIndex returns the index in lifecyclePhases of the config's waitFor
+// phase, which defaults to updateContentCommand as in the spec
+func waitForIndex(config *devcontainer.DevContainerConfig) int {
+	if waitFor := slices.Index(lifecyclePhases, config.WaitFor); waitFor >= 0 {
+		return waitFor
+	}
+	return slices.Index(lifecyclePhases, "updateContentCommand")
+}
+
+// phasesAfterWaitFor returns the phases with commands that run after the
+// waitFor phase, see waitForIndex
+func phasesAfterWaitFor(config *devcontainer.DevContainerConfig) []string {
+	var phases []string
+	for _, phase := range lifecyclePhases[waitForIndex(config)+1:] {
+		if lifecycleCommand(config, phase) != nil {
+			phases = append(phases, phase)
+		}
+	}
+	return phases
+}
+
+// LifecycleLogPath returns the log of the box's background lifecycle commands
+func LifecycleLogPath(envName string) string {
+	return filepath.Join(ConfigDir, "logs", envName+"-lifecycle.log")
+}
+
+func lifecyclePidPath(envName string) string {
+	return filepath.Join(ConfigDir, "logs", envName+"-lifecycle.pid")
+}
+
+// startBackgroundLifecycle runs the box's remaining lifecycle commands in a
+// detached tape process that logs to LifecycleLogPath
+func startBackgroundLifecycle(envName string) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("error finding the tape executable: %v", err)
+	}
+
+	logPath := LifecycleLogPath(envName)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return fmt.Errorf("error creating log directory: %v", err)
+	}
+	logFile, err := os.Create(logPath)
+	if err != nil {
+		return fmt.Errorf("error creating lifecycle log: %v", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, LifecycleRunCommand, envName)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("error starting lifecycle commands: %v", err)
+	}
+	return cmd.Process.Release()
+}
+
+// RunBackgroundLifecycle runs the lifecycle commands the devcontainer CLI
+// hasn't run yet in the box's container. It is run by the process
+// startBackgroundLifecycle starts. Its outcome is recorded in the history,
+// where tape events reports it.
+func RunBackgroundLifecycle(envName string) (err error) {
+	pidPath := lifecyclePidPath(envName)
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("error writing lifecycle pid file: %v", err)
+	}
+	defer os.Remove(pidPath)
+	defer func() {
+		recordEvent(envName, "lifecycle", "run-user-commands", err)
+	}()
+
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	config, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	dc, err := FindDevContainer(*config)
+	if err != nil {
+		return err
+	}
+
+	secrets, err := resolveSecrets(*config)
+	if err != nil {
+		return err
+	}
+	additionalArgs := []string{"--container-id", dc.ID}
+	if globalConfig.DotfilesRepository != "" {
+		additionalArgs = append(additionalArgs,
+			"--dotfiles-repository", globalConfig.DotfilesRepository,
+		)
+	}
+
+	devCmd := DevcontainerCommand{
+		BoxConfig:      *config,
+		Command:        "run-user-commands",
+		AdditionalArgs: additionalArgs,
+		Image:          config.PrebuiltImage,
+		Secrets:        secrets,
+	}
+	if err := devCmd.Execute(); err != nil {
+		fmt.Fprintf(Stdout, "Lifecycle commands failed: %v\n", err)
+		return err
+	}
+	fmt.Fprintln(Stdout, "Lifecycle commands finished")
+	return nil
+}
+
+// LifecycleRunning reports whether the box's background lifecycle commands
+// are still running
+func LifecycleRunning(envName string) bool {
+	data, err := os.ReadFile(lifecyclePidPath(envName))
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return false
+	}
+	return processRunning(pid)
+}
+
+// LifecycleLog writes the log of the box's last background lifecycle run to
+// w. With follow, new output is written until the run finishes or ctx is cancelled.
+func LifecycleLog(ctx context.Context, envName string, follow bool, w io.Writer) error {
+	file, err := os.Open(LifecycleLogPath(envName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("no lifecycle commands have run in the background for %s", envName)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading lifecycle log: %v", err)
+	}
+	defer file.Close()
+
+	for {
+		// check before copying so output written just before the run finished isn't missed
+		running := follow && LifecycleRunning(envName)
+		if _, err := io.Copy(w, file); err != nil {
+			return fmt.Errorf("error reading lifecycle log: %v", err)
+		}
+		if !running {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestHostCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected [][]string
+		wantErr  bool
+	}{
+		{
+			name:     "string",
+			json:     `{"initializeCommand": "make init"}`,
+			expected: [][]string{{"/bin/sh", "-c", "make init"}},
+		},
+		{
+			name:     "array",
+			json:     `{"initializeCommand": ["git", "fetch"]}`,
+			expected: [][]string{{"git", "fetch"}},
+		},
+		{
+			name: "object",
+			json: `{"initializeCommand": {"fetch": ["git", "fetch"], "build": "make"}}`,
+			expected: [][]string{
+				{"/bin/sh", "-c", "make"},
+				{"git", "fetch"},
+			},
+		},
+		{
+			name:    "empty array",
+			json:    `{"initializeCommand": {"fetch": []}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := devcontainer.ParseDevContainer([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("ParseDevContainer() error = %v", err)
+			}
+			got, err := hostCommands(config.InitializeCommand)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("hostCommands() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("hostCommands() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunInitializeCommand(t *testing.T) {
+	workspace := t.TempDir()
+	config, err := devcontainer.ParseDevContainer([]byte(`{"initializeCommand": {
+		"a": "touch a",
+		"b": ["touch", "b"]
+	}}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+
+	if err := runInitializeCommand(config, workspace); err != nil {
+		t.Fatalf("runInitializeCommand() error = %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if _, err := os.Stat(filepath.Join(workspace, name)); err != nil {
+			t.Errorf("expected %s to be created in the workspace: %v", name, err)
+		}
+	}
+
+	config, err = devcontainer.ParseDevContainer([]byte(`{"initializeCommand": "exit 3"}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+	if err := runInitializeCommand(config, workspace); err == nil {
+		t.Error("runInitializeCommand() expected an error for a failing command")
+	}
+}
+
+func TestPhasesAfterWaitFor(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected []string
+	}{
+		{
+			name:     "default waits for updateContentCommand",
+			json:     `{"onCreateCommand": "a", "updateContentCommand": "b", "postCreateCommand": "c", "postStartCommand": "d"}`,
+			expected: []string{"postCreateCommand", "postStartCommand"},
+		},
+		{
+			name:     "wait for onCreateCommand",
+			json:     `{"waitFor": "onCreateCommand", "updateContentCommand": "b", "postAttachCommand": "e"}`,
+			expected: []string{"updateContentCommand", "postAttachCommand"},
+		},
+		{
+			name:     "wait for postStartCommand",
+			json:     `{"waitFor": "postStartCommand", "postCreateCommand": "c", "postStartCommand": "d"}`,
+			expected: nil,
+		},
+		{
+			name:     "nothing after waitFor",
+			json:     `{"onCreateCommand": "a"}`,
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := devcontainer.ParseDevContainer([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("ParseDevContainer() error = %v", err)
+			}
+			if got := phasesAfterWaitFor(config); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("phasesAfterWaitFor() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
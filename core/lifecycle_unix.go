@@ -0,0 +1,22 @@
+//go:build !windows
+
+package core
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a new session, so it keeps running when the terminal closes
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return process.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,22 @@
+package core
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// detach starts cmd in a new process group, so it doesn't get the console's Ctrl-C
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
+
+// processRunning relies on FindProcess opening a handle to the process on Windows
+func processRunning(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultSSHPort is the port tape's SSH server listens on unless it's taken
+const DefaultSSHPort = 2222
+
+// listenAttempts is how many ports ListenNextFree tries
+const listenAttempts = 100
+
+// ListenNextFree listens on the TCP address, or on the next free port after
+// its port when it's taken, so a port in use doesn't fail the listener.
+// Port 0 listens on any free port.
+func ListenNextFree(address string) (net.Listener, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portString)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %s", address)
+	}
+	if port == 0 {
+		return net.Listen("tcp", address)
+	}
+
+	var firstErr error
+	for candidate := port; candidate < port+listenAttempts && candidate <= 65535; candidate++ {
+		listener, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(candidate)))
+		if err == nil {
+			return listener, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
+// ListenerPort returns the port a TCP listener listens on
+func ListenerPort(listener net.Listener) int {
+	if addr, ok := listener.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return 0
+}
+
+// sshPortPath is where the SSH server records the port it listens on, next
+// to the daemon's socket
+func sshPortPath() string {
+	return filepath.Join(filepath.Dir(DaemonSocketPath()), "ssh-port")
+}
+
+// RecordSSHPort records the port the SSH server listens on, for SSHPort
+func RecordSSHPort(port int) error {
+	path := sshPortPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(strconv.Itoa(port)+"\n"), 0600)
+}
+
+// ClearSSHPort removes the recorded port when the SSH server stops
+func ClearSSHPort() {
+	os.Remove(sshPortPath())
+}
+
+// SSHPort returns the port the running SSH server listens on, or
+// DefaultSSHPort when none recorded one
+func SSHPort() int {
+	data, err := os.ReadFile(sshPortPath())
+	if err != nil {
+		return DefaultSSHPort
+	}
+	port, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || port <= 0 {
+		return DefaultSSHPort
+	}
+	return port
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"net"
+	"testing"
+)
+
+func TestListenNextFree(t *testing.T) {
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer taken.Close()
+	port := ListenerPort(taken)
+
+	listener, err := ListenNextFree(taken.Addr().String())
+	if err != nil {
+		t.Fatalf("ListenNextFree() error = %v", err)
+	}
+	defer listener.Close()
+	if got := ListenerPort(listener); got <= port {
+		t.Errorf("ListenNextFree() listens on %d, want a port after the taken %d", got, port)
+	}
+}
+
+func TestSSHPort(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	if got := SSHPort(); got != DefaultSSHPort {
+		t.Errorf("SSHPort() without a server = %d, want %d", got, DefaultSSHPort)
+	}
+	if err := RecordSSHPort(2223); err != nil {
+		t.Fatalf("RecordSSHPort() error = %v", err)
+	}
+	if got := SSHPort(); got != 2223 {
+		t.Errorf("SSHPort() = %d, want 2223", got)
+	}
+	ClearSSHPort()
+	if got := SSHPort(); got != DefaultSSHPort {
+		t.Errorf("SSHPort() after ClearSSHPort = %d, want %d", got, DefaultSSHPort)
+	}
+}
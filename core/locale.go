@@ -0,0 +1,68 @@
+package core
+
+import (
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// hostLocaleVariables are the locale variables passed from the host to
+// containers. The image needs the locale generated for programs to use it.
+var hostLocaleVariables = []string{"LANG", "LANGUAGE", "LC_ALL"}
+
+// hostLocaleEnv returns the host's timezone as TZ and its locale variables
+func hostLocaleEnv() map[string]string {
+	env := map[string]string{}
+	if tz := hostTimezone(); tz != "" {
+		env["TZ"] = tz
+	}
+	for _, key := range hostLocaleVariables {
+		if value := os.Getenv(key); value != "" {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// hostTimezone returns the host's timezone name, e.g. Europe/Berlin, from TZ
+// or the zoneinfo file /etc/localtime links to, "" when it's unknown
+func hostTimezone() string {
+	if tz := strings.TrimPrefix(os.Getenv("TZ"), ":"); tz != "" && !strings.HasPrefix(tz, "/") {
+		return tz
+	}
+	target, err := os.Readlink("/etc/localtime")
+	if err != nil {
+		return ""
+	}
+	_, name, _ := strings.Cut(target, "zoneinfo/")
+	return name
+}
+
+// applyHostLocale gives the container the host's timezone and locale, so its
+// logs and scheduled jobs aren't in UTC. Variables the config or the box
+// sets are kept. On a Linux host running the box itself, /etc/localtime is
+// mounted too, for images without tzdata.
+func applyHostLocale(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, env map[string]string) {
+	merged := maps.Clone(env)
+	maps.Copy(merged, config.ContainerEnv)
+	config.ContainerEnv = merged
+
+	local := boxConfig.Host == "" && boxConfig.DockerHost == ""
+	if !local || runtime.GOOS != "linux" || env["TZ"] == "" {
+		return
+	}
+	if _, err := os.Stat("/etc/localtime"); err != nil {
+		return
+	}
+	mounted := slices.ContainsFunc(config.Mounts, func(m devcontainer.MountValue) bool {
+		mount, err := m.Normalize()
+		return err == nil && mount.Target == "/etc/localtime"
+	})
+	if !mounted {
+		config.Mounts = append(config.Mounts, devcontainer.NewMountString("source=/etc/localtime,target=/etc/localtime,type=bind,readonly"))
+	}
+}
@@ -0,0 +1,34 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestApplyHostLocale(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{ContainerEnv: map[string]string{"LANG": "C.UTF-8"}}
+	host := map[string]string{"TZ": "Europe/Berlin", "LANG": "de_DE.UTF-8"}
+
+	// remote boxes get the variables, but not the host's /etc/localtime
+	applyHostLocale(BoxConfig{Host: "build-server"}, config, host)
+
+	expected := map[string]string{"TZ": "Europe/Berlin", "LANG": "C.UTF-8"}
+	if !reflect.DeepEqual(config.ContainerEnv, expected) {
+		t.Errorf("ContainerEnv = %v, want %v", config.ContainerEnv, expected)
+	}
+	if len(config.Mounts) > 0 {
+		t.Errorf("Mounts = %v, want none for a remote box", config.Mounts)
+	}
+	if host["LANG"] != "de_DE.UTF-8" {
+		t.Errorf("applyHostLocale() changed the host env to %v", host)
+	}
+}
+
+func TestHostTimezone(t *testing.T) {
+	t.Setenv("TZ", ":America/New_York")
+	if tz := hostTimezone(); tz != "America/New_York" {
+		t.Errorf("hostTimezone() = %q, want America/New_York", tz)
+	}
+}
@@ -0,0 +1,277 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// LockFile is the name of the lock file tape keeps next to a devcontainer config
+const LockFile = "tape.lock"
+
+// BoxLock pins the base images and features of a box to the digests they
+// resolved to when it was first built, so every machine builds the same
+// environment. It is written next to the devcontainer config to be
+// committed with it, and refreshed by tape upgrade --update-lock.
+type BoxLock struct {
+	// Images maps image references to their repo digests
+	Images map[string]string `json:"images,omitempty"`
+	// Features maps feature references, as written in the config, to what they resolved to
+	Features map[string]LockedFeature `json:"features,omitempty"`
+}
+
+// LockedFeature is the version a feature's tag resolved to
+type LockedFeature struct {
+	Version string `json:"version,omitempty"`
+	Digest  string `json:"digest"`
+}
+
+// LockPath returns the path of the box's lock file: next to its devcontainer
+// config, or its box config for boxes without one
+func LockPath(boxConfig BoxConfig) string {
+	if boxConfig.Config != "" {
+		return filepath.Join(filepath.Dir(boxConfig.Config), LockFile)
+	}
+	return strings.TrimSuffix(BoxConfigPath(boxConfig.Name), filepath.Ext(BoxConfigPath(boxConfig.Name))) + ".lock"
+}
+
+// LoadLock reads the box's lock file, returning nil when there is none
+func LoadLock(boxConfig BoxConfig) (*BoxLock, error) {
+	path := LockPath(boxConfig)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading lock file %s: %v", path, err)
+	}
+
+	var lock BoxLock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("error parsing lock file %s: %v", path, err)
+	}
+	return &lock, nil
+}
+
+// WriteLock writes the box's lock file
+func WriteLock(boxConfig BoxConfig, lock *BoxLock) error {
+	data, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error serializing lock file: %v", err)
+	}
+	path := LockPath(boxConfig)
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("error writing lock file %s: %v", path, err)
+	}
+	return nil
+}
+
+// applyLock pins the config's features to their locked digests. Images are
+// pinned by pinLockedImages once the build paths are resolved.
+func applyLock(config *devcontainer.DevContainerConfig, lock *BoxLock) error {
+	if lock == nil || len(lock.Features) == 0 {
+		return nil
+	}
+	features, err := config.FeatureList()
+	if err != nil {
+		return err
+	}
+	for _, feature := range features {
+		locked, ok := lock.Features[feature.Ref.String()]
+		if !ok || feature.Ref.Kind != devcontainer.FeatureRefOCI {
+			continue
+		}
+		delete(config.Features, feature.Ref.Raw)
+		config.Features[feature.Ref.Repository()+"@"+locked.Digest] = map[string]interface{}(feature.Options)
+	}
+	return nil
+}
+
+// pinLockedImages rewrites the config's image, or the FROM instructions of
+// its Dockerfile, to the locked digests, leaving the local tags alone. The
+// rewritten Dockerfile is written to a temporary directory that's returned
+// for the caller to remove, "" when nothing was written. config's Dockerfile
+// path has to be resolved already, see resolveBuildPaths.
+func pinLockedImages(config *devcontainer.DevContainerConfig, lock *BoxLock) (string, error) {
+	if lock == nil || len(lock.Images) == 0 {
+		return "", nil
+	}
+	if config.Image != "" {
+		if digest, ok := lock.Images[config.Image]; ok {
+			config.Image = pinnedImage(config.Image, digest)
+		}
+		return "", nil
+	}
+
+	dockerfile := &config.DockerFile
+	if config.Build != nil && config.Build.Dockerfile != "" {
+		dockerfile = &config.Build.Dockerfile
+	}
+	if *dockerfile == "" {
+		return "", nil
+	}
+	data, err := os.ReadFile(*dockerfile)
+	if err != nil {
+		// the CLI reports the missing Dockerfile
+		return "", nil
+	}
+	pinned, changed := pinDockerfileImages(data, lock.Images)
+	if !changed {
+		return "", nil
+	}
+
+	dir, err := os.MkdirTemp("", "tape-dockerfile-")
+	if err != nil {
+		return "", fmt.Errorf("error creating pinned Dockerfile: %v", err)
+	}
+	path := filepath.Join(dir, filepath.Base(*dockerfile))
+	if err := os.WriteFile(path, pinned, 0644); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("error writing pinned Dockerfile: %v", err)
+	}
+	*dockerfile = path
+	return dir, nil
+}
+
+// pinDockerfileImages rewrites the images of a Dockerfile's FROM instructions
+// that have a digest in digests to name@digest, the way dockerfileImages
+// finds them. It reports whether any was rewritten.
+func pinDockerfileImages(data []byte, digests map[string]string) ([]byte, bool) {
+	lines := strings.SplitAfter(string(data), "\n")
+	var stages []string
+	changed := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		// skip flags like --platform
+		image := slices.IndexFunc(fields[1:], func(f string) bool { return !strings.HasPrefix(f, "--") })
+		if image < 0 {
+			continue
+		}
+		image++
+		digest, ok := digests[fields[image]]
+		if ok && !slices.Contains(stages, strings.ToLower(fields[image])) {
+			fields[image] = pinnedImage(fields[image], digest)
+			lines[i] = strings.Join(fields, " ")
+			if strings.HasSuffix(line, "\n") {
+				lines[i] += "\n"
+			}
+			changed = true
+		}
+		if args := fields[image:]; len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages = append(stages, strings.ToLower(args[2]))
+		}
+	}
+	return []byte(strings.Join(lines, "")), changed
+}
+
+// pinnedImage returns the reference of image at digest
+func pinnedImage(image, digest string) string {
+	return container.ParseImageRef(image).Name() + "@" + digest
+}
+
+// resolveLock returns the box's lock with the images and features that
+// aren't locked yet added: images at the digest of the local image, or the
+// one their tag points to when they have to be pulled, and features at the
+// version their tag resolves to. It's called before the box is built, which
+// then uses exactly these digests; changed reports whether the lock file
+// needs writing once the build succeeded. Offline, only local images are
+// locked.
+func resolveLock(boxConfig BoxConfig) (lock *BoxLock, changed bool, err error) {
+	lock, err = LoadLock(boxConfig)
+	if err != nil {
+		return nil, false, err
+	}
+	if lock == nil {
+		lock = &BoxLock{}
+	}
+	if lock.Images == nil {
+		lock.Images = map[string]string{}
+	}
+	if lock.Features == nil {
+		lock.Features = map[string]LockedFeature{}
+	}
+
+	config, err := upgradeConfig(boxConfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	registry, err := newBoxRegistryClient(boxConfig)
+	if err != nil {
+		return nil, false, err
+	}
+
+	ctx := context.Background()
+	for _, image := range upgradeImages(boxConfig, config) {
+		ref := container.ParseImageRef(image)
+		if _, ok := lock.Images[image]; ok || ref.Digest != "" {
+			continue
+		}
+		exists, err := cli.HasImage(ctx, image)
+		if err != nil {
+			return nil, false, err
+		}
+		digest := ""
+		switch {
+		case exists:
+			// images built locally have no digest to lock
+			digest, err = cli.ImageDigest(ctx, image)
+		case !Offline:
+			digest, err = registry.manifestDigest(ref.Registry, ref.Repository, ref.Tag, imageManifestMediaTypes)
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		if digest != "" {
+			lock.Images[image] = digest
+			changed = true
+		}
+	}
+
+	if config != nil && boxConfig.PrebuiltImage == "" && !Offline {
+		features, err := config.FeatureList()
+		if err != nil {
+			return nil, false, err
+		}
+		for _, feature := range features {
+			ref := feature.Ref
+			if _, ok := lock.Features[ref.String()]; ok || ref.Kind != devcontainer.FeatureRefOCI || ref.Digest != "" {
+				continue
+			}
+			locked, err := registry.resolveFeature(ref)
+			if err != nil {
+				return nil, false, err
+			}
+			lock.Features[ref.String()] = locked
+			changed = true
+		}
+	}
+	return lock, changed, nil
+}
+
+// writeResolvedLock writes a lock resolved by resolveLock once the build
+// using it succeeded. A lock that can't be written doesn't make the box any
+// less usable.
+func writeResolvedLock(boxConfig BoxConfig, lock *BoxLock) {
+	if err := WriteLock(boxConfig, lock); err != nil {
+		fmt.Fprintf(Stdout, "Warning: not locking %s: %v\n", boxConfig.Name, err)
+		return
+	}
+	fmt.Fprintf(Stdout, "Locked the images and features of %s in %s\n", boxConfig.Name, LockPath(boxConfig))
+}
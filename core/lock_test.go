@@ -0,0 +1,158 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestLockRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	boxConfig := BoxConfig{Name: "app", Config: filepath.Join(dir, "devcontainer.json")}
+	if path := LockPath(boxConfig); path != filepath.Join(dir, LockFile) {
+		t.Errorf("LockPath() = %s, want %s", path, filepath.Join(dir, LockFile))
+	}
+
+	lock, err := LoadLock(boxConfig)
+	if err != nil || lock != nil {
+		t.Fatalf("LoadLock() without a lock file = %v, %v, want nil", lock, err)
+	}
+
+	expected := &BoxLock{
+		Images:   map[string]string{"ubuntu:24.04": "sha256:abc"},
+		Features: map[string]LockedFeature{"ghcr.io/devcontainers/features/go:1": {Version: "1.3.1", Digest: "sha256:def"}},
+	}
+	if err := WriteLock(boxConfig, expected); err != nil {
+		t.Fatalf("WriteLock() error = %v", err)
+	}
+	lock, err = LoadLock(boxConfig)
+	if err != nil {
+		t.Fatalf("LoadLock() error = %v", err)
+	}
+	if !reflect.DeepEqual(lock, expected) {
+		t.Errorf("LoadLock() = %+v, want %+v", lock, expected)
+	}
+}
+
+func TestApplyLock(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{Features: map[string]interface{}{
+		"ghcr.io/devcontainers/features/go:1":   map[string]interface{}{"version": "1.22"},
+		"ghcr.io/devcontainers/features/node:1": map[string]interface{}{},
+	}}
+	lock := &BoxLock{Features: map[string]LockedFeature{
+		"ghcr.io/devcontainers/features/go:1": {Version: "1.3.1", Digest: "sha256:def"},
+	}}
+	if err := applyLock(config, lock); err != nil {
+		t.Fatalf("applyLock() error = %v", err)
+	}
+
+	expected := map[string]interface{}{
+		"ghcr.io/devcontainers/features/go@sha256:def": map[string]interface{}{"version": "1.22"},
+		"ghcr.io/devcontainers/features/node:1":        map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(config.Features, expected) {
+		t.Errorf("applyLock() features = %v, want %v", config.Features, expected)
+	}
+}
+
+func TestResolveFeature(t *testing.T) {
+	manifest := `{"layers": [], "annotations": {"dev.containers.metadata": "{\"id\": \"go\", \"version\": \"1.3.1\"}"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/devcontainers/features/go/manifests/1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(manifest))
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	ref, err := devcontainer.ParseFeatureRef(registry + "/devcontainers/features/go:1")
+	if err != nil {
+		t.Fatalf("ParseFeatureRef() error = %v", err)
+	}
+
+	client := &registryClient{http: server.Client(), scheme: "http", tokens: map[string]string{}}
+	locked, err := client.resolveFeature(ref)
+	if err != nil {
+		t.Fatalf("resolveFeature() error = %v", err)
+	}
+	sum := sha256.Sum256([]byte(manifest))
+	expected := LockedFeature{Version: "1.3.1", Digest: "sha256:" + hex.EncodeToString(sum[:])}
+	if locked != expected {
+		t.Errorf("resolveFeature() = %+v, want %+v", locked, expected)
+	}
+}
+
+func TestPinDockerfileImages(t *testing.T) {
+	dockerfile := `FROM --platform=linux/amd64 golang:1.22 AS build
+RUN go build ./...
+
+FROM build AS test
+FROM ubuntu:24.04
+COPY --from=build /app /app
+`
+	digests := map[string]string{
+		"golang:1.22":  "sha256:abc",
+		"ubuntu:24.04": "sha256:def",
+		"build":        "sha256:123",
+	}
+	pinned, changed := pinDockerfileImages([]byte(dockerfile), digests)
+	if !changed {
+		t.Fatalf("pinDockerfileImages() changed = false, want true")
+	}
+
+	expected := `FROM --platform=linux/amd64 docker.io/library/golang@sha256:abc AS build
+RUN go build ./...
+
+FROM build AS test
+FROM docker.io/library/ubuntu@sha256:def
+COPY --from=build /app /app
+`
+	if string(pinned) != expected {
+		t.Errorf("pinDockerfileImages() = %q, want %q", pinned, expected)
+	}
+
+	if _, changed := pinDockerfileImages([]byte("FROM alpine:3\n"), digests); changed {
+		t.Errorf("pinDockerfileImages() of an unlocked image changed = true, want false")
+	}
+}
+
+func TestPinLockedImages(t *testing.T) {
+	lock := &BoxLock{Images: map[string]string{"ubuntu:24.04": "sha256:def"}}
+
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu:24.04"}
+	dir, err := pinLockedImages(config, lock)
+	if err != nil || dir != "" {
+		t.Fatalf("pinLockedImages() = %q, %v, want no Dockerfile", dir, err)
+	}
+	if config.Image != "docker.io/library/ubuntu@sha256:def" {
+		t.Errorf("pinLockedImages() image = %s, want docker.io/library/ubuntu@sha256:def", config.Image)
+	}
+
+	original := filepath.Join(t.TempDir(), "Dockerfile")
+	if err := os.WriteFile(original, []byte("FROM ubuntu:24.04\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config = &devcontainer.DevContainerConfig{Build: &devcontainer.BuildOptions{Dockerfile: original}}
+	dir, err = pinLockedImages(config, lock)
+	if err != nil {
+		t.Fatalf("pinLockedImages() error = %v", err)
+	}
+	defer os.RemoveAll(dir)
+	if config.Build.Dockerfile == original || filepath.Dir(config.Build.Dockerfile) != dir {
+		t.Errorf("pinLockedImages() Dockerfile = %s, want a copy in %s", config.Build.Dockerfile, dir)
+	}
+	data, err := os.ReadFile(original)
+	if err != nil || string(data) != "FROM ubuntu:24.04\n" {
+		t.Errorf("pinLockedImages() changed the original Dockerfile to %q", data)
+	}
+}
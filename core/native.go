@@ -0,0 +1,768 @@
+package core
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+	"golang.org/x/term"
+)
+
+// nativeStrategy does what the devcontainer CLI does itself, with the docker
+// CLI on the host, so neither the CLI nor node have to be installed. It runs
+// up, build, exec and run-user-commands for configs with an image or a
+// Dockerfile. Features and docker compose configs need the CLI, and the
+// remote user's UID isn't updated to the host user's.
+type nativeStrategy struct {
+	// docker is the docker CLI, or nerdctl with containerd
+	docker string
+	// containerd points nerdctl at containerd when set
+	containerd *ContainerdConfig
+	// env is added to the docker CLI's environment, see devcontainerCliEnv
+	env []string
+}
+
+func (s nativeStrategy) runsOnHost() bool {
+	return true
+}
+
+func (s nativeStrategy) help(command string) error {
+	fmt.Fprintf(Stdout, "With the %s execution strategy tape runs devcontainer %s itself with %s, it takes none of the devcontainer CLI's options\n",
+		ExecutionStrategyNative, command, s.docker)
+	return nil
+}
+
+func (s nativeStrategy) run(dc *DevcontainerCommand, configJSON []byte, hostPaths []string, auths *container.DockerConfig) error {
+	if configJSON == nil {
+		return fmt.Errorf("the %s execution strategy needs a devcontainer config", ExecutionStrategyNative)
+	}
+	config, err := devcontainer.ParseDevContainer(configJSON)
+	if err != nil {
+		return fmt.Errorf("error parsing config: %v", err)
+	}
+	if err := nativeSupported(config); err != nil {
+		return err
+	}
+	config, err = substituteVariables(config, dc.BoxConfig)
+	if err != nil {
+		return err
+	}
+	args, err := parseNativeArgs(dc.Command, dc.AdditionalArgs)
+	if err != nil {
+		return err
+	}
+
+	env, cleanup, err := hostDockerEnv(dc.BoxConfig, s.containerd, auths)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	n := &nativeRun{
+		docker:  s.docker,
+		env:     append(env, s.env...),
+		box:     dc.BoxConfig,
+		config:  config,
+		args:    args,
+		secrets: secretsEnv(dc.Secrets),
+	}
+	switch dc.Command {
+	case "up":
+		return n.up()
+	case "build":
+		_, err := n.buildImage(args.imageNames)
+		return err
+	case "exec":
+		return n.exec()
+	case "run-user-commands":
+		containerID, err := n.containerID()
+		if err != nil {
+			return err
+		}
+		return n.lifecycle(containerID)
+	}
+	return fmt.Errorf("the %s execution strategy doesn't support devcontainer %s", ExecutionStrategyNative, dc.Command)
+}
+
+// nativeSupported returns an error for configs only the devcontainer CLI can run
+func nativeSupported(config *devcontainer.DevContainerConfig) error {
+	switch {
+	case config.DockerComposeFile != nil:
+		return fmt.Errorf("the %s execution strategy doesn't support docker compose configs, use %s or %s",
+			ExecutionStrategyNative, ExecutionStrategyContainer, ExecutionStrategyLocalBinary)
+	case len(config.Features) > 0:
+		return fmt.Errorf("the %s execution strategy doesn't support features, use %s or %s",
+			ExecutionStrategyNative, ExecutionStrategyContainer, ExecutionStrategyLocalBinary)
+	}
+	return nil
+}
+
+// variablePattern matches the devcontainer spec's ${name} and ${name:argument} variables
+var variablePattern = regexp.MustCompile(`\$\{(\w+)(?::([^}]*))?\}`)
+
+// substituteVariables replaces the variables the devcontainer CLI resolves on
+// the host in the config's strings. ${containerEnv:...} is left for
+// resolveRemoteEnv, which resolves it from the container.
+func substituteVariables(config *devcontainer.DevContainerConfig, boxConfig BoxConfig) (*devcontainer.DevContainerConfig, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("error parsing config: %v", err)
+	}
+
+	local := map[string]string{
+		"localWorkspaceFolder":         boxConfig.Workspace,
+		"localWorkspaceFolderBasename": path.Base(DockerPath(boxConfig.Workspace)),
+	}
+	replace := func(s string) string {
+		return variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+			groups := variablePattern.FindStringSubmatch(match)
+			switch groups[1] {
+			case "localEnv", "env":
+				name, fallback, _ := strings.Cut(groups[2], ":")
+				if value, ok := os.LookupEnv(name); ok {
+					return value
+				}
+				return fallback
+			}
+			if value, ok := local[groups[1]]; ok {
+				return value
+			}
+			return match
+		})
+	}
+	folder := replace(cmp.Or(config.WorkspaceFolder, defaultWorkspaceFolder(boxConfig)))
+	local["containerWorkspaceFolder"] = folder
+	local["containerWorkspaceFolderBasename"] = path.Base(folder)
+
+	data, err = json.Marshal(substituteStrings(value, replace))
+	if err != nil {
+		return nil, fmt.Errorf("error serializing config to JSON: %v", err)
+	}
+	return devcontainer.ParseDevContainer(data)
+}
+
+// substituteStrings replaces every string in a decoded JSON value with replace
+func substituteStrings(value interface{}, replace func(string) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return replace(v)
+	case []interface{}:
+		for i := range v {
+			v[i] = substituteStrings(v[i], replace)
+		}
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = substituteStrings(v[key], replace)
+		}
+	}
+	return value
+}
+
+// nativeArgs are the devcontainer CLI options tape passes to its commands
+type nativeArgs struct {
+	noCache            bool
+	removeExisting     bool
+	skipNonBlocking    bool
+	containerID        string
+	imageNames         []string
+	dotfilesRepository string
+	consistency        string
+	cacheFrom          []string
+	cacheTo            string
+	remoteEnv          []string
+	// command is the command exec runs
+	command []string
+}
+
+// parseNativeArgs parses the devcontainer CLI options in args. Options the
+// native strategy doesn't implement are an error rather than ignored.
+func parseNativeArgs(command string, args []string) (nativeArgs, error) {
+	var parsed nativeArgs
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			if command != "exec" {
+				return nativeArgs{}, fmt.Errorf("unexpected argument %s to devcontainer %s", arg, command)
+			}
+			parsed.command = args[i:]
+			break
+		}
+
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch name {
+		case "--build-no-cache", "--no-cache":
+			parsed.noCache = true
+			continue
+		case "--remove-existing-container":
+			parsed.removeExisting = true
+			continue
+		case "--skip-non-blocking-commands":
+			parsed.skipNonBlocking = true
+			continue
+		case "--container-id", "--image-name", "--dotfiles-repository", "--workspace-mount-consistency",
+			"--cache-from", "--cache-to", "--remote-env":
+		default:
+			return nativeArgs{}, fmt.Errorf("the %s execution strategy doesn't support devcontainer %s's %s option", ExecutionStrategyNative, command, name)
+		}
+		if !hasValue {
+			if i+1 == len(args) {
+				return nativeArgs{}, fmt.Errorf("%s needs a value", name)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "--container-id":
+			parsed.containerID = value
+		case "--image-name":
+			parsed.imageNames = append(parsed.imageNames, value)
+		case "--dotfiles-repository":
+			parsed.dotfilesRepository = value
+		case "--workspace-mount-consistency":
+			parsed.consistency = value
+		case "--cache-from":
+			parsed.cacheFrom = append(parsed.cacheFrom, value)
+		case "--cache-to":
+			parsed.cacheTo = value
+		case "--remote-env":
+			parsed.remoteEnv = append(parsed.remoteEnv, value)
+		}
+	}
+	if command == "exec" && len(parsed.command) == 0 {
+		return nativeArgs{}, fmt.Errorf("devcontainer exec needs a command")
+	}
+	return parsed, nil
+}
+
+// hostDockerEnv returns the environment for a docker CLI, or a devcontainer
+// CLI running one, on the host: pointed at the box's docker host or at
+// containerd, with the box's registry credentials. cleanup removes the
+// credentials.
+func hostDockerEnv(boxConfig BoxConfig, containerd *ContainerdConfig, auths *container.DockerConfig) (env []string, cleanup func(), err error) {
+	env = os.Environ()
+	if containerd != nil {
+		env = append(env, containerd.Env()...)
+	} else if boxConfig.DockerHost != "" {
+		env = append(env, "DOCKER_HOST="+boxConfig.DockerHost)
+	}
+	// docker on the host has the user's credentials, only the box's own have to be added
+	if len(boxConfig.Registries) == 0 {
+		return env, func() {}, nil
+	}
+	dir, cleanup, err := writeDockerConfigDir(auths)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(env, "DOCKER_CONFIG="+dir), cleanup, nil
+}
+
+// nativeRun is a devcontainer CLI command the native strategy runs
+type nativeRun struct {
+	docker  string
+	env     []string
+	box     BoxConfig
+	config  *devcontainer.DevContainerConfig
+	args    nativeArgs
+	secrets []string
+}
+
+// command returns the docker CLI with args, its output going to the terminal
+func (n *nativeRun) command(args ...string) *exec.Cmd {
+	cmd := exec.Command(n.docker, args...)
+	cmd.Env = n.env
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	return cmd
+}
+
+// output runs the docker CLI with args and returns its trimmed output
+func (n *nativeRun) output(args ...string) (string, error) {
+	cmd := n.command(args...)
+	cmd.Stdout = nil
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running %s %s: %w", n.docker, args[0], err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// up creates the box's container, or starts the existing one, and runs the
+// lifecycle commands that haven't run in it yet
+func (n *nativeRun) up() error {
+	if err := runInitializeCommand(n.config, n.box.Workspace); err != nil {
+		return err
+	}
+
+	cli, err := newBoxClient(n.box)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+	ctx := context.Background()
+
+	existing, err := FindDevContainer(n.box)
+	if container.IsContainerNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err
+	}
+	if existing != nil && n.args.removeExisting {
+		if err := cli.RemoveContainer(ctx, existing.ID); err != nil {
+			return fmt.Errorf("error removing container: %w", err)
+		}
+		existing = nil
+	}
+
+	var containerID string
+	if existing != nil {
+		containerID = existing.ID
+		if existing.State != container.StateRunning {
+			if err := cli.StartContainer(ctx, containerID); err != nil {
+				return fmt.Errorf("error starting container: %w", err)
+			}
+		}
+	} else {
+		image, err := n.buildImage([]string{nativeImageName(n.box)})
+		if err != nil {
+			return err
+		}
+		args, err := nativeRunArgs(n.box, n.config, image, n.args.consistency)
+		if err != nil {
+			return err
+		}
+		containerID, err = n.output(args...)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.lifecycle(containerID)
+}
+
+// nativeImageName is the image up builds from the box's Dockerfile
+func nativeImageName(boxConfig BoxConfig) string {
+	return "tape-" + strings.ToLower(boxConfig.ContainerName())
+}
+
+// buildImage builds the config's Dockerfile tagged with tags and returns the
+// first tag, or returns the config's image when it has no Dockerfile
+func (n *nativeRun) buildImage(tags []string) (string, error) {
+	dockerfile, buildContext := n.config.DockerFile, n.config.Context
+	build := n.config.Build
+	if build != nil {
+		dockerfile, buildContext = build.Dockerfile, build.Context
+	}
+	if dockerfile == "" {
+		if n.config.Image == "" {
+			return "", fmt.Errorf("the devcontainer config has neither an image nor a Dockerfile")
+		}
+		if len(tags) > 0 && tags[0] != nativeImageName(n.box) {
+			// build --image-name of an image config only tags it, as the CLI does without features
+			for _, tag := range tags {
+				if err := n.command("tag", n.config.Image, tag).Run(); err != nil {
+					return "", fmt.Errorf("error tagging image: %w", err)
+				}
+			}
+		}
+		return n.config.Image, nil
+	}
+	if len(tags) == 0 {
+		return "", fmt.Errorf("devcontainer build needs an --image-name")
+	}
+
+	args := []string{"build", "-f", dockerfile}
+	for _, tag := range tags {
+		args = append(args, "-t", tag)
+	}
+	if build != nil {
+		for _, name := range slices.Sorted(maps.Keys(build.Args)) {
+			args = append(args, "--build-arg", name+"="+build.Args[name])
+		}
+		if build.Target != "" {
+			args = append(args, "--target", build.Target)
+		}
+		args = append(args, build.Options...)
+	}
+	if n.args.noCache {
+		args = append(args, "--no-cache")
+	}
+	for _, source := range n.args.cacheFrom {
+		args = append(args, "--cache-from", source)
+	}
+	if n.args.cacheTo != "" {
+		args = append(args, "--cache-to", n.args.cacheTo)
+	}
+	args = append(args, buildContext)
+
+	if err := n.command(args...).Run(); err != nil {
+		return "", fmt.Errorf("error building image: %w", err)
+	}
+	return tags[0], nil
+}
+
+// keepAliveScript keeps a container whose command the config overrides
+// running, the way the devcontainer CLI does
+const keepAliveScript = `echo Container started
+trap "exit 0" 15
+exec "$@"
+while sleep 1 & wait $!; do :; done`
+
+// nativeRunArgs returns the docker run arguments that create the box's
+// container from image, with the labels, mounts and environment the
+// devcontainer CLI gives it
+func nativeRunArgs(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, image string, consistency string) ([]string, error) {
+	args := []string{"run", "-d",
+		"--label", HostFolderLabel + "=" + boxConfig.Workspace,
+		"--label", ConfigFileLabel + "=" + boxConfig.Config,
+	}
+
+	workspaceMount := config.WorkspaceMount
+	if workspaceMount == "" {
+		folder := cmp.Or(config.WorkspaceFolder, defaultWorkspaceFolder(boxConfig))
+		workspaceMount = fmt.Sprintf("type=bind,source=%s,target=%s", boxConfig.Workspace, folder)
+		if consistency != "" {
+			workspaceMount += ",consistency=" + consistency
+		}
+	}
+	args = append(args, "--mount", workspaceMount)
+
+	for _, mount := range config.Mounts {
+		spec, err := mount.DockerMountSpec()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--mount", spec)
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.ContainerEnv)) {
+		args = append(args, "-e", name+"="+config.ContainerEnv[name])
+	}
+	for _, port := range appPorts(config.AppPort) {
+		args = append(args, "-p", port)
+	}
+	if config.ContainerUser != "" {
+		args = append(args, "-u", config.ContainerUser)
+	}
+	if gpu := config.HostRequirements; gpu != nil && gpu.GPU != nil && gpu.GPU.IsBool() && gpu.GPU.AsBool() {
+		args = append(args, "--gpus", "all")
+	}
+	args = append(args, config.RunArgs...)
+
+	if config.OverrideCommand != nil && !*config.OverrideCommand {
+		return append(args, image), nil
+	}
+	return append(args, "--entrypoint", "/bin/sh", image, "-c", keepAliveScript, "-"), nil
+}
+
+// appPorts returns the ports appPort publishes, in docker -p syntax
+func appPorts(appPort *devcontainer.AppPortValue) []string {
+	if appPort == nil {
+		return nil
+	}
+	values := appPort.AsArray()
+	if values == nil {
+		if port := appPort.AsInt(); port != 0 {
+			values = []interface{}{float64(port)}
+		} else if port := appPort.AsString(); port != "" {
+			values = []interface{}{port}
+		}
+	}
+
+	var ports []string
+	for _, value := range values {
+		switch v := value.(type) {
+		case float64:
+			// a port number is published on the same port of the host's loopback address
+			ports = append(ports, fmt.Sprintf("127.0.0.1:%d:%d", int(v), int(v)))
+		case string:
+			ports = append(ports, v)
+		}
+	}
+	return ports
+}
+
+// containerID returns the container given with --container-id, or the box's
+func (n *nativeRun) containerID() (string, error) {
+	if n.args.containerID != "" {
+		return n.args.containerID, nil
+	}
+	dc, err := FindDevContainer(n.box)
+	if err != nil {
+		return "", err
+	}
+	return dc.ID, nil
+}
+
+// remoteUser is the user commands run as in the container
+func (n *nativeRun) remoteUser() string {
+	return cmp.Or(n.config.RemoteUser, n.config.ContainerUser)
+}
+
+// exec runs the command attached to the terminal as the remote user
+func (n *nativeRun) exec() error {
+	containerID, err := n.containerID()
+	if err != nil {
+		return err
+	}
+	env, err := n.remoteEnv(containerID)
+	if err != nil {
+		return err
+	}
+	env = append(env, n.args.remoteEnv...)
+
+	args := []string{"exec", "-i"}
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		args = append(args, "-t")
+	}
+	cmd := n.command(append(args, n.execArgs(containerID, env, n.args.command)...)...)
+	cmd.Env = append(slices.Clip(n.env), env...)
+	cmd.Stdin = os.Stdin
+
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); errors.As(err, &exitErr) {
+		return &container.ExitError{Code: exitErr.ExitCode()}
+	} else if err != nil {
+		return fmt.Errorf("error running command: %w", err)
+	}
+	return nil
+}
+
+// execArgs returns the docker exec arguments, after exec's own flags, that run
+// argv as the remote user in the workspace folder. The variables are only
+// named, docker exec takes their values from its environment so they aren't
+// on its command line.
+func (n *nativeRun) execArgs(containerID string, env []string, argv []string) []string {
+	var args []string
+	if user := n.remoteUser(); user != "" {
+		args = append(args, "-u", user)
+	}
+	args = append(args, "-w", cmp.Or(n.config.WorkspaceFolder, defaultWorkspaceFolder(n.box)))
+	for _, variable := range env {
+		name, _, _ := strings.Cut(variable, "=")
+		args = append(args, "-e", name)
+	}
+	return append(append(args, containerID), argv...)
+}
+
+// containerEnvPattern matches ${containerEnv:NAME} and ${containerEnv:NAME:default}
+var containerEnvPattern = regexp.MustCompile(`\$\{containerEnv:([^}:]+)(?::([^}]*))?\}`)
+
+// resolveRemoteEnv returns remoteEnv as KEY=VALUE variables, sorted by name,
+// resolving ${containerEnv:NAME} from the environment containerEnv returns,
+// which is only called when a variable refers to it. Variables set to null
+// are left out.
+func resolveRemoteEnv(remoteEnv map[string]*string, containerEnv func() (map[string]string, error)) ([]string, error) {
+	var (
+		lookup map[string]string
+		env    []string
+	)
+	for _, name := range slices.Sorted(maps.Keys(remoteEnv)) {
+		value := remoteEnv[name]
+		if value == nil {
+			continue
+		}
+		resolved := *value
+		if containerEnvPattern.MatchString(resolved) && lookup == nil {
+			var err error
+			lookup, err = containerEnv()
+			if err != nil {
+				return nil, err
+			}
+		}
+		resolved = containerEnvPattern.ReplaceAllStringFunc(resolved, func(match string) string {
+			groups := containerEnvPattern.FindStringSubmatch(match)
+			if value, ok := lookup[groups[1]]; ok {
+				return value
+			}
+			return groups[2]
+		})
+		env = append(env, name+"="+resolved)
+	}
+	return env, nil
+}
+
+// remoteEnv returns the config's remoteEnv and the secrets as KEY=VALUE
+// variables, see resolveRemoteEnv
+func (n *nativeRun) remoteEnv(containerID string) ([]string, error) {
+	env, err := resolveRemoteEnv(n.config.RemoteEnv, func() (map[string]string, error) {
+		return n.containerEnv(containerID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(env, n.secrets...), nil
+}
+
+// containerEnv returns the environment the container was created with
+func (n *nativeRun) containerEnv(containerID string) (map[string]string, error) {
+	out, err := n.output("inspect", "--format", "{{json .Config.Env}}", containerID)
+	if err != nil {
+		return nil, err
+	}
+	var variables []string
+	if err := json.Unmarshal([]byte(out), &variables); err != nil {
+		return nil, fmt.Errorf("error parsing the container's environment: %v", err)
+	}
+	return parseEnv(variables), nil
+}
+
+// parseEnv maps KEY=VALUE variables by name
+func parseEnv(variables []string) map[string]string {
+	env := make(map[string]string, len(variables))
+	for _, variable := range variables {
+		if name, value, ok := strings.Cut(variable, "="); ok {
+			env[name] = value
+		}
+	}
+	return env
+}
+
+// lifecycle runs the lifecycle commands that haven't run in the container
+// yet. Like the devcontainer CLI it keeps markers in the remote user's home:
+// the create commands and the dotfiles run once per container, the
+// postStartCommand once per start and the postAttachCommand every time.
+func (n *nativeRun) lifecycle(containerID string) error {
+	cli, err := newBoxClient(n.box)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	details, err := cli.InspectContainer(context.Background(), containerID)
+	cli.Close()
+	if err != nil {
+		return err
+	}
+	created := details.Created.Format(time.RFC3339Nano)
+	started := details.StartedAt.Format(time.RFC3339Nano)
+
+	env, err := n.remoteEnv(containerID)
+	if err != nil {
+		return err
+	}
+	waitFor := waitForIndex(n.config)
+	for i, phase := range lifecyclePhases {
+		if n.args.skipNonBlocking && i > waitFor {
+			break
+		}
+		marker := created
+		switch phase {
+		case "postStartCommand":
+			marker = started
+		case "postAttachCommand":
+			marker = ""
+		}
+		if err := n.runOnce(containerID, phase, marker, func() error {
+			return n.runPhase(containerID, env, phase)
+		}); err != nil {
+			return err
+		}
+
+		if phase == "postCreateCommand" && n.args.dotfilesRepository != "" {
+			if err := n.runOnce(containerID, "dotfiles", created, func() error {
+				return n.installDotfiles(containerID, env)
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// runOnce calls run unless the container's marker for name holds value, and
+// records value after run succeeds. An empty value always runs it.
+func (n *nativeRun) runOnce(containerID, name, value string, run func() error) error {
+	if value == "" {
+		return run()
+	}
+	file := fmt.Sprintf(`"$HOME/.devcontainer/.%sMarker"`, name)
+	current, err := n.output(append([]string{"exec"}, n.execArgs(containerID, nil, []string{"/bin/sh", "-c", "cat " + file + " 2>/dev/null || true"})...)...)
+	if err != nil {
+		return err
+	}
+	if current == value {
+		return nil
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	script := `mkdir -p "$HOME/.devcontainer" && printf %s "$1" > ` + file
+	_, err = n.output(append([]string{"exec"}, n.execArgs(containerID, nil, []string{"/bin/sh", "-c", script, "-", value})...)...)
+	return err
+}
+
+// runPhase runs a lifecycle phase's commands in the container, in parallel
+// for the object form
+func (n *nativeRun) runPhase(containerID string, env []string, phase string) error {
+	commands, err := lifecycleCommand(n.config, phase).Normalize()
+	if err != nil {
+		return fmt.Errorf("invalid %s: %v", phase, err)
+	}
+	if len(commands) == 0 {
+		return nil
+	}
+	fmt.Fprintf(Stdout, "Running the %s...\n", phase)
+
+	errs := make([]error, len(commands))
+	var wg sync.WaitGroup
+	for i, command := range commands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cmd := n.command(append([]string{"exec"}, n.execArgs(containerID, env, command.Argv())...)...)
+			cmd.Env = append(slices.Clip(n.env), env...)
+			if err := cmd.Run(); err != nil {
+				errs[i] = fmt.Errorf("%s failed: %w", phase, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// dotfilesScript clones the repository in $1 to ~/dotfiles and runs its
+// install script, or links its dotfiles into the home directory when it has
+// none, the way the devcontainer CLI installs dotfiles
+const dotfilesScript = `set -e
+target="$HOME/dotfiles"
+[ -d "$target" ] || git clone --depth 1 "$1" "$target"
+cd "$target"
+for script in install.sh install bootstrap.sh bootstrap script/bootstrap setup.sh setup script/setup; do
+	if [ -f "$script" ]; then
+		chmod +x "$script"
+		exec "./$script"
+	fi
+done
+for file in .[!.]*; do
+	[ "$file" = .git ] || ln -sf "$target/$file" "$HOME/$file"
+done`
+
+// installDotfiles installs the dotfiles repository as the remote user
+func (n *nativeRun) installDotfiles(containerID string, env []string) error {
+	fmt.Fprintf(Stdout, "Installing dotfiles from %s...\n", n.args.dotfilesRepository)
+	repository := n.args.dotfilesRepository
+	// owner/repo is shorthand for a GitHub repository
+	if !strings.Contains(repository, ":") && strings.Count(repository, "/") == 1 {
+		repository = "https://github.com/" + repository
+	}
+	cmd := n.command(append([]string{"exec"}, n.execArgs(containerID, env, []string{"/bin/sh", "-c", dotfilesScript, "-", repository})...)...)
+	cmd.Env = append(slices.Clip(n.env), env...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error installing dotfiles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestParseNativeArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  string
+		args     []string
+		expected nativeArgs
+		wantErr  bool
+	}{
+		{
+			name:    "up",
+			command: "up",
+			args:    []string{"--build-no-cache", "--dotfiles-repository", "acme/dotfiles", "--remove-existing-container", "--cache-from=type=registry,ref=ghcr.io/acme/cache"},
+			expected: nativeArgs{
+				noCache:            true,
+				removeExisting:     true,
+				dotfilesRepository: "acme/dotfiles",
+				cacheFrom:          []string{"type=registry,ref=ghcr.io/acme/cache"},
+			},
+		},
+		{
+			name:     "exec",
+			command:  "exec",
+			args:     []string{"--remote-env", "A=1", "ls", "--all"},
+			expected: nativeArgs{remoteEnv: []string{"A=1"}, command: []string{"ls", "--all"}},
+		},
+		{
+			name:     "build",
+			command:  "build",
+			args:     []string{"--image-name", "app:1", "--no-cache"},
+			expected: nativeArgs{imageNames: []string{"app:1"}, noCache: true},
+		},
+		{
+			name:    "unsupported option",
+			command: "up",
+			args:    []string{"--skip-post-attach"},
+			wantErr: true,
+		},
+		{
+			name:    "missing value",
+			command: "up",
+			args:    []string{"--dotfiles-repository"},
+			wantErr: true,
+		},
+		{
+			name:    "exec without a command",
+			command: "exec",
+			args:    []string{"--remote-env", "A=1"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseNativeArgs(tt.command, tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseNativeArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("parseNativeArgs() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNativeRunArgs(t *testing.T) {
+	boxConfig := BoxConfig{Workspace: "/src/web", Config: "/src/web/.devcontainer/devcontainer.json"}
+	config, err := devcontainer.ParseDevContainer([]byte(`{
+		"image": "ubuntu",
+		"containerEnv": {"B": "2", "A": "1"},
+		"mounts": ["type=volume,source=cache,target=/cache"],
+		"appPort": [3000, "8080:80"],
+		"runArgs": ["--name", "tape-web"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := nativeRunArgs(boxConfig, config, "ubuntu", "cached")
+	if err != nil {
+		t.Fatalf("nativeRunArgs() error = %v", err)
+	}
+	expected := []string{"run", "-d",
+		"--label", "devcontainer.local_folder=/src/web",
+		"--label", "devcontainer.config_file=/src/web/.devcontainer/devcontainer.json",
+		"--mount", "type=bind,source=/src/web,target=/workspaces/web,consistency=cached",
+		"--mount", "type=volume,source=cache,target=/cache",
+		"-e", "A=1", "-e", "B=2",
+		"-p", "127.0.0.1:3000:3000", "-p", "8080:80",
+		"--name", "tape-web",
+		"--entrypoint", "/bin/sh", "ubuntu", "-c", keepAliveScript, "-",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("nativeRunArgs() = %q, want %q", got, expected)
+	}
+
+	override := false
+	config.OverrideCommand = &override
+	got, err = nativeRunArgs(boxConfig, config, "ubuntu", "")
+	if err != nil {
+		t.Fatalf("nativeRunArgs() error = %v", err)
+	}
+	if got[len(got)-1] != "ubuntu" || got[len(got)-2] != "tape-web" {
+		t.Errorf("nativeRunArgs() = %q, want the image's own command without overrideCommand", got)
+	}
+}
+
+func TestSubstituteVariables(t *testing.T) {
+	t.Setenv("TAPE_TEST_TOKEN", "abc")
+	config, err := devcontainer.ParseDevContainer([]byte(`{
+		"image": "ubuntu",
+		"workspaceFolder": "/work/${localWorkspaceFolderBasename}",
+		"containerEnv": {"TOKEN": "${localEnv:TAPE_TEST_TOKEN}", "MISSING": "${localEnv:TAPE_TEST_UNSET:fallback}"},
+		"remoteEnv": {"PATH": "${containerEnv:PATH}:${containerWorkspaceFolder}/bin"},
+		"mounts": ["type=bind,source=${localWorkspaceFolder}/.cache,target=/cache"]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := substituteVariables(config, BoxConfig{Workspace: "/src/web"})
+	if err != nil {
+		t.Fatalf("substituteVariables() error = %v", err)
+	}
+	if got.WorkspaceFolder != "/work/web" {
+		t.Errorf("workspaceFolder = %q, want /work/web", got.WorkspaceFolder)
+	}
+	if !reflect.DeepEqual(got.ContainerEnv, map[string]string{"TOKEN": "abc", "MISSING": "fallback"}) {
+		t.Errorf("containerEnv = %v, want the local variables", got.ContainerEnv)
+	}
+	if path := *got.RemoteEnv["PATH"]; path != "${containerEnv:PATH}:/work/web/bin" {
+		t.Errorf("remoteEnv PATH = %q, want ${containerEnv:PATH} left for the container", path)
+	}
+	if mount := got.Mounts[0].AsString(); mount != "type=bind,source=/src/web/.cache,target=/cache" {
+		t.Errorf("mount = %q, want the local workspace folder substituted", mount)
+	}
+}
+
+func TestNativeSupported(t *testing.T) {
+	tests := []struct {
+		config  string
+		wantErr bool
+	}{
+		{`{"image": "ubuntu"}`, false},
+		{`{"build": {"dockerfile": "Dockerfile"}}`, false},
+		{`{"image": "ubuntu", "features": {"ghcr.io/devcontainers/features/go:1": {}}}`, true},
+		{`{"dockerComposeFile": "compose.yml", "service": "app"}`, true},
+	}
+
+	for _, tt := range tests {
+		config, err := devcontainer.ParseDevContainer([]byte(tt.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := nativeSupported(config); (err != nil) != tt.wantErr {
+			t.Errorf("nativeSupported(%s) error = %v, wantErr %v", tt.config, err, tt.wantErr)
+		}
+	}
+}
+
+func TestNativeExec(t *testing.T) {
+	// a fake docker CLI records its arguments and the variables it's given
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "docker")
+	script := `#!/bin/sh
+echo "$@" > "$TAPE_TEST_DIR/args"
+echo "$TOKEN $GREETING" > "$TAPE_TEST_DIR/env"
+`
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPE_TEST_DIR", dir)
+
+	dc := &DevcontainerCommand{
+		BoxConfig:      BoxConfig{Workspace: "/src/web"},
+		Command:        "exec",
+		AdditionalArgs: []string{"--container-id", "abc123", "ls"},
+		Secrets:        map[string]string{"TOKEN": "s3cret-value"},
+	}
+	configJSON := []byte(`{"image": "ubuntu", "remoteUser": "dev", "remoteEnv": {"GREETING": "hello"}}`)
+	if err := (nativeStrategy{docker: binary}).run(dc, configJSON, nil, &container.DockerConfig{}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	if args := read("args"); args != "exec -i -u dev -w /workspaces/web -e GREETING -e TOKEN abc123 ls" {
+		t.Errorf("docker arguments = %q, want exec as the remote user with the variables only named", args)
+	}
+	if env := read("env"); env != "s3cret-value hello" {
+		t.Errorf("docker environment = %q, want the secret and the remoteEnv", env)
+	}
+}
+
+func TestResolveRemoteEnv(t *testing.T) {
+	path := "${containerEnv:PATH}:/opt/bin"
+	editor := "${containerEnv:EDITOR:vi}"
+	greeting := "hello"
+	remoteEnv := map[string]*string{"PATH": &path, "EDITOR": &editor, "GREETING": &greeting, "UNSET": nil}
+
+	lookups := 0
+	got, err := resolveRemoteEnv(remoteEnv, func() (map[string]string, error) {
+		lookups++
+		return map[string]string{"PATH": "/usr/bin"}, nil
+	})
+	if err != nil {
+		t.Fatalf("resolveRemoteEnv() error = %v", err)
+	}
+	expected := []string{"EDITOR=vi", "GREETING=hello", "PATH=/usr/bin:/opt/bin"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("resolveRemoteEnv() = %v, want %v", got, expected)
+	}
+	if lookups != 1 {
+		t.Errorf("resolveRemoteEnv() read the container's environment %d times, want once", lookups)
+	}
+
+	if _, err := resolveRemoteEnv(map[string]*string{"GREETING": &greeting}, func() (map[string]string, error) {
+		t.Errorf("resolveRemoteEnv() read the container's environment without a containerEnv variable")
+		return nil, nil
+	}); err != nil {
+		t.Fatalf("resolveRemoteEnv() error = %v", err)
+	}
+}
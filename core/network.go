@@ -0,0 +1,85 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikeocool/tape/container"
+)
+
+const NetworkLabel = "tape.network" // used to label networks created by tape
+
+// EnsureNetwork creates the named network if it does not already exist
+func EnsureNetwork(name string) (*container.Network, error) {
+	cli, err := newBoxClient(BoxConfig{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	network, err := cli.FindNetwork(ctx, name)
+	if err == nil {
+		return network, nil
+	}
+	if !container.IsNetworkNotFound(err) {
+		return nil, err
+	}
+
+	return cli.CreateNetwork(ctx, name, map[string]string{NetworkLabel: name})
+}
+
+// ListNetworks returns the networks created by tape
+func ListNetworks() ([]container.Network, error) {
+	cli, err := newBoxClient(BoxConfig{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	return cli.ListNetworks(context.Background(), []string{NetworkLabel})
+}
+
+// RemoveNetwork removes a network, refusing to touch networks tape did not create
+func RemoveNetwork(name string) error {
+	cli, err := newBoxClient(BoxConfig{})
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	network, err := cli.FindNetwork(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := network.Labels[NetworkLabel]; !ok {
+		return fmt.Errorf("network %s was not created by tape", name)
+	}
+
+	return cli.RemoveNetwork(ctx, network.ID)
+}
+
+// NetworkMembers returns the names of the boxes configured to join the network
+func NetworkMembers(name string) ([]string, error) {
+	envs, err := ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	var members []string
+	for _, envName := range envs {
+		config, err := LoadBoxConfig(envName)
+		if err != nil {
+			continue
+		}
+		if config.Network == name {
+			members = append(members, envName)
+		}
+	}
+
+	return members, nil
+}
@@ -0,0 +1,206 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// DefaultNotifyAfter is how long an operation must take to be notified unless
+// configured otherwise, so starting a box that's already built stays quiet
+const DefaultNotifyAfter = 30 * time.Second
+
+// Notification operations besides the ones recorded in the history log
+const (
+	NotifyCrash = "crash"
+	NotifyJoin  = "join"
+)
+
+// Notification is sent when an operation finishes or a container crashes.
+// It's the JSON body of webhook requests.
+type Notification struct {
+	Time      time.Time `json:"time"`
+	EnvName   string    `json:"env"`
+	Operation string    `json:"operation"`
+	Outcome   string    `json:"outcome"`
+	Error     string    `json:"error,omitempty"`
+	// Duration is how long the operation took, in seconds
+	Duration float64 `json:"duration,omitempty"`
+	// ExitCode is the exit code of a crashed container
+	ExitCode int `json:"exit_code,omitempty"`
+	// Share is the share source of a teammate who joined
+	Share string `json:"share,omitempty"`
+}
+
+// Message returns a one line description of the notification
+func (n Notification) Message() string {
+	took := time.Duration(n.Duration * float64(time.Second)).Round(time.Second)
+	switch {
+	case n.Operation == NotifyCrash:
+		return fmt.Sprintf("%s crashed with exit code %d", n.EnvName, n.ExitCode)
+	case n.Operation == NotifyJoin:
+		return fmt.Sprintf("%s joined %s", n.Share, n.EnvName)
+	case n.Outcome == OutcomeError:
+		return fmt.Sprintf("tape %s %s failed after %s: %s", n.Operation, n.EnvName, took, n.Error)
+	default:
+		return fmt.Sprintf("tape %s %s finished in %s", n.Operation, n.EnvName, took)
+	}
+}
+
+// notifyOperation notifies that an operation finished, when notifications
+// are configured and it took long enough. Like the history log it's best
+// effort, failing to notify doesn't fail the operation.
+func notifyOperation(envName string, operation string, started time.Time, err error) {
+	globalConfig, configErr := LoadGlobalConfig()
+	if configErr != nil || !globalConfig.Notifications.Enabled() {
+		return
+	}
+	duration := time.Since(started)
+	if duration < globalConfig.Notifications.AfterDuration() {
+		return
+	}
+
+	notification := Notification{
+		Time:      time.Now(),
+		EnvName:   envName,
+		Operation: operation,
+		Outcome:   OutcomeOK,
+		Duration:  duration.Seconds(),
+	}
+	if err != nil {
+		notification.Outcome = OutcomeError
+		notification.Error = err.Error()
+	}
+	if err := Notify(globalConfig.Notifications, notification); err != nil {
+		fmt.Fprintf(Stderr, "Warning: error sending notification: %v\n", err)
+	}
+}
+
+// NotifyShareJoined records and notifies that a teammate the environment is shared
+// with connected to it. Like notifyOperation it's best effort.
+func NotifyShareJoined(envName string, source string) {
+	recordEvent(envName, NotifyJoin, source, nil)
+
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil || !globalConfig.Notifications.Enabled() {
+		return
+	}
+	notification := Notification{
+		Time:      time.Now(),
+		EnvName:   envName,
+		Operation: NotifyJoin,
+		Outcome:   OutcomeOK,
+		Share:     source,
+	}
+	if err := Notify(globalConfig.Notifications, notification); err != nil {
+		fmt.Fprintf(Stderr, "Warning: error sending notification: %v\n", err)
+	}
+}
+
+// Notify sends a notification to the desktop and the webhook, as configured
+func Notify(config NotificationsConfig, notification Notification) error {
+	var errs []error
+	if config.Desktop {
+		errs = append(errs, desktopNotification(notification.Message()))
+	}
+	if config.Webhook != "" {
+		errs = append(errs, postWebhook(config.Webhook, notification))
+	}
+	return errors.Join(errs...)
+}
+
+func desktopNotification(message string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %q with title %q", message, "tape")
+		cmd = exec.Command("osascript", "-e", script)
+	case "linux":
+		cmd = exec.Command("notify-send", "tape", message)
+	default:
+		return fmt.Errorf("desktop notifications aren't supported on %s", runtime.GOOS)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error showing desktop notification: %v: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+func postWebhook(url string, notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error posting to webhook: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error posting to webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// WatchCrashes notifies when a box's container on the default docker host
+// exits with an error without being stopped or killed, until ctx is cancelled
+func WatchCrashes(ctx context.Context) error {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	if !globalConfig.Notifications.Enabled() {
+		return nil
+	}
+
+	cli, err := newBackend(globalConfig, globalConfig.DockerHost)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	// docker stop and docker kill send a kill event before the container dies
+	killed := map[string]bool{}
+	return cli.ContainerEvents(ctx, []string{EnvLabel}, func(event container.Event) error {
+		notification, ok := crashNotification(killed, event)
+		if !ok {
+			return nil
+		}
+		if err := Notify(globalConfig.Notifications, notification); err != nil {
+			fmt.Fprintf(Stderr, "Warning: error sending notification: %v\n", err)
+		}
+		return nil
+	})
+}
+
+// crashNotification tracks the kill events in killed and returns the
+// notification for a die event that wasn't preceded by one
+func crashNotification(killed map[string]bool, event container.Event) (Notification, bool) {
+	switch event.Action {
+	case "kill":
+		killed[event.ContainerID] = true
+	case "die":
+		wasKilled := killed[event.ContainerID]
+		delete(killed, event.ContainerID)
+		if !wasKilled && event.ExitCode != 0 {
+			return Notification{
+				Time:      event.Time,
+				EnvName:   event.Labels[EnvLabel],
+				Operation: NotifyCrash,
+				Outcome:   OutcomeError,
+				ExitCode:  event.ExitCode,
+			}, true
+		}
+	}
+	return Notification{}, false
+}
@@ -0,0 +1,144 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestNotificationMessage(t *testing.T) {
+	tests := []struct {
+		name         string
+		notification Notification
+		expected     string
+	}{
+		{
+			name:         "finished",
+			notification: Notification{EnvName: "app", Operation: "up", Outcome: OutcomeOK, Duration: 192.4},
+			expected:     "tape up app finished in 3m12s",
+		},
+		{
+			name:         "failed",
+			notification: Notification{EnvName: "app", Operation: "upgrade", Outcome: OutcomeError, Error: "no space left on device", Duration: 45},
+			expected:     "tape upgrade app failed after 45s: no space left on device",
+		},
+		{
+			name:         "crash",
+			notification: Notification{EnvName: "app", Operation: NotifyCrash, Outcome: OutcomeError, ExitCode: 137},
+			expected:     "app crashed with exit code 137",
+		},
+		{
+			name:         "join",
+			notification: Notification{EnvName: "app", Operation: NotifyJoin, Outcome: OutcomeOK, Share: "github:alice"},
+			expected:     "github:alice joined app",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.notification.Message(); got != tt.expected {
+				t.Errorf("Message() = %q, expected %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCrashNotification(t *testing.T) {
+	labels := map[string]string{EnvLabel: "app"}
+	tests := []struct {
+		name     string
+		events   []container.Event
+		expected []string
+	}{
+		{
+			name:     "crash",
+			events:   []container.Event{{ContainerID: "a", Action: "die", Labels: labels, ExitCode: 1}},
+			expected: []string{"app crashed with exit code 1"},
+		},
+		{
+			name:   "clean exit",
+			events: []container.Event{{ContainerID: "a", Action: "die", Labels: labels}},
+		},
+		{
+			name: "stopped",
+			events: []container.Event{
+				{ContainerID: "a", Action: "kill", Labels: labels},
+				{ContainerID: "a", Action: "die", Labels: labels, ExitCode: 143},
+			},
+		},
+		{
+			name: "crash after being stopped",
+			events: []container.Event{
+				{ContainerID: "a", Action: "kill", Labels: labels},
+				{ContainerID: "a", Action: "die", Labels: labels, ExitCode: 143},
+				{ContainerID: "a", Action: "start", Labels: labels},
+				{ContainerID: "a", Action: "die", Labels: labels, ExitCode: 2},
+			},
+			expected: []string{"app crashed with exit code 2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			killed := map[string]bool{}
+			var got []string
+			for _, event := range tt.events {
+				if notification, ok := crashNotification(killed, event); ok {
+					got = append(got, notification.Message())
+				}
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("notifications = %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNotifyWebhook(t *testing.T) {
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("Content-Type = %q", r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Errorf("error decoding body: %v", err)
+		}
+		if received.EnvName == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	notification := Notification{
+		Time:      time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		EnvName:   "app",
+		Operation: "up",
+		Outcome:   OutcomeOK,
+		Duration:  90,
+	}
+	if err := Notify(NotificationsConfig{Webhook: server.URL}, notification); err != nil {
+		t.Fatalf("Notify() error = %v", err)
+	}
+	if !reflect.DeepEqual(received, notification) {
+		t.Errorf("received %+v, expected %+v", received, notification)
+	}
+
+	notification.EnvName = "broken"
+	if err := Notify(NotificationsConfig{Webhook: server.URL}, notification); err == nil {
+		t.Errorf("Notify() expected an error for a failing webhook")
+	}
+}
+
+func TestNotificationsAfterDuration(t *testing.T) {
+	if got := (NotificationsConfig{}).AfterDuration(); got != DefaultNotifyAfter {
+		t.Errorf("AfterDuration() = %v, expected %v", got, DefaultNotifyAfter)
+	}
+	if got := (NotificationsConfig{After: "2m"}).AfterDuration(); got != 2*time.Minute {
+		t.Errorf("AfterDuration() = %v, expected 2m", got)
+	}
+}
@@ -0,0 +1,482 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// UpOptions configures UpBox
+type UpOptions struct {
+	// Rebuild rebuilds the image without the cache and replaces the existing container
+	Rebuild bool
+	// Recreate replaces the existing container even if its config is unchanged
+	Recreate bool
+	// NoRecreate starts the existing container even if its config changed
+	NoRecreate bool
+	// Image was built from the box's config, e.g. by UpgradeBox, and is used
+	// instead of building it
+	Image string
+	// ConfigName chooses the workspace's .devcontainer/<name>/devcontainer.json
+	// when it has several configs
+	ConfigName string
+	// Untrusted sandboxes the box, as if it was configured untrusted
+	Untrusted bool
+	// Trust starts the box even if its workspace isn't trusted, see
+	// checkWorkspaceTrust
+	Trust bool
+	// DevcontainerArgs are passed to devcontainer up as they are, for the
+	// CLI's options tape doesn't know about
+	DevcontainerArgs []string
+	// upgrade is set when UpgradeBox replaces the container, which notifies
+	// once the whole upgrade finished
+	upgrade bool
+}
+
+// UpBox creates and starts the box's container with the devcontainer CLI,
+// or starts the existing one. A stopped container whose config changed since
+// it was created is replaced, unless opts says otherwise.
+func UpBox(envName string, opts UpOptions) (err error) {
+	var detail []string
+	started := time.Now()
+	defer func() {
+		forgetBoxState(envName)
+		recordEvent(envName, "up", strings.Join(detail, ", "), err)
+		if !opts.upgrade {
+			notifyOperation(envName, "up", started, err)
+		}
+	}()
+	switch {
+	case opts.Rebuild:
+		detail = append(detail, "rebuild")
+	case opts.Recreate:
+		detail = append(detail, "recreate")
+	}
+
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	config, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+	if err := selectDevcontainerConfig(config, opts.ConfigName); err != nil {
+		return err
+	}
+	if opts.Untrusted {
+		config.Untrusted = true
+	}
+
+	if err := checkContainerName(*config); err != nil {
+		return err
+	}
+	if err := checkGUI(*config); err != nil {
+		return err
+	}
+	if !config.Untrusted && !opts.Trust {
+		if err := checkWorkspaceTrust(*config); err != nil {
+			return err
+		}
+	}
+
+	if err := runHook(*config, HookPreUp); err != nil {
+		return err
+	}
+
+	// an untrusted box runs on its own network instead of the configured one
+	if config.Untrusted {
+		detail = append(detail, "untrusted")
+		if err := startEgressProxy(*config, *globalConfig); err != nil {
+			return err
+		}
+	} else if config.Network != "" {
+		if _, err := EnsureNetwork(config.Network); err != nil {
+			return fmt.Errorf("error creating network %s: %w", config.Network, err)
+		}
+	}
+
+	// the lifecycle commands run with the secrets, without them being stored in the container
+	secrets, err := resolveSecrets(*config)
+	if err != nil {
+		return err
+	}
+	var additionalArgs []string
+	if opts.Rebuild {
+		additionalArgs = append(additionalArgs, "--build-no-cache")
+	}
+
+	if globalConfig.DotfilesRepository != "" {
+		additionalArgs = append(additionalArgs,
+			"--dotfiles-repository", globalConfig.DotfilesRepository,
+		)
+	}
+
+	if config.WorkspaceMountConsistency != "" {
+		additionalArgs = append(additionalArgs, "--workspace-mount-consistency", config.WorkspaceMountConsistency)
+	}
+	additionalArgs = append(additionalArgs, opts.DevcontainerArgs...)
+
+	devCmd := DevcontainerCommand{
+		BoxConfig:      *config,
+		Command:        "up",
+		AdditionalArgs: additionalArgs,
+		Image:          config.PrebuiltImage,
+		Secrets:        secrets,
+	}
+	if opts.Image != "" {
+		devCmd.Image = opts.Image
+		devCmd.ImageFromConfig = true
+	}
+	devCmd.rootless = detectRootless(*config)
+
+	recreate := opts.Rebuild || opts.Recreate
+	var background []string
+	rootlessNote := ""
+	if config.Config != "" {
+		effective, err := devCmd.effectiveConfig()
+		if err != nil {
+			return err
+		}
+		if devCmd.rootless {
+			rootlessNote = rootlessAdvice(effective)
+		}
+
+		if !recreate && !opts.NoRecreate {
+			recreate, err = configChanged(*config, effective)
+			if err != nil {
+				return err
+			}
+			if recreate {
+				detail = append(detail, "recreate, config changed")
+			}
+		}
+
+		// return once the waitFor phase is done and run the rest in the background
+		background = phasesAfterWaitFor(effective)
+	}
+
+	if recreate {
+		devCmd.AdditionalArgs = append(devCmd.AdditionalArgs, "--remove-existing-container")
+	}
+	creating := config.Config != "" && createsContainer(*config, recreate)
+	if creating && rootlessNote != "" {
+		fmt.Fprintf(Stdout, "Warning: %s\n", rootlessNote)
+	}
+	lockChanged := false
+	if creating {
+		// lock before building, so the lock records what the build used. A
+		// lock that can't be resolved doesn't make the box any less usable.
+		lock, changed, err := resolveLock(*config)
+		if err != nil {
+			fmt.Fprintf(Stdout, "Warning: not locking %s: %v\n", envName, err)
+		} else {
+			devCmd.lock, lockChanged = lock, changed
+			if label := featureDigestsLabel(*config, lock); label != "" {
+				devCmd.Labels = map[string]string{FeatureDigestsLabel: label}
+			}
+		}
+	}
+	if len(background) > 0 {
+		devCmd.AdditionalArgs = append(devCmd.AdditionalArgs, "--skip-non-blocking-commands")
+	}
+
+	// the cookie can change between logins, it's refreshed whenever the box starts
+	if err := writeXauth(*config); err != nil {
+		return err
+	}
+
+	if err := devCmd.Execute(); err != nil {
+		return err
+	}
+
+	if lockChanged {
+		writeResolvedLock(*config, devCmd.lock)
+	}
+
+	if err := prepareCacheVolumes(*config); err != nil {
+		return err
+	}
+
+	if config.Sync {
+		if err := seedSyncVolume(envName); err != nil {
+			return fmt.Errorf("error copying the workspace into the sync volume: %w", err)
+		}
+	}
+
+	if len(background) > 0 {
+		if err := startBackgroundLifecycle(envName); err != nil {
+			return err
+		}
+		fmt.Fprintf(Stdout, "Running %s in the background, see tape logs --lifecycle %s\n",
+			strings.Join(background, ", "), envName)
+	}
+	return runHook(*config, HookPostUp)
+}
+
+// createsContainer reports whether up creates a container for the box,
+// rather than starting the existing one
+func createsContainer(boxConfig BoxConfig, recreate bool) bool {
+	if recreate {
+		return true
+	}
+	_, err := FindDevContainer(boxConfig)
+	return container.IsContainerNotFound(err)
+}
+
+// configChanged reports whether the box's stopped container has to be
+// recreated to apply the effective config. Running containers are left alone
+// since recreating them would interrupt whatever runs in them.
+func configChanged(boxConfig BoxConfig, effective *devcontainer.DevContainerConfig) (bool, error) {
+	dc, err := FindDevContainer(boxConfig)
+	if container.IsContainerNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if !hashChanged(dc.Labels, effective) {
+		return false, nil
+	}
+	if dc.State == container.StateRunning {
+		fmt.Fprintf(Stdout, "The config of %s changed since its container was created, run tape up --recreate to apply it\n", boxConfig.Name)
+		return false, nil
+	}
+	fmt.Fprintf(Stdout, "The config of %s changed since its container was created, recreating it\n", boxConfig.Name)
+	return true, nil
+}
+
+// hashChanged reports whether a container's config hash label differs from
+// the effective config's. Containers created without the label, e.g. by an
+// older tape or adopted ones, are assumed to be up to date.
+func hashChanged(labels map[string]string, effective *devcontainer.DevContainerConfig) bool {
+	current, ok := labels[ConfigHashLabel]
+	if !ok {
+		return false
+	}
+	return current != runArgLabel(effective.RunArgs, ConfigHashLabel)
+}
+
+// StopBox stops the box's container, running its stop hooks around it
+func StopBox(envName string) error {
+	return stopBox(envName, "stop", "")
+}
+
+// stopBox stops the box's container, recording it in the history as operation
+func stopBox(envName string, operation string, detail string) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+	if err := runHook(*boxConfig, HookPreStop); err != nil {
+		recordEvent(envName, operation, detail, err)
+		return err
+	}
+
+	err = withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		removePortProxies(ctx, cli, envName)
+		removeEgressProxy(ctx, cli, envName)
+		return cli.StopContainer(ctx, dc.ID)
+	})
+	recordEvent(envName, operation, detail, err)
+	if err != nil {
+		return err
+	}
+	return runHook(*boxConfig, HookPostStop)
+}
+
+// RemoveBox removes the box's container
+func RemoveBox(envName string) error {
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		removePortProxies(ctx, cli, envName)
+		removeEgressProxy(ctx, cli, envName)
+		if err := cli.RemoveContainer(ctx, dc.ID); err != nil {
+			return err
+		}
+		if boxConfig, err := LoadBoxConfig(envName); err == nil {
+			removeUntrustedNetwork(ctx, cli, *boxConfig)
+		}
+		return nil
+	})
+	recordEvent(envName, "rm", "", err)
+	return err
+}
+
+// PauseBox freezes the processes in the box's container
+func PauseBox(envName string) error {
+	return withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+		return cli.PauseContainer(ctx, dc.ID)
+	})
+}
+
+// ResumeBox unfreezes the processes in the box's container
+func ResumeBox(envName string) error {
+	return withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		return cli.UnpauseContainer(ctx, dc.ID)
+	})
+}
+
+// BoxUptime returns how long the box's container has been running
+func BoxUptime(envName string) (time.Duration, error) {
+	var uptime time.Duration
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+		inspect, err := cli.InspectContainer(ctx, dc.ID)
+		if err != nil {
+			return fmt.Errorf("error inspecting container: %v", err)
+		}
+		uptime = time.Since(inspect.StartedAt)
+		return nil
+	})
+	return uptime, err
+}
+
+// BoxProcesses lists the processes running in the box's container. psArgs
+// are passed to ps, e.g. aux.
+func BoxProcesses(envName string, psArgs []string) (*container.Processes, error) {
+	var processes *container.Processes
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+		var err error
+		processes, err = cli.ContainerTop(ctx, dc.ID, psArgs)
+		return err
+	})
+	return processes, err
+}
+
+// DiffIgnore are paths whose changes tape diff hides unless asked for all
+// of them: scratch space and caches rather than state worth keeping
+var DiffIgnore = []string{"/tmp", "/var/tmp", "/var/cache", "/var/log", "/run"}
+
+// BoxChanges lists the files changed in the box's container since it was
+// created, limited to the given paths when there are any, and skipping the
+// ignored paths
+func BoxChanges(envName string, paths []string, ignore []string) ([]container.Change, error) {
+	var changes []container.Change
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		var err error
+		changes, err = cli.ContainerDiff(ctx, dc.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return filterChanges(changes, paths, ignore), nil
+}
+
+func filterChanges(changes []container.Change, paths []string, ignore []string) []container.Change {
+	var filtered []container.Change
+	for _, change := range changes {
+		if len(paths) > 0 && !slices.ContainsFunc(paths, func(path string) bool { return pathWithin(change.Path, path) }) {
+			continue
+		}
+		if slices.ContainsFunc(ignore, func(path string) bool { return pathWithin(change.Path, path) }) {
+			continue
+		}
+		filtered = append(filtered, change)
+	}
+	return filtered
+}
+
+// pathWithin reports whether path is dir or inside it
+func pathWithin(path string, dir string) bool {
+	dir = strings.TrimSuffix(dir, "/")
+	return path == dir || strings.HasPrefix(path, dir+"/")
+}
+
+// BoxRestarts is how docker supervises a box's container, see BoxConfig.Restart
+type BoxRestarts struct {
+	Policy string
+	// Count is how often the container was restarted since it was last started by tape
+	Count int
+	// ExitCode is the exit code of the container's last run
+	ExitCode int
+}
+
+// GetBoxRestarts returns the restart policy, restart count and last exit code
+// of the box's container
+func GetBoxRestarts(envName string) (*BoxRestarts, error) {
+	var restarts *BoxRestarts
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		inspect, err := cli.InspectContainer(ctx, dc.ID)
+		if err != nil {
+			return fmt.Errorf("error inspecting container: %v", err)
+		}
+		restarts = &BoxRestarts{Policy: inspect.RestartPolicy, Count: inspect.RestartCount, ExitCode: inspect.ExitCode}
+		return nil
+	})
+	return restarts, err
+}
+
+// RequireRunning returns an error wrapping ErrNotRunning unless the box's container is running
+func RequireRunning(envName string) error {
+	return withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		return requireRunning(envName, dc)
+	})
+}
+
+func requireRunning(envName string, dc *container.Container) error {
+	if dc.State != container.StateRunning {
+		return fmt.Errorf("%w: %s is %s", ErrNotRunning, envName, boxStateFromContainerState(dc.State))
+	}
+	return nil
+}
+
+// withBoxContainer finds the box's container and runs fn with a client for the box's docker host
+func withBoxContainer(envName string, fn func(context.Context, container.Backend, *container.Container) error) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return err
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+	defer forgetBoxState(envName)
+
+	return fn(context.Background(), cli, dc)
+}
+
+// BoxLogs writes the output of the box's container to stdout and stderr. With
+// follow, new output is written until the container stops or ctx is cancelled.
+func BoxLogs(ctx context.Context, envName string, follow bool, stdout, stderr io.Writer) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return err
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	return cli.ContainerLogs(ctx, dc.ID, follow, stdout, stderr)
+}
@@ -0,0 +1,53 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestFilterChanges(t *testing.T) {
+	changes := []container.Change{
+		{Kind: container.ChangeModified, Path: "/etc"},
+		{Kind: container.ChangeAdded, Path: "/etc/profile.d/extra.sh"},
+		{Kind: container.ChangeModified, Path: "/tmp"},
+		{Kind: container.ChangeAdded, Path: "/tmp/build.lock"},
+		{Kind: container.ChangeAdded, Path: "/tmpfiles"},
+		{Kind: container.ChangeAdded, Path: "/usr/local/bin/tool"},
+	}
+
+	tests := []struct {
+		name     string
+		paths    []string
+		ignore   []string
+		expected []string
+	}{
+		{
+			name:     "all",
+			expected: []string{"/etc", "/etc/profile.d/extra.sh", "/tmp", "/tmp/build.lock", "/tmpfiles", "/usr/local/bin/tool"},
+		},
+		{
+			name:     "ignored",
+			ignore:   []string{"/tmp"},
+			expected: []string{"/etc", "/etc/profile.d/extra.sh", "/tmpfiles", "/usr/local/bin/tool"},
+		},
+		{
+			name:     "paths",
+			paths:    []string{"/etc/", "/usr/local"},
+			expected: []string{"/etc", "/etc/profile.d/extra.sh", "/usr/local/bin/tool"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range filterChanges(changes, tt.paths, tt.ignore) {
+				got = append(got, change.Path)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("filterChanges() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,16 @@
+package core
+
+import (
+	"io"
+	"os"
+)
+
+// Stdout and Stderr receive core's progress messages and warnings, and the
+// output of the commands it runs on the host, like the devcontainer CLI and
+// hooks. Programs embedding core can replace them, e.g. with io.Discard.
+// Commands attached to the terminal, ExecInBox's and the devcontainer CLI's
+// when it runs in a container, still use the process's own.
+var (
+	Stdout io.Writer = os.Stdout
+	Stderr io.Writer = os.Stderr
+)
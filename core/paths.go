@@ -0,0 +1,34 @@
+package core
+
+import (
+	"runtime"
+	"strings"
+)
+
+// DockerPath converts a host path to the form docker and the devcontainer CLI
+// use for it. On Windows, drive letters become a leading directory the way
+// Docker Desktop expects, e.g. C:\Users\me becomes /c/Users/me. Paths on other
+// platforms are returned unchanged.
+func DockerPath(hostPath string) string {
+	return dockerPath(hostPath, runtime.GOOS == "windows")
+}
+
+func dockerPath(hostPath string, windows bool) string {
+	if !windows {
+		return hostPath
+	}
+
+	p := strings.ReplaceAll(hostPath, `\`, "/")
+	if len(p) >= 2 && p[1] == ':' && isDriveLetter(p[0]) {
+		rest := strings.TrimPrefix(p[2:], "/")
+		p = "/" + strings.ToLower(p[:1])
+		if rest != "" {
+			p += "/" + rest
+		}
+	}
+	return p
+}
+
+func isDriveLetter(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
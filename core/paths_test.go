@@ -0,0 +1,60 @@
+package core
+
+import "testing"
+
+func TestDockerPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		windows  bool
+		expected string
+	}{
+		{`/home/me/src`, false, `/home/me/src`},
+		{`C:\Users\me\src`, true, `/c/Users/me/src`},
+		{`d:\work`, true, `/d/work`},
+		{`C:\`, true, `/c`},
+		{`\\wsl$\Ubuntu\home\me`, true, `//wsl$/Ubuntu/home/me`},
+		{`src\app`, true, `src/app`},
+	}
+
+	for _, tt := range tests {
+		if got := dockerPath(tt.path, tt.windows); got != tt.expected {
+			t.Errorf("dockerPath(%q, %v) = %q, want %q", tt.path, tt.windows, got, tt.expected)
+		}
+	}
+}
+
+func TestWSLPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{`\\wsl$\Ubuntu\home\me\src`, `/home/me/src`},
+		{`\\wsl.localhost\Ubuntu-22.04\home\me`, `/home/me`},
+		{`//wsl$/Ubuntu/home/me`, `/home/me`},
+		{`C:\Users\me\src`, `/mnt/c/Users/me/src`},
+		{`D:\`, `/mnt/d`},
+		{`/home/me/src`, `/home/me/src`},
+		{`src/app`, `src/app`},
+	}
+
+	for _, tt := range tests {
+		if got := wslPath(tt.path); got != tt.expected {
+			t.Errorf("wslPath(%q) = %q, want %q", tt.path, got, tt.expected)
+		}
+	}
+}
+
+func TestOnWindowsDrive(t *testing.T) {
+	tests := map[string]bool{
+		"/mnt/c":            true,
+		"/mnt/c/Users/me":   true,
+		"/mnt/wsl/shared":   false,
+		"/home/me/src":      false,
+		"/mnt/data/project": false,
+	}
+	for path, expected := range tests {
+		if got := onWindowsDrive(path); got != expected {
+			t.Errorf("onWindowsDrive(%q) = %v, want %v", path, got, expected)
+		}
+	}
+}
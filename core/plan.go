@@ -0,0 +1,233 @@
+package core
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// UpPlan is what tape up would do for a box, see PlanUp
+type UpPlan struct {
+	EnvName string
+	// Strategy runs the devcontainer CLI, see ExecutionStrategyContainer
+	Strategy string
+	// CLIImage is the image the CLI runs in with the container strategy
+	CLIImage string
+	// HostPaths are the host directories the CLI reads, mounted into its
+	// container with the container strategy
+	HostPaths []string
+	// Command is the devcontainer CLI's argv, or the docker run that creates
+	// the container with the native strategy
+	Command []string
+	// Images are pulled before the CLI runs, the config's image or the base
+	// images of its Dockerfile
+	Images []string
+	// Dockerfile and Context are set when the CLI builds the image
+	Dockerfile string
+	Context    string
+	Features   []string
+	RunArgs    []string
+	// WorkspaceMount and Mounts are in docker --mount syntax
+	WorkspaceMount string
+	Mounts         []string
+	ContainerEnv   map[string]string
+	RemoteEnv      map[string]string
+	// Secrets are the names of the variables resolved from the box's secrets
+	Secrets   []string
+	Lifecycle []PlannedCommand
+}
+
+// PlannedCommand is a lifecycle command up would run
+type PlannedCommand struct {
+	Phase string
+	// Commands are shell command lines, several for the object form, which
+	// runs them in parallel
+	Commands []string
+	// OnHost is set for initializeCommand, which runs on the host
+	OnHost bool
+	// Background is set for the phases after waitFor, which up doesn't wait for
+	Background bool
+}
+
+// PlanUp returns what UpBox would do with opts without running anything or
+// connecting to docker, so whether an existing container is reused can't be
+// known: the plan is for creating the container. Secrets aren't resolved,
+// and hooks aren't run. When the box violates a policy, the plan is returned
+// with the PolicyError.
+func PlanUp(envName string, opts UpOptions) (*UpPlan, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	if err := selectDevcontainerConfig(boxConfig, opts.ConfigName); err != nil {
+		return nil, err
+	}
+	if opts.Untrusted {
+		boxConfig.Untrusted = true
+	}
+
+	plan := &UpPlan{EnvName: envName, Strategy: globalConfig.ExecutionStrategy}
+	if plan.Strategy == "" {
+		plan.Strategy = ExecutionStrategyContainer
+	}
+
+	// the same arguments as UpBox, the secrets are only resolved into their file
+	plan.Secrets = slices.Sorted(maps.Keys(boxConfig.Secrets))
+	var additionalArgs []string
+	if opts.Rebuild {
+		additionalArgs = append(additionalArgs, "--build-no-cache")
+	}
+	if globalConfig.DotfilesRepository != "" {
+		additionalArgs = append(additionalArgs, "--dotfiles-repository", globalConfig.DotfilesRepository)
+	}
+	if boxConfig.WorkspaceMountConsistency != "" {
+		additionalArgs = append(additionalArgs, "--workspace-mount-consistency", boxConfig.WorkspaceMountConsistency)
+	}
+	additionalArgs = append(additionalArgs, opts.DevcontainerArgs...)
+	if opts.Rebuild || opts.Recreate {
+		additionalArgs = append(additionalArgs, "--remove-existing-container")
+	}
+	devCmd := DevcontainerCommand{
+		BoxConfig:      *boxConfig,
+		Command:        "up",
+		AdditionalArgs: additionalArgs,
+		Image:          boxConfig.PrebuiltImage,
+	}
+	if opts.Image != "" {
+		devCmd.Image = opts.Image
+		devCmd.ImageFromConfig = true
+	}
+
+	hostPaths := []string{boxConfig.Workspace}
+	configPath := ""
+	var (
+		policyErr error
+		effective *devcontainer.DevContainerConfig
+	)
+	if boxConfig.Config != "" {
+		config, err := devCmd.effectiveConfig()
+		if err != nil {
+			return nil, err
+		}
+		effective = config
+		configDir := filepath.Dir(boxConfig.Config)
+		hostPaths = append(hostPaths, configDir)
+		hostPaths = append(hostPaths, resolveBuildPaths(config, configDir)...)
+		policyErr = enforcePolicies(*boxConfig, config)
+
+		plan.Images = configImages(config)
+		if config.Build != nil {
+			plan.Dockerfile, plan.Context = config.Build.Dockerfile, config.Build.Context
+		} else {
+			plan.Dockerfile, plan.Context = config.DockerFile, config.Context
+		}
+		plan.Features = slices.Sorted(maps.Keys(config.Features))
+		plan.RunArgs = config.RunArgs
+		plan.WorkspaceMount = config.WorkspaceMount
+		for _, mount := range config.Mounts {
+			spec, err := mount.DockerMountSpec()
+			if err != nil {
+				return nil, err
+			}
+			plan.Mounts = append(plan.Mounts, spec)
+		}
+		plan.ContainerEnv = config.ContainerEnv
+		plan.RemoteEnv = map[string]string{}
+		for name, value := range config.RemoteEnv {
+			if value != nil {
+				plan.RemoteEnv[name] = *value
+			}
+		}
+
+		if config.InitializeCommand != nil {
+			commands, err := hostCommands(config.InitializeCommand)
+			if err != nil {
+				return nil, fmt.Errorf("invalid initializeCommand: %v", err)
+			}
+			plan.Lifecycle = append(plan.Lifecycle, PlannedCommand{Phase: "initializeCommand", Commands: shellJoinAll(commands), OnHost: true})
+		}
+		background := phasesAfterWaitFor(config)
+		for _, phase := range lifecyclePhases {
+			command := lifecycleCommand(config, phase)
+			if command == nil {
+				continue
+			}
+			commands, err := hostCommands(command)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %v", phase, err)
+			}
+			plan.Lifecycle = append(plan.Lifecycle, PlannedCommand{Phase: phase, Commands: shellJoinAll(commands), Background: slices.Contains(background, phase)})
+		}
+		if len(background) > 0 {
+			devCmd.AdditionalArgs = append(devCmd.AdditionalArgs, "--skip-non-blocking-commands")
+		}
+		devCmd.AdditionalArgs = append(devCmd.AdditionalArgs, buildCacheArgs(buildCache(*boxConfig, config, globalConfig))...)
+		configPath = "<effective config>"
+	} else {
+		policyErr = enforcePolicies(*boxConfig, nil)
+	}
+
+	secretsPath := ""
+	switch plan.Strategy {
+	case ExecutionStrategyNative:
+		if effective == nil {
+			return nil, fmt.Errorf("the %s execution strategy needs a devcontainer config", ExecutionStrategyNative)
+		}
+		if err := nativeSupported(effective); err != nil {
+			return nil, err
+		}
+		config, err := substituteVariables(effective, *boxConfig)
+		if err != nil {
+			return nil, err
+		}
+		image := config.Image
+		if plan.Dockerfile != "" {
+			image = nativeImageName(*boxConfig)
+		}
+		args, err := nativeRunArgs(*boxConfig, config, image, boxConfig.WorkspaceMountConsistency)
+		if err != nil {
+			return nil, err
+		}
+		docker := "docker"
+		if globalConfig.Runtime == RuntimeContainerd {
+			docker = "nerdctl"
+		}
+		plan.Command = append([]string{docker}, args...)
+	case ExecutionStrategyLocalBinary:
+		if len(plan.Secrets) > 0 {
+			secretsPath = "<secrets file>"
+		}
+		plan.Command = buildDevcontainerArgs(devCmd.Command, boxConfig.Workspace, configPath, secretsFileArgs(secretsPath, devCmd.AdditionalArgs))
+	default:
+		plan.CLIImage = devcontainerCliImage(globalConfig)
+		if configPath != "" {
+			configPath = "/tmp/devcontainer.json"
+		}
+		if len(plan.Secrets) > 0 {
+			secretsPath = secretsContainerPath
+		}
+		plan.Command = buildDevcontainerArgs(devCmd.Command, DockerPath(boxConfig.Workspace), configPath, secretsFileArgs(secretsPath, devCmd.AdditionalArgs))
+	}
+	plan.HostPaths = minimalMounts(hostPaths)
+	return plan, policyErr
+}
+
+func shellJoinAll(commands [][]string) []string {
+	lines := make([]string, len(commands))
+	for i, args := range commands {
+		lines[i] = devcontainer.ShellJoin(args)
+	}
+	return lines
+}
+
+// CommandLine returns the devcontainer CLI command as a shell command line
+func (p UpPlan) CommandLine() string {
+	return devcontainer.ShellJoin(p.Command)
+}
@@ -0,0 +1,62 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPlanUp(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"app.yml": "workspace: app\nenv:\n  FOO: bar\nsecrets:\n  TOKEN: env://TAPE_TEST_TOKEN\n",
+		"app/.devcontainer/devcontainer.json": `{
+			"image": "ubuntu:22.04",
+			"initializeCommand": "echo init",
+			"postCreateCommand": ["npm", "install"],
+			"postStartCommand": "npm run dev > /tmp/dev.log"
+		}`,
+	})
+
+	plan, err := PlanUp("app", UpOptions{Recreate: true})
+	if err != nil {
+		t.Fatalf("PlanUp() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(plan.Images, []string{"ubuntu:22.04"}) {
+		t.Errorf("PlanUp().Images = %v, want [ubuntu:22.04]", plan.Images)
+	}
+	if plan.ContainerEnv["FOO"] != "bar" {
+		t.Errorf("PlanUp().ContainerEnv = %v, want FOO=bar", plan.ContainerEnv)
+	}
+	command := plan.CommandLine()
+	for _, want := range []string{"devcontainer up --workspace-folder", "--config /tmp/devcontainer.json", "--secrets-file /tmp/secrets.json", "--remove-existing-container", "--skip-non-blocking-commands"} {
+		if !strings.Contains(command, want) {
+			t.Errorf("PlanUp().CommandLine() = %s, want it to contain %s", command, want)
+		}
+	}
+
+	expected := []PlannedCommand{
+		{Phase: "initializeCommand", Commands: []string{"/bin/sh -c 'echo init'"}, OnHost: true},
+		{Phase: "postCreateCommand", Commands: []string{"npm install"}, Background: true},
+		{Phase: "postStartCommand", Commands: []string{"/bin/sh -c 'npm run dev > /tmp/dev.log'"}, Background: true},
+	}
+	if !reflect.DeepEqual(plan.Lifecycle, expected) {
+		t.Errorf("PlanUp().Lifecycle = %+v, want %+v", plan.Lifecycle, expected)
+	}
+}
+
+func TestPlanUpPolicyViolation(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"policy.yml":                          "allowed-images: [mcr.microsoft.com/*]\n",
+		"app.yml":                             "workspace: app\n",
+		"app/.devcontainer/devcontainer.json": `{"image": "ubuntu"}`,
+	})
+
+	plan, err := PlanUp("app", UpOptions{})
+	if err == nil || !strings.Contains(err.Error(), "image ubuntu is not allowed") {
+		t.Errorf("PlanUp() error = %v, want a policy violation", err)
+	}
+	if plan == nil {
+		t.Errorf("PlanUp() = nil, want the plan with the violation")
+	}
+}
@@ -0,0 +1,296 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// Policy restricts what environments may do. Organisations distribute it as
+// policy.yml in the ConfigDir, or serve it from GlobalConfig.PolicyURL, and
+// tape enforces it whenever a box is started or built.
+type Policy struct {
+	// AllowedImages are patterns of the base images boxes may use, where *
+	// matches anything, e.g. mcr.microsoft.com/devcontainers/*. Any image is
+	// allowed when it's empty.
+	AllowedImages []string `yaml:"allowed-images,omitempty" validate:"dive,required"`
+	// DeniedRunArgs are docker run flags boxes may not use, either just the
+	// flag, e.g. --privileged, or with a value, e.g. --network=host. They're
+	// also checked against the privileged, capAdd and securityOpt in the
+	// metadata of the box's features.
+	DeniedRunArgs []string `yaml:"denied-run-args,omitempty" validate:"dive,startswith=-"`
+	// RequiredLimits are the resource limits every box has to set
+	RequiredLimits []string `yaml:"required-limits,omitempty" validate:"dive,oneof=cpus memory"`
+}
+
+// PolicyError lists the ways a box violates a policy
+type PolicyError struct {
+	EnvName string
+	// Source is the file or URL the policy came from
+	Source     string
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s violates the policy in %s:\n  %s", e.EnvName, e.Source, strings.Join(e.Violations, "\n  "))
+}
+
+func (e *PolicyError) Unwrap() error {
+	return ErrPolicyViolation
+}
+
+// PolicyPath returns the path of the local policy file
+func PolicyPath() string {
+	return filepath.Join(ConfigDir, "policy.yml")
+}
+
+// policyCachePath returns where the policy fetched from GlobalConfig.PolicyURL
+// is kept, for when it can't be fetched
+func policyCachePath() string {
+	return filepath.Join(ConfigDir, "cache", "policy.yml")
+}
+
+var policyClient = &http.Client{Timeout: 10 * time.Second}
+
+// sourcedPolicy is a policy and where it came from
+type sourcedPolicy struct {
+	source string
+	policy Policy
+}
+
+// loadPolicies loads the local policy file and the policy at policyURL, those
+// that exist. A policy that can't be fetched is read from the copy cached
+// when it last was, and boxes can't be started without either.
+func loadPolicies(policyURL string) ([]sourcedPolicy, error) {
+	var policies []sourcedPolicy
+
+	data, err := os.ReadFile(PolicyPath())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("error reading policy %s: %v", PolicyPath(), err)
+	}
+	if err == nil {
+		policy, err := parsePolicy(data, PolicyPath())
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, sourcedPolicy{PolicyPath(), *policy})
+	}
+
+	if policyURL != "" {
+		data, err := fetchPolicy(policyURL)
+		if err != nil {
+			cached, cacheErr := os.ReadFile(policyCachePath())
+			if cacheErr != nil {
+				return nil, fmt.Errorf("error fetching policy: %w", err)
+			}
+			fmt.Fprintf(Stderr, "Warning: using the cached policy, %v\n", err)
+			data = cached
+		}
+		policy, err := parsePolicy(data, policyURL)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, sourcedPolicy{policyURL, *policy})
+	}
+	return policies, nil
+}
+
+// fetchPolicy downloads the policy at url and caches it
+func fetchPolicy(url string) ([]byte, error) {
+	if Offline {
+		return nil, ErrOffline
+	}
+	resp, err := policyClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cache is a fallback, failing to write it only loses that
+	if err := os.MkdirAll(filepath.Dir(policyCachePath()), 0755); err == nil {
+		os.WriteFile(policyCachePath(), data, 0644)
+	}
+	return data, nil
+}
+
+func parsePolicy(data []byte, source string) (*Policy, error) {
+	var policy Policy
+	if err := unmarshalStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("error parsing policy %s: %v", source, err)
+	}
+	if err := validator.New().Struct(policy); err != nil {
+		return nil, fmt.Errorf("policy %s is invalid: %v", source, err)
+	}
+	return &policy, nil
+}
+
+// enforcePolicies returns a PolicyError when the box, with its effective
+// devcontainer config, violates one of the configured policies
+func enforcePolicies(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) error {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	policies, err := loadPolicies(globalConfig.PolicyURL)
+	if err != nil {
+		return err
+	}
+	if boxConfig.Hardened {
+		policies = append(policies, sourcedPolicy{hardenedPolicySource, hardenedPolicy})
+	}
+	if boxConfig.Untrusted {
+		policies = append(policies, sourcedPolicy{untrustedPolicySource, untrustedPolicy})
+	}
+
+	// features can run the container privileged too, which only matters
+	// when a policy denies run args
+	var featureArgs map[string][]string
+	if slices.ContainsFunc(policies, func(p sourcedPolicy) bool { return len(p.policy.DeniedRunArgs) > 0 }) {
+		featureArgs, err = featureRunArgs(boxConfig, config)
+		if err != nil {
+			return fmt.Errorf("error checking features against the policy: %w", err)
+		}
+	}
+
+	var errs []error
+	for _, p := range policies {
+		if violations := p.policy.violations(boxConfig, config, featureArgs); len(violations) > 0 {
+			errs = append(errs, &PolicyError{EnvName: boxConfig.Name, Source: p.source, Violations: violations})
+		}
+	}
+	// the sandbox strips the config's host binds, but not the features', and
+	// doesn't reach compose services
+	if boxConfig.Untrusted {
+		if violations := untrustedViolations(boxConfig, config, featureArgs); len(violations) > 0 {
+			errs = append(errs, &PolicyError{EnvName: boxConfig.Name, Source: untrustedPolicySource, Violations: violations})
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// featureRunArgs returns the docker run flags the metadata of each of the
+// config's features adds, by feature
+func featureRunArgs(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) (map[string][]string, error) {
+	if config == nil {
+		return nil, nil
+	}
+	features, err := config.FeatureList()
+	if err != nil {
+		return nil, err
+	}
+	registry := newRegistryClient()
+	args := map[string][]string{}
+	for _, feature := range features {
+		metadata, err := featureMetadata(registry, feature.Ref, filepath.Dir(boxConfig.Config))
+		if err != nil {
+			return nil, err
+		}
+		if featureArgs := metadata.RunArgs(); len(featureArgs) > 0 {
+			args[feature.Ref.Raw] = featureArgs
+		}
+	}
+	return args, nil
+}
+
+// violations describes each way the box breaks the policy. featureArgs are
+// the run flags the box's features add, see featureRunArgs.
+func (p Policy) violations(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, featureArgs map[string][]string) []string {
+	var violations []string
+
+	if len(p.AllowedImages) > 0 {
+		for _, image := range upgradeImages(boxConfig, config) {
+			if !slices.ContainsFunc(p.AllowedImages, func(pattern string) bool { return imageAllowed(pattern, image) }) {
+				violations = append(violations, fmt.Sprintf("image %s is not allowed, allowed images are %s", image, strings.Join(p.AllowedImages, ", ")))
+			}
+		}
+	}
+
+	// boxes without a devcontainer config only have tape's resource limits
+	var runArgs []string
+	if config != nil {
+		runArgs = runArgForms(config.RunArgs)
+	} else {
+		if boxConfig.Resources.CPUs != "" {
+			runArgs = append(runArgs, "--cpus")
+		}
+		if boxConfig.Resources.Memory != "" {
+			runArgs = append(runArgs, "--memory")
+		}
+	}
+	for _, denied := range p.DeniedRunArgs {
+		if slices.Contains(runArgs, denied) {
+			violations = append(violations, fmt.Sprintf("runArgs %s is not allowed", denied))
+		}
+	}
+	for _, feature := range slices.Sorted(maps.Keys(featureArgs)) {
+		for _, denied := range p.DeniedRunArgs {
+			if slices.Contains(featureArgs[feature], denied) {
+				violations = append(violations, fmt.Sprintf("feature %s uses %s, which is not allowed", feature, denied))
+			}
+		}
+	}
+
+	for _, limit := range p.RequiredLimits {
+		if !slices.Contains(runArgs, "--"+limit) {
+			violations = append(violations, fmt.Sprintf("a %s limit is required, set resources.%s in the box config", limit, limit))
+		}
+	}
+	return violations
+}
+
+// runArgForms returns each flag in args on its own and with its value, as
+// --flag=value, whether it's given as one argument or two
+func runArgForms(args []string) []string {
+	var forms []string
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if flag, _, found := strings.Cut(arg, "="); found {
+			forms = append(forms, flag, arg)
+			continue
+		}
+		forms = append(forms, arg)
+		if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			forms = append(forms, arg+"="+args[i+1])
+		}
+	}
+	return forms
+}
+
+// imageAllowed reports whether image matches pattern, as written or without
+// its tag or digest, or fully qualified like docker.io/library/ubuntu
+func imageAllowed(pattern string, image string) bool {
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false
+	}
+
+	ref := container.ParseImageRef(image)
+	written, _, _ := strings.Cut(image, "@")
+	if ref.Tag != "" {
+		written = strings.TrimSuffix(written, ":"+ref.Tag)
+	}
+	candidates := []string{image, written, ref.Name()}
+	return slices.ContainsFunc(candidates, re.MatchString)
+}
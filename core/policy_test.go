@@ -0,0 +1,184 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestPolicyViolations(t *testing.T) {
+	policy := Policy{
+		AllowedImages:  []string{"mcr.microsoft.com/devcontainers/*", "ubuntu"},
+		DeniedRunArgs:  []string{"--privileged", "--network=host"},
+		RequiredLimits: []string{"memory"},
+	}
+
+	tests := []struct {
+		name        string
+		boxConfig   BoxConfig
+		config      *devcontainer.DevContainerConfig
+		featureArgs map[string][]string
+		expected    []string
+	}{
+		{
+			name:   "allowed",
+			config: &devcontainer.DevContainerConfig{Image: "mcr.microsoft.com/devcontainers/go:1", RunArgs: []string{"--memory", "4g", "--network", "shared"}},
+		},
+		{
+			name:   "docker hub image with a tag",
+			config: &devcontainer.DevContainerConfig{Image: "ubuntu:24.04", RunArgs: []string{"--memory=4g"}},
+		},
+		{
+			name:   "violations",
+			config: &devcontainer.DevContainerConfig{Image: "ghcr.io/acme/app", RunArgs: []string{"--privileged", "--network", "host"}},
+			expected: []string{
+				"image ghcr.io/acme/app is not allowed, allowed images are mcr.microsoft.com/devcontainers/*, ubuntu",
+				"runArgs --privileged is not allowed",
+				"runArgs --network=host is not allowed",
+				"a memory limit is required, set resources.memory in the box config",
+			},
+		},
+		{
+			name:        "privileged feature",
+			config:      &devcontainer.DevContainerConfig{Image: "ubuntu", RunArgs: []string{"--memory=4g"}},
+			featureArgs: map[string][]string{"ghcr.io/devcontainers/features/docker-in-docker:2": {"--privileged"}},
+			expected:    []string{"feature ghcr.io/devcontainers/features/docker-in-docker:2 uses --privileged, which is not allowed"},
+		},
+		{
+			name:      "prebuilt image without a config",
+			boxConfig: BoxConfig{PrebuiltImage: "debian", Resources: BoxResources{Memory: "2g"}},
+			expected:  []string{"image debian is not allowed, allowed images are mcr.microsoft.com/devcontainers/*, ubuntu"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := policy.violations(tt.boxConfig, tt.config, tt.featureArgs)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("violations() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEnforcePoliciesPrivilegedFeature(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"policy.yml": "denied-run-args: [--privileged, --cap-add=SYS_ADMIN]\n",
+		"app/.devcontainer/dind/devcontainer-feature.json": `{"id": "dind", "privileged": true, "capAdd": ["SYS_ADMIN"], "securityOpt": ["seccomp=unconfined"]}`,
+	})
+	boxConfig := BoxConfig{Name: "app", Config: filepath.Join(ConfigDir, "app", ".devcontainer", "devcontainer.json")}
+	config := &devcontainer.DevContainerConfig{Image: "ubuntu", Features: map[string]interface{}{"./dind": map[string]interface{}{}}}
+
+	err := enforcePolicies(boxConfig, config)
+	var policyErr *PolicyError
+	if !errors.As(err, &policyErr) {
+		t.Fatalf("enforcePolicies() error = %v, want a PolicyError", err)
+	}
+	expected := []string{
+		"feature ./dind uses --privileged, which is not allowed",
+		"feature ./dind uses --cap-add=SYS_ADMIN, which is not allowed",
+	}
+	if !reflect.DeepEqual(policyErr.Violations, expected) {
+		t.Errorf("enforcePolicies() violations = %q, want %q", policyErr.Violations, expected)
+	}
+}
+
+func TestFeatureMetadataRunArgs(t *testing.T) {
+	metadata := FeatureMetadata{Privileged: true, CapAdd: []string{"SYS_PTRACE"}, SecurityOpt: []string{"seccomp=unconfined"}}
+	expected := []string{"--privileged", "--cap-add", "--cap-add=SYS_PTRACE", "--security-opt", "--security-opt=seccomp=unconfined"}
+	if got := metadata.RunArgs(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("RunArgs() = %v, want %v", got, expected)
+	}
+}
+
+func TestImageAllowed(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		image    string
+		expected bool
+	}{
+		{"ubuntu", "ubuntu", true},
+		{"ubuntu", "ubuntu:24.04", true},
+		{"docker.io/library/ubuntu", "ubuntu@sha256:abc", true},
+		{"ubuntu:22.04", "ubuntu:24.04", false},
+		{"ghcr.io/acme/*", "ghcr.io/acme/tools/app:1", true},
+		{"ghcr.io/acme/*", "ghcr.io/acmecorp/app", false},
+		{"localhost:5000/*", "localhost:5000/app:dev", true},
+	}
+
+	for _, tt := range tests {
+		if got := imageAllowed(tt.pattern, tt.image); got != tt.expected {
+			t.Errorf("imageAllowed(%q, %q) = %v, want %v", tt.pattern, tt.image, got, tt.expected)
+		}
+	}
+}
+
+func TestLoadPolicies(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"policy.yml": "denied-run-args: [--privileged]\n",
+	})
+
+	served := "required-limits: [cpus]\n"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if served == "" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte(served))
+	}))
+	defer server.Close()
+
+	expected := []sourcedPolicy{
+		{PolicyPath(), Policy{DeniedRunArgs: []string{"--privileged"}}},
+		{server.URL, Policy{RequiredLimits: []string{"cpus"}}},
+	}
+	policies, err := loadPolicies(server.URL)
+	if err != nil {
+		t.Fatalf("loadPolicies() error = %v", err)
+	}
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("loadPolicies() = %+v, want %+v", policies, expected)
+	}
+
+	// the cached copy is used when the policy can't be fetched
+	served = ""
+	policies, err = loadPolicies(server.URL)
+	if err != nil {
+		t.Fatalf("loadPolicies() error = %v", err)
+	}
+	if !reflect.DeepEqual(policies, expected) {
+		t.Errorf("loadPolicies() = %+v, want %+v", policies, expected)
+	}
+
+	// without a cached copy boxes can't be started
+	if err := os.Remove(policyCachePath()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPolicies(server.URL); err == nil {
+		t.Errorf("loadPolicies() did not fail without the policy")
+	}
+
+	if err := os.WriteFile(filepath.Join(ConfigDir, "policy.yml"), []byte("required-limits: [gpus]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadPolicies(""); err == nil {
+		t.Errorf("loadPolicies() accepted an invalid policy")
+	}
+}
+
+func TestPolicyError(t *testing.T) {
+	err := error(&PolicyError{EnvName: "app", Source: "policy.yml", Violations: []string{"runArgs --privileged is not allowed"}})
+	if !errors.Is(err, ErrPolicyViolation) {
+		t.Errorf("PolicyError doesn't match ErrPolicyViolation")
+	}
+	expected := "app violates the policy in policy.yml:\n  runArgs --privileged is not allowed"
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, want %q", err.Error(), expected)
+	}
+}
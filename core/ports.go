@@ -0,0 +1,131 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// BoxPort is a container port published to the host
+type BoxPort struct {
+	ContainerPort int
+	Protocol      string
+	HostPort      int
+}
+
+// Variable names the environment variable for the port, e.g. TAPE_PORT_8080,
+// or TAPE_PORT_53_UDP for non-tcp ports
+func (p BoxPort) Variable() string {
+	name := fmt.Sprintf("TAPE_PORT_%d", p.ContainerPort)
+	if p.Protocol != "" && p.Protocol != "tcp" {
+		name += "_" + strings.ToUpper(p.Protocol)
+	}
+	return name
+}
+
+// GetBoxPorts returns the box's published ports. For a running box these are
+// read from the container and the proxies publishing its ports on demand, so
+// ports docker assigned are included. Otherwise only the ports with a fixed
+// host port in the box config are known.
+func GetBoxPorts(envName string) ([]BoxPort, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	summary, err := GetBoxSummary(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	if summary.State != BoxStateRunning {
+		return configuredPorts(boxConfig.Ports), nil
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	inspect, err := cli.InspectContainer(ctx, summary.ContainerID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %v", err)
+	}
+	bindings := inspect.Ports
+
+	proxies, err := findPortProxies(ctx, cli, envName, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, proxy := range proxies {
+		details, err := cli.InspectContainer(ctx, proxy.ID)
+		if err != nil {
+			return nil, fmt.Errorf("error inspecting container: %v", err)
+		}
+		bindings = append(bindings, details.Ports...)
+	}
+	return publishedPorts(bindings), nil
+}
+
+// publishedPorts keeps one host port per container port (docker binds IPv4
+// and IPv6 separately)
+func publishedPorts(bindings []container.PortBinding) []BoxPort {
+	var ports []BoxPort
+	seen := map[string]bool{}
+	for _, binding := range bindings {
+		key := fmt.Sprintf("%d/%s", binding.ContainerPort, binding.Protocol)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		ports = append(ports, BoxPort{ContainerPort: binding.ContainerPort, Protocol: binding.Protocol, HostPort: binding.HostPort})
+	}
+	sortPorts(ports)
+	return ports
+}
+
+// configuredPorts returns the box config's "[ip:]hostPort:containerPort" mappings
+func configuredPorts(mappings []string) []BoxPort {
+	var ports []BoxPort
+	for _, mapping := range mappings {
+		mapping, protocol, found := strings.Cut(mapping, "/")
+		if !found {
+			protocol = "tcp"
+		}
+		i := strings.LastIndex(mapping, ":")
+		if i < 0 {
+			// docker picks the host port when the container starts
+			continue
+		}
+		hostPort, containerPort := mapping[:i], mapping[i+1:]
+		if j := strings.LastIndex(hostPort, ":"); j >= 0 {
+			hostPort = hostPort[j+1:]
+		}
+		host, err := strconv.Atoi(hostPort)
+		if err != nil {
+			continue
+		}
+		container, err := strconv.Atoi(containerPort)
+		if err != nil {
+			continue
+		}
+		ports = append(ports, BoxPort{ContainerPort: container, Protocol: protocol, HostPort: host})
+	}
+	sortPorts(ports)
+	return ports
+}
+
+func sortPorts(ports []BoxPort) {
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].ContainerPort != ports[j].ContainerPort {
+			return ports[i].ContainerPort < ports[j].ContainerPort
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+}
@@ -0,0 +1,107 @@
+package core
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// tcpListenState is the state of listening sockets in /proc/net/tcp
+const tcpListenState = "0A"
+
+// ListeningPorts returns the TCP ports processes in the box's running
+// container listen on, read from /proc/net/tcp. Ports only listening on the
+// container's loopback address are included, they are forwarded through a
+// relay in the container, see dialBoxPort.
+func ListeningPorts(envName string) ([]int, error) {
+	var ports []int
+	err := withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+		listeners, err := readListeners(ctx, cli, dc.ID)
+		if err != nil {
+			return err
+		}
+		ports = slices.Sorted(maps.Keys(listeners))
+		return nil
+	})
+	return ports, err
+}
+
+// readListeners returns the addresses processes in a running container
+// listen on, by port
+func readListeners(ctx context.Context, cli container.Backend, containerID string) (map[int][]net.IP, error) {
+	// tcp6 is missing when IPv6 is disabled, cat still prints tcp
+	result, err := cli.ExecContainer(ctx, containerID, container.ExecConfig{
+		Command: []string{"cat", "/proc/net/tcp", "/proc/net/tcp6"},
+		User:    "root",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading listening ports: %v", err)
+	}
+	if result.ExitCode != 0 && len(result.Stdout) == 0 {
+		return nil, fmt.Errorf("error reading listening ports: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+	return parseListeners(string(result.Stdout)), nil
+}
+
+// parseListeners returns the addresses of the listening sockets in the
+// contents of /proc/net/tcp and /proc/net/tcp6 by port, whose lines are like
+//
+//	sl  local_address rem_address   st ...
+//	0: 00000000:0BB8 00000000:0000 0A ...
+func parseListeners(data string) map[int][]net.IP {
+	listeners := map[int][]net.IP{}
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != tcpListenState {
+			continue
+		}
+		address, port, found := strings.Cut(fields[1], ":")
+		if !found {
+			continue
+		}
+		ip := parseProcIP(address)
+		if ip == nil {
+			continue
+		}
+		p, err := strconv.ParseUint(port, 16, 16)
+		if err != nil || p == 0 {
+			continue
+		}
+		listeners[int(p)] = append(listeners[int(p)], ip)
+	}
+	return listeners
+}
+
+// loopbackAddress returns the loopback address of a port's listeners when
+// it only listens on loopback, and can't be reached from outside the
+// container, or "" otherwise
+func loopbackAddress(ips []net.IP) string {
+	if len(ips) == 0 || slices.ContainsFunc(ips, func(ip net.IP) bool { return !ip.IsLoopback() }) {
+		return ""
+	}
+	return ips[0].String()
+}
+
+// parseProcIP parses an address from /proc/net/tcp, hex in host byte order,
+// which is little endian, a 32 bit word at a time
+func parseProcIP(s string) net.IP {
+	b, err := hex.DecodeString(s)
+	if err != nil || (len(b) != net.IPv4len && len(b) != net.IPv6len) {
+		return nil
+	}
+	ip := make(net.IP, len(b))
+	for i := 0; i < len(b); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	return ip
+}
@@ -0,0 +1,54 @@
+package core
+
+import (
+	"maps"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseListeners(t *testing.T) {
+	data := `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0BB8 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12345 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12346 1 0000000000000000 100 0 0 10 0
+   2: 020011AC:0BB8 0A0011AC:D431 01 00000000:00000000 00:00000000 00000000  1000        0 12347 1 0000000000000000 20 4 30 10 -1
+   3: 030011AC:1388 00000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12348 1 0000000000000000 100 0 0 10 0
+  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0BB8 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12349 1 0000000000000000 100 0 0 10 0
+   1: 00000000000000000000000001000000:2328 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12350 1 0000000000000000 100 0 0 10 0
+   2: 00000000000000000000000000000000:0050 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000  1000        0 12351 1 0000000000000000 100 0 0 10 0
+`
+	// 3000 is listed twice, 8080 and 9000 only listen on loopback and the
+	// established connection on 3000 isn't a listener
+	listeners := parseListeners(data)
+	if ports := slices.Sorted(maps.Keys(listeners)); !reflect.DeepEqual(ports, []int{80, 3000, 5000, 8080, 9000}) {
+		t.Errorf("parseListeners() ports = %v, want [80 3000 5000 8080 9000]", ports)
+	}
+
+	loopback := map[int]string{80: "", 3000: "", 5000: "", 8080: "127.0.0.1", 9000: "::1"}
+	for port, expected := range loopback {
+		if address := loopbackAddress(listeners[port]); address != expected {
+			t.Errorf("loopbackAddress() of %d = %q, want %q", port, address, expected)
+		}
+	}
+}
+
+func TestParseProcIP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"0100007F", "127.0.0.1"},
+		{"020011AC", "172.17.0.2"},
+		{"00000000000000000000000001000000", "::1"},
+		{"0000000000000000FFFF00000100007F", "127.0.0.1"},
+	}
+	for _, tt := range tests {
+		if ip := parseProcIP(tt.input); ip.String() != tt.expected {
+			t.Errorf("parseProcIP(%q) = %v, want %s", tt.input, ip, tt.expected)
+		}
+	}
+	if ip := parseProcIP("zz"); ip != nil {
+		t.Errorf("parseProcIP(\"zz\") = %v, want nil", ip)
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// ProxyDomain is the domain tape daemon's reverse proxy serves boxes on.
+// Browsers resolve every *.localhost name to the loopback address.
+const ProxyDomain = "localhost"
+
+// ProxyRoute is a hostname the reverse proxy forwards to a box's port
+type ProxyRoute struct {
+	Hostname string
+	EnvName  string
+	Port     int
+	// Protocol is how the port is spoken to, http or https
+	Protocol string
+}
+
+var hostnameInvalidChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// hostnameLabel turns a box name or port label into a DNS label, e.g.
+// "Team/API Server" into team-api-server
+func hostnameLabel(s string) string {
+	return strings.Trim(hostnameInvalidChars.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// ProxyRoutes returns the hostnames the reverse proxy serves a box on:
+// <box>.localhost for its primary port, the first of forwardPorts, and
+// <label>.<box>.localhost for each port labelled in portsAttributes
+func ProxyRoutes(boxConfig BoxConfig) ([]ProxyRoute, error) {
+	var config *devcontainer.DevContainerConfig
+	if boxConfig.Config != "" {
+		var err error
+		config, err = LoadConfig(boxConfig.Config)
+		if err != nil {
+			return nil, fmt.Errorf("error loading config: %v", err)
+		}
+	}
+	return proxyRoutes(boxConfig, config)
+}
+
+func proxyRoutes(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) ([]ProxyRoute, error) {
+	host := hostnameLabel(boxConfig.Name) + "." + ProxyDomain
+	var attributes map[string]devcontainer.PortAttributes
+	var ports []int
+	if config != nil {
+		attributes = config.PortsAttributes
+		specs, err := config.ForwardPortSpecs()
+		if err != nil {
+			return nil, err
+		}
+		// ports of other compose services aren't the box's to serve
+		for _, spec := range specs {
+			if spec.IsLocal() {
+				ports = append(ports, spec.Port)
+			}
+		}
+	}
+	var labelled []int
+	for key := range attributes {
+		if port, err := strconv.Atoi(key); err == nil {
+			labelled = append(labelled, port)
+		}
+	}
+	slices.Sort(labelled)
+	ports = append(ports, labelled...)
+	ports = append(ports, configuredContainerPorts(boxConfig.Ports)...)
+	if len(ports) == 0 {
+		return nil, nil
+	}
+
+	protocol := func(port int) string {
+		if attributes[strconv.Itoa(port)].Protocol == "https" {
+			return "https"
+		}
+		return "http"
+	}
+	routes := []ProxyRoute{{Hostname: host, EnvName: boxConfig.Name, Port: ports[0], Protocol: protocol(ports[0])}}
+	for _, port := range labelled {
+		label := hostnameLabel(attributes[strconv.Itoa(port)].Label)
+		if label == "" {
+			continue
+		}
+		routes = append(routes, ProxyRoute{Hostname: label + "." + host, EnvName: boxConfig.Name, Port: port, Protocol: protocol(port)})
+	}
+	return routes, nil
+}
+
+// configuredContainerPorts returns the container ports of the box config's
+// port mappings
+func configuredContainerPorts(mappings []string) []int {
+	var ports []int
+	for _, mapping := range mappings {
+		mapping, _, _ = strings.Cut(mapping, "/")
+		// the container port is last, after any host address and port
+		if i := strings.LastIndex(mapping, ":"); i >= 0 {
+			mapping = mapping[i+1:]
+		}
+		if port, err := strconv.Atoi(mapping); err == nil {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// FindProxyRoute returns the route for a request's Host header
+func FindProxyRoute(host string) (*ProxyRoute, error) {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	host = strings.ToLower(host)
+
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+	for _, envName := range envNames {
+		// only boxes whose name matches the hostname are loaded
+		if !strings.HasSuffix(host, hostnameLabel(envName)+"."+ProxyDomain) {
+			continue
+		}
+		boxConfig, err := LoadBoxConfig(envName)
+		if err != nil {
+			return nil, err
+		}
+		routes, err := ProxyRoutes(*boxConfig)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			if route.Hostname == host {
+				return &route, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrNoProxyRoute, host)
+}
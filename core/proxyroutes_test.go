@@ -0,0 +1,84 @@
+package core
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestProxyRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		boxConfig BoxConfig
+		config    *devcontainer.DevContainerConfig
+		expected  []ProxyRoute
+	}{
+		{
+			name:      "no ports",
+			boxConfig: BoxConfig{Name: "app"},
+		},
+		{
+			name:      "first forwarded port",
+			boxConfig: BoxConfig{Name: "app"},
+			config:    &devcontainer.DevContainerConfig{ForwardPorts: []interface{}{"db:5432", float64(3000), float64(8080)}},
+			expected:  []ProxyRoute{{Hostname: "app.localhost", EnvName: "app", Port: 3000, Protocol: "http"}},
+		},
+		{
+			name:      "labelled ports",
+			boxConfig: BoxConfig{Name: "Team/API"},
+			config: &devcontainer.DevContainerConfig{PortsAttributes: map[string]devcontainer.PortAttributes{
+				"9000": {Label: "Docs Site"},
+				"8443": {Label: "admin", Protocol: "https"},
+				"5432": {},
+			}},
+			expected: []ProxyRoute{
+				{Hostname: "team-api.localhost", EnvName: "Team/API", Port: 5432, Protocol: "http"},
+				{Hostname: "admin.team-api.localhost", EnvName: "Team/API", Port: 8443, Protocol: "https"},
+				{Hostname: "docs-site.team-api.localhost", EnvName: "Team/API", Port: 9000, Protocol: "http"},
+			},
+		},
+		{
+			name:      "box config ports",
+			boxConfig: BoxConfig{Name: "app", Ports: []string{"127.0.0.1:8000:80/tcp"}},
+			expected:  []ProxyRoute{{Hostname: "app.localhost", EnvName: "app", Port: 80, Protocol: "http"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes, err := proxyRoutes(tt.boxConfig, tt.config)
+			if err != nil {
+				t.Fatalf("proxyRoutes() error = %v", err)
+			}
+			if !reflect.DeepEqual(routes, tt.expected) {
+				t.Errorf("proxyRoutes() = %+v, want %+v", routes, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFindProxyRoute(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"app.yml":                               "workspace: app\n",
+		"app/.devcontainer/devcontainer.json":   `{"image": "ubuntu", "forwardPorts": [3000]}`,
+		"other.yml":                             "workspace: other\n",
+		"other/.devcontainer/devcontainer.json": `{"image": "ubuntu"}`,
+	})
+
+	route, err := FindProxyRoute("App.localhost:8080")
+	if err != nil {
+		t.Fatalf("FindProxyRoute() error = %v", err)
+	}
+	expected := ProxyRoute{Hostname: "app.localhost", EnvName: "app", Port: 3000, Protocol: "http"}
+	if *route != expected {
+		t.Errorf("FindProxyRoute() = %+v, want %+v", *route, expected)
+	}
+
+	for _, host := range []string{"other.localhost", "missing.localhost", "example.com"} {
+		if _, err := FindProxyRoute(host); !errors.Is(err, ErrNoProxyRoute) {
+			t.Errorf("FindProxyRoute(%q) error = %v, want ErrNoProxyRoute", host, err)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// BoxContainer is a container tape created, found by its labels rather than
+// through a box config, so it includes containers whose config was deleted
+type BoxContainer struct {
+	EnvName     string
+	ContainerID string
+	State       BoxState
+	ConfigHash  string
+	Version     string
+}
+
+// HasConfig reports whether the container's environment still has a config file
+func (b BoxContainer) HasConfig() bool {
+	_, err := os.Stat(BoxConfigPath(b.EnvName))
+	return err == nil
+}
+
+// ListBoxContainers returns the containers tape created on the default docker host
+func ListBoxContainers() ([]BoxContainer, error) {
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ListContainers(context.Background(), []string{EnvLabel})
+	if err != nil {
+		return nil, err
+	}
+
+	boxContainers := make([]BoxContainer, len(containers))
+	for i, c := range containers {
+		boxContainers[i] = BoxContainer{
+			EnvName:     c.Labels[EnvLabel],
+			ContainerID: c.ID,
+			State:       boxStateFromContainerState(c.State),
+			ConfigHash:  c.Labels[ConfigHashLabel],
+			Version:     c.Labels[VersionLabel],
+		}
+	}
+	return boxContainers, nil
+}
+
+// PruneBoxContainers removes stopped containers tape created for environments
+// that no longer have a config, returning the containers it removed
+func PruneBoxContainers() ([]BoxContainer, error) {
+	boxContainers, err := ListBoxContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	var removed []BoxContainer
+	for _, c := range boxContainers {
+		if !c.State.CanRemove() || c.HasConfig() {
+			continue
+		}
+		if err := cli.RemoveContainer(context.Background(), c.ContainerID); err != nil {
+			return removed, fmt.Errorf("error removing container %s: %v", c.ContainerID, err)
+		}
+		removed = append(removed, c)
+	}
+	return removed, nil
+}
+
+// PruneReport lists what AutoPrune removed
+type PruneReport struct {
+	Containers []BoxContainer
+	Snapshots  []Snapshot
+}
+
+// AutoPrune removes what PruneBoxContainers would, plus whatever the global
+// config's retention policy expires: containers stopped for longer than
+// stopped-after and all but the newest max-snapshots snapshots of each environment
+func AutoPrune() (*PruneReport, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	policy := globalConfig.Retention
+
+	boxContainers, err := ListBoxContainers()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newDefaultClient()
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	report := &PruneReport{}
+
+	for _, c := range boxContainers {
+		if !c.State.CanRemove() {
+			continue
+		}
+		if c.HasConfig() {
+			expired, err := stoppedLongerThan(ctx, cli, c.ContainerID, policy.StoppedAfterDuration())
+			if err != nil {
+				return report, err
+			}
+			if !expired {
+				continue
+			}
+		}
+		if err := cli.RemoveContainer(ctx, c.ContainerID); err != nil {
+			return report, fmt.Errorf("error removing container %s: %v", c.ContainerID, err)
+		}
+		report.Containers = append(report.Containers, c)
+	}
+
+	if policy.MaxSnapshots == 0 {
+		return report, nil
+	}
+
+	envNames, err := snapshotEnvs()
+	if err != nil {
+		return report, err
+	}
+	for _, envName := range envNames {
+		snapshots, err := ListSnapshots(envName)
+		if err != nil {
+			return report, err
+		}
+		if len(snapshots) <= policy.MaxSnapshots {
+			continue
+		}
+		for _, snapshot := range snapshots[policy.MaxSnapshots:] {
+			if err := RemoveSnapshot(envName, snapshot.Tag); err != nil {
+				return report, err
+			}
+			report.Snapshots = append(report.Snapshots, snapshot)
+		}
+	}
+
+	return report, nil
+}
+
+// stoppedLongerThan reports whether the container exited more than after ago.
+// A zero duration never expires.
+func stoppedLongerThan(ctx context.Context, cli container.Backend, containerID string, after time.Duration) (bool, error) {
+	if after == 0 {
+		return false, nil
+	}
+
+	inspect, err := cli.InspectContainer(ctx, containerID)
+	if err != nil {
+		return false, fmt.Errorf("error inspecting container %s: %v", containerID, err)
+	}
+
+	// containers that never ran have no finish time, use when they were created
+	stoppedAt := inspect.FinishedAt
+	if stoppedAt.IsZero() {
+		stoppedAt = inspect.Created
+	}
+	if stoppedAt.IsZero() {
+		return false, nil
+	}
+	return time.Since(stoppedAt) > after, nil
+}
+
+// snapshotEnvs returns the environments that have snapshots, including
+// environments whose config was deleted
+func snapshotEnvs() ([]string, error) {
+	root := filepath.Join(ConfigDir, ".snapshots")
+	var envNames []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fs.SkipAll
+			}
+			return err
+		}
+		if d.IsDir() || d.Name() != snapshotManifestFile {
+			return nil
+		}
+		// the manifest is at <env>/<tag>/snapshot.json
+		envDir, err := filepath.Rel(root, filepath.Dir(filepath.Dir(path)))
+		if err != nil {
+			return err
+		}
+		envName := filepath.ToSlash(envDir)
+		if !slices.Contains(envNames, envName) {
+			envNames = append(envNames, envName)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshots: %v", err)
+	}
+	return envNames, nil
+}
+
+// newDefaultClient returns the backend for the docker host from the global config
+func newDefaultClient() (container.Backend, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newBackend(globalConfig, globalConfig.DockerHost)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	return cli, nil
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestListSnapshots(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		".snapshots/web/old/snapshot.json":        `{"env": "web", "tag": "old", "createdAt": "2024-01-01T00:00:00Z"}`,
+		".snapshots/web/new/snapshot.json":        `{"env": "web", "tag": "new", "createdAt": "2024-03-01T00:00:00Z"}`,
+		".snapshots/web/middle/snapshot.json":     `{"env": "web", "tag": "middle", "createdAt": "2024-02-01T00:00:00Z"}`,
+		".snapshots/project/api/v1/snapshot.json": `{"env": "project/api", "tag": "v1", "createdAt": "2024-01-01T00:00:00Z"}`,
+	})
+
+	snapshots, err := ListSnapshots("web")
+	if err != nil {
+		t.Fatalf("ListSnapshots() error = %v", err)
+	}
+	var tags []string
+	for _, snapshot := range snapshots {
+		tags = append(tags, snapshot.Tag)
+	}
+	if expected := []string{"new", "middle", "old"}; !reflect.DeepEqual(tags, expected) {
+		t.Errorf("ListSnapshots() tags = %v, want %v", tags, expected)
+	}
+
+	snapshots, err = ListSnapshots("missing")
+	if err != nil || len(snapshots) != 0 {
+		t.Errorf("ListSnapshots(missing) = %v, %v, want no snapshots", snapshots, err)
+	}
+
+	envNames, err := snapshotEnvs()
+	if err != nil {
+		t.Fatalf("snapshotEnvs() error = %v", err)
+	}
+	sort.Strings(envNames)
+	if expected := []string{"project/api", "web"}; !reflect.DeepEqual(envNames, expected) {
+		t.Errorf("snapshotEnvs() = %v, want %v", envNames, expected)
+	}
+}
@@ -0,0 +1,217 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// Labels of the proxy containers that publish a box's ports
+const (
+	PublishEnvLabel  = "tape.publish.env"
+	PublishPortLabel = "tape.publish.port"
+)
+
+// PublishProxyImage runs the proxies that publish ports of running boxes
+var PublishProxyImage = "alpine/socat:latest"
+
+// PublishedPort is a box's container port published on the host by a proxy container
+type PublishedPort struct {
+	ContainerPort int
+	HostPort      int
+	ProxyID       string
+}
+
+// PublishPort publishes a port of the box's running container on the host
+// without recreating the container, which docker can't add ports to, by
+// running a proxy container on the box's network that publishes it. A
+// hostPort of 0 picks a free port. The proxy is removed when the box is
+// stopped, since the box's address can change when it's started again.
+func PublishPort(envName string, containerPort int, hostPort int) (*PublishedPort, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	var published *PublishedPort
+	err = withBoxContainer(envName, func(ctx context.Context, cli container.Backend, dc *container.Container) error {
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+
+		existing, err := findPortProxies(ctx, cli, envName, containerPort)
+		if err != nil {
+			return err
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("port %d of %s is already published, unpublish it first", containerPort, envName)
+		}
+
+		details, err := cli.InspectContainer(ctx, dc.ID)
+		if err != nil {
+			return fmt.Errorf("error inspecting container: %v", err)
+		}
+		network, address, err := proxyTarget(*boxConfig, details.Networks)
+		if err != nil {
+			return err
+		}
+
+		exists, err := cli.HasImage(ctx, PublishProxyImage)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			if err := pullImage(ctx, cli, *boxConfig, PublishProxyImage); err != nil {
+				return err
+			}
+		}
+
+		port := strconv.Itoa(containerPort)
+		proxy, err := cli.CreateContainer(ctx, container.ContainerConfig{
+			Image:   PublishProxyImage,
+			Command: []string{"TCP-LISTEN:" + port + ",fork,reuseaddr", "TCP-CONNECT:" + address + ":" + port},
+			Name:    fmt.Sprintf("%s-publish-%d", boxConfig.ContainerName(), containerPort),
+			Labels:  map[string]string{PublishEnvLabel: envName, PublishPortLabel: port},
+			Network: network,
+			Ports:   []container.PortBinding{{ContainerPort: containerPort, Protocol: "tcp", HostIP: "127.0.0.1", HostPort: hostPort}},
+		})
+		if err != nil {
+			return err
+		}
+		if err := cli.StartContainer(ctx, proxy.ID); err != nil {
+			cli.RemoveContainer(ctx, proxy.ID)
+			return err
+		}
+
+		proxyDetails, err := cli.InspectContainer(ctx, proxy.ID)
+		if err != nil {
+			return fmt.Errorf("error inspecting container: %v", err)
+		}
+		published = &PublishedPort{ContainerPort: containerPort, ProxyID: proxy.ID}
+		for _, binding := range proxyDetails.Ports {
+			if binding.ContainerPort == containerPort {
+				published.HostPort = binding.HostPort
+			}
+		}
+		return nil
+	})
+	if err == nil {
+		recordEvent(envName, "publish", fmt.Sprintf("%d:%d", published.HostPort, containerPort), nil)
+	}
+	return published, err
+}
+
+// UnpublishPort removes the proxy publishing a port of the box
+func UnpublishPort(envName string, containerPort int) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	proxies, err := findPortProxies(ctx, cli, envName, containerPort)
+	if err != nil {
+		return err
+	}
+	if len(proxies) == 0 {
+		return fmt.Errorf("port %d of %s is not published", containerPort, envName)
+	}
+	for _, proxy := range proxies {
+		if err := cli.RemoveContainer(ctx, proxy.ID); err != nil {
+			return err
+		}
+	}
+	recordEvent(envName, "unpublish", strconv.Itoa(containerPort), nil)
+	return nil
+}
+
+// PublishedPorts lists the ports of the box published by proxy containers
+func PublishedPorts(envName string) ([]PublishedPort, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	proxies, err := findPortProxies(ctx, cli, envName, 0)
+	if err != nil {
+		return nil, err
+	}
+	var ports []PublishedPort
+	for _, proxy := range proxies {
+		containerPort, _ := strconv.Atoi(proxy.Labels[PublishPortLabel])
+		port := PublishedPort{ContainerPort: containerPort, ProxyID: proxy.ID}
+		if details, err := cli.InspectContainer(ctx, proxy.ID); err == nil {
+			for _, binding := range details.Ports {
+				if binding.ContainerPort == containerPort {
+					port.HostPort = binding.HostPort
+				}
+			}
+		}
+		ports = append(ports, port)
+	}
+	slices.SortFunc(ports, func(a, b PublishedPort) int { return a.ContainerPort - b.ContainerPort })
+	return ports, nil
+}
+
+// findPortProxies returns the proxies publishing the box's port, or all of
+// its ports when containerPort is 0
+func findPortProxies(ctx context.Context, cli container.Backend, envName string, containerPort int) ([]container.Container, error) {
+	labels := []string{PublishEnvLabel + "=" + envName}
+	if containerPort != 0 {
+		labels = append(labels, fmt.Sprintf("%s=%d", PublishPortLabel, containerPort))
+	}
+	proxies, err := cli.FindContainers(ctx, labels)
+	if container.IsContainerNotFound(err) {
+		return nil, nil
+	}
+	return proxies, err
+}
+
+// removePortProxies removes the proxies publishing the box's ports. They are
+// best effort, a proxy left behind only forwards to nothing.
+func removePortProxies(ctx context.Context, cli container.Backend, envName string) {
+	proxies, err := findPortProxies(ctx, cli, envName, 0)
+	if err != nil {
+		fmt.Fprintf(Stderr, "Warning: error finding published ports: %v\n", err)
+		return
+	}
+	for _, proxy := range proxies {
+		if err := cli.RemoveContainer(ctx, proxy.ID); err != nil {
+			fmt.Fprintf(Stderr, "Warning: error unpublishing ports: %v\n", err)
+		}
+	}
+}
+
+// proxyTarget picks the network a proxy joins to reach the box and the box's
+// address on it: the box's configured network, else the default bridge,
+// else any network it's on
+func proxyTarget(boxConfig BoxConfig, networks map[string]string) (string, string, error) {
+	for _, name := range []string{boxConfig.Network, "bridge"} {
+		if address, ok := networks[name]; ok && name != "" {
+			return name, address, nil
+		}
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return "", "", fmt.Errorf("%s is not reachable on any network, it can't publish ports", boxConfig.Name)
+	}
+	slices.Sort(names)
+	return names[0], networks[names[0]], nil
+}
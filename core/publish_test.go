@@ -0,0 +1,51 @@
+package core
+
+import "testing"
+
+func TestProxyTarget(t *testing.T) {
+	tests := []struct {
+		name            string
+		boxConfig       BoxConfig
+		networks        map[string]string
+		expectedNetwork string
+		expectedAddress string
+		wantErr         bool
+	}{
+		{
+			name:            "configured network",
+			boxConfig:       BoxConfig{Network: "shared"},
+			networks:        map[string]string{"bridge": "172.17.0.2", "shared": "172.20.0.3"},
+			expectedNetwork: "shared",
+			expectedAddress: "172.20.0.3",
+		},
+		{
+			name:            "default bridge",
+			networks:        map[string]string{"bridge": "172.17.0.2", "other": "172.21.0.2"},
+			expectedNetwork: "bridge",
+			expectedAddress: "172.17.0.2",
+		},
+		{
+			name:            "any network",
+			networks:        map[string]string{"web": "172.22.0.2", "db": "172.21.0.2"},
+			expectedNetwork: "db",
+			expectedAddress: "172.21.0.2",
+		},
+		{
+			name:     "no network",
+			networks: map[string]string{},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			network, address, err := proxyTarget(tt.boxConfig, tt.networks)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("proxyTarget() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if network != tt.expectedNetwork || address != tt.expectedAddress {
+				t.Errorf("proxyTarget() = %s, %s, want %s, %s", network, address, tt.expectedNetwork, tt.expectedAddress)
+			}
+		})
+	}
+}
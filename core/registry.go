@@ -0,0 +1,263 @@
+package core
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+const ociManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+
+// registryClient pulls devcontainer artifacts (features, templates) from OCI registries
+type registryClient struct {
+	http *http.Client
+	// scheme is overridden in tests, registries are always https
+	scheme string
+	// tokens caches bearer tokens by repository
+	tokens map[string]string
+	// auth returns the credentials for a registry, nil for anonymous access
+	auth func(registry string) (*container.RegistryAuth, error)
+}
+
+func newRegistryClient() *registryClient {
+	return &registryClient{
+		http:   http.DefaultClient,
+		scheme: "https",
+		tokens: map[string]string{},
+		auth: func(registry string) (*container.RegistryAuth, error) {
+			dockerConfig, err := container.LoadDockerConfig()
+			if err != nil {
+				return nil, err
+			}
+			return dockerConfig.Lookup(registry)
+		},
+	}
+}
+
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	Layers []ociDescriptor `json:"layers"`
+}
+
+// fetchLayer downloads the first layer of the artifact, which is where
+// devcontainer features and templates keep their tarball
+func (c *registryClient) fetchLayer(ref devcontainer.FeatureRef) ([]byte, error) {
+	if Offline {
+		return nil, fmt.Errorf("%w: fetching %s needs the registry", ErrOffline, ref)
+	}
+
+	reference := ref.Version
+	if ref.Digest != "" {
+		reference = ref.Digest
+	}
+
+	data, err := c.get(ref, "manifests/"+reference, ociManifestMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching manifest for %s: %w", ref, err)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing manifest for %s: %v", ref, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return nil, fmt.Errorf("manifest for %s has no layers", ref)
+	}
+
+	layer, err := c.get(ref, "blobs/"+manifest.Layers[0].Digest, "")
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", ref, err)
+	}
+	return layer, nil
+}
+
+// get fetches a path under the repository's /v2/ API
+func (c *registryClient) get(ref devcontainer.FeatureRef, path string, accept string) ([]byte, error) {
+	resp, err := c.request(http.MethodGet, ref.Registry, ref.Namespace+"/"+ref.ID, path, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// featureMetadataAnnotation is the manifest annotation feature publishers
+// store devcontainer-feature.json in
+const featureMetadataAnnotation = "dev.containers.metadata"
+
+// resolveFeature returns the version and manifest digest a feature's tag
+// points to. The version is empty when the manifest doesn't include the
+// feature's metadata.
+func (c *registryClient) resolveFeature(ref devcontainer.FeatureRef) (LockedFeature, error) {
+	if Offline {
+		return LockedFeature{}, fmt.Errorf("%w: resolving %s needs the registry", ErrOffline, ref)
+	}
+	data, err := c.get(ref, "manifests/"+ref.Version, ociManifestMediaType)
+	if err != nil {
+		return LockedFeature{}, fmt.Errorf("error fetching manifest for %s: %w", ref, err)
+	}
+	sum := sha256.Sum256(data)
+	locked := LockedFeature{Digest: "sha256:" + hex.EncodeToString(sum[:])}
+
+	var manifest struct {
+		Annotations map[string]string `json:"annotations"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return LockedFeature{}, fmt.Errorf("error parsing manifest for %s: %v", ref, err)
+	}
+	var metadata struct {
+		Version string `json:"version"`
+	}
+	if json.Unmarshal([]byte(manifest.Annotations[featureMetadataAnnotation]), &metadata) == nil {
+		locked.Version = metadata.Version
+	}
+	return locked, nil
+}
+
+// manifestDigest returns the digest of the manifest a tag points to, without
+// downloading it
+func (c *registryClient) manifestDigest(registry string, repository string, tag string, accept string) (string, error) {
+	if Offline {
+		return "", fmt.Errorf("%w: checking %s/%s:%s needs the registry", ErrOffline, registry, repository, tag)
+	}
+	resp, err := c.request(http.MethodHead, registry, repository, "manifests/"+tag, accept)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry returned no digest for %s/%s:%s", registry, repository, tag)
+	}
+	return digest, nil
+}
+
+// request sends a request for a path under the repository's /v2/ API,
+// authenticating with a bearer token when the registry asks for one. The
+// response's status is OK.
+func (c *registryClient) request(method string, registry string, repository string, path string, accept string) (*http.Response, error) {
+	endpoint := fmt.Sprintf("%s://%s/v2/%s/%s", c.scheme, registryHost(registry), repository, path)
+
+	resp, err := c.do(method, endpoint, accept, c.tokens[repository])
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		token, err := c.fetchToken(challenge, registry)
+		if err != nil {
+			return nil, err
+		}
+		c.tokens[repository] = token
+
+		resp, err = c.do(method, endpoint, accept, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, endpoint)
+	}
+	return resp, nil
+}
+
+// registryHost returns the host serving a registry's API, which differs
+// from the registry's name for Docker Hub
+func registryHost(registry string) string {
+	if registry == container.DockerHubRegistry {
+		return "registry-1.docker.io"
+	}
+	return registry
+}
+
+func (c *registryClient) do(method string, endpoint string, accept string, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return c.http.Do(req)
+}
+
+// matches the key="value" parameters of a WWW-Authenticate header
+var challengeParamPattern = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken requests a pull token from the realm in a Bearer challenge, with
+// the docker CLI's credentials for the registry if it has any
+func (c *registryClient) fetchToken(challenge string, registry string) (string, error) {
+	params := map[string]string{}
+	for _, match := range challengeParamPattern.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("registry requires authentication: %s", challenge)
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+
+	req, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", fmt.Errorf("error fetching registry token: %v", err)
+	}
+	if c.auth != nil {
+		auth, err := c.auth(registry)
+		if err != nil {
+			return "", err
+		}
+		if auth != nil && auth.Username != "" {
+			req.SetBasicAuth(auth.Username, auth.Password)
+		}
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("error fetching registry token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry token request returned %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("error parsing registry token: %v", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
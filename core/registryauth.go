@@ -0,0 +1,285 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// RegistryCredentials are a box's credentials for a registry. The password
+// is usually read from an environment variable so it stays out of the config.
+type RegistryCredentials struct {
+	Username    string `yaml:"username" validate:"required"`
+	Password    string `yaml:"password,omitempty" validate:"required_without_all=PasswordEnv PasswordSecret"`
+	PasswordEnv string `yaml:"password-env,omitempty"`
+	// PasswordSecret is a secret reference the password is looked up with, see ResolveSecret
+	PasswordSecret string `yaml:"password-secret,omitempty"`
+}
+
+// auth returns the credentials with the password resolved
+func (c RegistryCredentials) auth(registry string) (*container.RegistryAuth, error) {
+	password := c.Password
+	if c.PasswordSecret != "" {
+		secret, err := ResolveSecret(context.Background(), c.PasswordSecret)
+		if err != nil {
+			return nil, err
+		}
+		password = secret
+	}
+	if c.PasswordEnv != "" {
+		password = os.Getenv(c.PasswordEnv)
+		if password == "" {
+			return nil, fmt.Errorf("%s is not set, it has the password for %s", c.PasswordEnv, registry)
+		}
+	}
+	return &container.RegistryAuth{Username: c.Username, Password: password}, nil
+}
+
+// registryAuth returns the credentials for a registry, the box's own if it
+// has them, otherwise the docker CLI's. It returns nil when there are none.
+func registryAuth(boxConfig BoxConfig, dockerConfig *container.DockerConfig, registry string) (*container.RegistryAuth, error) {
+	if creds, ok := boxConfig.Registries[registry]; ok {
+		return creds.auth(registry)
+	}
+	return dockerConfig.Lookup(registry)
+}
+
+// pullImage pulls an image with the box's credentials for its registry,
+// retrying as the global config says. Offline, it only checks that the image
+// exists locally.
+func pullImage(ctx context.Context, cli container.Backend, boxConfig BoxConfig, image string) error {
+	if Offline {
+		return container.Pull(ctx, cli, image, container.PullOptions{Offline: true})
+	}
+
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	dockerConfig, err := container.LoadDockerConfig()
+	if err != nil {
+		return err
+	}
+	auth, err := registryAuth(boxConfig, dockerConfig, container.ImageRegistry(image))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(Stdout, "Pulling %s...\n", image)
+	return container.Pull(ctx, cli, image, container.PullOptions{
+		Auth:  auth,
+		Retry: globalConfig.Pull.RetryPolicy(),
+		Out:   Stdout,
+	})
+}
+
+// pullMissingImages pulls the images a devcontainer config needs that don't
+// exist locally, so they get tape's credentials, retries and progress rather
+// than being pulled by the devcontainer CLI. Offline, a missing image fails
+// fast instead of when the CLI can't reach the registry. Locked images are
+// pulled at their locked digest, see pinLockedImages.
+func pullMissingImages(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) error {
+	images := configImages(config)
+	if len(images) == 0 {
+		return nil
+	}
+
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	for _, image := range images {
+		exists, err := cli.HasImage(ctx, image)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
+		if err := pullImage(ctx, cli, boxConfig, image); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// registryAuths returns a docker config with the credentials the devcontainer
+// CLI needs to pull the box's images: the box's own, the ones stored in the
+// docker CLI's config.json, and the ones for the registries of the box's
+// image or base images, and the extra registries, e.g. of the build cache.
+// Credential helpers are only asked for the latter, and resolved here since
+// they usually aren't installed where the CLI runs.
+func registryAuths(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, extra ...string) (*container.DockerConfig, error) {
+	dockerConfig, err := container.LoadDockerConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var registries []string
+	for registry := range boxConfig.Registries {
+		registries = append(registries, registry)
+	}
+	for key := range dockerConfig.Auths {
+		registries = append(registries, registryFromConfigKey(key))
+	}
+	for _, image := range configImages(config) {
+		registries = append(registries, container.ImageRegistry(image))
+	}
+	registries = append(registries, extra...)
+	slices.Sort(registries)
+	registries = slices.Compact(registries)
+
+	auths := &container.DockerConfig{Auths: map[string]container.DockerConfigAuth{}}
+	for _, registry := range registries {
+		auth, err := registryAuth(boxConfig, dockerConfig, registry)
+		if err != nil {
+			return nil, err
+		}
+		if auth != nil {
+			auths.Auths[container.DockerConfigKey(registry)] = container.EncodeDockerConfigAuth(*auth)
+		}
+	}
+	return auths, nil
+}
+
+// registryFromConfigKey returns the registry of a config.json key, which
+// docker login writes as a URL for some registries
+func registryFromConfigKey(key string) string {
+	if key == container.DockerConfigKey(container.DockerHubRegistry) {
+		return container.DockerHubRegistry
+	}
+	key = strings.TrimPrefix(strings.TrimPrefix(key, "https://"), "http://")
+	host, _, _ := strings.Cut(key, "/")
+	return host
+}
+
+// configImages returns the images a devcontainer config pulls: its image,
+// or the base images of its Dockerfile. config's Dockerfile path has to be
+// resolved already, see resolveBuildPaths.
+func configImages(config *devcontainer.DevContainerConfig) []string {
+	if config == nil {
+		return nil
+	}
+	if config.Image != "" {
+		return []string{config.Image}
+	}
+
+	dockerfile := config.DockerFile
+	if config.Build != nil && config.Build.Dockerfile != "" {
+		dockerfile = config.Build.Dockerfile
+	}
+	if dockerfile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(dockerfile)
+	if err != nil {
+		// the CLI reports the missing Dockerfile
+		return nil
+	}
+	return dockerfileImages(data)
+}
+
+// dockerfileImages returns the images in a Dockerfile's FROM instructions,
+// skipping earlier stages and images given as build arguments
+func dockerfileImages(data []byte) []string {
+	var images, stages []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || !strings.EqualFold(fields[0], "FROM") {
+			continue
+		}
+		// skip flags like --platform
+		args := slices.DeleteFunc(fields[1:], func(f string) bool { return strings.HasPrefix(f, "--") })
+		if len(args) == 0 {
+			continue
+		}
+
+		image := args[0]
+		if !strings.Contains(image, "$") && image != "scratch" && !slices.Contains(stages, strings.ToLower(image)) {
+			images = append(images, image)
+		}
+		if len(args) >= 3 && strings.EqualFold(args[1], "AS") {
+			stages = append(stages, strings.ToLower(args[2]))
+		}
+	}
+	return images
+}
+
+// writeDockerConfigDir returns a copy of the docker CLI's config directory
+// with auths added to config.json, for running docker on the host with the
+// box's credentials. The other files are linked so contexts and CLI plugins
+// like buildx keep working. cleanup removes the copy.
+func writeDockerConfigDir(auths *container.DockerConfig) (dir string, cleanup func(), err error) {
+	original := container.DockerConfigDir()
+
+	// keep settings tape doesn't know about
+	config := map[string]interface{}{}
+	data, err := os.ReadFile(filepath.Join(original, "config.json"))
+	if err != nil && !os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("error reading docker config: %v", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &config); err != nil {
+			return "", nil, fmt.Errorf("error parsing docker config: %v", err)
+		}
+	}
+
+	existing, _ := config["auths"].(map[string]interface{})
+	if existing == nil {
+		existing = map[string]interface{}{}
+	}
+	helpers, _ := config["credHelpers"].(map[string]interface{})
+	for key, auth := range auths.Auths {
+		existing[key] = auth
+		// a helper would take precedence over the entry in auths
+		delete(helpers, key)
+	}
+	config["auths"] = existing
+	// with a default store docker ignores auths. The credentials of the
+	// registries the box needs are resolved above, others are anonymous.
+	delete(config, "credsStore")
+
+	dir, err = os.MkdirTemp("", "tape-docker-config-")
+	if err != nil {
+		return "", nil, fmt.Errorf("error creating docker config directory: %v", err)
+	}
+	cleanup = func() { os.RemoveAll(dir) }
+
+	entries, err := os.ReadDir(original)
+	if err != nil && !os.IsNotExist(err) {
+		cleanup()
+		return "", nil, fmt.Errorf("error reading docker config directory: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "config.json" {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(original, entry.Name()), filepath.Join(dir, entry.Name())); err != nil {
+			cleanup()
+			return "", nil, fmt.Errorf("error linking docker config: %v", err)
+		}
+	}
+
+	data, err = json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error serializing docker config: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error writing docker config: %v", err)
+	}
+	return dir, cleanup, nil
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestDockerfileImages(t *testing.T) {
+	dockerfile := `ARG BASE=debian
+FROM --platform=linux/amd64 golang:1.23 AS build
+FROM ${BASE}
+from ghcr.io/acme/base:1 as runtime
+FROM build
+FROM scratch
+`
+	expected := []string{"golang:1.23", "ghcr.io/acme/base:1"}
+	if got := dockerfileImages([]byte(dockerfile)); !reflect.DeepEqual(got, expected) {
+		t.Errorf("dockerfileImages() = %v, want %v", got, expected)
+	}
+}
+
+func TestRegistryAuths(t *testing.T) {
+	dockerDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dockerDir)
+	config := `{"auths": {"https://index.docker.io/v1/": {"auth": "aHViOnB3"}, "ghcr.io": {"auth": "Z2g6b2xk"}}}`
+	if err := os.WriteFile(filepath.Join(dockerDir, "config.json"), []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GHCR_TOKEN", "new")
+
+	boxConfig := BoxConfig{Registries: map[string]RegistryCredentials{
+		"ghcr.io": {Username: "box", PasswordEnv: "GHCR_TOKEN"},
+	}}
+	auths, err := registryAuths(boxConfig, &devcontainer.DevContainerConfig{Image: "quay.io/acme/app"})
+	if err != nil {
+		t.Fatalf("registryAuths() error = %v", err)
+	}
+	expected := map[string]container.DockerConfigAuth{
+		"https://index.docker.io/v1/": {Auth: "aHViOnB3"},
+		// the box's credentials win over the docker CLI's
+		"ghcr.io": container.EncodeDockerConfigAuth(container.RegistryAuth{Username: "box", Password: "new"}),
+	}
+	if !reflect.DeepEqual(auths.Auths, expected) {
+		t.Errorf("registryAuths() = %v, want %v", auths.Auths, expected)
+	}
+
+	t.Setenv("GHCR_TOKEN", "")
+	if _, err := registryAuths(boxConfig, nil); err == nil {
+		t.Errorf("registryAuths() did not fail without the password")
+	}
+
+	boxConfig.Registries["ghcr.io"] = RegistryCredentials{Username: "box", PasswordSecret: "cmd://echo secret"}
+	auths, err = registryAuths(boxConfig, nil)
+	if err != nil {
+		t.Fatalf("registryAuths() error = %v", err)
+	}
+	if got, want := auths.Auths["ghcr.io"], container.EncodeDockerConfigAuth(container.RegistryAuth{Username: "box", Password: "secret"}); got != want {
+		t.Errorf("registryAuths() with a password secret = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,293 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// reportHistoryEvents is how much of the history log a report includes
+const reportHistoryEvents = 200
+
+// redacted replaces the values reports leave out
+const redacted = "REDACTED"
+
+// sensitiveKeyPattern matches the config keys whose values reports leave out
+var sensitiveKeyPattern = regexp.MustCompile(`(?i)password|token|secret|credential|auth|webhook|key`)
+
+// envKeys are the config keys holding variables, all of whose values reports leave out
+var envKeys = []string{"env", "secrets", "containerEnv", "remoteEnv", "args"}
+
+// commandKeys are the config keys holding commands, whose NAME=value
+// arguments reports leave out
+var commandKeys = []string{"runArgs", "initializeCommand", "onCreateCommand", "updateContentCommand",
+	"postCreateCommand", "postStartCommand", "postAttachCommand", "tasks", "hooks"}
+
+// commandVariablePattern matches NAME=value arguments in recorded commands
+var commandVariablePattern = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=\S*`)
+
+// urlCredentialsPattern matches the user info of URLs, e.g. the token of
+// https://token@github.com/me/dotfiles
+var urlCredentialsPattern = regexp.MustCompile(`([A-Za-z][A-Za-z0-9+.-]*://)[^/\s@]+@`)
+
+// WriteReport writes a gzipped tarball for bug reports: tape's and docker's
+// versions, the doctor checks, the global and box configs, the boxes' states
+// and the end of the history log. Values that could be credentials are
+// redacted, and nothing is sent anywhere.
+func WriteReport(w io.Writer) error {
+	gzipWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	files := []struct {
+		name    string
+		content func() ([]byte, error)
+	}{
+		{"versions.txt", reportVersions},
+		{"doctor.txt", reportDoctor},
+		{"docker.json", reportDocker},
+		{"environments.txt", reportEnvironments},
+		{"history.jsonl", reportHistory},
+	}
+	for _, file := range files {
+		content, err := file.content()
+		if err != nil {
+			// a report is most useful when something is broken, so record the error instead
+			content = []byte(fmt.Sprintf("error: %v\n", err))
+		}
+		if err := writeTarFile(tarWriter, file.name, content); err != nil {
+			return err
+		}
+	}
+
+	if err := writeReportConfigs(tarWriter); err != nil {
+		return err
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		return fmt.Errorf("error writing report: %v", err)
+	}
+	return gzipWriter.Close()
+}
+
+func reportVersions() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "tape %s\n", Version)
+	fmt.Fprintf(&b, "go %s\n", runtime.Version())
+	fmt.Fprintf(&b, "os %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	if InWSL() {
+		fmt.Fprintf(&b, "wsl %s\n", os.Getenv("WSL_DISTRO_NAME"))
+	}
+	return b.Bytes(), nil
+}
+
+func reportDoctor() ([]byte, error) {
+	checks, err := Doctor()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, check := range checks {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", check.Status, check.Name, check.Message)
+	}
+	return b.Bytes(), nil
+}
+
+func reportDocker() ([]byte, error) {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return nil, err
+	}
+	cli, err := newBackend(globalConfig, globalConfig.DockerHost)
+	if err != nil {
+		return nil, err
+	}
+	defer cli.Close()
+
+	info, err := cli.Info(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(info, "", "  ")
+}
+
+func reportEnvironments() ([]byte, error) {
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, envName := range envNames {
+		summary, err := GetBoxSummary(envName)
+		if err != nil {
+			fmt.Fprintf(&b, "%s: error: %v\n", envName, err)
+			continue
+		}
+		fmt.Fprintf(&b, "%s: %s, %d containers\n", envName, summary.State, len(summary.Containers))
+	}
+	return b.Bytes(), nil
+}
+
+func reportHistory() ([]byte, error) {
+	events, err := History("")
+	if err != nil {
+		return nil, err
+	}
+	if len(events) > reportHistoryEvents {
+		events = events[len(events)-reportHistoryEvents:]
+	}
+	var b bytes.Buffer
+	for _, event := range events {
+		event.User = redacted
+		event.Command = redactCommand(event.Command)
+		event.Detail = redactCommand(event.Detail)
+		line, err := json.Marshal(event)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(append(line, '\n'))
+	}
+	return b.Bytes(), nil
+}
+
+// writeReportConfigs adds the redacted global config, box configs and the
+// boxes' devcontainer configs to the report
+func writeReportConfigs(tarWriter *tar.Writer) error {
+	configs := map[string]string{"config/global.yml": GlobalConfigPath()}
+	envNames, err := ListBoxConfigs()
+	if err != nil {
+		return writeTarFile(tarWriter, "config/error.txt", []byte(fmt.Sprintf("error: %v\n", err)))
+	}
+	for _, envName := range envNames {
+		configs["config/boxes/"+envName+".yml"] = BoxConfigPath(envName)
+		if boxConfig, err := LoadBoxConfig(envName); err == nil && boxConfig.Config != "" {
+			configs["config/devcontainer/"+envName+".json"] = boxConfig.Config
+		}
+	}
+
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		data, err := os.ReadFile(configs[name])
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err == nil {
+			if strings.HasSuffix(name, ".json") {
+				data, err = redactJSON(data)
+			} else {
+				data, err = redactYAML(data)
+			}
+		}
+		if err != nil {
+			data = []byte(fmt.Sprintf("error: %v\n", err))
+		}
+		if err := writeTarFile(tarWriter, name, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// redactYAML redacts a YAML config, keeping its order
+func redactYAML(data []byte) ([]byte, error) {
+	var doc yaml.MapSlice
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(redactValue("", doc))
+}
+
+// redactJSON redacts a devcontainer config
+func redactJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(redactValue("", doc), "", "  ")
+}
+
+// redactValue replaces the values of sensitive keys, and every value of
+// maps of variables, with redacted. Commands, see commandKeys, are redacted
+// like recorded commands, and the credentials of URLs are left out everywhere.
+func redactValue(key string, value interface{}) interface{} {
+	if key != "" && sensitiveKeyPattern.MatchString(key) {
+		switch value.(type) {
+		case yaml.MapSlice, map[string]interface{}:
+		default:
+			return redacted
+		}
+	}
+	envMap := slices.Contains(envKeys, key)
+	// the values of maps of commands, e.g. tasks, are commands too
+	command := slices.Contains(commandKeys, key)
+
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		out := make(yaml.MapSlice, len(v))
+		for i, item := range v {
+			k := fmt.Sprint(item.Key)
+			if envMap {
+				out[i] = yaml.MapItem{Key: item.Key, Value: redacted}
+				continue
+			}
+			if command {
+				k = key
+			}
+			out[i] = yaml.MapItem{Key: item.Key, Value: redactValue(k, item.Value)}
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			if envMap {
+				out[k] = redacted
+				continue
+			}
+			itemKey := k
+			if command {
+				itemKey = key
+			}
+			out[k] = redactValue(itemKey, item)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, item := range v {
+			// the items of lists are redacted like their list, e.g. the
+			// arguments of runArgs like commands
+			out[i] = redactValue(key, item)
+		}
+		return out
+	case string:
+		if command {
+			return redactCommand(v)
+		}
+		return redactURLCredentials(v)
+	}
+	return value
+}
+
+// redactCommand redacts the values of NAME=value arguments, e.g. variables
+// given to tape exec, and the credentials of URLs
+func redactCommand(command string) string {
+	return redactURLCredentials(commandVariablePattern.ReplaceAllString(command, "$1="+redacted))
+}
+
+// redactURLCredentials redacts the user info of URLs
+func redactURLCredentials(s string) string {
+	return urlCredentialsPattern.ReplaceAllString(s, "${1}"+redacted+"@")
+}
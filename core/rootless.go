@@ -0,0 +1,43 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// detectRootless reports whether the box's docker daemon runs rootless. A
+// daemon that can't be reached counts as rootful, up fails on it anyway.
+func detectRootless(boxConfig BoxConfig) bool {
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return false
+	}
+	defer cli.Close()
+	info, err := cli.Info(context.Background())
+	return err == nil && info.Rootless
+}
+
+// rootlessOverrides turns off updateRemoteUserUID unless the config sets it.
+// With rootless docker, root in the container is the host user, so the
+// workspace's files are owned by root in the container. Giving the remote
+// user the host user's UID, which maps to a subordinate UID on the host,
+// only makes them owned by someone else.
+func rootlessOverrides(config *devcontainer.DevContainerConfig) {
+	if config.UpdateRemoteUserUID == nil {
+		disabled := false
+		config.UpdateRemoteUserUID = &disabled
+	}
+}
+
+// rootlessAdvice explains how the workspace's files appear to a remote user
+// other than root with rootless docker, "" when it's root
+func rootlessAdvice(config *devcontainer.DevContainerConfig) string {
+	user := remoteUser(config)
+	if user == "root" {
+		return ""
+	}
+	return fmt.Sprintf("docker runs rootless, so the workspace's files are owned by root in the container and %s can't write them; "+
+		"set remoteUser to root, which is your host user, or give %s access with a post-create chown", user, user)
+}
@@ -0,0 +1,32 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestRootlessOverrides(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{}
+	rootlessOverrides(config)
+	if config.UpdateRemoteUserUID == nil || *config.UpdateRemoteUserUID {
+		t.Errorf("updateRemoteUserUID = %v, want false", config.UpdateRemoteUserUID)
+	}
+
+	// the config's own choice is kept
+	enabled := true
+	config = &devcontainer.DevContainerConfig{UpdateRemoteUserUID: &enabled}
+	rootlessOverrides(config)
+	if !*config.UpdateRemoteUserUID {
+		t.Error("updateRemoteUserUID = false, want the config's true")
+	}
+}
+
+func TestRootlessAdvice(t *testing.T) {
+	if advice := rootlessAdvice(&devcontainer.DevContainerConfig{ContainerUser: "root"}); advice != "" {
+		t.Errorf("rootlessAdvice() = %q, want none for root", advice)
+	}
+	if advice := rootlessAdvice(&devcontainer.DevContainerConfig{RemoteUser: "vscode"}); advice == "" {
+		t.Error("rootlessAdvice() = \"\", want advice for vscode")
+	}
+}
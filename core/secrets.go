@@ -0,0 +1,200 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// SecretProvider looks up secrets in a secret store. Providers are registered
+// for a scheme with RegisterSecretProvider, and resolve the references of
+// that scheme, e.g. ssm:///team/db-password.
+type SecretProvider interface {
+	// Resolve returns the secret for ref, the full reference including its scheme
+	Resolve(ctx context.Context, ref string) (string, error)
+}
+
+// SecretProviderFunc adapts a function to a SecretProvider
+type SecretProviderFunc func(ctx context.Context, ref string) (string, error)
+
+// Resolve calls f
+func (f SecretProviderFunc) Resolve(ctx context.Context, ref string) (string, error) {
+	return f(ctx, ref)
+}
+
+var (
+	secretProvidersMu sync.RWMutex
+	secretProviders   = map[string]SecretProvider{
+		"env":  SecretProviderFunc(resolveEnvSecret),
+		"file": SecretProviderFunc(resolveFileSecret),
+		"cmd":  SecretProviderFunc(resolveCommandSecret),
+		// the 1Password CLI reads its own op://vault/item/field references
+		"op":  commandSecretProvider{name: "op", args: func(ref string) []string { return []string{"read", "--no-newline", ref} }},
+		"ssm": commandSecretProvider{name: "aws", args: ssmArgs},
+	}
+)
+
+// RegisterSecretProvider makes secret references with the given scheme
+// resolve with provider, replacing any provider registered for it
+func RegisterSecretProvider(scheme string, provider SecretProvider) {
+	secretProvidersMu.Lock()
+	defer secretProvidersMu.Unlock()
+	secretProviders[scheme] = provider
+}
+
+// SecretSchemes returns the schemes with a registered provider
+func SecretSchemes() []string {
+	secretProvidersMu.RLock()
+	defer secretProvidersMu.RUnlock()
+	schemes := make([]string, 0, len(secretProviders))
+	for scheme := range secretProviders {
+		schemes = append(schemes, scheme)
+	}
+	slices.Sort(schemes)
+	return schemes
+}
+
+// ResolveSecret looks up a secret reference, scheme://path, with the provider
+// registered for its scheme
+func ResolveSecret(ctx context.Context, ref string) (string, error) {
+	scheme, _, found := strings.Cut(ref, "://")
+	if !found {
+		return "", fmt.Errorf("invalid secret reference %q, expected scheme://path", ref)
+	}
+
+	secretProvidersMu.RLock()
+	provider, ok := secretProviders[scheme]
+	secretProvidersMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q in %s, expected one of %v", ErrUnknownSecretScheme, scheme, ref, SecretSchemes())
+	}
+
+	secret, err := provider.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("error resolving secret %s: %w", ref, err)
+	}
+	return secret, nil
+}
+
+// resolveSecrets resolves the box's secrets by name
+func resolveSecrets(boxConfig BoxConfig) (map[string]string, error) {
+	ctx := context.Background()
+	secrets := make(map[string]string, len(boxConfig.Secrets))
+	for name, ref := range boxConfig.Secrets {
+		secret, err := ResolveSecret(ctx, ref)
+		if err != nil {
+			return nil, err
+		}
+		secrets[name] = secret
+	}
+	return secrets, nil
+}
+
+// secretsEnv returns secrets as KEY=VALUE variables, sorted by name
+func secretsEnv(secrets map[string]string) []string {
+	env := make([]string, 0, len(secrets))
+	for _, name := range slices.Sorted(maps.Keys(secrets)) {
+		env = append(env, name+"="+secrets[name])
+	}
+	return env
+}
+
+// writeSecretsFile writes secrets to a file only the user can read, in the
+// JSON format of the devcontainer CLI's --secrets-file, so they aren't on
+// its command line. cleanup removes the file.
+func writeSecretsFile(secrets map[string]string) (path string, cleanup func(), err error) {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return "", nil, fmt.Errorf("error serializing secrets: %v", err)
+	}
+	// CreateTemp creates the file with mode 0600
+	file, err := os.CreateTemp("", "tape-secrets-*.json")
+	if err != nil {
+		return "", nil, fmt.Errorf("error creating secrets file: %v", err)
+	}
+	cleanup = func() { os.Remove(file.Name()) }
+	_, err = file.Write(data)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("error writing secrets file: %v", err)
+	}
+	return file.Name(), cleanup, nil
+}
+
+// resolveEnvSecret reads env://NAME from the environment
+func resolveEnvSecret(ctx context.Context, ref string) (string, error) {
+	name := strings.TrimPrefix(ref, "env://")
+	secret, ok := os.LookupEnv(name)
+	if !ok {
+		return "", fmt.Errorf("%s is not set", name)
+	}
+	return secret, nil
+}
+
+// resolveFileSecret reads file:///path, or file://~/path relative to the
+// home directory, without a trailing newline
+func resolveFileSecret(ctx context.Context, ref string) (string, error) {
+	path := strings.TrimPrefix(ref, "file://")
+	if rest, found := strings.CutPrefix(path, "~/"); found {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(home, rest)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveCommandSecret runs cmd://command with the shell and returns its output
+func resolveCommandSecret(ctx context.Context, ref string) (string, error) {
+	return runSecretCommand(ctx, "/bin/sh", "-c", strings.TrimPrefix(ref, "cmd://"))
+}
+
+// commandSecretProvider resolves secrets with a store's CLI
+type commandSecretProvider struct {
+	name string
+	args func(ref string) []string
+}
+
+func (p commandSecretProvider) Resolve(ctx context.Context, ref string) (string, error) {
+	if _, err := exec.LookPath(p.name); err != nil {
+		return "", fmt.Errorf("%s is not installed", p.name)
+	}
+	return runSecretCommand(ctx, p.name, p.args(ref)...)
+}
+
+// ssmArgs looks up ssm://name, or ssm:///path/name, in AWS Systems Manager
+// Parameter Store with the aws CLI's default credentials and region
+func ssmArgs(ref string) []string {
+	name := strings.TrimPrefix(ref, "ssm://")
+	return []string{"ssm", "get-parameter", "--name", name, "--with-decryption", "--query", "Parameter.Value", "--output", "text"}
+}
+
+func runSecretCommand(ctx context.Context, name string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%v: %s", err, msg)
+		}
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
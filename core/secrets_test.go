@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("TAPE_TEST_SECRET", "from-env")
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	RegisterSecretProvider("test", SecretProviderFunc(func(ctx context.Context, ref string) (string, error) {
+		return "resolved " + ref, nil
+	}))
+
+	tests := []struct {
+		name     string
+		ref      string
+		expected string
+		wantErr  bool
+	}{
+		{name: "env", ref: "env://TAPE_TEST_SECRET", expected: "from-env"},
+		{name: "unset env", ref: "env://TAPE_TEST_UNSET", wantErr: true},
+		{name: "file", ref: "file://" + path, expected: "from-file"},
+		{name: "command", ref: "cmd://printf 'from command\\n'", expected: "from command"},
+		{name: "failing command", ref: "cmd://exit 1", wantErr: true},
+		{name: "registered provider", ref: "test://a/b", expected: "resolved test://a/b"},
+		{name: "unknown scheme", ref: "vault://a/b", wantErr: true},
+		{name: "no scheme", ref: "TAPE_TEST_SECRET", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ResolveSecret(context.Background(), tt.ref)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.expected {
+				t.Errorf("ResolveSecret() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	if _, err := ResolveSecret(context.Background(), "vault://a/b"); !errors.Is(err, ErrUnknownSecretScheme) {
+		t.Errorf("ResolveSecret() error = %v, want ErrUnknownSecretScheme", err)
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	t.Setenv("TAPE_TEST_TOKEN", "abc")
+	boxConfig := BoxConfig{Secrets: map[string]string{
+		"TOKEN":  "env://TAPE_TEST_TOKEN",
+		"API_ID": "cmd://echo 42",
+	}}
+
+	got, err := resolveSecrets(boxConfig)
+	if err != nil {
+		t.Fatalf("resolveSecrets() error = %v", err)
+	}
+	expected := map[string]string{"API_ID": "42", "TOKEN": "abc"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("resolveSecrets() = %v, want %v", got, expected)
+	}
+	if env := secretsEnv(got); !reflect.DeepEqual(env, []string{"API_ID=42", "TOKEN=abc"}) {
+		t.Errorf("secretsEnv() = %v, want [API_ID=42 TOKEN=abc]", env)
+	}
+}
+
+func TestSecretsStayOffCommandLine(t *testing.T) {
+	// a fake devcontainer CLI records its arguments and the secrets file it's given
+	dir := t.TempDir()
+	binary := filepath.Join(dir, "devcontainer")
+	script := `#!/bin/sh
+echo "$@" > "$TAPE_TEST_DIR/args"
+while [ $# -gt 0 ]; do
+	if [ "$1" = --secrets-file ]; then
+		echo "$2" > "$TAPE_TEST_DIR/path"
+		cat "$2" > "$TAPE_TEST_DIR/secrets"
+		ls -l "$2" | cut -c1-10 > "$TAPE_TEST_DIR/mode"
+	fi
+	shift
+done
+`
+	if err := os.WriteFile(binary, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("TAPE_TEST_DIR", dir)
+
+	dc := &DevcontainerCommand{
+		BoxConfig:      BoxConfig{Workspace: dir},
+		Command:        "up",
+		AdditionalArgs: []string{"--remove-existing-container"},
+		Secrets:        map[string]string{"TOKEN": "s3cret-value"},
+	}
+	if err := (localBinaryStrategy{binary: binary}).run(dc, nil, nil, &container.DockerConfig{}); err != nil {
+		t.Fatalf("run() error = %v", err)
+	}
+
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("the CLI didn't get a secrets file: %v", err)
+		}
+		return strings.TrimSpace(string(data))
+	}
+	if args := read("args"); strings.Contains(args, "s3cret-value") {
+		t.Errorf("the CLI's arguments %q contain the secret", args)
+	}
+	if secrets := read("secrets"); secrets != `{"TOKEN":"s3cret-value"}` {
+		t.Errorf("secrets file = %s, want the secrets as JSON", secrets)
+	}
+	if mode := read("mode"); mode != "-rw-------" {
+		t.Errorf("secrets file mode = %s, want -rw-------", mode)
+	}
+	if _, err := os.Stat(read("path")); !os.IsNotExist(err) {
+		t.Errorf("secrets file wasn't removed, stat error = %v", err)
+	}
+}
+
+func TestSSMArgs(t *testing.T) {
+	expected := []string{"ssm", "get-parameter", "--name", "/team/db-password", "--with-decryption", "--query", "Parameter.Value", "--output", "text"}
+	if got := ssmArgs("ssm:///team/db-password"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("ssmArgs() = %v, want %v", got, expected)
+	}
+}
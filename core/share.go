@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"gopkg.in/yaml.v2"
+)
+
+// Share authorizes a teammate's public keys to connect to an environment
+// over tape's SSH server
+type Share struct {
+	// Source is where the keys came from, github:<user>, gitlab:<user> or a
+	// public key file, and names the share
+	Source string `yaml:"source"`
+	// Keys are the public keys, in authorized_keys format
+	Keys  []string  `yaml:"keys"`
+	Added time.Time `yaml:"added"`
+}
+
+// keyHosts are the hosts serving users' public keys at /<user>.keys, by the
+// prefix of share sources
+var keyHosts = map[string]string{
+	"github": "https://github.com",
+	"gitlab": "https://gitlab.com",
+}
+
+var keysClient = &http.Client{Timeout: 10 * time.Second}
+
+// sharesPath is the file recording the shares of each environment
+func sharesPath() string {
+	return filepath.Join(ConfigDir, ".shares.yml")
+}
+
+func loadShares() (map[string][]Share, error) {
+	shares := map[string][]Share{}
+
+	data, err := os.ReadFile(sharesPath())
+	if os.IsNotExist(err) {
+		return shares, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", sharesPath(), err)
+	}
+
+	if err := yaml.Unmarshal(data, &shares); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", sharesPath(), err)
+	}
+	return shares, nil
+}
+
+func saveShares(shares map[string][]Share) error {
+	data, err := yaml.Marshal(shares)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(ConfigDir, 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(sharesPath(), data, 0600)
+}
+
+// ShareBox authorizes the keys of source for the environment, replacing the
+// keys an earlier share of the same source authorized
+func ShareBox(envName string, source string) (*Share, error) {
+	if _, err := LoadBoxConfig(envName); err != nil {
+		return nil, err
+	}
+
+	data, err := readShareKeys(source)
+	if err != nil {
+		return nil, fmt.Errorf("error reading keys of %s: %w", source, err)
+	}
+	keys, err := parseAuthorizedKeys(data)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing keys of %s: %w", source, err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s has no public keys", source)
+	}
+
+	shares, err := loadShares()
+	if err != nil {
+		return nil, err
+	}
+	share := Share{Source: source, Keys: keys, Added: time.Now()}
+	envShares := slices.DeleteFunc(shares[envName], func(s Share) bool { return s.Source == source })
+	shares[envName] = append(envShares, share)
+	if err := saveShares(shares); err != nil {
+		return nil, err
+	}
+	return &share, nil
+}
+
+// RevokeShare removes the keys source was authorized with from the environment
+func RevokeShare(envName string, source string) error {
+	shares, err := loadShares()
+	if err != nil {
+		return err
+	}
+	envShares := shares[envName]
+	i := slices.IndexFunc(envShares, func(s Share) bool { return s.Source == source })
+	if i < 0 {
+		return fmt.Errorf("%s isn't shared with %s", envName, source)
+	}
+	shares[envName] = slices.Delete(envShares, i, i+1)
+	if len(shares[envName]) == 0 {
+		delete(shares, envName)
+	}
+	return saveShares(shares)
+}
+
+// ListShares returns the environment's shares
+func ListShares(envName string) ([]Share, error) {
+	shares, err := loadShares()
+	if err != nil {
+		return nil, err
+	}
+	return shares[envName], nil
+}
+
+// HasShares reports whether any environment is shared
+func HasShares() (bool, error) {
+	shares, err := loadShares()
+	if err != nil {
+		return false, err
+	}
+	for _, envShares := range shares {
+		if len(envShares) > 0 {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// AuthorizedShare returns the environment's share authorizing the key, or
+// nil when none does
+func AuthorizedShare(envName string, key ssh.PublicKey) (*Share, error) {
+	envShares, err := ListShares(envName)
+	if err != nil {
+		return nil, err
+	}
+	marshaled := key.Marshal()
+	for _, share := range envShares {
+		for _, line := range share.Keys {
+			shared, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+			if err == nil && bytes.Equal(shared.Marshal(), marshaled) {
+				return &share, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+// readShareKeys returns the public keys of a share source: the keys a user
+// published on GitHub or GitLab, or the content of a public key file
+func readShareKeys(source string) ([]byte, error) {
+	prefix, user, found := strings.Cut(source, ":")
+	host, ok := keyHosts[prefix]
+	if !found || !ok {
+		return os.ReadFile(source)
+	}
+	if user == "" || strings.ContainsAny(user, "/?#") {
+		return nil, fmt.Errorf("invalid user %q", user)
+	}
+	if Offline {
+		return nil, fmt.Errorf("%w: fetching keys from %s", ErrOffline, host)
+	}
+
+	url := fmt.Sprintf("%s/%s.keys", host, user)
+	resp, err := keysClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseAuthorizedKeys returns the keys of authorized_keys formatted data,
+// one per line, normalized without options or comments
+func parseAuthorizedKeys(data []byte) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(line))
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key))))
+	}
+	return keys, scanner.Err()
+}
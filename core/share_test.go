@@ -0,0 +1,104 @@
+package core
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func generatePublicKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key, err := ssh.NewPublicKey(public)
+	if err != nil {
+		t.Fatalf("Failed to convert key: %v", err)
+	}
+	return key
+}
+
+func TestShareBox(t *testing.T) {
+	setupConfigDir(t, map[string]string{"app.yml": "workspace: /src/app\n"})
+
+	alice := generatePublicKey(t)
+	other := generatePublicKey(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/alice.keys" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(ssh.MarshalAuthorizedKey(alice))
+	}))
+	defer server.Close()
+	original := keyHosts
+	keyHosts = map[string]string{"github": server.URL}
+	t.Cleanup(func() { keyHosts = original })
+
+	if shared, err := HasShares(); err != nil || shared {
+		t.Errorf("HasShares() before sharing = %v, %v, expected false", shared, err)
+	}
+	share, err := ShareBox("app", "github:alice")
+	if err != nil {
+		t.Fatalf("ShareBox() error = %v", err)
+	}
+	if len(share.Keys) != 1 {
+		t.Fatalf("ShareBox() keys = %v, expected 1", share.Keys)
+	}
+	if shared, err := HasShares(); err != nil || !shared {
+		t.Errorf("HasShares() after sharing = %v, %v, expected true", shared, err)
+	}
+
+	if _, err := ShareBox("app", "github:bob"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("ShareBox() of a missing user error = %v, expected a 404", err)
+	}
+	if _, err := ShareBox("missing", "github:alice"); err == nil {
+		t.Error("ShareBox() of a missing environment should fail")
+	}
+
+	if got, err := AuthorizedShare("app", alice); err != nil || got == nil || got.Source != "github:alice" {
+		t.Errorf("AuthorizedShare(alice) = %v, %v, expected github:alice", got, err)
+	}
+	if got, err := AuthorizedShare("app", other); err != nil || got != nil {
+		t.Errorf("AuthorizedShare(other) = %v, %v, expected none", got, err)
+	}
+	if got, err := AuthorizedShare("web", alice); err != nil || got != nil {
+		t.Errorf("AuthorizedShare() of another environment = %v, %v, expected none", got, err)
+	}
+
+	if err := RevokeShare("app", "github:alice"); err != nil {
+		t.Fatalf("RevokeShare() error = %v", err)
+	}
+	if got, err := AuthorizedShare("app", alice); err != nil || got != nil {
+		t.Errorf("AuthorizedShare() after revoking = %v, %v, expected none", got, err)
+	}
+	if shared, err := HasShares(); err != nil || shared {
+		t.Errorf("HasShares() after revoking = %v, %v, expected false", shared, err)
+	}
+	if err := RevokeShare("app", "github:alice"); err == nil {
+		t.Error("RevokeShare() of a revoked share should fail")
+	}
+}
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	key := generatePublicKey(t)
+	line := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+
+	keys, err := parseAuthorizedKeys([]byte("# keys\n\n" + line + " alice@laptop\n"))
+	if err != nil {
+		t.Fatalf("parseAuthorizedKeys() error = %v", err)
+	}
+	if len(keys) != 1 || keys[0] != line {
+		t.Errorf("parseAuthorizedKeys() = %q, expected %q", keys, line)
+	}
+
+	if _, err := parseAuthorizedKeys([]byte("not a key\n")); err == nil {
+		t.Error("parseAuthorizedKeys() of an invalid key should fail")
+	}
+}
@@ -0,0 +1,118 @@
+package core
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// shimMarker starts the line of a shim script naming its environment
+const shimMarker = "# tape shim for "
+
+// Shim is a script in BinDir that runs a command inside an environment
+type Shim struct {
+	Command string
+	EnvName string
+	Path    string
+}
+
+// shimScript returns a POSIX shell script that runs command in the
+// environment through tape exec, passing along its arguments
+func shimScript(envName string, command string, executable string) string {
+	return fmt.Sprintf("#!/bin/sh\n%s%s\nexec %s exec %s -- %s \"$@\"\n",
+		shimMarker, envName, devcontainer.ShellQuote(executable), devcontainer.ShellQuote(envName), devcontainer.ShellQuote(command))
+}
+
+// AddShim installs a shim in BinDir so typing command on the host runs it in
+// the environment. executable is the tape binary the shim calls. An existing
+// shim for the same command is replaced, but other files are left alone.
+func AddShim(envName string, command string, executable string) (*Shim, error) {
+	if _, err := LoadBoxConfig(envName); err != nil {
+		return nil, err
+	}
+	if command == "" || strings.ContainsAny(command, `/\`) || strings.HasPrefix(command, ".") {
+		return nil, fmt.Errorf("invalid command name %s", command)
+	}
+
+	path := filepath.Join(BinDir(), command)
+	if _, err := os.Stat(path); err == nil {
+		if _, err := readShim(path); err != nil {
+			return nil, fmt.Errorf("%s already exists and is not a tape shim", path)
+		}
+	}
+
+	if err := os.MkdirAll(BinDir(), 0755); err != nil {
+		return nil, fmt.Errorf("error creating %s: %v", BinDir(), err)
+	}
+	if err := os.WriteFile(path, []byte(shimScript(envName, command, executable)), 0755); err != nil {
+		return nil, fmt.Errorf("error writing shim: %v", err)
+	}
+
+	return &Shim{Command: command, EnvName: envName, Path: path}, nil
+}
+
+// RemoveShim removes the shim for command from BinDir
+func RemoveShim(command string) error {
+	path := filepath.Join(BinDir(), filepath.Base(command))
+	if _, err := readShim(path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("error removing shim: %v", err)
+	}
+	return nil
+}
+
+// ListShims returns the shims in BinDir, sorted by command
+func ListShims() ([]Shim, error) {
+	entries, err := os.ReadDir(BinDir())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %v", BinDir(), err)
+	}
+
+	var shims []Shim
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		shim, err := readShim(filepath.Join(BinDir(), entry.Name()))
+		if err != nil {
+			// not every file in BinDir has to be a shim
+			continue
+		}
+		shims = append(shims, *shim)
+	}
+
+	sort.Slice(shims, func(i, j int) bool {
+		return shims[i].Command < shims[j].Command
+	})
+	return shims, nil
+}
+
+// readShim parses the shim at path, returning an error if it isn't one
+func readShim(path string) (*Shim, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no shim %s", filepath.Base(path))
+		}
+		return nil, fmt.Errorf("error reading shim: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for i := 0; i < 2 && scanner.Scan(); i++ {
+		if envName, found := strings.CutPrefix(scanner.Text(), shimMarker); found {
+			return &Shim{Command: filepath.Base(path), EnvName: envName, Path: path}, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is not a tape shim", path)
+}
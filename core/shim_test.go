@@ -0,0 +1,66 @@
+package core
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShims(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		"web.yml":     "workspace: /src/web\n",
+		"bin/mytool":  "#!/bin/sh\necho not a shim\n",
+		"bin/.hidden": "",
+	})
+
+	// a fake tape that prints its arguments, one per line
+	fakeTape := filepath.Join(t.TempDir(), "tape")
+	if err := os.WriteFile(fakeTape, []byte("#!/bin/sh\nprintf '%s\\n' \"$@\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	shim, err := AddShim("web", "npm", fakeTape)
+	if err != nil {
+		t.Fatalf("AddShim() error = %v", err)
+	}
+
+	out, err := exec.Command(shim.Path, "run", "it's").Output()
+	if err != nil {
+		t.Fatalf("running shim error = %v", err)
+	}
+	expected := []string{"exec", "web", "--", "npm", "run", "it's"}
+	if got := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("shim ran tape with %q, want %q", got, expected)
+	}
+
+	if _, err := AddShim("web", "mytool", fakeTape); err == nil {
+		t.Errorf("AddShim() replaced a file that is not a shim")
+	}
+	if _, err := AddShim("missing", "go", fakeTape); err == nil {
+		t.Errorf("AddShim() accepted an unknown environment")
+	}
+	if _, err := AddShim("web", "../go", fakeTape); err == nil {
+		t.Errorf("AddShim() accepted a path as the command")
+	}
+
+	shims, err := ListShims()
+	if err != nil {
+		t.Fatalf("ListShims() error = %v", err)
+	}
+	if len(shims) != 1 || shims[0].Command != "npm" || shims[0].EnvName != "web" {
+		t.Errorf("ListShims() = %+v, want the npm shim", shims)
+	}
+
+	if err := RemoveShim("mytool"); err == nil {
+		t.Errorf("RemoveShim() removed a file that is not a shim")
+	}
+	if err := RemoveShim("npm"); err != nil {
+		t.Fatalf("RemoveShim() error = %v", err)
+	}
+	if _, err := os.Stat(shim.Path); !os.IsNotExist(err) {
+		t.Errorf("RemoveShim() left %s", shim.Path)
+	}
+}
@@ -0,0 +1,297 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+const SnapshotLabel = "tape.snapshot" // used to label images created by tape snapshot
+
+const snapshotManifestFile = "snapshot.json"
+
+type SnapshotVolume struct {
+	Name        string `json:"name"`
+	Destination string `json:"destination"`
+	Archive     string `json:"archive"`
+}
+
+type Snapshot struct {
+	EnvName   string           `json:"env"`
+	Tag       string           `json:"tag"`
+	Image     string           `json:"image"`
+	CreatedAt time.Time        `json:"createdAt"`
+	Volumes   []SnapshotVolume `json:"volumes,omitempty"`
+}
+
+// SnapshotImage returns the image reference a box snapshot is committed to
+func SnapshotImage(envName string, tag string) string {
+	return fmt.Sprintf("tape-snapshot/%s:%s", strings.ToLower(envName), tag)
+}
+
+// snapshotTagPattern is docker's grammar for tags, which also keeps them
+// safe to use as directory names
+var snapshotTagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// snapshotDir returns the directory holding a snapshot's manifest and volume
+// archives, failing for tags that aren't valid docker tags
+func snapshotDir(envName string, tag string) (string, error) {
+	if !snapshotTagPattern.MatchString(tag) {
+		return "", fmt.Errorf("invalid snapshot tag %q, expected up to 128 letters, digits, _, . and -, not starting with . or -", tag)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(envName)) {
+		return "", fmt.Errorf("invalid environment name %s", envName)
+	}
+	return filepath.Join(ConfigDir, ".snapshots", envName, tag), nil
+}
+
+// CreateSnapshot commits the box's container to an image and archives its named volumes
+func CreateSnapshot(envName string, tag string) (_ *Snapshot, err error) {
+	defer func() {
+		recordEvent(envName, "snapshot", tag, err)
+	}()
+
+	dir, err := snapshotDir(envName, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+
+	inspect, err := cli.InspectContainer(ctx, dc.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error inspecting container: %v", err)
+	}
+
+	snapshot := &Snapshot{
+		EnvName:   envName,
+		Tag:       tag,
+		Image:     SnapshotImage(envName, tag),
+		CreatedAt: time.Now(),
+	}
+
+	_, err = cli.CommitContainer(ctx, dc.ID, snapshot.Image, map[string]string{SnapshotLabel: envName})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("error creating snapshot directory: %v", err)
+	}
+	// a snapshot without its manifest can't be listed or removed
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+
+	for _, m := range inspect.Mounts {
+		if m.Type != container.MountTypeVolume {
+			continue
+		}
+
+		archive := m.Name + ".tar"
+		if err := archiveVolume(ctx, dc, m.Destination, filepath.Join(dir, archive)); err != nil {
+			return nil, err
+		}
+
+		snapshot.Volumes = append(snapshot.Volumes, SnapshotVolume{
+			Name:        m.Name,
+			Destination: m.Destination,
+			Archive:     archive,
+		})
+	}
+
+	manifest, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("error serializing snapshot: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, snapshotManifestFile), manifest, 0644); err != nil {
+		return nil, fmt.Errorf("error writing snapshot: %v", err)
+	}
+
+	return snapshot, nil
+}
+
+func archiveVolume(ctx context.Context, dc *container.Container, path string, archivePath string) error {
+	reader, err := dc.CopyFrom(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(archivePath)
+	if err != nil {
+		return fmt.Errorf("error creating volume archive: %v", err)
+	}
+	defer file.Close()
+
+	if _, err := file.ReadFrom(reader); err != nil {
+		return fmt.Errorf("error writing volume archive: %v", err)
+	}
+	return nil
+}
+
+// LoadSnapshot reads a snapshot's manifest
+func LoadSnapshot(envName string, tag string) (*Snapshot, error) {
+	dir, err := snapshotDir(envName, tag)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, snapshotManifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("no snapshot %s for %s", tag, envName)
+		}
+		return nil, fmt.Errorf("error reading snapshot: %v", err)
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("error parsing snapshot: %v", err)
+	}
+	return &snapshot, nil
+}
+
+// RestoreSnapshot recreates the box's container from a snapshot image and
+// copies the archived volume contents back in
+func RestoreSnapshot(envName string, tag string) (err error) {
+	defer func() {
+		recordEvent(envName, "restore", tag, err)
+	}()
+
+	snapshot, err := LoadSnapshot(envName, tag)
+	if err != nil {
+		return err
+	}
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	devCmd := DevcontainerCommand{
+		BoxConfig:      *boxConfig,
+		Command:        "up",
+		AdditionalArgs: []string{"--remove-existing-container"},
+		Image:          snapshot.Image,
+		// the snapshot already contains the results of the create commands
+		SkipCreateCommands: true,
+	}
+	if err := devCmd.Execute(); err != nil {
+		return err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	// LoadSnapshot validated the tag
+	dir, _ := snapshotDir(envName, tag)
+	for _, volume := range snapshot.Volumes {
+		file, err := os.Open(filepath.Join(dir, volume.Archive))
+		if err != nil {
+			return fmt.Errorf("error reading volume archive: %v", err)
+		}
+
+		// the archive's root entry is the volume's mount point, so extract into its parent
+		err = dc.CopyTo(ctx, path.Dir(volume.Destination), file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListSnapshots returns the box's snapshots, newest first
+func ListSnapshots(envName string) ([]Snapshot, error) {
+	entries, err := os.ReadDir(filepath.Join(ConfigDir, ".snapshots", envName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading snapshots: %v", err)
+	}
+
+	var snapshots []Snapshot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		snapshot, err := LoadSnapshot(envName, entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, *snapshot)
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedAt.After(snapshots[j].CreatedAt)
+	})
+	return snapshots, nil
+}
+
+// RemoveSnapshot removes a snapshot's image and its volume archives
+func RemoveSnapshot(envName string, tag string) error {
+	snapshot, err := LoadSnapshot(envName, tag)
+	if err != nil {
+		return err
+	}
+
+	var cli container.Backend
+	if boxConfig, err := LoadBoxConfig(envName); err == nil {
+		cli, err = newBoxClient(*boxConfig)
+		if err != nil {
+			return fmt.Errorf("error creating container client: %v", err)
+		}
+	} else {
+		// the environment was deleted, its snapshots are on the default host
+		cli, err = newDefaultClient()
+		if err != nil {
+			return err
+		}
+	}
+	defer cli.Close()
+
+	err = cli.RemoveImage(context.Background(), snapshot.Image)
+	if err != nil && !errors.Is(err, container.ErrImageNotFound) {
+		return fmt.Errorf("error removing snapshot image: %w", err)
+	}
+
+	// LoadSnapshot validated the tag
+	dir, _ := snapshotDir(envName, tag)
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("error removing snapshot directory: %v", err)
+	}
+	return nil
+}
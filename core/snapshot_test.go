@@ -0,0 +1,49 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotDir(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	tests := []struct {
+		tag     string
+		wantErr bool
+	}{
+		{tag: "latest"},
+		{tag: "before-upgrade.2"},
+		{tag: "_tmp"},
+		{tag: "", wantErr: true},
+		{tag: "..", wantErr: true},
+		{tag: "../../..", wantErr: true},
+		{tag: "a/b", wantErr: true},
+		{tag: ".hidden", wantErr: true},
+		{tag: "-flag", wantErr: true},
+		{tag: "has space", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tag, func(t *testing.T) {
+			dir, err := snapshotDir("app", tt.tag)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("snapshotDir(%q) error = %v, wantErr %v", tt.tag, err, tt.wantErr)
+			}
+			if err == nil && dir != filepath.Join(ConfigDir, ".snapshots", "app", tt.tag) {
+				t.Errorf("snapshotDir(%q) = %s", tt.tag, dir)
+			}
+		})
+	}
+}
+
+func TestRemoveSnapshotRejectsTraversal(t *testing.T) {
+	setupConfigDir(t, map[string]string{"keep/file": "data"})
+
+	if err := RemoveSnapshot("app", "../../.."); err == nil {
+		t.Fatalf("RemoveSnapshot() error = nil, want an invalid tag error")
+	}
+	if _, err := os.Stat(filepath.Join(ConfigDir, "keep", "file")); err != nil {
+		t.Errorf("RemoveSnapshot() removed files outside the snapshot: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// DockerSocketProxyImage filters the docker API the devcontainer CLI's
+// container reaches, see GlobalConfig.DockerSocketProxy
+var DockerSocketProxyImage = "tecnativa/docker-socket-proxy:0.3"
+
+// DockerSocketProxyLabel labels the docker socket proxy's container
+const DockerSocketProxyLabel = "tape.docker-socket-proxy"
+
+// dockerSocketProxyName names the proxy's container and the network the
+// devcontainer CLI's container reaches it on
+const dockerSocketProxyName = "tape-docker-socket-proxy"
+
+// dockerSocketProxyHost is the DOCKER_HOST of the devcontainer CLI's
+// container when it goes through the proxy
+const dockerSocketProxyHost = "tcp://" + dockerSocketProxyName + ":2375"
+
+// dockerSocketProxyEnv enables the API sections the devcontainer CLI uses:
+// building and pulling images, creating, starting and inspecting containers,
+// running the lifecycle commands with exec, and the networks and volumes
+// configs mount or join. The rest of the API, e.g. swarm, plugins and
+// secrets, is denied. Creating containers and exec are enough to get root on
+// the host, the proxy only keeps a compromised CLI from the rest.
+var dockerSocketProxyEnv = []string{
+	"POST=1",
+	"BUILD=1",
+	"GRPC=1",
+	"SESSION=1",
+	"CONTAINERS=1",
+	"ALLOW_START=1",
+	"ALLOW_STOP=1",
+	"EXEC=1",
+	"IMAGES=1",
+	"DISTRIBUTION=1",
+	"INFO=1",
+	"NETWORKS=1",
+	"VOLUMES=1",
+}
+
+// ensureDockerSocketProxy starts the docker socket proxy unless it's running,
+// and returns the network the devcontainer CLI's container joins to reach
+// it. The proxy is shared by all boxes and keeps running.
+func ensureDockerSocketProxy(ctx context.Context, cli container.Backend, boxConfig BoxConfig) (string, error) {
+	if _, err := EnsureNetwork(dockerSocketProxyName); err != nil {
+		return "", fmt.Errorf("error creating network %s: %w", dockerSocketProxyName, err)
+	}
+
+	proxies, err := cli.FindContainers(ctx, []string{DockerSocketProxyLabel})
+	if err != nil && !container.IsContainerNotFound(err) {
+		return "", err
+	}
+	if len(proxies) > 0 {
+		if proxies[0].State != container.StateRunning {
+			if err := cli.StartContainer(ctx, proxies[0].ID); err != nil {
+				return "", fmt.Errorf("error starting docker socket proxy: %w", err)
+			}
+		}
+		return dockerSocketProxyName, nil
+	}
+
+	exists, err := cli.HasImage(ctx, DockerSocketProxyImage)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if err := pullImage(ctx, cli, boxConfig, DockerSocketProxyImage); err != nil {
+			return "", err
+		}
+	}
+	proxy, err := cli.CreateContainer(ctx, container.ContainerConfig{
+		Image:   DockerSocketProxyImage,
+		Name:    dockerSocketProxyName,
+		Binds:   []string{"/var/run/docker.sock:/var/run/docker.sock:ro"},
+		Env:     dockerSocketProxyEnv,
+		Labels:  map[string]string{DockerSocketProxyLabel: "true"},
+		Network: dockerSocketProxyName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("error creating docker socket proxy: %w", err)
+	}
+	if err := cli.StartContainer(ctx, proxy.ID); err != nil {
+		cli.RemoveContainer(ctx, proxy.ID)
+		return "", fmt.Errorf("error starting docker socket proxy: %w", err)
+	}
+	return dockerSocketProxyName, nil
+}
@@ -0,0 +1,220 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// stateCache holds box summaries so listing many boxes doesn't list the
+// containers again for each box. The daemon keeps it in memory, invalidated
+// by container events, see WatchBoxStates. The CLI can keep it on disk for
+// the global config's state-cache-ttl, invalidated by tape's own operations.
+type stateCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedState
+	// host is the docker host whose events invalidate the entries, and
+	// watching is set while they do
+	host     string
+	watching bool
+}
+
+// cachedState is a box's summary as it was at Time
+type cachedState struct {
+	Summary BoxSummary `json:"summary"`
+	Time    time.Time  `json:"time"`
+	// ConfigModTime is the box config's modification time, an edited config
+	// may match other containers
+	ConfigModTime time.Time `json:"config-mod-time"`
+}
+
+var boxStates = &stateCache{}
+
+// stateCachePath is the CLI's state cache
+func stateCachePath() string {
+	return filepath.Join(ConfigDir, ".state-cache.json")
+}
+
+// cachedBoxSummary returns the box's cached summary, if it's still valid
+func cachedBoxSummary(boxConfig BoxConfig) (*BoxSummary, bool) {
+	modTime := boxConfigModTime(boxConfig.Name)
+
+	boxStates.mu.Lock()
+	defer boxStates.mu.Unlock()
+	if boxStates.watching {
+		entry, ok := boxStates.entries[boxConfig.Name]
+		if !ok || boxConfig.DockerHost != boxStates.host || !entry.ConfigModTime.Equal(modTime) {
+			return nil, false
+		}
+		return &entry.Summary, true
+	}
+
+	ttl := stateCacheTTL()
+	if ttl == 0 {
+		return nil, false
+	}
+	entry, ok := loadStateCache()[boxConfig.Name]
+	if !ok || time.Since(entry.Time) >= ttl || !entry.ConfigModTime.Equal(modTime) {
+		return nil, false
+	}
+	return &entry.Summary, true
+}
+
+// cacheBoxSummary caches the box's summary where cachedBoxSummary looks for it
+func cacheBoxSummary(boxConfig BoxConfig, summary *BoxSummary) {
+	entry := cachedState{Summary: *summary, Time: time.Now(), ConfigModTime: boxConfigModTime(boxConfig.Name)}
+
+	boxStates.mu.Lock()
+	defer boxStates.mu.Unlock()
+	if boxStates.watching {
+		if boxConfig.DockerHost == boxStates.host {
+			boxStates.entries[boxConfig.Name] = entry
+		}
+		return
+	}
+
+	if stateCacheTTL() == 0 {
+		return
+	}
+	entries := loadStateCache()
+	entries[boxConfig.Name] = entry
+	saveStateCache(entries)
+}
+
+// forgetBoxState drops the box's cached summary, after an operation that
+// changes its container
+func forgetBoxState(envName string) {
+	boxStates.mu.Lock()
+	defer boxStates.mu.Unlock()
+	delete(boxStates.entries, envName)
+
+	entries := loadStateCache()
+	if _, ok := entries[envName]; ok {
+		delete(entries, envName)
+		saveStateCache(entries)
+	}
+}
+
+// WatchBoxStates keeps the box summaries in memory, for the boxes on the
+// default docker host, dropping them as their containers' events arrive,
+// until ctx is cancelled. Nothing is cached once it returns.
+func WatchBoxStates(ctx context.Context) error {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	boxStates.mu.Lock()
+	boxStates.entries = map[string]cachedState{}
+	boxStates.host = globalConfig.DockerHost
+	boxStates.watching = true
+	boxStates.mu.Unlock()
+	defer func() {
+		boxStates.mu.Lock()
+		boxStates.entries = nil
+		boxStates.watching = false
+		boxStates.mu.Unlock()
+	}()
+
+	return WatchBoxEvents(ctx, func(container.Event) error { return nil })
+}
+
+// WatchBoxEvents calls fn with the events of the devcontainers on the
+// default docker host until ctx is cancelled or fn returns an error. The
+// states the event may have changed are dropped from the cache before.
+func WatchBoxEvents(ctx context.Context, fn func(container.Event) error) error {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+	cli, err := newBackend(globalConfig, globalConfig.DockerHost)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	// every devcontainer has the host folder label, tape's have the env label too
+	return cli.ContainerEvents(ctx, []string{HostFolderLabel}, func(event container.Event) error {
+		forgetEventStates(event)
+		return fn(event)
+	})
+}
+
+// forgetEventStates drops the cached states an event may have changed
+func forgetEventStates(event container.Event) {
+	boxStates.mu.Lock()
+	defer boxStates.mu.Unlock()
+	invalidateStates(boxStates.entries, event)
+
+	entries := loadStateCache()
+	if len(entries) > 0 {
+		invalidateStates(entries, event)
+		saveStateCache(entries)
+	}
+}
+
+// invalidateStates drops the entries an event may have changed: its box's,
+// or all of them for containers tape didn't label, which boxes only find by
+// their workspace
+func invalidateStates(entries map[string]cachedState, event container.Event) {
+	if envName, ok := event.Labels[EnvLabel]; ok {
+		delete(entries, envName)
+		return
+	}
+	clear(entries)
+}
+
+// stateCacheTTL returns how long the CLI caches box summaries on disk, 0
+// when it doesn't
+func stateCacheTTL() time.Duration {
+	globalConfig, err := LoadGlobalConfig()
+	if err != nil {
+		return 0
+	}
+	return globalConfig.StateCacheTTLDuration()
+}
+
+// boxConfigModTime returns the modification time of the box's config file
+func boxConfigModTime(envName string) time.Time {
+	info, err := os.Stat(BoxConfigPath(envName))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// loadStateCache reads the CLI's state cache. The cache is best effort, an
+// unreadable one is empty.
+func loadStateCache() map[string]cachedState {
+	entries := map[string]cachedState{}
+	data, err := os.ReadFile(stateCachePath())
+	if err != nil {
+		return entries
+	}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return map[string]cachedState{}
+	}
+	return entries
+}
+
+// saveStateCache writes the CLI's state cache, replacing the file so
+// concurrent tape processes never read half of it
+func saveStateCache(entries map[string]cachedState) {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return
+	}
+	tmp := stateCachePath() + fmt.Sprintf(".%d", os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmp, stateCachePath()); err != nil {
+		os.Remove(tmp)
+	}
+}
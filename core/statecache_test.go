@@ -0,0 +1,76 @@
+package core
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+func TestStateCacheOnDisk(t *testing.T) {
+	setupConfigDir(t, map[string]string{
+		".tape.yml": "state-cache-ttl: 1h\n",
+		"app.yml":   "workspace: /src/app\n",
+	})
+	boxConfig, err := LoadBoxConfig("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &BoxSummary{EnvName: "app", State: BoxStateRunning, ContainerID: "abc"}
+
+	if _, ok := cachedBoxSummary(*boxConfig); ok {
+		t.Fatal("cachedBoxSummary() found a summary in an empty cache")
+	}
+	cacheBoxSummary(*boxConfig, summary)
+	got, ok := cachedBoxSummary(*boxConfig)
+	if !ok || !reflect.DeepEqual(got, summary) {
+		t.Fatalf("cachedBoxSummary() = %+v, %v, want %+v", got, ok, summary)
+	}
+
+	// editing the box config invalidates its state
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(BoxConfigPath("app"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cachedBoxSummary(*boxConfig); ok {
+		t.Error("cachedBoxSummary() returned a summary for an edited config")
+	}
+
+	cacheBoxSummary(*boxConfig, summary)
+	forgetBoxState("app")
+	if _, ok := cachedBoxSummary(*boxConfig); ok {
+		t.Error("cachedBoxSummary() returned a forgotten summary")
+	}
+}
+
+func TestStateCacheDisabled(t *testing.T) {
+	setupConfigDir(t, map[string]string{"app.yml": "workspace: /src/app\n"})
+	boxConfig, err := LoadBoxConfig("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cacheBoxSummary(*boxConfig, &BoxSummary{EnvName: "app", State: BoxStateRunning})
+	if _, ok := cachedBoxSummary(*boxConfig); ok {
+		t.Error("cachedBoxSummary() returned a summary without state-cache-ttl")
+	}
+	if _, err := os.Stat(stateCachePath()); !os.IsNotExist(err) {
+		t.Errorf("state cache was written without state-cache-ttl: %v", err)
+	}
+}
+
+func TestInvalidateStates(t *testing.T) {
+	entries := map[string]cachedState{"app": {}, "api": {}}
+
+	invalidateStates(entries, container.Event{Action: "die", Labels: map[string]string{EnvLabel: "app"}})
+	if _, ok := entries["app"]; ok || len(entries) != 1 {
+		t.Errorf("entries after an event of app = %v, want api's", entries)
+	}
+
+	invalidateStates(entries, container.Event{Action: "start", Labels: map[string]string{HostFolderLabel: "/src/old"}})
+	if len(entries) != 0 {
+		t.Errorf("entries after an event of an unlabelled container = %v, want none", entries)
+	}
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"sync"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// BoxStats is a resource usage sample for a box's container
+type BoxStats struct {
+	EnvName string
+	container.Stats
+}
+
+// BoxStatsFunc receives samples from WatchBoxStats. Calls are never concurrent.
+type BoxStatsFunc func(BoxStats)
+
+// WatchBoxStats reports resource usage for the running containers of the
+// given boxes. With stream it keeps reporting until ctx is cancelled,
+// otherwise it reports one sample per box. Every box must be running.
+func WatchBoxStats(ctx context.Context, envNames []string, stream bool, fn BoxStatsFunc) error {
+	type target struct {
+		envName string
+		cli     container.Backend
+		id      string
+	}
+
+	var targets []target
+	defer func() {
+		for _, t := range targets {
+			t.cli.Close()
+		}
+	}()
+
+	for _, envName := range envNames {
+		boxConfig, err := LoadBoxConfig(envName)
+		if err != nil {
+			return err
+		}
+		dc, err := FindDevContainer(*boxConfig)
+		if err != nil {
+			return err
+		}
+		if err := requireRunning(envName, dc); err != nil {
+			return err
+		}
+		cli, err := newBoxClient(*boxConfig)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target{envName: envName, cli: cli, id: dc.ID})
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	for _, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := t.cli.ContainerStats(ctx, t.id, stream, func(stats container.Stats) error {
+				mu.Lock()
+				defer mu.Unlock()
+				fn(BoxStats{EnvName: t.envName, Stats: stats})
+				return nil
+			})
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = err
+				}
+				mu.Unlock()
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}
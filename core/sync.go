@@ -0,0 +1,456 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+)
+
+// SyncVolumeName returns the named volume holding a sync mode box's workspace
+func SyncVolumeName(boxConfig BoxConfig) string {
+	return "tape-sync-" + boxConfig.ContainerName()
+}
+
+// syncWorkspaceMount returns the workspaceMount that puts the workspace in
+// the sync volume instead of bind mounting it from the host
+func syncWorkspaceMount(boxConfig BoxConfig, folder string) string {
+	return fmt.Sprintf("source=%s,target=%s,type=volume", SyncVolumeName(boxConfig), folder)
+}
+
+// syncTarget is a running sync mode box's container and workspace folder
+type syncTarget struct {
+	boxConfig *BoxConfig
+	dc        *container.Container
+	folder    string
+	user      string
+	// mu keeps pushes and pulls from running at the same time
+	mu sync.Mutex
+}
+
+func loadSyncTarget(envName string) (*syncTarget, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+	if !boxConfig.Sync {
+		return nil, fmt.Errorf("%s does not use sync mode, set sync: true in its config", envName)
+	}
+
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := requireRunning(envName, dc); err != nil {
+		return nil, err
+	}
+
+	folder, err := boxConfig.ContainerWorkspaceFolder()
+	if err != nil {
+		return nil, err
+	}
+
+	user := config.RemoteUser
+	if user == "" {
+		user = config.ContainerUser
+	}
+
+	return &syncTarget{boxConfig: boxConfig, dc: dc, folder: folder, user: user}, nil
+}
+
+// PushWorkspace copies the given workspace paths, relative to the workspace,
+// from the host into the box's sync volume. Paths missing on the host are
+// deleted in the container. With no paths, the whole workspace is copied.
+func PushWorkspace(envName string, paths []string) error {
+	target, err := loadSyncTarget(envName)
+	if err != nil {
+		return err
+	}
+	return target.push(context.Background(), paths)
+}
+
+func (t *syncTarget) push(ctx context.Context, paths []string) error {
+	workspace := t.boxConfig.Workspace
+	everything := len(paths) == 0
+	if everything {
+		files, err := scanTree(workspace, t.boxConfig.SyncIgnore)
+		if err != nil {
+			return fmt.Errorf("error reading workspace: %v", err)
+		}
+		for rel := range files {
+			paths = append(paths, rel)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	var copied, removed []string
+	for _, rel := range paths {
+		info, err := os.Lstat(filepath.Join(workspace, rel))
+		if os.IsNotExist(err) {
+			removed = append(removed, path.Join(t.folder, filepath.ToSlash(rel)))
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("error reading %s: %v", rel, err)
+		}
+		if err := addToTar(tw, workspace, rel, info); err != nil {
+			return err
+		}
+		copied = append(copied, path.Join(t.folder, filepath.ToSlash(rel)))
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("error creating archive: %v", err)
+	}
+
+	if len(copied) > 0 {
+		if err := t.dc.CopyTo(ctx, t.folder, &buf); err != nil {
+			return err
+		}
+		// the archive is extracted as root, hand the files to the remote user
+		if t.user != "" && t.user != "root" {
+			chown := append([]string{"chown", t.user + ":", "--"}, copied...)
+			if everything {
+				chown = []string{"chown", "-R", t.user + ":", "--", t.folder}
+			}
+			if err := t.run(ctx, chown); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(removed) > 0 {
+		if err := t.run(ctx, append([]string{"rm", "-f", "--"}, removed...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run runs a command as root in the container, returning an error if it fails
+func (t *syncTarget) run(ctx context.Context, command []string) error {
+	result, err := t.dc.Exec(ctx, container.ExecConfig{Command: command, User: "root"})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 {
+		return fmt.Errorf("error running %s in container: %s", command[0], strings.TrimSpace(string(result.Stderr)))
+	}
+	return nil
+}
+
+// addToTar adds a workspace file to the archive. Docker creates missing
+// parent directories when extracting it.
+func addToTar(tw *tar.Writer, workspace string, rel string, info os.FileInfo) error {
+	name := filepath.ToSlash(rel)
+
+	link := ""
+	if info.Mode()&os.ModeSymlink != 0 {
+		var err error
+		link, err = os.Readlink(filepath.Join(workspace, rel))
+		if err != nil {
+			return fmt.Errorf("error reading link %s: %v", rel, err)
+		}
+	}
+
+	header, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return fmt.Errorf("error archiving %s: %v", rel, err)
+	}
+	header.Name = name
+	if err := tw.WriteHeader(header); err != nil {
+		return fmt.Errorf("error archiving %s: %v", rel, err)
+	}
+
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	file, err := os.Open(filepath.Join(workspace, rel))
+	if err != nil {
+		return fmt.Errorf("error reading %s: %v", rel, err)
+	}
+	defer file.Close()
+	if _, err := io.Copy(tw, file); err != nil {
+		return fmt.Errorf("error archiving %s: %v", rel, err)
+	}
+	return nil
+}
+
+// PullWorkspace copies the workspace from the box's sync volume back to the
+// host, overwriting host files. Files only on the host are kept.
+func PullWorkspace(envName string) error {
+	target, err := loadSyncTarget(envName)
+	if err != nil {
+		return err
+	}
+
+	reader, err := target.dc.CopyFrom(context.Background(), target.folder)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return extractTar(reader, target.boxConfig.Workspace)
+}
+
+// extractTar writes an archive of a directory's contents into dir. The
+// archive's root entry is the directory itself, so its name is stripped.
+func extractTar(reader io.Reader, dir string) error {
+	return extractArchive(reader, dir, true)
+}
+
+// extractArchive writes an archive into dir, without the name of its root
+// entry when stripRoot is set
+func extractArchive(reader io.Reader, dir string, stripRoot bool) error {
+	_, err := extractEntries(reader, dir, stripRoot, false)
+	return err
+}
+
+// extractEntries is extractArchive returning the paths it wrote, relative to
+// dir. With onlyChanged, files and links that are already the same in dir
+// are skipped, and written files keep the archive's modification times, so
+// syncing them back finds them unchanged.
+func extractEntries(reader io.Reader, dir string, stripRoot bool, onlyChanged bool) ([]string, error) {
+	var written []string
+	tr := tar.NewReader(reader)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive: %v", err)
+		}
+
+		rel := strings.TrimPrefix(header.Name, "./")
+		if stripRoot {
+			var found bool
+			if _, rel, found = strings.Cut(rel, "/"); !found {
+				continue
+			}
+		}
+		if rel == "" {
+			continue
+		}
+		rel = filepath.FromSlash(rel)
+		if !filepath.IsLocal(rel) {
+			return nil, fmt.Errorf("refusing to extract %s outside the workspace", header.Name)
+		}
+		dest := filepath.Join(dir, rel)
+		if onlyChanged && sameAsHeader(dest, header) {
+			continue
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return nil, fmt.Errorf("error creating %s: %v", dest, err)
+			}
+			continue
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return nil, fmt.Errorf("error creating %s: %v", filepath.Dir(dest), err)
+			}
+			file, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(header.Mode)&os.ModePerm)
+			if err != nil {
+				return nil, fmt.Errorf("error writing %s: %v", dest, err)
+			}
+			_, err = io.Copy(file, tr)
+			file.Close()
+			if err != nil {
+				return nil, fmt.Errorf("error writing %s: %v", dest, err)
+			}
+			if onlyChanged {
+				os.Chtimes(dest, header.ModTime, header.ModTime)
+			}
+		case tar.TypeSymlink:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return nil, fmt.Errorf("error creating %s: %v", filepath.Dir(dest), err)
+			}
+			os.Remove(dest)
+			if err := os.Symlink(header.Linkname, dest); err != nil {
+				return nil, fmt.Errorf("error creating link %s: %v", dest, err)
+			}
+		default:
+			continue
+		}
+		written = append(written, rel)
+	}
+}
+
+// sameAsHeader reports whether dest already is the archive's file or link.
+// Files compare by size and modification time, to the second that archives
+// keep.
+func sameAsHeader(dest string, header *tar.Header) bool {
+	info, err := os.Lstat(dest)
+	if err != nil {
+		return false
+	}
+	switch header.Typeflag {
+	case tar.TypeReg:
+		return info.Mode().IsRegular() && info.Size() == header.Size &&
+			info.ModTime().Unix() == header.ModTime.Unix()
+	case tar.TypeSymlink:
+		link, err := os.Readlink(dest)
+		return err == nil && link == header.Linkname
+	}
+	return false
+}
+
+// seedSyncVolume pushes the whole workspace if the sync volume is empty,
+// e.g. right after it was created
+func seedSyncVolume(envName string) error {
+	target, err := loadSyncTarget(envName)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	result, err := target.dc.Exec(ctx, container.ExecConfig{
+		Command: []string{"find", target.folder, "-mindepth", "1", "-maxdepth", "1"},
+		User:    "root",
+	})
+	if err != nil {
+		return err
+	}
+	if result.ExitCode != 0 || len(bytes.TrimSpace(result.Stdout)) > 0 {
+		return nil
+	}
+	return target.push(ctx, nil)
+}
+
+// SyncBox keeps the box's sync volume and the host workspace in sync until
+// ctx is cancelled: the workspace is pushed once, then changes on the host
+// are pushed as they happen, and files created or modified in the container
+// are pulled every opts.Interval. When both sides change a file, the last
+// change wins. Files deleted in the container are kept on the host. When
+// mutagen is installed it runs a two-way sync session instead, which keeps
+// running in mutagen's daemon after SyncBox returns.
+func SyncBox(ctx context.Context, envName string, opts WatchOptions, onPush func(changed []string, err error), onPull func(changed []string, err error)) error {
+	target, err := loadSyncTarget(envName)
+	if err != nil {
+		return err
+	}
+
+	if mutagen, err := exec.LookPath("mutagen"); err == nil {
+		return target.startMutagen(mutagen)
+	}
+
+	if err := target.push(ctx, nil); err != nil {
+		return err
+	}
+	marker := fmt.Sprintf("/tmp/.tape-sync-%d", os.Getpid())
+	if err := target.run(ctx, []string{"touch", marker}); err != nil {
+		return err
+	}
+	defer target.run(context.Background(), []string{"rm", "-f", "--", marker, marker + ".next"})
+
+	go func() {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := target.pullChanged(ctx, marker)
+				if ctx.Err() == nil && (err != nil || len(changed) > 0) {
+					onPull(changed, err)
+				}
+			}
+		}
+	}()
+
+	opts.Ignore = target.boxConfig.SyncIgnore
+	return WatchWorkspace(ctx, target.boxConfig.Workspace, opts, func(changed []string) {
+		target.mu.Lock()
+		defer target.mu.Unlock()
+		onPush(changed, target.push(ctx, changed))
+	})
+}
+
+// pullChanged copies the files in the container modified since the marker
+// file was last touched to the host, and touches it again. Returns the
+// paths written on the host, relative to the workspace.
+func (t *syncTarget) pullChanged(ctx context.Context, marker string) ([]string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// touch the next marker first, so changes made while listing are found
+	// by the next pull
+	script := `touch "$1.next" && find "$2" -newer "$1" \( -type f -o -type l \) -print0 && mv "$1.next" "$1"`
+	result, err := t.dc.Exec(ctx, container.ExecConfig{Command: []string{"sh", "-c", script, "sh", marker, t.folder}, User: "root"})
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitCode != 0 {
+		return nil, fmt.Errorf("error listing changes in container: %s", strings.TrimSpace(string(result.Stderr)))
+	}
+
+	var paths []string
+	for _, name := range strings.Split(string(result.Stdout), "\x00") {
+		rel, ok := strings.CutPrefix(name, t.folder+"/")
+		if ok && !watchIgnoredPath(filepath.FromSlash(rel), t.boxConfig.SyncIgnore) {
+			paths = append(paths, rel)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, nil
+	}
+
+	archive, err := t.dc.Exec(ctx, container.ExecConfig{Command: append([]string{"tar", "-cf", "-", "-C", t.folder, "--"}, paths...), User: "root"})
+	if err != nil {
+		return nil, err
+	}
+	if archive.ExitCode != 0 {
+		return nil, fmt.Errorf("error archiving changes in container: %s", strings.TrimSpace(string(archive.Stderr)))
+	}
+	return extractEntries(bytes.NewReader(archive.Stdout), t.boxConfig.Workspace, false, true)
+}
+
+// startMutagen replaces the box's mutagen session with a new two-way sync
+func (t *syncTarget) startMutagen(mutagen string) error {
+	name := "tape-" + t.boxConfig.ContainerName()
+
+	// there is at most one session per box, ignore errors when there is none
+	exec.Command(mutagen, "sync", "terminate", name).Run()
+
+	args := []string{"sync", "create",
+		"--name", name,
+		"--sync-mode", "two-way-resolved",
+	}
+	for _, pattern := range t.boxConfig.SyncIgnore {
+		args = append(args, "--ignore", pattern)
+	}
+	user := ""
+	if t.user != "" {
+		user = t.user + "@"
+	}
+	args = append(args, t.boxConfig.Workspace, fmt.Sprintf("docker://%s%s%s", user, t.dc.ID, t.folder))
+
+	cmd := exec.Command(mutagen, args...)
+	cmd.Stdout = Stdout
+	cmd.Stderr = Stderr
+	if t.boxConfig.DockerHost != "" {
+		cmd.Env = append(os.Environ(), "DOCKER_HOST="+t.boxConfig.DockerHost)
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("error starting mutagen sync: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestExtractTar(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	entries := []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Name: "app/", Typeflag: tar.TypeDir, Mode: 0755}, ""},
+		{tar.Header{Name: "app/main.go", Typeflag: tar.TypeReg, Mode: 0644}, "package main"},
+		{tar.Header{Name: "app/pkg/util.go", Typeflag: tar.TypeReg, Mode: 0644}, "package pkg"},
+		{tar.Header{Name: "app/link", Typeflag: tar.TypeSymlink, Linkname: "main.go"}, ""},
+	}
+	for _, entry := range entries {
+		entry.header.Size = int64(len(entry.content))
+		if err := tw.WriteHeader(&entry.header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(entry.content))
+	}
+	tw.Close()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "host-only.txt"), []byte("keep"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := extractTar(&buf, dir); err != nil {
+		t.Fatalf("extractTar() error = %v", err)
+	}
+
+	for name, expected := range map[string]string{
+		"main.go":                       "package main",
+		filepath.Join("pkg", "util.go"): "package pkg",
+		"link":                          "package main",
+		"host-only.txt":                 "keep",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("extractTar() did not write %s: %v", name, err)
+			continue
+		}
+		if string(data) != expected {
+			t.Errorf("%s = %q, want %q", name, data, expected)
+		}
+	}
+}
+
+func TestExtractTarOutsideDir(t *testing.T) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "app/../../evil", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.Close()
+
+	if err := extractTar(&buf, t.TempDir()); err == nil {
+		t.Errorf("extractTar() extracted a file outside the directory")
+	}
+}
+
+func TestExtractEntriesOnlyChanged(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	for name, content := range map[string]string{"same.go": "package same", "edited.go": "package old"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		// the host keeps nanoseconds the archive doesn't
+		if err := os.Chtimes(path, modTime.Add(500*time.Millisecond), modTime.Add(500*time.Millisecond)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	edited := modTime.Add(time.Minute)
+	for _, entry := range []struct {
+		header  tar.Header
+		content string
+	}{
+		{tar.Header{Name: "same.go", Typeflag: tar.TypeReg, Mode: 0644, ModTime: modTime}, "package same"},
+		{tar.Header{Name: "edited.go", Typeflag: tar.TypeReg, Mode: 0644, ModTime: edited}, "package new"},
+		{tar.Header{Name: "pkg/new.go", Typeflag: tar.TypeReg, Mode: 0644, ModTime: edited}, "package pkg"},
+	} {
+		entry.header.Size = int64(len(entry.content))
+		if err := tw.WriteHeader(&entry.header); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(entry.content))
+	}
+	tw.Close()
+
+	written, err := extractEntries(&buf, dir, false, true)
+	if err != nil {
+		t.Fatalf("extractEntries() error = %v", err)
+	}
+	expected := []string{"edited.go", filepath.Join("pkg", "new.go")}
+	if !reflect.DeepEqual(written, expected) {
+		t.Errorf("extractEntries() = %v, want %v", written, expected)
+	}
+	info, err := os.Stat(filepath.Join(dir, "edited.go"))
+	if err != nil || !info.ModTime().Equal(edited) {
+		t.Errorf("edited.go modified at %v, %v, want the archive's %v", info.ModTime(), err, edited)
+	}
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// BoxTasks returns the box's tasks, merging the devcontainer's
+// customizations.tape.tasks with the box config's tasks. String tasks are
+// run with /bin/sh -c, array tasks directly.
+func BoxTasks(envName string) (map[string]devcontainer.ExecCommand, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+
+	return mergeTasks(config, boxConfig.Tasks)
+}
+
+func mergeTasks(config *devcontainer.DevContainerConfig, boxTasks map[string]string) (map[string]devcontainer.ExecCommand, error) {
+	tape, err := config.Tape()
+	if err != nil {
+		return nil, fmt.Errorf("error reading customizations.tape: %v", err)
+	}
+
+	tasks := map[string]devcontainer.ExecCommand{}
+	for name, command := range tape.Tasks {
+		parsed, err := command.Normalize()
+		if err != nil {
+			return nil, fmt.Errorf("task %s: %v", name, err)
+		}
+		// an object's commands run in parallel, with nothing to pass args on to
+		if len(parsed) != 1 || parsed[0].Name != "" {
+			return nil, fmt.Errorf("task %s must be a string or a non-empty array", name)
+		}
+		tasks[name] = parsed[0].ExecCommand
+	}
+	for name, command := range boxTasks {
+		tasks[name] = devcontainer.ShellCommand(command)
+	}
+	return tasks, nil
+}
+
+// TaskNames returns the task names in tasks, sorted
+func TaskNames(tasks map[string]devcontainer.ExecCommand) []string {
+	names := make([]string, 0, len(tasks))
+	for name := range tasks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RunTask runs a task in the box's container attached to the terminal, with
+// args appended to its command. A failing task returns an error with the
+// task's exit code, see container.ExitError.
+func RunTask(envName string, task string, args []string) error {
+	tasks, err := BoxTasks(envName)
+	if err != nil {
+		return err
+	}
+
+	command, ok := tasks[task]
+	if !ok {
+		return fmt.Errorf("no task %s for %s (available: %s)", task, envName, strings.Join(TaskNames(tasks), ", "))
+	}
+
+	// shell tasks receive the args as positional parameters, "$@" after the script
+	return ExecInBox(envName, ExecOptions{Command: command.ArgvWith(task, args)})
+}
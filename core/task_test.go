@@ -0,0 +1,48 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestMergeTasks(t *testing.T) {
+	config, err := devcontainer.ParseDevContainer([]byte(`{"customizations": {"tape": {"tasks": {
+		"test": "go test ./...",
+		"lint": ["golangci-lint", "run"]
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+
+	got, err := mergeTasks(config, map[string]string{"test": "make test", "serve": "npm start"})
+	if err != nil {
+		t.Fatalf("mergeTasks() error = %v", err)
+	}
+
+	expected := map[string]devcontainer.ExecCommand{
+		"test":  devcontainer.ShellCommand("make test"),
+		"lint":  {Args: []string{"golangci-lint", "run"}},
+		"serve": devcontainer.ShellCommand("npm start"),
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("mergeTasks() = %v, want %v", got, expected)
+	}
+	if names := TaskNames(got); !reflect.DeepEqual(names, []string{"lint", "serve", "test"}) {
+		t.Errorf("TaskNames() = %v, want [lint serve test]", names)
+	}
+}
+
+func TestMergeTasksObject(t *testing.T) {
+	config, err := devcontainer.ParseDevContainer([]byte(`{"customizations": {"tape": {"tasks": {
+		"dev": {"server": "npm start", "watch": "npm run watch"}
+	}}}}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+
+	if _, err := mergeTasks(config, nil); err == nil {
+		t.Error("mergeTasks() expected an error for an object task")
+	}
+}
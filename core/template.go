@@ -0,0 +1,136 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// templateMetadataFile is the file at the root of a template that describes it
+const templateMetadataFile = "devcontainer-template.json"
+
+// templateSkippedFiles are the files in a template that describe it rather than
+// being part of what it generates
+var templateSkippedFiles = []string{templateMetadataFile, "README.md", "NOTES.md"}
+
+// Template is a devcontainer template pulled from a registry
+type Template struct {
+	Ref      devcontainer.FeatureRef
+	Metadata *devcontainer.TemplateMetadata
+	files    []templateFile
+}
+
+type templateFile struct {
+	path    string
+	mode    os.FileMode
+	content []byte
+}
+
+// FetchTemplate pulls a template such as ghcr.io/devcontainers/templates/go
+func FetchTemplate(ref string) (*Template, error) {
+	parsed, err := devcontainer.ParseFeatureRef(ref)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template reference: %v", err)
+	}
+	if parsed.Kind != devcontainer.FeatureRefOCI {
+		return nil, fmt.Errorf("template %s must be an OCI reference", ref)
+	}
+
+	layer, err := newRegistryClient().fetchLayer(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	return readTemplate(parsed, bytes.NewReader(layer))
+}
+
+// readTemplate reads a template from its tarball
+func readTemplate(ref devcontainer.FeatureRef, r io.Reader) (*Template, error) {
+	template := &Template{Ref: ref}
+
+	tarReader := tar.NewReader(r)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading template: %v", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		name := path.Clean(header.Name)
+		if !filepath.IsLocal(filepath.FromSlash(name)) {
+			return nil, fmt.Errorf("template contains invalid path %s", header.Name)
+		}
+
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s from template: %v", name, err)
+		}
+
+		if name == templateMetadataFile {
+			template.Metadata, err = devcontainer.ParseTemplateMetadata(content)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if slices.Contains(templateSkippedFiles, name) {
+			continue
+		}
+
+		template.files = append(template.files, templateFile{path: name, mode: os.FileMode(header.Mode).Perm(), content: content})
+	}
+
+	if template.Metadata == nil {
+		return nil, fmt.Errorf("%s is not a template, it has no %s", ref, templateMetadataFile)
+	}
+	return template, nil
+}
+
+// Apply writes the template's files into workspace with the options
+// substituted. Existing files are only replaced when force is set. It returns
+// the paths written, relative to workspace.
+func (t *Template) Apply(workspace string, options map[string]string, force bool) ([]string, error) {
+	resolved, err := t.Metadata.ResolveOptions(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if !force {
+		for _, file := range t.files {
+			target := filepath.Join(workspace, filepath.FromSlash(file.path))
+			if _, err := os.Stat(target); err == nil {
+				return nil, fmt.Errorf("%s already exists, use --force to overwrite it", target)
+			}
+		}
+	}
+
+	var written []string
+	for _, file := range t.files {
+		target := filepath.Join(workspace, filepath.FromSlash(file.path))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return written, fmt.Errorf("error creating %s: %v", filepath.Dir(target), err)
+		}
+
+		mode := file.mode
+		if mode == 0 {
+			mode = 0644
+		}
+		content := devcontainer.ApplyTemplateOptions(file.content, resolved)
+		if err := os.WriteFile(target, content, mode); err != nil {
+			return written, fmt.Errorf("error writing %s: %v", target, err)
+		}
+		written = append(written, file.path)
+	}
+	return written, nil
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func templateTarball(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	tarWriter := tar.NewWriter(&buf)
+	for name, content := range files {
+		if err := writeTarFile(tarWriter, name, []byte(content)); err != nil {
+			t.Fatalf("Failed to write tarball: %v", err)
+		}
+	}
+	if err := tarWriter.Close(); err != nil {
+		t.Fatalf("Failed to write tarball: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestFetchTemplate(t *testing.T) {
+	layer := templateTarball(t, map[string]string{
+		"devcontainer-template.json":      `{"id": "go", "options": {"imageVariant": {"type": "string", "default": "1.22"}}}`,
+		"README.md":                       "# Go\n",
+		".devcontainer/devcontainer.json": `{"image": "mcr.microsoft.com/devcontainers/go:${templateOption:imageVariant}"}`,
+	})
+
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:devcontainers/templates/go:pull" {
+				t.Errorf("token request scope = %s", r.URL.Query().Get("scope"))
+			}
+			fmt.Fprint(w, `{"token": "secret"}`)
+		case r.Header.Get("Authorization") != "Bearer secret":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:devcontainers/templates/go:pull"`, server.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.URL.Path == "/v2/devcontainers/templates/go/manifests/latest":
+			fmt.Fprint(w, `{"layers": [{"mediaType": "application/vnd.devcontainers.layer.v1+tar", "digest": "sha256:abc"}]}`)
+		case r.URL.Path == "/v2/devcontainers/templates/go/blobs/sha256:abc":
+			w.Write(layer)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	ref, err := devcontainer.ParseFeatureRef(registry + "/devcontainers/templates/go")
+	if err != nil {
+		t.Fatalf("ParseFeatureRef() error = %v", err)
+	}
+
+	client := &registryClient{http: server.Client(), scheme: "http", tokens: map[string]string{}}
+	data, err := client.fetchLayer(ref)
+	if err != nil {
+		t.Fatalf("fetchLayer() error = %v", err)
+	}
+
+	template, err := readTemplate(ref, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("readTemplate() error = %v", err)
+	}
+	if template.Metadata.ID != "go" {
+		t.Errorf("readTemplate().Metadata.ID = %v, want go", template.Metadata.ID)
+	}
+
+	workspace := t.TempDir()
+	written, err := template.Apply(workspace, map[string]string{"imageVariant": "1.21"}, false)
+	if err != nil {
+		t.Fatalf("Apply() error = %v", err)
+	}
+	if !reflect.DeepEqual(written, []string{".devcontainer/devcontainer.json"}) {
+		t.Errorf("Apply() = %v, want only the devcontainer config", written)
+	}
+
+	content, err := os.ReadFile(filepath.Join(workspace, ".devcontainer/devcontainer.json"))
+	if err != nil {
+		t.Fatalf("Failed to read applied template: %v", err)
+	}
+	if expected := `{"image": "mcr.microsoft.com/devcontainers/go:1.21"}`; string(content) != expected {
+		t.Errorf("Apply() wrote %s, want %s", content, expected)
+	}
+
+	if _, err := template.Apply(workspace, nil, false); err == nil {
+		t.Errorf("Apply() did not refuse to overwrite existing files")
+	}
+	if _, err := template.Apply(workspace, nil, true); err != nil {
+		t.Errorf("Apply() with force error = %v", err)
+	}
+}
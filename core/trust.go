@@ -0,0 +1,328 @@
+package core
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/devcontainer"
+	"gopkg.in/yaml.v2"
+)
+
+// Like VS Code's workspace trust, a box can't run commands on the host or
+// take control of docker before someone said its workspace may: up asks
+// first, and the answer is recorded for the workspace.
+
+// trustDecision is the answer recorded for a workspace
+type trustDecision struct {
+	Trusted bool      `json:"trusted"`
+	Time    time.Time `json:"time"`
+}
+
+// TrustPath returns the file recording which workspaces are trusted
+func TrustPath() string {
+	return filepath.Join(ConfigDir, "trusted-workspaces.json")
+}
+
+// TrustError is returned when a box would run commands on the host or reach
+// beyond its container, see trustReasons, and its workspace isn't trusted
+type TrustError struct {
+	EnvName   string
+	Workspace string
+	// Reasons describe what the config would do that needs trust
+	Reasons []string
+	// Declined is set when the workspace was explicitly not trusted, rather
+	// than never asked about
+	Declined bool
+}
+
+func (e *TrustError) Error() string {
+	state := "hasn't been trusted"
+	if e.Declined {
+		state = "is not trusted"
+	}
+	return fmt.Sprintf("the workspace %s of %s %s, starting it would:\n  %s", e.Workspace, e.EnvName, state, strings.Join(e.Reasons, "\n  "))
+}
+
+func (e *TrustError) Unwrap() error {
+	return ErrUntrustedWorkspace
+}
+
+// WorkspaceTrust returns whether the workspace is trusted, and whether that
+// was decided at all
+func WorkspaceTrust(workspace string) (trusted bool, decided bool, err error) {
+	decisions, err := loadTrustDecisions()
+	if err != nil {
+		return false, false, err
+	}
+	decision, ok := decisions[workspace]
+	return decision.Trusted, ok, nil
+}
+
+// SetWorkspaceTrust records whether the workspace is trusted
+func SetWorkspaceTrust(workspace string, trusted bool) error {
+	decisions, err := loadTrustDecisions()
+	if err != nil {
+		return err
+	}
+	decisions[workspace] = trustDecision{Trusted: trusted, Time: time.Now()}
+
+	data, err := json.MarshalIndent(decisions, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(TrustPath()), 0755); err != nil {
+		return fmt.Errorf("error recording workspace trust: %v", err)
+	}
+	// replace the file, so concurrent tape processes never read half of it
+	tmp := TrustPath() + fmt.Sprintf(".%d", os.Getpid())
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("error recording workspace trust: %v", err)
+	}
+	if err := os.Rename(tmp, TrustPath()); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("error recording workspace trust: %v", err)
+	}
+	return nil
+}
+
+func loadTrustDecisions() (map[string]trustDecision, error) {
+	decisions := map[string]trustDecision{}
+	data, err := os.ReadFile(TrustPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return decisions, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading workspace trust: %v", err)
+	}
+	if err := json.Unmarshal(data, &decisions); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %v", TrustPath(), err)
+	}
+	return decisions, nil
+}
+
+// CheckWorkspaceTrust returns a TrustError when tape up would have to ask
+// whether the environment's workspace is trusted, see checkWorkspaceTrust.
+// configName chooses the devcontainer config like UpOptions.ConfigName.
+func CheckWorkspaceTrust(envName string, configName string) error {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+	if err := selectDevcontainerConfig(boxConfig, configName); err != nil {
+		return err
+	}
+	if boxConfig.Untrusted {
+		return nil
+	}
+	return checkWorkspaceTrust(*boxConfig)
+}
+
+// checkWorkspaceTrust returns a TrustError when the box runs commands on the
+// host or reaches beyond its container, see trustReasons, and its workspace
+// isn't trusted. Workspaces whose box already has a container tape created
+// trusted were used before tape asked, they are recorded as trusted.
+func checkWorkspaceTrust(boxConfig BoxConfig) error {
+	trusted, decided, err := WorkspaceTrust(boxConfig.Workspace)
+	if err != nil || trusted {
+		return err
+	}
+
+	var config *devcontainer.DevContainerConfig
+	if boxConfig.Config != "" {
+		config, err = LoadConfig(boxConfig.Config)
+		if err != nil {
+			return err
+		}
+	}
+	reasons, err := trustReasons(boxConfig, config)
+	if err != nil {
+		return err
+	}
+	if len(reasons) == 0 {
+		return nil
+	}
+
+	if !decided && createdTrusted(boxConfig) {
+		return SetWorkspaceTrust(boxConfig.Workspace, true)
+	}
+	return &TrustError{EnvName: boxConfig.Name, Workspace: boxConfig.Workspace, Reasons: reasons, Declined: decided}
+}
+
+// createdTrusted reports whether the box has a container tape created
+// without sandboxing it. Containers created untrusted, or before tape
+// recorded whether they were, don't count.
+func createdTrusted(boxConfig BoxConfig) bool {
+	dc, err := FindDevContainer(boxConfig)
+	return err == nil && dc.Labels[UntrustedLabel] == "false"
+}
+
+// trustReasons describes what the box does that needs its workspace to be
+// trusted: its hooks and its devcontainer config's initializeCommand run on
+// the host, and the config can mount the docker socket, or have compose
+// services that reach beyond their containers. config is nil for boxes
+// without a devcontainer config.
+func trustReasons(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) ([]string, error) {
+	var reasons []string
+
+	for _, hook := range []string{HookPreUp, HookPostUp, HookPreStop, HookPostStop} {
+		for _, command := range boxConfig.Hooks.commands(hook) {
+			reasons = append(reasons, fmt.Sprintf("run its %s hook on the host: %s", hook, command))
+		}
+	}
+	if config == nil {
+		return reasons, nil
+	}
+
+	commands, err := config.InitializeCommand.Normalize()
+	if err != nil {
+		return nil, fmt.Errorf("invalid initializeCommand: %v", err)
+	}
+	for _, command := range commands {
+		reasons = append(reasons, fmt.Sprintf("run initializeCommand on the host: %s", command.String()))
+	}
+
+	socket := slices.ContainsFunc(config.Mounts, func(m devcontainer.MountValue) bool {
+		spec, err := m.DockerMountSpec()
+		return err == nil && mountsDockerSocket(spec)
+	})
+	if socket || runArgsMountDockerSocket(config.RunArgs) {
+		reasons = append(reasons, "mount the docker socket, which controls the host's docker")
+	}
+
+	featureArgs, err := featureRunArgs(boxConfig, config)
+	if err != nil {
+		return nil, fmt.Errorf("error checking features for the docker socket: %w", err)
+	}
+	for _, feature := range slices.Sorted(maps.Keys(featureArgs)) {
+		if featureMountsDockerSocket(featureArgs[feature]) {
+			reasons = append(reasons, fmt.Sprintf("mount the docker socket for feature %s", feature))
+		}
+	}
+
+	composeReasons, err := composeTrustReasons(boxConfig, config)
+	if err != nil {
+		return nil, err
+	}
+	return append(reasons, composeReasons...), nil
+}
+
+// composeTrustService is the part of a compose service that can reach
+// beyond its container
+type composeTrustService struct {
+	Privileged  bool          `yaml:"privileged"`
+	CapAdd      []string      `yaml:"cap_add"`
+	Pid         string        `yaml:"pid"`
+	NetworkMode string        `yaml:"network_mode"`
+	Devices     []interface{} `yaml:"devices"`
+	Volumes     []interface{} `yaml:"volumes"`
+}
+
+// composeTrustReasons describes the services of the config's compose files
+// that run privileged, share the host's namespaces or devices, or mount host
+// paths outside the workspace
+func composeTrustReasons(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) ([]string, error) {
+	if config.DockerComposeFile == nil {
+		return nil, nil
+	}
+	files := config.DockerComposeFile.AsArray()
+	if file := config.DockerComposeFile.AsString(); file != "" {
+		files = []string{file}
+	}
+
+	var reasons []string
+	for _, file := range files {
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(boxConfig.Config), file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading compose file: %v", err)
+		}
+		var project struct {
+			Services map[string]composeTrustService `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(data, &project); err != nil {
+			return nil, fmt.Errorf("error parsing %s: %v", file, err)
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(project.Services)) {
+			service := project.Services[name]
+			if service.Privileged {
+				reasons = append(reasons, fmt.Sprintf("run compose service %s privileged", name))
+			}
+			if len(service.CapAdd) > 0 {
+				reasons = append(reasons, fmt.Sprintf("give compose service %s the capabilities %s", name, strings.Join(service.CapAdd, ", ")))
+			}
+			if service.Pid == "host" || service.NetworkMode == "host" {
+				reasons = append(reasons, fmt.Sprintf("share the host's namespaces with compose service %s", name))
+			}
+			if len(service.Devices) > 0 {
+				reasons = append(reasons, fmt.Sprintf("give compose service %s the host's devices", name))
+			}
+			for _, volume := range service.Volumes {
+				source, ok := composeBindSource(volume)
+				if ok && !inWorkspace(boxConfig.Workspace, filepath.Dir(file), source) {
+					reasons = append(reasons, fmt.Sprintf("mount %s from the host into compose service %s", source, name))
+				}
+			}
+		}
+	}
+	return reasons, nil
+}
+
+// composeBindSource returns the host path a compose volume binds, in either
+// its short or long syntax
+func composeBindSource(volume interface{}) (string, bool) {
+	switch v := volume.(type) {
+	case string:
+		source, _, found := strings.Cut(v, ":")
+		if !found || !strings.ContainsAny(source[:min(len(source), 1)], "/.~$") {
+			// a named or anonymous volume
+			return "", false
+		}
+		return source, true
+	case map[interface{}]interface{}:
+		source, _ := v["source"].(string)
+		return source, v["type"] == "bind"
+	}
+	return "", false
+}
+
+// inWorkspace reports whether a bind source, relative to dir, is in the
+// workspace. Sources with variables or ~ can't be told, they aren't.
+func inWorkspace(workspace string, dir string, source string) bool {
+	if strings.ContainsAny(source, "$~") {
+		return false
+	}
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(dir, source)
+	}
+	rel, err := filepath.Rel(workspace, source)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// runArgsMountDockerSocket reports whether run flags mount the docker socket
+func runArgsMountDockerSocket(runArgs []string) bool {
+	for i := 0; i < len(runArgs); i++ {
+		flag, value, hasValue := strings.Cut(runArgs[i], "=")
+		if !slices.Contains([]string{"-v", "--volume", "--mount"}, flag) {
+			continue
+		}
+		if !hasValue && i+1 < len(runArgs) {
+			i++
+			value = runArgs[i]
+		}
+		if mountsDockerSocket(value) {
+			return true
+		}
+	}
+	return false
+}
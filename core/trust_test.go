@@ -0,0 +1,149 @@
+package core
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestWorkspaceTrust(t *testing.T) {
+	setupConfigDir(t, nil)
+
+	if trusted, decided, err := WorkspaceTrust("/src/app"); err != nil || trusted || decided {
+		t.Fatalf("WorkspaceTrust() = %v, %v, %v, want undecided", trusted, decided, err)
+	}
+
+	if err := SetWorkspaceTrust("/src/app", true); err != nil {
+		t.Fatalf("SetWorkspaceTrust() error = %v", err)
+	}
+	if err := SetWorkspaceTrust("/src/other", false); err != nil {
+		t.Fatalf("SetWorkspaceTrust() error = %v", err)
+	}
+
+	if trusted, decided, err := WorkspaceTrust("/src/app"); err != nil || !trusted || !decided {
+		t.Errorf("WorkspaceTrust(app) = %v, %v, %v, want trusted", trusted, decided, err)
+	}
+	if trusted, decided, err := WorkspaceTrust("/src/other"); err != nil || trusted || !decided {
+		t.Errorf("WorkspaceTrust(other) = %v, %v, %v, want declined", trusted, decided, err)
+	}
+}
+
+func TestTrustReasons(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected []string
+	}{
+		{
+			name:   "nothing to trust",
+			config: `{"image": "ubuntu", "postCreateCommand": "npm install"}`,
+		},
+		{
+			name:   "initializeCommand",
+			config: `{"image": "ubuntu", "initializeCommand": {"env": "./setup.sh", "login": ["docker", "login"]}}`,
+			expected: []string{
+				"run initializeCommand on the host: ./setup.sh",
+				"run initializeCommand on the host: docker login",
+			},
+		},
+		{
+			name:     "docker socket mount",
+			config:   `{"image": "ubuntu", "mounts": ["source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind"]}`,
+			expected: []string{"mount the docker socket, which controls the host's docker"},
+		},
+		{
+			name:     "docker socket run arg",
+			config:   `{"image": "ubuntu", "runArgs": ["--cpus", "2", "-v", "/var/run/docker.sock:/var/run/docker.sock"]}`,
+			expected: []string{"mount the docker socket, which controls the host's docker"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := devcontainer.ParseDevContainer([]byte(tt.config))
+			if err != nil {
+				t.Fatalf("ParseDevContainer() error = %v", err)
+			}
+			got, err := trustReasons(BoxConfig{Name: "app"}, config)
+			if err != nil {
+				t.Fatalf("trustReasons() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("trustReasons() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestTrustError(t *testing.T) {
+	err := error(&TrustError{EnvName: "app", Workspace: "/src/app", Reasons: []string{"run initializeCommand on the host: make"}})
+	if !errors.Is(err, ErrUntrustedWorkspace) {
+		t.Errorf("errors.Is(%v, ErrUntrustedWorkspace) = false, want true", err)
+	}
+	expected := "the workspace /src/app of app hasn't been trusted, starting it would:\n  run initializeCommand on the host: make"
+	if err.Error() != expected {
+		t.Errorf("Error() = %q, want %q", err.Error(), expected)
+	}
+}
+
+func TestTrustReasonsHooksAndCompose(t *testing.T) {
+	workspace := t.TempDir()
+	compose := `services:
+  app:
+    build: .
+    volumes:
+      - ..:/workspace
+      - ./cache:/cache
+      - data:/data
+  db:
+    image: postgres
+    privileged: true
+    cap_add: [SYS_ADMIN]
+    volumes:
+      - /var/run/docker.sock:/var/run/docker.sock
+      - type: bind
+        source: /etc
+        target: /host-etc
+`
+	if err := os.MkdirAll(filepath.Join(workspace, ".devcontainer"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(workspace, ".devcontainer", "compose.yml"), []byte(compose), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config, err := devcontainer.ParseDevContainer([]byte(`{"dockerComposeFile": "compose.yml", "service": "app"}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+	boxConfig := BoxConfig{
+		Name:      "app",
+		Workspace: workspace,
+		Config:    filepath.Join(workspace, ".devcontainer", "devcontainer.json"),
+		Hooks:     BoxHooks{PreUp: []string{"sudo vpn up"}},
+	}
+
+	got, err := trustReasons(boxConfig, config)
+	if err != nil {
+		t.Fatalf("trustReasons() error = %v", err)
+	}
+	expected := []string{
+		"run its pre-up hook on the host: sudo vpn up",
+		"run compose service db privileged",
+		"give compose service db the capabilities SYS_ADMIN",
+		"mount /var/run/docker.sock from the host into compose service db",
+		"mount /etc from the host into compose service db",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("trustReasons() = %q, want %q", got, expected)
+	}
+
+	// boxes without a devcontainer config still run their hooks
+	got, err = trustReasons(boxConfig, nil)
+	if err != nil || !reflect.DeepEqual(got, expected[:1]) {
+		t.Errorf("trustReasons() without a config = %q, %v, want %q", got, err, expected[:1])
+	}
+}
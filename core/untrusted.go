@@ -0,0 +1,378 @@
+package core
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// EgressProxyEnvLabel labels the egress proxy of an untrusted box with its name
+const EgressProxyEnvLabel = "tape.egress.env"
+
+// EgressProxyImage runs the proxies untrusted boxes reach the allowed hosts through
+var EgressProxyImage = "alpine:3.20"
+
+// egressProxyAlias is the name untrusted boxes reach their egress proxy by
+const egressProxyAlias = "tape-egress"
+
+const egressProxyPort = 8888
+
+// egressProxyDir holds the egress proxy's config in its container
+const egressProxyDir = "/etc/tape-egress"
+
+// untrustedPolicy is enforced for untrusted boxes, whose config and features
+// could otherwise escape the sandbox through the host's namespaces
+var untrustedPolicy = Policy{
+	DeniedRunArgs: []string{
+		"--privileged",
+		"--pid=host",
+		"--ipc=host",
+		"--uts=host",
+		"--userns=host",
+		"--cgroupns=host",
+		"--device",
+		"--cap-add",
+		"--volumes-from",
+		// hardened boxes set no-new-privileges, so only the options that
+		// lift confinement are denied
+		"--security-opt=seccomp=unconfined",
+		"--security-opt=apparmor=unconfined",
+		"--security-opt=label=disable",
+		"--security-opt=label:disable",
+		"--security-opt=systempaths=unconfined",
+		"--security-opt=no-new-privileges=false",
+		"--security-opt=no-new-privileges:false",
+	},
+}
+
+// untrustedPolicySource names the untrusted policy in policy errors
+const untrustedPolicySource = "untrusted mode"
+
+// untrustedNetworkName returns the internal network an untrusted box runs on
+func untrustedNetworkName(boxConfig BoxConfig) string {
+	return "tape-untrusted-" + boxConfig.ContainerName()
+}
+
+// sandboxConfig strips what lets an untrusted repo reach beyond its
+// container: the initializeCommand, which runs on the host, the unsafe
+// lifecycle commands, and mounts of the docker socket. The container runs on
+// network, where only the egress proxy leads out.
+func sandboxConfig(config *devcontainer.DevContainerConfig, network string, unsafe []string) {
+	config.InitializeCommand = nil
+	for _, command := range unsafe {
+		switch command {
+		case "onCreateCommand":
+			config.OnCreateCommand = nil
+		case "updateContentCommand":
+			config.UpdateContentCommand = nil
+		case "postCreateCommand":
+			config.PostCreateCommand = nil
+		case "postStartCommand":
+			config.PostStartCommand = nil
+		case "postAttachCommand":
+			config.PostAttachCommand = nil
+		}
+	}
+
+	config.Mounts = slices.DeleteFunc(config.Mounts, func(m devcontainer.MountValue) bool {
+		spec, err := m.DockerMountSpec()
+		return err == nil && mountsDockerSocket(spec)
+	})
+
+	var runArgs []string
+	for i := 0; i < len(config.RunArgs); i++ {
+		arg := config.RunArgs[i]
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !slices.Contains([]string{"-v", "--volume", "--mount", "--network", "--net"}, flag) {
+			runArgs = append(runArgs, arg)
+			continue
+		}
+		if !hasValue && i+1 < len(config.RunArgs) {
+			i++
+			value = config.RunArgs[i]
+		}
+		if (flag == "--network" || flag == "--net") || mountsDockerSocket(value) {
+			continue
+		}
+		runArgs = append(runArgs, flag, value)
+	}
+	config.RunArgs = append(runArgs, "--network", network)
+
+	proxy := fmt.Sprintf("http://%s:%d", egressProxyAlias, egressProxyPort)
+	env := map[string]string{}
+	for key, value := range config.ContainerEnv {
+		env[key] = value
+	}
+	for _, key := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy"} {
+		env[key] = proxy
+	}
+	env["NO_PROXY"] = "localhost,127.0.0.1"
+	env["no_proxy"] = env["NO_PROXY"]
+	config.ContainerEnv = env
+}
+
+// stripHostBinds removes the bind mounts of host paths outside the workspace
+// from an untrusted box's devcontainer config, in its mounts, runArgs and
+// workspaceMount, so it can't read or change the host's files, or reach the
+// docker socket through a parent directory
+func stripHostBinds(config *devcontainer.DevContainerConfig, workspace string) {
+	config.Mounts = slices.DeleteFunc(config.Mounts, func(m devcontainer.MountValue) bool {
+		spec, err := m.DockerMountSpec()
+		return err != nil || !hostBindAllowed(spec, true, workspace)
+	})
+
+	if config.WorkspaceMount != "" && !hostBindAllowed(config.WorkspaceMount, true, workspace) {
+		// the devcontainer CLI mounts the workspace itself
+		config.WorkspaceMount = ""
+		config.WorkspaceFolder = ""
+	}
+
+	var runArgs []string
+	for i := 0; i < len(config.RunArgs); i++ {
+		arg := config.RunArgs[i]
+		flag, value, hasValue := strings.Cut(arg, "=")
+		if !slices.Contains([]string{"-v", "--volume", "--mount"}, flag) {
+			runArgs = append(runArgs, arg)
+			continue
+		}
+		if !hasValue && i+1 < len(config.RunArgs) {
+			i++
+			value = config.RunArgs[i]
+		}
+		if hostBindAllowed(value, flag == "--mount", workspace) {
+			runArgs = append(runArgs, flag, value)
+		}
+	}
+	config.RunArgs = runArgs
+}
+
+// hostBindAllowed reports whether a --mount spec, or a -v spec when isMount is
+// false, is anything but a bind of a host path outside the workspace
+func hostBindAllowed(spec string, isMount bool, workspace string) bool {
+	source, bind := hostBindSource(spec, isMount)
+	return !bind || bindInWorkspace(workspace, source)
+}
+
+// hostBindSource returns the host path a --mount spec, or a -v spec when
+// isMount is false, binds
+func hostBindSource(spec string, isMount bool) (string, bool) {
+	if !isMount {
+		source, _, found := strings.Cut(spec, ":")
+		if !found || source == "" || !strings.ContainsAny(source[:1], "/.~$") {
+			// a named or anonymous volume
+			return "", false
+		}
+		return source, true
+	}
+
+	mountType, source := "volume", ""
+	for _, field := range strings.Split(spec, ",") {
+		key, value, _ := strings.Cut(field, "=")
+		switch key {
+		case "type":
+			mountType = value
+		case "source", "src":
+			source = value
+		}
+	}
+	return source, mountType == "bind"
+}
+
+// bindInWorkspace reports whether a bind's source is in the workspace.
+// Sources with other variables, ~ or relative paths can't be told, they
+// aren't.
+func bindInWorkspace(workspace string, source string) bool {
+	source = strings.ReplaceAll(source, "${localWorkspaceFolder}", workspace)
+	if strings.ContainsAny(source, "$~") || !filepath.IsAbs(source) {
+		return false
+	}
+	rel, err := filepath.Rel(workspace, source)
+	return err == nil && filepath.IsLocal(rel)
+}
+
+// mountsDockerSocket reports whether a volume or mount spec mounts the
+// docker socket
+func mountsDockerSocket(spec string) bool {
+	return strings.Contains(spec, "docker.sock")
+}
+
+// dockerSocketViolations describes the features that mount the docker
+// socket, by their run flags, see featureRunArgs
+func dockerSocketViolations(featureArgs map[string][]string) []string {
+	var violations []string
+	for _, feature := range slices.Sorted(maps.Keys(featureArgs)) {
+		if featureMountsDockerSocket(featureArgs[feature]) {
+			violations = append(violations, fmt.Sprintf("feature %s mounts the docker socket, which is not allowed", feature))
+		}
+	}
+	return violations
+}
+
+// untrustedViolations describes what an untrusted box's config does that
+// can't be sandboxed: compose services, which the sandbox doesn't reach, and
+// features binding host paths outside the workspace. featureArgs are the run
+// flags of its features, see featureRunArgs.
+func untrustedViolations(boxConfig BoxConfig, config *devcontainer.DevContainerConfig, featureArgs map[string][]string) []string {
+	var violations []string
+	if config != nil && config.DockerComposeFile != nil {
+		violations = append(violations, "dockerComposeFile is not allowed, compose services can't be sandboxed")
+	}
+	for _, feature := range slices.Sorted(maps.Keys(featureArgs)) {
+		binds := slices.ContainsFunc(featureArgs[feature], func(arg string) bool {
+			spec, ok := strings.CutPrefix(arg, "--mount=")
+			return ok && !mountsDockerSocket(spec) && !hostBindAllowed(spec, true, boxConfig.Workspace)
+		})
+		if binds {
+			violations = append(violations, fmt.Sprintf("feature %s mounts host paths, which is not allowed", feature))
+		}
+	}
+	return append(violations, dockerSocketViolations(featureArgs)...)
+}
+
+// featureMountsDockerSocket reports whether a feature's run flags, see
+// featureRunArgs, mount the docker socket
+func featureMountsDockerSocket(args []string) bool {
+	return slices.ContainsFunc(args, func(arg string) bool {
+		spec, ok := strings.CutPrefix(arg, "--mount=")
+		return ok && mountsDockerSocket(spec)
+	})
+}
+
+// egressFilter returns tinyproxy's filter for the allowed hosts, a regular
+// expression per host, where *.example.com matches the subdomains of
+// example.com
+func egressFilter(hosts []string) string {
+	var filter strings.Builder
+	for _, host := range hosts {
+		if domain, ok := strings.CutPrefix(host, "*."); ok {
+			fmt.Fprintf(&filter, "^.+\\.%s$\n", regexp.QuoteMeta(domain))
+		} else {
+			fmt.Fprintf(&filter, "^%s$\n", regexp.QuoteMeta(host))
+		}
+	}
+	return filter.String()
+}
+
+// egressProxyConfig returns tinyproxy's config, denying the hosts its
+// filter doesn't match
+func egressProxyConfig() string {
+	return fmt.Sprintf(`Port %d
+Listen 0.0.0.0
+Timeout 600
+MaxClients 100
+LogLevel Connect
+FilterDefaultDeny Yes
+FilterExtended On
+FilterURLs Off
+Filter "%s/filter"
+ConnectPort 443
+ConnectPort 80
+`, egressProxyPort, egressProxyDir)
+}
+
+// startEgressProxy creates the untrusted box's internal network and starts the
+// proxy that's its only way out, replacing the proxy of an earlier run. The
+// proxy sits on the default bridge and the box's network, where the box
+// reaches it as tape-egress.
+func startEgressProxy(boxConfig BoxConfig, globalConfig GlobalConfig) error {
+	cli, err := newBoxClient(boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	ctx := context.Background()
+	removeEgressProxy(ctx, cli, boxConfig.Name)
+
+	name := untrustedNetworkName(boxConfig)
+	network, err := cli.FindNetwork(ctx, name)
+	if container.IsNetworkNotFound(err) {
+		network, err = cli.CreateInternalNetwork(ctx, name, map[string]string{NetworkLabel: name})
+	}
+	if err != nil {
+		return fmt.Errorf("error creating network %s: %w", name, err)
+	}
+
+	exists, err := cli.HasImage(ctx, EgressProxyImage)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		if err := pullImage(ctx, cli, boxConfig, EgressProxyImage); err != nil {
+			return err
+		}
+	}
+
+	proxy, err := cli.CreateContainer(ctx, container.ContainerConfig{
+		Image:   EgressProxyImage,
+		Command: []string{"sh", "-c", fmt.Sprintf("apk add --no-cache tinyproxy >/dev/null && exec tinyproxy -d -c %s/tinyproxy.conf", egressProxyDir)},
+		Name:    boxConfig.ContainerName() + "-egress",
+		Labels:  map[string]string{EgressProxyEnvLabel: boxConfig.Name},
+	})
+	if err != nil {
+		return err
+	}
+
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	err = writeTarFile(tw, "tape-egress/tinyproxy.conf", []byte(egressProxyConfig()))
+	if err == nil {
+		err = writeTarFile(tw, "tape-egress/filter", []byte(egressFilter(globalConfig.Untrusted.AllowedHostsOrDefault())))
+	}
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = cli.CopyToContainer(ctx, proxy.ID, "/etc", &archive)
+	}
+	if err == nil {
+		err = cli.ConnectNetwork(ctx, network.ID, proxy.ID, []string{egressProxyAlias})
+	}
+	if err == nil {
+		err = cli.StartContainer(ctx, proxy.ID)
+	}
+	if err != nil {
+		cli.RemoveContainer(ctx, proxy.ID)
+		return fmt.Errorf("error starting egress proxy: %w", err)
+	}
+	return nil
+}
+
+// removeEgressProxy removes the egress proxy of an untrusted box. It's best
+// effort like removePortProxies, the box can't reach out without it anyway.
+func removeEgressProxy(ctx context.Context, cli container.Backend, envName string) {
+	proxies, err := cli.FindContainers(ctx, []string{EgressProxyEnvLabel + "=" + envName})
+	if container.IsContainerNotFound(err) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(Stderr, "Warning: error finding egress proxy: %v\n", err)
+		return
+	}
+	for _, proxy := range proxies {
+		if err := cli.RemoveContainer(ctx, proxy.ID); err != nil {
+			fmt.Fprintf(Stderr, "Warning: error removing egress proxy: %v\n", err)
+		}
+	}
+}
+
+// removeUntrustedNetwork removes the internal network of an untrusted box
+// once its container is gone
+func removeUntrustedNetwork(ctx context.Context, cli container.Backend, boxConfig BoxConfig) {
+	network, err := cli.FindNetwork(ctx, untrustedNetworkName(boxConfig))
+	if err != nil {
+		return
+	}
+	if err := cli.RemoveNetwork(ctx, network.ID); err != nil {
+		fmt.Fprintf(Stderr, "Warning: error removing network %s: %v\n", network.Name, err)
+	}
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestSandboxConfig(t *testing.T) {
+	var config devcontainer.DevContainerConfig
+	err := json.Unmarshal([]byte(`{
+		"image": "ubuntu",
+		"initializeCommand": "curl https://example.com | sh",
+		"onCreateCommand": "npm install",
+		"postCreateCommand": "make setup",
+		"mounts": [
+			"source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind",
+			"source=cache,target=/cache,type=volume"
+		],
+		"runArgs": ["-v", "/var/run/docker.sock:/var/run/docker.sock", "--network=host", "--volume=/data:/data", "--cpus", "2"],
+		"containerEnv": {"FOO": "bar"}
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sandboxConfig(&config, "tape-untrusted-box", []string{"postCreateCommand"})
+
+	if config.InitializeCommand != nil {
+		t.Errorf("initializeCommand = %v, want nil", config.InitializeCommand)
+	}
+	if config.PostCreateCommand != nil {
+		t.Errorf("postCreateCommand = %v, want nil", config.PostCreateCommand)
+	}
+	if config.OnCreateCommand == nil {
+		t.Error("onCreateCommand was removed, it isn't unsafe")
+	}
+	var mounts []string
+	for _, mount := range config.Mounts {
+		mounts = append(mounts, mount.AsString())
+	}
+	if expected := []string{"source=cache,target=/cache,type=volume"}; !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("mounts = %v, want %v", mounts, expected)
+	}
+	expectedArgs := []string{"--volume", "/data:/data", "--cpus", "2", "--network", "tape-untrusted-box"}
+	if !reflect.DeepEqual(config.RunArgs, expectedArgs) {
+		t.Errorf("runArgs = %v, want %v", config.RunArgs, expectedArgs)
+	}
+	if config.ContainerEnv["FOO"] != "bar" || config.ContainerEnv["HTTPS_PROXY"] != "http://tape-egress:8888" {
+		t.Errorf("containerEnv = %v, want FOO and the egress proxy", config.ContainerEnv)
+	}
+}
+
+func TestDockerSocketViolations(t *testing.T) {
+	featureArgs := map[string][]string{
+		"ghcr.io/devcontainers/features/docker-outside-of-docker:1": FeatureMetadata{
+			Mounts: []devcontainer.MountValue{devcontainer.NewMountString("source=/var/run/docker.sock,target=/var/run/docker-host.sock,type=bind")},
+		}.RunArgs(),
+		"ghcr.io/devcontainers/features/node:1": FeatureMetadata{
+			Mounts: []devcontainer.MountValue{devcontainer.NewMountString("source=node-cache,target=/cache,type=volume")},
+		}.RunArgs(),
+	}
+
+	expected := []string{"feature ghcr.io/devcontainers/features/docker-outside-of-docker:1 mounts the docker socket, which is not allowed"}
+	if got := dockerSocketViolations(featureArgs); !reflect.DeepEqual(got, expected) {
+		t.Errorf("dockerSocketViolations() = %q, want %q", got, expected)
+	}
+}
+
+func TestStripHostBinds(t *testing.T) {
+	var config devcontainer.DevContainerConfig
+	err := json.Unmarshal([]byte(`{
+		"image": "ubuntu",
+		"workspaceMount": "source=/,target=/workspace,type=bind",
+		"workspaceFolder": "/workspace",
+		"mounts": [
+			"source=${localWorkspaceFolder}/data,target=/data,type=bind",
+			"source=${localWorkspaceFolder}/..,target=/parent,type=bind",
+			"source=/var/run,target=/r,type=bind",
+			"source=${localEnv:HOME}/.ssh,target=/ssh,type=bind",
+			"source=cache,target=/cache,type=volume"
+		],
+		"runArgs": ["-v", "/:/host", "--volume=/src/app/out:/out", "-v", "node_modules:/app/node_modules",
+			"--mount", "type=bind,source=/var/run,target=/r", "--cpus", "2"]
+	}`), &config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stripHostBinds(&config, "/src/app")
+
+	var mounts []string
+	for _, mount := range config.Mounts {
+		mounts = append(mounts, mount.AsString())
+	}
+	expected := []string{"source=${localWorkspaceFolder}/data,target=/data,type=bind", "source=cache,target=/cache,type=volume"}
+	if !reflect.DeepEqual(mounts, expected) {
+		t.Errorf("mounts = %v, want %v", mounts, expected)
+	}
+	expectedArgs := []string{"--volume", "/src/app/out:/out", "-v", "node_modules:/app/node_modules", "--cpus", "2"}
+	if !reflect.DeepEqual(config.RunArgs, expectedArgs) {
+		t.Errorf("runArgs = %v, want %v", config.RunArgs, expectedArgs)
+	}
+	if config.WorkspaceMount != "" || config.WorkspaceFolder != "" {
+		t.Errorf("workspaceMount = %q, workspaceFolder = %q, want both removed", config.WorkspaceMount, config.WorkspaceFolder)
+	}
+}
+
+func TestUntrustedViolations(t *testing.T) {
+	config, err := devcontainer.ParseDevContainer([]byte(`{"dockerComposeFile": "compose.yml", "service": "app"}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+	featureArgs := map[string][]string{
+		"ghcr.io/example/features/host-config:1": FeatureMetadata{
+			Mounts: []devcontainer.MountValue{devcontainer.NewMountString("source=/etc,target=/host-etc,type=bind")},
+		}.RunArgs(),
+	}
+
+	expected := []string{
+		"dockerComposeFile is not allowed, compose services can't be sandboxed",
+		"feature ghcr.io/example/features/host-config:1 mounts host paths, which is not allowed",
+	}
+	got := untrustedViolations(BoxConfig{Workspace: "/src/app"}, config, featureArgs)
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("untrustedViolations() = %q, want %q", got, expected)
+	}
+
+	capAdd := untrustedPolicy.violations(BoxConfig{}, &devcontainer.DevContainerConfig{RunArgs: []string{"--cap-add", "SYS_ADMIN"}}, nil)
+	if len(capAdd) != 1 {
+		t.Errorf("untrustedPolicy.violations() of --cap-add = %q, want one", capAdd)
+	}
+}
+
+func TestEgressFilter(t *testing.T) {
+	expected := "^github\\.com$\n^.+\\.githubusercontent\\.com$\n"
+	if got := egressFilter([]string{"github.com", "*.githubusercontent.com"}); got != expected {
+		t.Errorf("egressFilter() = %q, want %q", got, expected)
+	}
+}
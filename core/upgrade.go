@@ -0,0 +1,352 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+// FeatureDigestsLabel records the manifest digests of a container's features
+// when it was created, so tape upgrade can tell when they were updated
+const FeatureDigestsLabel = "tape.feature-digests"
+
+// imageManifestMediaTypes are the manifests an image tag can point to. Docker
+// records the digest of whichever the registry returns for them.
+var imageManifestMediaTypes = strings.Join([]string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+	ociManifestMediaType,
+}, ", ")
+
+// ImageUpdate is a base image whose tag points to a newer digest than the
+// local image's. Current is empty when the image isn't available locally.
+type ImageUpdate struct {
+	Image   string
+	Current string
+	Latest  string
+}
+
+// FeatureUpdate is a feature whose tag points to a newer digest than when
+// the container was created. Current is empty when that wasn't recorded.
+type FeatureUpdate struct {
+	Feature string
+	Current string
+	Latest  string
+	// Version is the feature's version at Latest, when its publisher recorded it
+	Version string
+}
+
+// UpgradePlan is what upgrading a box would update. The updates of a box
+// with a lock file are relative to the lock, and applying them updates it.
+type UpgradePlan struct {
+	EnvName  string
+	Locked   bool
+	Images   []ImageUpdate
+	Features []FeatureUpdate
+}
+
+// NeedsUpgrade reports whether there is anything to update
+func (p UpgradePlan) NeedsUpgrade() bool {
+	return len(p.Images) > 0 || len(p.Features) > 0
+}
+
+// UpgradeImage returns the image tape upgrade builds a box's new container
+// from, named after the box the way image names allow
+func UpgradeImage(envName string) string {
+	name := hostnameLabel(envName)
+	if name == "" {
+		name = "box"
+	}
+	return fmt.Sprintf("tape-upgrade/%s:latest", name)
+}
+
+// PlanUpgrade checks the registries for newer versions of the box's base
+// images and features. Boxes without a container have nothing to upgrade,
+// tape up builds them with the latest versions.
+func PlanUpgrade(envName string) (*UpgradePlan, error) {
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return nil, err
+	}
+
+	dc, err := FindDevContainer(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	registry, err := newBoxRegistryClient(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := upgradeConfig(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+	lock, err := LoadLock(*boxConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &UpgradePlan{EnvName: envName, Locked: lock != nil}
+	ctx := context.Background()
+	for _, image := range upgradeImages(*boxConfig, config) {
+		ref := container.ParseImageRef(image)
+		if ref.Digest != "" {
+			// pinned images can't change
+			continue
+		}
+		current, ok := "", false
+		if lock != nil {
+			current, ok = lock.Images[image]
+		}
+		if !ok {
+			current, err = cli.ImageDigest(ctx, image)
+			if err != nil {
+				return nil, err
+			}
+		}
+		latest, err := registry.manifestDigest(ref.Registry, ref.Repository, ref.Tag, imageManifestMediaTypes)
+		if err != nil {
+			return nil, fmt.Errorf("error checking %s for updates: %w", image, err)
+		}
+		if current != latest {
+			plan.Images = append(plan.Images, ImageUpdate{Image: image, Current: current, Latest: latest})
+		}
+	}
+
+	if config != nil && boxConfig.PrebuiltImage == "" {
+		features, err := config.FeatureList()
+		if err != nil {
+			return nil, err
+		}
+		recorded := parseDigestsLabel(dc.Labels[FeatureDigestsLabel])
+		if lock != nil {
+			for ref, locked := range lock.Features {
+				recorded[ref] = locked.Digest
+			}
+		}
+		plan.Features, err = featureUpdates(features, recorded, registry.resolveFeature)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
+
+// UpgradeBox applies an upgrade plan with as little downtime as possible:
+// the new image is built while the old container keeps running, and only
+// then is the container replaced
+func UpgradeBox(plan *UpgradePlan) (err error) {
+	envName := plan.EnvName
+	started := time.Now()
+	defer func() {
+		recordEvent(envName, "upgrade", fmt.Sprintf("%d images, %d features", len(plan.Images), len(plan.Features)), err)
+		notifyOperation(envName, "upgrade", started, err)
+	}()
+
+	boxConfig, err := LoadBoxConfig(envName)
+	if err != nil {
+		return err
+	}
+
+	cli, err := newBoxClient(*boxConfig)
+	if err != nil {
+		return fmt.Errorf("error creating container client: %v", err)
+	}
+	defer cli.Close()
+
+	// pulling moves the tags, the running container keeps the image it has.
+	// Locked images are pulled by digest once the lock is updated.
+	ctx := context.Background()
+	if plan.Locked {
+		if err := updateLock(*boxConfig, plan); err != nil {
+			return err
+		}
+	} else {
+		for _, update := range plan.Images {
+			if err := pullImage(ctx, cli, *boxConfig, update.Image); err != nil {
+				return err
+			}
+		}
+	}
+
+	// prebuilt images need no build, and boxes without a devcontainer config
+	// are built by the CLI from the workspace
+	if boxConfig.PrebuiltImage != "" || boxConfig.Config == "" {
+		return UpBox(envName, UpOptions{Recreate: true, upgrade: true})
+	}
+
+	image := UpgradeImage(envName)
+	args := []string{"--image-name", image}
+	if len(plan.Features) > 0 {
+		// the layers of a feature are cached by its reference, not its content
+		args = append(args, "--no-cache")
+	}
+	build := DevcontainerCommand{
+		BoxConfig:      *boxConfig,
+		Command:        "build",
+		AdditionalArgs: args,
+	}
+	// lock what isn't locked yet before building, like UpBox
+	lock, lockChanged, err := resolveLock(*boxConfig)
+	if err != nil {
+		fmt.Fprintf(Stdout, "Warning: not locking %s: %v\n", envName, err)
+	} else {
+		build.lock = lock
+	}
+	fmt.Fprintf(Stdout, "Building the new image of %s, the current container keeps running\n", envName)
+	if err := build.Execute(); err != nil {
+		return fmt.Errorf("error building %s: %w", image, err)
+	}
+	if lockChanged {
+		writeResolvedLock(*boxConfig, lock)
+	}
+
+	fmt.Fprintf(Stdout, "Replacing the container of %s\n", envName)
+	return UpBox(envName, UpOptions{Recreate: true, Image: image, upgrade: true})
+}
+
+// updateLock writes the plan's latest digests to the box's lock file
+func updateLock(boxConfig BoxConfig, plan *UpgradePlan) error {
+	lock, err := LoadLock(boxConfig)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		lock = &BoxLock{}
+	}
+	if lock.Images == nil {
+		lock.Images = map[string]string{}
+	}
+	if lock.Features == nil {
+		lock.Features = map[string]LockedFeature{}
+	}
+	for _, update := range plan.Images {
+		lock.Images[update.Image] = update.Latest
+	}
+	for _, update := range plan.Features {
+		lock.Features[update.Feature] = LockedFeature{Version: update.Version, Digest: update.Latest}
+	}
+	return WriteLock(boxConfig, lock)
+}
+
+// upgradeConfig returns the box's effective devcontainer config with its
+// build paths resolved, or nil when the box has none
+func upgradeConfig(boxConfig BoxConfig) (*devcontainer.DevContainerConfig, error) {
+	if boxConfig.Config == "" {
+		return nil, nil
+	}
+	config, err := LoadConfig(boxConfig.Config)
+	if err != nil {
+		return nil, fmt.Errorf("error loading config: %v", err)
+	}
+	config = EffectiveConfig(boxConfig, config)
+	resolveBuildPaths(config, filepath.Dir(boxConfig.Config))
+	return config, nil
+}
+
+// upgradeImages returns the images a box is built from
+func upgradeImages(boxConfig BoxConfig, config *devcontainer.DevContainerConfig) []string {
+	if boxConfig.PrebuiltImage != "" {
+		return []string{boxConfig.PrebuiltImage}
+	}
+	return configImages(config)
+}
+
+// featureUpdates compares the digests recorded for a container's OCI
+// features with the ones latest returns. Features pinned to a digest can't
+// change and are skipped.
+func featureUpdates(features []devcontainer.Feature, recorded map[string]string, latest func(devcontainer.FeatureRef) (LockedFeature, error)) ([]FeatureUpdate, error) {
+	var updates []FeatureUpdate
+	for _, feature := range features {
+		if feature.Ref.Kind != devcontainer.FeatureRefOCI || feature.Ref.Digest != "" {
+			continue
+		}
+		resolved, err := latest(feature.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("error checking %s for updates: %w", feature.Ref, err)
+		}
+		current := recorded[feature.Ref.String()]
+		if current != resolved.Digest {
+			updates = append(updates, FeatureUpdate{
+				Feature: feature.Ref.String(),
+				Current: current,
+				Latest:  resolved.Digest,
+				Version: resolved.Version,
+			})
+		}
+	}
+	return updates, nil
+}
+
+// formatDigestsLabel formats digests as ref=digest pairs separated by commas
+func formatDigestsLabel(digests map[string]string) string {
+	pairs := make([]string, 0, len(digests))
+	for ref, digest := range digests {
+		pairs = append(pairs, ref+"="+digest)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// parseDigestsLabel is the inverse of formatDigestsLabel
+func parseDigestsLabel(label string) map[string]string {
+	digests := map[string]string{}
+	for _, pair := range strings.Split(label, ",") {
+		if ref, digest, found := strings.Cut(pair, "="); found {
+			digests[ref] = digest
+		}
+	}
+	return digests
+}
+
+// newBoxRegistryClient returns a registry client with the box's credentials
+func newBoxRegistryClient(boxConfig BoxConfig) (*registryClient, error) {
+	dockerConfig, err := container.LoadDockerConfig()
+	if err != nil {
+		return nil, err
+	}
+	client := newRegistryClient()
+	client.auth = func(registry string) (*container.RegistryAuth, error) {
+		return registryAuth(boxConfig, dockerConfig, registry)
+	}
+	return client, nil
+}
+
+// featureDigestsLabel returns the label recording the digests the box's OCI
+// features are built with, taken from the lock the build uses, or "" when
+// there are none. Upgrades treat features missing from it as outdated.
+func featureDigestsLabel(boxConfig BoxConfig, lock *BoxLock) string {
+	if boxConfig.PrebuiltImage != "" || len(lock.Features) == 0 {
+		return ""
+	}
+	config, err := upgradeConfig(boxConfig)
+	if err != nil || config == nil {
+		return ""
+	}
+	features, err := config.FeatureList()
+	if err != nil {
+		return ""
+	}
+	digests := map[string]string{}
+	for _, feature := range features {
+		if locked, ok := lock.Features[feature.Ref.String()]; ok && feature.Ref.Digest == "" {
+			digests[feature.Ref.String()] = locked.Digest
+		}
+	}
+	return formatDigestsLabel(digests)
+}
@@ -0,0 +1,113 @@
+package core
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/devcontainer"
+)
+
+func TestFeatureUpdates(t *testing.T) {
+	config := &devcontainer.DevContainerConfig{Features: map[string]interface{}{
+		"ghcr.io/devcontainers/features/go:1":              map[string]interface{}{},
+		"ghcr.io/devcontainers/features/node:1":            map[string]interface{}{},
+		"ghcr.io/devcontainers/features/rust@sha256:0123":  map[string]interface{}{},
+		"ghcr.io/devcontainers/features/python:1":          map[string]interface{}{},
+		"./local-feature":                                  map[string]interface{}{},
+		"https://example.com/devcontainer-feature-foo.tgz": map[string]interface{}{},
+	}}
+	features, err := config.FeatureList()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorded := map[string]string{
+		"ghcr.io/devcontainers/features/go:1":   "sha256:old",
+		"ghcr.io/devcontainers/features/node:1": "sha256:node",
+	}
+	latest := func(ref devcontainer.FeatureRef) (LockedFeature, error) {
+		return LockedFeature{Version: "1.0.0", Digest: "sha256:" + ref.ID}, nil
+	}
+
+	updates, err := featureUpdates(features, recorded, latest)
+	if err != nil {
+		t.Fatalf("featureUpdates() error = %v", err)
+	}
+	expected := []FeatureUpdate{
+		{Feature: "ghcr.io/devcontainers/features/go:1", Current: "sha256:old", Latest: "sha256:go", Version: "1.0.0"},
+		{Feature: "ghcr.io/devcontainers/features/python:1", Current: "", Latest: "sha256:python", Version: "1.0.0"},
+	}
+	if !reflect.DeepEqual(updates, expected) {
+		t.Errorf("featureUpdates() = %+v, want %+v", updates, expected)
+	}
+
+	_, err = featureUpdates(features, recorded, func(devcontainer.FeatureRef) (LockedFeature, error) {
+		return LockedFeature{}, errors.New("registry is down")
+	})
+	if err == nil {
+		t.Errorf("featureUpdates() did not fail when the registry did")
+	}
+}
+
+func TestDigestsLabel(t *testing.T) {
+	digests := map[string]string{
+		"ghcr.io/devcontainers/features/node:1": "sha256:b",
+		"ghcr.io/devcontainers/features/go:1":   "sha256:a",
+	}
+	label := formatDigestsLabel(digests)
+	expected := "ghcr.io/devcontainers/features/go:1=sha256:a,ghcr.io/devcontainers/features/node:1=sha256:b"
+	if label != expected {
+		t.Errorf("formatDigestsLabel() = %q, want %q", label, expected)
+	}
+	if got := parseDigestsLabel(label); !reflect.DeepEqual(got, digests) {
+		t.Errorf("parseDigestsLabel() = %v, want %v", got, digests)
+	}
+	if got := parseDigestsLabel(""); len(got) != 0 {
+		t.Errorf("parseDigestsLabel(\"\") = %v, want an empty map", got)
+	}
+}
+
+func TestManifestDigest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/v2/library/ubuntu/manifests/24.04" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "manifest.list.v2+json") {
+			t.Errorf("Accept = %s, want the manifest list type", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Docker-Content-Digest", "sha256:abc")
+	}))
+	defer server.Close()
+
+	registry := strings.TrimPrefix(server.URL, "http://")
+	client := &registryClient{http: server.Client(), scheme: "http", tokens: map[string]string{}}
+	digest, err := client.manifestDigest(registry, "library/ubuntu", "24.04", imageManifestMediaTypes)
+	if err != nil {
+		t.Fatalf("manifestDigest() error = %v", err)
+	}
+	if digest != "sha256:abc" {
+		t.Errorf("manifestDigest() = %s, want sha256:abc", digest)
+	}
+
+	if _, err := client.manifestDigest(registry, "library/ubuntu", "missing", imageManifestMediaTypes); err == nil {
+		t.Errorf("manifestDigest() did not fail for a missing tag")
+	}
+}
+
+func TestUpgradeImage(t *testing.T) {
+	tests := map[string]string{
+		"app":          "tape-upgrade/app:latest",
+		"Team/API App": "tape-upgrade/team-api-app:latest",
+		"__":           "tape-upgrade/box:latest",
+	}
+	for name, expected := range tests {
+		if image := UpgradeImage(name); image != expected {
+			t.Errorf("UpgradeImage(%q) = %s, want %s", name, image, expected)
+		}
+	}
+}
@@ -0,0 +1,5 @@
+package core
+
+// Version is the tape version, set at build time with
+// -ldflags "-X github.com/mikeocool/tape/core.Version=..."
+var Version = "dev"
@@ -0,0 +1,248 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// DefaultWatchIgnore are directory and file names skipped when watching a workspace
+var DefaultWatchIgnore = []string{".git", "node_modules"}
+
+// WatchOptions configures WatchWorkspace
+type WatchOptions struct {
+	// Poll scans the workspace for changes every Interval instead of relying
+	// on file system events, for file systems that don't report them, e.g.
+	// network mounts
+	Poll bool
+	// Interval is how often the workspace is scanned for changes when polling
+	Interval time.Duration
+	// Debounce is how long the workspace must be unchanged before onChange is called
+	Debounce time.Duration
+	// Ignore are glob patterns matched against file and directory names
+	Ignore []string
+}
+
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchWorkspace watches dir for changes on the host and calls onChange
+// with the changed paths, relative to dir, once they settle, until ctx is
+// cancelled. Watching on the host avoids relying on events making it
+// through the bind mount into the container.
+func WatchWorkspace(ctx context.Context, dir string, opts WatchOptions, onChange func(changed []string)) error {
+	if opts.Poll {
+		return pollWorkspace(ctx, dir, opts, onChange)
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("error watching %s: %w, try --poll", dir, err)
+	}
+	defer watcher.Close()
+	if _, err := addWatches(watcher, dir, dir, opts.Ignore); err != nil {
+		return err
+	}
+
+	// stopped until the first change
+	settled := time.NewTimer(opts.Debounce)
+	settled.Stop()
+	var pending []string
+	addPending := func(paths ...string) {
+		for _, path := range paths {
+			if !slices.Contains(pending, path) {
+				pending = append(pending, path)
+			}
+		}
+		settled.Reset(opts.Debounce)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-watcher.Errors:
+			return fmt.Errorf("error watching %s: %w", dir, err)
+		case event := <-watcher.Events:
+			rel, err := filepath.Rel(dir, event.Name)
+			if err != nil || event.Op == fsnotify.Chmod || watchIgnoredPath(rel, opts.Ignore) {
+				continue
+			}
+			// directories aren't watched recursively, new ones are added with
+			// the files created in them before they were watched
+			if event.Has(fsnotify.Create) {
+				files, err := addWatches(watcher, dir, event.Name, opts.Ignore)
+				if err != nil {
+					return err
+				}
+				if files != nil {
+					addPending(files...)
+					continue
+				}
+			}
+			addPending(rel)
+		case <-settled.C:
+			sort.Strings(pending)
+			onChange(pending)
+			pending = nil
+		}
+	}
+}
+
+// addWatches watches path and the directories under it, skipping ignored
+// ones, and returns the files in them relative to dir. It returns nil when
+// path isn't a directory.
+func addWatches(watcher *fsnotify.Watcher, dir string, path string, ignore []string) ([]string, error) {
+	files := []string{}
+	err := filepath.WalkDir(path, func(current string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files can disappear while walking
+			if current != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if current == path && !d.IsDir() {
+			files = nil
+			return filepath.SkipAll
+		}
+		if current != dir && watchIgnored(d.Name(), ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			rel, err := filepath.Rel(dir, current)
+			if err != nil {
+				return err
+			}
+			files = append(files, rel)
+			return nil
+		}
+		if err := watcher.Add(current); err != nil {
+			return fmt.Errorf("error watching %s: %w, try --poll", current, err)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// watchIgnoredPath reports whether any of the names in a path relative to the
+// watched directory is ignored
+func watchIgnoredPath(rel string, ignore []string) bool {
+	return slices.ContainsFunc(strings.Split(rel, string(filepath.Separator)), func(name string) bool {
+		return watchIgnored(name, ignore)
+	})
+}
+
+// pollWorkspace is WatchWorkspace scanning dir every opts.Interval
+func pollWorkspace(ctx context.Context, dir string, opts WatchOptions, onChange func(changed []string)) error {
+	previous, err := scanTree(dir, opts.Ignore)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+
+	var pending []string
+	var lastChange time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := scanTree(dir, opts.Ignore)
+		if err != nil {
+			return err
+		}
+		if changed := diffTrees(previous, current); len(changed) > 0 {
+			for _, path := range changed {
+				if !slices.Contains(pending, path) {
+					pending = append(pending, path)
+				}
+			}
+			lastChange = time.Now()
+		}
+		previous = current
+
+		if len(pending) > 0 && time.Since(lastChange) >= opts.Debounce {
+			sort.Strings(pending)
+			onChange(pending)
+			pending = nil
+		}
+	}
+}
+
+// scanTree returns the state of every file under dir, relative to dir
+func scanTree(dir string, ignore []string) (map[string]fileState, error) {
+	files := map[string]fileState{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files can disappear while scanning
+			if path != dir && errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if path != dir && watchIgnored(d.Name(), ignore) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = fileState{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return files, err
+}
+
+func watchIgnored(name string, ignore []string) bool {
+	for _, pattern := range ignore {
+		if matched, _ := filepath.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// diffTrees returns the paths added, removed or modified between two scans
+func diffTrees(previous, current map[string]fileState) []string {
+	var changed []string
+	for path, state := range current {
+		if old, ok := previous[path]; !ok || old != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
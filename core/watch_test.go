@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScanAndDiffTrees(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("main.go", "package main")
+	write("pkg/util.go", "package pkg")
+	write(".git/HEAD", "ref: main")
+	write("node_modules/dep/index.js", "")
+
+	before, err := scanTree(dir, DefaultWatchIgnore)
+	if err != nil {
+		t.Fatalf("scanTree() error = %v", err)
+	}
+	if len(before) != 2 {
+		t.Errorf("scanTree() = %v, want main.go and pkg/util.go", before)
+	}
+
+	write("main.go", "package main // changed")
+	write("pkg/new.go", "package pkg")
+	write(".git/HEAD", "ref: other")
+	os.Remove(filepath.Join(dir, "pkg/util.go"))
+	// make sure the rewrite is visible even with coarse mtimes
+	os.Chtimes(filepath.Join(dir, "main.go"), time.Now(), time.Now().Add(time.Second))
+
+	after, err := scanTree(dir, DefaultWatchIgnore)
+	if err != nil {
+		t.Fatalf("scanTree() error = %v", err)
+	}
+
+	expected := []string{"main.go", filepath.Join("pkg", "new.go"), filepath.Join("pkg", "util.go")}
+	if got := diffTrees(before, after); !reflect.DeepEqual(got, expected) {
+		t.Errorf("diffTrees() = %v, want %v", got, expected)
+	}
+	if got := diffTrees(after, after); len(got) != 0 {
+		t.Errorf("diffTrees() = %v for identical scans, want none", got)
+	}
+}
+
+func TestWatchWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "node_modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes := make(chan []string, 10)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- WatchWorkspace(ctx, dir, WatchOptions{Debounce: 50 * time.Millisecond, Ignore: DefaultWatchIgnore}, func(changed []string) {
+			changes <- changed
+		})
+	}()
+	// give the watcher time to start
+	time.Sleep(200 * time.Millisecond)
+
+	os.WriteFile(filepath.Join(dir, "node_modules", "dep.js"), nil, 0644)
+	os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644)
+	os.MkdirAll(filepath.Join(dir, "pkg", "util"), 0755)
+	os.WriteFile(filepath.Join(dir, "pkg", "util", "util.go"), []byte("package util"), 0644)
+
+	var changed []string
+	deadline := time.After(5 * time.Second)
+	for !slices.Contains(changed, "main.go") || !slices.Contains(changed, filepath.Join("pkg", "util", "util.go")) {
+		select {
+		case c := <-changes:
+			changed = append(changed, c...)
+		case err := <-errs:
+			t.Fatalf("WatchWorkspace() error = %v", err)
+		case <-deadline:
+			t.Fatalf("WatchWorkspace() reported %v, want main.go and pkg/util/util.go", changed)
+		}
+	}
+	if slices.ContainsFunc(changed, func(path string) bool { return strings.HasPrefix(path, "node_modules") }) {
+		t.Errorf("WatchWorkspace() reported %v, want node_modules ignored", changed)
+	}
+}
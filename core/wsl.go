@@ -0,0 +1,48 @@
+package core
+
+import (
+	"os"
+	"strings"
+)
+
+// InWSL reports whether tape runs inside WSL, where workspace paths may be
+// given in their Windows form
+func InWSL() bool {
+	if os.Getenv("WSL_DISTRO_NAME") != "" {
+		return true
+	}
+	release, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	return err == nil && strings.Contains(strings.ToLower(string(release)), "microsoft")
+}
+
+// wslPath converts a Windows path to the path of the same file inside WSL.
+// Paths into a distro's filesystem, \\wsl$\<distro>\... or
+// \\wsl.localhost\<distro>\..., become the Linux path in the distro and drive
+// paths become their /mnt mount. Other paths are returned unchanged.
+func wslPath(p string) string {
+	slashed := strings.ReplaceAll(p, `\`, "/")
+	lower := strings.ToLower(slashed)
+	for _, prefix := range []string{"//wsl$/", "//wsl.localhost/"} {
+		if !strings.HasPrefix(lower, prefix) {
+			continue
+		}
+		// drop the distro name
+		_, rest, _ := strings.Cut(slashed[len(prefix):], "/")
+		return "/" + rest
+	}
+
+	if len(slashed) >= 2 && slashed[1] == ':' && isDriveLetter(slashed[0]) {
+		return strings.TrimSuffix("/mnt/"+strings.ToLower(slashed[:1])+"/"+strings.TrimPrefix(slashed[2:], "/"), "/")
+	}
+	return p
+}
+
+// onWindowsDrive reports whether a WSL path is on a Windows drive mounted
+// under /mnt, which is much slower to bind mount than the distro's own filesystem
+func onWindowsDrive(p string) bool {
+	rest, ok := strings.CutPrefix(p, "/mnt/")
+	if !ok || rest == "" || !isDriveLetter(rest[0]) {
+		return false
+	}
+	return len(rest) == 1 || rest[1] == '/'
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// matches the errors yaml.v2 reports for keys that don't map to a struct field
+var unknownFieldPattern = regexp.MustCompile(`^line (\d+): field (\S+) not found in type (\S+)$`)
+
+// unmarshalStrict decodes YAML into out, rejecting unknown keys with an error
+// that names the closest known key
+func unmarshalStrict(data []byte, out interface{}) error {
+	err := yaml.UnmarshalStrict(data, out)
+	if err == nil {
+		return nil
+	}
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	knownFields := yamlFieldsByType(reflect.TypeOf(out))
+
+	messages := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		match := unknownFieldPattern.FindStringSubmatch(msg)
+		if match == nil {
+			messages[i] = msg
+			continue
+		}
+
+		line, _ := strconv.Atoi(match[1])
+		field := match[2]
+		messages[i] = fmt.Sprintf("unknown field %q at line %d", field, line)
+		if suggestion := closestField(field, knownFields[match[3]]); suggestion != "" {
+			messages[i] += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+	}
+
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// yamlFieldsByType returns the YAML keys of a struct and its nested structs,
+// keyed by type name as it appears in yaml.v2 errors (e.g. core.BoxConfig)
+func yamlFieldsByType(t reflect.Type) map[string][]string {
+	fields := map[string][]string{}
+
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Map {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return
+		}
+		if _, seen := fields[t.String()]; seen {
+			return
+		}
+		fields[t.String()] = []string{}
+
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+
+			name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+			if name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+
+			fields[t.String()] = append(fields[t.String()], name)
+			walk(field.Type)
+		}
+	}
+	walk(t)
+
+	return fields
+}
+
+// closestField returns the known field nearest to field by edit distance, or
+// empty string if none is close enough to be a plausible typo
+func closestField(field string, known []string) string {
+	best := ""
+	bestDistance := len(field)/2 + 1
+	for _, candidate := range known {
+		distance := editDistance(field, candidate)
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,345 @@
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+)
+
+// Environment is an entry in the environment list
+type Environment struct {
+	Name  string        `json:"name"`
+	State core.BoxState `json:"state"`
+	// Error is set when the environment's state couldn't be read
+	Error string `json:"error,omitempty"`
+}
+
+// ExecRequest runs a command in an environment without a terminal
+type ExecRequest struct {
+	Command    []string `json:"command"`
+	User       string   `json:"user,omitempty"`
+	WorkingDir string   `json:"workingDir,omitempty"`
+	Env        []string `json:"env,omitempty"`
+}
+
+// ExecResponse is the outcome of an ExecRequest
+type ExecResponse struct {
+	ExitCode int    `json:"exitCode"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// ForwardRequest forwards a host address to a port in an environment
+type ForwardRequest struct {
+	ContainerPort int `json:"containerPort"`
+	// Address is the host:port to listen on, 127.0.0.1 with a free port by default
+	Address string `json:"address,omitempty"`
+}
+
+// Forward is a port forward the daemon runs
+type Forward struct {
+	ID            string `json:"id"`
+	EnvName       string `json:"env"`
+	ContainerPort int    `json:"containerPort"`
+	Address       string `json:"address"`
+	// Auto is set for the forwards of ports tape detected, see autoForward
+	Auto bool `json:"auto,omitempty"`
+	// Notification is the port's onAutoForward notification, empty when it's silent
+	Notification string `json:"notification,omitempty"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+	// Kind identifies the errors callers handle, see errorKind
+	Kind string `json:"kind,omitempty"`
+}
+
+// api serves the core operations over HTTP, see newAPIHandler for the routes
+type api struct {
+	mu       sync.Mutex
+	forwards map[string]*runningForward
+	nextID   int
+	// ignoredPorts are the env:port keys autoForward leaves alone
+	ignoredPorts map[string]bool
+}
+
+type runningForward struct {
+	Forward
+	cancel context.CancelFunc
+}
+
+func newAPI() *api {
+	return &api{forwards: map[string]*runningForward{}, ignoredPorts: map[string]bool{}}
+}
+
+func newAPIHandler(a *api) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/ping", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"version": core.Version})
+	})
+	mux.HandleFunc("GET /v1/environments", a.listEnvironments)
+	mux.HandleFunc("GET /v1/environments/{name...}", a.getEnvironment)
+	mux.HandleFunc("POST /v1/up/{name...}", a.up)
+	mux.HandleFunc("POST /v1/stop/{name...}", a.stop)
+	mux.HandleFunc("POST /v1/exec/{name...}", a.exec)
+	mux.HandleFunc("GET /v1/forwards", a.listForwards)
+	mux.HandleFunc("POST /v1/forwards/{name...}", a.createForward)
+	mux.HandleFunc("DELETE /v1/forwards/{id}", a.deleteForward)
+	return mux
+}
+
+// ListEnvironments returns the configured environments and their states
+func ListEnvironments() ([]Environment, error) {
+	envNames, err := core.ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	environments := make([]Environment, len(envNames))
+	for i, envName := range envNames {
+		environments[i].Name = envName
+		summary, err := core.CachedBoxSummary(envName)
+		if err != nil {
+			environments[i].Error = err.Error()
+			continue
+		}
+		environments[i].State = summary.State
+	}
+	return environments, nil
+}
+
+func (a *api) listEnvironments(w http.ResponseWriter, r *http.Request) {
+	environments, err := ListEnvironments()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, environments)
+}
+
+func (a *api) getEnvironment(w http.ResponseWriter, r *http.Request) {
+	summary, err := core.GetBoxSummary(r.PathValue("name"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+func (a *api) up(w http.ResponseWriter, r *http.Request) {
+	var opts core.UpOptions
+	if !readJSON(w, r, &opts) {
+		return
+	}
+	if err := core.UpBox(r.PathValue("name"), opts); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) stop(w http.ResponseWriter, r *http.Request) {
+	if err := core.StopBox(r.PathValue("name")); err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *api) exec(w http.ResponseWriter, r *http.Request) {
+	var req ExecRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if len(req.Command) == 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "no command to run"})
+		return
+	}
+
+	result, err := core.RunInBox(r.PathValue("name"), core.ExecOptions{
+		Command:    req.Command,
+		User:       req.User,
+		WorkingDir: req.WorkingDir,
+		Env:        req.Env,
+	})
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, ExecResponse{
+		ExitCode: result.ExitCode,
+		Stdout:   string(result.Stdout),
+		Stderr:   string(result.Stderr),
+	})
+}
+
+func (a *api) listForwards(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	forwards := make([]Forward, 0, len(a.forwards))
+	for _, f := range a.forwards {
+		forwards = append(forwards, f.Forward)
+	}
+	a.mu.Unlock()
+
+	slices.SortFunc(forwards, func(x, y Forward) int {
+		return strings.Compare(x.ID, y.ID)
+	})
+	writeJSON(w, http.StatusOK, forwards)
+}
+
+func (a *api) createForward(w http.ResponseWriter, r *http.Request) {
+	envName := r.PathValue("name")
+	var req ForwardRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+	if req.ContainerPort <= 0 {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: "containerPort is required"})
+		return
+	}
+	if req.Address == "" {
+		req.Address = "127.0.0.1:0"
+	}
+
+	// check the box is running before listening, ForwardPort only fails later
+	if err := core.RequireRunning(envName); err != nil {
+		writeError(w, err)
+		return
+	}
+	// a taken port is moved to the next free one, the response has the address
+	listener, err := core.ListenNextFree(req.Address)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, errorResponse{Error: err.Error()})
+		return
+	}
+
+	forward := a.startForward(envName, req.ContainerPort, listener, false)
+	writeJSON(w, http.StatusCreated, forward)
+}
+
+// startForward forwards connections on listener to the box's port until the
+// forward is deleted, and carries out the port's onAutoForward behavior
+func (a *api) startForward(envName string, containerPort int, listener net.Listener, auto bool) Forward {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.mu.Lock()
+	a.nextID++
+	forward := &runningForward{
+		Forward: Forward{
+			ID:            strconv.Itoa(a.nextID),
+			EnvName:       envName,
+			ContainerPort: containerPort,
+			Address:       listener.Addr().String(),
+			Auto:          auto,
+		},
+		cancel: cancel,
+	}
+	a.forwards[forward.ID] = forward
+	a.mu.Unlock()
+
+	notification, err := core.OnForward(envName, containerPort, forward.Address)
+	if err != nil {
+		log.Printf("Warning: forward %s to %s:%d: %v", forward.Address, envName, containerPort, err)
+	}
+	if notification != "" {
+		log.Print(notification)
+	}
+
+	go func() {
+		if err := core.ForwardPort(ctx, envName, listener, containerPort); err != nil {
+			log.Printf("Forward %s to %s:%d stopped: %v", forward.Address, envName, containerPort, err)
+		}
+		a.mu.Lock()
+		delete(a.forwards, forward.ID)
+		a.mu.Unlock()
+	}()
+
+	started := forward.Forward
+	started.Notification = notification
+	return started
+}
+
+func (a *api) deleteForward(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	forward, ok := a.forwards[r.PathValue("id")]
+	a.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, errorResponse{Error: "no forward " + r.PathValue("id")})
+		return
+	}
+	if forward.Auto {
+		// keep autoForward from forwarding the port again
+		a.mu.Lock()
+		a.ignoredPorts[fmt.Sprintf("%s:%d", forward.EnvName, forward.ContainerPort)] = true
+		a.mu.Unlock()
+	}
+	forward.cancel()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeJSON(w, http.StatusBadRequest, errorResponse{Error: fmt.Sprintf("invalid request body: %v", err)})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError responds with the status and kind for err, which Client turns
+// back into the matching error
+func writeError(w http.ResponseWriter, err error) {
+	kind := errorKind(err)
+	status := http.StatusInternalServerError
+	switch kind {
+	case kindNotFound:
+		status = http.StatusNotFound
+	case kindNotRunning, kindAmbiguous, kindNoContainer:
+		status = http.StatusConflict
+	case kindDockerUnavailable:
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, errorResponse{Error: err.Error(), Kind: kind})
+}
+
+const (
+	kindNotFound          = "not-found"
+	kindNotRunning        = "not-running"
+	kindAmbiguous         = "ambiguous"
+	kindNoContainer       = "no-container"
+	kindDockerUnavailable = "docker-unavailable"
+)
+
+func errorKind(err error) string {
+	switch {
+	case errors.Is(err, core.ErrConfigNotFound):
+		return kindNotFound
+	case errors.Is(err, core.ErrNotRunning):
+		return kindNotRunning
+	case errors.Is(err, core.ErrAmbiguousContainer):
+		return kindAmbiguous
+	case container.IsContainerNotFound(err):
+		return kindNoContainer
+	case errors.Is(err, core.ErrDockerUnavailable):
+		return kindDockerUnavailable
+	}
+	return ""
+}
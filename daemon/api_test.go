@@ -0,0 +1,72 @@
+package daemon
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/mikeocool/tape/core"
+)
+
+func startAPI(t *testing.T) *Client {
+	t.Helper()
+
+	original := core.ConfigDir
+	core.ConfigDir = t.TempDir()
+	t.Cleanup(func() { core.ConfigDir = original })
+
+	socket := filepath.Join(core.ConfigDir, "daemon.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	server := &http.Server{Handler: newAPIHandler(newAPI())}
+	go server.Serve(listener)
+	t.Cleanup(func() { server.Close() })
+
+	client, err := Connect(socket)
+	if err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	return client
+}
+
+func TestAPIEnvironments(t *testing.T) {
+	client := startAPI(t)
+
+	environments, err := client.Environments()
+	if err != nil {
+		t.Fatalf("Environments() error = %v", err)
+	}
+	if len(environments) != 0 {
+		t.Errorf("Environments() = %v, want none", environments)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	client := startAPI(t)
+
+	_, err := client.BoxSummary("missing")
+	if !errors.Is(err, core.ErrConfigNotFound) {
+		t.Errorf("BoxSummary() error = %v, want ErrConfigNotFound", err)
+	}
+
+	err = client.DeleteForward("1")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound {
+		t.Errorf("DeleteForward() error = %v, want a 404", err)
+	}
+
+	_, err = client.Exec("missing", ExecRequest{})
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+		t.Errorf("Exec() error = %v, want a 400", err)
+	}
+}
+
+func TestConnectWithoutDaemon(t *testing.T) {
+	if _, err := Connect(filepath.Join(t.TempDir(), "daemon.sock")); err == nil {
+		t.Error("Connect() expected an error without a daemon")
+	}
+}
@@ -0,0 +1,121 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+)
+
+// autoForwardInterval is how often the running boxes are scanned for ports
+const autoForwardInterval = 2 * time.Second
+
+// autoForward forwards the ports processes in running boxes start listening
+// on, like VS Code does, until ctx is cancelled. Each is forwarded on the same
+// port of 127.0.0.1, or a free one when it's taken, unless its onAutoForward
+// is ignore, and the forward is stopped when the port is closed.
+func (a *api) autoForward(ctx context.Context) {
+	ticker := time.NewTicker(autoForwardInterval)
+	defer ticker.Stop()
+	for {
+		a.scanPorts()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// scanPorts starts forwards for the newly listening ports of running boxes
+// and stops the automatic forwards of ports no longer listening
+func (a *api) scanPorts() {
+	envNames, err := core.ListBoxConfigs()
+	if err != nil {
+		log.Printf("Error scanning ports: %v", err)
+		return
+	}
+
+	listening := map[string][]int{}
+	for _, envName := range envNames {
+		summary, err := core.GetBoxSummary(envName)
+		if err != nil || summary.State != core.BoxStateRunning {
+			continue
+		}
+		ports, err := core.ListeningPorts(envName)
+		if err != nil {
+			log.Printf("Error scanning ports of %s: %v", envName, err)
+			continue
+		}
+		listening[envName] = ports
+	}
+
+	a.mu.Lock()
+	forwarded := map[string]bool{}
+	var stale []*runningForward
+	for _, f := range a.forwards {
+		forwarded[fmt.Sprintf("%s:%d", f.EnvName, f.ContainerPort)] = true
+		if f.Auto && !slices.Contains(listening[f.EnvName], f.ContainerPort) {
+			stale = append(stale, f)
+		}
+	}
+	a.mu.Unlock()
+
+	for _, f := range stale {
+		log.Printf("Port %d of %s closed, stopping forward %s", f.ContainerPort, f.EnvName, f.Address)
+		f.cancel()
+	}
+
+	for envName, ports := range listening {
+		for _, port := range ports {
+			if forwarded[fmt.Sprintf("%s:%d", envName, port)] || a.ignored(envName, port) {
+				continue
+			}
+			listener, err := listenAutoForward(port)
+			if err != nil {
+				log.Printf("Error forwarding port %d of %s: %v", port, envName, err)
+				continue
+			}
+			a.startForward(envName, port, listener, true)
+		}
+	}
+}
+
+// ignored reports whether the port's onAutoForward keeps it from being
+// forwarded. Ignored ports are remembered, so their config is only read once.
+func (a *api) ignored(envName string, port int) bool {
+	key := fmt.Sprintf("%s:%d", envName, port)
+	a.mu.Lock()
+	ignored, ok := a.ignoredPorts[key]
+	a.mu.Unlock()
+	if ok {
+		return ignored
+	}
+
+	behavior, err := core.AutoForwardBehavior(envName, port)
+	if err != nil {
+		log.Printf("Error reading the attributes of port %d of %s: %v", port, envName, err)
+		return true
+	}
+	ignored = behavior == core.AutoForwardIgnore
+	if ignored {
+		a.mu.Lock()
+		a.ignoredPorts[key] = true
+		a.mu.Unlock()
+	}
+	return ignored
+}
+
+// listenAutoForward listens on the same port on the host as in the box when
+// it's free, so URLs the box prints work, else on any free port
+func listenAutoForward(port int) (net.Listener, error) {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err == nil {
+		return listener, nil
+	}
+	return net.Listen("tcp", "127.0.0.1:0")
+}
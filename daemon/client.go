@@ -0,0 +1,215 @@
+package daemon
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/mikeocool/tape/container"
+	"github.com/mikeocool/tape/core"
+)
+
+// APIError is an error the daemon responded with
+type APIError struct {
+	Status  int
+	Kind    string
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error the daemon's error stands for, so callers can
+// check for core's errors the same way as when calling core directly
+func (e *APIError) Unwrap() error {
+	switch e.Kind {
+	case kindNotFound:
+		return core.ErrConfigNotFound
+	case kindNotRunning:
+		return core.ErrNotRunning
+	case kindAmbiguous:
+		return core.ErrAmbiguousContainer
+	case kindNoContainer:
+		return &container.ContainerNotFoundError{}
+	case kindDockerUnavailable:
+		return core.ErrDockerUnavailable
+	}
+	return nil
+}
+
+// Client calls a running daemon's API over its unix socket
+type Client struct {
+	http *http.Client
+}
+
+// Connect returns a client for the daemon listening on socket, or an error
+// if none answers
+func Connect(socket string) (*Client, error) {
+	return connect(func(ctx context.Context) (net.Conn, error) {
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, "unix", socket)
+	}, time.Second)
+}
+
+// ConnectRemote returns a client for the daemon running on another machine,
+// given as [user@]host[:port]. It runs tape daemon dial-stdio there over SSH,
+// so tape has to be on the remote PATH.
+func ConnectRemote(host string) (*Client, error) {
+	args, err := container.SSHCommand(host, "tape", "daemon", DialStdioCommand)
+	if err != nil {
+		return nil, err
+	}
+	// leave time for the SSH handshake. Errors are discarded since callers fall
+	// back to running commands in process when no daemon answers.
+	return connect(func(ctx context.Context) (net.Conn, error) {
+		return container.DialCommand(context.WithoutCancel(ctx), args, io.Discard)
+	}, 10*time.Second)
+}
+
+func connect(dial func(context.Context) (net.Conn, error), timeout time.Duration) (*Client, error) {
+	c := &Client{http: &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dial(ctx)
+			},
+		},
+	}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := c.do(ctx, http.MethodGet, "/v1/ping", nil, nil); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Environments lists the configured environments and their states
+func (c *Client) Environments() ([]Environment, error) {
+	var environments []Environment
+	err := c.do(context.Background(), http.MethodGet, "/v1/environments", nil, &environments)
+	return environments, err
+}
+
+// BoxSummary returns the environment's summary, see core.GetBoxSummary
+func (c *Client) BoxSummary(envName string) (*core.BoxSummary, error) {
+	var summary core.BoxSummary
+	if err := c.do(context.Background(), http.MethodGet, "/v1/environments/"+url.PathEscape(envName), nil, &summary); err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// Up starts the environment, see core.UpBox. The devcontainer CLI's output
+// goes to the daemon's log.
+func (c *Client) Up(envName string, opts core.UpOptions) error {
+	return c.do(context.Background(), http.MethodPost, "/v1/up/"+url.PathEscape(envName), opts, nil)
+}
+
+// Stop stops the environment, see core.StopBox
+func (c *Client) Stop(envName string) error {
+	return c.do(context.Background(), http.MethodPost, "/v1/stop/"+url.PathEscape(envName), nil, nil)
+}
+
+// Exec runs a command in the environment and returns its output
+func (c *Client) Exec(envName string, req ExecRequest) (*ExecResponse, error) {
+	var resp ExecResponse
+	if err := c.do(context.Background(), http.MethodPost, "/v1/exec/"+url.PathEscape(envName), req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Forwards lists the port forwards the daemon runs
+func (c *Client) Forwards() ([]Forward, error) {
+	var forwards []Forward
+	err := c.do(context.Background(), http.MethodGet, "/v1/forwards", nil, &forwards)
+	return forwards, err
+}
+
+// CreateForward has the daemon forward a host address to a port in the environment
+func (c *Client) CreateForward(envName string, req ForwardRequest) (*Forward, error) {
+	var forward Forward
+	if err := c.do(context.Background(), http.MethodPost, "/v1/forwards/"+url.PathEscape(envName), req, &forward); err != nil {
+		return nil, err
+	}
+	return &forward, nil
+}
+
+// DeleteForward stops a port forward
+func (c *Client) DeleteForward(id string) error {
+	return c.do(context.Background(), http.MethodDelete, "/v1/forwards/"+url.PathEscape(id), nil, nil)
+}
+
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	// the host is ignored, requests go to the socket
+	req, err := http.NewRequestWithContext(ctx, method, "http://tape"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling tape daemon: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		var errResp errorResponse
+		if err := json.NewDecoder(resp.Body).Decode(&errResp); err != nil || errResp.Error == "" {
+			errResp.Error = fmt.Sprintf("tape daemon responded with %s", resp.Status)
+		}
+		return &APIError{Status: resp.StatusCode, Kind: errResp.Kind, Message: errResp.Error}
+	}
+
+	if result == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+		return fmt.Errorf("error reading tape daemon response: %v", err)
+	}
+	return nil
+}
+
+// DialStdioCommand is the tape daemon subcommand that connects its stdin and
+// stdout to the daemon's socket, see ConnectRemote
+const DialStdioCommand = "dial-stdio"
+
+// DialStdio copies stdin to the daemon listening on socket and its responses
+// to stdout until either side closes the connection
+func DialStdio(socket string, stdin io.Reader, stdout io.Writer) error {
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return fmt.Errorf("error connecting to the daemon: %w", err)
+	}
+	defer conn.Close()
+
+	done := make(chan error, 2)
+	go func() {
+		_, err := io.Copy(conn, stdin)
+		done <- err
+	}()
+	go func() {
+		_, err := io.Copy(stdout, conn)
+		done <- err
+	}()
+	return <-done
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/mikeocool/tape/ssh"
+)
+
+// Options configures the daemon
+type Options struct {
+	// MetricsAddress overrides the address from the global config
+	MetricsAddress string
+	// ProxyAddress overrides the reverse proxy's address from the global config
+	ProxyAddress string
+	// AutoForward forwards the ports boxes listen on, even when the global config doesn't
+	AutoForward bool
+}
+
+// Run runs the SSH server, the metrics endpoint and the API on
+// core.DaemonSocketPath until one of them fails or the daemon is interrupted.
+// It also notifies when containers crash, if notifications are configured,
+// serves the reverse proxy to the environments, if it has an address, and
+// forwards the ports boxes listen on, if auto-forwarding is on. Boxes with an
+// idle timeout are stopped once they have been idle for longer, and the
+// retention policy is enforced if it has an interval.
+func Run(opts Options) error {
+	globalConfig, err := core.LoadGlobalConfig()
+	if err != nil {
+		return err
+	}
+
+	address := opts.MetricsAddress
+	if address == "" {
+		address = globalConfig.Daemon.MetricsAddress
+	}
+	if address == "" {
+		address = core.DefaultMetricsAddress
+	}
+
+	proxyAddress := opts.ProxyAddress
+	if proxyAddress == "" {
+		proxyAddress = globalConfig.Daemon.ProxyAddress
+	}
+
+	// one for each server: SSH, metrics, the reverse proxy and the API
+	errs := make(chan error, 4)
+	go func() {
+		errs <- ssh.Start(ssh.Options{})
+	}()
+
+	started := time.Now()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metricsHandler(started))
+	server := &http.Server{Addr: address, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		log.Printf("Serving metrics on http://%s/metrics", address)
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("error serving metrics: %w", err)
+		}
+	}()
+
+	// the reverse proxy is optional, it needs a port of its own
+	var proxyServer *http.Server
+	if proxyAddress != "" {
+		proxyServer = &http.Server{Addr: proxyAddress, Handler: routeRequests(proxyHandler()), ReadHeaderTimeout: 10 * time.Second}
+		go func() {
+			log.Printf("Serving environments on http://<name>.%s, listening on %s", core.ProxyDomain, proxyAddress)
+			err := proxyServer.ListenAndServe()
+			if !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("error serving the reverse proxy: %w", err)
+			}
+		}()
+	}
+
+	socket := core.DaemonSocketPath()
+	if err := privateDir(filepath.Dir(socket)); err != nil {
+		return err
+	}
+	// a socket left behind by a daemon that didn't shut down cleanly
+	os.Remove(socket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("error listening on %s: %w", socket, err)
+	}
+	defer os.Remove(socket)
+	a := newAPI()
+	apiServer := &http.Server{Handler: newAPIHandler(a), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		log.Printf("Serving the API on %s", socket)
+		err := apiServer.Serve(listener)
+		if !errors.Is(err, http.ErrServerClosed) {
+			errs <- fmt.Errorf("error serving the API: %w", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		// missing crash notifications shouldn't take down the daemon
+		if err := core.WatchCrashes(ctx); err != nil {
+			log.Printf("Not watching for crashed containers: %v", err)
+		}
+	}()
+	go func() {
+		if err := core.WatchBoxStates(ctx); err != nil {
+			log.Printf("Not caching box states: %v", err)
+		}
+	}()
+	go stopIdleBoxes(ctx)
+	if interval := globalConfig.Retention.IntervalDuration(); interval > 0 {
+		log.Printf("Pruning every %s", interval)
+		go pruneOnSchedule(ctx, interval)
+	}
+	if opts.AutoForward || globalConfig.Daemon.AutoForward {
+		log.Printf("Forwarding the ports boxes listen on")
+		go a.autoForward(ctx)
+	}
+	select {
+	case err = <-errs:
+	case <-ctx.Done():
+	}
+	apiServer.Close()
+	server.Close()
+	if proxyServer != nil {
+		proxyServer.Close()
+	}
+	return err
+}
+
+// privateDir creates dir accessible only by the user, restricting it if it
+// already existed, before anything is created in it
+func privateDir(dir string) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("error creating %s: %w", dir, err)
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		return fmt.Errorf("error restricting access to %s: %w", dir, err)
+	}
+	return nil
+}
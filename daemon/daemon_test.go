@@ -0,0 +1,28 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPrivateDir(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("permissions are unix only")
+	}
+	dir := filepath.Join(t.TempDir(), ".daemon")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := privateDir(dir); err != nil {
+		t.Fatalf("privateDir() error = %v", err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("privateDir() permissions = %o, want 700", perm)
+	}
+}
@@ -0,0 +1,40 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+)
+
+// idleCheckInterval is how often the running boxes are checked for idleness
+const idleCheckInterval = time.Minute
+
+// stopIdleBoxes stops the boxes idle for longer than their idle timeout,
+// see core.StopIdleBox, until ctx is cancelled
+func stopIdleBoxes(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		envNames, err := core.ListBoxConfigs()
+		if err != nil {
+			log.Printf("Error checking for idle boxes: %v", err)
+			continue
+		}
+		for _, envName := range envNames {
+			stopped, err := core.StopIdleBox(envName)
+			if err != nil {
+				log.Printf("Error stopping idle box %s: %v", envName, err)
+			} else if stopped {
+				log.Printf("Stopped %s, it was idle for longer than its idle timeout", envName)
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+	"github.com/mikeocool/tape/ssh"
+)
+
+// metric is a metric family in the Prometheus text format
+type metric struct {
+	name    string
+	help    string
+	kind    string // gauge or counter
+	samples []sample
+}
+
+type sample struct {
+	labels []label
+	value  float64
+}
+
+type label struct {
+	name  string
+	value string
+}
+
+// boxStates are reported even when no environment is in them, so the series don't come and go
+var boxStates = []core.BoxState{
+	core.BoxStateRunning,
+	core.BoxStatePaused,
+	core.BoxStateStopped,
+	core.BoxStateCreated,
+	core.BoxStateRestarting,
+	core.BoxStateRemoving,
+	core.BoxStateDead,
+	core.BoxStateDoesNotExist,
+	core.BoxStateUnknown,
+}
+
+func metricsHandler(started time.Time) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		metrics, err := collectMetrics(started)
+		if err != nil {
+			log.Printf("Error collecting metrics: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writeMetrics(w, metrics)
+	})
+}
+
+// collectMetrics gathers the metrics at scrape time. Exec and idle stop
+// counts come from the history log, so they include events from before the
+// daemon started.
+func collectMetrics(started time.Time) ([]metric, error) {
+	envNames, err := core.ListBoxConfigs()
+	if err != nil {
+		return nil, err
+	}
+
+	states := map[core.BoxState]int{}
+	uptime := metric{
+		name: "tape_environment_uptime_seconds",
+		help: "Seconds since the environment's container started.",
+		kind: "gauge",
+	}
+	for _, envName := range envNames {
+		state := core.BoxStateUnknown
+		if summary, err := core.GetBoxSummary(envName); err == nil {
+			state = summary.State
+		}
+		states[state]++
+
+		if state == core.BoxStateRunning {
+			if d, err := core.BoxUptime(envName); err == nil {
+				uptime.samples = append(uptime.samples, sample{[]label{{"env", envName}}, d.Seconds()})
+			}
+		}
+	}
+
+	environments := metric{
+		name: "tape_environments",
+		help: "Configured environments by the state of their container.",
+		kind: "gauge",
+	}
+	for _, state := range boxStates {
+		environments.samples = append(environments.samples, sample{[]label{{"state", string(state)}}, float64(states[state])})
+	}
+
+	events, err := core.History("")
+	if err != nil {
+		return nil, err
+	}
+	type eventKey struct{ env, outcome string }
+	execCounts := map[eventKey]int{}
+	idleStopCounts := map[eventKey]int{}
+	for _, event := range events {
+		switch event.Operation {
+		case "exec":
+			execCounts[eventKey{event.EnvName, event.Outcome}]++
+		case core.IdleStopOperation:
+			idleStopCounts[eventKey{event.EnvName, event.Outcome}]++
+		}
+	}
+	execs := metric{
+		name: "tape_execs_total",
+		help: "Commands run in environments with tape exec.",
+		kind: "counter",
+	}
+	for key, count := range execCounts {
+		execs.samples = append(execs.samples, sample{[]label{{"env", key.env}, {"outcome", key.outcome}}, float64(count)})
+	}
+	idleStops := metric{
+		name: "tape_idle_stops_total",
+		help: "Environments stopped by the daemon after their idle timeout.",
+		kind: "counter",
+	}
+	for key, count := range idleStopCounts {
+		idleStops.samples = append(idleStops.samples, sample{[]label{{"env", key.env}, {"outcome", key.outcome}}, float64(count)})
+	}
+
+	activeSessions, totalSessions := ssh.Sessions()
+	envSessions := metric{
+		name: "tape_ssh_environment_sessions",
+		help: "Open SSH sessions of each environment.",
+		kind: "gauge",
+	}
+	for envName, count := range ssh.EnvironmentSessions() {
+		envSessions.samples = append(envSessions.samples, sample{[]label{{"env", envName}}, float64(count)})
+	}
+
+	return []metric{
+		{
+			name:    "tape_daemon_uptime_seconds",
+			help:    "Seconds since tape daemon started.",
+			kind:    "gauge",
+			samples: []sample{{value: time.Since(started).Seconds()}},
+		},
+		environments,
+		uptime,
+		execs,
+		idleStops,
+		{
+			name:    "tape_ssh_sessions",
+			help:    "Open SSH sessions.",
+			kind:    "gauge",
+			samples: []sample{{value: float64(activeSessions)}},
+		},
+		envSessions,
+		{
+			name:    "tape_ssh_sessions_total",
+			help:    "SSH sessions opened since the daemon started.",
+			kind:    "counter",
+			samples: []sample{{value: float64(totalSessions)}},
+		},
+	}, nil
+}
+
+// writeMetrics writes metrics in the Prometheus text exposition format, with
+// samples sorted so scrapes are stable
+func writeMetrics(w io.Writer, metrics []metric) {
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(w, "# TYPE %s %s\n", m.name, m.kind)
+
+		lines := make([]string, len(m.samples))
+		for i, s := range m.samples {
+			lines[i] = m.name + formatLabels(s.labels) + " " + strconv.FormatFloat(s.value, 'g', -1, 64)
+		}
+		sort.Strings(lines)
+		for _, line := range lines {
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func formatLabels(labels []label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, l.name, labelEscaper.Replace(l.value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// labelEscaper escapes label values the way the text format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
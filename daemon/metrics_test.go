@@ -0,0 +1,76 @@
+package daemon
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	var out strings.Builder
+	writeMetrics(&out, []metric{
+		{
+			name: "tape_environments",
+			help: "Configured environments by the state of their container.",
+			kind: "gauge",
+			samples: []sample{
+				{[]label{{"state", "stopped"}}, 2},
+				{[]label{{"state", "running"}}, 1},
+			},
+		},
+		{
+			name:    "tape_execs_total",
+			help:    "Commands run in environments with tape exec.",
+			kind:    "counter",
+			samples: []sample{{[]label{{"env", `a"b\c`}, {"outcome", "ok"}}, 3}},
+		},
+		{
+			name:    "tape_daemon_uptime_seconds",
+			help:    "Seconds since tape daemon started.",
+			kind:    "gauge",
+			samples: []sample{{value: 1.5}},
+		},
+	})
+
+	expected := `# HELP tape_environments Configured environments by the state of their container.
+# TYPE tape_environments gauge
+tape_environments{state="running"} 1
+tape_environments{state="stopped"} 2
+# HELP tape_execs_total Commands run in environments with tape exec.
+# TYPE tape_execs_total counter
+tape_execs_total{env="a\"b\\c",outcome="ok"} 3
+# HELP tape_daemon_uptime_seconds Seconds since tape daemon started.
+# TYPE tape_daemon_uptime_seconds gauge
+tape_daemon_uptime_seconds 1.5
+`
+	if out.String() != expected {
+		t.Errorf("writeMetrics() =\n%s\nwant\n%s", out.String(), expected)
+	}
+}
+
+func TestCollectIdleStops(t *testing.T) {
+	original := core.ConfigDir
+	core.ConfigDir = t.TempDir()
+	t.Cleanup(func() { core.ConfigDir = original })
+
+	history := `{"time":"2025-06-01T12:00:00Z","env":"app","operation":"idle-stop","outcome":"ok"}
+{"time":"2025-06-01T13:00:00Z","env":"app","operation":"idle-stop","outcome":"ok"}
+{"time":"2025-06-01T14:00:00Z","env":"app","operation":"stop","outcome":"ok"}
+`
+	if err := os.WriteFile(core.HistoryPath(), []byte(history), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := collectMetrics(time.Now())
+	if err != nil {
+		t.Fatalf("collectMetrics() error = %v", err)
+	}
+	var out strings.Builder
+	writeMetrics(&out, metrics)
+	if expected := `tape_idle_stops_total{env="app",outcome="ok"} 2`; !strings.Contains(out.String(), expected) {
+		t.Errorf("collectMetrics() = %s, want %s", out.String(), expected)
+	}
+}
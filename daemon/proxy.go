@@ -0,0 +1,77 @@
+package daemon
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+
+	"github.com/mikeocool/tape/core"
+)
+
+// proxyHandler routes requests for <env>.localhost to the environment's
+// primary port, see core.ProxyRoutes
+func proxyHandler() http.Handler {
+	// the route's environment and port are passed to the transport's dial as
+	// the request's address, env:port
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		envName, port, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		containerPort, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, err
+		}
+		return core.DialBox(ctx, envName, containerPort)
+	}
+	transport := &http.Transport{
+		DialContext: dial,
+		// servers in dev environments use self-signed certificates
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			route := r.In.Context().Value(proxyRouteKey{}).(*core.ProxyRoute)
+			r.Out.URL.Scheme = route.Protocol
+			r.Out.URL.Host = net.JoinHostPort(route.EnvName, strconv.Itoa(route.Port))
+			// dev servers check the Host they're served on, keep the one the browser used
+			r.Out.Host = r.In.Host
+			r.SetXForwarded()
+		},
+		Transport: transport,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			route := r.Context().Value(proxyRouteKey{}).(*core.ProxyRoute)
+			log.Printf("Error proxying %s to %s:%d: %v", r.Host, route.EnvName, route.Port, err)
+			status := http.StatusBadGateway
+			if errors.Is(err, core.ErrNotRunning) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, fmt.Sprintf("tape: error reaching %s port %d: %v", route.EnvName, route.Port, err), status)
+		},
+	}
+}
+
+type proxyRouteKey struct{}
+
+// routeRequests resolves each request's route before handing it to proxy
+func routeRequests(proxy http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, err := core.FindProxyRoute(r.Host)
+		if errors.Is(err, core.ErrNoProxyRoute) {
+			http.Error(w, "tape: "+err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			http.Error(w, "tape: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		proxy.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), proxyRouteKey{}, route)))
+	})
+}
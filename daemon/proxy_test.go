@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mikeocool/tape/core"
+)
+
+func TestProxyUnknownHost(t *testing.T) {
+	original := core.ConfigDir
+	core.ConfigDir = t.TempDir()
+	t.Cleanup(func() { core.ConfigDir = original })
+
+	server := httptest.NewServer(routeRequests(proxyHandler()))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Host = "missing.localhost"
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("status = %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+	body := make([]byte, 512)
+	n, _ := resp.Body.Read(body)
+	if !strings.Contains(string(body[:n]), "missing.localhost") {
+		t.Errorf("body = %q, want it to name the hostname", body[:n])
+	}
+}
@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/mikeocool/tape/core"
+)
+
+// pruneOnSchedule enforces the retention policy every interval, see
+// core.AutoPrune, until ctx is cancelled
+func pruneOnSchedule(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		report, err := core.AutoPrune()
+		if err != nil {
+			log.Printf("Error pruning: %v", err)
+		}
+		if report == nil {
+			continue
+		}
+		for _, c := range report.Containers {
+			log.Printf("Pruned container %s of %s", c.ContainerID, c.EnvName)
+		}
+		for _, snapshot := range report.Snapshots {
+			log.Printf("Pruned snapshot %s of %s", snapshot.Tag, snapshot.EnvName)
+		}
+	}
+}
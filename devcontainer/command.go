@@ -0,0 +1,147 @@
+package devcontainer
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// ExecCommand is a command in the form it's run in: a string command is a
+// script run with /bin/sh -c, an array command runs its first element
+// directly with the rest as its arguments, without a shell.
+type ExecCommand struct {
+	// Args is the script of a shell command, or the argv of another one
+	Args []string
+	// Shell runs Args[0] with /bin/sh -c
+	Shell bool
+}
+
+// ShellCommand returns the command that runs script with /bin/sh -c
+func ShellCommand(script string) ExecCommand {
+	return ExecCommand{Args: []string{script}, Shell: true}
+}
+
+// NewExecCommand converts a command as the devcontainer spec writes it, a
+// string or an array of strings, e.g. an entry of an object-form command
+func NewExecCommand(value interface{}) (ExecCommand, error) {
+	switch v := value.(type) {
+	case string:
+		return ShellCommand(v), nil
+	case []string:
+		if len(v) > 0 {
+			return ExecCommand{Args: v}, nil
+		}
+	case []interface{}:
+		args := make([]string, len(v))
+		for i, arg := range v {
+			s, ok := arg.(string)
+			if !ok {
+				return ExecCommand{}, fmt.Errorf("arguments must be strings")
+			}
+			args[i] = s
+		}
+		if len(args) > 0 {
+			return ExecCommand{Args: args}, nil
+		}
+	}
+	return ExecCommand{}, fmt.Errorf("expected a string or a non-empty array")
+}
+
+// Argv returns the arguments to exec the command with
+func (c ExecCommand) Argv() []string {
+	if c.Shell {
+		return []string{"/bin/sh", "-c", c.Args[0]}
+	}
+	return c.Args
+}
+
+// ArgvWith returns the arguments to exec the command with args appended. A
+// shell command receives them as its positional parameters, "$@", with name
+// as $0.
+func (c ExecCommand) ArgvWith(name string, args []string) []string {
+	if len(args) == 0 {
+		return c.Argv()
+	}
+	if c.Shell {
+		return append([]string{"/bin/sh", "-c", c.Args[0] + ` "$@"`, name}, args...)
+	}
+	return append(append([]string{}, c.Args...), args...)
+}
+
+// String returns the command as a shell command line: a shell command's
+// script as it is, the arguments of another one quoted where needed
+func (c ExecCommand) String() string {
+	if c.Shell {
+		return c.Args[0]
+	}
+	return ShellJoin(c.Args)
+}
+
+var shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// ShellJoin formats argv as a shell command line, quoting where needed
+func ShellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if shellSafePattern.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = ShellQuote(arg)
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// ShellQuote wraps a value in single quotes so a POSIX shell treats it literally
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// ParsedCommand is one of the commands a CommandValue runs
+type ParsedCommand struct {
+	// Name is the entry's key in an object-form command, empty otherwise
+	Name string
+	ExecCommand
+}
+
+// Normalize returns the commands c runs: one for a string or an array, and one
+// per entry, sorted by name, for the object form, whose entries run in
+// parallel. A nil or unset command runs nothing.
+func (c *CommandValue) Normalize() ([]ParsedCommand, error) {
+	if c == nil || c.value == nil {
+		return nil, nil
+	}
+	if object, ok := c.value.(map[string]interface{}); ok {
+		commands := make([]ParsedCommand, 0, len(object))
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			command, err := NewExecCommand(object[name])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+			commands = append(commands, ParsedCommand{Name: name, ExecCommand: command})
+		}
+		return commands, nil
+	}
+	command, err := NewExecCommand(c.value)
+	if err != nil {
+		return nil, err
+	}
+	return []ParsedCommand{{ExecCommand: command}}, nil
+}
+
+// MustString returns the command's script, panicking unless it's a string
+func (c CommandValue) MustString() string {
+	s, ok := c.value.(string)
+	if !ok {
+		panic(fmt.Sprintf("command %v is not a string", c.value))
+	}
+	return s
+}
+
+// TryArray returns the command's arguments and whether it's an array
+func (c CommandValue) TryArray() ([]string, bool) {
+	a, ok := c.value.([]string)
+	return a, ok
+}
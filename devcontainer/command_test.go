@@ -0,0 +1,214 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func TestNewExecCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected ExecCommand
+		wantErr  bool
+	}{
+		{"string", `"npm install && npm test"`, ShellCommand("npm install && npm test"), false},
+		{"array", `["npm", "run", "build && deploy"]`, ExecCommand{Args: []string{"npm", "run", "build && deploy"}}, false},
+		{"array with one element", `["make"]`, ExecCommand{Args: []string{"make"}}, false},
+		{"empty array", `[]`, ExecCommand{}, true},
+		{"non-string argument", `["sleep", 1]`, ExecCommand{}, true},
+		{"number", `42`, ExecCommand{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var value interface{}
+			if err := json.Unmarshal([]byte(tt.json), &value); err != nil {
+				t.Fatal(err)
+			}
+			got, err := NewExecCommand(value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewExecCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("NewExecCommand() = %#v, want %#v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecCommandArgv(t *testing.T) {
+	tests := []struct {
+		name     string
+		command  ExecCommand
+		args     []string
+		expected []string
+	}{
+		{
+			name:     "shell",
+			command:  ShellCommand("npm install && npm test"),
+			expected: []string{"/bin/sh", "-c", "npm install && npm test"},
+		},
+		{
+			name:     "array runs without a shell",
+			command:  ExecCommand{Args: []string{"echo", "$HOME", "a && b"}},
+			expected: []string{"echo", "$HOME", "a && b"},
+		},
+		{
+			name:     "shell with args",
+			command:  ShellCommand("go test ./..."),
+			args:     []string{"-run", "TestFoo"},
+			expected: []string{"/bin/sh", "-c", `go test ./... "$@"`, "test", "-run", "TestFoo"},
+		},
+		{
+			name:     "array with args",
+			command:  ExecCommand{Args: []string{"golangci-lint", "run"}},
+			args:     []string{"--fix"},
+			expected: []string{"golangci-lint", "run", "--fix"},
+		},
+		{
+			// an array that happens to start a shell is still an array
+			name:     "array running a shell",
+			command:  ExecCommand{Args: []string{"/bin/sh", "-c", "exit 0"}},
+			args:     []string{"x"},
+			expected: []string{"/bin/sh", "-c", "exit 0", "x"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.command.ArgvWith("test", tt.args); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ArgvWith() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExecCommandString(t *testing.T) {
+	tests := []struct {
+		command  ExecCommand
+		expected string
+	}{
+		{ShellCommand("npm install && npm test"), "npm install && npm test"},
+		{ExecCommand{Args: []string{"go", "test", "./..."}}, "go test ./..."},
+		{ExecCommand{Args: []string{"echo", "hello world"}}, "echo 'hello world'"},
+		{ExecCommand{Args: []string{"echo", "it's"}}, `echo 'it'\''s'`},
+		{ExecCommand{Args: []string{"echo", "$HOME", "`id`", "a;b", ""}}, `echo '$HOME' '` + "`id`" + `' 'a;b' ''`},
+		{ExecCommand{Args: []string{"env", "FOO=bar", "--flag=a,b", "user@host:/path"}}, "env FOO=bar --flag=a,b user@host:/path"},
+	}
+	for _, tt := range tests {
+		if got := tt.command.String(); got != tt.expected {
+			t.Errorf("String() = %s, want %s", got, tt.expected)
+		}
+	}
+}
+
+// TestShellJoinRoundTrip checks that a shell parses ShellJoin's output back
+// into the same arguments
+func TestShellJoinRoundTrip(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	args := []string{"plain", "with space", "it's", `double "quoted"`, "$HOME", "`id`", "a;b|c&d", "new\nline", "back\\slash", "*", ""}
+	output, err := exec.Command("sh", "-c", `for arg in `+ShellJoin(args)+`; do printf '%s\0' "$arg"; done`).Output()
+	if err != nil {
+		t.Fatalf("sh error = %v", err)
+	}
+	var got []string
+	start := 0
+	for i, b := range output {
+		if b == 0 {
+			got = append(got, string(output[start:i]))
+			start = i + 1
+		}
+	}
+	if !reflect.DeepEqual(got, args) {
+		t.Errorf("sh parsed %q, want %q", got, args)
+	}
+}
+
+func TestCommandValueNormalize(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		expected []ParsedCommand
+		wantErr  bool
+	}{
+		{
+			name:     "string",
+			json:     `"npm install"`,
+			expected: []ParsedCommand{{ExecCommand: ShellCommand("npm install")}},
+		},
+		{
+			name:     "array",
+			json:     `["npm", "install"]`,
+			expected: []ParsedCommand{{ExecCommand: ExecCommand{Args: []string{"npm", "install"}}}},
+		},
+		{
+			name: "object",
+			json: `{"server": ["npm", "start"], "db": "docker compose up -d"}`,
+			expected: []ParsedCommand{
+				{Name: "db", ExecCommand: ShellCommand("docker compose up -d")},
+				{Name: "server", ExecCommand: ExecCommand{Args: []string{"npm", "start"}}},
+			},
+		},
+		{
+			name:    "empty array",
+			json:    `[]`,
+			wantErr: true,
+		},
+		{
+			name:    "object with an invalid entry",
+			json:    `{"server": 1}`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var command CommandValue
+			if err := json.Unmarshal([]byte(tt.json), &command); err != nil {
+				t.Fatal(err)
+			}
+			got, err := command.Normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Normalize() = %#v, want %#v", got, tt.expected)
+			}
+		})
+	}
+
+	var unset *CommandValue
+	if got, err := unset.Normalize(); got != nil || err != nil {
+		t.Errorf("Normalize() of a nil command = %v, %v, want nothing", got, err)
+	}
+}
+
+func TestCommandValueAccessors(t *testing.T) {
+	var array CommandValue
+	if err := json.Unmarshal([]byte(`["make", "test"]`), &array); err != nil {
+		t.Fatal(err)
+	}
+	if args, ok := array.TryArray(); !ok || !reflect.DeepEqual(args, []string{"make", "test"}) {
+		t.Errorf("TryArray() = %v, %v, want the arguments", args, ok)
+	}
+
+	var script CommandValue
+	if err := json.Unmarshal([]byte(`"make test"`), &script); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := script.TryArray(); ok {
+		t.Error("TryArray() of a string command succeeded")
+	}
+	if got := script.MustString(); got != "make test" {
+		t.Errorf("MustString() = %q, want %q", got, "make test")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("MustString() of an array command didn't panic")
+		}
+	}()
+	array.MustString()
+}
@@ -1,9 +1,10 @@
-package devcontinaer
+package devcontainer
 
 import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strings"
 )
 
 // DevContainerConfig represents the root structure of a devcontainer.json file
@@ -33,7 +34,7 @@ type DevContainerConfig struct {
 	AppPort         *AppPortValue     `json:"appPort,omitempty"`
 	ContainerEnv    map[string]string `json:"containerEnv,omitempty"`
 	ContainerUser   string            `json:"containerUser,omitempty"`
-	Mounts          []string          `json:"mounts,omitempty"`
+	Mounts          []MountValue      `json:"mounts,omitempty"`
 	RunArgs         []string          `json:"runArgs,omitempty"`
 	ShutdownAction  string            `json:"shutdownAction,omitempty"`
 	OverrideCommand *bool             `json:"overrideCommand,omitempty"`
@@ -147,6 +148,11 @@ func (c *ComposeFileValue) UnmarshalJSON(data []byte) error {
 	return fmt.Errorf("cannot unmarshal %s into ComposeFileValue", data)
 }
 
+// NewComposeFile creates a ComposeFileValue from a single compose file path
+func NewComposeFile(path string) *ComposeFileValue {
+	return &ComposeFileValue{value: path}
+}
+
 // MarshalJSON custom marshaler for ComposeFileValue
 func (c ComposeFileValue) MarshalJSON() ([]byte, error) {
 	return json.Marshal(c.value)
@@ -246,6 +252,131 @@ func (c CommandValue) AsObject() map[string]interface{} {
 	return nil
 }
 
+// MountValue represents a mount that can be a docker --mount style string or an object
+type MountValue struct {
+	value interface{}
+}
+
+// MountObject is the object form of a mount
+type MountObject struct {
+	Type   string `json:"type"`
+	Source string `json:"source,omitempty"`
+	Target string `json:"target"`
+}
+
+// NewMountString creates a MountValue from a docker --mount style string
+func NewMountString(s string) MountValue {
+	return MountValue{value: s}
+}
+
+// NewMountObject creates a MountValue from a mount object
+func NewMountObject(o MountObject) MountValue {
+	return MountValue{value: o}
+}
+
+// UnmarshalJSON custom unmarshaler for MountValue
+func (m *MountValue) UnmarshalJSON(data []byte) error {
+	// Try as string
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		m.value = s
+		return nil
+	}
+
+	// Try as object
+	var o MountObject
+	if err := json.Unmarshal(data, &o); err == nil {
+		m.value = o
+		return nil
+	}
+
+	return fmt.Errorf("cannot unmarshal %s into MountValue", data)
+}
+
+// MarshalJSON custom marshaler for MountValue
+func (m MountValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.value)
+}
+
+// IsString checks if the mount is a string
+func (m MountValue) IsString() bool {
+	_, ok := m.value.(string)
+	return ok
+}
+
+// IsObject checks if the mount is an object
+func (m MountValue) IsObject() bool {
+	_, ok := m.value.(MountObject)
+	return ok
+}
+
+// AsString returns the mount as a string if it is a string, otherwise returns empty string
+func (m MountValue) AsString() string {
+	if s, ok := m.value.(string); ok {
+		return s
+	}
+	return ""
+}
+
+// AsObject returns the mount as an object if it is an object, otherwise returns nil
+func (m MountValue) AsObject() *MountObject {
+	if o, ok := m.value.(MountObject); ok {
+		return &o
+	}
+	return nil
+}
+
+// Normalize returns the mount's type, source and target, parsing the string form if needed
+func (m MountValue) Normalize() (MountObject, error) {
+	if o := m.AsObject(); o != nil {
+		if o.Target == "" {
+			return MountObject{}, fmt.Errorf("mount is missing a target")
+		}
+		return *o, nil
+	}
+
+	var o MountObject
+	for _, option := range strings.Split(m.AsString(), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(option), "=")
+		switch key {
+		case "type":
+			o.Type = value
+		case "source", "src":
+			o.Source = value
+		case "target", "destination", "dst":
+			o.Target = value
+		}
+	}
+
+	if o.Target == "" {
+		return MountObject{}, fmt.Errorf("mount %q is missing a target", m.AsString())
+	}
+	if o.Type == "" {
+		o.Type = "volume"
+	}
+	return o, nil
+}
+
+// DockerMountSpec returns the mount in docker --mount syntax. String mounts are
+// returned as written so options like readonly are preserved.
+func (m MountValue) DockerMountSpec() (string, error) {
+	o, err := m.Normalize()
+	if err != nil {
+		return "", err
+	}
+
+	if m.IsString() {
+		return m.AsString(), nil
+	}
+
+	spec := fmt.Sprintf("type=%s", o.Type)
+	if o.Source != "" {
+		spec += fmt.Sprintf(",source=%s", o.Source)
+	}
+	spec += fmt.Sprintf(",target=%s", o.Target)
+	return spec, nil
+}
+
 // PortAttributes represents the attributes for a specific port
 type PortAttributes struct {
 	OnAutoForward    string `json:"onAutoForward,omitempty"`
@@ -257,10 +388,75 @@ type PortAttributes struct {
 
 // HostRequirements represents the host hardware requirements
 type HostRequirements struct {
-	CPUs    int         `json:"cpus,omitempty"`
-	Memory  string      `json:"memory,omitempty"`
-	Storage string      `json:"storage,omitempty"`
-	GPU     interface{} `json:"gpu,omitempty"`
+	CPUs    int       `json:"cpus,omitempty"`
+	Memory  string    `json:"memory,omitempty"`
+	Storage string    `json:"storage,omitempty"`
+	GPU     *GPUValue `json:"gpu,omitempty"`
+}
+
+// GPUValue represents a GPU requirement that can be a boolean, "optional", or
+// an object of GPURequirements
+type GPUValue struct {
+	value interface{}
+}
+
+// UnmarshalJSON custom unmarshaler for GPUValue to handle multiple types
+func (g *GPUValue) UnmarshalJSON(data []byte) error {
+	// Try as boolean
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		g.value = b
+		return nil
+	}
+
+	// Try as string, of which only "optional" is valid
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s != "optional" {
+			return fmt.Errorf("invalid gpu requirement %q, expected \"optional\"", s)
+		}
+		g.value = s
+		return nil
+	}
+
+	// Try as object
+	var r GPURequirements
+	if err := json.Unmarshal(data, &r); err == nil {
+		g.value = r
+		return nil
+	}
+
+	return fmt.Errorf("cannot unmarshal %s into GPUValue", data)
+}
+
+// MarshalJSON custom marshaler for GPUValue
+func (g GPUValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(g.value)
+}
+
+// IsBool checks if the requirement is a boolean
+func (g GPUValue) IsBool() bool {
+	_, ok := g.value.(bool)
+	return ok
+}
+
+// AsBool returns the requirement as a boolean if it is a boolean, otherwise returns false
+func (g GPUValue) AsBool() bool {
+	b, _ := g.value.(bool)
+	return b
+}
+
+// IsOptional checks if the requirement is "optional", a GPU is used when the host has one
+func (g GPUValue) IsOptional() bool {
+	return g.value == "optional"
+}
+
+// AsRequirements returns the requirement as GPURequirements if it is an object, otherwise returns nil
+func (g GPUValue) AsRequirements() *GPURequirements {
+	if r, ok := g.value.(GPURequirements); ok {
+		return &r
+	}
+	return nil
 }
 
 // GPURequirements represents detailed GPU requirements when specified as an object
@@ -276,6 +472,8 @@ type BuildOptions struct {
 	Target     string            `json:"target,omitempty"`
 	Args       map[string]string `json:"args,omitempty"`
 	CacheFrom  interface{}       `json:"cacheFrom,omitempty"`
+	// Options are extra arguments to docker build
+	Options []string `json:"options,omitempty"`
 }
 
 // ParseDevContainer parses a devcontainer.json file into a DevContainer struct
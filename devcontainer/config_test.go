@@ -1,4 +1,4 @@
-package devcontinaer
+package devcontainer
 
 import (
 	"encoding/json"
@@ -124,6 +124,11 @@ func TestParseAndSerializeDevContainer(t *testing.T) {
 			input:   `{"name":"web-app","image":"nginx","appPort":[80,"443:8443"]}`,
 			wantErr: false,
 		},
+		{
+			name:    "config with mounts",
+			input:   `{"image":"ubuntu","mounts":["source=/tmp,target=/tmp,type=bind",{"type":"volume","source":"cache","target":"/cache"}]}`,
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -225,6 +230,94 @@ func TestAppPortValue(t *testing.T) {
 	}
 }
 
+func TestGPUValue(t *testing.T) {
+	tests := []struct {
+		name             string
+		input            string
+		wantBool         bool
+		wantIsBool       bool
+		wantOptional     bool
+		wantRequirements *GPURequirements
+		wantErr          bool
+	}{
+		{
+			name:       "required",
+			input:      `{"gpu":true}`,
+			wantBool:   true,
+			wantIsBool: true,
+		},
+		{
+			name:       "not required",
+			input:      `{"gpu":false}`,
+			wantIsBool: true,
+		},
+		{
+			name:         "optional",
+			input:        `{"gpu":"optional"}`,
+			wantOptional: true,
+		},
+		{
+			name:             "requirements",
+			input:            `{"gpu":{"cores":2,"memory":"8gb"}}`,
+			wantRequirements: &GPURequirements{Cores: 2, Memory: "8gb"},
+		},
+		{
+			name:    "invalid string",
+			input:   `{"gpu": "required"}`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid type",
+			input:   `{"gpu": 1}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requirements HostRequirements
+			err := json.Unmarshal([]byte(tt.input), &requirements)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			gpu := requirements.GPU
+			if got := gpu.IsBool(); got != tt.wantIsBool {
+				t.Errorf("GPU.IsBool() = %v, want %v", got, tt.wantIsBool)
+			}
+			if got := gpu.AsBool(); got != tt.wantBool {
+				t.Errorf("GPU.AsBool() = %v, want %v", got, tt.wantBool)
+			}
+			if got := gpu.IsOptional(); got != tt.wantOptional {
+				t.Errorf("GPU.IsOptional() = %v, want %v", got, tt.wantOptional)
+			}
+			if got := gpu.AsRequirements(); !reflect.DeepEqual(got, tt.wantRequirements) {
+				t.Errorf("GPU.AsRequirements() = %v, want %v", got, tt.wantRequirements)
+			}
+
+			// the value marshals back as it was written
+			data, err := json.Marshal(requirements)
+			if err != nil {
+				t.Fatalf("Marshal error = %v", err)
+			}
+			if string(data) != tt.input {
+				t.Errorf("Marshal() = %s, want %s", data, tt.input)
+			}
+		})
+	}
+
+	data, err := json.Marshal(HostRequirements{CPUs: 2})
+	if err != nil {
+		t.Fatalf("Marshal error = %v", err)
+	}
+	if string(data) != `{"cpus":2}` {
+		t.Errorf("Marshal() = %s, want gpu omitted", data)
+	}
+}
+
 func TestCommandValue(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -318,3 +411,86 @@ func TestComposeFileValue(t *testing.T) {
 		})
 	}
 }
+
+func TestMountValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		isString  bool
+		isObject  bool
+		wantMount MountObject
+		wantSpec  string
+		wantErr   bool
+	}{
+		{
+			name:      "string mount",
+			input:     `{"mounts": ["source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind,readonly"]}`,
+			isString:  true,
+			isObject:  false,
+			wantMount: MountObject{Type: "bind", Source: "/var/run/docker.sock", Target: "/var/run/docker.sock"},
+			wantSpec:  "source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind,readonly",
+		},
+		{
+			name:      "string mount with aliases and default type",
+			input:     `{"mounts": ["src=cache,dst=/cache"]}`,
+			isString:  true,
+			isObject:  false,
+			wantMount: MountObject{Type: "volume", Source: "cache", Target: "/cache"},
+			wantSpec:  "src=cache,dst=/cache",
+		},
+		{
+			name:      "object mount",
+			input:     `{"mounts": [{"type": "volume", "source": "node_modules", "target": "/app/node_modules"}]}`,
+			isString:  false,
+			isObject:  true,
+			wantMount: MountObject{Type: "volume", Source: "node_modules", Target: "/app/node_modules"},
+			wantSpec:  "type=volume,source=node_modules,target=/app/node_modules",
+		},
+		{
+			name:     "string mount without target",
+			input:    `{"mounts": ["source=/tmp,type=bind"]}`,
+			isString: true,
+			isObject: false,
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config DevContainerConfig
+			err := json.Unmarshal([]byte(tt.input), &config)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal: %v", err)
+			}
+
+			mount := config.Mounts[0]
+
+			if got := mount.IsString(); got != tt.isString {
+				t.Errorf("Mount.IsString() = %v, want %v", got, tt.isString)
+			}
+
+			if got := mount.IsObject(); got != tt.isObject {
+				t.Errorf("Mount.IsObject() = %v, want %v", got, tt.isObject)
+			}
+
+			got, err := mount.Normalize()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Mount.Normalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got != tt.wantMount {
+				t.Errorf("Mount.Normalize() = %v, want %v", got, tt.wantMount)
+			}
+
+			spec, err := mount.DockerMountSpec()
+			if err != nil {
+				t.Fatalf("Mount.DockerMountSpec() error = %v", err)
+			}
+			if spec != tt.wantSpec {
+				t.Errorf("Mount.DockerMountSpec() = %v, want %v", spec, tt.wantSpec)
+			}
+		})
+	}
+}
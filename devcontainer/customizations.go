@@ -0,0 +1,115 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// VSCodeCustomizations are the settings under customizations.vscode
+type VSCodeCustomizations struct {
+	Extensions []string               `json:"extensions,omitempty"`
+	Settings   map[string]interface{} `json:"settings,omitempty"`
+}
+
+// CodespacesCustomizations are the settings under customizations.codespaces
+type CodespacesCustomizations struct {
+	OpenFiles    []string               `json:"openFiles,omitempty"`
+	Repositories map[string]interface{} `json:"repositories,omitempty"`
+}
+
+// TapeCustomizations are the settings under customizations.tape
+type TapeCustomizations struct {
+	// Tasks are named commands run with tape task, as a shell string or an argument array
+	Tasks map[string]CommandValue `json:"tasks,omitempty"`
+}
+
+// Customization decodes the customizations for a tool into out. It returns
+// false if the config has no customizations for the tool.
+func (dc *DevContainerConfig) Customization(tool string, out interface{}) (bool, error) {
+	value, ok := dc.Customizations[tool]
+	if !ok {
+		return false, nil
+	}
+
+	// round trip through JSON to decode the free-form map into the typed struct
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// VSCode returns the customizations.vscode settings
+func (dc *DevContainerConfig) VSCode() (VSCodeCustomizations, error) {
+	var vscode VSCodeCustomizations
+	_, err := dc.Customization("vscode", &vscode)
+	return vscode, err
+}
+
+// Codespaces returns the customizations.codespaces settings
+func (dc *DevContainerConfig) Codespaces() (CodespacesCustomizations, error) {
+	var codespaces CodespacesCustomizations
+	_, err := dc.Customization("codespaces", &codespaces)
+	return codespaces, err
+}
+
+// Tape returns the customizations.tape settings
+func (dc *DevContainerConfig) Tape() (TapeCustomizations, error) {
+	var tape TapeCustomizations
+	_, err := dc.Customization("tape", &tape)
+	return tape, err
+}
+
+// AddExtension adds a VS Code extension, returning false if it was already present
+func (dc *DevContainerConfig) AddExtension(id string) bool {
+	vscode := dc.vscodeCustomizations()
+
+	extensions, _ := vscode["extensions"].([]interface{})
+	for _, existing := range extensions {
+		if s, ok := existing.(string); ok && strings.EqualFold(s, id) {
+			return false
+		}
+	}
+
+	vscode["extensions"] = append(extensions, id)
+	return true
+}
+
+// RemoveExtension removes a VS Code extension, returning false if it was not present
+func (dc *DevContainerConfig) RemoveExtension(id string) bool {
+	vscode := dc.vscodeCustomizations()
+
+	extensions, _ := vscode["extensions"].([]interface{})
+	remaining := make([]interface{}, 0, len(extensions))
+	for _, existing := range extensions {
+		if s, ok := existing.(string); ok && strings.EqualFold(s, id) {
+			continue
+		}
+		remaining = append(remaining, existing)
+	}
+
+	if len(remaining) == len(extensions) {
+		return false
+	}
+
+	vscode["extensions"] = remaining
+	return true
+}
+
+// vscodeCustomizations returns the raw customizations.vscode map, creating it
+// if needed, so edits preserve keys tape does not model
+func (dc *DevContainerConfig) vscodeCustomizations() map[string]interface{} {
+	if dc.Customizations == nil {
+		dc.Customizations = map[string]interface{}{}
+	}
+
+	vscode, ok := dc.Customizations["vscode"].(map[string]interface{})
+	if !ok {
+		vscode = map[string]interface{}{}
+		dc.Customizations["vscode"] = vscode
+	}
+	return vscode
+}
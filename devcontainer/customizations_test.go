@@ -0,0 +1,96 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCustomizations(t *testing.T) {
+	input := `{"customizations": {
+		"vscode": {"extensions": ["golang.go"], "settings": {"editor.tabSize": 4}, "unknown": true},
+		"codespaces": {"openFiles": ["README.md"]}
+	}}`
+
+	var config DevContainerConfig
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	vscode, err := config.VSCode()
+	if err != nil {
+		t.Fatalf("VSCode() error = %v", err)
+	}
+	if !reflect.DeepEqual(vscode.Extensions, []string{"golang.go"}) {
+		t.Errorf("VSCode().Extensions = %v, want [golang.go]", vscode.Extensions)
+	}
+	if vscode.Settings["editor.tabSize"] != float64(4) {
+		t.Errorf("VSCode().Settings = %v, want editor.tabSize 4", vscode.Settings)
+	}
+
+	codespaces, err := config.Codespaces()
+	if err != nil {
+		t.Fatalf("Codespaces() error = %v", err)
+	}
+	if !reflect.DeepEqual(codespaces.OpenFiles, []string{"README.md"}) {
+		t.Errorf("Codespaces().OpenFiles = %v, want [README.md]", codespaces.OpenFiles)
+	}
+
+	if !config.AddExtension("ms-python.python") {
+		t.Errorf("AddExtension() = false for a new extension")
+	}
+	if config.AddExtension("Golang.Go") {
+		t.Errorf("AddExtension() = true for an existing extension")
+	}
+	if !config.RemoveExtension("golang.go") {
+		t.Errorf("RemoveExtension() = false for an existing extension")
+	}
+	if config.RemoveExtension("golang.go") {
+		t.Errorf("RemoveExtension() = true for a missing extension")
+	}
+
+	output, err := json.Marshal(config)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	expected := `{"customizations":{"codespaces":{"openFiles":["README.md"]},"vscode":{"extensions":["ms-python.python"],"settings":{"editor.tabSize":4},"unknown":true}}}`
+	if string(output) != expected {
+		t.Errorf("json.Marshal() = %s, want %s", output, expected)
+	}
+}
+
+func TestAddExtensionWithoutCustomizations(t *testing.T) {
+	config := DevContainerConfig{}
+
+	if !config.AddExtension("golang.go") {
+		t.Errorf("AddExtension() = false for a new extension")
+	}
+
+	vscode, err := config.VSCode()
+	if err != nil {
+		t.Fatalf("VSCode() error = %v", err)
+	}
+	if !reflect.DeepEqual(vscode.Extensions, []string{"golang.go"}) {
+		t.Errorf("VSCode().Extensions = %v, want [golang.go]", vscode.Extensions)
+	}
+}
+
+func TestTapeCustomizations(t *testing.T) {
+	input := `{"customizations": {"tape": {"tasks": {"test": "go test ./...", "lint": ["golangci-lint", "run"]}}}}`
+
+	var config DevContainerConfig
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	tape, err := config.Tape()
+	if err != nil {
+		t.Fatalf("Tape() error = %v", err)
+	}
+	if got := tape.Tasks["test"].AsString(); got != "go test ./..." {
+		t.Errorf("Tape().Tasks[test] = %v, want go test ./...", got)
+	}
+	if got := tape.Tasks["lint"].AsArray(); !reflect.DeepEqual(got, []string{"golangci-lint", "run"}) {
+		t.Errorf("Tape().Tasks[lint] = %v, want [golangci-lint run]", got)
+	}
+}
@@ -0,0 +1,247 @@
+package devcontainer
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// FeatureRefKind is how a feature is referenced in the features map
+type FeatureRefKind string
+
+const (
+	FeatureRefOCI     FeatureRefKind = "oci"
+	FeatureRefLocal   FeatureRefKind = "local"
+	FeatureRefTarball FeatureRefKind = "tarball"
+)
+
+// ociPathPattern matches the lowercase path components allowed in OCI repository names
+var ociPathPattern = regexp.MustCompile(`^[a-z0-9]+(?:[._-][a-z0-9]+)*(?:/[a-z0-9]+(?:[._-][a-z0-9]+)*)*$`)
+
+// FeatureRef is a parsed feature identifier, such as ghcr.io/devcontainers/features/go:1
+type FeatureRef struct {
+	Raw  string
+	Kind FeatureRefKind
+	// Registry, Namespace, ID, Version and Digest are set for OCI references
+	Registry  string
+	Namespace string
+	ID        string
+	Version   string
+	Digest    string
+	// Path is set for local and tarball references
+	Path string
+}
+
+// ParseFeatureRef parses and validates a feature identifier
+func ParseFeatureRef(ref string) (FeatureRef, error) {
+	if ref == "" {
+		return FeatureRef{}, fmt.Errorf("feature reference is empty")
+	}
+
+	if strings.HasPrefix(ref, "./") || strings.HasPrefix(ref, "../") {
+		return FeatureRef{Raw: ref, Kind: FeatureRefLocal, Path: ref, ID: lastPathComponent(ref)}, nil
+	}
+
+	if strings.HasPrefix(ref, "https://") || strings.HasPrefix(ref, "http://") {
+		id := strings.TrimSuffix(lastPathComponent(ref), ".tgz")
+		id = strings.TrimPrefix(id, "devcontainer-feature-")
+		return FeatureRef{Raw: ref, Kind: FeatureRefTarball, Path: ref, ID: id}, nil
+	}
+
+	parsed := FeatureRef{Raw: ref, Kind: FeatureRefOCI, Version: "latest"}
+
+	name := ref
+	if before, digest, found := strings.Cut(name, "@"); found {
+		if !strings.HasPrefix(digest, "sha256:") {
+			return FeatureRef{}, fmt.Errorf("feature %s has an invalid digest", ref)
+		}
+		name = before
+		parsed.Digest = digest
+		parsed.Version = ""
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		parsed.Version = name[i+1:]
+		name = name[:i]
+		if parsed.Version == "" {
+			return FeatureRef{}, fmt.Errorf("feature %s has an empty version", ref)
+		}
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) < 3 {
+		return FeatureRef{}, fmt.Errorf("feature %s must be of the form registry/namespace/id", ref)
+	}
+
+	parsed.Registry = parts[0]
+	parsed.Namespace = strings.Join(parts[1:len(parts)-1], "/")
+	parsed.ID = parts[len(parts)-1]
+
+	if !ociPathPattern.MatchString(parsed.Namespace + "/" + parsed.ID) {
+		return FeatureRef{}, fmt.Errorf("feature %s is not a valid OCI reference", ref)
+	}
+
+	return parsed, nil
+}
+
+// Repository returns the OCI repository of the feature without its version
+func (f FeatureRef) Repository() string {
+	if f.Kind != FeatureRefOCI {
+		return f.Path
+	}
+	return fmt.Sprintf("%s/%s/%s", f.Registry, f.Namespace, f.ID)
+}
+
+// String returns the reference as it would be written in devcontainer.json
+func (f FeatureRef) String() string {
+	if f.Kind != FeatureRefOCI {
+		return f.Path
+	}
+	if f.Digest != "" {
+		return f.Repository() + "@" + f.Digest
+	}
+	return f.Repository() + ":" + f.Version
+}
+
+func lastPathComponent(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	return path[strings.LastIndex(path, "/")+1:]
+}
+
+// FeatureOptions are the options a feature is configured with
+type FeatureOptions map[string]interface{}
+
+// GetString returns a string option, or empty string if unset or not a string
+func (o FeatureOptions) GetString(key string) string {
+	if s, ok := o[key].(string); ok {
+		return s
+	}
+	return ""
+}
+
+// GetBool returns a boolean option, or false if unset or not a boolean
+func (o FeatureOptions) GetBool(key string) bool {
+	if b, ok := o[key].(bool); ok {
+		return b
+	}
+	return false
+}
+
+// Set sets an option
+func (o FeatureOptions) Set(key string, value interface{}) {
+	o[key] = value
+}
+
+// featureOptions converts a features map value to options. A string value is
+// shorthand for the version option.
+func featureOptions(value interface{}) (FeatureOptions, error) {
+	switch v := value.(type) {
+	case nil:
+		return FeatureOptions{}, nil
+	case map[string]interface{}:
+		return FeatureOptions(v), nil
+	case FeatureOptions:
+		return v, nil
+	case string:
+		return FeatureOptions{"version": v}, nil
+	case bool:
+		// legacy form for enabling a feature with its default options
+		return FeatureOptions{}, nil
+	default:
+		return nil, fmt.Errorf("invalid feature options of type %T", value)
+	}
+}
+
+// Feature is a feature reference and the options it is configured with
+type Feature struct {
+	Ref     FeatureRef
+	Options FeatureOptions
+}
+
+// FeatureList returns the config's features sorted by reference
+func (dc *DevContainerConfig) FeatureList() ([]Feature, error) {
+	keys := make([]string, 0, len(dc.Features))
+	for key := range dc.Features {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	features := make([]Feature, 0, len(keys))
+	for _, key := range keys {
+		ref, err := ParseFeatureRef(key)
+		if err != nil {
+			return nil, err
+		}
+		options, err := featureOptions(dc.Features[key])
+		if err != nil {
+			return nil, fmt.Errorf("feature %s: %v", key, err)
+		}
+		features = append(features, Feature{Ref: ref, Options: options})
+	}
+	return features, nil
+}
+
+// GetFeature returns the options for a feature. The feature can be given by
+// its full reference or by its repository without a version.
+func (dc *DevContainerConfig) GetFeature(ref string) (FeatureOptions, bool) {
+	key, ok := dc.featureKey(ref)
+	if !ok {
+		return nil, false
+	}
+	options, err := featureOptions(dc.Features[key])
+	if err != nil {
+		return nil, false
+	}
+	return options, true
+}
+
+// SetFeature adds a feature or replaces the options of an existing one
+func (dc *DevContainerConfig) SetFeature(ref string, options FeatureOptions) error {
+	if _, err := ParseFeatureRef(ref); err != nil {
+		return err
+	}
+
+	if key, ok := dc.featureKey(ref); ok {
+		delete(dc.Features, key)
+	}
+	if dc.Features == nil {
+		dc.Features = map[string]interface{}{}
+	}
+	if options == nil {
+		options = FeatureOptions{}
+	}
+	dc.Features[ref] = map[string]interface{}(options)
+	return nil
+}
+
+// RemoveFeature removes a feature, returning whether it was present
+func (dc *DevContainerConfig) RemoveFeature(ref string) bool {
+	key, ok := dc.featureKey(ref)
+	if ok {
+		delete(dc.Features, key)
+	}
+	return ok
+}
+
+// ValidateFeatures checks that every feature reference and its options are valid
+func (dc *DevContainerConfig) ValidateFeatures() error {
+	_, err := dc.FeatureList()
+	return err
+}
+
+func (dc *DevContainerConfig) featureKey(ref string) (string, bool) {
+	if _, ok := dc.Features[ref]; ok {
+		return ref, true
+	}
+
+	parsed, err := ParseFeatureRef(ref)
+	if err != nil {
+		return "", false
+	}
+	for key := range dc.Features {
+		existing, err := ParseFeatureRef(key)
+		if err == nil && existing.Repository() == parsed.Repository() {
+			return key, true
+		}
+	}
+	return "", false
+}
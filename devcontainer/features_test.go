@@ -0,0 +1,155 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestParseFeatureRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected FeatureRef
+		wantErr  bool
+	}{
+		{
+			name:  "oci with version",
+			input: "ghcr.io/devcontainers/features/go:1",
+			expected: FeatureRef{
+				Raw:       "ghcr.io/devcontainers/features/go:1",
+				Kind:      FeatureRefOCI,
+				Registry:  "ghcr.io",
+				Namespace: "devcontainers/features",
+				ID:        "go",
+				Version:   "1",
+			},
+		},
+		{
+			name:  "oci without version",
+			input: "ghcr.io/devcontainers/features/docker-outside-of-docker",
+			expected: FeatureRef{
+				Raw:       "ghcr.io/devcontainers/features/docker-outside-of-docker",
+				Kind:      FeatureRefOCI,
+				Registry:  "ghcr.io",
+				Namespace: "devcontainers/features",
+				ID:        "docker-outside-of-docker",
+				Version:   "latest",
+			},
+		},
+		{
+			name:  "oci with registry port and digest",
+			input: "localhost:5000/team/features/tool@sha256:abc123",
+			expected: FeatureRef{
+				Raw:       "localhost:5000/team/features/tool@sha256:abc123",
+				Kind:      FeatureRefOCI,
+				Registry:  "localhost:5000",
+				Namespace: "team/features",
+				ID:        "tool",
+				Digest:    "sha256:abc123",
+			},
+		},
+		{
+			name:  "local feature",
+			input: "./local-features/hello",
+			expected: FeatureRef{
+				Raw:  "./local-features/hello",
+				Kind: FeatureRefLocal,
+				ID:   "hello",
+				Path: "./local-features/hello",
+			},
+		},
+		{
+			name:  "tarball feature",
+			input: "https://example.com/devcontainer-feature-hello.tgz",
+			expected: FeatureRef{
+				Raw:  "https://example.com/devcontainer-feature-hello.tgz",
+				Kind: FeatureRefTarball,
+				ID:   "hello",
+				Path: "https://example.com/devcontainer-feature-hello.tgz",
+			},
+		},
+		{
+			name:    "empty",
+			input:   "",
+			wantErr: true,
+		},
+		{
+			name:    "missing namespace",
+			input:   "ghcr.io/go:1",
+			wantErr: true,
+		},
+		{
+			name:    "uppercase id",
+			input:   "ghcr.io/devcontainers/features/Go:1",
+			wantErr: true,
+		},
+		{
+			name:    "empty version",
+			input:   "ghcr.io/devcontainers/features/go:",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFeatureRef(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseFeatureRef() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ParseFeatureRef() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFeatureHelpers(t *testing.T) {
+	input := `{"features": {"ghcr.io/devcontainers/features/go:1": {"version": "1.23"}, "ghcr.io/devcontainers/features/node:1": "lts"}}`
+
+	var config DevContainerConfig
+	if err := json.Unmarshal([]byte(input), &config); err != nil {
+		t.Fatalf("Failed to unmarshal: %v", err)
+	}
+
+	if err := config.ValidateFeatures(); err != nil {
+		t.Errorf("ValidateFeatures() error = %v", err)
+	}
+
+	options, ok := config.GetFeature("ghcr.io/devcontainers/features/go")
+	if !ok {
+		t.Fatalf("GetFeature() did not find go feature by repository")
+	}
+	if got := options.GetString("version"); got != "1.23" {
+		t.Errorf("GetString(version) = %v, want 1.23", got)
+	}
+
+	options, ok = config.GetFeature("ghcr.io/devcontainers/features/node:1")
+	if !ok || options.GetString("version") != "lts" {
+		t.Errorf("GetFeature() for string shorthand = %v, %v, want version lts", options, ok)
+	}
+
+	if err := config.SetFeature("ghcr.io/devcontainers/features/go:2", FeatureOptions{"version": "latest"}); err != nil {
+		t.Fatalf("SetFeature() error = %v", err)
+	}
+	if _, ok := config.Features["ghcr.io/devcontainers/features/go:1"]; ok {
+		t.Errorf("SetFeature() did not replace the existing version of the feature")
+	}
+
+	if !config.RemoveFeature("ghcr.io/devcontainers/features/node") {
+		t.Errorf("RemoveFeature() did not find node feature")
+	}
+
+	features, err := config.FeatureList()
+	if err != nil {
+		t.Fatalf("FeatureList() error = %v", err)
+	}
+	if len(features) != 1 || features[0].Ref.Version != "2" {
+		t.Errorf("FeatureList() = %+v, want only go:2", features)
+	}
+
+	if err := config.SetFeature("not a feature", nil); err == nil {
+		t.Errorf("SetFeature() with invalid reference did not return an error")
+	}
+}
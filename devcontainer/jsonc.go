@@ -0,0 +1,384 @@
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// devcontainer.json is JSONC, JSON with comments and trailing commas. The
+// edits here change a file's text in place rather than re-serializing it, so
+// its comments, indentation and key order survive everywhere but the edited
+// value.
+
+// StandardizeJSONC returns data as plain JSON, with comments and trailing
+// commas replaced by spaces so offsets into it match data
+func StandardizeJSONC(data []byte) []byte {
+	out := stripJSONCComments(data)
+	scanJSONC(out, func(i int, inString bool) int {
+		if inString || out[i] != ',' {
+			return i
+		}
+		j := skipJSONCSpace(out, i+1)
+		if j < len(out) && (out[j] == '}' || out[j] == ']') {
+			out[i] = ' '
+		}
+		return i
+	})
+	return out
+}
+
+// stripJSONCComments returns data with its comments replaced by spaces
+func stripJSONCComments(data []byte) []byte {
+	out := bytes.Clone(data)
+	scanJSONC(out, func(i int, inString bool) int {
+		if inString || out[i] != '/' || i+1 >= len(out) {
+			return i
+		}
+		end := i
+		switch out[i+1] {
+		case '/':
+			end = bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i
+			}
+		case '*':
+			end = bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+		default:
+			return i
+		}
+		for j := i; j < end; j++ {
+			if out[j] != '\n' && out[j] != '\r' {
+				out[j] = ' '
+			}
+		}
+		return end - 1
+	})
+	return out
+}
+
+// scanJSONC calls visit with the offset of each byte of data that isn't
+// escaped in a string, and whether it's inside a string. visit returns the
+// offset of the last byte it handled.
+func scanJSONC(data []byte, visit func(i int, inString bool) int) {
+	inString := false
+	for i := 0; i < len(data); i++ {
+		switch {
+		case inString && data[i] == '\\':
+			i++
+		case data[i] == '"':
+			inString = !inString
+		default:
+			i = visit(i, inString)
+		}
+	}
+}
+
+func skipJSONCSpace(data []byte, i int) int {
+	for i < len(data) && (data[i] == ' ' || data[i] == '\t' || data[i] == '\n' || data[i] == '\r') {
+		i++
+	}
+	return i
+}
+
+// jsoncNode is a value in a JSONC document, located by its byte range
+type jsoncNode struct {
+	start, end int
+	// kind is '{' for objects, '[' for arrays and 0 for other values
+	kind     byte
+	children []jsoncChild
+}
+
+// jsoncChild is a member of an object or an element of an array
+type jsoncChild struct {
+	key string
+	// start is the offset of the member's key, or of the element
+	start int
+	value *jsoncNode
+}
+
+// find returns the index of the child at key, an object key or an array index
+func (n *jsoncNode) find(key string) int {
+	if n.kind == '[' {
+		if i, err := strconv.Atoi(key); err == nil && i >= 0 && i < len(n.children) {
+			return i
+		}
+		return -1
+	}
+	for i, child := range n.children {
+		if child.key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseJSONC parses the document's structure, returning it with the
+// standardized data its offsets index
+func parseJSONC(data []byte) (*jsoncNode, []byte, error) {
+	std := StandardizeJSONC(data)
+	if !json.Valid(std) {
+		var v interface{}
+		if err := json.Unmarshal(std, &v); err != nil {
+			return nil, nil, err
+		}
+	}
+	pos := 0
+	return parseJSONCValue(std, &pos), std, nil
+}
+
+// parseJSONCValue parses the value at *pos of valid JSON, leaving *pos after it
+func parseJSONCValue(data []byte, pos *int) *jsoncNode {
+	*pos = skipJSONCSpace(data, *pos)
+	node := &jsoncNode{start: *pos}
+	switch data[*pos] {
+	case '{', '[':
+		node.kind = data[*pos]
+		*pos++
+		for {
+			*pos = skipJSONCSpace(data, *pos)
+			if data[*pos] == '}' || data[*pos] == ']' {
+				*pos++
+				break
+			}
+			if data[*pos] == ',' {
+				*pos++
+				continue
+			}
+			child := jsoncChild{start: *pos}
+			if node.kind == '{' {
+				keyEnd := endOfJSONString(data, *pos)
+				json.Unmarshal(data[*pos:keyEnd], &child.key)
+				*pos = skipJSONCSpace(data, keyEnd) + 1
+			}
+			child.value = parseJSONCValue(data, pos)
+			node.children = append(node.children, child)
+		}
+	case '"':
+		*pos = endOfJSONString(data, *pos)
+	default:
+		for *pos < len(data) && bytes.IndexByte([]byte(",]} \t\r\n"), data[*pos]) < 0 {
+			*pos++
+		}
+	}
+	node.end = *pos
+	return node
+}
+
+// endOfJSONString returns the offset after the string starting at start
+func endOfJSONString(data []byte, start int) int {
+	for i := start + 1; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return len(data)
+}
+
+// SetJSONC sets the value at path in a JSONC document, creating the objects
+// on the way that don't exist. Array elements are addressed by index, where
+// the array's length appends. Only the set value's text changes.
+func SetJSONC(data []byte, path []string, value interface{}) ([]byte, error) {
+	root, std, err := parseJSONC(data)
+	if err != nil {
+		return nil, err
+	}
+
+	node := root
+	for i, key := range path {
+		switch node.kind {
+		case '{':
+			if j := node.find(key); j >= 0 {
+				node = node.children[j].value
+				continue
+			}
+			return insertJSONC(data, node, key, nestJSONC(path[i+1:], value)), nil
+		case '[':
+			if j := node.find(key); j >= 0 {
+				node = node.children[j].value
+				continue
+			}
+			if key != strconv.Itoa(len(node.children)) {
+				return nil, fmt.Errorf("invalid index %s", key)
+			}
+			return insertJSONC(data, node, "", nestJSONC(path[i+1:], value)), nil
+		default:
+			if string(std[node.start:node.end]) != "null" {
+				return nil, fmt.Errorf("cannot set %s on %s", key, data[node.start:node.end])
+			}
+			return replaceJSONC(data, node.start, node.end, nestJSONC(path[i:], value)), nil
+		}
+	}
+	return replaceJSONC(data, node.start, node.end, value), nil
+}
+
+// DeleteJSONC removes the value at path from a JSONC document, along with
+// the separator before or after it
+func DeleteJSONC(data []byte, path []string) ([]byte, error) {
+	if len(path) == 0 {
+		return nil, fmt.Errorf("cannot delete the document")
+	}
+	root, _, err := parseJSONC(data)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := root
+	for ; len(path) > 1; path = path[1:] {
+		i := parent.find(path[0])
+		if parent.kind == 0 || i < 0 {
+			return nil, fmt.Errorf("key %s not found", path[0])
+		}
+		parent = parent.children[i].value
+	}
+	i := parent.find(path[0])
+	if parent.kind == 0 || i < 0 {
+		return nil, fmt.Errorf("key %s not found", path[0])
+	}
+	return deleteJSONCChild(data, parent, i), nil
+}
+
+// deleteJSONCChild removes the parent's i-th child. The separator after it
+// goes with it, or the one before it for the last child.
+func deleteJSONCChild(data []byte, parent *jsoncNode, i int) []byte {
+	children := parent.children
+	var start, end int
+	switch {
+	case len(children) == 1 && parent.kind == '{':
+		return spliceJSONC(data, parent.start, parent.end, []byte("{}"))
+	case len(children) == 1:
+		return spliceJSONC(data, parent.start, parent.end, []byte("[]"))
+	case i < len(children)-1:
+		start, end = children[i].start, children[i+1].start
+	default:
+		start, end = children[i-1].value.end, children[i].value.end
+	}
+	return spliceJSONC(data, start, end, nil)
+}
+
+// nestJSONC wraps value in an object for each key of path
+func nestJSONC(path []string, value interface{}) interface{} {
+	for i := len(path) - 1; i >= 0; i-- {
+		value = map[string]interface{}{path[i]: value}
+	}
+	return value
+}
+
+// replaceJSONC replaces the value between start and end, indenting it like
+// the line it starts on
+func replaceJSONC(data []byte, start, end int, value interface{}) []byte {
+	return spliceJSONC(data, start, end, renderJSONC(value, lineIndent(data, start), indentUnit(data)))
+}
+
+// insertJSONC adds a member to an object or an element to an array, after
+// its last child. Children on lines of their own get a line for the new one
+// with the same indentation, children on the container's line are followed
+// on the same line.
+func insertJSONC(data []byte, container *jsoncNode, key string, value interface{}) []byte {
+	unit := indentUnit(data)
+	child := func(indent string) []byte {
+		rendered := renderJSONC(value, indent, unit)
+		if container.kind == '[' {
+			return rendered
+		}
+		return append(append(renderJSONC(key, "", unit), ": "...), rendered...)
+	}
+
+	if len(container.children) == 0 {
+		var empty interface{} = []interface{}{value}
+		if container.kind == '{' {
+			empty = map[string]interface{}{key: value}
+		}
+		return replaceJSONC(data, container.start, container.end, empty)
+	}
+
+	last := container.children[len(container.children)-1]
+	pos := last.value.end
+	uncommented := stripJSONCComments(data)
+	comma := skipJSONCSpace(uncommented, pos)
+	hasComma := uncommented[comma] == ','
+
+	if !bytes.Contains(data[container.start:last.start], []byte("\n")) {
+		text := append([]byte(", "), child(lineIndent(data, container.start))...)
+		return spliceJSONC(data, pos, pos, text)
+	}
+
+	// the new child goes at the end of the last one's line, after any
+	// comment there, unless a block comment runs on past it
+	lineEnd := container.end - 1
+	if newline := bytes.IndexByte(data[pos:lineEnd], '\n'); newline >= 0 {
+		lineEnd = pos + newline
+		if lineEnd > pos && data[lineEnd-1] == '\r' {
+			lineEnd--
+		}
+	}
+	if hasComma && comma >= lineEnd {
+		lineEnd = comma + 1
+	}
+	if bytes.Count(data[pos:lineEnd], []byte("/*")) > bytes.Count(data[pos:lineEnd], []byte("*/")) {
+		lineEnd = pos
+		if hasComma {
+			lineEnd = comma + 1
+		}
+	}
+
+	indent := lineIndent(data, last.start)
+	text := append([]byte("\n"+indent), child(indent)...)
+	edited := spliceJSONC(data, lineEnd, lineEnd, text)
+	if !hasComma {
+		edited = spliceJSONC(edited, pos, pos, []byte(","))
+	}
+	return edited
+}
+
+// renderJSONC formats value for a line indented by prefix, in a file
+// indented by unit
+func renderJSONC(value interface{}, prefix, unit string) []byte {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent(prefix, unit)
+	encoder.Encode(value)
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// spliceJSONC returns data with the bytes between start and end replaced by text
+func spliceJSONC(data []byte, start, end int, text []byte) []byte {
+	edited := make([]byte, 0, len(data)-(end-start)+len(text))
+	edited = append(edited, data[:start]...)
+	edited = append(edited, text...)
+	return append(edited, data[end:]...)
+}
+
+// lineIndent returns the whitespace the line containing pos starts with
+func lineIndent(data []byte, pos int) string {
+	start := bytes.LastIndexByte(data[:pos], '\n') + 1
+	end := start
+	for end < pos && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// indentUnit returns the indentation of the document's first indented line,
+// two spaces if it has none
+func indentUnit(data []byte) string {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " \t")
+		if len(trimmed) > 0 && len(trimmed) < len(line) {
+			return string(line[:len(line)-len(trimmed)])
+		}
+	}
+	return "  "
+}
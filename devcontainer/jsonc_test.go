@@ -0,0 +1,216 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+const jsoncDocument = `{
+	// the app's image
+	"image": "mcr.microsoft.com/devcontainers/go:1", // pinned
+	"forwardPorts": [
+		3000,
+		8080, /* the API */
+	],
+	"customizations": {
+		"vscode": {"extensions": ["golang.go"]}
+	},
+}
+`
+
+func TestStandardizeJSONC(t *testing.T) {
+	std := StandardizeJSONC([]byte(jsoncDocument))
+	if len(std) != len(jsoncDocument) {
+		t.Errorf("StandardizeJSONC() changed the length from %d to %d", len(jsoncDocument), len(std))
+	}
+	var config DevContainerConfig
+	if err := json.Unmarshal(std, &config); err != nil {
+		t.Fatalf("StandardizeJSONC() isn't JSON: %v\n%s", err, std)
+	}
+	if config.Image != "mcr.microsoft.com/devcontainers/go:1" || len(config.ForwardPorts) != 2 {
+		t.Errorf("StandardizeJSONC() parsed as %+v", config)
+	}
+
+	// comment markers and commas in strings are left alone
+	s := `{"a": "http://x/*y*/", "b": "1,]"}`
+	if got := string(StandardizeJSONC([]byte(s))); got != s {
+		t.Errorf("StandardizeJSONC(%s) = %s", s, got)
+	}
+}
+
+func TestSetJSONC(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		value   interface{}
+		replace string
+		with    string
+		wantErr bool
+	}{
+		{
+			name:    "replace a value",
+			path:    "image",
+			value:   "ubuntu",
+			replace: `"mcr.microsoft.com/devcontainers/go:1"`,
+			with:    `"ubuntu"`,
+		},
+		{
+			name:    "add a key",
+			path:    "remoteUser",
+			value:   "vscode",
+			replace: "\t\t\"vscode\": {\"extensions\": [\"golang.go\"]}\n\t},\n",
+			with:    "\t\t\"vscode\": {\"extensions\": [\"golang.go\"]}\n\t},\n\t\"remoteUser\": \"vscode\"\n",
+		},
+		{
+			name:    "append to an array after a comment",
+			path:    "forwardPorts.2",
+			value:   9000,
+			replace: "8080, /* the API */\n",
+			with:    "8080, /* the API */\n\t\t9000\n",
+		},
+		{
+			name:    "append to an inline array",
+			path:    "customizations.vscode.extensions.1",
+			value:   "eamodio.gitlens",
+			replace: `["golang.go"]`,
+			with:    `["golang.go", "eamodio.gitlens"]`,
+		},
+		{
+			name:    "create nested objects",
+			path:    "customizations.vscode.settings.go.lintTool",
+			value:   "golangci-lint",
+			replace: `{"extensions": ["golang.go"]}`,
+			with:    "{\"extensions\": [\"golang.go\"], \"settings\": {\n\t\t\t\"go.lintTool\": \"golangci-lint\"\n\t\t}}",
+		},
+		{
+			name:    "multi-line value",
+			path:    "image",
+			value:   map[string]interface{}{"a": 1},
+			replace: `"mcr.microsoft.com/devcontainers/go:1"`,
+			with:    "{\n\t\t\"a\": 1\n\t}",
+		},
+		{
+			name:    "index out of range",
+			path:    "forwardPorts.5",
+			value:   1,
+			wantErr: true,
+		},
+		{
+			name:    "key of a scalar",
+			path:    "image.tag",
+			value:   "1",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// nested settings keys are one key with a dot in them
+			path := strings.Split(tt.path, ".")
+			if strings.HasPrefix(tt.path, "customizations.vscode.settings.") {
+				path = []string{"customizations", "vscode", "settings", "go.lintTool"}
+			}
+			got, err := SetJSONC([]byte(jsoncDocument), path, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SetJSONC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			expected := strings.Replace(jsoncDocument, tt.replace, tt.with, 1)
+			if string(got) != expected {
+				t.Errorf("SetJSONC() =\n%s\nwant\n%s", got, expected)
+			}
+			var v interface{}
+			if err := json.Unmarshal(StandardizeJSONC(got), &v); err != nil {
+				t.Errorf("SetJSONC() result doesn't parse: %v", err)
+			}
+		})
+	}
+}
+
+func TestSetJSONCWithoutTrailingComma(t *testing.T) {
+	got, err := SetJSONC([]byte("{\n  \"image\": \"ubuntu\" // base\n}\n"), []string{"remoteUser"}, "root")
+	if err != nil {
+		t.Fatalf("SetJSONC() error = %v", err)
+	}
+	expected := "{\n  \"image\": \"ubuntu\", // base\n  \"remoteUser\": \"root\"\n}\n"
+	if string(got) != expected {
+		t.Errorf("SetJSONC() = %q, want %q", got, expected)
+	}
+
+	// a comma in a comment isn't a separator
+	got, err = SetJSONC([]byte("{\n  \"image\": \"ubuntu\" /* 22.04, jammy */\n}"), []string{"remoteUser"}, "root")
+	if err != nil {
+		t.Fatalf("SetJSONC() error = %v", err)
+	}
+	expected = "{\n  \"image\": \"ubuntu\", /* 22.04, jammy */\n  \"remoteUser\": \"root\"\n}"
+	if string(got) != expected {
+		t.Errorf("SetJSONC() = %q, want %q", got, expected)
+	}
+
+	got, err = SetJSONC([]byte("{}"), []string{"image"}, "ubuntu")
+	if err != nil {
+		t.Fatalf("SetJSONC() error = %v", err)
+	}
+	if expected := `{
+  "image": "ubuntu"
+}`; string(got) != expected {
+		t.Errorf("SetJSONC() = %q, want %q", got, expected)
+	}
+}
+
+func TestDeleteJSONC(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    []string
+		replace string
+		with    string
+		wantErr bool
+	}{
+		{
+			name:    "first element",
+			path:    []string{"forwardPorts", "0"},
+			replace: "\t\t3000,\n",
+		},
+		{
+			name:    "last element",
+			path:    []string{"forwardPorts", "1"},
+			replace: ",\n\t\t8080",
+		},
+		{
+			name:    "only element",
+			path:    []string{"customizations", "vscode", "extensions", "0"},
+			replace: `["golang.go"]`,
+			with:    "[]",
+		},
+		{
+			name:    "member",
+			path:    []string{"image"},
+			replace: "\"image\": \"mcr.microsoft.com/devcontainers/go:1\", // pinned\n\t",
+		},
+		{
+			name:    "missing",
+			path:    []string{"forwardPorts", "2"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DeleteJSONC([]byte(jsoncDocument), tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DeleteJSONC() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			expected := strings.Replace(jsoncDocument, tt.replace, tt.with, 1)
+			if string(got) != expected {
+				t.Errorf("DeleteJSONC() =\n%s\nwant\n%s", got, expected)
+			}
+			if _, err := ParseDevContainer(StandardizeJSONC(got)); err != nil {
+				t.Errorf("DeleteJSONC() result doesn't parse: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package devcontainer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Merge combines two configs following the devcontainer metadata merge rules:
+// arrays are appended (without duplicates), maps are merged with override
+// winning per key, scalars set in override replace base, and lifecycle commands
+// accumulate so both run. Neither argument is modified.
+func Merge(base, override *DevContainerConfig) *DevContainerConfig {
+	if base == nil {
+		base = &DevContainerConfig{}
+	}
+	if override == nil {
+		override = &DevContainerConfig{}
+	}
+
+	merged := *base
+
+	merged.Name = mergeString(base.Name, override.Name)
+	merged.Features = mergeInterfaceMaps(base.Features, override.Features)
+	// install order is a single ordering rather than a collection, so it is replaced
+	if len(override.OverrideFeatureInstallOrder) > 0 {
+		merged.OverrideFeatureInstallOrder = override.OverrideFeatureInstallOrder
+	}
+	merged.ForwardPorts = appendUnique(base.ForwardPorts, override.ForwardPorts)
+	merged.PortsAttributes = mergeMaps(base.PortsAttributes, override.PortsAttributes)
+	merged.OtherPortsAttributes = mergePointer(base.OtherPortsAttributes, override.OtherPortsAttributes)
+	merged.UpdateRemoteUserUID = mergePointer(base.UpdateRemoteUserUID, override.UpdateRemoteUserUID)
+	merged.RemoteEnv = mergeMaps(base.RemoteEnv, override.RemoteEnv)
+	merged.RemoteUser = mergeString(base.RemoteUser, override.RemoteUser)
+	merged.InitializeCommand = mergeCommands(base.InitializeCommand, override.InitializeCommand)
+	merged.OnCreateCommand = mergeCommands(base.OnCreateCommand, override.OnCreateCommand)
+	merged.UpdateContentCommand = mergeCommands(base.UpdateContentCommand, override.UpdateContentCommand)
+	merged.PostCreateCommand = mergeCommands(base.PostCreateCommand, override.PostCreateCommand)
+	merged.PostStartCommand = mergeCommands(base.PostStartCommand, override.PostStartCommand)
+	merged.PostAttachCommand = mergeCommands(base.PostAttachCommand, override.PostAttachCommand)
+	merged.WaitFor = mergeString(base.WaitFor, override.WaitFor)
+	merged.UserEnvProbe = mergeString(base.UserEnvProbe, override.UserEnvProbe)
+	merged.HostRequirements = mergePointer(base.HostRequirements, override.HostRequirements)
+	merged.Customizations = mergeInterfaceMaps(base.Customizations, override.Customizations)
+
+	merged.AppPort = mergePointer(base.AppPort, override.AppPort)
+	merged.ContainerEnv = mergeMaps(base.ContainerEnv, override.ContainerEnv)
+	merged.ContainerUser = mergeString(base.ContainerUser, override.ContainerUser)
+	merged.Mounts = appendUnique(base.Mounts, override.Mounts)
+	merged.RunArgs = appendArgs(base.RunArgs, override.RunArgs)
+	merged.ShutdownAction = mergeString(base.ShutdownAction, override.ShutdownAction)
+	merged.OverrideCommand = mergePointer(base.OverrideCommand, override.OverrideCommand)
+	merged.WorkspaceFolder = mergeString(base.WorkspaceFolder, override.WorkspaceFolder)
+	merged.WorkspaceMount = mergeString(base.WorkspaceMount, override.WorkspaceMount)
+
+	merged.Build = mergePointer(base.Build, override.Build)
+	merged.DockerFile = mergeString(base.DockerFile, override.DockerFile)
+	merged.Context = mergeString(base.Context, override.Context)
+
+	merged.Image = mergeString(base.Image, override.Image)
+
+	merged.DockerComposeFile = mergePointer(base.DockerComposeFile, override.DockerComposeFile)
+	merged.Service = mergeString(base.Service, override.Service)
+	merged.RunServices = appendUnique(base.RunServices, override.RunServices)
+
+	return &merged
+}
+
+func mergeString(base, override string) string {
+	if override != "" {
+		return override
+	}
+	return base
+}
+
+func mergePointer[T any](base, override *T) *T {
+	if override != nil {
+		return override
+	}
+	return base
+}
+
+func mergeMaps[V any](base, override map[string]V) map[string]V {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	merged := make(map[string]V, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = value
+	}
+	return merged
+}
+
+// mergeInterfaceMaps merges free-form JSON objects recursively, applying the
+// same rules as Merge to nested objects and arrays
+func mergeInterfaceMaps(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil && override == nil {
+		return nil
+	}
+
+	merged := make(map[string]interface{}, len(base)+len(override))
+	for key, value := range base {
+		merged[key] = value
+	}
+	for key, value := range override {
+		merged[key] = mergeInterfaceValues(merged[key], value)
+	}
+	return merged
+}
+
+func mergeInterfaceValues(base, override interface{}) interface{} {
+	switch o := override.(type) {
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			return mergeInterfaceMaps(b, o)
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			return appendUnique(b, o)
+		}
+	}
+	return override
+}
+
+// appendUnique appends the values in override that are not already in base
+func appendUnique[T any](base, override []T) []T {
+	if len(override) == 0 {
+		return base
+	}
+
+	merged := append([]T{}, base...)
+	for _, value := range override {
+		found := false
+		for _, existing := range merged {
+			if fmt.Sprint(existing) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, value)
+		}
+	}
+	return merged
+}
+
+// appendArgs appends command line arguments as-is, since flags can legitimately repeat
+func appendArgs(base, override []string) []string {
+	if len(override) == 0 {
+		return base
+	}
+	return append(append([]string{}, base...), override...)
+}
+
+// mergeCommands combines lifecycle commands so that both run. Object-form
+// commands already run in parallel, so their entries are combined, and the
+// other side is added under a "base" or "override" key. Otherwise the commands
+// are chained into a single shell command that runs base, then override.
+func mergeCommands(base, override *CommandValue) *CommandValue {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	if base.IsObject() || override.IsObject() {
+		merged := map[string]interface{}{}
+		addCommandEntries(merged, base, "base")
+		addCommandEntries(merged, override, "override")
+		return &CommandValue{value: merged}
+	}
+
+	return &CommandValue{value: commandShellString(base) + " && " + commandShellString(override)}
+}
+
+func addCommandEntries(entries map[string]interface{}, command *CommandValue, key string) {
+	if command.IsObject() {
+		for k, v := range command.AsObject() {
+			entries[k] = v
+		}
+		return
+	}
+	entries[key] = command.value
+}
+
+// commandShellString renders a string or array command as a shell command
+func commandShellString(command *CommandValue) string {
+	if args, ok := command.TryArray(); ok {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = ShellQuote(arg)
+		}
+		return strings.Join(quoted, " ")
+	}
+	return "(" + command.MustString() + ")"
+}
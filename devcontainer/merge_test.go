@@ -0,0 +1,121 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	tests := []struct {
+		name     string
+		base     string
+		override string
+		expected string
+	}{
+		{
+			name:     "empty configs",
+			base:     `{}`,
+			override: `{}`,
+			expected: `{}`,
+		},
+		{
+			name:     "scalars override",
+			base:     `{"name":"base","image":"ubuntu","remoteUser":"root"}`,
+			override: `{"image":"debian"}`,
+			expected: `{"name":"base","image":"debian","remoteUser":"root"}`,
+		},
+		{
+			name:     "arrays append without duplicates",
+			base:     `{"forwardPorts":[3000,8080],"mounts":["source=a,target=/a,type=bind"]}`,
+			override: `{"forwardPorts":[8080,9000],"mounts":["source=b,target=/b,type=bind"]}`,
+			expected: `{"forwardPorts":[3000,8080,9000],"mounts":["source=a,target=/a,type=bind","source=b,target=/b,type=bind"]}`,
+		},
+		{
+			name:     "run args append as-is",
+			base:     `{"runArgs":["--cap-add","SYS_PTRACE"]}`,
+			override: `{"runArgs":["--cap-add","NET_ADMIN"]}`,
+			expected: `{"runArgs":["--cap-add","SYS_PTRACE","--cap-add","NET_ADMIN"]}`,
+		},
+		{
+			name:     "maps merge",
+			base:     `{"containerEnv":{"A":"1","B":"2"},"features":{"ghcr.io/devcontainers/features/go:1":{}}}`,
+			override: `{"containerEnv":{"B":"3","C":"4"},"features":{"ghcr.io/devcontainers/features/node:1":{"version":"lts"}}}`,
+			expected: `{"containerEnv":{"A":"1","B":"3","C":"4"},"features":{"ghcr.io/devcontainers/features/go:1":{},"ghcr.io/devcontainers/features/node:1":{"version":"lts"}}}`,
+		},
+		{
+			name:     "customizations merge recursively",
+			base:     `{"customizations":{"vscode":{"extensions":["golang.go"],"settings":{"a":1}}}}`,
+			override: `{"customizations":{"vscode":{"extensions":["ms-python.python"],"settings":{"b":2}}}}`,
+			expected: `{"customizations":{"vscode":{"extensions":["golang.go","ms-python.python"],"settings":{"a":1,"b":2}}}}`,
+		},
+		{
+			name:     "string commands accumulate",
+			base:     `{"postCreateCommand":"npm install"}`,
+			override: `{"postCreateCommand":["echo","all done"]}`,
+			expected: `{"postCreateCommand":"(npm install) && 'echo' 'all done'"}`,
+		},
+		{
+			name:     "object commands accumulate",
+			base:     `{"postStartCommand":{"server":"npm start"}}`,
+			override: `{"postStartCommand":"echo started"}`,
+			expected: `{"postStartCommand":{"server":"npm start","override":"echo started"}}`,
+		},
+		{
+			name:     "command only in override",
+			base:     `{}`,
+			override: `{"onCreateCommand":"make"}`,
+			expected: `{"onCreateCommand":"make"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := ParseDevContainer([]byte(tt.base))
+			if err != nil {
+				t.Fatalf("Failed to parse base: %v", err)
+			}
+			override, err := ParseDevContainer([]byte(tt.override))
+			if err != nil {
+				t.Fatalf("Failed to parse override: %v", err)
+			}
+
+			output, err := json.Marshal(Merge(base, override))
+			if err != nil {
+				t.Fatalf("json.Marshal() error = %v", err)
+			}
+
+			var got, expected map[string]interface{}
+			if err := json.Unmarshal(output, &got); err != nil {
+				t.Fatalf("Failed to parse output JSON: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tt.expected), &expected); err != nil {
+				t.Fatalf("Failed to parse expected JSON: %v", err)
+			}
+
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Merge() = %s, want %s", string(output), tt.expected)
+			}
+		})
+	}
+}
+
+func TestMergeDoesNotModifyInputs(t *testing.T) {
+	base := &DevContainerConfig{
+		RunArgs:      []string{"--init"},
+		ContainerEnv: map[string]string{"A": "1"},
+	}
+	override := &DevContainerConfig{
+		RunArgs:      []string{"--name", "box"},
+		ContainerEnv: map[string]string{"A": "2"},
+	}
+
+	Merge(base, override)
+
+	if !reflect.DeepEqual(base.RunArgs, []string{"--init"}) {
+		t.Errorf("Merge() modified base.RunArgs: %v", base.RunArgs)
+	}
+	if base.ContainerEnv["A"] != "1" {
+		t.Errorf("Merge() modified base.ContainerEnv: %v", base.ContainerEnv)
+	}
+}
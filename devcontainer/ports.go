@@ -0,0 +1,76 @@
+package devcontainer
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// PortSpec is a forwardPorts entry: a port of the container, or of another
+// compose service when Host is set
+type PortSpec struct {
+	// Host is the compose service the port belongs to, empty for the container
+	Host string
+	Port int
+}
+
+// ParsePortSpec parses a forwardPorts entry, a port number or a string like
+// "8080" or "db:5432"
+func ParsePortSpec(value interface{}) (PortSpec, error) {
+	var spec PortSpec
+	switch v := value.(type) {
+	case int:
+		spec.Port = v
+	case float64:
+		if v != math.Trunc(v) {
+			return PortSpec{}, fmt.Errorf("port %v is not an integer", v)
+		}
+		spec.Port = int(v)
+	case string:
+		port := v
+		if host, p, ok := strings.Cut(v, ":"); ok {
+			if host == "" {
+				return PortSpec{}, fmt.Errorf("port %q is missing a host", v)
+			}
+			spec.Host, port = host, p
+		}
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return PortSpec{}, fmt.Errorf("invalid port %q", v)
+		}
+		spec.Port = n
+	default:
+		return PortSpec{}, fmt.Errorf("port must be a number or a string, got %T", value)
+	}
+	if spec.Port < 1 || spec.Port > 65535 {
+		return PortSpec{}, fmt.Errorf("port %d is out of range", spec.Port)
+	}
+	return spec, nil
+}
+
+// IsLocal reports whether the port is the container's own
+func (p PortSpec) IsLocal() bool {
+	return p.Host == ""
+}
+
+// String returns the port as forwardPorts writes it
+func (p PortSpec) String() string {
+	if p.IsLocal() {
+		return strconv.Itoa(p.Port)
+	}
+	return p.Host + ":" + strconv.Itoa(p.Port)
+}
+
+// ForwardPortSpecs parses the config's forwardPorts
+func (dc *DevContainerConfig) ForwardPortSpecs() ([]PortSpec, error) {
+	specs := make([]PortSpec, 0, len(dc.ForwardPorts))
+	for _, value := range dc.ForwardPorts {
+		spec, err := ParsePortSpec(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid forwardPorts entry: %w", err)
+		}
+		specs = append(specs, spec)
+	}
+	return specs, nil
+}
@@ -0,0 +1,59 @@
+package devcontainer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePortSpec(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		expected PortSpec
+		wantErr  bool
+	}{
+		{value: float64(3000), expected: PortSpec{Port: 3000}},
+		{value: 8080, expected: PortSpec{Port: 8080}},
+		{value: "8080", expected: PortSpec{Port: 8080}},
+		{value: "db:5432", expected: PortSpec{Host: "db", Port: 5432}},
+		{value: float64(80.5), wantErr: true},
+		{value: float64(0), wantErr: true},
+		{value: 70000, wantErr: true},
+		{value: "http", wantErr: true},
+		{value: ":5432", wantErr: true},
+		{value: "db:", wantErr: true},
+		{value: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePortSpec(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParsePortSpec(%v) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if got != tt.expected {
+			t.Errorf("ParsePortSpec(%v) = %+v, want %+v", tt.value, got, tt.expected)
+		}
+	}
+}
+
+func TestForwardPortSpecs(t *testing.T) {
+	config, err := ParseDevContainer([]byte(`{"forwardPorts": [3000, "db:5432", "8080"]}`))
+	if err != nil {
+		t.Fatalf("ParseDevContainer() error = %v", err)
+	}
+	specs, err := config.ForwardPortSpecs()
+	if err != nil {
+		t.Fatalf("ForwardPortSpecs() error = %v", err)
+	}
+	expected := []PortSpec{{Port: 3000}, {Host: "db", Port: 5432}, {Port: 8080}}
+	if !reflect.DeepEqual(specs, expected) {
+		t.Errorf("ForwardPortSpecs() = %+v, want %+v", specs, expected)
+	}
+	if got := specs[1].String(); got != "db:5432" {
+		t.Errorf("String() = %q, want %q", got, "db:5432")
+	}
+
+	config.ForwardPorts = append(config.ForwardPorts, "db:postgres")
+	if _, err := config.ForwardPortSpecs(); err == nil {
+		t.Error("ForwardPortSpecs() expected an error for an invalid entry")
+	}
+}
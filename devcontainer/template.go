@@ -0,0 +1,99 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// TemplateOption is an option declared in devcontainer-template.json
+type TemplateOption struct {
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Proposals   []string    `json:"proposals,omitempty"`
+	Enum        []string    `json:"enum,omitempty"`
+	Default     interface{} `json:"default,omitempty"`
+}
+
+// DefaultString returns the option's default as it is substituted into template files
+func (o TemplateOption) DefaultString() string {
+	if o.Default == nil {
+		return ""
+	}
+	return fmt.Sprint(o.Default)
+}
+
+// Validate checks value against the option's type and enum
+func (o TemplateOption) Validate(value string) error {
+	if len(o.Enum) > 0 && !slices.Contains(o.Enum, value) {
+		return fmt.Errorf("%s is not one of %s", value, strings.Join(o.Enum, ", "))
+	}
+	if o.Type == "boolean" && value != "true" && value != "false" {
+		return fmt.Errorf("%s is not true or false", value)
+	}
+	return nil
+}
+
+// TemplateMetadata is the devcontainer-template.json file at the root of a template
+type TemplateMetadata struct {
+	ID          string                    `json:"id"`
+	Version     string                    `json:"version,omitempty"`
+	Name        string                    `json:"name,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Options     map[string]TemplateOption `json:"options,omitempty"`
+	// OptionalPaths are files the user can choose to leave out
+	OptionalPaths []string `json:"optionalPaths,omitempty"`
+}
+
+// ParseTemplateMetadata parses a devcontainer-template.json file
+func ParseTemplateMetadata(data []byte) (*TemplateMetadata, error) {
+	var metadata TemplateMetadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, fmt.Errorf("error parsing devcontainer-template.json: %v", err)
+	}
+	return &metadata, nil
+}
+
+// OptionNames returns the template's option names in a stable order
+func (m *TemplateMetadata) OptionNames() []string {
+	names := make([]string, 0, len(m.Options))
+	for name := range m.Options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ResolveOptions fills in defaults for options not in values and validates
+// the result, rejecting options the template doesn't declare
+func (m *TemplateMetadata) ResolveOptions(values map[string]string) (map[string]string, error) {
+	resolved := map[string]string{}
+	for name, value := range values {
+		option, ok := m.Options[name]
+		if !ok {
+			return nil, fmt.Errorf("template %s has no option %s", m.ID, name)
+		}
+		if err := option.Validate(value); err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", name, err)
+		}
+		resolved[name] = value
+	}
+
+	for name, option := range m.Options {
+		if _, ok := resolved[name]; !ok {
+			resolved[name] = option.DefaultString()
+		}
+	}
+	return resolved, nil
+}
+
+// ApplyTemplateOptions substitutes ${templateOption:name} placeholders in a template file
+func ApplyTemplateOptions(content []byte, options map[string]string) []byte {
+	result := string(content)
+	for name, value := range options {
+		result = strings.ReplaceAll(result, "${templateOption:"+name+"}", value)
+	}
+	return []byte(result)
+}
@@ -0,0 +1,81 @@
+package devcontainer
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTemplateMetadata(t *testing.T) {
+	metadata, err := ParseTemplateMetadata([]byte(`{
+		"id": "go",
+		"version": "4.0.0",
+		"options": {
+			"imageVariant": {"type": "string", "proposals": ["1.22-bookworm", "1.21-bookworm"], "default": "1.22-bookworm"},
+			"nodeVersion": {"type": "string", "enum": ["none", "lts"], "default": "none"},
+			"installTools": {"type": "boolean", "default": true}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("ParseTemplateMetadata() error = %v", err)
+	}
+
+	if got := metadata.OptionNames(); !reflect.DeepEqual(got, []string{"imageVariant", "installTools", "nodeVersion"}) {
+		t.Errorf("OptionNames() = %v", got)
+	}
+
+	tests := []struct {
+		name     string
+		values   map[string]string
+		expected map[string]string
+		wantErr  bool
+	}{
+		{
+			name:     "defaults",
+			values:   map[string]string{},
+			expected: map[string]string{"imageVariant": "1.22-bookworm", "nodeVersion": "none", "installTools": "true"},
+		},
+		{
+			name:     "proposals are not enforced",
+			values:   map[string]string{"imageVariant": "1.20", "nodeVersion": "lts"},
+			expected: map[string]string{"imageVariant": "1.20", "nodeVersion": "lts", "installTools": "true"},
+		},
+		{
+			name:    "enum is enforced",
+			values:  map[string]string{"nodeVersion": "18"},
+			wantErr: true,
+		},
+		{
+			name:    "boolean",
+			values:  map[string]string{"installTools": "yes"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown option",
+			values:  map[string]string{"color": "blue"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := metadata.ResolveOptions(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveOptions() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("ResolveOptions() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestApplyTemplateOptions(t *testing.T) {
+	content := []byte(`{"image": "mcr.microsoft.com/devcontainers/go:${templateOption:imageVariant}", "other": "${templateOption:missing}"}`)
+
+	got := ApplyTemplateOptions(content, map[string]string{"imageVariant": "1.22"})
+
+	expected := `{"image": "mcr.microsoft.com/devcontainers/go:1.22", "other": "${templateOption:missing}"}`
+	if string(got) != expected {
+		t.Errorf("ApplyTemplateOptions() = %s, want %s", got, expected)
+	}
+}
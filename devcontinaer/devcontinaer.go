@@ -0,0 +1,43 @@
+// Package devcontinaer forwards to the devcontainer package under its old,
+// misspelled import path.
+//
+// Deprecated: import github.com/mikeocool/tape/devcontainer instead. This
+// package will be removed in a future release.
+package devcontinaer
+
+import "github.com/mikeocool/tape/devcontainer"
+
+type (
+	AppPortValue             = devcontainer.AppPortValue
+	BuildOptions             = devcontainer.BuildOptions
+	CodespacesCustomizations = devcontainer.CodespacesCustomizations
+	CommandValue             = devcontainer.CommandValue
+	ComposeFileValue         = devcontainer.ComposeFileValue
+	DevContainerConfig       = devcontainer.DevContainerConfig
+	Feature                  = devcontainer.Feature
+	FeatureOptions           = devcontainer.FeatureOptions
+	FeatureRef               = devcontainer.FeatureRef
+	FeatureRefKind           = devcontainer.FeatureRefKind
+	GPURequirements          = devcontainer.GPURequirements
+	GPUValue                 = devcontainer.GPUValue
+	HostRequirements         = devcontainer.HostRequirements
+	MountObject              = devcontainer.MountObject
+	MountValue               = devcontainer.MountValue
+	PortAttributes           = devcontainer.PortAttributes
+	VSCodeCustomizations     = devcontainer.VSCodeCustomizations
+)
+
+const (
+	FeatureRefOCI     = devcontainer.FeatureRefOCI
+	FeatureRefLocal   = devcontainer.FeatureRefLocal
+	FeatureRefTarball = devcontainer.FeatureRefTarball
+)
+
+var (
+	LoadDevContainerFromFile = devcontainer.LoadDevContainerFromFile
+	Merge                    = devcontainer.Merge
+	NewMountObject           = devcontainer.NewMountObject
+	NewMountString           = devcontainer.NewMountString
+	ParseDevContainer        = devcontainer.ParseDevContainer
+	ParseFeatureRef          = devcontainer.ParseFeatureRef
+)
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"os"
+
 	"github.com/mikeocool/tape/cli"
 )
 
 func main() {
-	cli.Execute()
+	os.Exit(cli.HandleError(cli.Execute()))
 }
@@ -0,0 +1,86 @@
+package ssh
+
+import (
+	"net"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestParsePtyRequest(t *testing.T) {
+	payload := ssh.Marshal(&struct {
+		Term     string
+		Columns  uint32
+		Rows     uint32
+		Width    uint32
+		Height   uint32
+		Modelist string
+	}{"xterm", 120, 40, 0, 0, ""})
+
+	termType, w, h, ok := parsePtyRequest(payload)
+	if !ok || termType != "xterm" || w != 120 || h != 40 {
+		t.Errorf("parsePtyRequest() = %q, %d, %d, %v, want xterm, 120, 40, true", termType, w, h, ok)
+	}
+
+	if _, _, _, ok := parsePtyRequest(payload[:6]); ok {
+		t.Error("parsePtyRequest() of a truncated request should fail")
+	}
+}
+
+func TestAcquireSession(t *testing.T) {
+	if !acquireSession("web", 2) || !acquireSession("web", 2) {
+		t.Fatal("acquireSession() under the limit should succeed")
+	}
+	if acquireSession("web", 2) {
+		t.Error("acquireSession() at the limit should fail")
+	}
+	if !acquireSession("api", 2) {
+		t.Error("acquireSession() of another environment should succeed")
+	}
+	if got := EnvironmentSessions(); got["web"] != 2 || got["api"] != 1 {
+		t.Errorf("EnvironmentSessions() = %v, want web 2 and api 1", got)
+	}
+
+	releaseSession("web")
+	if !acquireSession("web", 2) {
+		t.Error("acquireSession() after a release should succeed")
+	}
+
+	releaseSession("web")
+	releaseSession("web")
+	releaseSession("api")
+	if got := EnvironmentSessions(); len(got) != 0 {
+		t.Errorf("EnvironmentSessions() after releasing all = %v, want none", got)
+	}
+	if active, _ := Sessions(); active != 0 {
+		t.Errorf("Sessions() active = %d, want 0", active)
+	}
+}
+
+func TestSFTPServerCommand(t *testing.T) {
+	cmd := sftpServerCommand(false)
+	if cmd[len(cmd)-1] != "sftp-server" {
+		t.Errorf("sftpServerCommand(false) = %q, want no arguments for sftp-server", cmd)
+	}
+	cmd = sftpServerCommand(true)
+	if cmd[len(cmd)-1] != "-R" {
+		t.Errorf("sftpServerCommand(true) = %q, want sftp-server started with -R", cmd)
+	}
+}
+
+func TestIsLoopback(t *testing.T) {
+	tests := []struct {
+		addr net.Addr
+		want bool
+	}{
+		{&net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 50000}, true},
+		{&net.TCPAddr{IP: net.ParseIP("::1"), Port: 50000}, true},
+		{&net.TCPAddr{IP: net.ParseIP("192.168.1.20"), Port: 50000}, false},
+		{&net.UnixAddr{Name: "/tmp/sock", Net: "unix"}, false},
+	}
+	for _, tt := range tests {
+		if got := isLoopback(tt.addr); got != tt.want {
+			t.Errorf("isLoopback(%v) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}